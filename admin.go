@@ -0,0 +1,70 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+const (
+	localNode     = "_local"
+	adminsSection = "admins"
+)
+
+// EnsureAdmin ensures that user is a server admin on the local node, creating
+// it with password pass if it does not exist. It returns true if the admin was
+// created.
+//
+// It is intended for provisioning fresh CouchDB instances, which start with
+// no admins, in "admin party" mode, where every request has admin rights. The
+// first admin is created via the node's admins configuration section, which
+// ends admin party: requests made without credentials are then rejected, so
+// the client should be re-authenticated as user afterwards. An existing
+// admin's password is not changed.
+//
+// A status 401 or 403 error means the node is already secured, and the client
+// is not authenticated as an admin. If the admin is created but cannot then
+// be read back, created is true, and the error is returned.
+func (c *Client) EnsureAdmin(ctx context.Context, user, pass string) (created bool, err error) {
+	if user == "" {
+		return false, missingArg("user")
+	}
+	if pass == "" {
+		return false, missingArg("pass")
+	}
+	admins, err := c.ConfigSection(ctx, localNode, adminsSection)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := admins[user]; ok {
+		return false, nil
+	}
+	if _, err := c.SetConfigValue(ctx, localNode, adminsSection, user, pass); err != nil {
+		return false, err
+	}
+	// Read the admin back, to verify that it was stored. If it was the
+	// first, the node is now secured, so an unauthenticated client is
+	// refused, which is verification enough.
+	hash, err := c.ConfigValue(ctx, localNode, adminsSection, user)
+	switch code := StatusCode(err); {
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		return true, nil
+	case err != nil:
+		return true, err
+	case hash == "":
+		return true, &Error{HTTPStatus: http.StatusBadGateway, Message: fmt.Sprintf("kivik: admin %q not found after creation", user)}
+	}
+	return true, nil
+}
@@ -0,0 +1,146 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestEnsureAdmin(t *testing.T) {
+	type tt struct {
+		client     *Client
+		user, pass string
+		created    bool
+		status     int
+		err        string
+	}
+	// adminParty mocks a node's admins section, which starts with admins,
+	// and is secured against reads once non-empty, if secure is true.
+	adminParty := func(admins driver.ConfigSection, secure bool) *Client {
+		return &Client{driverClient: &mock.Configer{
+			ConfigSectionFunc: func(_ context.Context, node, section string) (driver.ConfigSection, error) {
+				if node != "_local" || section != "admins" {
+					return nil, fmt.Errorf("Unexpected section: %s/%s", node, section)
+				}
+				return admins, nil
+			},
+			SetConfigValueFunc: func(_ context.Context, _, _, key, value string) (string, error) {
+				admins[key] = "-pbkdf2-" + value
+				return "", nil
+			},
+			ConfigValueFunc: func(_ context.Context, _, _, key string) (string, error) {
+				if secure {
+					return "", &Error{HTTPStatus: http.StatusUnauthorized, Message: "unauthorized"}
+				}
+				return admins[key], nil
+			},
+		}}
+	}
+	tests := testy.NewTable()
+	tests.Add("no user", tt{
+		client: &Client{},
+		pass:   "abc",
+		status: http.StatusBadRequest,
+		err:    "kivik: user required",
+	})
+	tests.Add("no password", tt{
+		client: &Client{},
+		user:   "admin",
+		status: http.StatusBadRequest,
+		err:    "kivik: pass required",
+	})
+	tests.Add("config not supported", tt{
+		client: &Client{driverClient: &mock.Client{}},
+		user:   "admin",
+		pass:   "abc",
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support Config interface",
+	})
+	tests.Add("secured", tt{
+		client: &Client{driverClient: &mock.Configer{
+			ConfigSectionFunc: func(context.Context, string, string) (driver.ConfigSection, error) {
+				return nil, &Error{HTTPStatus: http.StatusUnauthorized, Message: "You are not a server admin."}
+			},
+		}},
+		user:   "admin",
+		pass:   "abc",
+		status: http.StatusUnauthorized,
+		err:    "You are not a server admin.",
+	})
+	tests.Add("admin party", tt{
+		client:  adminParty(driver.ConfigSection{}, true),
+		user:    "admin",
+		pass:    "abc",
+		created: true,
+	})
+	tests.Add("additional admin", tt{
+		client:  adminParty(driver.ConfigSection{"root": "-pbkdf2-xxx"}, false),
+		user:    "admin",
+		pass:    "abc",
+		created: true,
+	})
+	tests.Add("already exists", tt{
+		client: adminParty(driver.ConfigSection{"admin": "-pbkdf2-xxx"}, false),
+		user:   "admin",
+		pass:   "abc",
+	})
+	tests.Add("set failure", tt{
+		client: &Client{driverClient: &mock.Configer{
+			ConfigSectionFunc: func(context.Context, string, string) (driver.ConfigSection, error) {
+				return driver.ConfigSection{}, nil
+			},
+			SetConfigValueFunc: func(context.Context, string, string, string, string) (string, error) {
+				return "", errors.New("set failed")
+			},
+		}},
+		user:   "admin",
+		pass:   "abc",
+		status: http.StatusInternalServerError,
+		err:    "set failed",
+	})
+	tests.Add("not stored", tt{
+		client: &Client{driverClient: &mock.Configer{
+			ConfigSectionFunc: func(context.Context, string, string) (driver.ConfigSection, error) {
+				return driver.ConfigSection{}, nil
+			},
+			SetConfigValueFunc: func(context.Context, string, string, string, string) (string, error) {
+				return "", nil
+			},
+			ConfigValueFunc: func(context.Context, string, string, string) (string, error) {
+				return "", nil
+			},
+		}},
+		user:    "admin",
+		pass:    "abc",
+		created: true,
+		status:  http.StatusBadGateway,
+		err:     `kivik: admin "admin" not found after creation`,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		created, err := tt.client.EnsureAdmin(context.Background(), tt.user, tt.pass)
+		if created != tt.created {
+			t.Errorf("Unexpected created: %t", created)
+		}
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
@@ -19,12 +19,25 @@ import (
 	"net/http"
 
 	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
 )
 
+// OptionSplitBulkDocs, when true, causes BulkDocs to split a batch which the
+// server rejects with status 413 (request entity too large) in half, and to
+// retry each half, recursively, until every batch is accepted. A document
+// which is too large on its own is not stored, and its result has a
+// *RequestTooLargeError as UpdateErr. Once a batch has been split, any other
+// failure of one of its parts is likewise reported as the UpdateErr of each of
+// that part's documents, since the other parts may have been stored. The
+// option is interpreted by Kivik, and not passed to the driver.
+const OptionSplitBulkDocs = "kivik.split_bulk_docs"
+
 // BulkResults is an iterator over the results of a BulkDocs query.
 type BulkResults struct {
 	*iter
 	bulki driver.BulkResults
+	// requireQuorum is the value of OptionRequireQuorum.
+	requireQuorum bool
 }
 
 // Next returns the next BulkResult from the feed. If an error occurs, it will
@@ -83,14 +96,31 @@ func (r *BulkResults) Rev() string {
 
 // UpdateErr returns the error associated with the current result, or nil
 // if none. Do not confuse this with Err, which returns an error for the
-// iterator itself.
+// iterator itself. With OptionRequireQuorum, an update accepted without
+// quorum is reported by an *AcceptedError.
 func (r *BulkResults) UpdateErr() error {
 	runlock, err := r.rlock()
 	if err != nil {
 		return nil
 	}
 	defer runlock()
-	return r.curVal.(*driver.BulkResult).Error
+	result := r.curVal.(*driver.BulkResult)
+	if result.Error == nil && result.Accepted && r.requireQuorum {
+		return &AcceptedError{Op: "BulkDocs", DocID: result.ID, Rev: result.Rev}
+	}
+	return result.Error
+}
+
+// Accepted returns true if the current result is an update which the server
+// accepted without confirming that it was committed to a quorum of nodes.
+// See OptionRequireQuorum.
+func (r *BulkResults) Accepted() bool {
+	runlock, err := r.rlock()
+	if err != nil {
+		return false
+	}
+	defer runlock()
+	return r.curVal.(*driver.BulkResult).Accepted
 }
 
 // BulkDocs allows you to create and update multiple documents at the same time
@@ -100,6 +130,9 @@ func (r *BulkResults) UpdateErr() error {
 //
 // As with Put, each individual document may be a JSON-marshable object, or a
 // raw JSON string in a []byte, json.RawMessage, or io.Reader.
+//
+// If the server rejects the request as too large, a *RequestTooLargeError is
+// returned, unless OptionSplitBulkDocs is set.
 func (db *DB) BulkDocs(ctx context.Context, docs []interface{}, options ...Options) (*BulkResults, error) {
 	docsi, err := docsInterfaceSlice(docs)
 	if err != nil {
@@ -108,31 +141,126 @@ func (db *DB) BulkDocs(ctx context.Context, docs []interface{}, options ...Optio
 	if len(docsi) == 0 {
 		return nil, &Error{HTTPStatus: http.StatusBadRequest, Err: errors.New("kivik: no documents provided")}
 	}
-	opts := mergeOptions(options...)
+	for _, doc := range docsi {
+		if err := db.checkDocSize(doc); err != nil {
+			return nil, err
+		}
+	}
+	opts := db.mergeOptions(options...)
+	split, err := queryopts.Bool(opts, OptionSplitBulkDocs)
+	if err != nil {
+		return nil, err
+	}
+	delete(opts, OptionSplitBulkDocs)
+	requireQuorum, err := requireQuorumOption(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, op := db.startOp(ctx, "BulkDocs", "_bulk_docs")
+	bulki, err := db.bulkDocs(ctx, docsi, opts, split)
+	if err != nil {
+		op.done()
+		return nil, err
+	}
+	results := newBulkResults(ctx, bulki)
+	results.requireQuorum = requireQuorum
+	db.measure(results.iter, "BulkDocs", "_bulk_docs")
+	op.doneOnClose(results.iter)
+	return results, nil
+}
+
+func (db *DB) bulkDocs(ctx context.Context, docsi []interface{}, opts map[string]interface{}, split bool) (driver.BulkResults, error) {
 	if bulkDocer, ok := db.driverDB.(driver.BulkDocer); ok {
-		bulki, err := bulkDocer.BulkDocs(ctx, docsi, opts)
+		if split {
+			results, err := db.splitBulkDocs(ctx, bulkDocer, docsi, opts)
+			if err != nil {
+				return nil, err
+			}
+			return &emulatedBulkResults{results}, nil
+		}
+		bulki, err := bulkDocer.BulkDocs(ctx, docsi, driverOptions(opts))
 		if err != nil {
-			return nil, err
+			return nil, db.tooLarge(ctx, opError("BulkDocs", err))
 		}
-		return newBulkResults(ctx, bulki), nil
+		return bulki, nil
 	}
+	// Each write requires quorum, so that a write accepted without it is
+	// reported by an *AcceptedError, which is recorded as Accepted.
+	writeOpts := mergeOptions(opts, Options{OptionRequireQuorum: true})
 	var results []driver.BulkResult
 	for _, doc := range docsi {
 		var err error
 		var id, rev string
 		if docID, ok := extractDocID(doc); ok {
 			id = docID
-			rev, err = db.Put(ctx, id, doc, opts)
+			rev, err = db.Put(ctx, id, doc, writeOpts)
 		} else {
-			id, rev, err = db.CreateDoc(ctx, doc, opts)
+			id, rev, err = db.CreateDoc(ctx, doc, writeOpts)
+		}
+		var accepted *AcceptedError
+		if errors.As(err, &accepted) {
+			err = nil
 		}
 		results = append(results, driver.BulkResult{
-			ID:    id,
-			Rev:   rev,
-			Error: err,
+			ID:       id,
+			Rev:      rev,
+			Error:    err,
+			Accepted: accepted != nil,
 		})
 	}
-	return newBulkResults(ctx, &emulatedBulkResults{results}), nil
+	return &emulatedBulkResults{results}, nil
+}
+
+// splitBulkDocs stores docs with bulkDocer, splitting the batch in half and
+// retrying each half whenever it is rejected as too large.
+func (db *DB) splitBulkDocs(ctx context.Context, bulkDocer driver.BulkDocer, docs []interface{}, opts map[string]interface{}) ([]driver.BulkResult, error) {
+	bulki, err := bulkDocer.BulkDocs(ctx, docs, driverOptions(opts))
+	if err == nil {
+		return readBulkResults(bulki)
+	}
+	err = opError("BulkDocs", err)
+	if StatusCode(err) != http.StatusRequestEntityTooLarge {
+		return nil, err
+	}
+	if len(docs) == 1 {
+		id, _ := extractDocID(docs[0])
+		return []driver.BulkResult{{ID: id, Error: db.tooLarge(ctx, err)}}, nil
+	}
+	half := len(docs) / 2
+	results := db.splitBulkHalf(ctx, bulkDocer, docs[:half], opts)
+	return append(results, db.splitBulkHalf(ctx, bulkDocer, docs[half:], opts)...), nil
+}
+
+// splitBulkHalf stores one half of a split batch. As the other half may
+// already have been stored, a failure is recorded as the error of each of
+// the half's documents, rather than failing the whole batch.
+func (db *DB) splitBulkHalf(ctx context.Context, bulkDocer driver.BulkDocer, docs []interface{}, opts map[string]interface{}) []driver.BulkResult {
+	results, err := db.splitBulkDocs(ctx, bulkDocer, docs, opts)
+	if err == nil {
+		return results
+	}
+	results = make([]driver.BulkResult, len(docs))
+	for i, doc := range docs {
+		id, _ := extractDocID(doc)
+		results[i] = driver.BulkResult{ID: id, Error: err}
+	}
+	return results
+}
+
+// readBulkResults reads and closes bulki.
+func readBulkResults(bulki driver.BulkResults) ([]driver.BulkResult, error) {
+	defer bulki.Close() // nolint: errcheck
+	var results []driver.BulkResult
+	for {
+		var result driver.BulkResult
+		if err := bulki.Next(&result); err != nil {
+			if err == io.EOF {
+				return results, nil
+			}
+			return nil, err
+		}
+		results = append(results, result)
+	}
 }
 
 type emulatedBulkResults struct {
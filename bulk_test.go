@@ -261,7 +261,7 @@ func TestBulkDocs(t *testing.T) { // nolint: gocyclo
 							results: []driver.BulkResult{
 								{ID: "foo", Rev: "2-xxx"},
 								{ID: "newDocID", Rev: "1-xxx"},
-								{ID: "error", Error: errors.New("error")},
+								{ID: "error", Error: &Error{HTTPStatus: http.StatusInternalServerError, Op: "Put", Reason: "error", Err: errors.New("error")}},
 							},
 						},
 					},
@@ -271,7 +271,7 @@ func TestBulkDocs(t *testing.T) { // nolint: gocyclo
 					results: []driver.BulkResult{
 						{ID: "foo", Rev: "2-xxx"},
 						{ID: "newDocID", Rev: "1-xxx"},
-						{ID: "error", Error: errors.New("error")},
+						{ID: "error", Error: &Error{HTTPStatus: http.StatusInternalServerError, Op: "Put", Reason: "error", Err: errors.New("error")}},
 					},
 				},
 			},
@@ -417,3 +417,158 @@ func TestBulkResultsGetters(t *testing.T) {
 		})
 	})
 }
+
+func TestBulkDocsTooLarge(t *testing.T) {
+	tooLarge := &Error{HTTPStatus: http.StatusRequestEntityTooLarge, Message: "too large"}
+	// bulkDocer rejects batches of more than two documents, and any batch
+	// including the document "huge". Batches of two or fewer including the
+	// document "broken" fail with a 500.
+	bulkDocer := func(batches *[]int) *mock.BulkDocer {
+		return &mock.BulkDocer{
+			BulkDocsFunc: func(_ context.Context, docs []interface{}, opts map[string]interface{}) (driver.BulkResults, error) {
+				if _, ok := opts[OptionSplitBulkDocs]; ok {
+					return nil, errors.New("split option passed to driver")
+				}
+				*batches = append(*batches, len(docs))
+				var results []driver.BulkResult
+				for _, doc := range docs {
+					id := doc.(map[string]string)["_id"]
+					if id == "huge" || len(docs) > 2 {
+						return nil, tooLarge
+					}
+					if id == "broken" {
+						return nil, &Error{HTTPStatus: http.StatusInternalServerError, Message: "broken"}
+					}
+					results = append(results, driver.BulkResult{ID: id, Rev: "1-xxx"})
+				}
+				return &emulatedBulkResults{results}, nil
+			},
+		}
+	}
+	configer := &mock.Configer{
+		ConfigValueFunc: func(_ context.Context, node, section, key string) (string, error) {
+			if node != "_local" || section != "chttpd" || key != "max_http_request_size" {
+				return "", fmt.Errorf("unexpected config value %s/%s/%s", node, section, key)
+			}
+			return "4294967296", nil
+		},
+	}
+	docs := func(ids ...string) []interface{} {
+		docs := make([]interface{}, len(ids))
+		for i, id := range ids {
+			docs[i] = map[string]string{"_id": id}
+		}
+		return docs
+	}
+	type result struct {
+		ID, Rev        string
+		Status         int
+		MaxRequestSize int64
+	}
+	type tt struct {
+		client   driver.Client
+		docs     []interface{}
+		options  Options
+		batches  []int
+		expected []result
+		status   int
+		err      string
+		maxSize  int64
+	}
+	tests := testy.NewTable()
+	tests.Add("too large", tt{
+		client:  configer,
+		docs:    docs("a", "b", "c"),
+		batches: []int{3},
+		status:  http.StatusRequestEntityTooLarge,
+		err:     "too large (max_http_request_size is 4294967296 bytes)",
+		maxSize: 4294967296,
+	})
+	tests.Add("too large, config unavailable", tt{
+		client:  &mock.Client{},
+		docs:    docs("a", "b", "c"),
+		batches: []int{3},
+		status:  http.StatusRequestEntityTooLarge,
+		err:     "too large",
+	})
+	tests.Add("invalid split option", tt{
+		client:  configer,
+		docs:    docs("a"),
+		options: Options{OptionSplitBulkDocs: "sometimes"},
+		status:  http.StatusBadRequest,
+		err:     `invalid value for kivik.split_bulk_docs: "sometimes"`,
+	})
+	tests.Add("split", tt{
+		client:  configer,
+		docs:    docs("a", "b", "c", "d", "e"),
+		options: Options{OptionSplitBulkDocs: true},
+		batches: []int{5, 2, 3, 1, 2},
+		expected: []result{
+			{ID: "a", Rev: "1-xxx"},
+			{ID: "b", Rev: "1-xxx"},
+			{ID: "c", Rev: "1-xxx"},
+			{ID: "d", Rev: "1-xxx"},
+			{ID: "e", Rev: "1-xxx"},
+		},
+	})
+	tests.Add("split with oversized document", tt{
+		client:  configer,
+		docs:    docs("a", "huge", "b"),
+		options: Options{OptionSplitBulkDocs: true},
+		batches: []int{3, 1, 2, 1, 1},
+		expected: []result{
+			{ID: "a", Rev: "1-xxx"},
+			{ID: "huge", Status: http.StatusRequestEntityTooLarge, MaxRequestSize: 4294967296},
+			{ID: "b", Rev: "1-xxx"},
+		},
+	})
+	tests.Add("split with failed half", tt{
+		client:  configer,
+		docs:    docs("a", "b", "c", "broken"),
+		options: Options{OptionSplitBulkDocs: true},
+		batches: []int{4, 2, 2},
+		expected: []result{
+			{ID: "a", Rev: "1-xxx"},
+			{ID: "b", Rev: "1-xxx"},
+			{ID: "c", Status: http.StatusInternalServerError},
+			{ID: "broken", Status: http.StatusInternalServerError},
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		var batches []int
+		db := &DB{
+			client:   &Client{driverClient: tt.client},
+			driverDB: bulkDocer(&batches),
+		}
+		rows, err := db.BulkDocs(context.Background(), tt.docs, tt.options)
+		if d := testy.DiffInterface(tt.batches, batches); d != nil {
+			t.Errorf("Unexpected batches:\n%s", d)
+		}
+		if tt.maxSize != 0 {
+			var tooLarge *RequestTooLargeError
+			if !errors.As(err, &tooLarge) || tooLarge.MaxRequestSize != tt.maxSize {
+				t.Errorf("Unexpected error: %#v", err)
+			}
+		}
+		testy.StatusError(t, tt.err, tt.status, err)
+		var results []result
+		for rows.Next() {
+			r := result{ID: rows.ID(), Rev: rows.Rev()}
+			if err := rows.UpdateErr(); err != nil {
+				r.Status = StatusCode(err)
+			}
+			var tooLarge *RequestTooLargeError
+			if errors.As(rows.UpdateErr(), &tooLarge) {
+				r.MaxRequestSize = tooLarge.MaxRequestSize
+			}
+			results = append(results, r)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(tt.expected, results); d != nil {
+			t.Error(d)
+		}
+	})
+}
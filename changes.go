@@ -15,6 +15,9 @@ package kivik
 import (
 	"context"
 	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
 
 	"github.com/go-kivik/kivik/v4/driver"
 )
@@ -23,6 +26,8 @@ import (
 type Changes struct {
 	*iter
 	changesi driver.Changes
+	// useNumber is set by OptionUseNumber.
+	useNumber bool
 }
 
 // Next prepares the next result value for reading. It returns true on success
@@ -47,12 +52,22 @@ func (c *Changes) Close() error {
 	return c.iter.Close()
 }
 
+// Stats returns the consumer's progress through the changes feed so far. Lag
+// and MaxLag show whether the consumer keeps up with the feed.
+func (c *Changes) Stats() IteratorStats {
+	return c.metrics.snapshot()
+}
+
 type changesIterator struct{ driver.Changes }
 
 var _ iterator = &changesIterator{}
 
 func (c *changesIterator) Next(i interface{}) error { return c.Changes.Next(i.(*driver.Change)) }
 
+func (c *changesIterator) measure(i interface{}, _ *iterMetrics) int64 {
+	return rawSize(i.(*driver.Change).Doc)
+}
+
 func newChanges(ctx context.Context, changesi driver.Changes) *Changes {
 	return &Changes{
 		iter:     newIterator(ctx, &changesIterator{changesi}, &driver.Change{}),
@@ -65,6 +80,12 @@ func (c *Changes) Changes() []string {
 	return c.curVal.(*driver.Change).Changes
 }
 
+// Leaves returns the leaf revisions listed by the current change. Unless the
+// feed was requested with StyleAllDocs, this is only the winning revision.
+func (c *Changes) Leaves() LeafRevs {
+	return newLeafRevs(c.curVal.(*driver.Change).Changes)
+}
+
 // Deleted returns true if the change relates to a deleted document.
 func (c *Changes) Deleted() bool {
 	return c.curVal.(*driver.Change).Deleted
@@ -83,18 +104,151 @@ func (c *Changes) ScanDoc(dest interface{}) error {
 		return err
 	}
 	defer runlock()
-	return json.Unmarshal(c.curVal.(*driver.Change).Doc, dest)
+	return unmarshalJSON(c.curVal.(*driver.Change).Doc, dest, c.useNumber)
+}
+
+// ChangeEvent is a single result from the changes feed, as decoded by
+// ScanEvent.
+type ChangeEvent struct {
+	// ID is the ID of the changed document.
+	ID string `json:"id"`
+	// Seq is the update sequence of the change.
+	Seq string `json:"seq"`
+	// Deleted is true if the change deleted the document.
+	Deleted bool `json:"deleted,omitempty"`
+	// Revs lists the leaf revisions of the document. With style=all_docs,
+	// this includes conflicting revisions.
+	Revs []string `json:"revs"`
+	// Doc is the raw JSON document, when include_docs=true was requested.
+	Doc json.RawMessage `json:"doc,omitempty"`
+}
+
+// Leaves returns the leaf revisions of the event.
+func (e *ChangeEvent) Leaves() LeafRevs {
+	return newLeafRevs(e.Revs)
+}
+
+// LeafRevs are the leaf revisions of a document listed by a change.
+type LeafRevs struct {
+	// Winner is the winning revision of the document.
+	Winner string
+	// Others are the other leaf revisions of the document, which are only
+	// listed with StyleAllDocs. They are conflicting revisions, or deleted
+	// former conflicts.
+	Others []string
+}
+
+func newLeafRevs(revs []string) LeafRevs {
+	if len(revs) == 0 {
+		return LeafRevs{}
+	}
+	leaves := LeafRevs{Winner: revs[0]}
+	if len(revs) > 1 {
+		leaves.Others = append([]string(nil), revs[1:]...)
+	}
+	return leaves
+}
+
+// Conflicted returns true if the document has leaf revisions besides the
+// winner. Only changes read with StyleAllDocs list them.
+func (l LeafRevs) Conflicted() bool {
+	return len(l.Others) > 0
+}
+
+// ScanDoc unmarshals the document of the event into dest. It returns a status
+// 404 error if the event does not include the document.
+func (e *ChangeEvent) ScanDoc(dest interface{}) error {
+	if len(e.Doc) == 0 {
+		return &Error{HTTPStatus: http.StatusNotFound, Message: "kivik: change does not include doc; use include_docs=true"}
+	}
+	return unmarshalJSON(e.Doc, dest, false)
+}
+
+// ScanEvent copies the current change into event. The document, if any, is
+// copied, so event remains valid after the next call to Next.
+func (c *Changes) ScanEvent(event *ChangeEvent) error {
+	runlock, err := c.rlock()
+	if err != nil {
+		return err
+	}
+	defer runlock()
+	change := c.curVal.(*driver.Change)
+	*event = ChangeEvent{
+		ID:      change.ID,
+		Seq:     change.Seq,
+		Deleted: change.Deleted,
+		Revs:    append([]string(nil), change.Changes...),
+	}
+	if len(change.Doc) > 0 {
+		event.Doc = append(json.RawMessage(nil), change.Doc...)
+	}
+	return nil
+}
+
+// ChangesDocIDs returns options which limit the changes feed to the listed
+// documents, using the built-in _doc_ids filter.
+func ChangesDocIDs(docIDs ...string) Options {
+	return Options{"filter": "_doc_ids", "doc_ids": docIDs}
+}
+
+// ChangesSelector returns options which limit the changes feed to documents
+// matching a Mango selector, using the built-in _selector filter. selector
+// must marshal to a JSON object.
+func ChangesSelector(selector interface{}) Options {
+	return Options{"filter": "_selector", "selector": selector}
+}
+
+// ChangesView returns options which limit the changes feed to documents
+// emitted by the map function of a view, using the built-in _view filter. To
+// use a filter function defined in a design document, see Filter.
+func ChangesView(ddoc, view string) Options {
+	return Options{"filter": "_view", "view": strings.TrimPrefix(ddoc, "_design/") + "/" + strings.TrimPrefix(view, "_view/")}
+}
+
+// Style selects the revisions listed in each result of the changes feed.
+type Style string
+
+const (
+	// StyleMainOnly lists only the winning revision of each document. This is
+	// the default.
+	StyleMainOnly Style = "main_only"
+	// StyleAllDocs lists all leaf revisions of each document, winner first,
+	// including conflicting revisions and deleted former conflicts.
+	StyleAllDocs Style = "all_docs"
+)
+
+// ChangesStyle returns the style option of the changes feed.
+func ChangesStyle(style Style) Options {
+	return Options{"style": string(style)}
 }
 
 // Changes returns an iterator over the real-time changes feed. The feed remains
 // open until explicitly closed, or an error is encountered.
 // See http://couchdb.readthedocs.io/en/latest/api/database/changes.html#get--db-_changes
 func (db *DB) Changes(ctx context.Context, options ...Options) (*Changes, error) {
-	changesi, err := db.driverDB.Changes(ctx, mergeOptions(options...))
+	opts := db.mergeOptions(options...)
+	useNumber, err := useNumberOption(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.changesFilter(ctx, opts); err != nil {
+		return nil, err
+	}
+	transforms, err := changesTransformOption(opts)
 	if err != nil {
 		return nil, err
 	}
-	return newChanges(ctx, changesi), nil
+	ctx, op := db.startOp(ctx, "Changes", "_changes")
+	changesi, err := db.driverDB.Changes(ctx, driverOptions(opts))
+	if err != nil {
+		op.done()
+		return nil, opError("Changes", err)
+	}
+	changes := newChanges(ctx, withTransforms(ctx, changesi, transforms))
+	changes.useNumber = useNumber
+	db.measure(changes.iter, "Changes", "_changes")
+	op.doneOnClose(changes.iter)
+	return changes, nil
 }
 
 // Seq returns the Seq of the current result.
@@ -135,3 +289,136 @@ func (c *Changes) ETag() string {
 	}
 	return c.changesi.ETag()
 }
+
+// CatchUpChanges returns an iterator over the changes feed, intended for
+// followers which may be far behind. Rather than reading the entire backlog
+// from a single continuous feed, it first makes repeated feed=normal requests,
+// each with a limit of batchSize, starting from the since option, if any.
+// Once a batch returns fewer than batchSize changes, or reports no pending
+// changes, it switches to a continuous feed from the last sequence read. Any
+// feed and limit options are overridden; all other options are passed through
+// to each request.
+//
+// Drivers which do not report Pending will switch to the continuous feed after
+// the first batch.
+func (db *DB) CatchUpChanges(ctx context.Context, batchSize int, options ...Options) (*Changes, error) {
+	if batchSize < 1 {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: batch size must be positive"}
+	}
+	opts := db.mergeOptions(options...)
+	useNumber, err := useNumberOption(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.changesFilter(ctx, opts); err != nil {
+		return nil, err
+	}
+	transforms, err := changesTransformOption(opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, op := db.startOp(ctx, "CatchUpChanges", "_changes")
+	c := &catchUpChanges{
+		ctx:       ctx,
+		db:        db.driverDB,
+		opts:      opts,
+		batchSize: int64(batchSize),
+	}
+	if since, ok := c.opts["since"].(string); ok {
+		c.lastSeq = since
+	}
+	if err := c.request(false); err != nil {
+		op.done()
+		return nil, opError("CatchUpChanges", err)
+	}
+	changes := newChanges(ctx, withTransforms(ctx, c, transforms))
+	changes.useNumber = useNumber
+	db.measure(changes.iter, "CatchUpChanges", "_changes")
+	op.doneOnClose(changes.iter)
+	return changes, nil
+}
+
+// catchUpChanges is a driver.Changes which reads the changes feed in batches,
+// then continuously.
+type catchUpChanges struct {
+	ctx       context.Context
+	db        driver.DB
+	opts      map[string]interface{}
+	batchSize int64
+
+	changesi driver.Changes
+	live     bool
+	count    int64
+	lastSeq  string
+}
+
+var _ driver.Changes = &catchUpChanges{}
+
+// request starts the next batch, or the continuous feed if live is true.
+func (c *catchUpChanges) request(live bool) error {
+	opts := make(map[string]interface{}, len(c.opts)+3)
+	for k, v := range c.opts {
+		opts[k] = v
+	}
+	if c.lastSeq != "" {
+		opts["since"] = c.lastSeq
+	}
+	if live {
+		opts["feed"] = "continuous"
+		delete(opts, "limit")
+	} else {
+		opts["feed"] = "normal"
+		opts["limit"] = c.batchSize
+	}
+	changesi, err := c.db.Changes(c.ctx, driverOptions(opts))
+	if err != nil {
+		return err
+	}
+	c.changesi = changesi
+	c.live = live
+	c.count = 0
+	return nil
+}
+
+func (c *catchUpChanges) Next(change *driver.Change) error {
+	for {
+		err := c.changesi.Next(change)
+		if err == nil {
+			c.count++
+			c.lastSeq = change.Seq
+			return nil
+		}
+		if err != io.EOF || c.live {
+			return err
+		}
+		if seq := c.changesi.LastSeq(); seq != "" {
+			c.lastSeq = seq
+		}
+		caughtUp := c.count < c.batchSize || c.changesi.Pending() == 0
+		if err := c.changesi.Close(); err != nil {
+			return err
+		}
+		if err := c.request(caughtUp); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *catchUpChanges) Close() error {
+	return c.changesi.Close()
+}
+
+func (c *catchUpChanges) LastSeq() string {
+	if seq := c.changesi.LastSeq(); seq != "" {
+		return seq
+	}
+	return c.lastSeq
+}
+
+func (c *catchUpChanges) Pending() int64 {
+	return c.changesi.Pending()
+}
+
+func (c *catchUpChanges) ETag() string {
+	return c.changesi.ETag()
+}
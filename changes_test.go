@@ -16,6 +16,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"testing"
 
@@ -134,6 +135,17 @@ func TestChangesGetters(t *testing.T) {
 		}
 	})
 
+	t.Run("Leaves", func(t *testing.T) {
+		expected := LeafRevs{Winner: "1", Others: []string{"2", "3"}}
+		result := c.Leaves()
+		if d := testy.DiffInterface(expected, result); d != nil {
+			t.Error(d)
+		}
+		if !result.Conflicted() {
+			t.Error("Expected Conflicted")
+		}
+	})
+
 	t.Run("Deleted", func(t *testing.T) {
 		expected := true
 		result := c.Deleted()
@@ -288,3 +300,237 @@ func TestChanges_uninitialized_should_not_panic(t *testing.T) {
 	_ = c.Pending()
 	_ = c.ETag()
 }
+
+// seqChanges returns a mock changes feed of the changes with the given
+// sequence numbers, reporting pending as the remaining count.
+func seqChanges(seqs []string, pending int64) *mock.Changes {
+	return &mock.Changes{
+		NextFunc: func(change *driver.Change) error {
+			if len(seqs) == 0 {
+				return io.EOF
+			}
+			*change = driver.Change{ID: "doc" + seqs[0], Seq: seqs[0]}
+			seqs = seqs[1:]
+			return nil
+		},
+		CloseFunc:   func() error { return nil },
+		LastSeqFunc: func() string { return "" },
+		PendingFunc: func() int64 { return pending },
+		ETagFunc:    func() string { return "" },
+	}
+}
+
+func TestCatchUpChanges(t *testing.T) {
+	t.Run("invalid batch size", func(t *testing.T) {
+		db := &DB{driverDB: &mock.DB{}}
+		_, err := db.CatchUpChanges(context.Background(), 0)
+		testy.StatusError(t, "kivik: batch size must be positive", http.StatusBadRequest, err)
+	})
+	t.Run("db error", func(t *testing.T) {
+		db := &DB{driverDB: &mock.DB{
+			ChangesFunc: func(_ context.Context, _ map[string]interface{}) (driver.Changes, error) {
+				return nil, errors.New("db error")
+			},
+		}}
+		_, err := db.CatchUpChanges(context.Background(), 10)
+		testy.StatusError(t, "db error", http.StatusInternalServerError, err)
+	})
+	t.Run("catch up then continuous", func(t *testing.T) {
+		feeds := []*mock.Changes{
+			seqChanges([]string{"1", "2"}, 3),
+			seqChanges([]string{"3", "4"}, 1),
+			seqChanges([]string{"5"}, 0),
+			seqChanges([]string{"6"}, 0),
+		}
+		var requests []map[string]interface{}
+		db := &DB{driverDB: &mock.DB{
+			ChangesFunc: func(_ context.Context, opts map[string]interface{}) (driver.Changes, error) {
+				requests = append(requests, opts)
+				feed := feeds[0]
+				feeds = feeds[1:]
+				return feed, nil
+			},
+		}}
+		changes, err := db.CatchUpChanges(context.Background(), 2, Options{
+			"include_docs": true,
+			"feed":         "longpoll",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var seqs []string
+		for changes.Next() {
+			seqs = append(seqs, changes.Seq())
+		}
+		if err := changes.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]string{"1", "2", "3", "4", "5", "6"}, seqs); d != nil {
+			t.Error(d)
+		}
+		expected := []map[string]interface{}{
+			{"include_docs": true, "feed": "normal", "limit": int64(2)},
+			{"include_docs": true, "feed": "normal", "limit": int64(2), "since": "2"},
+			{"include_docs": true, "feed": "normal", "limit": int64(2), "since": "4"},
+			{"include_docs": true, "feed": "continuous", "since": "5"},
+		}
+		if d := testy.DiffInterface(expected, requests); d != nil {
+			t.Error(d)
+		}
+		if seq := changes.LastSeq(); seq != "6" {
+			t.Errorf("Unexpected last seq: %s", seq)
+		}
+	})
+}
+
+func TestChangesScanEvent(t *testing.T) {
+	type tt struct {
+		changes  *Changes
+		expected ChangeEvent
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("success", tt{
+		changes: &Changes{
+			iter: &iter{
+				ready: true,
+				curVal: &driver.Change{
+					ID:      "foo",
+					Seq:     "3-xxx",
+					Deleted: true,
+					Changes: []string{"2-a", "2-b"},
+					Doc:     []byte(`{"_id":"foo"}`),
+				},
+			},
+		},
+		expected: ChangeEvent{
+			ID:      "foo",
+			Seq:     "3-xxx",
+			Deleted: true,
+			Revs:    []string{"2-a", "2-b"},
+			Doc:     []byte(`{"_id":"foo"}`),
+		},
+	})
+	tests.Add("no doc", tt{
+		changes: &Changes{
+			iter: &iter{
+				ready:  true,
+				curVal: &driver.Change{ID: "foo", Seq: "1"},
+			},
+		},
+		expected: ChangeEvent{ID: "foo", Seq: "1"},
+	})
+	tests.Add("closed", tt{
+		changes: &Changes{
+			iter: &iter{closed: true},
+		},
+		status: http.StatusBadRequest,
+		err:    "kivik: Iterator is closed",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		var event ChangeEvent
+		err := tt.changes.ScanEvent(&event)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, event); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestChangeEventScanDoc(t *testing.T) {
+	t.Run("doc", func(t *testing.T) {
+		var doc map[string]interface{}
+		event := &ChangeEvent{Doc: []byte(`{"foo":"bar"}`)}
+		if err := event.ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(map[string]interface{}{"foo": "bar"}, doc); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("no doc", func(t *testing.T) {
+		var doc map[string]interface{}
+		err := (&ChangeEvent{}).ScanDoc(&doc)
+		testy.StatusError(t, "kivik: change does not include doc; use include_docs=true", http.StatusNotFound, err)
+	})
+}
+
+func TestChangesFilterOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  Options
+		expected Options
+	}{
+		{
+			name:     "doc ids",
+			options:  ChangesDocIDs("a", "b"),
+			expected: Options{"filter": "_doc_ids", "doc_ids": []string{"a", "b"}},
+		},
+		{
+			name:     "selector",
+			options:  ChangesSelector(map[string]interface{}{"type": "user"}),
+			expected: Options{"filter": "_selector", "selector": map[string]interface{}{"type": "user"}},
+		},
+		{
+			name:     "view",
+			options:  ChangesView("_design/foo", "_view/bar"),
+			expected: Options{"filter": "_view", "view": "foo/bar"},
+		},
+		{
+			name:     "all docs style",
+			options:  ChangesStyle(StyleAllDocs),
+			expected: Options{"style": "all_docs"},
+		},
+		{
+			name:     "main only style",
+			options:  ChangesStyle(StyleMainOnly),
+			expected: Options{"style": "main_only"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if d := testy.DiffInterface(test.expected, test.options); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestChangeEventLeaves(t *testing.T) {
+	tests := []struct {
+		name       string
+		revs       []string
+		expected   LeafRevs
+		conflicted bool
+	}{
+		{
+			name:     "no revs",
+			expected: LeafRevs{},
+		},
+		{
+			name:     "winner only",
+			revs:     []string{"2-a"},
+			expected: LeafRevs{Winner: "2-a"},
+		},
+		{
+			name:       "conflicts",
+			revs:       []string{"2-a", "2-b", "3-c"},
+			expected:   LeafRevs{Winner: "2-a", Others: []string{"2-b", "3-c"}},
+			conflicted: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := &ChangeEvent{Revs: test.revs}
+			leaves := event.Leaves()
+			if d := testy.DiffInterface(test.expected, leaves); d != nil {
+				t.Error(d)
+			}
+			if leaves.Conflicted() != test.conflicted {
+				t.Errorf("Unexpected Conflicted: %t", leaves.Conflicted())
+			}
+		})
+	}
+}
@@ -29,7 +29,8 @@ func (c *Client) ClusterStatus(ctx context.Context, options ...Options) (string,
 	if !ok {
 		return "", clusterNotImplemented
 	}
-	return cluster.ClusterStatus(ctx, mergeOptions(options...))
+	status, err := cluster.ClusterStatus(ctx, driverOptions(c.mergeOptions(options...)))
+	return status, opError("ClusterStatus", err)
 }
 
 // ClusterSetup performs the requested cluster action. action should be
@@ -42,7 +43,7 @@ func (c *Client) ClusterSetup(ctx context.Context, action interface{}) error {
 	if !ok {
 		return clusterNotImplemented
 	}
-	return cluster.ClusterSetup(ctx, action)
+	return opError("ClusterSetup", cluster.ClusterSetup(ctx, action))
 }
 
 // ClusterMembership contains the list of known nodes, and cluster nodes, as returned
@@ -61,5 +62,5 @@ func (c *Client) Membership(ctx context.Context) (*ClusterMembership, error) {
 		return nil, clusterNotImplemented
 	}
 	nodes, err := cluster.Membership(ctx)
-	return (*ClusterMembership)(nodes), err
+	return (*ClusterMembership)(nodes), opError("Membership", err)
 }
@@ -0,0 +1,100 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"reflect"
+	"sync"
+)
+
+// DecodeFunc decodes a raw JSON document, key or value into dest.
+type DecodeFunc func(data []byte, dest interface{}) error
+
+var decoders = struct {
+	sync.RWMutex
+	types      map[reflect.Type]DecodeFunc
+	interfaces []interfaceDecoder
+}{
+	types: map[reflect.Type]DecodeFunc{},
+}
+
+type interfaceDecoder struct {
+	iface  reflect.Type
+	decode DecodeFunc
+}
+
+// RegisterDecoder registers fn to decode scan destinations of target's type,
+// in place of encoding/json. It is consulted by Row.ScanDoc, Changes.ScanDoc,
+// ChangeEvent.ScanDoc, and the ScanDoc, ScanValue and ScanKey methods of Rows.
+//
+// target is either a value of the destination type itself, such as
+// (*MyType)(nil), or a nil pointer to an interface type, such as
+// (*proto.Message)(nil), in which case fn decodes every destination which
+// implements the interface. This allows, for example, protojson to be used
+// for all proto messages:
+//
+//	kivik.RegisterDecoder((*proto.Message)(nil), func(data []byte, dest interface{}) error {
+//		return protojson.Unmarshal(data, dest.(proto.Message))
+//	})
+//
+// A decoder registered for the exact destination type takes precedence over
+// one registered for an interface; otherwise, interfaces are tried in the
+// order registered. OptionUseNumber does not apply to registered decoders.
+//
+// RegisterDecoder is meant to be called from an init function, and panics if
+// target is nil, or its type already has a decoder.
+func RegisterDecoder(target interface{}, fn DecodeFunc) {
+	typ := reflect.TypeOf(target)
+	if typ == nil || fn == nil {
+		panic("kivik: RegisterDecoder target and decoder must not be nil")
+	}
+	decoders.Lock()
+	defer decoders.Unlock()
+	if typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Interface {
+		iface := typ.Elem()
+		for _, d := range decoders.interfaces {
+			if d.iface == iface {
+				panic("kivik: RegisterDecoder called twice for " + iface.String())
+			}
+		}
+		decoders.interfaces = append(decoders.interfaces, interfaceDecoder{iface: iface, decode: fn})
+		return
+	}
+	if _, dup := decoders.types[typ]; dup {
+		panic("kivik: RegisterDecoder called twice for " + typ.String())
+	}
+	decoders.types[typ] = fn
+}
+
+// decoderFor returns the decoder registered for dest, or nil if dest should
+// be decoded with encoding/json.
+func decoderFor(dest interface{}) DecodeFunc {
+	decoders.RLock()
+	defer decoders.RUnlock()
+	if len(decoders.types) == 0 && len(decoders.interfaces) == 0 {
+		return nil
+	}
+	typ := reflect.TypeOf(dest)
+	if typ == nil {
+		return nil
+	}
+	if fn, ok := decoders.types[typ]; ok {
+		return fn
+	}
+	for _, d := range decoders.interfaces {
+		if typ.Implements(d.iface) {
+			return d.decode
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// rawDoc captures the raw document, rather than decoding it.
+type rawDoc struct {
+	data string
+}
+
+type message interface {
+	setMessage(string)
+}
+
+type textMessage struct {
+	text string
+}
+
+func (m *textMessage) setMessage(s string) { m.text = "message:" + s }
+
+type exactMessage struct {
+	text string
+}
+
+func (m *exactMessage) setMessage(s string) { m.text = "message:" + s }
+
+// withDecoders swaps out the decoder registry for the duration of a test.
+func withDecoders(t *testing.T) func() {
+	t.Helper()
+	decoders.Lock()
+	types, interfaces := decoders.types, decoders.interfaces
+	decoders.types, decoders.interfaces = map[reflect.Type]DecodeFunc{}, nil
+	decoders.Unlock()
+	return func() {
+		decoders.Lock()
+		decoders.types, decoders.interfaces = types, interfaces
+		decoders.Unlock()
+	}
+}
+
+func registerTestDecoders() {
+	RegisterDecoder((*rawDoc)(nil), func(data []byte, dest interface{}) error {
+		dest.(*rawDoc).data = string(data)
+		return nil
+	})
+	RegisterDecoder((*message)(nil), func(data []byte, dest interface{}) error {
+		dest.(message).setMessage(string(data))
+		return nil
+	})
+	RegisterDecoder((*exactMessage)(nil), func(data []byte, dest interface{}) error {
+		dest.(*exactMessage).text = "exact:" + string(data)
+		return nil
+	})
+}
+
+func TestRegisterDecoderPanics(t *testing.T) {
+	defer withDecoders(t)()
+	registerTestDecoders()
+	type tt struct {
+		target interface{}
+		fn     DecodeFunc
+		err    string
+	}
+	fn := func([]byte, interface{}) error { return nil }
+	tests := testy.NewTable()
+	tests.Add("nil target", tt{
+		fn:  fn,
+		err: "kivik: RegisterDecoder target and decoder must not be nil",
+	})
+	tests.Add("nil decoder", tt{
+		target: (*rawDoc)(nil),
+		err:    "kivik: RegisterDecoder target and decoder must not be nil",
+	})
+	tests.Add("duplicate type", tt{
+		target: (*rawDoc)(nil),
+		fn:     fn,
+		err:    "kivik: RegisterDecoder called twice for *kivik.rawDoc",
+	})
+	tests.Add("duplicate interface", tt{
+		target: (*message)(nil),
+		fn:     fn,
+		err:    "kivik: RegisterDecoder called twice for kivik.message",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		defer func() {
+			p := recover()
+			if d := testy.DiffInterface(tt.err, p); d != nil {
+				t.Error(d)
+			}
+		}()
+		RegisterDecoder(tt.target, tt.fn)
+	})
+}
+
+func TestRegisteredDecoders(t *testing.T) {
+	defer withDecoders(t)()
+	registerTestDecoders()
+	const doc = `{"foo":"bar"}`
+
+	t.Run("Row.ScanDoc", func(t *testing.T) {
+		row := &Row{Body: ioutil.NopCloser(strings.NewReader(doc))}
+		var dest rawDoc
+		if err := row.ScanDoc(&dest); err != nil {
+			t.Fatal(err)
+		}
+		if dest.data != doc {
+			t.Errorf("Unexpected result: %s", dest.data)
+		}
+	})
+	t.Run("Rows", func(t *testing.T) {
+		var done bool
+		rows := newRows(context.Background(), &mock.Rows{
+			NextFunc: func(row *driver.Row) error {
+				if done {
+					return io.EOF
+				}
+				done = true
+				row.Key = json.RawMessage(`"key"`)
+				row.ValueReader = strings.NewReader(`"value"`)
+				row.Doc = json.RawMessage(doc)
+				return nil
+			},
+		})
+		if !rows.Next() {
+			t.Fatal(rows.Err())
+		}
+		var key exactMessage
+		var value textMessage
+		var dest map[string]interface{}
+		if err := rows.ScanKey(&key); err != nil {
+			t.Fatal(err)
+		}
+		if err := rows.ScanValue(&value); err != nil {
+			t.Fatal(err)
+		}
+		if err := rows.ScanDoc(&dest); err != nil {
+			t.Fatal(err)
+		}
+		expected := []interface{}{`exact:"key"`, `message:"value"`, map[string]interface{}{"foo": "bar"}}
+		if d := testy.DiffInterface(expected, []interface{}{key.text, value.text, dest}); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("ChangeEvent.ScanDoc", func(t *testing.T) {
+		event := &ChangeEvent{Doc: json.RawMessage(doc)}
+		var dest textMessage
+		if err := event.ScanDoc(&dest); err != nil {
+			t.Fatal(err)
+		}
+		if dest.text != "message:"+doc {
+			t.Errorf("Unexpected result: %s", dest.text)
+		}
+	})
+}
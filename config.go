@@ -37,7 +37,7 @@ func (c *Client) Config(ctx context.Context, node string) (Config, error) {
 	if configer, ok := c.driverClient.(driver.Configer); ok {
 		driverCf, err := configer.Config(ctx, node)
 		if err != nil {
-			return nil, err
+			return nil, opError("Config", err)
 		}
 		cf := Config{}
 		for k, v := range driverCf {
@@ -55,7 +55,7 @@ func (c *Client) Config(ctx context.Context, node string) (Config, error) {
 func (c *Client) ConfigSection(ctx context.Context, node, section string) (ConfigSection, error) {
 	if configer, ok := c.driverClient.(driver.Configer); ok {
 		sec, err := configer.ConfigSection(ctx, node, section)
-		return ConfigSection(sec), err
+		return ConfigSection(sec), opError("ConfigSection", err)
 	}
 	return nil, configNotImplemented
 }
@@ -65,7 +65,8 @@ func (c *Client) ConfigSection(ctx context.Context, node, section string) (Confi
 // See http://docs.couchdb.org/en/stable/api/server/configuration.html#get--_node-node-name-_config-section-key
 func (c *Client) ConfigValue(ctx context.Context, node, section, key string) (string, error) {
 	if configer, ok := c.driverClient.(driver.Configer); ok {
-		return configer.ConfigValue(ctx, node, section, key)
+		value, err := configer.ConfigValue(ctx, node, section, key)
+		return value, opError("ConfigValue", err)
 	}
 	return "", configNotImplemented
 }
@@ -76,7 +77,8 @@ func (c *Client) ConfigValue(ctx context.Context, node, section, key string) (st
 // See http://docs.couchdb.org/en/stable/api/server/configuration.html#put--_node-node-name-_config-section-key
 func (c *Client) SetConfigValue(ctx context.Context, node, section, key, value string) (string, error) {
 	if configer, ok := c.driverClient.(driver.Configer); ok {
-		return configer.SetConfigValue(ctx, node, section, key, value)
+		old, err := configer.SetConfigValue(ctx, node, section, key, value)
+		return old, opError("SetConfigValue", err)
 	}
 	return "", configNotImplemented
 }
@@ -87,7 +89,8 @@ func (c *Client) SetConfigValue(ctx context.Context, node, section, key, value s
 // See http://docs.couchdb.org/en/stable/api/server/configuration.html#delete--_node-node-name-_config-section-key
 func (c *Client) DeleteConfigKey(ctx context.Context, node, section, key string) (string, error) {
 	if configer, ok := c.driverClient.(driver.Configer); ok {
-		return configer.DeleteConfigKey(ctx, node, section, key)
+		old, err := configer.DeleteConfigKey(ctx, node, section, key)
+		return old, opError("DeleteConfigKey", err)
 	}
 	return "", configNotImplemented
 }
@@ -0,0 +1,128 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
+)
+
+// Options to request details of the current document when Put or Delete fail
+// with a document update conflict. They are interpreted by Kivik, and not
+// passed to the driver.
+const (
+	// OptionFetchConflictRev, when true, causes the current winning revision
+	// to be fetched after a conflict, and returned in a *ConflictError.
+	OptionFetchConflictRev = "kivik.fetch_conflict_rev"
+
+	// OptionFetchConflictDoc, when true, causes the current document body to
+	// be fetched after a conflict, along with its revision, and returned in a
+	// *ConflictError.
+	OptionFetchConflictDoc = "kivik.fetch_conflict_doc"
+)
+
+// ConflictError is returned by Put and Delete for a document update conflict,
+// when OptionFetchConflictRev or OptionFetchConflictDoc is set, so that the
+// caller may retry without a separate round trip to fetch the current
+// revision.
+type ConflictError struct {
+	// DocID is the ID of the conflicting document.
+	DocID string
+
+	// CurrentRev is the current winning revision of the document.
+	CurrentRev string
+
+	// CurrentDoc is the raw JSON of the current document. It is only set when
+	// OptionFetchConflictDoc was requested.
+	CurrentDoc json.RawMessage
+
+	// Err is the conflict error returned by the driver.
+	Err error
+}
+
+var (
+	_ error       = &ConflictError{}
+	_ statusCoder = &ConflictError{}
+)
+
+func (e *ConflictError) Error() string {
+	return e.Err.Error()
+}
+
+// StatusCode returns 409 (conflict).
+func (e *ConflictError) StatusCode() int {
+	return http.StatusConflict
+}
+
+// Cause returns e.Err.
+func (e *ConflictError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns e.Err.
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// conflictFetch reads the conflict options from opts, returning whether the
+// current revision and the current document should be fetched.
+func conflictFetch(opts Options) (rev, doc bool, err error) {
+	if rev, err = queryopts.Bool(opts, OptionFetchConflictRev); err != nil {
+		return false, false, err
+	}
+	if doc, err = queryopts.Bool(opts, OptionFetchConflictDoc); err != nil {
+		return false, false, err
+	}
+	return rev || doc, doc, nil
+}
+
+// conflictError returns a *ConflictError for err, if it is a conflict, with
+// the current state of docID attached. If err is not a conflict, or the
+// current state cannot be fetched, as when the document has been deleted,
+// err is returned unaltered.
+func (db *DB) conflictError(ctx context.Context, docID string, fetchDoc bool, err error) error {
+	if !IsConflict(err) {
+		return err
+	}
+	cerr := &ConflictError{DocID: docID, Err: err}
+	if !fetchDoc {
+		_, rev, e := db.GetMeta(ctx, docID)
+		if e != nil {
+			return err
+		}
+		cerr.CurrentRev = rev
+		return cerr
+	}
+	row := db.Get(ctx, docID)
+	if row.Err != nil {
+		return err
+	}
+	defer row.Body.Close() // nolint: errcheck
+	body, e := ioutil.ReadAll(row.Body)
+	if e != nil {
+		return err
+	}
+	cerr.CurrentRev, cerr.CurrentDoc = row.Rev, body
+	if cerr.CurrentRev == "" {
+		var meta struct {
+			Rev string `json:"_rev"`
+		}
+		_ = json.Unmarshal(body, &meta)
+		cerr.CurrentRev = meta.Rev
+	}
+	return cerr
+}
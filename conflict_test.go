@@ -0,0 +1,137 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	kerrs "github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestConflictError(t *testing.T) {
+	conflict := kerrs.Status(http.StatusConflict, "Document update conflict.")
+	get := func(_ context.Context, docID string, _ map[string]interface{}) (*driver.Document, error) {
+		if docID != "foo" {
+			return nil, kerrs.Status(http.StatusNotFound, "missing")
+		}
+		return &driver.Document{
+			Rev:  "2-xxx",
+			Body: body(`{"_id":"foo","_rev":"2-xxx"}`),
+		}, nil
+	}
+	type tt struct {
+		docID    string
+		options  Options
+		putErr   error
+		expected error
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("no option", tt{
+		docID:    "foo",
+		putErr:   conflict,
+		expected: opError("Put", conflict),
+	})
+	tests.Add("fetch rev", tt{
+		docID:   "foo",
+		options: Options{OptionFetchConflictRev: true},
+		putErr:  conflict,
+		expected: &ConflictError{
+			DocID:      "foo",
+			CurrentRev: "2-xxx",
+			Err:        opError("Put", conflict),
+		},
+	})
+	tests.Add("fetch doc", tt{
+		docID:   "foo",
+		options: Options{OptionFetchConflictDoc: "true"},
+		putErr:  conflict,
+		expected: &ConflictError{
+			DocID:      "foo",
+			CurrentRev: "2-xxx",
+			CurrentDoc: json.RawMessage(`{"_id":"foo","_rev":"2-xxx"}`),
+			Err:        opError("Put", conflict),
+		},
+	})
+	tests.Add("not a conflict", tt{
+		docID:    "foo",
+		options:  Options{OptionFetchConflictRev: true},
+		putErr:   errors.New("oops"),
+		expected: opError("Put", errors.New("oops")),
+	})
+	tests.Add("fetch fails", tt{
+		docID:    "deleted",
+		options:  Options{OptionFetchConflictRev: true},
+		putErr:   conflict,
+		expected: opError("Put", conflict),
+	})
+	tests.Add("invalid option", tt{
+		docID:   "foo",
+		options: Options{OptionFetchConflictRev: 1},
+		status:  http.StatusBadRequest,
+		err:     "invalid value for kivik.fetch_conflict_rev: 1",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		db := &DB{driverDB: &mock.DB{
+			PutFunc: func(_ context.Context, _ string, _ interface{}, opts map[string]interface{}) (string, error) {
+				if len(opts) != 0 {
+					return "", fmt.Errorf("Unexpected options: %v", opts)
+				}
+				return "", tt.putErr
+			},
+			GetFunc: get,
+		}}
+		_, err := db.Put(context.Background(), tt.docID, map[string]string{}, tt.options)
+		if tt.err != "" {
+			testy.StatusError(t, tt.err, tt.status, err)
+			return
+		}
+		if d := testy.DiffInterface(tt.expected, err); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestDeleteConflictError(t *testing.T) {
+	conflict := kerrs.Status(http.StatusConflict, "Document update conflict.")
+	db := &DB{driverDB: &mock.DB{
+		DeleteFunc: func(context.Context, string, string, map[string]interface{}) (string, error) {
+			return "", conflict
+		},
+		GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+			return &driver.Document{Body: body(`{"_id":"foo","_rev":"3-xxx"}`)}, nil
+		},
+	}}
+	_, err := db.Delete(context.Background(), "foo", "1-xxx", Options{OptionFetchConflictRev: true})
+	var cerr *ConflictError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("Expected a *ConflictError, got %T", err)
+	}
+	if cerr.CurrentRev != "3-xxx" {
+		t.Errorf("Unexpected rev: %s", cerr.CurrentRev)
+	}
+	if !IsConflict(err) {
+		t.Error("Expected IsConflict to be true")
+	}
+}
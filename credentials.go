@@ -0,0 +1,120 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	optionSourceCredentials = "kivik.source_credentials"
+	optionTargetCredentials = "kivik.target_credentials"
+)
+
+// Credentials are a username and password for a replication endpoint.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialProvider supplies credentials for a replication endpoint, for
+// example from a secrets manager, so that they need not be embedded in DSNs
+// or configuration.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (*Credentials, error)
+}
+
+// CredentialProviderFunc is an adapter to allow the use of an ordinary
+// function as a CredentialProvider.
+type CredentialProviderFunc func(ctx context.Context) (*Credentials, error)
+
+// Credentials calls f(ctx).
+func (f CredentialProviderFunc) Credentials(ctx context.Context) (*Credentials, error) {
+	return f(ctx)
+}
+
+// SourceCredentials returns an option for Replicate, which authenticates to
+// the source with credentials from p.
+//
+// The provider is consulted each time Replicate is called, so credentials
+// which have been rotated are picked up when a replication is restarted by
+// creating it again. The source is passed to the driver as an endpoint
+// object, with the credentials under auth.basic, rather than in the URL, so
+// that they are not exposed in the replication's source URL. Any userinfo in
+// sourceDSN is replaced.
+func SourceCredentials(p CredentialProvider) Options {
+	return Options{optionSourceCredentials: p}
+}
+
+// TargetCredentials returns an option for Replicate, which authenticates to
+// the target with credentials from p. See SourceCredentials.
+func TargetCredentials(p CredentialProvider) Options {
+	return Options{optionTargetCredentials: p}
+}
+
+// resolveCredentials replaces any credential provider options in opts with
+// source or target endpoint objects.
+func resolveCredentials(ctx context.Context, opts Options, targetDSN, sourceDSN string) error {
+	for _, e := range []struct {
+		option, endpoint, dsn string
+	}{
+		{optionSourceCredentials, "source", sourceDSN},
+		{optionTargetCredentials, "target", targetDSN},
+	} {
+		v, ok := opts[e.option]
+		if !ok {
+			continue
+		}
+		delete(opts, e.option)
+		p, ok := v.(CredentialProvider)
+		if !ok {
+			return &Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid value for %s: %v", e.option, v)}
+		}
+		if _, ok := opts[e.endpoint]; ok {
+			return &Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("kivik: %s credentials cannot be combined with a %[1]s option", e.endpoint)}
+		}
+		endpoint, err := credentialEndpoint(ctx, p, e.dsn)
+		if err != nil {
+			return &Error{HTTPStatus: StatusCode(err), Message: "kivik: " + e.endpoint + " credentials", Err: err}
+		}
+		opts[e.endpoint] = endpoint
+	}
+	return nil
+}
+
+func credentialEndpoint(ctx context.Context, p CredentialProvider, dsn string) (map[string]interface{}, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("%q is not a URL", dsn)}
+	}
+	creds, err := p.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if creds == nil {
+		return nil, &Error{HTTPStatus: http.StatusInternalServerError, Message: "provider returned no credentials"}
+	}
+	u.User = nil
+	return map[string]interface{}{
+		"url": u.String(),
+		"auth": map[string]interface{}{
+			"basic": map[string]interface{}{
+				"username": creds.Username,
+				"password": creds.Password,
+			},
+		},
+	}, nil
+}
@@ -13,12 +13,14 @@
 package kivik
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/go-kivik/kivik/v4/driver"
@@ -30,6 +32,40 @@ type DB struct {
 	name     string
 	driverDB driver.DB
 	err      error
+	options  Options
+}
+
+// WithOptions returns a new handle to the same database, whose methods merge
+// options under those passed to each call. This allows options such as
+// update=lazy or include_docs=true to be set once, rather than on every call.
+// The defaults apply to every method which accepts options, so should be
+// chosen with care. Calling WithOptions on the returned DB merges further
+// defaults over these. Options interpreted by Kivik, such as OptionUseNumber,
+// are used by the methods to which they apply, and never passed to the
+// driver.
+func (db *DB) WithOptions(options ...Options) *DB {
+	return &DB{
+		client:   db.client,
+		name:     db.name,
+		driverDB: db.driverDB,
+		err:      db.err,
+		options:  db.mergeOptions(options...),
+	}
+}
+
+// Options returns a copy of the default options set with WithOptions, or nil
+// if there are none.
+func (db *DB) Options() Options {
+	return mergeOptions(db.options)
+}
+
+// mergeOptions merges otherOpts under any default options set with
+// WithOptions.
+func (db *DB) mergeOptions(otherOpts ...Options) Options {
+	if db.options == nil {
+		return mergeOptions(otherOpts...)
+	}
+	return mergeOptions(append([]Options{db.options}, otherOpts...)...)
 }
 
 // Client returns the Client used to connect to the database.
@@ -55,11 +91,21 @@ func (db *DB) AllDocs(ctx context.Context, options ...Options) (*Rows, error) {
 	if db.err != nil {
 		return nil, db.err
 	}
-	rowsi, err := db.driverDB.AllDocs(ctx, mergeOptions(options...))
+	opts := db.mergeOptions(options...)
+	useNumber, err := useNumberOption(opts)
 	if err != nil {
 		return nil, err
 	}
-	return newRows(ctx, rowsi), nil
+	ctx, op := db.startOp(ctx, "AllDocs", "_all_docs")
+	rowsi, err := db.driverDB.AllDocs(ctx, driverOptions(opts))
+	if err != nil {
+		op.done()
+		return nil, opError("AllDocs", err)
+	}
+	rows := newRowsOpts(ctx, rowsi, useNumber)
+	db.measure(rows.iter, "AllDocs", "_all_docs")
+	op.doneOnClose(rows.iter)
+	return rows, nil
 }
 
 // DesignDocs returns a list of all documents in the database.
@@ -71,11 +117,21 @@ func (db *DB) DesignDocs(ctx context.Context, options ...Options) (*Rows, error)
 	if !ok {
 		return nil, &Error{HTTPStatus: http.StatusNotImplemented, Err: errors.New("kivik: design doc view not supported by driver")}
 	}
-	rowsi, err := ddocer.DesignDocs(ctx, mergeOptions(options...))
+	opts := db.mergeOptions(options...)
+	useNumber, err := useNumberOption(opts)
 	if err != nil {
 		return nil, err
 	}
-	return newRows(ctx, rowsi), nil
+	ctx, op := db.startOp(ctx, "DesignDocs", "_design_docs")
+	rowsi, err := ddocer.DesignDocs(ctx, driverOptions(opts))
+	if err != nil {
+		op.done()
+		return nil, opError("DesignDocs", err)
+	}
+	rows := newRowsOpts(ctx, rowsi, useNumber)
+	db.measure(rows.iter, "DesignDocs", "_design_docs")
+	op.doneOnClose(rows.iter)
+	return rows, nil
 }
 
 // LocalDocs returns a list of all documents in the database.
@@ -87,27 +143,54 @@ func (db *DB) LocalDocs(ctx context.Context, options ...Options) (*Rows, error)
 	if !ok {
 		return nil, &Error{HTTPStatus: http.StatusNotImplemented, Err: errors.New("kivik: local doc view not supported by driver")}
 	}
-	rowsi, err := ldocer.LocalDocs(ctx, mergeOptions(options...))
+	opts := db.mergeOptions(options...)
+	useNumber, err := useNumberOption(opts)
 	if err != nil {
 		return nil, err
 	}
-	return newRows(ctx, rowsi), nil
+	ctx, op := db.startOp(ctx, "LocalDocs", "_local_docs")
+	rowsi, err := ldocer.LocalDocs(ctx, driverOptions(opts))
+	if err != nil {
+		op.done()
+		return nil, opError("LocalDocs", err)
+	}
+	rows := newRowsOpts(ctx, rowsi, useNumber)
+	db.measure(rows.iter, "LocalDocs", "_local_docs")
+	op.doneOnClose(rows.iter)
+	return rows, nil
 }
 
 // Query executes the specified view function from the specified design
 // document. ddoc and view may or may not be be prefixed with '_design/'
 // and '_view/' respectively. No other
+//
+// Combining group or group_level with reduce=false results in a status 400
+// error, without contacting the server. See ValidateQuery for more thorough
+// checks.
 func (db *DB) Query(ctx context.Context, ddoc, view string, options ...Options) (*Rows, error) {
 	if db.err != nil {
 		return nil, db.err
 	}
 	ddoc = strings.TrimPrefix(ddoc, "_design/")
 	view = strings.TrimPrefix(view, "_view/")
-	rowsi, err := db.driverDB.Query(ctx, ddoc, view, mergeOptions(options...))
+	opts := db.mergeOptions(options...)
+	if err := validateQueryOptions(opts); err != nil {
+		return nil, err
+	}
+	useNumber, err := useNumberOption(opts)
 	if err != nil {
 		return nil, err
 	}
-	return newRows(ctx, rowsi), nil
+	ctx, op := db.startOp(ctx, "Query", "_design/"+ddoc+"/_view/"+view)
+	rowsi, err := db.driverDB.Query(ctx, ddoc, view, driverOptions(opts))
+	if err != nil {
+		op.done()
+		return nil, opError("Query", err)
+	}
+	rows := newRowsOpts(ctx, rowsi, useNumber)
+	db.measure(rows.iter, "Query", "_design/"+ddoc+"/_view/"+view)
+	op.doneOnClose(rows.iter)
+	return rows, nil
 }
 
 // Row contains the result of calling Get for a single document. For most uses,
@@ -135,6 +218,9 @@ type Row struct {
 
 	// Attachments is experimental
 	Attachments *AttachmentsIterator
+
+	// useNumber is set by OptionUseNumber.
+	useNumber bool
 }
 
 // ScanDoc unmarshals the data from the fetched row into dest. It is an
@@ -145,7 +231,7 @@ func (r *Row) ScanDoc(dest interface{}) error {
 		return r.Err
 	}
 	defer r.Body.Close() // nolint: errcheck
-	return json.NewDecoder(r.Body).Decode(dest)
+	return decodeJSON(r.Body, dest, r.useNumber)
 }
 
 // Get fetches the requested document. Any errors are deferred until the
@@ -154,14 +240,25 @@ func (db *DB) Get(ctx context.Context, docID string, options ...Options) *Row {
 	if db.err != nil {
 		return &Row{Err: db.err}
 	}
-	doc, err := db.driverDB.Get(ctx, docID, mergeOptions(options...))
+	opts := db.mergeOptions(options...)
+	useNumber, err := useNumberOption(opts)
 	if err != nil {
 		return &Row{Err: err}
 	}
+	ctx, op := db.startOp(ctx, "Get", docID)
+	doc, err := db.driverDB.Get(ctx, docID, driverOptions(opts))
+	if err != nil {
+		op.done()
+		return &Row{Err: opError("Get", err)}
+	}
+	if doc.Body == nil {
+		op.done()
+	}
 	row := &Row{
 		ContentLength: doc.ContentLength,
 		Rev:           doc.Rev,
-		Body:          doc.Body,
+		Body:          op.body(doc.Body),
+		useNumber:     useNumber,
 	}
 	if doc.Attachments != nil {
 		row.Attachments = &AttachmentsIterator{atti: doc.Attachments}
@@ -175,9 +272,10 @@ func (db *DB) GetMeta(ctx context.Context, docID string, options ...Options) (si
 	if db.err != nil {
 		return 0, "", db.err
 	}
-	opts := mergeOptions(options...)
+	opts := db.mergeOptions(options...)
 	if r, ok := db.driverDB.(driver.MetaGetter); ok {
-		return r.GetMeta(ctx, docID, opts)
+		size, rev, err := r.GetMeta(ctx, docID, driverOptions(opts))
+		return size, rev, opError("GetMeta", err)
 	}
 	row := db.Get(ctx, docID, opts)
 	if row.Err != nil {
@@ -202,7 +300,21 @@ func (db *DB) CreateDoc(ctx context.Context, doc interface{}, options ...Options
 	if db.err != nil {
 		return "", "", db.err
 	}
-	return db.driverDB.CreateDoc(ctx, doc, mergeOptions(options...))
+	if err := db.checkDocSize(doc); err != nil {
+		return "", "", err
+	}
+	opts := db.mergeOptions(options...)
+	requireQuorum, err := requireQuorumOption(opts)
+	if err != nil {
+		return "", "", err
+	}
+	ctx, op := db.startOp(ctx, "CreateDoc", "")
+	defer op.done()
+	res, err := db.createDocResult(ctx, doc, opts)
+	if err != nil {
+		return "", "", opError("CreateDoc", err)
+	}
+	return res.ID, res.Rev, acceptedError("CreateDoc", res, requireQuorum)
 }
 
 // normalizeFromJSON unmarshals a []byte, json.RawMessage or io.Reader to a
@@ -225,10 +337,17 @@ func normalizeFromJSON(i interface{}) (interface{}, error) {
 			return nil, &Error{HTTPStatus: http.StatusBadRequest, Err: err}
 		}
 	}
+	// Numbers are kept as json.Number, so that they reach the driver
+	// unaltered.
 	var x map[string]interface{}
-	if err := json.Unmarshal(body, &x); err != nil {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&x); err != nil {
 		return nil, &Error{HTTPStatus: http.StatusBadRequest, Err: err}
 	}
+	if dec.More() {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: invalid data after top-level value"}
+	}
 	return x, nil
 }
 
@@ -275,6 +394,9 @@ func extractDocID(i interface{}) (string, bool) {
 //  - A []byte value, containing a valid JSON document
 //  - A json.RawMessage value containing a valid JSON document
 //  - An io.Reader, from which a valid JSON document may be read.
+//
+// If OptionFetchConflictRev or OptionFetchConflictDoc is set, a conflict is
+// returned as a *ConflictError carrying the document's current state.
 func (db *DB) Put(ctx context.Context, docID string, doc interface{}, options ...Options) (rev string, err error) {
 	if db.err != nil {
 		return "", db.err
@@ -286,10 +408,32 @@ func (db *DB) Put(ctx context.Context, docID string, doc interface{}, options ..
 	if err != nil {
 		return "", err
 	}
-	return db.driverDB.Put(ctx, docID, i, mergeOptions(options...))
+	if err := db.checkDocSize(i); err != nil {
+		return "", err
+	}
+	opts := db.mergeOptions(options...)
+	fetchRev, fetchDoc, err := conflictFetch(opts)
+	if err != nil {
+		return "", err
+	}
+	requireQuorum, err := requireQuorumOption(opts)
+	if err != nil {
+		return "", err
+	}
+	ctx, op := db.startOp(ctx, "Put", docID)
+	defer op.done()
+	res, err := db.putResult(ctx, docID, i, opts)
+	if err != nil && fetchRev {
+		return "", db.conflictError(ctx, docID, fetchDoc, opError("Put", err))
+	}
+	if err != nil {
+		return "", opError("Put", err)
+	}
+	return res.Rev, acceptedError("Put", res, requireQuorum)
 }
 
-// Delete marks the specified document as deleted.
+// Delete marks the specified document as deleted. Conflicts are reported as
+// for Put.
 func (db *DB) Delete(ctx context.Context, docID, rev string, options ...Options) (newRev string, err error) {
 	if db.err != nil {
 		return "", db.err
@@ -297,7 +441,25 @@ func (db *DB) Delete(ctx context.Context, docID, rev string, options ...Options)
 	if docID == "" {
 		return "", missingArg("docID")
 	}
-	return db.driverDB.Delete(ctx, docID, rev, mergeOptions(options...))
+	opts := db.mergeOptions(options...)
+	fetchRev, fetchDoc, err := conflictFetch(opts)
+	if err != nil {
+		return "", err
+	}
+	requireQuorum, err := requireQuorumOption(opts)
+	if err != nil {
+		return "", err
+	}
+	ctx, op := db.startOp(ctx, "Delete", docID)
+	defer op.done()
+	res, err := db.deleteResult(ctx, docID, rev, opts)
+	if err != nil && fetchRev {
+		return "", db.conflictError(ctx, docID, fetchDoc, opError("Delete", err))
+	}
+	if err != nil {
+		return "", opError("Delete", err)
+	}
+	return res.Rev, acceptedError("Delete", res, requireQuorum)
 }
 
 // Flush requests a flush of disk cache to disk or other permanent storage.
@@ -308,7 +470,7 @@ func (db *DB) Flush(ctx context.Context) error {
 		return db.err
 	}
 	if flusher, ok := db.driverDB.(driver.Flusher); ok {
-		return flusher.Flush(ctx)
+		return opError("Flush", flusher.Flush(ctx))
 	}
 	return &Error{HTTPStatus: http.StatusNotImplemented, Err: errors.New("kivik: flush not supported by driver")}
 }
@@ -362,7 +524,7 @@ func (db *DB) Stats(ctx context.Context) (*DBStats, error) {
 	}
 	i, err := db.driverDB.Stats(ctx)
 	if err != nil {
-		return nil, err
+		return nil, opError("Stats", err)
 	}
 	return driverStats2kivikStats(i), nil
 }
@@ -399,7 +561,7 @@ func (db *DB) Compact(ctx context.Context) error {
 	if db.err != nil {
 		return db.err
 	}
-	return db.driverDB.Compact(ctx)
+	return opError("Compact", db.driverDB.Compact(ctx))
 }
 
 // CompactView compats the view indexes associated with the specified design
@@ -411,7 +573,7 @@ func (db *DB) Compact(ctx context.Context) error {
 // particular, CouchDB triggers the compaction and returns immediately, whereas
 // PouchDB waits until compaction has completed, before returning.
 func (db *DB) CompactView(ctx context.Context, ddocID string) error {
-	return db.driverDB.CompactView(ctx, ddocID)
+	return opError("CompactView", db.driverDB.CompactView(ctx, ddocID))
 }
 
 // ViewCleanup removes view index files that are no longer required as a result
@@ -421,7 +583,7 @@ func (db *DB) ViewCleanup(ctx context.Context) error {
 	if db.err != nil {
 		return db.err
 	}
-	return db.driverDB.ViewCleanup(ctx)
+	return opError("ViewCleanup", db.driverDB.ViewCleanup(ctx))
 }
 
 // Security returns the database's security document.
@@ -432,11 +594,12 @@ func (db *DB) Security(ctx context.Context) (*Security, error) {
 	}
 	s, err := db.driverDB.Security(ctx)
 	if err != nil {
-		return nil, err
+		return nil, opError("Security", err)
 	}
 	return &Security{
 		Admins:  Members(s.Admins),
 		Members: Members(s.Members),
+		Extra:   s.Extra,
 	}, err
 }
 
@@ -449,11 +612,7 @@ func (db *DB) SetSecurity(ctx context.Context, security *Security) error {
 	if security == nil {
 		return missingArg("security")
 	}
-	sec := &driver.Security{
-		Admins:  driver.Members(security.Admins),
-		Members: driver.Members(security.Members),
-	}
-	return db.driverDB.SetSecurity(ctx, sec)
+	return opError("SetSecurity", db.driverDB.SetSecurity(ctx, security.driver()))
 }
 
 // Copy copies the source document to a new document with an ID of targetID. If
@@ -472,9 +631,10 @@ func (db *DB) Copy(ctx context.Context, targetID, sourceID string, options ...Op
 	if sourceID == "" {
 		return "", missingArg("sourceID")
 	}
-	opts := mergeOptions(options...)
+	opts := db.mergeOptions(options...)
 	if copier, ok := db.driverDB.(driver.Copier); ok {
-		return copier.Copy(ctx, targetID, sourceID, opts)
+		targetRev, err := copier.Copy(ctx, targetID, sourceID, driverOptions(opts))
+		return targetRev, opError("Copy", err)
 	}
 	var doc map[string]interface{}
 	if err = db.Get(ctx, sourceID, opts).ScanDoc(&doc); err != nil {
@@ -498,8 +658,22 @@ func (db *DB) PutAttachment(ctx context.Context, docID, rev string, att *Attachm
 	if e := att.validate(); e != nil {
 		return "", e
 	}
+	if err := db.checkAttachmentSize(att); err != nil {
+		return "", err
+	}
+	opts := db.mergeOptions(options...)
+	requireQuorum, err := requireQuorumOption(opts)
+	if err != nil {
+		return "", err
+	}
 	a := driver.Attachment(*att)
-	return db.driverDB.PutAttachment(ctx, docID, rev, &a, mergeOptions(options...))
+	ctx, op := db.startOp(ctx, "PutAttachment", docID+"/"+att.Filename)
+	defer op.done()
+	res, err := db.putAttachmentResult(ctx, docID, rev, &a, opts)
+	if err != nil {
+		return "", opError("PutAttachment", err)
+	}
+	return res.Rev, acceptedError("PutAttachment", res, requireQuorum)
 }
 
 // GetAttachment returns a file attachment associated with the document.
@@ -513,11 +687,17 @@ func (db *DB) GetAttachment(ctx context.Context, docID, filename string, options
 	if filename == "" {
 		return nil, missingArg("filename")
 	}
-	att, err := db.driverDB.GetAttachment(ctx, docID, filename, mergeOptions(options...))
+	ctx, op := db.startOp(ctx, "GetAttachment", docID+"/"+filename)
+	att, err := db.driverDB.GetAttachment(ctx, docID, filename, driverOptions(db.mergeOptions(options...)))
 	if err != nil {
-		return nil, err
+		op.done()
+		return nil, opError("GetAttachment", err)
 	}
 	a := Attachment(*att)
+	if a.Content == nil {
+		op.done()
+	}
+	a.Content = op.body(a.Content)
 	return &a, nil
 }
 
@@ -544,9 +724,11 @@ func (db *DB) GetAttachmentMeta(ctx context.Context, docID, filename string, opt
 	}
 	var att *Attachment
 	if metaer, ok := db.driverDB.(driver.AttachmentMetaGetter); ok {
-		a, err := metaer.GetAttachmentMeta(ctx, docID, filename, mergeOptions(options...))
+		ctx, op := db.startOp(ctx, "GetAttachmentMeta", docID+"/"+filename)
+		defer op.done()
+		a, err := metaer.GetAttachmentMeta(ctx, docID, filename, driverOptions(db.mergeOptions(options...)))
 		if err != nil {
-			return nil, err
+			return nil, opError("GetAttachmentMeta", err)
 		}
 		att = new(Attachment)
 		*att = Attachment(*a)
@@ -576,7 +758,18 @@ func (db *DB) DeleteAttachment(ctx context.Context, docID, rev, filename string,
 	if filename == "" {
 		return "", missingArg("filename")
 	}
-	return db.driverDB.DeleteAttachment(ctx, docID, rev, filename, mergeOptions(options...))
+	opts := db.mergeOptions(options...)
+	requireQuorum, err := requireQuorumOption(opts)
+	if err != nil {
+		return "", err
+	}
+	ctx, op := db.startOp(ctx, "DeleteAttachment", docID+"/"+filename)
+	defer op.done()
+	res, err := db.deleteAttachmentResult(ctx, docID, rev, filename, opts)
+	if err != nil {
+		return "", opError("DeleteAttachment", err)
+	}
+	return res.Rev, acceptedError("DeleteAttachment", res, requireQuorum)
 }
 
 // PurgeResult is the result of a purge request.
@@ -597,19 +790,47 @@ type PurgeResult struct {
 //
 // Purge expects as input a map with document ID as key, and slice of
 // revisions as value.
+//
+// Large purges are split into several requests, each of at most 100
+// documents, and no more revisions than the database's purged_infos_limit
+// (see PurgedInfosLimit), so that indexes can keep up. The results are
+// combined, with Seq from the final request. If a request fails, the result
+// of those which succeeded is returned with the error.
 func (db *DB) Purge(ctx context.Context, docRevMap map[string][]string) (*PurgeResult, error) {
 	if db.err != nil {
 		return nil, db.err
 	}
-	if purger, ok := db.driverDB.(driver.Purger); ok {
+	purger, ok := db.driverDB.(driver.Purger)
+	if !ok {
+		return nil, &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: purge not supported by driver"}
+	}
+	ctx, op := db.startOp(ctx, "Purge", "_purge")
+	defer op.done()
+	revLimit, err := db.purgeRevLimit(ctx)
+	if err != nil {
+		return nil, opError("Purge", err)
+	}
+	batches := purgeBatches(docRevMap, maxPurgeDocs, revLimit)
+	if len(batches) <= 1 {
 		res, err := purger.Purge(ctx, docRevMap)
 		if err != nil {
-			return nil, err
+			return nil, opError("Purge", err)
 		}
 		r := PurgeResult(*res)
 		return &r, nil
 	}
-	return nil, &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: purge not supported by driver"}
+	result := &PurgeResult{Purged: map[string][]string{}}
+	for _, batch := range batches {
+		res, err := purger.Purge(ctx, batch)
+		if err != nil {
+			return result, opError("Purge", err)
+		}
+		result.Seq = res.Seq
+		for docID, revs := range res.Purged {
+			result.Purged[docID] = append(result.Purged[docID], revs...)
+		}
+	}
+	return result, nil
 }
 
 // BulkGetReference is a reference to a document given in a BulkGet query.
@@ -636,11 +857,21 @@ func (db *DB) BulkGet(ctx context.Context, docs []BulkGetReference, options ...O
 	for i, ref := range docs {
 		refs[i] = driver.BulkGetReference(ref)
 	}
-	rowsi, err := bulkGetter.BulkGet(ctx, refs, mergeOptions(options...))
+	opts := db.mergeOptions(options...)
+	useNumber, err := useNumberOption(opts)
 	if err != nil {
 		return nil, err
 	}
-	return newRows(ctx, rowsi), nil
+	ctx, op := db.startOp(ctx, "BulkGet", "_bulk_get")
+	rowsi, err := bulkGetter.BulkGet(ctx, refs, driverOptions(opts))
+	if err != nil {
+		op.done()
+		return nil, opError("BulkGet", err)
+	}
+	rows := newRowsOpts(ctx, rowsi, useNumber)
+	db.measure(rows.iter, "BulkGet", "_bulk_get")
+	op.doneOnClose(rows.iter)
+	return rows, nil
 }
 
 // Close cleans up any resources used by the DB. The default CouchDB driver
@@ -650,7 +881,7 @@ func (db *DB) Close(ctx context.Context) error {
 		return db.err
 	}
 	if closer, ok := db.driverDB.(driver.DBCloser); ok {
-		return closer.Close(ctx)
+		return opError("Close", closer.Close(ctx))
 	}
 	return nil
 }
@@ -685,15 +916,67 @@ func (db *DB) RevsDiff(ctx context.Context, revMap interface{}) (*Rows, error) {
 		return nil, db.err
 	}
 	if rd, ok := db.driverDB.(driver.RevsDiffer); ok {
+		ctx, op := db.startOp(ctx, "RevsDiff", "_revs_diff")
 		rowsi, err := rd.RevsDiff(ctx, revMap)
 		if err != nil {
-			return nil, err
+			op.done()
+			return nil, opError("RevsDiff", err)
 		}
-		return newRows(ctx, rowsi), nil
+		rows := newRows(ctx, rowsi)
+		db.measure(rows.iter, "RevsDiff", "_revs_diff")
+		op.doneOnClose(rows.iter)
+		return rows, nil
 	}
 	return nil, &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: _revs_diff not supported by driver"}
 }
 
+// revsDiffBatchSize is the most documents DiffRevs includes in a single
+// RevsDiff request.
+var revsDiffBatchSize = 1000
+
+// DiffRevs is a typed wrapper around RevsDiff. It returns the RevDiff for
+// each document in revMap with revisions missing from the database; documents
+// with none missing are omitted. Large maps are split into several requests.
+func (db *DB) DiffRevs(ctx context.Context, revMap map[string][]string) (Diffs, error) {
+	docIDs := make([]string, 0, len(revMap))
+	for docID := range revMap {
+		docIDs = append(docIDs, docID)
+	}
+	sort.Strings(docIDs)
+	diffs := Diffs{}
+	for len(docIDs) > 0 {
+		n := revsDiffBatchSize
+		if n > len(docIDs) {
+			n = len(docIDs)
+		}
+		batch := make(map[string][]string, n)
+		for _, docID := range docIDs[:n] {
+			batch[docID] = revMap[docID]
+		}
+		docIDs = docIDs[n:]
+		if err := db.diffRevs(ctx, batch, diffs); err != nil {
+			return nil, err
+		}
+	}
+	return diffs, nil
+}
+
+func (db *DB) diffRevs(ctx context.Context, revMap map[string][]string, diffs Diffs) error {
+	rows, err := db.RevsDiff(ctx, revMap)
+	if err != nil {
+		return err
+	}
+	defer rows.Close() // nolint: errcheck
+	for rows.Next() {
+		var diff RevDiff
+		if err := rows.ScanValue(&diff); err != nil {
+			return err
+		}
+		diffs[rows.ID()] = diff
+	}
+	return rows.Err()
+}
+
 // PartitionStats contains partition statistics.
 type PartitionStats struct {
 	DBName          string
@@ -715,7 +998,7 @@ func (db *DB) PartitionStats(ctx context.Context, name string) (*PartitionStats,
 	if pdb, ok := db.driverDB.(driver.PartitionedDB); ok {
 		stats, err := pdb.PartitionStats(ctx, name)
 		if err != nil {
-			return nil, err
+			return nil, opError("PartitionStats", err)
 		}
 		s := PartitionStats(*stats)
 		return &s, nil
@@ -20,6 +20,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
 	"testing"
 
@@ -252,6 +253,13 @@ func TestQuery(t *testing.T) {
 			status: http.StatusInternalServerError,
 			err:    "db error",
 		},
+		{
+			name:    "group with reduce=false",
+			db:      &DB{driverDB: &mock.DB{}},
+			options: Options{"reduce": false, "group": true},
+			status:  http.StatusBadRequest,
+			err:     "kivik: group and group_level are invalid when reduce=false",
+		},
 		{
 			name: "success",
 			db: &DB{
@@ -316,7 +324,12 @@ func TestGet(t *testing.T) {
 				},
 			},
 			expected: &Row{
-				Err: fmt.Errorf("db error"),
+				Err: &Error{
+					HTTPStatus: http.StatusInternalServerError,
+					Op:         "Get",
+					Reason:     "db error",
+					Err:        fmt.Errorf("db error"),
+				},
 			},
 		},
 		{
@@ -2024,12 +2037,104 @@ func TestRevsDiff(t *testing.T) {
 	})
 }
 
+func TestDiffRevs(t *testing.T) {
+	// missingRevs reports every revision as missing, except "1-a".
+	missingRevs := func(calls *int) *mock.RevsDiffer {
+		return &mock.RevsDiffer{
+			RevsDiffFunc: func(_ context.Context, revMap interface{}) (driver.Rows, error) {
+				*calls++
+				var docIDs []string
+				for docID := range revMap.(map[string][]string) {
+					docIDs = append(docIDs, docID)
+				}
+				sort.Strings(docIDs)
+				return &mock.Rows{
+					NextFunc: func(row *driver.Row) error {
+						for len(docIDs) > 0 {
+							docID := docIDs[0]
+							docIDs = docIDs[1:]
+							var missing []string
+							for _, rev := range revMap.(map[string][]string)[docID] {
+								if rev != "1-a" {
+									missing = append(missing, rev)
+								}
+							}
+							if len(missing) == 0 {
+								continue
+							}
+							value, _ := json.Marshal(driver.RevDiff{Missing: missing})
+							row.ID = docID
+							row.Value = value
+							return nil
+						}
+						return io.EOF
+					},
+					CloseFunc: func() error { return nil },
+				}, nil
+			},
+		}
+	}
+	type tt struct {
+		db       *DB
+		revMap   map[string][]string
+		calls    int
+		expected Diffs
+		status   int
+		err      string
+	}
+	var calls int
+	tests := testy.NewTable()
+	tests.Add("not supported", tt{
+		db:     &DB{driverDB: &mock.DB{}},
+		revMap: map[string][]string{"foo": {"1-a"}},
+		status: http.StatusNotImplemented,
+		err:    "kivik: _revs_diff not supported by driver",
+	})
+	tests.Add("empty", func(t *testing.T) interface{} {
+		calls = 0
+		return tt{
+			db:       &DB{driverDB: missingRevs(&calls)},
+			expected: Diffs{},
+		}
+	})
+	tests.Add("batched", func(t *testing.T) interface{} {
+		calls = 0
+		return tt{
+			db: &DB{driverDB: missingRevs(&calls)},
+			revMap: map[string][]string{
+				"a": {"1-a", "2-b"},
+				"b": {"1-a"},
+				"c": {"3-c"},
+			},
+			calls: 2,
+			expected: Diffs{
+				"a": {Missing: []string{"2-b"}},
+				"c": {Missing: []string{"3-c"}},
+			},
+		}
+	})
+
+	revsDiffBatchSize = 2
+	defer func() { revsDiffBatchSize = 1000 }()
+	tests.Run(t, func(t *testing.T, tt tt) {
+		diffs, err := tt.db.DiffRevs(context.Background(), tt.revMap)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if calls != tt.calls {
+			t.Errorf("Expected %d requests, got %d", tt.calls, calls)
+		}
+		if d := testy.DiffInterface(tt.expected, diffs); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
 func TestPartitionStats(t *testing.T) {
 	type tt struct {
-		db     *DB
-		name   string
-		status int
-		err    string
+		db       *DB
+		name     string
+		expected *PartitionStats
+		status   int
+		err      string
 	}
 	tests := testy.NewTable()
 	tests.Add("non-PartitionedDB", tt{
@@ -2066,12 +2171,217 @@ func TestPartitionStats(t *testing.T) {
 			},
 		},
 		name: "partXX",
+		expected: &PartitionStats{
+			DBName:    "dbXX",
+			Partition: "partXX",
+			DocCount:  123,
+		},
 	})
 
 	tests.Run(t, func(t *testing.T, tt tt) {
 		result, err := tt.db.PartitionStats(context.Background(), tt.name)
 		testy.StatusError(t, tt.err, tt.status, err)
-		if d := testy.DiffInterface(testy.Snapshot(t), result); d != nil {
+		if d := testy.DiffInterface(tt.expected, result); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestDBWithOptions(t *testing.T) {
+	var got map[string]interface{}
+	db := &DB{
+		driverDB: &mock.DB{
+			AllDocsFunc: func(_ context.Context, opts map[string]interface{}) (driver.Rows, error) {
+				got = opts
+				return &mock.Rows{}, nil
+			},
+		},
+	}
+	base := db.WithOptions(Options{"include_docs": true, "limit": 10})
+	derived := base.WithOptions(Options{"update": "lazy"})
+	tests := []struct {
+		name     string
+		db       *DB
+		options  Options
+		expected map[string]interface{}
+	}{
+		{
+			name: "no defaults",
+			db:   db,
+		},
+		{
+			name:     "defaults",
+			db:       base,
+			expected: map[string]interface{}{"include_docs": true, "limit": 10},
+		},
+		{
+			name:     "per-call options take precedence",
+			db:       base,
+			options:  Options{"limit": 5},
+			expected: map[string]interface{}{"include_docs": true, "limit": 5},
+		},
+		{
+			name:     "chained",
+			db:       derived,
+			expected: map[string]interface{}{"include_docs": true, "limit": 10, "update": "lazy"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := test.db.AllDocs(context.Background(), test.options); err != nil {
+				t.Fatal(err)
+			}
+			if d := testy.DiffInterface(test.expected, got); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+	t.Run("Options", func(t *testing.T) {
+		if opts := db.Options(); opts != nil {
+			t.Errorf("Unexpected options: %v", opts)
+		}
+		opts := derived.Options()
+		opts["limit"] = 1
+		expected := Options{"include_docs": true, "limit": 10, "update": "lazy"}
+		if d := testy.DiffInterface(expected, derived.Options()); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestKivikOptionsNotPassedToDriver(t *testing.T) {
+	var got map[string]interface{}
+	record := func(opts map[string]interface{}) {
+		got = opts
+	}
+	driverDB := &mock.DB{
+		PutFunc: func(_ context.Context, _ string, _ interface{}, opts map[string]interface{}) (string, error) {
+			record(opts)
+			return "1-xxx", nil
+		},
+		CreateDocFunc: func(_ context.Context, _ interface{}, opts map[string]interface{}) (string, string, error) {
+			record(opts)
+			return "foo", "1-xxx", nil
+		},
+		DeleteFunc: func(_ context.Context, _, _ string, opts map[string]interface{}) (string, error) {
+			record(opts)
+			return "2-xxx", nil
+		},
+		PutAttachmentFunc: func(_ context.Context, _, _ string, _ *driver.Attachment, opts map[string]interface{}) (string, error) {
+			record(opts)
+			return "2-xxx", nil
+		},
+		GetAttachmentFunc: func(_ context.Context, _, _ string, opts map[string]interface{}) (*driver.Attachment, error) {
+			record(opts)
+			return &driver.Attachment{Content: ioutil.NopCloser(strings.NewReader("x"))}, nil
+		},
+		DeleteAttachmentFunc: func(_ context.Context, _, _, _ string, opts map[string]interface{}) (string, error) {
+			record(opts)
+			return "3-xxx", nil
+		},
+		GetFunc: func(_ context.Context, _ string, opts map[string]interface{}) (*driver.Document, error) {
+			record(opts)
+			return &driver.Document{Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+		},
+		AllDocsFunc: func(_ context.Context, opts map[string]interface{}) (driver.Rows, error) {
+			record(opts)
+			return &mock.Rows{}, nil
+		},
+		QueryFunc: func(_ context.Context, _, _ string, opts map[string]interface{}) (driver.Rows, error) {
+			record(opts)
+			return &mock.Rows{}, nil
+		},
+		ChangesFunc: func(_ context.Context, opts map[string]interface{}) (driver.Changes, error) {
+			record(opts)
+			return &mock.Changes{}, nil
+		},
+	}
+	type tt struct {
+		options Options
+		call    func(*DB) error
+	}
+	tests := testy.NewTable()
+	tests.Add("Put", tt{
+		options: Options{OptionUseNumber: true},
+		call: func(db *DB) error {
+			_, err := db.Put(context.Background(), "foo", map[string]string{})
+			return err
+		},
+	})
+	tests.Add("CreateDoc", tt{
+		options: Options{OptionUseNumber: true},
+		call: func(db *DB) error {
+			_, _, err := db.CreateDoc(context.Background(), map[string]string{})
+			return err
+		},
+	})
+	tests.Add("Delete", tt{
+		options: Options{OptionUseNumber: true},
+		call: func(db *DB) error {
+			_, err := db.Delete(context.Background(), "foo", "1-xxx")
+			return err
+		},
+	})
+	tests.Add("PutAttachment", tt{
+		options: Options{OptionUseNumber: true},
+		call: func(db *DB) error {
+			_, err := db.PutAttachment(context.Background(), "foo", "1-xxx", &Attachment{
+				Filename:    "foo.txt",
+				ContentType: "text/plain",
+				Content:     ioutil.NopCloser(strings.NewReader("x")),
+			})
+			return err
+		},
+	})
+	tests.Add("GetAttachment", tt{
+		options: Options{OptionUseNumber: true},
+		call: func(db *DB) error {
+			_, err := db.GetAttachment(context.Background(), "foo", "foo.txt")
+			return err
+		},
+	})
+	tests.Add("DeleteAttachment", tt{
+		options: Options{OptionUseNumber: true},
+		call: func(db *DB) error {
+			_, err := db.DeleteAttachment(context.Background(), "foo", "2-xxx", "foo.txt")
+			return err
+		},
+	})
+	reads := map[string]func(*DB) error{
+		"Get": func(db *DB) error {
+			return db.Get(context.Background(), "foo").Err
+		},
+		"AllDocs": func(db *DB) error {
+			_, err := db.AllDocs(context.Background())
+			return err
+		},
+		"Query": func(db *DB) error {
+			_, err := db.Query(context.Background(), "ddoc", "view")
+			return err
+		},
+		"Changes": func(db *DB) error {
+			_, err := db.Changes(context.Background())
+			return err
+		},
+	}
+	for name, call := range reads {
+		tests.Add(name+" require quorum", tt{
+			options: Options{OptionRequireQuorum: true},
+			call:    call,
+		})
+		tests.Add(name+" fetch conflict", tt{
+			options: Options{OptionFetchConflictRev: true, OptionFetchConflictDoc: true},
+			call:    call,
+		})
+	}
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		got = nil
+		db := (&DB{driverDB: driverDB}).WithOptions(tt.options, Options{"foo": "bar"})
+		if err := tt.call(db); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(map[string]interface{}{"foo": "bar"}, got); d != nil {
 			t.Error(d)
 		}
 	})
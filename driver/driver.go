@@ -149,6 +149,44 @@ type Members struct {
 type Security struct {
 	Admins  Members `json:"admins"`
 	Members Members `json:"members"`
+
+	// Extra holds any other fields of the security document, such as the
+	// "cloudant" field used by Cloudant, keyed by field name.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// MarshalJSON satisfies the json.Marshaler interface, including s.Extra
+// alongside the standard fields.
+func (s Security) MarshalJSON() ([]byte, error) {
+	doc := make(map[string]interface{}, len(s.Extra)+2)
+	for k, v := range s.Extra {
+		doc[k] = v
+	}
+	doc["admins"] = s.Admins
+	doc["members"] = s.Members
+	return json.Marshal(doc)
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface, collecting any
+// non-standard fields in s.Extra.
+func (s *Security) UnmarshalJSON(data []byte) error {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	*s = Security{}
+	for key, dest := range map[string]*Members{"admins": &s.Admins, "members": &s.Members} {
+		if raw, ok := doc[key]; ok {
+			if err := json.Unmarshal(raw, dest); err != nil {
+				return err
+			}
+			delete(doc, key)
+		}
+	}
+	if len(doc) > 0 {
+		s.Extra = doc
+	}
+	return nil
 }
 
 // DB is a database handle.
@@ -232,6 +270,23 @@ type Purger interface {
 	Purge(ctx context.Context, docRevMap map[string][]string) (*PurgeResult, error)
 }
 
+// Snapshotter is an optional interface which may be implemented by a DB to
+// provide read-only, point-in-time views of the database.
+type Snapshotter interface {
+	// Snapshot returns a read-only view of the database as it is at the time
+	// of the call, which later writes to the database do not affect.
+	Snapshot(ctx context.Context) (DB, error)
+}
+
+// PurgedInfosLimiter is an optional interface which may be implemented by a
+// DB to get and set the number of purge requests the database retains.
+type PurgedInfosLimiter interface {
+	// PurgedInfosLimit returns the database's purged_infos_limit.
+	PurgedInfosLimit(ctx context.Context) (int64, error)
+	// SetPurgedInfosLimit sets the database's purged_infos_limit.
+	SetPurgedInfosLimit(ctx context.Context, limit int64) error
+}
+
 // PurgeResult is the result of a purge request.
 type PurgeResult struct {
 	Seq    int64               `json:"purge_seq"`
@@ -334,6 +389,35 @@ type BulkResult struct {
 	ID    string `json:"id"`
 	Rev   string `json:"rev"`
 	Error error
+	// Accepted is true if the update was accepted without confirmation that
+	// it was committed to a quorum of nodes, as for WriteResult.
+	Accepted bool `json:"-"`
+}
+
+// WriteResult is the result of a document write, as reported by a
+// QuorumWriter.
+type WriteResult struct {
+	// ID is the ID of the document written, as assigned by CreateDoc.
+	ID string
+	// Rev is the new revision of the document.
+	Rev string
+	// Accepted is true if the server accepted the write, but did not confirm
+	// that it was committed to a quorum of nodes, as signalled by CouchDB
+	// with status 202 rather than 201. The write has nonetheless been stored.
+	Accepted bool
+}
+
+// QuorumWriter is an optional interface which may be implemented by a DB to
+// report, with the result of each document write, whether the write was
+// committed to a quorum of nodes. Each method is otherwise as the DB method
+// of the same name, without the Result suffix, which is not called when
+// QuorumWriter is implemented.
+type QuorumWriter interface {
+	PutResult(ctx context.Context, docID string, doc interface{}, options map[string]interface{}) (*WriteResult, error)
+	CreateDocResult(ctx context.Context, doc interface{}, options map[string]interface{}) (*WriteResult, error)
+	DeleteResult(ctx context.Context, docID, rev string, options map[string]interface{}) (*WriteResult, error)
+	PutAttachmentResult(ctx context.Context, docID, rev string, att *Attachment, options map[string]interface{}) (*WriteResult, error)
+	DeleteAttachmentResult(ctx context.Context, docID, rev, filename string, options map[string]interface{}) (*WriteResult, error)
 }
 
 // BulkResults is an iterator over the results for a BulkDocs call.
@@ -383,6 +467,15 @@ type DesignDocer interface {
 	DesignDocs(ctx context.Context, options map[string]interface{}) (Rows, error)
 }
 
+// MultiQuerier is an optional interface that may be implemented by a DB, to
+// execute several queries against a view in a single request.
+//
+// The returned Rows should return EOQ at the end of each query's results,
+// except the last, and should implement QueryIndexer.
+type MultiQuerier interface {
+	QueryMulti(ctx context.Context, ddoc, view string, queries []map[string]interface{}) (Rows, error)
+}
+
 // LocalDocer is an optional interface that may be implemented by a DB.
 type LocalDocer interface {
 	// LocalDocs returns all of the local documents in the database, subject to
@@ -390,6 +483,19 @@ type LocalDocer interface {
 	LocalDocs(ctx context.Context, options map[string]interface{}) (Rows, error)
 }
 
+// LocalDocStorer is an optional interface that may be implemented by a DB to
+// read and write local documents directly. docID is given without the
+// "_local/" prefix. PutLocal and DeleteLocal apply regardless of the current
+// revision, if any.
+//
+// If a DB does not implement LocalDocStorer, Kivik emulates it by calling Get,
+// Put and Delete with prefixed IDs, fetching the current revision as needed.
+type LocalDocStorer interface {
+	GetLocal(ctx context.Context, docID string, options map[string]interface{}) (*Document, error)
+	PutLocal(ctx context.Context, docID string, doc interface{}, options map[string]interface{}) error
+	DeleteLocal(ctx context.Context, docID string, options map[string]interface{}) error
+}
+
 // Pinger is an optional interface that may be implemented by a Client. When
 // not implemented, Kivik will call Version instead, to determine if the
 // database is usable.
@@ -0,0 +1,172 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver
+
+import (
+	"context"
+	"sync"
+)
+
+// Priority is the priority of a request, relative to the client's other
+// requests. The zero value is PriorityNormal.
+type Priority int
+
+// Request priorities.
+const (
+	// PriorityLow is for background work, such as batch jobs, which should
+	// give way to other requests.
+	PriorityLow Priority = -1
+	// PriorityNormal is the default.
+	PriorityNormal Priority = 0
+	// PriorityHigh is for latency-sensitive requests, such as reads which
+	// serve a user waiting for a response.
+	PriorityHigh Priority = 1
+)
+
+// PriorityHeader is the HTTP request header by which a driver may convey a
+// request's priority to a proxy in front of the server, with the value
+// returned by Priority.String.
+const PriorityHeader = "X-Couch-Priority"
+
+func (p Priority) String() string {
+	switch {
+	case p < PriorityNormal:
+		return "low"
+	case p > PriorityNormal:
+		return "high"
+	}
+	return "normal"
+}
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx which carries priority p. Drivers
+// which limit concurrent requests should admit waiting requests in order of
+// priority, as PriorityLimiter does.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext returns the priority carried by ctx, or
+// PriorityNormal.
+func PriorityFromContext(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityKey{}).(Priority)
+	return p
+}
+
+// PriorityLimiter limits the number of requests in flight at once. When
+// the limit is reached, requests wait for a slot, and are admitted highest
+// priority first, and in order of arrival within a priority. It may be used
+// by drivers to schedule requests, and to report the ActiveRequests and
+// WaitingRequests of ClientStats.
+//
+// Admission is strictly by priority, so under sustained load, low priority
+// requests may wait until higher priority requests let up.
+type PriorityLimiter struct {
+	limit int
+
+	mu      sync.Mutex
+	active  int
+	waiters []*priorityWaiter
+}
+
+type priorityWaiter struct {
+	priority Priority
+	ready    chan struct{}
+	admitted bool
+}
+
+// NewPriorityLimiter returns a PriorityLimiter which allows limit requests
+// in flight at once. If limit is less than 1, requests are not limited.
+func NewPriorityLimiter(limit int) *PriorityLimiter {
+	return &PriorityLimiter{limit: limit}
+}
+
+// Acquire blocks until a slot is available for a request with the priority
+// carried by ctx, or until ctx is done, in which case it returns ctx.Err().
+// On success, release must be called when the request is complete.
+func (l *PriorityLimiter) Acquire(ctx context.Context) (release func(), err error) {
+	l.mu.Lock()
+	if l.limit < 1 || (l.active < l.limit && len(l.waiters) == 0) {
+		l.active++
+		l.mu.Unlock()
+		return l.releaseFunc(), nil
+	}
+	w := &priorityWaiter{
+		priority: PriorityFromContext(ctx),
+		ready:    make(chan struct{}),
+	}
+	l.waiters = append(l.waiters, w)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return l.releaseFunc(), nil
+	case <-ctx.Done():
+	}
+	l.mu.Lock()
+	if w.admitted {
+		// The slot was handed over as ctx was done; pass it on.
+		l.mu.Unlock()
+		l.release()
+		return nil, ctx.Err()
+	}
+	for i, other := range l.waiters {
+		if other == w {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			break
+		}
+	}
+	l.mu.Unlock()
+	return nil, ctx.Err()
+}
+
+func (l *PriorityLimiter) releaseFunc() func() {
+	var once sync.Once
+	return func() { once.Do(l.release) }
+}
+
+// release frees a slot, handing it to the first waiter of the highest
+// priority, if any.
+func (l *PriorityLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.waiters) == 0 {
+		l.active--
+		return
+	}
+	next := 0
+	for i, w := range l.waiters {
+		if w.priority > l.waiters[next].priority {
+			next = i
+		}
+	}
+	w := l.waiters[next]
+	l.waiters = append(l.waiters[:next], l.waiters[next+1:]...)
+	w.admitted = true
+	close(w.ready)
+}
+
+// Active returns the number of requests in flight.
+func (l *PriorityLimiter) Active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active
+}
+
+// Waiting returns the number of requests waiting for a slot.
+func (l *PriorityLimiter) Waiting() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.waiters)
+}
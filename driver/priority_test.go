@@ -0,0 +1,122 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPriorityString(t *testing.T) {
+	for p, expected := range map[Priority]string{
+		PriorityLow:    "low",
+		PriorityNormal: "normal",
+		PriorityHigh:   "high",
+		-5:             "low",
+		5:              "high",
+	} {
+		if s := p.String(); s != expected {
+			t.Errorf("%d: got %q, expected %q", p, s, expected)
+		}
+	}
+}
+
+// waitFor polls until cond is true, or fails the test.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPriorityLimiter(t *testing.T) {
+	t.Run("unlimited", func(t *testing.T) {
+		l := NewPriorityLimiter(0)
+		for i := 0; i < 3; i++ {
+			if _, err := l.Acquire(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if l.Active() != 3 {
+			t.Errorf("Unexpected active count: %d", l.Active())
+		}
+	})
+	t.Run("admission order", func(t *testing.T) {
+		l := NewPriorityLimiter(1)
+		release, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		admitted := make(chan string, 4)
+		acquire := func(name string, p Priority) {
+			waiting := l.Waiting()
+			go func() {
+				release, err := l.Acquire(WithPriority(context.Background(), p))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				admitted <- name
+				release()
+			}()
+			waitFor(t, func() bool { return l.Waiting() == waiting+1 })
+		}
+		acquire("low", PriorityLow)
+		acquire("normal 1", PriorityNormal)
+		acquire("high", PriorityHigh)
+		acquire("normal 2", PriorityNormal)
+		release()
+		release() // Releasing twice has no further effect.
+		var order []string
+		for i := 0; i < 4; i++ {
+			order = append(order, <-admitted)
+		}
+		expected := []string{"high", "normal 1", "normal 2", "low"}
+		for i := range expected {
+			if order[i] != expected[i] {
+				t.Fatalf("Unexpected order: %v", order)
+			}
+		}
+		waitFor(t, func() bool { return l.Active() == 0 })
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		l := NewPriorityLimiter(1)
+		release, err := l.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error)
+		go func() {
+			_, err := l.Acquire(ctx)
+			errs <- err
+		}()
+		waitFor(t, func() bool { return l.Waiting() == 1 })
+		cancel()
+		if err := <-errs; err != context.Canceled {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if l.Waiting() != 0 {
+			t.Errorf("Unexpected waiting count: %d", l.Waiting())
+		}
+		release()
+		if l.Active() != 0 {
+			t.Errorf("Unexpected active count: %d", l.Active())
+		}
+	})
+}
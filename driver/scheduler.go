@@ -0,0 +1,69 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver
+
+import (
+	"context"
+	"time"
+)
+
+// SchedulerJob represents a replication job, as reported by the
+// /_scheduler/jobs endpoint.
+type SchedulerJob struct {
+	Database  string              `json:"database"`
+	JobID     string              `json:"id"`
+	PID       string              `json:"pid"`
+	Source    string              `json:"source"`
+	Target    string              `json:"target"`
+	User      string              `json:"user"`
+	DocID     string              `json:"doc_id"`
+	History   []SchedulerJobEvent `json:"history"`
+	Node      string              `json:"node"`
+	StartTime time.Time           `json:"start_time"`
+}
+
+// SchedulerJobEvent is an entry in a replication job's history.
+type SchedulerJobEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	Type      string    `json:"type"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// SchedulerDoc represents the state of a _replicator document, as reported by
+// the /_scheduler/docs endpoint.
+type SchedulerDoc struct {
+	Database      string                 `json:"database"`
+	DocID         string                 `json:"doc_id"`
+	ReplicationID string                 `json:"id"`
+	Node          string                 `json:"node"`
+	Source        string                 `json:"source"`
+	Target        string                 `json:"target"`
+	State         string                 `json:"state"`
+	Info          map[string]interface{} `json:"info"`
+	ErrorCount    int                    `json:"error_count"`
+	LastUpdated   time.Time              `json:"last_updated"`
+	StartTime     time.Time              `json:"start_time"`
+}
+
+// Scheduler is an optional interface that may be implemented by a Client to
+// report on the replication scheduler, added in CouchDB 2.1.
+type Scheduler interface {
+	// SchedulerJobs returns the replication jobs currently being run.
+	SchedulerJobs(ctx context.Context, options map[string]interface{}) ([]*SchedulerJob, error)
+	// SchedulerDocs returns the state of replications defined in all
+	// _replicator databases.
+	SchedulerDocs(ctx context.Context, options map[string]interface{}) ([]*SchedulerDoc, error)
+	// SchedulerDoc returns the state of a single replication document in
+	// the named replicator database.
+	SchedulerDoc(ctx context.Context, replicatorDB, docID string) (*SchedulerDoc, error)
+}
@@ -0,0 +1,58 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ClientStats contains connection pool and concurrency statistics for a
+// client.
+type ClientStats struct {
+	// MaxIdleConns is the configured maximum number of idle connections
+	// across all hosts, or 0 for no limit.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost is the configured maximum number of idle
+	// connections per host.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost is the configured maximum number of connections per
+	// host, including those in use, or 0 for no limit.
+	MaxConnsPerHost int
+	// MaxConcurrentRequests is the configured maximum number of requests
+	// allowed in flight at once, or 0 for no limit.
+	MaxConcurrentRequests int
+	// ActiveRequests is the number of requests currently in flight.
+	ActiveRequests int
+	// WaitingRequests is the number of requests currently blocked, waiting
+	// for a concurrency slot.
+	WaitingRequests int
+	// TotalRequests is the total number of requests issued by the client.
+	TotalRequests int64
+}
+
+// ClientStatser is an optional interface that may be implemented by a Client
+// to report connection pool and concurrency statistics.
+type ClientStatser interface {
+	// Stats returns the current client statistics.
+	Stats(ctx context.Context) (*ClientStats, error)
+}
+
+// NodeStatser is an optional interface that may be implemented by a Client to
+// report the statistics of a cluster node, as returned by
+// /_node/{node}/_stats.
+type NodeStatser interface {
+	// NodeStats returns the raw JSON statistics of node. If path is given,
+	// only the statistics under that path are returned.
+	NodeStats(ctx context.Context, node string, path ...string) (json.RawMessage, error)
+}
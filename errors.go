@@ -18,14 +18,17 @@ import (
 	"strings"
 
 	"golang.org/x/xerrors"
+
+	"github.com/go-kivik/kivik/v4/errors"
 )
 
 // Error represents an error returned by Kivik.
 //
-// This type definition is not guaranteed to remain stable, or even exported.
-// When examining errors programatically, you should rely instead on the
-// StatusCode() function in this package, rather than on directly observing
-// the fields of this type.
+// Errors returned by a driver for the core client and database operations are
+// wrapped in an *Error, which may be retrieved with errors.As to examine the
+// failed operation, and the CouchDB error and reason. For the common cases,
+// the predicates such as IsNotFound and IsConflict are simpler, and work for
+// any error which carries an HTTP status.
 type Error struct {
 	// HTTPStatus is the HTTP status code associated with this error. Normally
 	// this is the actual HTTP status returned by the server, but in some cases
@@ -40,6 +43,16 @@ type Error struct {
 	// Message is the error message.
 	Message string
 
+	// Op is the name of the operation which failed, such as "Put", if known.
+	Op string
+
+	// CouchError is the CouchDB error name, such as "conflict" or
+	// "not_found", if known.
+	CouchError string
+
+	// Reason is the CouchDB human-readable reason for the error, if known.
+	Reason string
+
 	// Err is the originating error, if any.
 	Err error
 }
@@ -121,6 +134,79 @@ type causer interface {
 	Cause() error
 }
 
+type reasoner interface {
+	Reason() string
+}
+
+// couchErrorer may be implemented by driver errors which know the CouchDB
+// error name reported by the server.
+type couchErrorer interface {
+	CouchError() string
+}
+
+// opError wraps err, returned by the driver for op, in an *Error carrying its
+// HTTP status, and its CouchDB error and reason where available. If err is
+// already an *Error, a copy is returned with Op set, rather than wrapping it
+// again. A nil err returns nil.
+func opError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if kerr, ok := err.(*Error); ok {
+		copied := *kerr
+		e = &copied
+	} else {
+		e = &Error{HTTPStatus: StatusCode(err), Err: err}
+		var kerr *Error
+		if xerrors.As(err, &kerr) {
+			e.FromServer = kerr.FromServer
+		}
+	}
+	e.Op = op
+	if e.Reason == "" {
+		e.Reason = err.Error()
+		var r reasoner
+		if xerrors.As(err, &r) {
+			e.Reason = r.Reason()
+		}
+	}
+	if e.CouchError == "" {
+		var ce couchErrorer
+		if xerrors.As(err, &ce) {
+			e.CouchError = ce.CouchError()
+		} else if status := httpStatus(err); status != 0 {
+			e.CouchError = errors.StatusText(status)
+		}
+	}
+	return e
+}
+
+// httpStatus returns the HTTP status carried by err, as StatusCode does, or 0
+// if it carries none, as for context.Canceled or an I/O error.
+func httpStatus(err error) int {
+	var coder statusCoder
+	for err != nil {
+		if xerrors.As(err, &coder) {
+			if kerr, ok := coder.(*Error); ok && kerr.HTTPStatus == 0 {
+				err = kerr.Err
+				continue
+			}
+			return coder.StatusCode()
+		}
+		if uw := xerrors.Unwrap(err); uw != nil {
+			err = uw
+			continue
+		}
+		c, ok := err.(causer)
+		if !ok {
+			return 0
+		}
+		err = c.Cause()
+	}
+	return 0
+}
+
 // StatusCode returns the HTTP status code embedded in the error, or 500
 // (internal server error), if there was no specified status code.  If err is
 // nil, StatusCode returns 0. This provides a convenient way to determine the
@@ -164,3 +250,35 @@ func StatusCode(err error) int {
 		return http.StatusInternalServerError
 	}
 }
+
+// IsBadRequest returns true if err carries a 400 (bad request) status.
+func IsBadRequest(err error) bool {
+	return err != nil && StatusCode(err) == http.StatusBadRequest
+}
+
+// IsUnauthorized returns true if err carries a 401 (unauthorized) status.
+func IsUnauthorized(err error) bool {
+	return err != nil && StatusCode(err) == http.StatusUnauthorized
+}
+
+// IsForbidden returns true if err carries a 403 (forbidden) status.
+func IsForbidden(err error) bool {
+	return err != nil && StatusCode(err) == http.StatusForbidden
+}
+
+// IsNotFound returns true if err carries a 404 (not found) status.
+func IsNotFound(err error) bool {
+	return err != nil && StatusCode(err) == http.StatusNotFound
+}
+
+// IsConflict returns true if err carries a 409 (conflict) status, as returned
+// for document update conflicts.
+func IsConflict(err error) bool {
+	return err != nil && StatusCode(err) == http.StatusConflict
+}
+
+// IsPreconditionFailed returns true if err carries a 412 (precondition
+// failed) status, as returned when creating a database which already exists.
+func IsPreconditionFailed(err error) bool {
+	return err != nil && StatusCode(err) == http.StatusPreconditionFailed
+}
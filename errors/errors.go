@@ -32,7 +32,7 @@ type statusError struct {
 // type.
 func (se *statusError) MarshalJSON() ([]byte, error) {
 	return json.Marshal(map[string]string{
-		"error":  statusText(se.statusCode),
+		"error":  StatusText(se.statusCode),
 		"reason": se.message,
 	})
 }
@@ -35,9 +35,10 @@ var statusTextStrings = map[int]string{
 	604: "bad_api_call",
 }
 
-// statusText returns a text for the HTTP status code. It returns the string
-// "unknown" if the code is unknown to Kivik.
-func statusText(code int) string {
+// StatusText returns the CouchDB error name for the HTTP status code, such as
+// "not_found" for 404. It returns the string "unknown" if the code is unknown
+// to Kivik.
+func StatusText(code int) string {
 	if text, ok := statusTextStrings[code]; ok {
 		return text
 	}
@@ -38,7 +38,7 @@ func TestStatusText(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			result := statusText(test.code)
+			result := StatusText(test.code)
 			if test.expected != result {
 				t.Errorf("Unexpected result: %s", result)
 			}
@@ -13,16 +13,22 @@
 package kivik
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
+	"strings"
 	"testing"
 
 	pkgerrs "github.com/pkg/errors"
 	"gitlab.com/flimzy/testy"
 	"golang.org/x/xerrors"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	kerrs "github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/internal/mock"
 )
 
 func TestStatusCoder(t *testing.T) {
@@ -156,3 +162,588 @@ func TestFormatError(t *testing.T) {
 		}
 	})
 }
+
+type couchErr struct{ error }
+
+func (couchErr) StatusCode() int    { return http.StatusConflict }
+func (couchErr) CouchError() string { return "custom_conflict" }
+
+func TestOpError(t *testing.T) {
+	wrapped := pkgerrs.Wrap(kerrs.Status(http.StatusNotFound, "missing"), "get")
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "plain error",
+			err:  errors.New("oops"),
+			expected: &Error{
+				HTTPStatus: http.StatusInternalServerError,
+				Op:         "Put",
+				Reason:     "oops",
+				Err:        errors.New("oops"),
+			},
+		},
+		{
+			name: "context canceled",
+			err:  context.Canceled,
+			expected: &Error{
+				HTTPStatus: http.StatusInternalServerError,
+				Op:         "Put",
+				Reason:     "context canceled",
+				Err:        context.Canceled,
+			},
+		},
+		{
+			name: "wrapped status",
+			err:  wrapped,
+			expected: &Error{
+				HTTPStatus: http.StatusNotFound,
+				Op:         "Put",
+				CouchError: "not_found",
+				Reason:     "get: missing",
+				Err:        wrapped,
+			},
+		},
+		{
+			name: "status error with reason",
+			err:  kerrs.Status(http.StatusNotFound, "missing"),
+			expected: &Error{
+				HTTPStatus: http.StatusNotFound,
+				Op:         "Put",
+				CouchError: "not_found",
+				Reason:     "missing",
+				Err:        kerrs.Status(http.StatusNotFound, "missing"),
+			},
+		},
+		{
+			name: "server error",
+			err:  &Error{HTTPStatus: http.StatusConflict, FromServer: true, Message: "conflict"},
+			expected: &Error{
+				HTTPStatus: http.StatusConflict,
+				FromServer: true,
+				Message:    "conflict",
+				Op:         "Put",
+				CouchError: "conflict",
+				Reason:     "conflict",
+			},
+		},
+		{
+			name: "kivik error without status",
+			err:  &Error{Message: "kivik: oops", Err: context.Canceled},
+			expected: &Error{
+				Message: "kivik: oops",
+				Op:      "Put",
+				Reason:  "kivik: oops: context canceled",
+				Err:     context.Canceled,
+			},
+		},
+		{
+			name: "driver CouchDB error name",
+			err:  couchErr{errors.New("conflict")},
+			expected: &Error{
+				HTTPStatus: http.StatusConflict,
+				Op:         "Put",
+				CouchError: "custom_conflict",
+				Reason:     "conflict",
+				Err:        couchErr{errors.New("conflict")},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := opError("Put", test.err)
+			if d := testy.DiffInterface(test.expected, err); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestOpErrorMethods(t *testing.T) {
+	ctx := context.Background()
+	failed := kerrs.Status(http.StatusBadGateway, "failed")
+	db := func(driverDB driver.DB) *DB {
+		return &DB{driverDB: driverDB}
+	}
+	client := func(driverClient driver.Client) *Client {
+		return &Client{driverClient: driverClient}
+	}
+	failingDB := &mock.DB{
+		AllDocsFunc: func(context.Context, map[string]interface{}) (driver.Rows, error) {
+			return nil, failed
+		},
+		StatsFunc: func(context.Context) (*driver.DBStats, error) {
+			return nil, failed
+		},
+		CompactFunc: func(context.Context) error {
+			return failed
+		},
+		CompactViewFunc: func(context.Context, string) error {
+			return failed
+		},
+		ViewCleanupFunc: func(context.Context) error {
+			return failed
+		},
+		SecurityFunc: func(context.Context) (*driver.Security, error) {
+			return nil, failed
+		},
+		SetSecurityFunc: func(context.Context, *driver.Security) error {
+			return failed
+		},
+		ChangesFunc: func(context.Context, map[string]interface{}) (driver.Changes, error) {
+			return nil, failed
+		},
+	}
+	failingClient := &mock.Client{
+		VersionFunc: func(context.Context) (*driver.Version, error) {
+			return nil, failed
+		},
+		DBFunc: func(context.Context, string, map[string]interface{}) (driver.DB, error) {
+			return nil, failed
+		},
+	}
+
+	type tt struct {
+		op   string
+		call func() error
+	}
+	tests := testy.NewTable()
+	tests.Add("Find", tt{call: func() error {
+		_, err := db(&mock.OptsFinder{
+			FindFunc: func(context.Context, interface{}, map[string]interface{}) (driver.Rows, error) {
+				return nil, failed
+			},
+		}).Find(ctx, map[string]interface{}{})
+		return err
+	}})
+	tests.Add("CreateIndex", tt{call: func() error {
+		return db(&mock.OptsFinder{
+			CreateIndexFunc: func(context.Context, string, string, interface{}, map[string]interface{}) error {
+				return failed
+			},
+		}).CreateIndex(ctx, "", "", nil)
+	}})
+	tests.Add("DeleteIndex", tt{call: func() error {
+		return db(&mock.OptsFinder{
+			DeleteIndexFunc: func(context.Context, string, string, map[string]interface{}) error {
+				return failed
+			},
+		}).DeleteIndex(ctx, "", "")
+	}})
+	tests.Add("GetIndexes", tt{call: func() error {
+		_, err := db(&mock.OptsFinder{
+			GetIndexesFunc: func(context.Context, map[string]interface{}) ([]driver.Index, error) {
+				return nil, failed
+			},
+		}).GetIndexes(ctx)
+		return err
+	}})
+	tests.Add("Explain", tt{call: func() error {
+		_, err := db(&mock.OptsFinder{
+			ExplainFunc: func(context.Context, interface{}, map[string]interface{}) (*driver.QueryPlan, error) {
+				return nil, failed
+			},
+		}).Explain(ctx, nil)
+		return err
+	}})
+	tests.Add("DesignDocs", tt{call: func() error {
+		_, err := db(&mock.DesignDocer{
+			DesignDocsFunc: func(context.Context, map[string]interface{}) (driver.Rows, error) {
+				return nil, failed
+			},
+		}).DesignDocs(ctx)
+		return err
+	}})
+	tests.Add("LocalDocs", tt{call: func() error {
+		_, err := db(&mock.LocalDocer{
+			LocalDocsFunc: func(context.Context, map[string]interface{}) (driver.Rows, error) {
+				return nil, failed
+			},
+		}).LocalDocs(ctx)
+		return err
+	}})
+	tests.Add("GetMeta", tt{call: func() error {
+		_, _, err := db(&mock.MetaGetter{
+			GetMetaFunc: func(context.Context, string, map[string]interface{}) (int64, string, error) {
+				return 0, "", failed
+			},
+		}).GetMeta(ctx, "foo")
+		return err
+	}})
+	tests.Add("Flush", tt{call: func() error {
+		return db(&mock.Flusher{
+			FlushFunc: func(context.Context) error {
+				return failed
+			},
+		}).Flush(ctx)
+	}})
+	tests.Add("Copy", tt{call: func() error {
+		_, err := db(&mock.Copier{
+			CopyFunc: func(context.Context, string, string, map[string]interface{}) (string, error) {
+				return "", failed
+			},
+		}).Copy(ctx, "bar", "foo")
+		return err
+	}})
+	tests.Add("GetAttachmentMeta", tt{call: func() error {
+		_, err := db(&mock.AttachmentMetaGetter{
+			GetAttachmentMetaFunc: func(context.Context, string, string, map[string]interface{}) (*driver.Attachment, error) {
+				return nil, failed
+			},
+		}).GetAttachmentMeta(ctx, "foo", "foo.txt")
+		return err
+	}})
+	tests.Add("Purge", tt{call: func() error {
+		_, err := db(&mock.Purger{
+			PurgeFunc: func(context.Context, map[string][]string) (*driver.PurgeResult, error) {
+				return nil, failed
+			},
+		}).Purge(ctx, map[string][]string{"foo": {"1-xxx"}})
+		return err
+	}})
+	tests.Add("PurgedInfosLimit", tt{call: func() error {
+		_, err := db(&mock.PurgedInfosLimiter{
+			PurgedInfosLimitFunc: func(context.Context) (int64, error) {
+				return 0, failed
+			},
+		}).PurgedInfosLimit(ctx)
+		return err
+	}})
+	tests.Add("SetPurgedInfosLimit", tt{call: func() error {
+		return db(&mock.PurgedInfosLimiter{
+			SetPurgedInfosLimitFunc: func(context.Context, int64) error {
+				return failed
+			},
+		}).SetPurgedInfosLimit(ctx, 1)
+	}})
+	tests.Add("BulkDocs", tt{call: func() error {
+		_, err := db(&mock.BulkDocer{
+			BulkDocsFunc: func(context.Context, []interface{}, map[string]interface{}) (driver.BulkResults, error) {
+				return nil, failed
+			},
+		}).BulkDocs(ctx, []interface{}{map[string]string{}})
+		return err
+	}})
+	tests.Add("BulkGet", tt{call: func() error {
+		_, err := db(&mock.BulkGetter{
+			BulkGetFunc: func(context.Context, []driver.BulkGetReference, map[string]interface{}) (driver.Rows, error) {
+				return nil, failed
+			},
+		}).BulkGet(ctx, nil)
+		return err
+	}})
+	tests.Add("Close", tt{call: func() error {
+		return db(&mock.DBCloser{
+			CloseFunc: func(context.Context) error {
+				return failed
+			},
+		}).Close(ctx)
+	}})
+	tests.Add("RevsDiff", tt{call: func() error {
+		_, err := db(&mock.RevsDiffer{
+			RevsDiffFunc: func(context.Context, interface{}) (driver.Rows, error) {
+				return nil, failed
+			},
+		}).RevsDiff(ctx, nil)
+		return err
+	}})
+	tests.Add("PartitionStats", tt{call: func() error {
+		_, err := db(&mock.PartitionedDB{
+			PartitionStatsFunc: func(context.Context, string) (*driver.PartitionStats, error) {
+				return nil, failed
+			},
+		}).PartitionStats(ctx, "foo")
+		return err
+	}})
+	tests.Add("Snapshot", tt{call: func() error {
+		_, err := db(&mock.Snapshotter{
+			SnapshotFunc: func(context.Context) (driver.DB, error) {
+				return nil, failed
+			},
+		}).Snapshot(ctx)
+		return err
+	}})
+	tests.Add("Stats", tt{call: func() error {
+		_, err := db(failingDB).Stats(ctx)
+		return err
+	}})
+	tests.Add("Compact", tt{call: func() error {
+		return db(failingDB).Compact(ctx)
+	}})
+	tests.Add("CompactView", tt{call: func() error {
+		return db(failingDB).CompactView(ctx, "_design/foo")
+	}})
+	tests.Add("ViewCleanup", tt{call: func() error {
+		return db(failingDB).ViewCleanup(ctx)
+	}})
+	tests.Add("Security", tt{call: func() error {
+		_, err := db(failingDB).Security(ctx)
+		return err
+	}})
+	tests.Add("SetSecurity", tt{call: func() error {
+		return db(failingDB).SetSecurity(ctx, &Security{})
+	}})
+	tests.Add("CatchUpChanges", tt{call: func() error {
+		_, err := db(failingDB).CatchUpChanges(ctx, 10)
+		return err
+	}})
+	tests.Add("Version", tt{call: func() error {
+		_, err := client(failingClient).Version(ctx)
+		return err
+	}})
+	tests.Add("DB", tt{call: func() error {
+		return client(failingClient).DB(ctx, "foo").Err()
+	}})
+	tests.Add("Authenticate", tt{call: func() error {
+		return client(&mock.Authenticator{
+			AuthenticateFunc: func(context.Context, interface{}) error {
+				return failed
+			},
+		}).Authenticate(ctx, nil)
+	}})
+	tests.Add("DBsStats", tt{call: func() error {
+		_, err := client(&mock.DBsStatser{
+			DBsStatsFunc: func(context.Context, []string) ([]*driver.DBStats, error) {
+				return nil, failed
+			},
+		}).DBsStats(ctx, []string{"foo"})
+		return err
+	}})
+	tests.Add("Ping", tt{call: func() error {
+		_, err := client(&mock.Pinger{
+			PingFunc: func(context.Context) (bool, error) {
+				return false, failed
+			},
+		}).Ping(ctx)
+		return err
+	}})
+	tests.Add("Client.Close", tt{op: "Close", call: func() error {
+		return client(&mock.ClientCloser{
+			CloseFunc: func(context.Context) error {
+				return failed
+			},
+		}).Close(ctx)
+	}})
+	tests.Add("ClusterStatus", tt{call: func() error {
+		_, err := client(&mock.Cluster{
+			ClusterStatusFunc: func(context.Context, map[string]interface{}) (string, error) {
+				return "", failed
+			},
+		}).ClusterStatus(ctx)
+		return err
+	}})
+	tests.Add("ClusterSetup", tt{call: func() error {
+		return client(&mock.Cluster{
+			ClusterSetupFunc: func(context.Context, interface{}) error {
+				return failed
+			},
+		}).ClusterSetup(ctx, nil)
+	}})
+	tests.Add("Membership", tt{call: func() error {
+		_, err := client(&mock.Cluster{
+			MembershipFunc: func(context.Context) (*driver.ClusterMembership, error) {
+				return nil, failed
+			},
+		}).Membership(ctx)
+		return err
+	}})
+	tests.Add("Config", tt{call: func() error {
+		_, err := client(&mock.Configer{
+			ConfigFunc: func(context.Context, string) (driver.Config, error) {
+				return nil, failed
+			},
+		}).Config(ctx, "local")
+		return err
+	}})
+	tests.Add("ConfigSection", tt{call: func() error {
+		_, err := client(&mock.Configer{
+			ConfigSectionFunc: func(context.Context, string, string) (driver.ConfigSection, error) {
+				return nil, failed
+			},
+		}).ConfigSection(ctx, "local", "foo")
+		return err
+	}})
+	tests.Add("ConfigValue", tt{call: func() error {
+		_, err := client(&mock.Configer{
+			ConfigValueFunc: func(context.Context, string, string, string) (string, error) {
+				return "", failed
+			},
+		}).ConfigValue(ctx, "local", "foo", "bar")
+		return err
+	}})
+	tests.Add("SetConfigValue", tt{call: func() error {
+		_, err := client(&mock.Configer{
+			SetConfigValueFunc: func(context.Context, string, string, string, string) (string, error) {
+				return "", failed
+			},
+		}).SetConfigValue(ctx, "local", "foo", "bar", "baz")
+		return err
+	}})
+	tests.Add("DeleteConfigKey", tt{call: func() error {
+		_, err := client(&mock.Configer{
+			DeleteConfigKeyFunc: func(context.Context, string, string, string) (string, error) {
+				return "", failed
+			},
+		}).DeleteConfigKey(ctx, "local", "foo", "bar")
+		return err
+	}})
+	tests.Add("NodeStats", tt{call: func() error {
+		_, err := client(&mock.NodeStatser{
+			NodeStatsFunc: func(context.Context, string, ...string) (json.RawMessage, error) {
+				return nil, failed
+			},
+		}).NodeStats(ctx, "local")
+		return err
+	}})
+	tests.Add("GetReplications", tt{call: func() error {
+		_, err := client(&mock.ClientReplicator{
+			GetReplicationsFunc: func(context.Context, map[string]interface{}) ([]driver.Replication, error) {
+				return nil, failed
+			},
+		}).GetReplications(ctx)
+		return err
+	}})
+	tests.Add("Replicate", tt{call: func() error {
+		_, err := client(&mock.ClientReplicator{
+			ReplicateFunc: func(context.Context, string, string, map[string]interface{}) (driver.Replication, error) {
+				return nil, failed
+			},
+		}).Replicate(ctx, "target", "source")
+		return err
+	}})
+	tests.Add("SchedulerJobs", tt{call: func() error {
+		_, err := client(&mock.Scheduler{
+			SchedulerJobsFunc: func(context.Context, map[string]interface{}) ([]*driver.SchedulerJob, error) {
+				return nil, failed
+			},
+		}).SchedulerJobs(ctx)
+		return err
+	}})
+	tests.Add("SchedulerDocs", tt{call: func() error {
+		_, err := client(&mock.Scheduler{
+			SchedulerDocsFunc: func(context.Context, map[string]interface{}) ([]*driver.SchedulerDoc, error) {
+				return nil, failed
+			},
+		}).SchedulerDocs(ctx)
+		return err
+	}})
+	tests.Add("SchedulerDoc", tt{call: func() error {
+		_, err := client(&mock.Scheduler{
+			SchedulerDocFunc: func(context.Context, string, string) (*driver.SchedulerDoc, error) {
+				return nil, failed
+			},
+		}).SchedulerDoc(ctx, "_replicator", "foo")
+		return err
+	}})
+	tests.Add("Session", tt{call: func() error {
+		_, err := client(&mock.Sessioner{
+			SessionFunc: func(context.Context) (*driver.Session, error) {
+				return nil, failed
+			},
+		}).Session(ctx)
+		return err
+	}})
+	tests.Add("Client.Stats", tt{op: "Stats", call: func() error {
+		_, err := client(&mock.ClientStatser{
+			StatsFunc: func(context.Context) (*driver.ClientStats, error) {
+				return nil, failed
+			},
+		}).Stats(ctx)
+		return err
+	}})
+	tests.Add("DBUpdates", tt{call: func() error {
+		_, err := client(&mock.DBUpdater{
+			DBUpdatesFunc: func(context.Context) (driver.DBUpdates, error) {
+				return nil, failed
+			},
+		}).DBUpdates(ctx)
+		return err
+	}})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		err := tt.call()
+		var kerr *Error
+		if !xerrors.As(err, &kerr) {
+			t.Fatalf("Expected an *Error, got %T: %v", err, err)
+		}
+		op := tt.op
+		if op == "" {
+			op = strings.TrimPrefix(t.Name(), "TestOpErrorMethods/")
+		}
+		if kerr.Op != op {
+			t.Errorf("Unexpected Op: %q, expected %q", kerr.Op, op)
+		}
+		if kerr.HTTPStatus != http.StatusBadGateway || kerr.Reason != "failed" {
+			t.Errorf("Unexpected error: %+v", kerr)
+		}
+	})
+}
+
+func TestErrorPredicates(t *testing.T) {
+	predicates := map[string]func(error) bool{
+		"IsBadRequest":         IsBadRequest,
+		"IsUnauthorized":       IsUnauthorized,
+		"IsForbidden":          IsForbidden,
+		"IsNotFound":           IsNotFound,
+		"IsConflict":           IsConflict,
+		"IsPreconditionFailed": IsPreconditionFailed,
+	}
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "plain error",
+			err:  errors.New("oops"),
+		},
+		{
+			name:     "not found",
+			err:      &Error{HTTPStatus: http.StatusNotFound},
+			expected: "IsNotFound",
+		},
+		{
+			name:     "wrapped conflict",
+			err:      opError("Put", kerrs.Status(http.StatusConflict, "conflict")),
+			expected: "IsConflict",
+		},
+		{
+			name:     "precondition failed",
+			err:      pkgerrs.Wrap(&Error{HTTPStatus: http.StatusPreconditionFailed}, "create"),
+			expected: "IsPreconditionFailed",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for name, fn := range predicates {
+				if got := fn(test.err); got != (name == test.expected) {
+					t.Errorf("%s returned %t", name, got)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorAs(t *testing.T) {
+	db := &DB{driverDB: &mock.DB{
+		PutFunc: func(context.Context, string, interface{}, map[string]interface{}) (string, error) {
+			return "", kerrs.Status(http.StatusConflict, "Document update conflict.")
+		},
+	}}
+	_, err := db.Put(context.Background(), "foo", map[string]string{})
+	var kerr *Error
+	if !xerrors.As(err, &kerr) {
+		t.Fatalf("error is not an *Error: %T", err)
+	}
+	if kerr.Op != "Put" || kerr.CouchError != "conflict" || kerr.Reason != "Document update conflict." {
+		t.Errorf("Unexpected error fields: %q %q %q", kerr.Op, kerr.CouchError, kerr.Reason)
+	}
+}
@@ -0,0 +1,166 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
+)
+
+const optionFilter = "kivik.filter"
+
+// OptionCheckFilter, when true, causes Changes and CatchUpChanges to check
+// that the filter requested with Filter exists, before opening the feed. See
+// CheckFilter. It is interpreted by Kivik, and not passed to the driver.
+const OptionCheckFilter = "kivik.check_filter"
+
+type filterSpec struct {
+	ddoc, name string
+	params     map[string]interface{}
+}
+
+// Filter returns an option which filters the changes feed, or a replication,
+// with the filter function name in the design document ddoc. params are
+// passed to the filter as query parameters, and may be nil.
+//
+// Kivik encodes params as the endpoint expects: for the changes feed, they
+// are added to the request's query parameters, and for Replicate, they are
+// given as the replication's query_params. String values are passed as is,
+// and other values as JSON, so that, for example, 3 is received by the
+// filter as "3", and a slice as a JSON array.
+func Filter(ddoc, name string, params map[string]interface{}) Options {
+	return Options{optionFilter: &filterSpec{
+		ddoc:   strings.TrimPrefix(ddoc, "_design/"),
+		name:   name,
+		params: params,
+	}}
+}
+
+// encodedParams returns the filter's params as strings.
+func (f *filterSpec) encodedParams() (map[string]string, error) {
+	encoded := make(map[string]string, len(f.params))
+	for k, v := range f.params {
+		if s, ok := v.(string); ok {
+			encoded[k] = s
+			continue
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid filter parameter %q", k), Err: err}
+		}
+		encoded[k] = string(data)
+	}
+	return encoded, nil
+}
+
+// filterOption removes the Filter option from opts, and returns it.
+func filterOption(opts Options) (*filterSpec, error) {
+	v, ok := opts[optionFilter]
+	if !ok {
+		return nil, nil
+	}
+	delete(opts, optionFilter)
+	f, ok := v.(*filterSpec)
+	if !ok {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid value for %s: %v", optionFilter, v)}
+	}
+	if f.ddoc == "" {
+		return nil, missingArg("filter ddoc")
+	}
+	if f.name == "" {
+		return nil, missingArg("filter name")
+	}
+	if _, ok := opts["filter"]; ok {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: Filter cannot be combined with a filter option"}
+	}
+	return f, nil
+}
+
+// changesFilter expands the Filter option in opts, if any, into changes feed
+// parameters, and checks that the filter exists if OptionCheckFilter is set.
+func (db *DB) changesFilter(ctx context.Context, opts Options) error {
+	check, err := queryopts.Bool(opts, OptionCheckFilter)
+	if err != nil {
+		return err
+	}
+	delete(opts, OptionCheckFilter)
+	f, err := filterOption(opts)
+	if err != nil || f == nil {
+		return err
+	}
+	params, err := f.encodedParams()
+	if err != nil {
+		return err
+	}
+	for k, v := range params {
+		if _, ok := opts[k]; ok || k == "filter" {
+			return &Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("kivik: filter parameter %q conflicts with changes option", k)}
+		}
+		opts[k] = v
+	}
+	opts["filter"] = f.ddoc + "/" + f.name
+	if check {
+		return db.CheckFilter(ctx, f.ddoc, f.name)
+	}
+	return nil
+}
+
+// replicationFilter expands the Filter option in opts, if any, into
+// replication parameters.
+func replicationFilter(opts Options) error {
+	f, err := filterOption(opts)
+	if err != nil || f == nil {
+		return err
+	}
+	if _, ok := opts["query_params"]; ok {
+		return &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: Filter cannot be combined with a query_params option"}
+	}
+	params, err := f.encodedParams()
+	if err != nil {
+		return err
+	}
+	opts["filter"] = f.ddoc + "/" + f.name
+	if len(params) > 0 {
+		opts["query_params"] = params
+	}
+	return nil
+}
+
+// CheckFilter returns a status 404 error if the design document ddoc does not
+// exist, or does not define the filter function name. It may be used to check
+// a filter before passing it to Changes, or to Replicate, on the source
+// database.
+func (db *DB) CheckFilter(ctx context.Context, ddoc, name string) error {
+	if ddoc == "" {
+		return missingArg("ddoc")
+	}
+	if name == "" {
+		return missingArg("name")
+	}
+	ddocID := "_design/" + strings.TrimPrefix(ddoc, "_design/")
+	var doc struct {
+		Filters map[string]json.RawMessage `json:"filters"`
+	}
+	if err := db.Get(ctx, ddocID).ScanDoc(&doc); err != nil {
+		return err
+	}
+	if _, ok := doc.Filters[name]; !ok {
+		return &Error{HTTPStatus: http.StatusNotFound, Message: fmt.Sprintf("kivik: filter %q not found in %s", name, ddocID)}
+	}
+	return nil
+}
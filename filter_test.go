@@ -0,0 +1,198 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// filterDB returns a DB whose only document is the design document "app",
+// with body ddoc, and whose changes feed reports the options it receives as
+// an error, so that they can be compared.
+func filterDB(ddoc string) *DB {
+	return &DB{driverDB: &mock.DB{
+		GetFunc: func(_ context.Context, docID string, _ map[string]interface{}) (*driver.Document, error) {
+			if docID != "_design/app" {
+				return nil, &Error{HTTPStatus: http.StatusNotFound, Message: "missing"}
+			}
+			return &driver.Document{Body: ioutil.NopCloser(strings.NewReader(ddoc))}, nil
+		},
+		ChangesFunc: func(_ context.Context, opts map[string]interface{}) (driver.Changes, error) {
+			return nil, &Error{HTTPStatus: http.StatusTeapot, Message: fmt.Sprintf("%v", opts)}
+		},
+	}}
+}
+
+const appDDoc = `{"_id":"_design/app","filters":{"by_type":"function(doc, req) { return doc.type === req.query.type; }"}}`
+
+func TestChangesFilter(t *testing.T) {
+	type tt struct {
+		db      *DB
+		options Options
+		status  int
+		err     string
+	}
+	tests := testy.NewTable()
+	tests.Add("params", tt{
+		db:      filterDB(appDDoc),
+		options: Filter("_design/app", "by_type", map[string]interface{}{"type": "user", "limit": 3, "tags": []string{"a"}}),
+		status:  http.StatusTeapot,
+		err:     `map[filter:app/by_type limit:3 tags:["a"] type:user]`,
+	})
+	tests.Add("no params", tt{
+		db:      filterDB(appDDoc),
+		options: Filter("app", "by_type", nil),
+		status:  http.StatusTeapot,
+		err:     `map[filter:app/by_type]`,
+	})
+	tests.Add("checked", tt{
+		db:      filterDB(appDDoc),
+		options: mergeOptions(Filter("app", "by_type", nil), Options{OptionCheckFilter: true}),
+		status:  http.StatusTeapot,
+		err:     `map[filter:app/by_type]`,
+	})
+	tests.Add("check missing filter", tt{
+		db:      filterDB(appDDoc),
+		options: mergeOptions(Filter("app", "by_color", nil), Options{OptionCheckFilter: true}),
+		status:  http.StatusNotFound,
+		err:     `kivik: filter "by_color" not found in _design/app`,
+	})
+	tests.Add("check missing ddoc", tt{
+		db:      filterDB(appDDoc),
+		options: mergeOptions(Filter("other", "by_type", nil), Options{OptionCheckFilter: true}),
+		status:  http.StatusNotFound,
+		err:     "missing",
+	})
+	tests.Add("unchecked missing filter", tt{
+		db:      filterDB(appDDoc),
+		options: Filter("app", "by_color", nil),
+		status:  http.StatusTeapot,
+		err:     `map[filter:app/by_color]`,
+	})
+	tests.Add("conflicting param", tt{
+		db:      filterDB(appDDoc),
+		options: mergeOptions(Filter("app", "by_type", map[string]interface{}{"since": "now"}), Options{"since": "0"}),
+		status:  http.StatusBadRequest,
+		err:     `kivik: filter parameter "since" conflicts with changes option`,
+	})
+	tests.Add("conflicting filter", tt{
+		db:      filterDB(appDDoc),
+		options: mergeOptions(Filter("app", "by_type", nil), ChangesDocIDs("a")),
+		status:  http.StatusBadRequest,
+		err:     "kivik: Filter cannot be combined with a filter option",
+	})
+	tests.Add("no name", tt{
+		db:      filterDB(appDDoc),
+		options: Filter("app", "", nil),
+		status:  http.StatusBadRequest,
+		err:     "kivik: filter name required",
+	})
+	tests.Add("unencodable param", tt{
+		db:      filterDB(appDDoc),
+		options: Filter("app", "by_type", map[string]interface{}{"ch": make(chan int)}),
+		status:  http.StatusBadRequest,
+		err:     `kivik: invalid filter parameter "ch": json: unsupported type: chan int`,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		_, err := tt.db.Changes(context.Background(), tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
+
+func TestReplicateFilter(t *testing.T) {
+	type tt struct {
+		options  Options
+		expected map[string]interface{}
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("params", tt{
+		options: Filter("app", "by_type", map[string]interface{}{"type": "user", "n": 3}),
+		expected: map[string]interface{}{
+			"filter":       "app/by_type",
+			"query_params": map[string]string{"type": "user", "n": "3"},
+		},
+	})
+	tests.Add("no params", tt{
+		options:  Filter("app", "by_type", nil),
+		expected: map[string]interface{}{"filter": "app/by_type"},
+	})
+	tests.Add("conflicting query_params", tt{
+		options: mergeOptions(Filter("app", "by_type", nil), Options{"query_params": map[string]string{}}),
+		status:  http.StatusBadRequest,
+		err:     "kivik: Filter cannot be combined with a query_params option",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		client := &Client{driverClient: &mock.ClientReplicator{
+			ReplicateFunc: func(_ context.Context, _, _ string, opts map[string]interface{}) (driver.Replication, error) {
+				if d := testy.DiffInterface(tt.expected, opts); d != nil {
+					return nil, fmt.Errorf("Unexpected options:\n%s", d)
+				}
+				return &mock.Replication{}, nil
+			},
+		}}
+		_, err := client.Replicate(context.Background(), "target", "source", tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
+
+func TestCheckFilter(t *testing.T) {
+	type tt struct {
+		db         *DB
+		ddoc, name string
+		status     int
+		err        string
+	}
+	tests := testy.NewTable()
+	tests.Add("exists", tt{
+		db:   filterDB(appDDoc),
+		ddoc: "app",
+		name: "by_type",
+	})
+	tests.Add("prefixed ddoc", tt{
+		db:   filterDB(appDDoc),
+		ddoc: "_design/app",
+		name: "by_type",
+	})
+	tests.Add("no filters", tt{
+		db:     filterDB(`{"_id":"_design/app"}`),
+		ddoc:   "app",
+		name:   "by_type",
+		status: http.StatusNotFound,
+		err:    `kivik: filter "by_type" not found in _design/app`,
+	})
+	tests.Add("no ddoc", tt{
+		db:     filterDB(appDDoc),
+		name:   "by_type",
+		status: http.StatusBadRequest,
+		err:    "kivik: ddoc required",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		err := tt.db.CheckFilter(context.Background(), tt.ddoc, tt.name)
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
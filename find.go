@@ -25,22 +25,30 @@ var findNotImplemented = &Error{HTTPStatus: http.StatusNotImplemented, Message:
 // JSON-marshalable to a valid query.
 // See http://docs.couchdb.org/en/2.0.0/api/database/find.html#db-find
 func (db *DB) Find(ctx context.Context, query interface{}, options ...Options) (*Rows, error) {
-	if finder, ok := db.driverDB.(driver.OptsFinder); ok {
-		rowsi, err := finder.Find(ctx, query, mergeOptions(options...))
-		if err != nil {
-			return nil, err
-		}
-		return newRows(ctx, rowsi), nil
+	opts := db.mergeOptions(options...)
+	useNumber, err := useNumberOption(opts)
+	if err != nil {
+		return nil, err
 	}
-	// nolint:staticcheck
-	if finder, ok := db.driverDB.(driver.Finder); ok {
-		rowsi, err := finder.Find(ctx, query)
-		if err != nil {
-			return nil, err
-		}
-		return newRows(ctx, rowsi), nil
+	var rowsi driver.Rows
+	ctx, op := db.startOp(ctx, "Find", "_find")
+	switch finder := db.driverDB.(type) {
+	case driver.OptsFinder:
+		rowsi, err = finder.Find(ctx, query, driverOptions(opts))
+	case driver.Finder: // nolint:staticcheck
+		rowsi, err = finder.Find(ctx, query)
+	default:
+		op.done()
+		return nil, findNotImplemented
 	}
-	return nil, findNotImplemented
+	if err != nil {
+		op.done()
+		return nil, opError("Find", err)
+	}
+	rows := newRowsOpts(ctx, rowsi, useNumber)
+	db.measure(rows.iter, "Find", "_find")
+	op.doneOnClose(rows.iter)
+	return rows, nil
 }
 
 // CreateIndex creates an index if it doesn't already exist. ddoc and name may
@@ -49,11 +57,11 @@ func (db *DB) Find(ctx context.Context, query interface{}, options ...Options) (
 // http://docs.couchdb.org/en/stable/api/database/find.html#db-index
 func (db *DB) CreateIndex(ctx context.Context, ddoc, name string, index interface{}, options ...Options) error {
 	if finder, ok := db.driverDB.(driver.OptsFinder); ok {
-		return finder.CreateIndex(ctx, ddoc, name, index, mergeOptions(options...))
+		return opError("CreateIndex", finder.CreateIndex(ctx, ddoc, name, index, driverOptions(db.mergeOptions(options...))))
 	}
 	// nolint:staticcheck
 	if finder, ok := db.driverDB.(driver.Finder); ok {
-		return finder.CreateIndex(ctx, ddoc, name, index)
+		return opError("CreateIndex", finder.CreateIndex(ctx, ddoc, name, index))
 	}
 	return findNotImplemented
 }
@@ -61,11 +69,11 @@ func (db *DB) CreateIndex(ctx context.Context, ddoc, name string, index interfac
 // DeleteIndex deletes the requested index.
 func (db *DB) DeleteIndex(ctx context.Context, ddoc, name string, options ...Options) error {
 	if finder, ok := db.driverDB.(driver.OptsFinder); ok {
-		return finder.DeleteIndex(ctx, ddoc, name, mergeOptions(options...))
+		return opError("DeleteIndex", finder.DeleteIndex(ctx, ddoc, name, driverOptions(db.mergeOptions(options...))))
 	}
 	// nolint:staticcheck
 	if finder, ok := db.driverDB.(driver.Finder); ok {
-		return finder.DeleteIndex(ctx, ddoc, name)
+		return opError("DeleteIndex", finder.DeleteIndex(ctx, ddoc, name))
 	}
 	return findNotImplemented
 }
@@ -81,12 +89,12 @@ type Index struct {
 // GetIndexes returns the indexes defined on the current database.
 func (db *DB) GetIndexes(ctx context.Context, options ...Options) ([]Index, error) {
 	if finder, ok := db.driverDB.(driver.OptsFinder); ok {
-		dIndexes, err := finder.GetIndexes(ctx, mergeOptions(options...))
+		dIndexes, err := finder.GetIndexes(ctx, driverOptions(db.mergeOptions(options...)))
 		indexes := make([]Index, len(dIndexes))
 		for i, index := range dIndexes {
 			indexes[i] = Index(index)
 		}
-		return indexes, err
+		return indexes, opError("GetIndexes", err)
 	}
 	// nolint:staticcheck
 	if finder, ok := db.driverDB.(driver.Finder); ok {
@@ -95,7 +103,7 @@ func (db *DB) GetIndexes(ctx context.Context, options ...Options) ([]Index, erro
 		for i, index := range dIndexes {
 			indexes[i] = Index(index)
 		}
-		return indexes, err
+		return indexes, opError("GetIndexes", err)
 	}
 	return nil, findNotImplemented
 }
@@ -120,9 +128,9 @@ type QueryPlan struct {
 // arguments as Find.
 func (db *DB) Explain(ctx context.Context, query interface{}, options ...Options) (*QueryPlan, error) {
 	if explainer, ok := db.driverDB.(driver.OptsFinder); ok {
-		plan, err := explainer.Explain(ctx, query, mergeOptions(options...))
+		plan, err := explainer.Explain(ctx, query, driverOptions(db.mergeOptions(options...)))
 		if err != nil {
-			return nil, err
+			return nil, opError("Explain", err)
 		}
 		qp := QueryPlan(*plan)
 		return &qp, nil
@@ -131,7 +139,7 @@ func (db *DB) Explain(ctx context.Context, query interface{}, options ...Options
 	if explainer, ok := db.driverDB.(driver.Finder); ok {
 		plan, err := explainer.Explain(ctx, query)
 		if err != nil {
-			return nil, err
+			return nil, opError("Explain", err)
 		}
 		qp := QueryPlan(*plan)
 		return &qp, nil
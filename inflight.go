@@ -0,0 +1,170 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Operation is a call in progress on a Client, or on one of its DBs, as
+// listed by InFlight.
+type Operation struct {
+	// Name is the name of the method, such as "Get" or "Query".
+	Name string
+	// Endpoint is the path of the resource addressed, such as "/db/docid".
+	Endpoint string
+	// Started is when the call began.
+	Started time.Time
+	// Priority is the priority given to the call's context by WithPriority.
+	Priority Priority
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Elapsed returns the time since the operation started.
+func (o *Operation) Elapsed() time.Duration {
+	return time.Since(o.Started)
+}
+
+// Context returns the operation's context, for example to read request-scoped
+// values identifying the caller.
+func (o *Operation) Context() context.Context {
+	return o.ctx
+}
+
+// Cancel cancels the operation's context. The call in progress fails with
+// context.Canceled, as if the caller's own context had been cancelled.
+func (o *Operation) Cancel() {
+	o.cancel()
+}
+
+type inFlight struct {
+	mu  sync.Mutex
+	ops map[*Operation]struct{}
+}
+
+// InFlight returns the operations currently in progress, oldest first. This
+// allows, for example, an admin endpoint of a long-running service to report
+// slow calls, and cancel them to shed load.
+//
+// Tracked operations are AllDBs, DBExists, CreateDB and DestroyDB, and the
+// Get, CreateDoc, Put, Delete, BulkDocs, BulkGet, Purge, PutAttachment,
+// GetAttachment, GetAttachmentMeta, DeleteAttachment, AllDocs, DesignDocs,
+// LocalDocs, Query, QueryMulti, Find, RevsDiff, Changes and CatchUpChanges
+// methods of DB. A Get remains in progress until the document body is closed,
+// as by Row.ScanDoc, a GetAttachment until the attachment content is closed,
+// and the methods which return an iterator until it is closed.
+func (c *Client) InFlight() []*Operation {
+	c.inflight.mu.Lock()
+	ops := make([]*Operation, 0, len(c.inflight.ops))
+	for op := range c.inflight.ops {
+		ops = append(ops, op)
+	}
+	c.inflight.mu.Unlock()
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].Started.Before(ops[j].Started)
+	})
+	return ops
+}
+
+// opHandle tracks an operation until done is called. A nil opHandle tracks
+// nothing.
+type opHandle struct {
+	client *Client
+	op     *Operation
+	once   sync.Once
+}
+
+// startOp registers an operation, returning the context it should use.
+func (c *Client) startOp(ctx context.Context, name, endpoint string) (context.Context, *opHandle) {
+	if c == nil {
+		return ctx, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	op := &Operation{
+		Name:     name,
+		Endpoint: endpoint,
+		Started:  time.Now(),
+		Priority: PriorityFromContext(ctx),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	c.inflight.mu.Lock()
+	if c.inflight.ops == nil {
+		c.inflight.ops = make(map[*Operation]struct{})
+	}
+	c.inflight.ops[op] = struct{}{}
+	c.inflight.mu.Unlock()
+	return ctx, &opHandle{client: c, op: op}
+}
+
+// startOp registers an operation on path, relative to the database.
+func (db *DB) startOp(ctx context.Context, name, path string) (context.Context, *opHandle) {
+	return db.client.startOp(ctx, name, db.endpoint(path))
+}
+
+// endpoint returns the endpoint of path, relative to the database.
+func (db *DB) endpoint(path string) string {
+	if path == "" {
+		return "/" + db.name
+	}
+	return "/" + db.name + "/" + path
+}
+
+// done deregisters the operation and cancels its context.
+func (h *opHandle) done() {
+	if h == nil {
+		return
+	}
+	h.once.Do(func() {
+		h.client.inflight.mu.Lock()
+		delete(h.client.inflight.ops, h.op)
+		h.client.inflight.mu.Unlock()
+		h.op.cancel()
+	})
+}
+
+// doneOnClose arranges for the operation to end when the iterator is closed.
+func (h *opHandle) doneOnClose(i *iter) {
+	if h == nil {
+		return
+	}
+	cancel := i.cancel
+	i.cancel = func() {
+		cancel()
+		h.done()
+	}
+}
+
+// body wraps r, so that the operation ends when it is closed.
+func (h *opHandle) body(r io.ReadCloser) io.ReadCloser {
+	if h == nil || r == nil {
+		return r
+	}
+	return &opBody{ReadCloser: r, h: h}
+}
+
+type opBody struct {
+	io.ReadCloser
+	h *opHandle
+}
+
+func (b *opBody) Close() error {
+	defer b.h.done()
+	return b.ReadCloser.Close()
+}
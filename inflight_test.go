@@ -0,0 +1,179 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+type opSummary struct {
+	Name, Endpoint string
+}
+
+func summarizeOps(c *Client) []opSummary {
+	var ops []opSummary
+	for _, op := range c.InFlight() {
+		ops = append(ops, opSummary{Name: op.Name, Endpoint: op.Endpoint})
+	}
+	return ops
+}
+
+func TestInFlight(t *testing.T) {
+	started := make(chan struct{})
+	driverDB := &mock.DB{
+		PutFunc: func(ctx context.Context, _ string, _ interface{}, _ map[string]interface{}) (string, error) {
+			close(started)
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+		GetFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+			return &driver.Document{Body: ioutil.NopCloser(strings.NewReader(`{}`))}, nil
+		},
+		QueryFunc: func(context.Context, string, string, map[string]interface{}) (driver.Rows, error) {
+			return &mock.Rows{
+				NextFunc:  func(*driver.Row) error { return io.EOF },
+				CloseFunc: func() error { return nil },
+			}, nil
+		},
+		GetAttachmentFunc: func(context.Context, string, string, map[string]interface{}) (*driver.Attachment, error) {
+			return &driver.Attachment{Content: ioutil.NopCloser(strings.NewReader("x"))}, nil
+		},
+		ChangesFunc: func(context.Context, map[string]interface{}) (driver.Changes, error) {
+			return &mock.Changes{
+				NextFunc:  func(*driver.Change) error { return io.EOF },
+				CloseFunc: func() error { return nil },
+			}, nil
+		},
+	}
+	client := &Client{driverClient: &mock.Client{
+		DBFunc: func(context.Context, string, map[string]interface{}) (driver.DB, error) {
+			return driverDB, nil
+		},
+	}}
+	db := client.DB(context.Background(), "db")
+
+	t.Run("cancel", func(t *testing.T) {
+		errs := make(chan error)
+		go func() {
+			_, err := db.Put(context.Background(), "doc", map[string]string{})
+			errs <- err
+		}()
+		<-started
+		ops := client.InFlight()
+		if d := testy.DiffInterface([]opSummary{{Name: "Put", Endpoint: "/db/doc"}}, summarizeOps(client)); d != nil {
+			t.Fatal(d)
+		}
+		if ops[0].Elapsed() <= 0 || ops[0].Context() == nil {
+			t.Errorf("Unexpected operation: %+v", ops[0])
+		}
+		ops[0].Cancel()
+		select {
+		case err := <-errs:
+			if !strings.Contains(err.Error(), context.Canceled.Error()) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Put not cancelled")
+		}
+		if ops := summarizeOps(client); ops != nil {
+			t.Errorf("Operations remain: %v", ops)
+		}
+	})
+	t.Run("get", func(t *testing.T) {
+		row := db.Get(context.Background(), "doc")
+		if d := testy.DiffInterface([]opSummary{{Name: "Get", Endpoint: "/db/doc"}}, summarizeOps(client)); d != nil {
+			t.Error(d)
+		}
+		var doc map[string]interface{}
+		if err := row.ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if ops := summarizeOps(client); ops != nil {
+			t.Errorf("Operations remain: %v", ops)
+		}
+	})
+	t.Run("query", func(t *testing.T) {
+		rows, err := db.Query(context.Background(), "_design/ddoc", "view")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]opSummary{{Name: "Query", Endpoint: "/db/_design/ddoc/_view/view"}}, summarizeOps(client)); d != nil {
+			t.Error(d)
+		}
+		for rows.Next() {
+		}
+		if ops := summarizeOps(client); ops != nil {
+			t.Errorf("Operations remain: %v", ops)
+		}
+	})
+	t.Run("get attachment", func(t *testing.T) {
+		att, err := db.GetAttachment(context.Background(), "doc", "foo.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]opSummary{{Name: "GetAttachment", Endpoint: "/db/doc/foo.txt"}}, summarizeOps(client)); d != nil {
+			t.Error(d)
+		}
+		if err := att.Content.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if ops := summarizeOps(client); ops != nil {
+			t.Errorf("Operations remain: %v", ops)
+		}
+	})
+	t.Run("catch up changes", func(t *testing.T) {
+		changes, err := db.CatchUpChanges(context.Background(), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]opSummary{{Name: "CatchUpChanges", Endpoint: "/db/_changes"}}, summarizeOps(client)); d != nil {
+			t.Error(d)
+		}
+		if err := changes.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if ops := summarizeOps(client); ops != nil {
+			t.Errorf("Operations remain: %v", ops)
+		}
+	})
+	t.Run("query error", func(t *testing.T) {
+		_, err := db.Query(context.Background(), "ddoc", "view", Options{"group": true, "reduce": false})
+		if err == nil {
+			t.Fatal("Expected an error")
+		}
+		if ops := summarizeOps(client); ops != nil {
+			t.Errorf("Operations remain: %v", ops)
+		}
+	})
+}
+
+func TestInFlightUntracked(t *testing.T) {
+	db := &DB{driverDB: &mock.DB{
+		PutFunc: func(context.Context, string, interface{}, map[string]interface{}) (string, error) {
+			return "1-abc", nil
+		},
+	}}
+	if _, err := db.Put(context.Background(), "doc", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,206 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package collate implements CouchDB view collation for JSON values, for use
+// by the embedded drivers.
+//
+// See https://docs.couchdb.org/en/stable/ddocs/views/collation.html
+package collate
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The relative order of JSON types, as defined by CouchDB.
+const (
+	rankNull = iota
+	rankFalse
+	rankTrue
+	rankNumber
+	rankString
+	rankArray
+	rankObject
+)
+
+// Normalize converts an arbitrary Go value to its generic JSON representation,
+// consisting only of nil, bool, json.Number, string, []interface{} and
+// map[string]interface{} values. The input value is never modified. Values
+// which cannot be marshaled to JSON are returned as nil.
+func Normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil, bool, json.Number, string:
+		return v
+	case float64:
+		return json.Number(strconv.FormatFloat(t, 'f', -1, 64))
+	case int:
+		return json.Number(strconv.Itoa(t))
+	case int64:
+		return json.Number(strconv.FormatInt(t, 10))
+	case []interface{}:
+		a := make([]interface{}, len(t))
+		for i, e := range t {
+			a[i] = Normalize(e)
+		}
+		return a
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[k] = Normalize(e)
+		}
+		return m
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var x interface{}
+	if err := dec.Decode(&x); err != nil {
+		return nil
+	}
+	return x
+}
+
+func rank(v interface{}) int {
+	switch t := v.(type) {
+	case nil:
+		return rankNull
+	case bool:
+		if t {
+			return rankTrue
+		}
+		return rankFalse
+	case json.Number, float64, int, int64:
+		return rankNumber
+	case string:
+		return rankString
+	case []interface{}:
+		return rankArray
+	}
+	return rankObject
+}
+
+func toFloat(v interface{}) float64 {
+	switch t := v.(type) {
+	case json.Number:
+		f, _ := t.Float64()
+		return f
+	case float64:
+		return t
+	case int:
+		return float64(t)
+	case int64:
+		return float64(t)
+	}
+	return 0
+}
+
+// Compare compares two JSON values a and b according to CouchDB collation
+// rules, returning -1 if a sorts before b, 1 if a sorts after b, and 0 if they
+// are equal. Values which are not in generic JSON form are normalized first.
+//
+// String comparison approximates the ICU collation used by CouchDB: strings
+// are compared case-insensitively, with lower case sorting first in case of a
+// tie.
+func Compare(a, b interface{}) int {
+	a, b = Normalize(a), Normalize(b)
+	ra, rb := rank(a), rank(b)
+	if ra != rb {
+		return compareInt(ra, rb)
+	}
+	switch ra {
+	case rankNumber:
+		fa, fb := toFloat(a), toFloat(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	case rankString:
+		return CompareStrings(a.(string), b.(string))
+	case rankArray:
+		return compareArrays(a.([]interface{}), b.([]interface{}))
+	case rankObject:
+		return compareObjects(a.(map[string]interface{}), b.(map[string]interface{}))
+	}
+	return 0
+}
+
+// CompareStrings compares two strings using the same rules as Compare.
+func CompareStrings(a, b string) int {
+	if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+		return c
+	}
+	ar, br := []rune(a), []rune(b)
+	for i := 0; i < len(ar) && i < len(br); i++ {
+		if ar[i] == br[i] {
+			continue
+		}
+		if unicode.IsLower(ar[i]) {
+			return -1
+		}
+		return 1
+	}
+	return compareInt(len(ar), len(br))
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareArrays(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(a), len(b))
+}
+
+// compareObjects compares objects key by key. CouchDB compares keys in the
+// order they appear in the JSON representation, which Go maps do not
+// preserve, so keys are compared in sorted order instead.
+func compareObjects(a, b map[string]interface{}) int {
+	ak, bk := sortedKeys(a), sortedKeys(b)
+	for i := 0; i < len(ak) && i < len(bk); i++ {
+		if c := CompareStrings(ak[i], bk[i]); c != 0 {
+			return c
+		}
+		if c := Compare(a[ak[i]], b[bk[i]]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(ak), len(bk))
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return CompareStrings(keys[i], keys[j]) < 0 })
+	return keys
+}
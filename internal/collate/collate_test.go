@@ -0,0 +1,92 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package collate
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestCompare(t *testing.T) {
+	// Taken from the CouchDB collation specification, in ascending order.
+	ordered := []interface{}{
+		nil,
+		false,
+		true,
+		1,
+		2,
+		3.0,
+		4,
+		"a",
+		"A",
+		"aa",
+		"b",
+		"B",
+		"ba",
+		"bb",
+		[]interface{}{"a"},
+		[]interface{}{"b"},
+		[]interface{}{"b", "c"},
+		[]interface{}{"b", "c", "a"},
+		[]interface{}{"b", "d"},
+		[]interface{}{"b", "d", "e"},
+		map[string]interface{}{"a": 1},
+		map[string]interface{}{"a": 2},
+		map[string]interface{}{"b": 1},
+		map[string]interface{}{"b": 2},
+		map[string]interface{}{"b": 2, "c": 2},
+	}
+	for i := range ordered {
+		for j := range ordered {
+			want := compareInt(i, j)
+			if got := Compare(ordered[i], ordered[j]); got != want {
+				t.Errorf("Compare(%v, %v) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+}
+
+func TestCompareNormalizes(t *testing.T) {
+	type key struct {
+		A int `json:"a"`
+	}
+	if c := Compare(key{A: 1}, map[string]interface{}{"a": json.Number("1")}); c != 0 {
+		t.Errorf("Expected struct and map to be equal, got %d", c)
+	}
+	if c := Compare(json.Number("10"), 9.5); c != 1 {
+		t.Errorf("Expected 10 > 9.5, got %d", c)
+	}
+}
+
+func TestSortStrings(t *testing.T) {
+	strs := []string{"b", "B", "a", "ab", "A", "Ab"}
+	sort.Slice(strs, func(i, j int) bool { return CompareStrings(strs[i], strs[j]) < 0 })
+	expected := []string{"a", "A", "ab", "Ab", "b", "B"}
+	if d := testy.DiffInterface(expected, strs); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	input := []interface{}{1, 2.5, map[string]interface{}{"a": int64(3)}}
+	expected := []interface{}{json.Number("1"), json.Number("2.5"), map[string]interface{}{"a": json.Number("3")}}
+	if d := testy.DiffInterface(expected, Normalize(input)); d != nil {
+		t.Error(d)
+	}
+	if _, ok := input[0].(int); !ok {
+		t.Errorf("Input was modified: %v", input)
+	}
+}
@@ -14,6 +14,7 @@ package mock
 
 import (
 	"context"
+	"encoding/json"
 
 	"github.com/go-kivik/kivik/v4/driver"
 )
@@ -207,3 +208,54 @@ func (c *Configer) SetConfigValue(ctx context.Context, node, section, key, value
 func (c *Configer) DeleteConfigKey(ctx context.Context, node, section, key string) (string, error) {
 	return c.DeleteConfigKeyFunc(ctx, node, section, key)
 }
+
+// ClientStatser mocks driver.Client and driver.ClientStatser
+type ClientStatser struct {
+	*Client
+	StatsFunc func(context.Context) (*driver.ClientStats, error)
+}
+
+var _ driver.ClientStatser = &ClientStatser{}
+
+// Stats calls c.StatsFunc
+func (c *ClientStatser) Stats(ctx context.Context) (*driver.ClientStats, error) {
+	return c.StatsFunc(ctx)
+}
+
+// NodeStatser mocks driver.Client and driver.NodeStatser
+type NodeStatser struct {
+	*Client
+	NodeStatsFunc func(context.Context, string, ...string) (json.RawMessage, error)
+}
+
+var _ driver.NodeStatser = &NodeStatser{}
+
+// NodeStats calls c.NodeStatsFunc
+func (c *NodeStatser) NodeStats(ctx context.Context, node string, path ...string) (json.RawMessage, error) {
+	return c.NodeStatsFunc(ctx, node, path...)
+}
+
+// Scheduler mocks driver.Client and driver.Scheduler
+type Scheduler struct {
+	*Client
+	SchedulerJobsFunc func(context.Context, map[string]interface{}) ([]*driver.SchedulerJob, error)
+	SchedulerDocsFunc func(context.Context, map[string]interface{}) ([]*driver.SchedulerDoc, error)
+	SchedulerDocFunc  func(context.Context, string, string) (*driver.SchedulerDoc, error)
+}
+
+var _ driver.Scheduler = &Scheduler{}
+
+// SchedulerJobs calls c.SchedulerJobsFunc
+func (c *Scheduler) SchedulerJobs(ctx context.Context, options map[string]interface{}) ([]*driver.SchedulerJob, error) {
+	return c.SchedulerJobsFunc(ctx, options)
+}
+
+// SchedulerDocs calls c.SchedulerDocsFunc
+func (c *Scheduler) SchedulerDocs(ctx context.Context, options map[string]interface{}) ([]*driver.SchedulerDoc, error) {
+	return c.SchedulerDocsFunc(ctx, options)
+}
+
+// SchedulerDoc calls c.SchedulerDocFunc
+func (c *Scheduler) SchedulerDoc(ctx context.Context, replicatorDB, docID string) (*driver.SchedulerDoc, error) {
+	return c.SchedulerDocFunc(ctx, replicatorDB, docID)
+}
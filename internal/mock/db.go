@@ -262,6 +262,19 @@ func (db *DesignDocer) DesignDocs(ctx context.Context, options map[string]interf
 	return db.DesignDocsFunc(ctx, options)
 }
 
+// MultiQuerier mocks a driver.DB and driver.MultiQuerier
+type MultiQuerier struct {
+	*DB
+	QueryMultiFunc func(context.Context, string, string, []map[string]interface{}) (driver.Rows, error)
+}
+
+var _ driver.MultiQuerier = &MultiQuerier{}
+
+// QueryMulti calls db.QueryMultiFunc
+func (db *MultiQuerier) QueryMulti(ctx context.Context, ddoc, view string, queries []map[string]interface{}) (driver.Rows, error) {
+	return db.QueryMultiFunc(ctx, ddoc, view, queries)
+}
+
 // LocalDocer mocks a driver.DB and driver.DesignDocer
 type LocalDocer struct {
 	*DB
@@ -275,6 +288,31 @@ func (db *LocalDocer) LocalDocs(ctx context.Context, options map[string]interfac
 	return db.LocalDocsFunc(ctx, options)
 }
 
+// LocalDocStorer mocks a driver.DB and driver.LocalDocStorer
+type LocalDocStorer struct {
+	*DB
+	GetLocalFunc    func(context.Context, string, map[string]interface{}) (*driver.Document, error)
+	PutLocalFunc    func(context.Context, string, interface{}, map[string]interface{}) error
+	DeleteLocalFunc func(context.Context, string, map[string]interface{}) error
+}
+
+var _ driver.LocalDocStorer = &LocalDocStorer{}
+
+// GetLocal calls db.GetLocalFunc
+func (db *LocalDocStorer) GetLocal(ctx context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+	return db.GetLocalFunc(ctx, docID, options)
+}
+
+// PutLocal calls db.PutLocalFunc
+func (db *LocalDocStorer) PutLocal(ctx context.Context, docID string, doc interface{}, options map[string]interface{}) error {
+	return db.PutLocalFunc(ctx, docID, doc, options)
+}
+
+// DeleteLocal calls db.DeleteLocalFunc
+func (db *LocalDocStorer) DeleteLocal(ctx context.Context, docID string, options map[string]interface{}) error {
+	return db.DeleteLocalFunc(ctx, docID, options)
+}
+
 // Purger mocks a driver.DB and driver.Purger
 type Purger struct {
 	*DB
@@ -288,6 +326,76 @@ func (db *Purger) Purge(ctx context.Context, docMap map[string][]string) (*drive
 	return db.PurgeFunc(ctx, docMap)
 }
 
+// PurgedInfosLimiter mocks a driver.DB, driver.Purger and
+// driver.PurgedInfosLimiter
+type PurgedInfosLimiter struct {
+	*Purger
+	PurgedInfosLimitFunc    func(context.Context) (int64, error)
+	SetPurgedInfosLimitFunc func(context.Context, int64) error
+}
+
+var _ driver.PurgedInfosLimiter = &PurgedInfosLimiter{}
+
+// PurgedInfosLimit calls db.PurgedInfosLimitFunc
+func (db *PurgedInfosLimiter) PurgedInfosLimit(ctx context.Context) (int64, error) {
+	return db.PurgedInfosLimitFunc(ctx)
+}
+
+// SetPurgedInfosLimit calls db.SetPurgedInfosLimitFunc
+func (db *PurgedInfosLimiter) SetPurgedInfosLimit(ctx context.Context, limit int64) error {
+	return db.SetPurgedInfosLimitFunc(ctx, limit)
+}
+
+// QuorumWriter mocks a driver.DB and driver.QuorumWriter
+type QuorumWriter struct {
+	*DB
+	PutResultFunc              func(context.Context, string, interface{}, map[string]interface{}) (*driver.WriteResult, error)
+	CreateDocResultFunc        func(context.Context, interface{}, map[string]interface{}) (*driver.WriteResult, error)
+	DeleteResultFunc           func(context.Context, string, string, map[string]interface{}) (*driver.WriteResult, error)
+	PutAttachmentResultFunc    func(context.Context, string, string, *driver.Attachment, map[string]interface{}) (*driver.WriteResult, error)
+	DeleteAttachmentResultFunc func(context.Context, string, string, string, map[string]interface{}) (*driver.WriteResult, error)
+}
+
+var _ driver.QuorumWriter = &QuorumWriter{}
+
+// PutResult calls db.PutResultFunc
+func (db *QuorumWriter) PutResult(ctx context.Context, docID string, doc interface{}, opts map[string]interface{}) (*driver.WriteResult, error) {
+	return db.PutResultFunc(ctx, docID, doc, opts)
+}
+
+// CreateDocResult calls db.CreateDocResultFunc
+func (db *QuorumWriter) CreateDocResult(ctx context.Context, doc interface{}, opts map[string]interface{}) (*driver.WriteResult, error) {
+	return db.CreateDocResultFunc(ctx, doc, opts)
+}
+
+// DeleteResult calls db.DeleteResultFunc
+func (db *QuorumWriter) DeleteResult(ctx context.Context, docID, rev string, opts map[string]interface{}) (*driver.WriteResult, error) {
+	return db.DeleteResultFunc(ctx, docID, rev, opts)
+}
+
+// PutAttachmentResult calls db.PutAttachmentResultFunc
+func (db *QuorumWriter) PutAttachmentResult(ctx context.Context, docID, rev string, att *driver.Attachment, opts map[string]interface{}) (*driver.WriteResult, error) {
+	return db.PutAttachmentResultFunc(ctx, docID, rev, att, opts)
+}
+
+// DeleteAttachmentResult calls db.DeleteAttachmentResultFunc
+func (db *QuorumWriter) DeleteAttachmentResult(ctx context.Context, docID, rev, filename string, opts map[string]interface{}) (*driver.WriteResult, error) {
+	return db.DeleteAttachmentResultFunc(ctx, docID, rev, filename, opts)
+}
+
+// Snapshotter mocks a driver.DB and driver.Snapshotter
+type Snapshotter struct {
+	*DB
+	SnapshotFunc func(context.Context) (driver.DB, error)
+}
+
+var _ driver.Snapshotter = &Snapshotter{}
+
+// Snapshot calls db.SnapshotFunc
+func (db *Snapshotter) Snapshot(ctx context.Context) (driver.DB, error) {
+	return db.SnapshotFunc(ctx)
+}
+
 // BulkGetter mocks a driver.DB and driver.BulkGetter
 type BulkGetter struct {
 	*DB
@@ -0,0 +1,130 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package queryopts provides helpers for drivers to read CouchDB-style query
+// options, which may be given as native Go values, or as their string
+// representations.
+package queryopts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-kivik/kivik/v4/errors"
+)
+
+// Bool returns the boolean value of the named option, which may be given as a
+// bool, or as a string, as it would appear in a CouchDB query string. An unset
+// option is false.
+func Bool(opts map[string]interface{}, key string) (bool, error) {
+	switch t := opts[key].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return t, nil
+	case string:
+		v, err := strconv.ParseBool(t)
+		if err != nil {
+			return false, errors.Statusf(http.StatusBadRequest, "invalid value for %s: %q", key, t)
+		}
+		return v, nil
+	}
+	return false, errors.Statusf(http.StatusBadRequest, "invalid value for %s: %v", key, opts[key])
+}
+
+// Int returns the non-negative integer value of the named option, and true, or
+// false if the option is not set. The value may be given as any integer type,
+// float64, json.Number, or a string.
+func Int(opts map[string]interface{}, key string) (int64, bool, error) {
+	var v int64
+	switch t := opts[key].(type) {
+	case nil:
+		return 0, false, nil
+	case int:
+		v = int64(t)
+	case int64:
+		v = t
+	case float64:
+		v = int64(t)
+	case json.Number:
+		i, err := t.Int64()
+		if err != nil {
+			return 0, false, errors.Statusf(http.StatusBadRequest, "invalid value for %s: %q", key, t)
+		}
+		v = i
+	case string:
+		i, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			return 0, false, errors.Statusf(http.StatusBadRequest, "invalid value for %s: %q", key, t)
+		}
+		v = i
+	default:
+		return 0, false, errors.Statusf(http.StatusBadRequest, "invalid value for %s: %v", key, opts[key])
+	}
+	if v < 0 {
+		return 0, false, errors.Statusf(http.StatusBadRequest, "invalid value for %s: %d", key, v)
+	}
+	return v, true, nil
+}
+
+// JSON returns the value of the first of the named options which is set, and
+// true. Values in raw JSON form ([]byte or json.RawMessage) are decoded.
+func JSON(opts map[string]interface{}, keys ...string) (interface{}, bool, error) {
+	for _, key := range keys {
+		v, ok := opts[key]
+		if !ok {
+			continue
+		}
+		var raw []byte
+		switch t := v.(type) {
+		case []byte:
+			raw = t
+		case json.RawMessage:
+			raw = t
+		default:
+			return v, true, nil
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, false, errors.Statusf(http.StatusBadRequest, "invalid value for %s: %s", key, err)
+		}
+		return v, true, nil
+	}
+	return nil, false, nil
+}
+
+// Strings returns the value of the named option as a list of strings.
+func Strings(opts map[string]interface{}, key string) ([]string, error) {
+	v, ok, err := JSON(opts, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	switch t := v.(type) {
+	case []string:
+		return t, nil
+	case []interface{}:
+		strs := make([]string, len(t))
+		for i, s := range t {
+			str, ok := s.(string)
+			if !ok {
+				return nil, errors.Statusf(http.StatusBadRequest, "invalid value for %s: %v", key, v)
+			}
+			strs[i] = str
+		}
+		return strs, nil
+	}
+	return nil, errors.Statusf(http.StatusBadRequest, "invalid value for %s: %v", key, fmt.Sprint(v))
+}
@@ -0,0 +1,139 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package queryopts
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestBool(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     map[string]interface{}
+		expected bool
+		status   int
+		err      string
+	}{
+		{name: "unset"},
+		{name: "bool", opts: map[string]interface{}{"x": true}, expected: true},
+		{name: "string", opts: map[string]interface{}{"x": "true"}, expected: true},
+		{
+			name:   "invalid string",
+			opts:   map[string]interface{}{"x": "yes please"},
+			status: http.StatusBadRequest,
+			err:    `invalid value for x: "yes please"`,
+		},
+		{
+			name:   "invalid type",
+			opts:   map[string]interface{}{"x": 1},
+			status: http.StatusBadRequest,
+			err:    "invalid value for x: 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := Bool(tt.opts, "x")
+			testy.StatusError(t, tt.err, tt.status, err)
+			if v != tt.expected {
+				t.Errorf("Unexpected result: %v", v)
+			}
+		})
+	}
+}
+
+func TestInt(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     map[string]interface{}
+		expected int64
+		ok       bool
+		status   int
+		err      string
+	}{
+		{name: "unset"},
+		{name: "int", opts: map[string]interface{}{"x": 3}, expected: 3, ok: true},
+		{name: "float64", opts: map[string]interface{}{"x": 3.0}, expected: 3, ok: true},
+		{name: "json.Number", opts: map[string]interface{}{"x": json.Number("3")}, expected: 3, ok: true},
+		{name: "string", opts: map[string]interface{}{"x": "3"}, expected: 3, ok: true},
+		{
+			name:   "negative",
+			opts:   map[string]interface{}{"x": -1},
+			status: http.StatusBadRequest,
+			err:    "invalid value for x: -1",
+		},
+		{
+			name:   "invalid string",
+			opts:   map[string]interface{}{"x": "three"},
+			status: http.StatusBadRequest,
+			err:    `invalid value for x: "three"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, ok, err := Int(tt.opts, "x")
+			testy.StatusError(t, tt.err, tt.status, err)
+			if v != tt.expected || ok != tt.ok {
+				t.Errorf("Unexpected result: %v, %v", v, ok)
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	opts := map[string]interface{}{
+		"raw":    []byte(`{"a":1}`),
+		"native": "foo",
+		"bad":    json.RawMessage(`{`),
+	}
+	v, ok, err := JSON(opts, "missing", "raw")
+	if err != nil || !ok {
+		t.Fatalf("Unexpected result: %v, %v", ok, err)
+	}
+	if d := testy.DiffInterface(map[string]interface{}{"a": json.Number("1")}, v); d != nil {
+		t.Error(d)
+	}
+	if v, _, _ := JSON(opts, "native"); v != "foo" {
+		t.Errorf("Unexpected result: %v", v)
+	}
+	_, _, err = JSON(opts, "bad")
+	testy.StatusError(t, "invalid value for bad: unexpected EOF", http.StatusBadRequest, err)
+}
+
+func TestStrings(t *testing.T) {
+	opts := map[string]interface{}{
+		"native":  []string{"a"},
+		"generic": []interface{}{"a", "b"},
+		"raw":     json.RawMessage(`["c"]`),
+		"bad":     []interface{}{1},
+	}
+	for key, expected := range map[string][]string{
+		"native":  {"a"},
+		"generic": {"a", "b"},
+		"raw":     {"c"},
+		"missing": nil,
+	} {
+		v, err := Strings(opts, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(expected, v); d != nil {
+			t.Errorf("%s: %s", key, d)
+		}
+	}
+	_, err := Strings(opts, "bad")
+	testy.StatusError(t, "invalid value for bad: [1]", http.StatusBadRequest, err)
+}
@@ -15,14 +15,21 @@
 package registry
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/go-kivik/kivik/v4/driver"
 )
 
+type driverAlias struct {
+	name    string
+	options map[string]interface{}
+}
+
 var (
 	driversMu sync.RWMutex
 	drivers   = make(map[string]driver.Driver)
+	aliases   = make(map[string]driverAlias)
 )
 
 // Register makes a database driver available by the provided name. If Register
@@ -36,13 +43,99 @@ func Register(name string, driver driver.Driver) {
 	if _, dup := drivers[name]; dup {
 		panic("kivik: Register called twice for driver " + name)
 	}
+	if _, dup := aliases[name]; dup {
+		panic("kivik: Register called for existing alias " + name)
+	}
 	drivers[name] = driver
 }
 
+// RegisterAlias makes the driver registered as name available under the name
+// alias as well, with the provided default options. name may itself be an
+// alias, and the aliased driver need not be registered yet. If RegisterAlias
+// is called twice with the same alias, if alias is already used by a driver,
+// or if the alias would refer back to itself, it panics.
+func RegisterAlias(alias, name string, options map[string]interface{}) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if _, dup := aliases[alias]; dup {
+		panic("kivik: RegisterAlias called twice for alias " + alias)
+	}
+	if _, dup := drivers[alias]; dup {
+		panic("kivik: RegisterAlias called for existing driver " + alias)
+	}
+	for target := name; ; {
+		if target == alias {
+			panic("kivik: RegisterAlias called with alias loop for " + alias)
+		}
+		a, ok := aliases[target]
+		if !ok {
+			break
+		}
+		target = a.name
+	}
+	aliases[alias] = driverAlias{name: name, options: options}
+}
+
 // Driver returns the driver registered with the requested name, or nil if
-// it has not been registered.
+// it has not been registered. If name is an alias, the aliased driver is
+// returned, following aliases of aliases.
 func Driver(name string) driver.Driver {
 	driversMu.RLock()
 	defer driversMu.RUnlock()
-	return drivers[name]
+	for {
+		a, ok := aliases[name]
+		if !ok {
+			return drivers[name]
+		}
+		name = a.name
+	}
+}
+
+// Options returns the default options registered for the named alias, or nil
+// if name is not an alias or has no default options. For an alias of an
+// alias, the options of each are merged, those of the outermost alias taking
+// precedence.
+func Options(name string) map[string]interface{} {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	var chain []map[string]interface{}
+	for {
+		a, ok := aliases[name]
+		if !ok {
+			break
+		}
+		if len(a.options) > 0 {
+			chain = append(chain, a.options)
+		}
+		name = a.name
+	}
+	switch len(chain) {
+	case 0:
+		return nil
+	case 1:
+		return chain[0]
+	}
+	options := make(map[string]interface{})
+	for i := len(chain) - 1; i >= 0; i-- {
+		for k, v := range chain[i] {
+			options[k] = v
+		}
+	}
+	return options
+}
+
+// Drivers returns a sorted list of the names of all registered drivers and
+// aliases.
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	names := make([]string, 0, len(drivers)+len(aliases))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
@@ -83,3 +83,133 @@ func TestRegister(t *testing.T) {
 		}
 	})
 }
+
+func TestRegisterAlias(t *testing.T) {
+	registryMU.Lock()
+	defer registryMU.Unlock()
+	reset := func() {
+		drivers = make(map[string]driver.Driver)
+		aliases = make(map[string]driverAlias)
+	}
+	t.Run("duplicate alias", func(t *testing.T) {
+		defer reset()
+		p := func() (p interface{}) {
+			defer func() {
+				p = recover()
+			}()
+			RegisterAlias("foo", "bar", nil)
+			RegisterAlias("foo", "baz", nil)
+			return ""
+		}()
+		if p.(string) != "kivik: RegisterAlias called twice for alias foo" {
+			t.Errorf("Unexpected panic: %v", p)
+		}
+	})
+
+	t.Run("alias shadows driver", func(t *testing.T) {
+		defer reset()
+		p := func() (p interface{}) {
+			defer func() {
+				p = recover()
+			}()
+			Register("foo", &mock.Driver{})
+			RegisterAlias("foo", "bar", nil)
+			return ""
+		}()
+		if p.(string) != "kivik: RegisterAlias called for existing driver foo" {
+			t.Errorf("Unexpected panic: %v", p)
+		}
+	})
+
+	t.Run("driver shadows alias", func(t *testing.T) {
+		defer reset()
+		p := func() (p interface{}) {
+			defer func() {
+				p = recover()
+			}()
+			RegisterAlias("foo", "bar", nil)
+			Register("foo", &mock.Driver{})
+			return ""
+		}()
+		if p.(string) != "kivik: Register called for existing alias foo" {
+			t.Errorf("Unexpected panic: %v", p)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		defer reset()
+		opts := map[string]interface{}{"foo": "bar"}
+		RegisterAlias("alias", "real", opts)
+		if d := Driver("alias"); d != nil {
+			t.Errorf("Expected nil driver before registration, got %v", d)
+		}
+		drv := &mock.Driver{}
+		Register("real", drv)
+		if d := Driver("alias"); d != drv {
+			t.Errorf("Unexpected driver for alias: %v", d)
+		}
+		if d := testy.DiffInterface(opts, Options("alias")); d != nil {
+			t.Error(d)
+		}
+		if o := Options("real"); o != nil {
+			t.Errorf("Unexpected options for driver: %v", o)
+		}
+		if d := testy.DiffInterface([]string{"alias", "real"}, Drivers()); d != nil {
+			t.Error(d)
+		}
+	})
+
+	t.Run("alias loop", func(t *testing.T) {
+		defer reset()
+		p := func() (p interface{}) {
+			defer func() {
+				p = recover()
+			}()
+			RegisterAlias("a", "b", nil)
+			RegisterAlias("b", "c", nil)
+			RegisterAlias("c", "a", nil)
+			return ""
+		}()
+		if p.(string) != "kivik: RegisterAlias called with alias loop for c" {
+			t.Errorf("Unexpected panic: %v", p)
+		}
+	})
+
+	t.Run("self alias", func(t *testing.T) {
+		defer reset()
+		p := func() (p interface{}) {
+			defer func() {
+				p = recover()
+			}()
+			RegisterAlias("foo", "foo", nil)
+			return ""
+		}()
+		if p.(string) != "kivik: RegisterAlias called with alias loop for foo" {
+			t.Errorf("Unexpected panic: %v", p)
+		}
+	})
+
+	t.Run("alias of alias", func(t *testing.T) {
+		defer reset()
+		RegisterAlias("outer", "inner", map[string]interface{}{"foo": "outer", "bar": "outer"})
+		RegisterAlias("inner", "real", map[string]interface{}{"foo": "inner", "baz": "inner"})
+		if d := Driver("outer"); d != nil {
+			t.Errorf("Expected nil driver before registration, got %v", d)
+		}
+		drv := &mock.Driver{}
+		Register("real", drv)
+		if d := Driver("outer"); d != drv {
+			t.Errorf("Unexpected driver for outer alias: %v", d)
+		}
+		if d := Driver("inner"); d != drv {
+			t.Errorf("Unexpected driver for inner alias: %v", d)
+		}
+		expected := map[string]interface{}{"foo": "outer", "bar": "outer", "baz": "inner"}
+		if d := testy.DiffInterface(expected, Options("outer")); d != nil {
+			t.Error(d)
+		}
+		if d := testy.DiffInterface(map[string]interface{}{"foo": "inner", "baz": "inner"}, Options("inner")); d != nil {
+			t.Error(d)
+		}
+	})
+}
@@ -38,6 +38,8 @@ type iter struct {
 	cancel func() // cancel function to exit context goroutine when iterator is closed
 
 	curVal interface{}
+
+	metrics iterMetrics
 }
 
 func (i *iter) rlock() (unlock func(), err error) {
@@ -93,6 +95,7 @@ func (i *iter) next() (doClose, ok bool) {
 	}
 	i.ready = true
 	i.eoq = false
+	i.metrics.processed()
 	err := i.feed.Next(i.curVal)
 	if err == driver.EOQ {
 		i.eoq = true
@@ -102,9 +105,21 @@ func (i *iter) next() (doClose, ok bool) {
 	if i.lasterr != nil {
 		return true, false
 	}
+	if !i.eoq {
+		i.yielded()
+	}
 	return false, true
 }
 
+// yielded records the receipt of the current value in the iterator's stats.
+func (i *iter) yielded() {
+	var size int64
+	if m, ok := i.feed.(measurer); ok {
+		size = m.measure(i.curVal, &i.metrics)
+	}
+	i.metrics.yielded(size)
+}
+
 // EOQ returns true if the iterator has reached the end of a query in a
 // multi-query query. When EOQ is true, the row data will not have been
 // updated. It is common to simply `continue` in case of EOQ, unless you care
@@ -129,6 +144,7 @@ func (i *iter) close(err error) error {
 		return nil
 	}
 	i.closed = true
+	i.metrics.processed()
 
 	if i.lasterr == nil {
 		i.lasterr = err
@@ -17,6 +17,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/go-kivik/kivik/v4/driver"
 	"github.com/go-kivik/kivik/v4/internal/registry"
@@ -27,6 +29,13 @@ type Client struct {
 	dsn          string
 	driverName   string
 	driverClient driver.Client
+	options      Options
+
+	mu          sync.RWMutex
+	limits      SizeLimits
+	metricsHook MetricsHook
+
+	inflight inFlight
 }
 
 // Options is a collection of options. The keys and values are backend specific.
@@ -48,12 +57,67 @@ func mergeOptions(otherOpts ...Options) Options {
 	return options
 }
 
+// driverOptions returns opts without the options interpreted by Kivik, whose
+// names begin with "kivik.", so that they never reach the driver, however
+// they were set.
+func driverOptions(opts map[string]interface{}) map[string]interface{} {
+	for k := range opts {
+		if strings.HasPrefix(k, "kivik.") {
+			stripped := make(map[string]interface{}, len(opts))
+			for k, v := range opts {
+				if !strings.HasPrefix(k, "kivik.") {
+					stripped[k] = v
+				}
+			}
+			return stripped
+		}
+	}
+	return opts
+}
+
+// mergeOptions merges otherOpts under any default options registered for the
+// driver alias used to create c.
+func (c *Client) mergeOptions(otherOpts ...Options) Options {
+	if c.options == nil {
+		return mergeOptions(otherOpts...)
+	}
+	return mergeOptions(append([]Options{c.options}, otherOpts...)...)
+}
+
 // Register makes a database driver available by the provided name. If Register
 // is called twice with the same name or if driver is nil, it panics.
 func Register(name string, driver driver.Driver) {
 	registry.Register(name, driver)
 }
 
+// RegisterAlias makes the driver registered as driverName also available by
+// the name alias. Any options provided are merged, with later values taking
+// precedence, and used as defaults by DB, AllDBs, DBExists, CreateDB,
+// DestroyDB, ClusterStatus, GetReplications, Replicate, SchedulerJobs and
+// SchedulerDocs, for clients created with the alias. They are not defaults for
+// the methods of a DB returned by DB; use DB.WithOptions for that. This allows
+// selecting a backend and its configuration from a single configuration
+// string. Example:
+//
+//  kivik.RegisterAlias("couchdb", "couch")
+//  kivik.RegisterAlias("memory-test", "memory", kivik.Options{"foo": "bar"})
+//
+// driverName may itself be an alias, in which case the default options of
+// both apply, those of the new alias taking precedence. The aliased driver
+// need not be registered yet, but must be registered before New is called
+// with the alias. If RegisterAlias is called twice with the same alias, alias
+// is already the name of a registered driver, or alias would refer back to
+// itself, it panics.
+func RegisterAlias(alias, driverName string, options ...Options) {
+	registry.RegisterAlias(alias, driverName, mergeOptions(options...))
+}
+
+// Drivers returns a sorted list of the names of all registered drivers,
+// including aliases.
+func Drivers() []string {
+	return registry.Drivers()
+}
+
 // New creates a new client object specified by its database driver name
 // and a driver-specific data source name.
 func New(driverName, dataSourceName string) (*Client, error) {
@@ -69,10 +133,12 @@ func New(driverName, dataSourceName string) (*Client, error) {
 		dsn:          dataSourceName,
 		driverName:   driverName,
 		driverClient: client,
+		options:      registry.Options(driverName),
 	}, nil
 }
 
 // Driver returns the name of the driver string used to connect this client.
+// If the client was created with an alias, the alias is returned.
 func (c *Client) Driver() string {
 	return c.driverName
 }
@@ -103,7 +169,7 @@ type Version struct {
 func (c *Client) Version(ctx context.Context) (*Version, error) {
 	ver, err := c.driverClient.Version(ctx)
 	if err != nil {
-		return nil, err
+		return nil, opError("Version", err)
 	}
 	v := &Version{}
 	*v = Version(*ver)
@@ -114,33 +180,43 @@ func (c *Client) Version(ctx context.Context) (*Version, error) {
 // passed are merged, with later values taking precidence. If any errors occur
 // at this stage, they are deferred, or may be checked directly with Err()
 func (c *Client) DB(ctx context.Context, dbName string, options ...Options) *DB {
-	db, err := c.driverClient.DB(ctx, dbName, mergeOptions(options...))
+	db, err := c.driverClient.DB(ctx, dbName, driverOptions(c.mergeOptions(options...)))
 	return &DB{
 		client:   c,
 		name:     dbName,
 		driverDB: db,
-		err:      err,
+		err:      opError("DB", err),
 	}
 }
 
 // AllDBs returns a list of all databases.
 func (c *Client) AllDBs(ctx context.Context, options ...Options) ([]string, error) {
-	return c.driverClient.AllDBs(ctx, mergeOptions(options...))
+	ctx, op := c.startOp(ctx, "AllDBs", "/_all_dbs")
+	defer op.done()
+	dbs, err := c.driverClient.AllDBs(ctx, driverOptions(c.mergeOptions(options...)))
+	return dbs, opError("AllDBs", err)
 }
 
 // DBExists returns true if the specified database exists.
 func (c *Client) DBExists(ctx context.Context, dbName string, options ...Options) (bool, error) {
-	return c.driverClient.DBExists(ctx, dbName, mergeOptions(options...))
+	ctx, op := c.startOp(ctx, "DBExists", "/"+dbName)
+	defer op.done()
+	exists, err := c.driverClient.DBExists(ctx, dbName, driverOptions(c.mergeOptions(options...)))
+	return exists, opError("DBExists", err)
 }
 
 // CreateDB creates a DB of the requested name.
 func (c *Client) CreateDB(ctx context.Context, dbName string, options ...Options) error {
-	return c.driverClient.CreateDB(ctx, dbName, mergeOptions(options...))
+	ctx, op := c.startOp(ctx, "CreateDB", "/"+dbName)
+	defer op.done()
+	return opError("CreateDB", c.driverClient.CreateDB(ctx, dbName, driverOptions(c.mergeOptions(options...))))
 }
 
 // DestroyDB deletes the requested DB.
 func (c *Client) DestroyDB(ctx context.Context, dbName string, options ...Options) error {
-	return c.driverClient.DestroyDB(ctx, dbName, mergeOptions(options...))
+	ctx, op := c.startOp(ctx, "DestroyDB", "/"+dbName)
+	defer op.done()
+	return opError("DestroyDB", c.driverClient.DestroyDB(ctx, dbName, driverOptions(c.mergeOptions(options...))))
 }
 
 // Authenticate authenticates the client with the passed authenticator, which
@@ -148,7 +224,7 @@ func (c *Client) DestroyDB(ctx context.Context, dbName string, options ...Option
 // error will be returned.
 func (c *Client) Authenticate(ctx context.Context, a interface{}) error {
 	if auth, ok := c.driverClient.(driver.Authenticator); ok {
-		return auth.Authenticate(ctx, a)
+		return opError("Authenticate", auth.Authenticate(ctx, a))
 	}
 	return &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: driver does not support authentication"}
 }
@@ -187,7 +263,7 @@ func (c *Client) nativeDBsStats(ctx context.Context, dbnames []string) ([]*DBSta
 	}
 	stats, err := statser.DBsStats(ctx, dbnames)
 	if err != nil {
-		return nil, err
+		return nil, opError("DBsStats", err)
 	}
 	dbstats := make([]*DBStats, len(stats))
 	for i, stat := range stats {
@@ -202,16 +278,17 @@ func (c *Client) nativeDBsStats(ctx context.Context, dbnames []string) ([]*DBSta
 // made to calling Version.
 func (c *Client) Ping(ctx context.Context) (bool, error) {
 	if pinger, ok := c.driverClient.(driver.Pinger); ok {
-		return pinger.Ping(ctx)
+		up, err := pinger.Ping(ctx)
+		return up, opError("Ping", err)
 	}
 	_, err := c.driverClient.Version(ctx)
-	return err == nil, err
+	return err == nil, opError("Ping", err)
 }
 
 // Close cleans up any resources used by Client.
 func (c *Client) Close(ctx context.Context) error {
 	if closer, ok := c.driverClient.(driver.ClientCloser); ok {
-		return closer.Close(ctx)
+		return opError("Close", closer.Close(ctx))
 	}
 	return nil
 }
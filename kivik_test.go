@@ -86,6 +86,189 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewAlias(t *testing.T) {
+	Register("aliased", &mock.Driver{
+		NewClientFunc: func(_ string) (driver.Client, error) {
+			return &mock.Client{
+				AllDBsFunc: func(_ context.Context, opts map[string]interface{}) ([]string, error) {
+					expected := map[string]interface{}{"foo": "bar", "baz": "qux"}
+					if d := testy.DiffInterface(expected, opts); d != nil {
+						return nil, fmt.Errorf("Unexpected options: %s", d)
+					}
+					return []string{"a"}, nil
+				},
+			}, nil
+		},
+	})
+	RegisterAlias("alias", "aliased", Options{"foo": "bar", "baz": "default"})
+	client, err := New("alias", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := client.Driver(); name != "alias" {
+		t.Errorf("Unexpected driver name: %s", name)
+	}
+	if _, err := client.AllDBs(context.Background(), Options{"baz": "qux"}); err != nil {
+		t.Error(err)
+	}
+	drivers := Drivers()
+	var found int
+	for _, name := range drivers {
+		if name == "alias" || name == "aliased" {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("Expected alias and driver in %v", drivers)
+	}
+}
+
+func TestClientDefaultOptions(t *testing.T) {
+	type tt struct {
+		client func(record func(map[string]interface{})) driver.Client
+		call   func(*Client, Options) error
+	}
+	tests := testy.NewTable()
+	tests.Add("DB", tt{
+		client: func(record func(map[string]interface{})) driver.Client {
+			return &mock.Client{
+				DBFunc: func(_ context.Context, _ string, opts map[string]interface{}) (driver.DB, error) {
+					record(opts)
+					return &mock.DB{}, nil
+				},
+			}
+		},
+		call: func(c *Client, opts Options) error {
+			return c.DB(context.Background(), "foo", opts).Err()
+		},
+	})
+	tests.Add("DBExists", tt{
+		client: func(record func(map[string]interface{})) driver.Client {
+			return &mock.Client{
+				DBExistsFunc: func(_ context.Context, _ string, opts map[string]interface{}) (bool, error) {
+					record(opts)
+					return true, nil
+				},
+			}
+		},
+		call: func(c *Client, opts Options) error {
+			_, err := c.DBExists(context.Background(), "foo", opts)
+			return err
+		},
+	})
+	tests.Add("CreateDB", tt{
+		client: func(record func(map[string]interface{})) driver.Client {
+			return &mock.Client{
+				CreateDBFunc: func(_ context.Context, _ string, opts map[string]interface{}) error {
+					record(opts)
+					return nil
+				},
+			}
+		},
+		call: func(c *Client, opts Options) error {
+			return c.CreateDB(context.Background(), "foo", opts)
+		},
+	})
+	tests.Add("DestroyDB", tt{
+		client: func(record func(map[string]interface{})) driver.Client {
+			return &mock.Client{
+				DestroyDBFunc: func(_ context.Context, _ string, opts map[string]interface{}) error {
+					record(opts)
+					return nil
+				},
+			}
+		},
+		call: func(c *Client, opts Options) error {
+			return c.DestroyDB(context.Background(), "foo", opts)
+		},
+	})
+	tests.Add("ClusterStatus", tt{
+		client: func(record func(map[string]interface{})) driver.Client {
+			return &mock.Cluster{
+				ClusterStatusFunc: func(_ context.Context, opts map[string]interface{}) (string, error) {
+					record(opts)
+					return "cluster_finished", nil
+				},
+			}
+		},
+		call: func(c *Client, opts Options) error {
+			_, err := c.ClusterStatus(context.Background(), opts)
+			return err
+		},
+	})
+	tests.Add("GetReplications", tt{
+		client: func(record func(map[string]interface{})) driver.Client {
+			return &mock.ClientReplicator{
+				GetReplicationsFunc: func(_ context.Context, opts map[string]interface{}) ([]driver.Replication, error) {
+					record(opts)
+					return nil, nil
+				},
+			}
+		},
+		call: func(c *Client, opts Options) error {
+			_, err := c.GetReplications(context.Background(), opts)
+			return err
+		},
+	})
+	tests.Add("Replicate", tt{
+		client: func(record func(map[string]interface{})) driver.Client {
+			return &mock.ClientReplicator{
+				ReplicateFunc: func(_ context.Context, _, _ string, opts map[string]interface{}) (driver.Replication, error) {
+					record(opts)
+					return &mock.Replication{}, nil
+				},
+			}
+		},
+		call: func(c *Client, opts Options) error {
+			_, err := c.Replicate(context.Background(), "target", "source", opts)
+			return err
+		},
+	})
+	tests.Add("SchedulerJobs", tt{
+		client: func(record func(map[string]interface{})) driver.Client {
+			return &mock.Scheduler{
+				SchedulerJobsFunc: func(_ context.Context, opts map[string]interface{}) ([]*driver.SchedulerJob, error) {
+					record(opts)
+					return nil, nil
+				},
+			}
+		},
+		call: func(c *Client, opts Options) error {
+			_, err := c.SchedulerJobs(context.Background(), opts)
+			return err
+		},
+	})
+	tests.Add("SchedulerDocs", tt{
+		client: func(record func(map[string]interface{})) driver.Client {
+			return &mock.Scheduler{
+				SchedulerDocsFunc: func(_ context.Context, opts map[string]interface{}) ([]*driver.SchedulerDoc, error) {
+					record(opts)
+					return nil, nil
+				},
+			}
+		},
+		call: func(c *Client, opts Options) error {
+			_, err := c.SchedulerDocs(context.Background(), opts)
+			return err
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		var got map[string]interface{}
+		c := &Client{
+			driverClient: tt.client(func(opts map[string]interface{}) { got = opts }),
+			options:      Options{"foo": "bar", "baz": "default"},
+		}
+		if err := tt.call(c, Options{"baz": "qux"}); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{"foo": "bar", "baz": "qux"}
+		if d := testy.DiffInterface(expected, got); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
 func TestClientGetters(t *testing.T) {
 	driverName := "foo"
 	dsn := "bar"
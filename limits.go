@@ -0,0 +1,185 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SizeLimits are client-side limits on the size of documents and attachments
+// to be uploaded. A zero value means no limit.
+type SizeLimits struct {
+	// MaxDocumentSize is the maximum size, in bytes, of a document's JSON
+	// representation.
+	MaxDocumentSize int64
+	// MaxAttachmentSize is the maximum size, in bytes, of an attachment
+	// uploaded with PutAttachment.
+	MaxAttachmentSize int64
+}
+
+// SetSizeLimits enables client-side enforcement of the size limits. Put,
+// CreateDoc, BulkDocs and PutAttachment will then return a status 413 error
+// for any document or attachment which exceeds the limits, without sending it
+// to the server. By default there are no limits.
+//
+// Attachment sizes can only be checked when Attachment.Size is set.
+func (c *Client) SetSizeLimits(limits SizeLimits) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limits = limits
+}
+
+// SizeLimits returns the size limits currently enforced by the client.
+func (c *Client) SizeLimits() SizeLimits {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.limits
+}
+
+// LoadSizeLimits reads the couchdb/max_document_size and
+// couchdb/max_attachment_size values from the configuration of the specified
+// node, enforces them as with SetSizeLimits, and returns them. A value which
+// is unset, or "infinity", means no limit. The driver must support the Config
+// interface.
+func (c *Client) LoadSizeLimits(ctx context.Context, node string) (SizeLimits, error) {
+	var limits SizeLimits
+	var err error
+	if limits.MaxDocumentSize, err = c.sizeConfig(ctx, node, "couchdb", "max_document_size"); err != nil {
+		return SizeLimits{}, err
+	}
+	if limits.MaxAttachmentSize, err = c.sizeConfig(ctx, node, "couchdb", "max_attachment_size"); err != nil {
+		return SizeLimits{}, err
+	}
+	c.SetSizeLimits(limits)
+	return limits, nil
+}
+
+func (c *Client) sizeConfig(ctx context.Context, node, section, key string) (int64, error) {
+	value, err := c.ConfigValue(ctx, node, section, key)
+	if StatusCode(err) == http.StatusNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	value = strings.TrimSpace(value)
+	if value == "" || value == "infinity" {
+		return 0, nil
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, &Error{HTTPStatus: http.StatusBadGateway, Message: fmt.Sprintf("kivik: invalid %s: %q", key, value)}
+	}
+	return size, nil
+}
+
+func (db *DB) sizeLimits() SizeLimits {
+	if db.client == nil {
+		return SizeLimits{}
+	}
+	return db.client.SizeLimits()
+}
+
+// checkDocSize returns a status 413 error if doc exceeds the maximum document
+// size.
+func (db *DB) checkDocSize(doc interface{}) error {
+	limit := db.sizeLimits().MaxDocumentSize
+	if limit == 0 {
+		return nil
+	}
+	var size int64
+	switch t := doc.(type) {
+	case []byte:
+		size = int64(len(t))
+	case json.RawMessage:
+		size = int64(len(t))
+	default:
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+		}
+		size = int64(len(data))
+	}
+	if size > limit {
+		return &Error{HTTPStatus: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("kivik: document size %d exceeds limit of %d bytes", size, limit)}
+	}
+	return nil
+}
+
+// checkAttachmentSize returns a status 413 error if att exceeds the maximum
+// attachment size.
+func (db *DB) checkAttachmentSize(att *Attachment) error {
+	limit := db.sizeLimits().MaxAttachmentSize
+	if limit == 0 || att.Size <= limit {
+		return nil
+	}
+	return &Error{HTTPStatus: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("kivik: attachment size %d exceeds limit of %d bytes", att.Size, limit)}
+}
+
+// RequestTooLargeError is returned by BulkDocs when the server rejects a
+// request with status 413 (request entity too large). With
+// OptionSplitBulkDocs, it is instead the UpdateErr of any single document
+// which is too large to be stored.
+type RequestTooLargeError struct {
+	// MaxRequestSize is the server's chttpd/max_http_request_size, in bytes,
+	// or 0 if it could not be read from the server's configuration.
+	MaxRequestSize int64
+
+	// Err is the error returned by the driver.
+	Err error
+}
+
+var (
+	_ error       = &RequestTooLargeError{}
+	_ statusCoder = &RequestTooLargeError{}
+)
+
+func (e *RequestTooLargeError) Error() string {
+	if e.MaxRequestSize == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s (max_http_request_size is %d bytes)", e.Err, e.MaxRequestSize)
+}
+
+// StatusCode returns 413 (request entity too large).
+func (e *RequestTooLargeError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// Cause returns e.Err.
+func (e *RequestTooLargeError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns e.Err.
+func (e *RequestTooLargeError) Unwrap() error {
+	return e.Err
+}
+
+// tooLarge returns err as a *RequestTooLargeError, if it has status 413,
+// reading the maximum request size from the configuration of the local node.
+func (db *DB) tooLarge(ctx context.Context, err error) error {
+	if StatusCode(err) != http.StatusRequestEntityTooLarge {
+		return err
+	}
+	tooLarge := &RequestTooLargeError{Err: err}
+	if db.client != nil {
+		tooLarge.MaxRequestSize, _ = db.client.sizeConfig(ctx, "_local", "chttpd", "max_http_request_size")
+	}
+	return tooLarge
+}
@@ -0,0 +1,190 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestLoadSizeLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		client   driver.Client
+		expected SizeLimits
+		status   int
+		err      string
+	}{
+		{
+			name:   "driver doesn't implement Configer",
+			client: &mock.Client{},
+			status: http.StatusNotImplemented,
+			err:    "kivik: driver does not support Config interface",
+		},
+		{
+			name: "config error",
+			client: &mock.Configer{
+				ConfigValueFunc: func(_ context.Context, _, _, _ string) (string, error) {
+					return "", errors.New("config error")
+				},
+			},
+			status: http.StatusInternalServerError,
+			err:    "config error",
+		},
+		{
+			name: "invalid value",
+			client: &mock.Configer{
+				ConfigValueFunc: func(_ context.Context, _, _, _ string) (string, error) {
+					return "lots", nil
+				},
+			},
+			status: http.StatusBadGateway,
+			err:    `kivik: invalid max_document_size: "lots"`,
+		},
+		{
+			name: "success",
+			client: &mock.Configer{
+				ConfigValueFunc: func(_ context.Context, node, section, key string) (string, error) {
+					if node != "_local" || section != "couchdb" {
+						return "", errors.New("unexpected node or section")
+					}
+					switch key {
+					case "max_document_size":
+						return "8000000", nil
+					case "max_attachment_size":
+						return "infinity", nil
+					}
+					return "", errors.New("unexpected key")
+				},
+			},
+			expected: SizeLimits{MaxDocumentSize: 8000000},
+		},
+		{
+			name: "unset",
+			client: &mock.Configer{
+				ConfigValueFunc: func(_ context.Context, _, _, _ string) (string, error) {
+					return "", &Error{HTTPStatus: http.StatusNotFound, Message: "unknown_config_value"}
+				},
+			},
+			expected: SizeLimits{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{driverClient: tt.client}
+			limits, err := c.LoadSizeLimits(context.Background(), "_local")
+			testy.StatusError(t, tt.err, tt.status, err)
+			if d := testy.DiffInterface(tt.expected, limits); d != nil {
+				t.Error(d)
+			}
+			if d := testy.DiffInterface(tt.expected, c.SizeLimits()); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestSizeLimits(t *testing.T) {
+	driverDB := &mock.BulkDocer{
+		DB: &mock.DB{
+			PutFunc: func(_ context.Context, _ string, _ interface{}, _ map[string]interface{}) (string, error) {
+				return "1-xxx", nil
+			},
+			CreateDocFunc: func(_ context.Context, _ interface{}, _ map[string]interface{}) (string, string, error) {
+				return "foo", "1-xxx", nil
+			},
+			PutAttachmentFunc: func(_ context.Context, _, _ string, _ *driver.Attachment, _ map[string]interface{}) (string, error) {
+				return "2-xxx", nil
+			},
+		},
+		BulkDocsFunc: func(_ context.Context, _ []interface{}, _ map[string]interface{}) (driver.BulkResults, error) {
+			return &mock.BulkResults{}, nil
+		},
+	}
+	client := &Client{}
+	client.SetSizeLimits(SizeLimits{MaxDocumentSize: 20, MaxAttachmentSize: 5})
+	db := &DB{client: client, driverDB: driverDB}
+	ctx := context.Background()
+	small := map[string]string{"a": "b"}
+	large := map[string]string{"a": "this is much too large"}
+
+	tests := []struct {
+		name   string
+		fn     func() error
+		status int
+		err    string
+	}{
+		{
+			name: "put small",
+			fn: func() error {
+				_, err := db.Put(ctx, "foo", small)
+				return err
+			},
+		},
+		{
+			name: "put large",
+			fn: func() error {
+				_, err := db.Put(ctx, "foo", large)
+				return err
+			},
+			status: http.StatusRequestEntityTooLarge,
+			err:    "kivik: document size 30 exceeds limit of 20 bytes",
+		},
+		{
+			name: "create large",
+			fn: func() error {
+				_, _, err := db.CreateDoc(ctx, large)
+				return err
+			},
+			status: http.StatusRequestEntityTooLarge,
+			err:    "kivik: document size 30 exceeds limit of 20 bytes",
+		},
+		{
+			name: "bulk with one large",
+			fn: func() error {
+				_, err := db.BulkDocs(ctx, []interface{}{small, large})
+				return err
+			},
+			status: http.StatusRequestEntityTooLarge,
+			err:    "kivik: document size 30 exceeds limit of 20 bytes",
+		},
+		{
+			name: "small attachment",
+			fn: func() error {
+				_, err := db.PutAttachment(ctx, "foo", "1-xxx", &Attachment{Filename: "x.txt", Size: 5})
+				return err
+			},
+		},
+		{
+			name: "large attachment",
+			fn: func() error {
+				_, err := db.PutAttachment(ctx, "foo", "1-xxx", &Attachment{Filename: "x.txt", Size: 6})
+				return err
+			},
+			status: http.StatusRequestEntityTooLarge,
+			err:    "kivik: attachment size 6 exceeds limit of 5 bytes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testy.StatusError(t, tt.err, tt.status, tt.fn())
+		})
+	}
+}
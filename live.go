@@ -0,0 +1,251 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// LiveRow is a row of a live query's result set.
+type LiveRow struct {
+	ID    string          `json:"id"`
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// LiveEvent describes how a single document update changed a live query's
+// result set. A row whose key or value changed appears in both Removed and
+// Added.
+type LiveEvent struct {
+	// Seq is the update sequence of the change.
+	Seq string
+	// DocID is the ID of the changed document.
+	DocID   string
+	Added   []LiveRow
+	Removed []LiveRow
+}
+
+// LiveQuery is a view query whose result set is kept up to date by watching
+// the changes feed. Call Close when done, to stop watching.
+type LiveQuery struct {
+	db         *DB
+	ddoc, view string
+	options    Options
+
+	rows   []LiveRow
+	byID   map[string][]LiveRow
+	events chan LiveEvent
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	err    error
+	closed bool
+}
+
+// LiveQuery runs a view query, then watches the changes feed for updates
+// which affect the result. Rows returns the initial result set, and Events
+// delivers an event for every subsequent document update which adds rows to,
+// or removes rows from, it.
+//
+// As views cannot be queried by document, each update is evaluated by
+// running the query again, and comparing the rows for the updated document
+// with those previously seen. This makes LiveQuery best suited to views or
+// key ranges of modest size. Rows must carry document IDs, so reduce views
+// must be queried with reduce=false. options are as for Query, but should not
+// include limit or skip, as rows which move in or out of a limited range
+// because of updates to other documents are not reported.
+func (db *DB) LiveQuery(ctx context.Context, ddoc, view string, options ...Options) (*LiveQuery, error) {
+	if ddoc == "" {
+		return nil, missingArg("ddoc")
+	}
+	if view == "" {
+		return nil, missingArg("view")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	// The feed is opened first, so that no update made while the initial
+	// query runs is missed. Such updates may be seen twice, which is
+	// harmless, as re-evaluating an unchanged document yields no event.
+	changes, err := db.Changes(ctx, Options{"feed": "continuous", "since": "now"})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	q := &LiveQuery{
+		db:      db,
+		ddoc:    ddoc,
+		view:    view,
+		options: db.mergeOptions(options...),
+		events:  make(chan LiveEvent),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	if q.rows, err = q.query(ctx); err != nil {
+		_ = changes.Close()
+		cancel()
+		return nil, err
+	}
+	q.byID = groupLiveRows(q.rows)
+	go q.watch(ctx, changes)
+	return q, nil
+}
+
+// Rows returns the result set as of when the query started.
+func (q *LiveQuery) Rows() []LiveRow {
+	return q.rows
+}
+
+// Events returns a channel of updates to the result set. The channel is
+// closed when the query is closed, or watching fails, in which case Err
+// returns the error.
+func (q *LiveQuery) Events() <-chan LiveEvent {
+	return q.events
+}
+
+// Err returns the error, if any, which ended the query. It should be checked
+// once the Events channel is closed.
+func (q *LiveQuery) Err() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.err
+}
+
+// Close stops watching for updates, and closes the Events channel.
+func (q *LiveQuery) Close() error {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cancel()
+	<-q.done
+	return nil
+}
+
+func (q *LiveQuery) watch(ctx context.Context, changes *Changes) {
+	defer close(q.done)
+	defer close(q.events)
+	defer changes.Close() // nolint: errcheck
+	for changes.Next() {
+		docID := changes.ID()
+		if strings.HasPrefix(docID, "_design/") {
+			continue
+		}
+		event, err := q.update(ctx, docID)
+		if err != nil {
+			q.fail(err)
+			return
+		}
+		if event == nil {
+			continue
+		}
+		event.Seq = changes.Seq()
+		select {
+		case q.events <- *event:
+		case <-ctx.Done():
+			q.fail(ctx.Err())
+			return
+		}
+	}
+	q.fail(changes.Err())
+}
+
+// fail records err as the reason the query ended, unless it was closed.
+func (q *LiveQuery) fail(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.err = err
+	}
+}
+
+// update re-evaluates the rows for docID, returning nil if they are
+// unchanged.
+func (q *LiveQuery) update(ctx context.Context, docID string) (*LiveEvent, error) {
+	rows, err := q.query(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var current []LiveRow
+	for _, row := range rows {
+		if row.ID == docID {
+			current = append(current, row)
+		}
+	}
+	previous := q.byID[docID]
+	event := &LiveEvent{
+		DocID:   docID,
+		Added:   diffLiveRows(current, previous),
+		Removed: diffLiveRows(previous, current),
+	}
+	if len(event.Added) == 0 && len(event.Removed) == 0 {
+		return nil, nil
+	}
+	if len(current) == 0 {
+		delete(q.byID, docID)
+	} else {
+		q.byID[docID] = current
+	}
+	return event, nil
+}
+
+func (q *LiveQuery) query(ctx context.Context) ([]LiveRow, error) {
+	rows, err := q.db.Query(ctx, q.ddoc, q.view, q.options)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // nolint: errcheck
+	var result []LiveRow
+	for rows.Next() {
+		row := LiveRow{ID: rows.ID()}
+		if row.ID == "" {
+			return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: live query rows must have document IDs; use reduce=false"}
+		}
+		if err := rows.ScanKey(&row.Key); err != nil {
+			return nil, err
+		}
+		if err := rows.ScanValue(&row.Value); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func groupLiveRows(rows []LiveRow) map[string][]LiveRow {
+	byID := make(map[string][]LiveRow)
+	for _, row := range rows {
+		byID[row.ID] = append(byID[row.ID], row)
+	}
+	return byID
+}
+
+// diffLiveRows returns the rows of a which are not in b, counting duplicates.
+func diffLiveRows(a, b []LiveRow) []LiveRow {
+	seen := make(map[[2]string]int, len(b))
+	for _, row := range b {
+		seen[[2]string{string(row.Key), string(row.Value)}]++
+	}
+	var diff []LiveRow
+	for _, row := range a {
+		k := [2]string{string(row.Key), string(row.Value)}
+		if seen[k] > 0 {
+			seen[k]--
+			continue
+		}
+		diff = append(diff, row)
+	}
+	return diff
+}
@@ -0,0 +1,185 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+func init() {
+	memorydb.RegisterView("live", "by_color", memorydb.View{
+		Map: func(doc map[string]interface{}, emit func(key, value interface{})) {
+			if color, ok := doc["color"]; ok {
+				emit(color, 1)
+			}
+		},
+		Reduce: memorydb.Count,
+	})
+}
+
+func liveDB(t *testing.T) *kivik.DB {
+	t.Helper()
+	ctx := context.Background()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	return client.DB(ctx, "test")
+}
+
+func liveRow(id, key string) kivik.LiveRow {
+	return kivik.LiveRow{ID: id, Key: json.RawMessage(`"` + key + `"`), Value: json.RawMessage(`1`)}
+}
+
+func nextLiveEvent(t *testing.T, q *kivik.LiveQuery) kivik.LiveEvent {
+	t.Helper()
+	select {
+	case event, ok := <-q.Events():
+		if !ok {
+			t.Fatalf("Events closed: %v", q.Err())
+		}
+		event.Seq = ""
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+	return kivik.LiveEvent{}
+}
+
+func TestLiveQuery(t *testing.T) {
+	ctx := context.Background()
+	db := liveDB(t)
+	revA, err := db.Put(ctx, "a", map[string]string{"color": "red"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revB, err := db.Put(ctx, "b", map[string]string{"color": "blue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := db.LiveQuery(ctx, "live", "by_color", kivik.Options{"reduce": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close() // nolint: errcheck
+
+	if d := testy.DiffInterface([]kivik.LiveRow{liveRow("b", "blue"), liveRow("a", "red")}, q.Rows()); d != nil {
+		t.Errorf("Unexpected initial rows:\n%s", d)
+	}
+
+	if _, err := db.Put(ctx, "a", map[string]string{"_rev": revA, "color": "green"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := kivik.LiveEvent{
+		DocID:   "a",
+		Added:   []kivik.LiveRow{liveRow("a", "green")},
+		Removed: []kivik.LiveRow{liveRow("a", "red")},
+	}
+	if d := testy.DiffInterface(expected, nextLiveEvent(t, q)); d != nil {
+		t.Errorf("Unexpected update event:\n%s", d)
+	}
+
+	// Neither a document outside the view nor a design document yields an
+	// event, so the next one is for d.
+	if _, err := db.Put(ctx, "c", map[string]string{"shape": "round"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Put(ctx, "_design/foo", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Put(ctx, "d", map[string]string{"color": "red"}); err != nil {
+		t.Fatal(err)
+	}
+	expected = kivik.LiveEvent{
+		DocID: "d",
+		Added: []kivik.LiveRow{liveRow("d", "red")},
+	}
+	if d := testy.DiffInterface(expected, nextLiveEvent(t, q)); d != nil {
+		t.Errorf("Unexpected addition event:\n%s", d)
+	}
+
+	if _, err := db.Delete(ctx, "b", revB); err != nil {
+		t.Fatal(err)
+	}
+	expected = kivik.LiveEvent{
+		DocID:   "b",
+		Removed: []kivik.LiveRow{liveRow("b", "blue")},
+	}
+	if d := testy.DiffInterface(expected, nextLiveEvent(t, q)); d != nil {
+		t.Errorf("Unexpected removal event:\n%s", d)
+	}
+
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-q.Events(); ok {
+		t.Error("Events not closed")
+	}
+	if err := q.Err(); err != nil {
+		t.Errorf("Unexpected error after close: %s", err)
+	}
+}
+
+func TestLiveQueryErrors(t *testing.T) {
+	type tt struct {
+		ddoc, view string
+		options    kivik.Options
+		status     int
+		err        string
+	}
+	tests := testy.NewTable()
+	tests.Add("no ddoc", tt{
+		view:   "by_color",
+		status: http.StatusBadRequest,
+		err:    "kivik: ddoc required",
+	})
+	tests.Add("no view", tt{
+		ddoc:   "live",
+		status: http.StatusBadRequest,
+		err:    "kivik: view required",
+	})
+	tests.Add("reduced", tt{
+		ddoc:   "live",
+		view:   "by_color",
+		status: http.StatusBadRequest,
+		err:    "kivik: live query rows must have document IDs; use reduce=false",
+	})
+	tests.Add("missing view", tt{
+		ddoc:   "live",
+		view:   "missing",
+		status: http.StatusNotFound,
+		err:    "missing named view live/missing",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		db := liveDB(t)
+		if _, err := db.Put(context.Background(), "a", map[string]string{"color": "red"}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := db.LiveQuery(context.Background(), tt.ddoc, tt.view, tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
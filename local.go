@@ -0,0 +1,152 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+const localPrefix = "_local/"
+
+// maxLocalAttempts is the number of times PutLocal and DeleteLocal retry an
+// emulated write which conflicts with a concurrent update.
+const maxLocalAttempts = 5
+
+// GetLocal fetches the local document docID, which may be given with or
+// without the "_local/" prefix. Local documents are not replicated, and are
+// intended for client-side state, such as replication checkpoints.
+func (db *DB) GetLocal(ctx context.Context, docID string, options ...Options) *Row {
+	if db.err != nil {
+		return &Row{Err: db.err}
+	}
+	docID = strings.TrimPrefix(docID, localPrefix)
+	if docID == "" {
+		return &Row{Err: missingArg("docID")}
+	}
+	opts := db.mergeOptions(options...)
+	if ls, ok := db.driverDB.(driver.LocalDocStorer); ok {
+		useNumber, err := useNumberOption(opts)
+		if err != nil {
+			return &Row{Err: err}
+		}
+		doc, err := ls.GetLocal(ctx, docID, driverOptions(opts))
+		if err != nil {
+			return &Row{Err: opError("GetLocal", err)}
+		}
+		return &Row{
+			ContentLength: doc.ContentLength,
+			Rev:           doc.Rev,
+			Body:          doc.Body,
+			useNumber:     useNumber,
+		}
+	}
+	return db.Get(ctx, localPrefix+docID, opts)
+}
+
+// PutLocal stores doc as the local document docID, which may be given with or
+// without the "_local/" prefix. Unlike Put, no revision is required: doc
+// replaces any existing version, and any _rev field it contains is ignored.
+func (db *DB) PutLocal(ctx context.Context, docID string, doc interface{}, options ...Options) error {
+	if db.err != nil {
+		return db.err
+	}
+	docID = strings.TrimPrefix(docID, localPrefix)
+	if docID == "" {
+		return missingArg("docID")
+	}
+	i, err := normalizeFromJSON(doc)
+	if err != nil {
+		return err
+	}
+	opts := db.mergeOptions(options...)
+	if ls, ok := db.driverDB.(driver.LocalDocStorer); ok {
+		return opError("PutLocal", ls.PutLocal(ctx, docID, i, driverOptions(opts)))
+	}
+	body, err := localBody(i)
+	if err != nil {
+		return err
+	}
+	return db.retryLocal(ctx, docID, func(rev string) error {
+		delete(body, "_rev")
+		if rev != "" {
+			body["_rev"] = rev
+		}
+		_, err := db.Put(ctx, localPrefix+docID, body, opts)
+		return err
+	})
+}
+
+// DeleteLocal deletes the local document docID, which may be given with or
+// without the "_local/" prefix. Unlike Delete, no revision is required.
+func (db *DB) DeleteLocal(ctx context.Context, docID string, options ...Options) error {
+	if db.err != nil {
+		return db.err
+	}
+	docID = strings.TrimPrefix(docID, localPrefix)
+	if docID == "" {
+		return missingArg("docID")
+	}
+	opts := db.mergeOptions(options...)
+	if ls, ok := db.driverDB.(driver.LocalDocStorer); ok {
+		return opError("DeleteLocal", ls.DeleteLocal(ctx, docID, driverOptions(opts)))
+	}
+	return db.retryLocal(ctx, docID, func(rev string) error {
+		if rev == "" {
+			return &Error{HTTPStatus: http.StatusNotFound, Message: "kivik: local document not found"}
+		}
+		_, err := db.Delete(ctx, localPrefix+docID, rev, opts)
+		return err
+	})
+}
+
+// localBody returns doc as a map, so that its _rev field may be replaced.
+func localBody(doc interface{}) (map[string]interface{}, error) {
+	if m, ok := doc.(map[string]interface{}); ok {
+		body := make(map[string]interface{}, len(m)+1)
+		for k, v := range m {
+			body[k] = v
+		}
+		return body, nil
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	return m, nil
+}
+
+// retryLocal calls write with the current rev of the local document docID, or
+// "" if it does not exist, retrying if write reports a conflict.
+func (db *DB) retryLocal(ctx context.Context, docID string, write func(rev string) error) error {
+	var err error
+	for i := 0; i < maxLocalAttempts; i++ {
+		var rev string
+		_, rev, err = db.GetMeta(ctx, localPrefix+docID)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		if err = write(rev); !IsConflict(err) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,289 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// localStore returns a mock DB which stores documents as Put, requiring a
+// matching _rev for updates, with revs "0-N". conflicts is the number of
+// times Put and Delete fail with a conflict before succeeding.
+func localStore(docs map[string]map[string]interface{}, conflicts int) *mock.DB {
+	revs := map[string]int{}
+	for id := range docs {
+		revs[id] = 1
+	}
+	conflict := func(docID, rev string) error {
+		if conflicts > 0 {
+			conflicts--
+			return &Error{HTTPStatus: http.StatusConflict, Err: errors.New("conflict")}
+		}
+		if cur, ok := revs[docID]; ok && fmt.Sprintf("0-%d", cur) != rev {
+			return &Error{HTTPStatus: http.StatusConflict, Err: errors.New("conflict")}
+		}
+		return nil
+	}
+	return &mock.DB{
+		GetFunc: func(_ context.Context, docID string, _ map[string]interface{}) (*driver.Document, error) {
+			doc, ok := docs[docID]
+			if !ok {
+				return nil, &Error{HTTPStatus: http.StatusNotFound, Err: errors.New("missing")}
+			}
+			body, _ := json.Marshal(doc)
+			return &driver.Document{
+				Rev:  fmt.Sprintf("0-%d", revs[docID]),
+				Body: ioutil.NopCloser(strings.NewReader(string(body))),
+			}, nil
+		},
+		PutFunc: func(_ context.Context, docID string, doc interface{}, _ map[string]interface{}) (string, error) {
+			body := doc.(map[string]interface{})
+			rev, _ := body["_rev"].(string)
+			if err := conflict(docID, rev); err != nil {
+				return "", err
+			}
+			delete(body, "_rev")
+			docs[docID] = body
+			revs[docID]++
+			return fmt.Sprintf("0-%d", revs[docID]), nil
+		},
+		DeleteFunc: func(_ context.Context, docID, rev string, _ map[string]interface{}) (string, error) {
+			if err := conflict(docID, rev); err != nil {
+				return "", err
+			}
+			delete(docs, docID)
+			delete(revs, docID)
+			return "0-0", nil
+		},
+	}
+}
+
+func TestGetLocal(t *testing.T) {
+	type tt struct {
+		db       *DB
+		docID    string
+		expected interface{}
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("db error", tt{
+		db:     &DB{err: errors.New("db error")},
+		docID:  "foo",
+		status: http.StatusInternalServerError,
+		err:    "db error",
+	})
+	tests.Add("missing id", tt{
+		db:     &DB{driverDB: &mock.DB{}},
+		docID:  "_local/",
+		status: http.StatusBadRequest,
+		err:    "kivik: docID required",
+	})
+	tests.Add("native", tt{
+		db: &DB{driverDB: &mock.LocalDocStorer{
+			GetLocalFunc: func(_ context.Context, docID string, _ map[string]interface{}) (*driver.Document, error) {
+				if docID != "foo" {
+					return nil, fmt.Errorf("Unexpected docID: %s", docID)
+				}
+				return &driver.Document{Body: ioutil.NopCloser(strings.NewReader(`{"native":true}`))}, nil
+			},
+		}},
+		docID:    "_local/foo",
+		expected: map[string]interface{}{"native": true},
+	})
+	tests.Add("native error", tt{
+		db: &DB{driverDB: &mock.LocalDocStorer{
+			GetLocalFunc: func(context.Context, string, map[string]interface{}) (*driver.Document, error) {
+				return nil, &Error{HTTPStatus: http.StatusNotFound, Err: errors.New("missing")}
+			},
+		}},
+		docID:  "foo",
+		status: http.StatusNotFound,
+		err:    "missing",
+	})
+	tests.Add("fallback", tt{
+		db: &DB{driverDB: localStore(map[string]map[string]interface{}{
+			"_local/foo": {"fallback": true},
+		}, 0)},
+		docID:    "foo",
+		expected: map[string]interface{}{"fallback": true},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		var doc interface{}
+		err := tt.db.GetLocal(context.Background(), tt.docID).ScanDoc(&doc)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, doc); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestGetLocalUseNumber(t *testing.T) {
+	db := &DB{driverDB: &mock.LocalDocStorer{
+		GetLocalFunc: func(_ context.Context, _ string, opts map[string]interface{}) (*driver.Document, error) {
+			if _, ok := opts[OptionUseNumber]; ok {
+				return nil, fmt.Errorf("Option passed to driver: %v", opts)
+			}
+			return &driver.Document{Body: ioutil.NopCloser(strings.NewReader(bigNumberDoc))}, nil
+		},
+	}}
+	var doc map[string]interface{}
+	if err := db.GetLocal(context.Background(), "foo", Options{OptionUseNumber: true}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["n"] != json.Number("12345678901234567890") {
+		t.Errorf("Unexpected value: %v (%T)", doc["n"], doc["n"])
+	}
+}
+
+func TestPutLocal(t *testing.T) {
+	type tt struct {
+		db       *DB
+		docs     map[string]map[string]interface{}
+		doc      interface{}
+		status   int
+		err      string
+		expected map[string]map[string]interface{}
+	}
+	tests := testy.NewTable()
+	tests.Add("db error", tt{
+		db:     &DB{err: errors.New("db error")},
+		status: http.StatusInternalServerError,
+		err:    "db error",
+	})
+	tests.Add("native", tt{
+		db: &DB{driverDB: &mock.LocalDocStorer{
+			PutLocalFunc: func(_ context.Context, docID string, doc interface{}, _ map[string]interface{}) error {
+				if docID != "foo" {
+					return fmt.Errorf("Unexpected docID: %s", docID)
+				}
+				if d := testy.DiffInterface(map[string]interface{}{"a": "b"}, doc); d != nil {
+					return fmt.Errorf("Unexpected doc: %s", d)
+				}
+				return nil
+			},
+		}},
+		doc: []byte(`{"a":"b"}`),
+	})
+	tests.Add("fallback, new", func() interface{} {
+		docs := map[string]map[string]interface{}{}
+		return tt{
+			db:       &DB{driverDB: localStore(docs, 0)},
+			docs:     docs,
+			doc:      map[string]interface{}{"a": "b"},
+			expected: map[string]map[string]interface{}{"_local/foo": {"a": "b"}},
+		}
+	})
+	tests.Add("fallback, replace", func() interface{} {
+		docs := map[string]map[string]interface{}{"_local/foo": {"a": "old"}}
+		return tt{
+			db:       &DB{driverDB: localStore(docs, 0)},
+			docs:     docs,
+			doc:      map[string]interface{}{"_rev": "99-stale", "a": "new"},
+			expected: map[string]map[string]interface{}{"_local/foo": {"a": "new"}},
+		}
+	})
+	tests.Add("fallback, concurrent update", func() interface{} {
+		docs := map[string]map[string]interface{}{"_local/foo": {"a": "old"}}
+		return tt{
+			db:       &DB{driverDB: localStore(docs, 2)},
+			docs:     docs,
+			doc:      struct{ A string }{A: "new"},
+			expected: map[string]map[string]interface{}{"_local/foo": {"A": "new"}},
+		}
+	})
+	tests.Add("fallback, repeated conflicts", func() interface{} {
+		docs := map[string]map[string]interface{}{"_local/foo": {"a": "old"}}
+		return tt{
+			db:       &DB{driverDB: localStore(docs, maxLocalAttempts)},
+			docs:     docs,
+			doc:      map[string]interface{}{"a": "new"},
+			status:   http.StatusConflict,
+			err:      "conflict",
+			expected: map[string]map[string]interface{}{"_local/foo": {"a": "old"}},
+		}
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		err := tt.db.PutLocal(context.Background(), "foo", tt.doc)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, tt.docs); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestDeleteLocal(t *testing.T) {
+	type tt struct {
+		db       *DB
+		docs     map[string]map[string]interface{}
+		status   int
+		err      string
+		expected map[string]map[string]interface{}
+	}
+	tests := testy.NewTable()
+	tests.Add("db error", tt{
+		db:     &DB{err: errors.New("db error")},
+		status: http.StatusInternalServerError,
+		err:    "db error",
+	})
+	tests.Add("native", tt{
+		db: &DB{driverDB: &mock.LocalDocStorer{
+			DeleteLocalFunc: func(_ context.Context, docID string, _ map[string]interface{}) error {
+				if docID != "foo" {
+					return fmt.Errorf("Unexpected docID: %s", docID)
+				}
+				return nil
+			},
+		}},
+	})
+	tests.Add("fallback", func() interface{} {
+		docs := map[string]map[string]interface{}{"_local/foo": {"a": "b"}}
+		return tt{
+			db:       &DB{driverDB: localStore(docs, 1)},
+			docs:     docs,
+			expected: map[string]map[string]interface{}{},
+		}
+	})
+	tests.Add("fallback, missing", func() interface{} {
+		docs := map[string]map[string]interface{}{}
+		return tt{
+			db:       &DB{driverDB: localStore(docs, 0)},
+			docs:     docs,
+			status:   http.StatusNotFound,
+			err:      "kivik: local document not found",
+			expected: map[string]map[string]interface{}{},
+		}
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		err := tt.db.DeleteLocal(context.Background(), "_local/foo")
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, tt.docs); d != nil {
+			t.Error(d)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// IteratorStats reports a consumer's progress through the results of an
+// iterator, such as Rows or Changes.
+type IteratorStats struct {
+	// Operation is the name of the method which returned the iterator, such
+	// as "AllDocs" or "Changes".
+	Operation string
+	// Endpoint is the path of the resource addressed, such as "/db/_changes".
+	Endpoint string
+	// Rows is the number of results yielded by Next.
+	Rows int64
+	// Bytes is the size of the raw JSON keys, values and documents of the
+	// results yielded. Documents and values read from a stream are counted as
+	// they are scanned.
+	Bytes int64
+	// LastNext is when Next last yielded a result, or the zero time if it has
+	// not yet done so.
+	LastNext time.Time
+	// Lag is the time between the latest result being received and the
+	// consumer finishing with it, by calling Next again or closing the
+	// iterator. For a changes feed, this is how far the consumer lags behind
+	// the feed.
+	Lag time.Duration
+	// MaxLag is the greatest Lag seen so far.
+	MaxLag time.Duration
+}
+
+// SinceLastNext returns the time since Next last yielded a result, or zero if
+// it has not yet done so. A consumer which is stuck processing a result shows
+// a steadily increasing value.
+func (s IteratorStats) SinceLastNext() time.Duration {
+	if s.LastNext.IsZero() {
+		return 0
+	}
+	return time.Since(s.LastNext)
+}
+
+// MetricsHook receives the stats of an iterator each time the consumer
+// finishes with a result. It is called synchronously, from the consumer's
+// goroutine, so should not block.
+type MetricsHook func(IteratorStats)
+
+// SetMetricsHook sets a hook to receive the stats of the iterators returned
+// by c and its DBs, such as to feed a consumer health dashboard. A nil hook disables reporting. Iterators already open are
+// unaffected.
+func (c *Client) SetMetricsHook(hook MetricsHook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metricsHook = hook
+}
+
+func (c *Client) getMetricsHook() MetricsHook {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.metricsHook
+}
+
+// measure arranges for the stats of i, returned by the method name for
+// endpoint, to be reported to c's metrics hook.
+func (c *Client) measure(i *iter, name, endpoint string) {
+	if c == nil {
+		return
+	}
+	i.metrics.stats.Operation = name
+	i.metrics.stats.Endpoint = endpoint
+	i.metrics.hook = c.getMetricsHook()
+}
+
+// measure is like Client.measure, for an iterator over path, relative to the
+// database.
+func (db *DB) measure(i *iter, name, path string) {
+	db.client.measure(i, name, db.endpoint(path))
+}
+
+// measurer is implemented by iterator feeds which report the size of their
+// results.
+type measurer interface {
+	// measure returns the size of value. It may wrap streams in value with
+	// m.reader, to count them as they are read.
+	measure(value interface{}, m *iterMetrics) int64
+}
+
+// iterMetrics collects the stats of an iterator.
+type iterMetrics struct {
+	mu       sync.Mutex
+	stats    IteratorStats
+	received time.Time // when the current result was received, until the consumer finishes with it
+	hook     MetricsHook
+}
+
+func (m *iterMetrics) snapshot() IteratorStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+// yielded records the receipt of a result of size bytes.
+func (m *iterMetrics) yielded(size int64) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.Rows++
+	m.stats.Bytes += size
+	m.stats.LastNext = now
+	m.received = now
+}
+
+// processed records that the consumer has finished with the current result,
+// if any, and reports to the hook.
+func (m *iterMetrics) processed() {
+	m.mu.Lock()
+	if m.received.IsZero() {
+		m.mu.Unlock()
+		return
+	}
+	m.stats.Lag = time.Since(m.received)
+	if m.stats.Lag > m.stats.MaxLag {
+		m.stats.MaxLag = m.stats.Lag
+	}
+	m.received = time.Time{}
+	stats, hook := m.stats, m.hook
+	m.mu.Unlock()
+	if hook != nil {
+		hook(stats)
+	}
+}
+
+func (m *iterMetrics) addBytes(n int64) {
+	m.mu.Lock()
+	m.stats.Bytes += n
+	m.mu.Unlock()
+}
+
+// reader wraps r, counting the bytes read from it.
+func (m *iterMetrics) reader(r io.Reader) io.Reader {
+	if r == nil {
+		return nil
+	}
+	return &countingReader{Reader: r, m: m}
+}
+
+type countingReader struct {
+	io.Reader
+	m *iterMetrics
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.m.addBytes(int64(n))
+	return n, err
+}
+
+func rawSize(raws ...json.RawMessage) int64 {
+	var n int64
+	for _, raw := range raws {
+		n += int64(len(raw))
+	}
+	return n
+}
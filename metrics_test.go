@@ -0,0 +1,201 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestRowsStats(t *testing.T) {
+	results := []driver.Row{
+		{ID: "a", Key: json.RawMessage(`"a"`), Value: json.RawMessage(`1`)},
+		{ID: "b", Key: json.RawMessage(`"b"`), Value: json.RawMessage(`2`), DocReader: strings.NewReader(`{"_id":"b"}`)},
+	}
+	driverDB := &mock.DB{
+		AllDocsFunc: func(context.Context, map[string]interface{}) (driver.Rows, error) {
+			rows := results
+			return &mock.Rows{
+				NextFunc: func(row *driver.Row) error {
+					if len(rows) == 0 {
+						return io.EOF
+					}
+					*row = rows[0]
+					rows = rows[1:]
+					return nil
+				},
+				CloseFunc: func() error { return nil },
+			}, nil
+		},
+	}
+	client := &Client{driverClient: &mock.Client{
+		DBFunc: func(context.Context, string, map[string]interface{}) (driver.DB, error) {
+			return driverDB, nil
+		},
+	}}
+	var reported []IteratorStats
+	client.SetMetricsHook(func(stats IteratorStats) {
+		reported = append(reported, stats)
+	})
+	db := client.DB(context.Background(), "db")
+
+	rows, err := db.AllDocs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := rows.Stats(); stats.Rows != 0 || stats.SinceLastNext() != 0 {
+		t.Errorf("Unexpected initial stats: %+v", stats)
+	}
+	for rows.Next() {
+		if rows.ID() == "b" {
+			var doc map[string]interface{}
+			if err := rows.ScanDoc(&doc); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	stats := rows.Stats()
+	if stats.LastNext.IsZero() || stats.SinceLastNext() <= 0 {
+		t.Errorf("Unexpected LastNext: %v", stats.LastNext)
+	}
+	type summary struct {
+		Operation, Endpoint string
+		Rows, Bytes         int64
+	}
+	summarize := func(s IteratorStats) summary {
+		return summary{Operation: s.Operation, Endpoint: s.Endpoint, Rows: s.Rows, Bytes: s.Bytes}
+	}
+	if d := testy.DiffInterface(summary{Operation: "AllDocs", Endpoint: "/db/_all_docs", Rows: 2, Bytes: 19}, summarize(stats)); d != nil {
+		t.Error(d)
+	}
+	var got []summary
+	for _, s := range reported {
+		got = append(got, summarize(s))
+	}
+	expected := []summary{
+		{Operation: "AllDocs", Endpoint: "/db/_all_docs", Rows: 1, Bytes: 4},
+		{Operation: "AllDocs", Endpoint: "/db/_all_docs", Rows: 2, Bytes: 19},
+	}
+	if d := testy.DiffInterface(expected, got); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestChangesStats(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	driverDB := &mock.DB{
+		ChangesFunc: func(context.Context, map[string]interface{}) (driver.Changes, error) {
+			seqs := []string{"1", "2", "3"}
+			return &mock.Changes{
+				NextFunc: func(ch *driver.Change) error {
+					if len(seqs) == 0 {
+						return io.EOF
+					}
+					*ch = driver.Change{ID: "doc" + seqs[0], Seq: seqs[0], Doc: json.RawMessage(`{}`)}
+					seqs = seqs[1:]
+					return nil
+				},
+				CloseFunc: func() error { return nil },
+			}, nil
+		},
+	}
+	client := &Client{driverClient: &mock.Client{
+		DBFunc: func(context.Context, string, map[string]interface{}) (driver.DB, error) {
+			return driverDB, nil
+		},
+	}}
+	var lags []time.Duration
+	client.SetMetricsHook(func(stats IteratorStats) {
+		lags = append(lags, stats.Lag)
+	})
+	changes, err := client.DB(context.Background(), "db").Changes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for changes.Next() {
+		if changes.Seq() == "2" {
+			time.Sleep(delay)
+		}
+	}
+	if err := changes.Err(); err != nil {
+		t.Fatal(err)
+	}
+	stats := changes.Stats()
+	if stats.Rows != 3 || stats.Bytes != 6 || stats.Endpoint != "/db/_changes" {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if stats.MaxLag < delay {
+		t.Errorf("MaxLag %v less than %v", stats.MaxLag, delay)
+	}
+	if len(lags) != 3 {
+		t.Fatalf("Expected 3 reports, got %d", len(lags))
+	}
+	if lags[1] < delay || lags[1] != stats.MaxLag {
+		t.Errorf("Unexpected lags: %v", lags)
+	}
+}
+
+func TestDesignDocsStats(t *testing.T) {
+	driverDB := &mock.DesignDocer{
+		DesignDocsFunc: func(context.Context, map[string]interface{}) (driver.Rows, error) {
+			rows := []driver.Row{{ID: "_design/foo", Key: json.RawMessage(`"_design/foo"`)}}
+			return &mock.Rows{
+				NextFunc: func(row *driver.Row) error {
+					if len(rows) == 0 {
+						return io.EOF
+					}
+					*row = rows[0]
+					rows = rows[1:]
+					return nil
+				},
+				CloseFunc: func() error { return nil },
+			}, nil
+		},
+	}
+	client := &Client{driverClient: &mock.Client{
+		DBFunc: func(context.Context, string, map[string]interface{}) (driver.DB, error) {
+			return driverDB, nil
+		},
+	}}
+	var reported []IteratorStats
+	client.SetMetricsHook(func(stats IteratorStats) {
+		reported = append(reported, stats)
+	})
+	rows, err := client.DB(context.Background(), "db").DesignDocs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) != 1 {
+		t.Fatalf("Expected 1 report, got %d", len(reported))
+	}
+	if s := reported[0]; s.Operation != "DesignDocs" || s.Endpoint != "/db/_design_docs" || s.Rows != 1 || s.Bytes != 13 {
+		t.Errorf("Unexpected stats: %+v", s)
+	}
+}
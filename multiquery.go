@@ -0,0 +1,161 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// QueryMulti executes several queries against a view, as a single request
+// where the driver supports it. See Query for the meaning of ddoc and view.
+// Each entry in queries holds the options for one query, and is merged with
+// any default options set with WithOptions.
+//
+// The results of all queries are returned by a single iterator. At the end of
+// each query's results, except the last, Next returns true with EOQ set, and
+// QueryIndex reports the 0-based index of the query being iterated. Offset,
+// TotalRows and UpdateSeq refer to the current query.
+//
+// If the driver does not support multiple queries, the queries are executed
+// one at a time, as each is reached. In this case, an error in any but the
+// first query is reported by Err.
+func (db *DB) QueryMulti(ctx context.Context, ddoc, view string, queries []Options) (*Rows, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	ddoc = strings.TrimPrefix(ddoc, "_design/")
+	view = strings.TrimPrefix(view, "_view/")
+	opts := make([]map[string]interface{}, len(queries))
+	var useNumber bool
+	for i, query := range queries {
+		queryOpts := db.mergeOptions(query)
+		if err := validateQueryOptions(queryOpts); err != nil {
+			return nil, err
+		}
+		un, err := useNumberOption(queryOpts)
+		if err != nil {
+			return nil, err
+		}
+		useNumber = useNumber || un
+		opts[i] = driverOptions(queryOpts)
+	}
+	ctx, op := db.startOp(ctx, "QueryMulti", "_design/"+ddoc+"/_view/"+view+"/queries")
+	var rowsi driver.Rows
+	if mq, ok := db.driverDB.(driver.MultiQuerier); ok {
+		var err error
+		rowsi, err = mq.QueryMulti(ctx, ddoc, view, opts)
+		if err != nil {
+			op.done()
+			return nil, opError("QueryMulti", err)
+		}
+	} else {
+		mqRows := &multiQueryRows{ctx: ctx, db: db.driverDB, ddoc: ddoc, view: view, queries: opts}
+		if len(opts) > 0 {
+			if err := mqRows.query(); err != nil {
+				op.done()
+				return nil, err
+			}
+		}
+		rowsi = mqRows
+	}
+	rows := newRowsOpts(ctx, rowsi, useNumber)
+	db.measure(rows.iter, "QueryMulti", "_design/"+ddoc+"/_view/"+view+"/queries")
+	op.doneOnClose(rows.iter)
+	return rows, nil
+}
+
+// multiQueryRows emulates a multi-query request by executing each query in
+// turn, once the previous query's results have been read.
+type multiQueryRows struct {
+	ctx        context.Context
+	db         driver.DB
+	ddoc, view string
+	queries    []map[string]interface{}
+	index      int
+	current    driver.Rows
+	// eoq is true when the current query's results have been exhausted
+	eoq bool
+}
+
+var (
+	_ driver.Rows         = &multiQueryRows{}
+	_ driver.QueryIndexer = &multiQueryRows{}
+)
+
+func (r *multiQueryRows) query() error {
+	rows, err := r.db.Query(r.ctx, r.ddoc, r.view, r.queries[r.index])
+	if err != nil {
+		return opError("Query", err)
+	}
+	r.current = rows
+	return nil
+}
+
+func (r *multiQueryRows) Next(row *driver.Row) error {
+	if r.current == nil {
+		return io.EOF
+	}
+	if r.eoq {
+		if err := r.current.Close(); err != nil {
+			return err
+		}
+		r.index++
+		r.eoq = false
+		if err := r.query(); err != nil {
+			r.current = nil
+			return err
+		}
+	}
+	err := r.current.Next(row)
+	if err != io.EOF || r.index == len(r.queries)-1 {
+		return err
+	}
+	r.eoq = true
+	return driver.EOQ
+}
+
+func (r *multiQueryRows) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	return r.current.Close()
+}
+
+func (r *multiQueryRows) QueryIndex() int {
+	return r.index
+}
+
+func (r *multiQueryRows) Offset() int64 {
+	if r.current == nil {
+		return 0
+	}
+	return r.current.Offset()
+}
+
+func (r *multiQueryRows) TotalRows() int64 {
+	if r.current == nil {
+		return 0
+	}
+	return r.current.TotalRows()
+}
+
+func (r *multiQueryRows) UpdateSeq() string {
+	if r.current == nil {
+		return ""
+	}
+	return r.current.UpdateSeq()
+}
@@ -0,0 +1,158 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// keyRows returns driver rows with one row per key, each with ID key.
+func keyRows(keys ...string) driver.Rows {
+	var i int
+	return &mock.Rows{
+		NextFunc: func(row *driver.Row) error {
+			if i == len(keys) {
+				return io.EOF
+			}
+			row.ID = keys[i]
+			i++
+			return nil
+		},
+		CloseFunc:     func() error { return nil },
+		TotalRowsFunc: func() int64 { return int64(len(keys)) },
+	}
+}
+
+// queryMultiResults returns the rows read from rows, rendered as
+// "index:id", or "index:EOQ" at the end of each query.
+func queryMultiResults(rows *Rows) []string {
+	var results []string
+	for rows.Next() {
+		if rows.EOQ() {
+			results = append(results, fmt.Sprintf("%d:EOQ", rows.QueryIndex()))
+			continue
+		}
+		results = append(results, fmt.Sprintf("%d:%s", rows.QueryIndex(), rows.ID()))
+	}
+	return results
+}
+
+func TestQueryMulti(t *testing.T) {
+	type tt struct {
+		db       *DB
+		queries  []Options
+		status   int
+		err      string
+		results  []string
+		rowsErr  string
+		rowsStat int
+	}
+	emulated := &mock.DB{
+		QueryFunc: func(_ context.Context, ddoc, view string, opts map[string]interface{}) (driver.Rows, error) {
+			if ddoc != "foo" || view != "bar" {
+				return nil, fmt.Errorf("Unexpected view: %s/%s", ddoc, view)
+			}
+			switch opts["key"] {
+			case "fail":
+				return nil, &Error{HTTPStatus: http.StatusBadRequest, Err: errors.New("bad key")}
+			case "none":
+				return keyRows(), nil
+			}
+			return keyRows(opts["key"].(string), opts["key"].(string)+"2"), nil
+		},
+	}
+
+	tests := testy.NewTable()
+	tests.Add("db error", tt{
+		db:     &DB{err: errors.New("db error")},
+		status: http.StatusInternalServerError,
+		err:    "db error",
+	})
+	tests.Add("invalid query", tt{
+		db:      &DB{driverDB: emulated},
+		queries: []Options{{}, {"reduce": false, "group": true}},
+		status:  http.StatusBadRequest,
+		err:     "kivik: group and group_level are invalid when reduce=false",
+	})
+	tests.Add("native", tt{
+		db: &DB{
+			driverDB: &mock.MultiQuerier{
+				QueryMultiFunc: func(_ context.Context, ddoc, view string, queries []map[string]interface{}) (driver.Rows, error) {
+					expected := []map[string]interface{}{
+						{"key": "a", "reduce": false},
+						{"key": "b", "reduce": false},
+					}
+					if d := testy.DiffInterface(expected, queries); d != nil {
+						return nil, fmt.Errorf("Unexpected queries: %s", d)
+					}
+					return keyRows("a", "b"), nil
+				},
+			},
+			options: Options{"reduce": false},
+		},
+		queries: []Options{{"key": "a"}, {"key": "b"}},
+		results: []string{"0:a", "0:b"},
+	})
+	tests.Add("native error", tt{
+		db: &DB{
+			driverDB: &mock.MultiQuerier{
+				QueryMultiFunc: func(context.Context, string, string, []map[string]interface{}) (driver.Rows, error) {
+					return nil, &Error{HTTPStatus: http.StatusNotFound, Err: errors.New("missing")}
+				},
+			},
+		},
+		status: http.StatusNotFound,
+		err:    "missing",
+	})
+	tests.Add("emulated", tt{
+		db:      &DB{driverDB: emulated},
+		queries: []Options{{"key": "a"}, {"key": "none"}, {"key": "b"}},
+		results: []string{"0:a", "0:a2", "0:EOQ", "1:EOQ", "2:b", "2:b2"},
+	})
+	tests.Add("emulated, no queries", tt{
+		db: &DB{driverDB: emulated},
+	})
+	tests.Add("emulated, first query fails", tt{
+		db:      &DB{driverDB: emulated},
+		queries: []Options{{"key": "fail"}, {"key": "a"}},
+		status:  http.StatusBadRequest,
+		err:     "bad key",
+	})
+	tests.Add("emulated, later query fails", tt{
+		db:       &DB{driverDB: emulated},
+		queries:  []Options{{"key": "a"}, {"key": "fail"}},
+		results:  []string{"0:a", "0:a2", "0:EOQ"},
+		rowsStat: http.StatusBadRequest,
+		rowsErr:  "bad key",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		rows, err := tt.db.QueryMulti(context.Background(), "_design/foo", "bar", tt.queries)
+		testy.StatusError(t, tt.err, tt.status, err)
+		results := queryMultiResults(rows)
+		if d := testy.DiffInterface(tt.results, results); d != nil {
+			t.Error(d)
+		}
+		testy.StatusError(t, tt.rowsErr, tt.rowsStat, rows.Err())
+	})
+}
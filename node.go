@@ -0,0 +1,141 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// NodeConfig provides access to the configuration and statistics of a single
+// cluster node, as returned by ConfigNode.
+type NodeConfig struct {
+	client *Client
+	node   string
+}
+
+// ConfigNode returns accessors for the configuration and statistics of node.
+// The special node name "_local" refers to the node handling each request.
+func (c *Client) ConfigNode(node string) *NodeConfig {
+	return &NodeConfig{client: c, node: node}
+}
+
+// Node returns the name of the node.
+func (n *NodeConfig) Node() string {
+	return n.node
+}
+
+// Config returns the node's entire config. See Client.Config.
+func (n *NodeConfig) Config(ctx context.Context) (Config, error) {
+	return n.client.Config(ctx, n.node)
+}
+
+// Section returns a section of the node's config. See Client.ConfigSection.
+func (n *NodeConfig) Section(ctx context.Context, section string) (ConfigSection, error) {
+	return n.client.ConfigSection(ctx, n.node, section)
+}
+
+// Value returns a single config value of the node. See Client.ConfigValue.
+func (n *NodeConfig) Value(ctx context.Context, section, key string) (string, error) {
+	return n.client.ConfigValue(ctx, n.node, section, key)
+}
+
+// Set sets a config value on the node, returning the old value. See
+// Client.SetConfigValue.
+func (n *NodeConfig) Set(ctx context.Context, section, key, value string) (string, error) {
+	return n.client.SetConfigValue(ctx, n.node, section, key, value)
+}
+
+// Delete deletes a config key from the node, returning the old value. See
+// Client.DeleteConfigKey.
+func (n *NodeConfig) Delete(ctx context.Context, section, key string) (string, error) {
+	return n.client.DeleteConfigKey(ctx, n.node, section, key)
+}
+
+// Stats returns the node's statistics. See Client.NodeStats.
+func (n *NodeConfig) Stats(ctx context.Context, path ...string) (NodeStats, error) {
+	return n.client.NodeStats(ctx, n.node, path...)
+}
+
+// StatsMetric is a single metric reported by a node.
+//
+// See https://docs.couchdb.org/en/stable/api/server/common.html#node-node-name-stats
+type StatsMetric struct {
+	// Value is the raw JSON value of the metric. For counters and gauges,
+	// this is a number; for histograms, an object.
+	Value json.RawMessage `json:"value"`
+	// Type is the metric type, such as "counter", "gauge" or "histogram".
+	Type string `json:"type"`
+	// Desc describes the metric.
+	Desc string `json:"desc"`
+}
+
+// NodeStats holds the metrics reported by a node, keyed by their path, joined
+// with "/", relative to the path requested. For example, the key
+// "couchdb/request_time" when all statistics are requested, or "request_time"
+// when the "couchdb" section is requested.
+type NodeStats map[string]*StatsMetric
+
+var nodeStatsNotImplemented = &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: driver does not support node statistics"}
+
+// NodeStats returns the statistics of node. If path is given, only the
+// statistics under that path, such as "couchdb" or "couchdb", "request_time",
+// are returned. If path names a single metric, it is returned with the key "".
+//
+// See https://docs.couchdb.org/en/stable/api/server/common.html#node-node-name-stats
+func (c *Client) NodeStats(ctx context.Context, node string, path ...string) (NodeStats, error) {
+	statser, ok := c.driverClient.(driver.NodeStatser)
+	if !ok {
+		return nil, nodeStatsNotImplemented
+	}
+	if node == "" {
+		return nil, missingArg("node")
+	}
+	raw, err := statser.NodeStats(ctx, node, path...)
+	if err != nil {
+		return nil, opError("NodeStats", err)
+	}
+	stats := NodeStats{}
+	if err := stats.add(nil, raw); err != nil {
+		return nil, &Error{HTTPStatus: http.StatusBadGateway, Err: err}
+	}
+	return stats, nil
+}
+
+// add adds the metrics in raw, found at path, to s.
+func (s NodeStats) add(path []string, raw json.RawMessage) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return err
+	}
+	if _, ok := fields["value"]; ok {
+		if _, ok := fields["type"]; ok {
+			metric := &StatsMetric{}
+			if err := json.Unmarshal(raw, metric); err != nil {
+				return err
+			}
+			s[strings.Join(path, "/")] = metric
+			return nil
+		}
+	}
+	for name, field := range fields {
+		if err := s.add(append(path[:len(path):len(path)], name), field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestNodeStats(t *testing.T) {
+	type tt struct {
+		client   *Client
+		node     string
+		path     []string
+		expected NodeStats
+		status   int
+		err      string
+	}
+	statser := func(body string) *mock.NodeStatser {
+		return &mock.NodeStatser{
+			NodeStatsFunc: func(_ context.Context, node string, path ...string) (json.RawMessage, error) {
+				if node != "node1" {
+					return nil, fmt.Errorf("Unexpected node: %s", node)
+				}
+				return json.RawMessage(body), nil
+			},
+		}
+	}
+	tests := testy.NewTable()
+	tests.Add("not implemented", tt{
+		client: &Client{driverClient: &mock.Client{}},
+		node:   "node1",
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support node statistics",
+	})
+	tests.Add("missing node", tt{
+		client: &Client{driverClient: statser(`{}`)},
+		status: http.StatusBadRequest,
+		err:    "kivik: node required",
+	})
+	tests.Add("driver error", tt{
+		client: &Client{driverClient: &mock.NodeStatser{
+			NodeStatsFunc: func(context.Context, string, ...string) (json.RawMessage, error) {
+				return nil, errors.New("stats failed")
+			},
+		}},
+		node:   "node1",
+		status: http.StatusInternalServerError,
+		err:    "stats failed",
+	})
+	tests.Add("invalid response", tt{
+		client: &Client{driverClient: statser(`invalid`)},
+		node:   "node1",
+		status: http.StatusBadGateway,
+		err:    "invalid character 'i' looking for beginning of value",
+	})
+	tests.Add("all", tt{
+		client: &Client{driverClient: statser(`{
+			"couchdb": {
+				"open_databases": {"value": 3, "type": "counter", "desc": "open dbs"},
+				"httpd": {
+					"requests": {"value": 10, "type": "counter", "desc": "requests"}
+				}
+			},
+			"pread": {
+				"exceed_limit": {"value": 0, "type": "counter", "desc": "limits"}
+			}
+		}`)},
+		node: "node1",
+		expected: NodeStats{
+			"couchdb/open_databases": {Value: json.RawMessage("3"), Type: "counter", Desc: "open dbs"},
+			"couchdb/httpd/requests": {Value: json.RawMessage("10"), Type: "counter", Desc: "requests"},
+			"pread/exceed_limit":     {Value: json.RawMessage("0"), Type: "counter", Desc: "limits"},
+		},
+	})
+	tests.Add("single metric", tt{
+		client: &Client{driverClient: statser(`{"value": {"min": 1, "max": 5}, "type": "histogram", "desc": "time"}`)},
+		node:   "node1",
+		path:   []string{"couchdb", "request_time"},
+		expected: NodeStats{
+			"": {Value: json.RawMessage(`{"min": 1, "max": 5}`), Type: "histogram", Desc: "time"},
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		stats, err := tt.client.NodeStats(context.Background(), tt.node, tt.path...)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, stats); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestConfigNode(t *testing.T) {
+	var calls []string
+	client := &Client{driverClient: &mock.Configer{
+		ConfigFunc: func(_ context.Context, node string) (driver.Config, error) {
+			calls = append(calls, "Config "+node)
+			return driver.Config{"a": {"b": "c"}}, nil
+		},
+		ConfigSectionFunc: func(_ context.Context, node, section string) (driver.ConfigSection, error) {
+			calls = append(calls, "ConfigSection "+node+" "+section)
+			return driver.ConfigSection{"b": "c"}, nil
+		},
+		ConfigValueFunc: func(_ context.Context, node, section, key string) (string, error) {
+			calls = append(calls, "ConfigValue "+node+" "+section+" "+key)
+			return "c", nil
+		},
+		SetConfigValueFunc: func(_ context.Context, node, section, key, value string) (string, error) {
+			calls = append(calls, "SetConfigValue "+node+" "+section+" "+key+" "+value)
+			return "c", nil
+		},
+		DeleteConfigKeyFunc: func(_ context.Context, node, section, key string) (string, error) {
+			calls = append(calls, "DeleteConfigKey "+node+" "+section+" "+key)
+			return "d", nil
+		},
+	}}
+	ctx := context.Background()
+	node := client.ConfigNode("node1")
+	if node.Node() != "node1" {
+		t.Errorf("Unexpected node: %s", node.Node())
+	}
+	if _, err := node.Config(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.Section(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.Value(ctx, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.Set(ctx, "a", "b", "d"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.Delete(ctx, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := node.Stats(ctx)
+	testy.StatusError(t, "kivik: driver does not support node statistics", http.StatusNotImplemented, err)
+	expected := []string{
+		"Config node1",
+		"ConfigSection node1 a",
+		"ConfigValue node1 a b",
+		"SetConfigValue node1 a b d",
+		"DeleteConfigKey node1 a b",
+	}
+	if d := testy.DiffInterface(expected, calls); d != nil {
+		t.Error(d)
+	}
+}
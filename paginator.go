@@ -0,0 +1,219 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// Paginator pages through the results of a view query or a Mango query,
+// handling CouchDB's pagination semantics.
+//
+// For views, each page is requested with limit set to one more than the page
+// size. The extra row, if returned, is not included in the page, but gives
+// the startkey and startkey_docid for the next page, so that no row is
+// repeated or skipped at page boundaries, even when keys are duplicated.
+//
+// For Mango queries, the bookmark returned with each page is used to request
+// the next.
+type Paginator struct {
+	db       *DB
+	pageSize int
+	err      error
+
+	// For views
+	ddoc, view string
+	options    Options
+	nextKey    json.RawMessage
+	nextDocID  string
+
+	// For Mango queries
+	query    map[string]interface{}
+	bookmark string
+
+	started   bool
+	more      bool
+	totalRows int64
+}
+
+// QueryPaginator returns a Paginator over the results of the view, pageSize
+// rows at a time. options are as for Query, except that limit is managed by
+// the Paginator, and skip is applied only to the first page.
+func (db *DB) QueryPaginator(ddoc, view string, pageSize int, options ...Options) *Paginator {
+	p := &Paginator{
+		db:       db,
+		pageSize: pageSize,
+		ddoc:     ddoc,
+		view:     view,
+		options:  db.mergeOptions(options...),
+	}
+	p.validate()
+	return p
+}
+
+// FindPaginator returns a Paginator over the results of the Mango query,
+// pageSize documents at a time. Any limit or bookmark in query is managed by
+// the Paginator, and skip is applied only to the first page.
+func (db *DB) FindPaginator(query interface{}, pageSize int) *Paginator {
+	p := &Paginator{
+		db:       db,
+		pageSize: pageSize,
+	}
+	if p.validate(); p.err != nil {
+		return p
+	}
+	raw, err := json.Marshal(query)
+	switch t := query.(type) {
+	case string:
+		raw = []byte(t)
+	case []byte:
+		raw, err = t, nil
+	case json.RawMessage:
+		raw, err = t, nil
+	}
+	if err == nil {
+		err = json.Unmarshal(raw, &p.query)
+	}
+	if err != nil {
+		p.err = &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	return p
+}
+
+func (p *Paginator) validate() {
+	if p.pageSize < 1 {
+		p.err = &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: page size must be positive"}
+	}
+}
+
+// HasMore returns true if another page may be available. It returns true
+// before the first page has been fetched, and false once an error has
+// occurred.
+//
+// For Mango queries, the end of the results can only be detected when a page
+// is short, so HasMore may return true when the next page will be empty.
+func (p *Paginator) HasMore() bool {
+	return p.err == nil && (!p.started || p.more)
+}
+
+// TotalPages returns an estimate of the number of pages, based on the
+// total_rows reported by the view. It returns -1 before the first page has
+// been fetched, and for Mango queries, which do not report a total. As
+// total_rows counts every row in the view, the estimate does not account
+// for startkey, endkey or key options.
+func (p *Paginator) TotalPages() int {
+	if !p.started || p.query != nil {
+		return -1
+	}
+	return int((p.totalRows + int64(p.pageSize) - 1) / int64(p.pageSize))
+}
+
+// NextPage fetches the next page of results. Once all results have been
+// returned, it returns a status 404 error.
+func (p *Paginator) NextPage(ctx context.Context) (*Rows, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if !p.HasMore() {
+		return nil, &Error{HTTPStatus: http.StatusNotFound, Message: "kivik: no more pages"}
+	}
+	var rows *Rows
+	var err error
+	if p.query != nil {
+		rows, err = p.db.Find(ctx, p.findQuery())
+	} else {
+		rows, err = p.db.Query(ctx, p.ddoc, p.view, p.viewOptions())
+	}
+	if err != nil {
+		p.err = err
+		return nil, err
+	}
+	page, err := p.readPage(rows)
+	if err != nil {
+		p.err = err
+		return nil, err
+	}
+	p.started = true
+	return page, nil
+}
+
+func (p *Paginator) viewOptions() Options {
+	opts := mergeOptions(p.options, Options{"limit": p.pageSize + 1})
+	if p.started {
+		delete(opts, "skip")
+		delete(opts, "start_key")
+		delete(opts, "startkey_docid")
+		delete(opts, "start_key_doc_id")
+		opts["startkey"] = p.nextKey
+		if p.nextDocID != "" {
+			opts["startkey_docid"] = p.nextDocID
+		}
+	}
+	return opts
+}
+
+func (p *Paginator) findQuery() map[string]interface{} {
+	query := make(map[string]interface{}, len(p.query)+2)
+	for k, v := range p.query {
+		query[k] = v
+	}
+	query["limit"] = p.pageSize
+	if p.started {
+		delete(query, "skip")
+		query["bookmark"] = p.bookmark
+	}
+	return query
+}
+
+// readPage buffers the rows of a page, recording the state needed to request
+// the next.
+func (p *Paginator) readPage(rows *Rows) (*Rows, error) {
+	defer rows.Close() // nolint: errcheck
+	page := make([]driver.Row, 0, p.pageSize)
+	p.more = false
+	for rows.Next() {
+		if rows.EOQ() {
+			continue
+		}
+		row, err := bufferRow(rows.curVal.(*driver.Row))
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == p.pageSize {
+			// The extra row marks the start of the next page.
+			p.more = true
+			p.nextKey, p.nextDocID = row.Key, row.ID
+			break
+		}
+		page = append(page, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if p.query != nil {
+		p.bookmark = rows.Bookmark()
+		p.more = len(page) == p.pageSize && p.bookmark != ""
+	} else {
+		p.totalRows = rows.TotalRows()
+	}
+	batch := &batchRows{Rows: rows.rowsi, rows: page}
+	return &Rows{
+		iter:      newIterator(context.Background(), &rowsIterator{batch}, &driver.Row{}),
+		rowsi:     rows.rowsi,
+		useNumber: rows.useNumber,
+	}, nil
+}
@@ -0,0 +1,183 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+func init() {
+	// Every document emits the same key, so that pages can only be divided
+	// correctly with startkey_docid.
+	memorydb.RegisterView("paginator", "same", memorydb.View{
+		Map: func(_ map[string]interface{}, emit func(key, value interface{})) {
+			emit("same", nil)
+		},
+	})
+}
+
+// paginatorDB returns a database with n documents, "doc00" through n-1.
+func paginatorDB(t *testing.T, n int) *kivik.DB {
+	t.Helper()
+	ctx := context.Background()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB(ctx, "test")
+	for i := 0; i < n; i++ {
+		if _, err := db.Put(ctx, fmt.Sprintf("doc%02d", i), map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// pages reads every page from p, returning the IDs on each.
+func pages(t *testing.T, p *kivik.Paginator) [][]string {
+	t.Helper()
+	var result [][]string
+	for p.HasMore() {
+		rows, err := p.NextPage(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for rows.Next() {
+			ids = append(ids, rows.ID())
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, ids)
+	}
+	return result
+}
+
+func TestQueryPaginator(t *testing.T) {
+	db := paginatorDB(t, 5)
+	p := db.QueryPaginator("paginator", "same", 2)
+	if total := p.TotalPages(); total != -1 {
+		t.Errorf("Unexpected total pages before first page: %d", total)
+	}
+	expected := [][]string{{"doc00", "doc01"}, {"doc02", "doc03"}, {"doc04"}}
+	if d := testy.DiffInterface(expected, pages(t, p)); d != nil {
+		t.Error(d)
+	}
+	if total := p.TotalPages(); total != 3 {
+		t.Errorf("Unexpected total pages: %d", total)
+	}
+	_, err := p.NextPage(context.Background())
+	testy.StatusError(t, "kivik: no more pages", http.StatusNotFound, err)
+}
+
+func TestQueryPaginatorExactPages(t *testing.T) {
+	db := paginatorDB(t, 4)
+	p := db.QueryPaginator("paginator", "same", 2, kivik.Options{"skip": 1})
+	expected := [][]string{{"doc01", "doc02"}, {"doc03"}}
+	if d := testy.DiffInterface(expected, pages(t, p)); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestFindPaginator(t *testing.T) {
+	db := paginatorDB(t, 5)
+	p := db.FindPaginator(`{"selector":{"n":{"$gte":1}},"limit":100}`, 2)
+	expected := [][]string{{"doc01", "doc02"}, {"doc03", "doc04"}, nil}
+	if d := testy.DiffInterface(expected, pages(t, p)); d != nil {
+		t.Error(d)
+	}
+	if total := p.TotalPages(); total != -1 {
+		t.Errorf("Unexpected total pages: %d", total)
+	}
+}
+
+func TestPaginatorUseNumber(t *testing.T) {
+	ctx := context.Background()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB(ctx, "test").WithOptions(kivik.Options{kivik.OptionUseNumber: true})
+	if _, err := db.Put(ctx, "doc", json.RawMessage(`{"n":12345678901234567890}`)); err != nil {
+		t.Fatal(err)
+	}
+	p := db.FindPaginator(map[string]interface{}{"selector": map[string]interface{}{}}, 2)
+	rows, err := p.NextPage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	var doc map[string]interface{}
+	if err := rows.ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["n"] != json.Number("12345678901234567890") {
+		t.Errorf("Unexpected value: %v (%T)", doc["n"], doc["n"])
+	}
+}
+
+func TestPaginatorErrors(t *testing.T) {
+	db := paginatorDB(t, 0)
+	tests := []struct {
+		name   string
+		p      *kivik.Paginator
+		status int
+		err    string
+	}{
+		{
+			name:   "invalid page size",
+			p:      db.QueryPaginator("paginator", "same", 0),
+			status: http.StatusBadRequest,
+			err:    "kivik: page size must be positive",
+		},
+		{
+			name:   "invalid query",
+			p:      db.FindPaginator("invalid", 10),
+			status: http.StatusBadRequest,
+			err:    "invalid character 'i' looking for beginning of value",
+		},
+		{
+			name:   "missing view",
+			p:      db.QueryPaginator("paginator", "missing", 10),
+			status: http.StatusNotFound,
+			err:    "missing named view paginator/missing",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.p.NextPage(context.Background())
+			testy.StatusError(t, test.err, test.status, err)
+			if test.p.HasMore() {
+				t.Error("HasMore should be false after an error")
+			}
+		})
+	}
+}
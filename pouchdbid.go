@@ -0,0 +1,236 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
+)
+
+// PouchDBReplicationID returns the ID of the checkpoint document, such as
+// "_local/abc...", which PouchDB records for a replication from the database
+// identified by sourceID to the one identified by targetID. Replications
+// which record their checkpoints under this ID can resume from, and be
+// resumed by, replications run with PouchDB.
+//
+// CouchDB's replicator derives its replication IDs differently, from its own
+// UUID and internal representation of the endpoints, so the ID returned does
+// not match the checkpoints of replications run by CouchDB.
+//
+// The filter, view, query_params, doc_ids and selector options contribute to
+// the ID, as they do for PouchDB; other options are ignored. The keys of
+// query_params are sorted, as PouchDB sorts them, so their order does not
+// matter, even when given as raw JSON. PouchDB hashes
+// the selector as written by the application, so to reproduce its ID, the
+// selector should be given as raw JSON, with the keys in the same order.
+//
+// Use DB.PouchDBEndpointID to identify a remote database.
+func PouchDBReplicationID(sourceID, targetID string, options ...Options) (string, error) {
+	opts := mergeOptions(options...)
+	var data strings.Builder
+	data.WriteString(sourceID)
+	data.WriteString(targetID)
+	filter, _ := opts["filter"].(string)
+	data.WriteString(filter)
+	if filter == "_view" {
+		view, _ := opts["view"].(string)
+		data.WriteString(view)
+	}
+	if params, ok := opts["query_params"]; ok && filter != "" {
+		raw, err := stringifySorted(params)
+		if err != nil {
+			return "", err
+		}
+		data.Write(raw)
+	}
+	docIDs, err := queryopts.Strings(opts, "doc_ids")
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(docIDs, func(i, j int) bool {
+		return lessUTF16(docIDs[i], docIDs[j])
+	})
+	data.WriteString(strings.Join(docIDs, ","))
+	if selector, ok := opts["selector"]; ok {
+		raw, err := stringifyJSON(selector)
+		if err != nil {
+			return "", err
+		}
+		data.Write(raw)
+	}
+	sum := md5.Sum([]byte(data.String()))
+	id := base64.StdEncoding.EncodeToString(sum[:])
+	return "_local/" + strings.NewReplacer("/", ".", "+", "_").Replace(id), nil
+}
+
+// stringifyJSON returns the compact JSON encoding of v, as JavaScript's
+// JSON.stringify would produce it. Raw JSON is compacted, but otherwise used
+// as is.
+func stringifyJSON(v interface{}) ([]byte, error) {
+	var raw []byte
+	switch t := v.(type) {
+	case json.RawMessage:
+		raw = t
+	case []byte:
+		raw = t
+	default:
+		buf := &bytes.Buffer{}
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return nil, &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+		}
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+	}
+	buf := &bytes.Buffer{}
+	if err := json.Compact(buf, raw); err != nil {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	return buf.Bytes(), nil
+}
+
+// stringifySorted returns the compact JSON encoding of v, as stringifyJSON,
+// but with the keys of every object in sorted order.
+func stringifySorted(v interface{}) ([]byte, error) {
+	raw, err := stringifyJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var x interface{}
+	if err := dec.Decode(&x); err != nil {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	buf := &bytes.Buffer{}
+	if err := writeSorted(buf, x); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeSorted(buf *bytes.Buffer, v interface{}) error {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessObjectKey(keys[i], keys[j])
+		})
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeSorted(buf, k); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeSorted(buf, t[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, elem := range t {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeSorted(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		raw, err := stringifyJSON(t)
+		if err != nil {
+			return err
+		}
+		buf.Write(raw)
+	}
+	return nil
+}
+
+// lessObjectKey orders object keys as JavaScript serializes them: keys which
+// are array indexes come first, in numeric order, followed by the rest,
+// compared by lessUTF16.
+func lessObjectKey(a, b string) bool {
+	x, xok := arrayIndex(a)
+	y, yok := arrayIndex(b)
+	switch {
+	case xok && yok:
+		return x < y
+	case xok || yok:
+		return xok
+	}
+	return lessUTF16(a, b)
+}
+
+// arrayIndex returns the value of key, if it is the canonical form of a
+// JavaScript array index.
+func arrayIndex(key string) (uint64, bool) {
+	n, err := strconv.ParseUint(key, 10, 32)
+	if err != nil || n == math.MaxUint32 || strconv.FormatUint(n, 10) != key {
+		return 0, false
+	}
+	return n, true
+}
+
+// lessUTF16 compares strings by their UTF-16 code units, as JavaScript does.
+func lessUTF16(a, b string) bool {
+	x, y := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(x) && i < len(y); i++ {
+		if x[i] != y[i] {
+			return x[i] < y[i]
+		}
+	}
+	return len(x) < len(y)
+}
+
+// PouchDBEndpointID returns the identifier by which PouchDB knows db, as a
+// remote database, for use with PouchDBReplicationID: the UUID of the server
+// followed by the database name or, if the server reports no UUID, the URL of
+// the database, without credentials. As for PouchDB, an error reading the
+// server's UUID is not reported, but causes the URL to be used.
+func (db *DB) PouchDBEndpointID(ctx context.Context) (string, error) {
+	if db.err != nil {
+		return "", db.err
+	}
+	info, err := db.client.ServerInfo(ctx)
+	if err == nil && info.UUID != "" {
+		return info.UUID + db.name, nil
+	}
+	u, err := url.Parse(db.client.DSN())
+	if err != nil {
+		return "", &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return strings.TrimSuffix(u.String(), "/") + "/" + url.PathEscape(db.name) + "/", nil
+}
@@ -0,0 +1,58 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// Priority is a hint of the importance of a request, relative to the
+// client's other requests. The zero value is PriorityNormal.
+type Priority int
+
+// Request priorities.
+const (
+	// PriorityLow is for background work, such as batch jobs, which should
+	// not delay other requests.
+	PriorityLow = Priority(driver.PriorityLow)
+	// PriorityNormal is the default.
+	PriorityNormal = Priority(driver.PriorityNormal)
+	// PriorityHigh is for latency-sensitive requests, such as reads which
+	// serve a user waiting for a response.
+	PriorityHigh = Priority(driver.PriorityHigh)
+)
+
+func (p Priority) String() string {
+	return driver.Priority(p).String()
+}
+
+// WithPriority returns a copy of ctx which gives the requests made with it
+// priority p. Drivers which limit concurrent requests admit waiting requests
+// in order of priority, so that, for example, a batch job run with
+// PriorityLow cannot starve foreground reads made through the same client.
+// Drivers may also pass the priority on to a proxy, in the
+// driver.PriorityHeader request header. It is only a hint: drivers which do
+// neither ignore it.
+//
+// The priority is reported by InFlight, in Operation.Priority.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return driver.WithPriority(ctx, driver.Priority(p))
+}
+
+// PriorityFromContext returns the priority given to ctx by WithPriority, or
+// PriorityNormal.
+func PriorityFromContext(ctx context.Context) Priority {
+	return Priority(driver.PriorityFromContext(ctx))
+}
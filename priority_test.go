@@ -0,0 +1,71 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestWithPriority(t *testing.T) {
+	var got driver.Priority
+	var ops []*Operation
+	client := &Client{}
+	client.driverClient = &mock.Client{
+		DBFunc: func(context.Context, string, map[string]interface{}) (driver.DB, error) {
+			return &mock.DB{
+				AllDocsFunc: func(ctx context.Context, _ map[string]interface{}) (driver.Rows, error) {
+					got = driver.PriorityFromContext(ctx)
+					ops = client.InFlight()
+					return &mock.Rows{
+						NextFunc:  func(*driver.Row) error { return io.EOF },
+						CloseFunc: func() error { return nil },
+					}, nil
+				},
+			}, nil
+		},
+	}
+	db := client.DB(context.Background(), "db")
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected Priority
+	}{
+		{"default", context.Background(), PriorityNormal},
+		{"low", WithPriority(context.Background(), PriorityLow), PriorityLow},
+		{"high", WithPriority(context.Background(), PriorityHigh), PriorityHigh},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := db.AllDocs(tt.ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = rows.Close()
+			if got != driver.Priority(tt.expected) {
+				t.Errorf("Driver got priority %s, expected %s", got, tt.expected)
+			}
+			if PriorityFromContext(tt.ctx) != tt.expected {
+				t.Errorf("Unexpected priority from context: %s", PriorityFromContext(tt.ctx))
+			}
+			if len(ops) != 1 || ops[0].Priority != tt.expected {
+				t.Errorf("Unexpected operations: %+v", ops)
+			}
+		})
+	}
+}
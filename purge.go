@@ -0,0 +1,118 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+const (
+	// maxPurgeDocs is CouchDB's default max_document_id_number, the most
+	// documents accepted by a single purge request.
+	maxPurgeDocs = 100
+	// defaultPurgedInfosLimit is CouchDB's default purged_infos_limit, used
+	// when the driver cannot report the database's own.
+	defaultPurgedInfosLimit = 1000
+)
+
+var purgedInfosLimitNotImplemented = &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: purged_infos_limit not supported by driver"}
+
+// PurgedInfosLimit returns the number of purge requests the database retains,
+// which bounds how many revisions may be purged before indexes and replicas
+// which have not yet seen the purges must be rebuilt.
+//
+// See https://docs.couchdb.org/en/stable/api/database/misc.html#db-purged-infos-limit
+func (db *DB) PurgedInfosLimit(ctx context.Context) (int64, error) {
+	if db.err != nil {
+		return 0, db.err
+	}
+	if limiter, ok := db.driverDB.(driver.PurgedInfosLimiter); ok {
+		limit, err := limiter.PurgedInfosLimit(ctx)
+		return limit, opError("PurgedInfosLimit", err)
+	}
+	return 0, purgedInfosLimitNotImplemented
+}
+
+// SetPurgedInfosLimit sets the number of purge requests the database retains.
+func (db *DB) SetPurgedInfosLimit(ctx context.Context, limit int64) error {
+	if db.err != nil {
+		return db.err
+	}
+	if limit <= 0 {
+		return &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: purged_infos_limit must be positive"}
+	}
+	if limiter, ok := db.driverDB.(driver.PurgedInfosLimiter); ok {
+		return opError("SetPurgedInfosLimit", limiter.SetPurgedInfosLimit(ctx, limit))
+	}
+	return purgedInfosLimitNotImplemented
+}
+
+// purgeRevLimit returns the most revisions to purge in one request.
+func (db *DB) purgeRevLimit(ctx context.Context) (int, error) {
+	limiter, ok := db.driverDB.(driver.PurgedInfosLimiter)
+	if !ok {
+		return defaultPurgedInfosLimit, nil
+	}
+	limit, err := limiter.PurgedInfosLimit(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if limit <= 0 {
+		return defaultPurgedInfosLimit, nil
+	}
+	return int(limit), nil
+}
+
+// purgeBatches splits docRevMap into batches of at most maxDocs documents
+// and maxRevs revisions, in document ID order. The revisions of a document
+// with more than maxRevs are split over several batches.
+func purgeBatches(docRevMap map[string][]string, maxDocs, maxRevs int) []map[string][]string {
+	docIDs := make([]string, 0, len(docRevMap))
+	for docID := range docRevMap {
+		docIDs = append(docIDs, docID)
+	}
+	sort.Strings(docIDs)
+	var batches []map[string][]string
+	batch := map[string][]string{}
+	var revCount int
+	flush := func() {
+		if len(batch) > 0 {
+			batches = append(batches, batch)
+		}
+		batch = map[string][]string{}
+		revCount = 0
+	}
+	for _, docID := range docIDs {
+		revs := docRevMap[docID]
+		for {
+			if len(batch) == maxDocs || (revCount == maxRevs && len(revs) > 0) {
+				flush()
+			}
+			n := maxRevs - revCount
+			if n > len(revs) {
+				n = len(revs)
+			}
+			batch[docID] = append(batch[docID], revs[:n]...)
+			revCount += n
+			if revs = revs[n:]; len(revs) == 0 {
+				break
+			}
+		}
+	}
+	flush()
+	return batches
+}
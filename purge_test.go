@@ -0,0 +1,251 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestPurgeBatches(t *testing.T) {
+	type tt struct {
+		docRevMap        map[string][]string
+		maxDocs, maxRevs int
+		expected         []map[string][]string
+	}
+	tests := testy.NewTable()
+	tests.Add("empty", tt{
+		maxDocs: 2,
+		maxRevs: 2,
+	})
+	tests.Add("single batch", tt{
+		docRevMap: map[string][]string{"a": {"1-a"}, "b": {"1-b"}},
+		maxDocs:   2,
+		maxRevs:   2,
+		expected:  []map[string][]string{{"a": {"1-a"}, "b": {"1-b"}}},
+	})
+	tests.Add("document limit", tt{
+		docRevMap: map[string][]string{"a": {"1-a"}, "b": {"1-b"}, "c": {"1-c"}},
+		maxDocs:   2,
+		maxRevs:   10,
+		expected: []map[string][]string{
+			{"a": {"1-a"}, "b": {"1-b"}},
+			{"c": {"1-c"}},
+		},
+	})
+	tests.Add("revision limit", tt{
+		docRevMap: map[string][]string{"a": {"1-a", "2-a"}, "b": {"1-b", "2-b"}},
+		maxDocs:   10,
+		maxRevs:   3,
+		expected: []map[string][]string{
+			{"a": {"1-a", "2-a"}, "b": {"1-b"}},
+			{"b": {"2-b"}},
+		},
+	})
+	tests.Add("split document", tt{
+		docRevMap: map[string][]string{"a": {"1-a", "2-a", "3-a", "4-a", "5-a"}},
+		maxDocs:   10,
+		maxRevs:   2,
+		expected: []map[string][]string{
+			{"a": {"1-a", "2-a"}},
+			{"a": {"3-a", "4-a"}},
+			{"a": {"5-a"}},
+		},
+	})
+	tests.Add("no revisions", tt{
+		docRevMap: map[string][]string{"a": {}, "b": {"1-b"}},
+		maxDocs:   1,
+		maxRevs:   1,
+		expected: []map[string][]string{
+			{"a": nil},
+			{"b": {"1-b"}},
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		batches := purgeBatches(tt.docRevMap, tt.maxDocs, tt.maxRevs)
+		if d := testy.DiffInterface(tt.expected, batches); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestPurgeBatched(t *testing.T) {
+	docRevMap := map[string][]string{}
+	for i := 0; i < maxPurgeDocs+1; i++ {
+		docRevMap[fmt.Sprintf("doc%03d", i)] = []string{"1-a", "2-b"}
+	}
+	purger := func(requests *[]map[string][]string) *mock.Purger {
+		return &mock.Purger{
+			PurgeFunc: func(_ context.Context, dm map[string][]string) (*driver.PurgeResult, error) {
+				*requests = append(*requests, dm)
+				if _, ok := dm["doc100"]; ok {
+					return nil, errors.New("purge failed")
+				}
+				return &driver.PurgeResult{Seq: int64(len(*requests)), Purged: dm}, nil
+			},
+		}
+	}
+	type tt struct {
+		db       *DB
+		requests []int // Number of documents in each request
+		seq      int64
+		purged   int
+		status   int
+		err      string
+	}
+	var requests []map[string][]string
+	tests := testy.NewTable()
+	tests.Add("default limit", func(t *testing.T) interface{} {
+		requests = nil
+		return tt{
+			db:       &DB{driverDB: purger(&requests)},
+			requests: []int{maxPurgeDocs, 1},
+			seq:      1,
+			purged:   maxPurgeDocs,
+			status:   http.StatusInternalServerError,
+			err:      "purge failed",
+		}
+	})
+	tests.Add("database limit", func(t *testing.T) interface{} {
+		requests = nil
+		return tt{
+			db: &DB{driverDB: &mock.PurgedInfosLimiter{
+				Purger: purger(&requests),
+				PurgedInfosLimitFunc: func(context.Context) (int64, error) {
+					return 60, nil
+				},
+			}},
+			requests: []int{30, 30, 30, 11},
+			seq:      3,
+			purged:   90,
+			status:   http.StatusInternalServerError,
+			err:      "purge failed",
+		}
+	})
+	tests.Add("limit error", func(t *testing.T) interface{} {
+		requests = nil
+		return tt{
+			db: &DB{driverDB: &mock.PurgedInfosLimiter{
+				Purger: purger(&requests),
+				PurgedInfosLimitFunc: func(context.Context) (int64, error) {
+					return 0, &Error{HTTPStatus: http.StatusUnauthorized, Message: "unauthorized"}
+				},
+			}},
+			status: http.StatusUnauthorized,
+			err:    "unauthorized",
+		}
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		result, err := tt.db.Purge(context.Background(), docRevMap)
+		testy.StatusError(t, tt.err, tt.status, err)
+		var sizes []int
+		for _, r := range requests {
+			sizes = append(sizes, len(r))
+		}
+		if d := testy.DiffInterface(tt.requests, sizes); d != nil {
+			t.Errorf("Unexpected requests:\n%s", d)
+		}
+		if tt.requests == nil {
+			return
+		}
+		if result.Seq != tt.seq {
+			t.Errorf("Unexpected seq: %d", result.Seq)
+		}
+		if len(result.Purged) != tt.purged {
+			t.Errorf("Expected %d documents purged, got %d", tt.purged, len(result.Purged))
+		}
+	})
+}
+
+func TestPurgedInfosLimit(t *testing.T) {
+	type tt struct {
+		db       *DB
+		expected int64
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("not supported", tt{
+		db:     &DB{driverDB: &mock.DB{}},
+		status: http.StatusNotImplemented,
+		err:    "kivik: purged_infos_limit not supported by driver",
+	})
+	tests.Add("db error", tt{
+		db:     &DB{err: errors.New("db error")},
+		status: http.StatusInternalServerError,
+		err:    "db error",
+	})
+	tests.Add("success", tt{
+		db: &DB{driverDB: &mock.PurgedInfosLimiter{
+			PurgedInfosLimitFunc: func(context.Context) (int64, error) {
+				return 1000, nil
+			},
+		}},
+		expected: 1000,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		limit, err := tt.db.PurgedInfosLimit(context.Background())
+		testy.StatusError(t, tt.err, tt.status, err)
+		if limit != tt.expected {
+			t.Errorf("Unexpected limit: %d", limit)
+		}
+	})
+}
+
+func TestSetPurgedInfosLimit(t *testing.T) {
+	type tt struct {
+		db     *DB
+		limit  int64
+		status int
+		err    string
+	}
+	tests := testy.NewTable()
+	tests.Add("not supported", tt{
+		db:     &DB{driverDB: &mock.DB{}},
+		limit:  10,
+		status: http.StatusNotImplemented,
+		err:    "kivik: purged_infos_limit not supported by driver",
+	})
+	tests.Add("invalid limit", tt{
+		db:     &DB{driverDB: &mock.DB{}},
+		status: http.StatusBadRequest,
+		err:    "kivik: purged_infos_limit must be positive",
+	})
+	tests.Add("success", tt{
+		db: &DB{driverDB: &mock.PurgedInfosLimiter{
+			SetPurgedInfosLimitFunc: func(_ context.Context, limit int64) error {
+				if limit != 10 {
+					return fmt.Errorf("Unexpected limit: %d", limit)
+				}
+				return nil
+			},
+		}},
+		limit: 10,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		err := tt.db.SetPurgedInfosLimit(context.Background(), tt.limit)
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
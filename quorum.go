@@ -0,0 +1,123 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
+)
+
+// OptionRequireQuorum, when true, causes Put, CreateDoc, Delete,
+// PutAttachment and DeleteAttachment to return an *AcceptedError if the
+// server accepted the write without confirming that it was committed to a
+// quorum of nodes, and BulkDocs to report such a write as the UpdateErr of
+// its result. Without it, such writes succeed as normal. The option is
+// interpreted by Kivik, and not passed to the driver.
+//
+// Only drivers which implement driver.QuorumWriter report whether writes
+// were committed to a quorum; with any other driver, every successful write
+// is taken to have been.
+const OptionRequireQuorum = "kivik.require_quorum"
+
+// AcceptedError reports a write which the server accepted, with status 202,
+// but did not confirm was committed to a quorum of nodes, as may happen
+// while a cluster is degraded. It is only returned with OptionRequireQuorum.
+//
+// The write has been stored, on at least one node, and the new revision is
+// returned alongside the error, as well as in Rev. It may yet be lost if
+// those nodes fail before it is copied to the others.
+type AcceptedError struct {
+	// Op is the method which made the write, such as "Put".
+	Op string
+	// DocID is the ID of the document written.
+	DocID string
+	// Rev is the new revision of the document.
+	Rev string
+}
+
+var (
+	_ error       = &AcceptedError{}
+	_ statusCoder = &AcceptedError{}
+)
+
+func (e *AcceptedError) Error() string {
+	return fmt.Sprintf("kivik: %s of %q accepted without quorum", e.Op, e.DocID)
+}
+
+// StatusCode returns 202 (accepted).
+func (e *AcceptedError) StatusCode() int {
+	return http.StatusAccepted
+}
+
+// requireQuorumOption returns the value of OptionRequireQuorum. Like other
+// kivik.* options, it is never passed to the driver.
+func requireQuorumOption(opts Options) (bool, error) {
+	return queryopts.Bool(opts, OptionRequireQuorum)
+}
+
+// acceptedError returns an *AcceptedError if require is set, and res reports
+// a write accepted without quorum, or nil otherwise.
+func acceptedError(op string, res *driver.WriteResult, require bool) error {
+	if !require || !res.Accepted {
+		return nil
+	}
+	return &AcceptedError{Op: op, DocID: res.ID, Rev: res.Rev}
+}
+
+// The following methods make a write through the driver's QuorumWriter
+// methods, if it is one, or else its plain DB methods, whose writes are
+// reported as committed.
+
+func (db *DB) putResult(ctx context.Context, docID string, doc interface{}, opts map[string]interface{}) (*driver.WriteResult, error) {
+	if w, ok := db.driverDB.(driver.QuorumWriter); ok {
+		return w.PutResult(ctx, docID, doc, driverOptions(opts))
+	}
+	rev, err := db.driverDB.Put(ctx, docID, doc, driverOptions(opts))
+	return &driver.WriteResult{ID: docID, Rev: rev}, err
+}
+
+func (db *DB) createDocResult(ctx context.Context, doc interface{}, opts map[string]interface{}) (*driver.WriteResult, error) {
+	if w, ok := db.driverDB.(driver.QuorumWriter); ok {
+		return w.CreateDocResult(ctx, doc, driverOptions(opts))
+	}
+	docID, rev, err := db.driverDB.CreateDoc(ctx, doc, driverOptions(opts))
+	return &driver.WriteResult{ID: docID, Rev: rev}, err
+}
+
+func (db *DB) deleteResult(ctx context.Context, docID, rev string, opts map[string]interface{}) (*driver.WriteResult, error) {
+	if w, ok := db.driverDB.(driver.QuorumWriter); ok {
+		return w.DeleteResult(ctx, docID, rev, driverOptions(opts))
+	}
+	newRev, err := db.driverDB.Delete(ctx, docID, rev, driverOptions(opts))
+	return &driver.WriteResult{ID: docID, Rev: newRev}, err
+}
+
+func (db *DB) putAttachmentResult(ctx context.Context, docID, rev string, att *driver.Attachment, opts map[string]interface{}) (*driver.WriteResult, error) {
+	if w, ok := db.driverDB.(driver.QuorumWriter); ok {
+		return w.PutAttachmentResult(ctx, docID, rev, att, driverOptions(opts))
+	}
+	newRev, err := db.driverDB.PutAttachment(ctx, docID, rev, att, driverOptions(opts))
+	return &driver.WriteResult{ID: docID, Rev: newRev}, err
+}
+
+func (db *DB) deleteAttachmentResult(ctx context.Context, docID, rev, filename string, opts map[string]interface{}) (*driver.WriteResult, error) {
+	if w, ok := db.driverDB.(driver.QuorumWriter); ok {
+		return w.DeleteAttachmentResult(ctx, docID, rev, filename, driverOptions(opts))
+	}
+	newRev, err := db.driverDB.DeleteAttachment(ctx, docID, rev, filename, driverOptions(opts))
+	return &driver.WriteResult{ID: docID, Rev: newRev}, err
+}
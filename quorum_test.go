@@ -0,0 +1,275 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestRequireQuorum(t *testing.T) {
+	type write func(db *DB, opts Options) (docID, rev string, err error)
+	writes := map[string]write{
+		"Put": func(db *DB, opts Options) (string, string, error) {
+			rev, err := db.Put(context.Background(), "foo", map[string]string{}, opts)
+			return "foo", rev, err
+		},
+		"CreateDoc": func(db *DB, opts Options) (string, string, error) {
+			return db.CreateDoc(context.Background(), map[string]string{}, opts)
+		},
+		"Delete": func(db *DB, opts Options) (string, string, error) {
+			rev, err := db.Delete(context.Background(), "foo", "1-xxx", opts)
+			return "foo", rev, err
+		},
+		"PutAttachment": func(db *DB, opts Options) (string, string, error) {
+			rev, err := db.PutAttachment(context.Background(), "foo", "1-xxx", &Attachment{Filename: "a.txt"}, opts)
+			return "foo", rev, err
+		},
+		"DeleteAttachment": func(db *DB, opts Options) (string, string, error) {
+			rev, err := db.DeleteAttachment(context.Background(), "foo", "1-xxx", "a.txt", opts)
+			return "foo", rev, err
+		},
+	}
+	type tt struct {
+		write write
+		// plain uses a driver which does not implement QuorumWriter.
+		plain    bool
+		accepted bool
+		driver   error
+		options  Options
+		docID    string
+		rev      string
+		expected error
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	for name, w := range writes {
+		name := name
+		tests.Add(name+" committed", tt{
+			write:   w,
+			options: Options{OptionRequireQuorum: true},
+			docID:   "foo",
+			rev:     "2-xxx",
+		})
+		tests.Add(name+" accepted", tt{
+			write:    w,
+			accepted: true,
+			docID:    "foo",
+			rev:      "2-xxx",
+		})
+		tests.Add(name+" accepted, quorum required", tt{
+			write:    w,
+			accepted: true,
+			options:  Options{OptionRequireQuorum: "true"},
+			docID:    "foo",
+			rev:      "2-xxx",
+			expected: &AcceptedError{Op: name, DocID: "foo", Rev: "2-xxx"},
+		})
+		tests.Add(name+" without QuorumWriter", tt{
+			write:   w,
+			plain:   true,
+			options: Options{OptionRequireQuorum: true},
+			docID:   "foo",
+			rev:     "2-xxx",
+		})
+		tests.Add(name+" failure", tt{
+			write:   w,
+			driver:  errors.New("failed"),
+			options: Options{OptionRequireQuorum: true},
+			status:  http.StatusInternalServerError,
+			err:     "failed",
+		})
+		tests.Add(name+" invalid option", tt{
+			write:   w,
+			options: Options{OptionRequireQuorum: 1},
+			status:  http.StatusBadRequest,
+			err:     "invalid value for kivik.require_quorum: 1",
+		})
+	}
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		result := func(opts map[string]interface{}) (*driver.WriteResult, error) {
+			if _, ok := opts[OptionRequireQuorum]; ok {
+				return nil, errors.New("option passed to driver")
+			}
+			if tt.driver != nil {
+				return nil, tt.driver
+			}
+			return &driver.WriteResult{ID: "foo", Rev: "2-xxx", Accepted: tt.accepted}, nil
+		}
+		revOnly := func(opts map[string]interface{}) (string, error) {
+			res, err := result(opts)
+			if err != nil {
+				return "", err
+			}
+			return res.Rev, nil
+		}
+		plain := &mock.DB{
+			PutFunc: func(_ context.Context, _ string, _ interface{}, opts map[string]interface{}) (string, error) {
+				return revOnly(opts)
+			},
+			CreateDocFunc: func(_ context.Context, _ interface{}, opts map[string]interface{}) (string, string, error) {
+				rev, err := revOnly(opts)
+				if err != nil {
+					return "", "", err
+				}
+				return "foo", rev, nil
+			},
+			DeleteFunc: func(_ context.Context, _, _ string, opts map[string]interface{}) (string, error) {
+				return revOnly(opts)
+			},
+			PutAttachmentFunc: func(_ context.Context, _, _ string, _ *driver.Attachment, opts map[string]interface{}) (string, error) {
+				return revOnly(opts)
+			},
+			DeleteAttachmentFunc: func(_ context.Context, _, _, _ string, opts map[string]interface{}) (string, error) {
+				return revOnly(opts)
+			},
+		}
+		db := &DB{driverDB: plain}
+		if !tt.plain {
+			db.driverDB = &mock.QuorumWriter{
+				PutResultFunc: func(_ context.Context, _ string, _ interface{}, opts map[string]interface{}) (*driver.WriteResult, error) {
+					return result(opts)
+				},
+				CreateDocResultFunc: func(_ context.Context, _ interface{}, opts map[string]interface{}) (*driver.WriteResult, error) {
+					return result(opts)
+				},
+				DeleteResultFunc: func(_ context.Context, _, _ string, opts map[string]interface{}) (*driver.WriteResult, error) {
+					return result(opts)
+				},
+				PutAttachmentResultFunc: func(_ context.Context, _, _ string, _ *driver.Attachment, opts map[string]interface{}) (*driver.WriteResult, error) {
+					return result(opts)
+				},
+				DeleteAttachmentResultFunc: func(_ context.Context, _, _, _ string, opts map[string]interface{}) (*driver.WriteResult, error) {
+					return result(opts)
+				},
+			}
+		}
+		docID, rev, err := tt.write(db, tt.options)
+		if tt.err != "" {
+			testy.StatusError(t, tt.err, tt.status, err)
+			return
+		}
+		if docID != tt.docID || rev != tt.rev {
+			t.Errorf("Unexpected result: %s, %s", docID, rev)
+		}
+		if d := testy.DiffInterface(tt.expected, err); d != nil {
+			t.Error(d)
+		}
+		if tt.expected != nil && StatusCode(err) != http.StatusAccepted {
+			t.Errorf("Unexpected status: %d", StatusCode(err))
+		}
+	})
+}
+
+func TestBulkDocsRequireQuorum(t *testing.T) {
+	type result struct {
+		ID       string
+		Rev      string
+		Accepted bool
+		Err      error
+	}
+	type tt struct {
+		db       *DB
+		options  Options
+		expected []result
+	}
+	bulkDocer := &mock.BulkDocer{
+		BulkDocsFunc: func(_ context.Context, _ []interface{}, opts map[string]interface{}) (driver.BulkResults, error) {
+			if _, ok := opts[OptionRequireQuorum]; ok {
+				return nil, errors.New("option passed to driver")
+			}
+			return &emulatedBulkResults{[]driver.BulkResult{
+				{ID: "a", Rev: "1-a"},
+				{ID: "b", Rev: "1-b", Accepted: true},
+				{ID: "c", Error: errors.New("conflict")},
+			}}, nil
+		},
+	}
+	quorumWriter := &mock.QuorumWriter{
+		PutResultFunc: func(_ context.Context, docID string, _ interface{}, opts map[string]interface{}) (*driver.WriteResult, error) {
+			if _, ok := opts[OptionRequireQuorum]; ok {
+				return nil, errors.New("option passed to driver")
+			}
+			return &driver.WriteResult{ID: docID, Rev: "1-" + docID, Accepted: docID == "b"}, nil
+		},
+	}
+	docs := []interface{}{
+		map[string]string{"_id": "a"},
+		map[string]string{"_id": "b"},
+	}
+	tests := testy.NewTable()
+	tests.Add("bulk docer", tt{
+		db: &DB{driverDB: bulkDocer},
+		expected: []result{
+			{ID: "a", Rev: "1-a"},
+			{ID: "b", Rev: "1-b", Accepted: true},
+			{ID: "c", Err: errors.New("conflict")},
+		},
+	})
+	tests.Add("bulk docer, quorum required", tt{
+		db:      &DB{driverDB: bulkDocer},
+		options: Options{OptionRequireQuorum: true},
+		expected: []result{
+			{ID: "a", Rev: "1-a"},
+			{ID: "b", Rev: "1-b", Accepted: true, Err: &AcceptedError{Op: "BulkDocs", DocID: "b", Rev: "1-b"}},
+			{ID: "c", Err: errors.New("conflict")},
+		},
+	})
+	tests.Add("emulated", tt{
+		db: &DB{driverDB: quorumWriter},
+		expected: []result{
+			{ID: "a", Rev: "1-a"},
+			{ID: "b", Rev: "1-b", Accepted: true},
+		},
+	})
+	tests.Add("emulated, quorum required", tt{
+		db:      &DB{driverDB: quorumWriter},
+		options: Options{OptionRequireQuorum: true},
+		expected: []result{
+			{ID: "a", Rev: "1-a"},
+			{ID: "b", Rev: "1-b", Accepted: true, Err: &AcceptedError{Op: "BulkDocs", DocID: "b", Rev: "1-b"}},
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		rows, err := tt.db.BulkDocs(context.Background(), docs, tt.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var results []result
+		for rows.Next() {
+			results = append(results, result{
+				ID:       rows.ID(),
+				Rev:      rows.Rev(),
+				Accepted: rows.Accepted(),
+				Err:      rows.UpdateErr(),
+			})
+		}
+		if err := rows.Err(); err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(tt.expected, results); d != nil {
+			t.Error(d)
+		}
+	})
+}
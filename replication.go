@@ -175,9 +175,9 @@ func (c *Client) GetReplications(ctx context.Context, options ...Options) ([]*Re
 	if !ok {
 		return nil, replicationNotImplemented
 	}
-	reps, err := replicator.GetReplications(ctx, mergeOptions(options...))
+	reps, err := replicator.GetReplications(ctx, driverOptions(c.mergeOptions(options...)))
 	if err != nil {
-		return nil, err
+		return nil, opError("GetReplications", err)
 	}
 	replications := make([]*Replication, len(reps))
 	for i, rep := range reps {
@@ -190,15 +190,25 @@ func (c *Client) GetReplications(ctx context.Context, options ...Options) ([]*Re
 //
 // To use an object for either "source" or "target", pass the desired object
 // in options. This will override targetDSN and sourceDSN function parameters.
+// To authenticate to either with credentials from a CredentialProvider, pass
+// SourceCredentials or TargetCredentials. To filter the replication with a
+// filter function, pass Filter.
 func (c *Client) Replicate(ctx context.Context, targetDSN, sourceDSN string, options ...Options) (*Replication, error) {
 	replicator, ok := c.driverClient.(driver.ClientReplicator)
 	if !ok {
 		return nil, replicationNotImplemented
 	}
-	rep, err := replicator.Replicate(ctx, targetDSN, sourceDSN, mergeOptions(options...))
-	if err != nil {
+	opts := c.mergeOptions(options...)
+	if err := resolveCredentials(ctx, opts, targetDSN, sourceDSN); err != nil {
+		return nil, err
+	}
+	if err := replicationFilter(opts); err != nil {
 		return nil, err
 	}
+	rep, err := replicator.Replicate(ctx, targetDSN, sourceDSN, driverOptions(opts))
+	if err != nil {
+		return nil, opError("Replicate", err)
+	}
 	return newReplication(rep), nil
 }
 
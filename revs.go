@@ -0,0 +1,71 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+)
+
+// GetAtRev fetches revision rev of docID, which need not be the current
+// revision. Old revisions are only available until the database is
+// compacted; see AvailableRevs. It is equivalent to calling Get with the rev
+// option.
+func (db *DB) GetAtRev(ctx context.Context, docID, rev string, options ...Options) *Row {
+	if db.err != nil {
+		return &Row{Err: db.err}
+	}
+	if rev == "" {
+		return &Row{Err: missingArg("rev")}
+	}
+	opts := mergeOptions(append(options[:len(options):len(options)], Options{"rev": rev})...)
+	return db.Get(ctx, docID, opts)
+}
+
+// RevInfo describes a revision in a document's history.
+type RevInfo struct {
+	Rev string `json:"rev"`
+	// Status is "available" if the revision's content may be fetched,
+	// "deleted" if the revision deleted the document, or "missing" if the
+	// content has been removed by compaction.
+	Status string `json:"status"`
+}
+
+// RevsInfo returns the revision history of the current revision of docID,
+// newest first, as reported by the revs_info option.
+func (db *DB) RevsInfo(ctx context.Context, docID string, options ...Options) ([]RevInfo, error) {
+	var doc struct {
+		RevsInfo []RevInfo `json:"_revs_info"`
+	}
+	opts := mergeOptions(append(options[:len(options):len(options)], Options{"revs_info": true})...)
+	if err := db.Get(ctx, docID, opts).ScanDoc(&doc); err != nil {
+		return nil, err
+	}
+	return doc.RevsInfo, nil
+}
+
+// AvailableRevs returns the revisions in the history of docID whose content
+// has not been removed by compaction, newest first. Each may be fetched with
+// GetAtRev.
+func (db *DB) AvailableRevs(ctx context.Context, docID string, options ...Options) ([]string, error) {
+	info, err := db.RevsInfo(ctx, docID, options...)
+	if err != nil {
+		return nil, err
+	}
+	var revs []string
+	for _, ri := range info {
+		if ri.Status == "available" {
+			revs = append(revs, ri.Rev)
+		}
+	}
+	return revs, nil
+}
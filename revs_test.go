@@ -0,0 +1,126 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestGetAtRev(t *testing.T) {
+	type tt struct {
+		db       *DB
+		rev      string
+		options  Options
+		expected interface{}
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("db error", tt{
+		db:     &DB{err: errors.New("db error")},
+		rev:    "1-xxx",
+		status: http.StatusInternalServerError,
+		err:    "db error",
+	})
+	tests.Add("missing rev", tt{
+		db:     &DB{driverDB: &mock.DB{}},
+		status: http.StatusBadRequest,
+		err:    "kivik: rev required",
+	})
+	tests.Add("success", tt{
+		db: &DB{
+			driverDB: &mock.DB{
+				GetFunc: func(_ context.Context, docID string, opts map[string]interface{}) (*driver.Document, error) {
+					expected := map[string]interface{}{"rev": "1-xxx", "revs": true}
+					if d := testy.DiffInterface(expected, opts); d != nil {
+						return nil, fmt.Errorf("Unexpected options: %s", d)
+					}
+					return &driver.Document{Body: ioutil.NopCloser(strings.NewReader(`{"_rev":"1-xxx"}`))}, nil
+				},
+			},
+			options: Options{"rev": "2-yyy"},
+		},
+		rev:      "1-xxx",
+		options:  Options{"revs": true},
+		expected: map[string]interface{}{"_rev": "1-xxx"},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		var doc interface{}
+		err := tt.db.GetAtRev(context.Background(), "foo", tt.rev, tt.options).ScanDoc(&doc)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, doc); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestRevsInfo(t *testing.T) {
+	db := &DB{
+		driverDB: &mock.DB{
+			GetFunc: func(_ context.Context, docID string, opts map[string]interface{}) (*driver.Document, error) {
+				if docID == "missing" {
+					return nil, &Error{HTTPStatus: http.StatusNotFound, Err: errors.New("missing")}
+				}
+				if d := testy.DiffInterface(map[string]interface{}{"revs_info": true}, opts); d != nil {
+					return nil, fmt.Errorf("Unexpected options: %s", d)
+				}
+				return &driver.Document{Body: ioutil.NopCloser(strings.NewReader(`{"_revs_info":[
+					{"rev":"4-d","status":"available"},
+					{"rev":"3-c","status":"deleted"},
+					{"rev":"2-b","status":"available"},
+					{"rev":"1-a","status":"missing"}
+				]}`))}, nil
+			},
+		},
+	}
+	t.Run("RevsInfo", func(t *testing.T) {
+		info, err := db.RevsInfo(context.Background(), "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []RevInfo{
+			{Rev: "4-d", Status: "available"},
+			{Rev: "3-c", Status: "deleted"},
+			{Rev: "2-b", Status: "available"},
+			{Rev: "1-a", Status: "missing"},
+		}
+		if d := testy.DiffInterface(expected, info); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("AvailableRevs", func(t *testing.T) {
+		revs, err := db.AvailableRevs(context.Background(), "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]string{"4-d", "2-b"}, revs); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		_, err := db.AvailableRevs(context.Background(), "missing")
+		testy.StatusError(t, "missing", http.StatusNotFound, err)
+	})
+}
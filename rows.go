@@ -14,7 +14,6 @@ package kivik
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
 
 	"github.com/go-kivik/kivik/v4/driver"
@@ -24,6 +23,8 @@ import (
 type Rows struct {
 	*iter
 	rowsi driver.Rows
+	// useNumber is set by OptionUseNumber.
+	useNumber bool
 }
 
 // Next prepares the next result value for reading. It returns true on success
@@ -48,12 +49,32 @@ func (r *Rows) Close() error {
 	return r.iter.Close()
 }
 
+// Stats returns the consumer's progress through the rows so far.
+func (r *Rows) Stats() IteratorStats {
+	return r.metrics.snapshot()
+}
+
 type rowsIterator struct{ driver.Rows }
 
 var _ iterator = &rowsIterator{}
 
 func (r *rowsIterator) Next(i interface{}) error { return r.Rows.Next(i.(*driver.Row)) }
 
+func (r *rowsIterator) measure(i interface{}, m *iterMetrics) int64 {
+	row := i.(*driver.Row)
+	row.ValueReader = m.reader(row.ValueReader)
+	row.DocReader = m.reader(row.DocReader)
+	return rawSize(row.Key, row.Value, row.Doc)
+}
+
+// newRowsOpts returns newRows(ctx, rowsi), decoding numbers as json.Number if
+// useNumber is true.
+func newRowsOpts(ctx context.Context, rowsi driver.Rows, useNumber bool) *Rows {
+	rows := newRows(ctx, rowsi)
+	rows.useNumber = useNumber
+	return rows
+}
+
 func newRows(ctx context.Context, rowsi driver.Rows) *Rows {
 	return &Rows{
 		iter:  newIterator(ctx, &rowsIterator{rowsi}, &driver.Row{}),
@@ -84,9 +105,9 @@ func (r *Rows) ScanValue(dest interface{}) error {
 		return row.Error
 	}
 	if row.ValueReader != nil {
-		return json.NewDecoder(row.ValueReader).Decode(dest)
+		return decodeJSON(row.ValueReader, dest, r.useNumber)
 	}
-	return json.Unmarshal(row.Value, dest)
+	return unmarshalJSON(row.Value, dest, r.useNumber)
 }
 
 // ScanDoc works the same as ScanValue, but on the doc field of the result. It
@@ -103,10 +124,10 @@ func (r *Rows) ScanDoc(dest interface{}) error {
 	}
 	doc := row.Doc
 	if row.DocReader != nil {
-		return json.NewDecoder(row.DocReader).Decode(dest)
+		return decodeJSON(row.DocReader, dest, r.useNumber)
 	}
 	if doc != nil {
-		return json.Unmarshal(doc, dest)
+		return unmarshalJSON(doc, dest, r.useNumber)
 	}
 	return &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: doc is nil; does the query include docs?"}
 }
@@ -123,7 +144,7 @@ func (r *Rows) ScanKey(dest interface{}) error {
 	if err := row.Error; err != nil {
 		return err
 	}
-	return json.Unmarshal(row.Key, dest)
+	return unmarshalJSON(row.Key, dest, r.useNumber)
 }
 
 // ID returns the ID of the current result.
@@ -0,0 +1,80 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
+)
+
+// OptionUseNumber, when true, causes numbers to be decoded as json.Number,
+// rather than float64, when scanning into an interface{} value, such as the
+// values of a map[string]interface{}. This preserves large integers and
+// decimal values, such as currency amounts, which cannot be represented
+// exactly as a float64, so that documents survive a read-modify-write
+// round trip unaltered. It is interpreted by Kivik, and not passed to the
+// driver.
+//
+// It applies to ScanDoc, ScanValue and ScanKey on the results of Get, Query,
+// QueryMulti, AllDocs, DesignDocs, LocalDocs, BulkGet and Find, and to
+// Changes.ScanDoc. To enable it for all reads from a database, pass it to
+// WithOptions.
+//
+// Struct fields of numeric types are unaffected. Types which implement
+// json.Unmarshaler, such as decimal types, receive the number's exact text
+// either way.
+const OptionUseNumber = "kivik.use_number"
+
+// useNumberOption reports whether OptionUseNumber is set in opts, and removes
+// it.
+func useNumberOption(opts Options) (bool, error) {
+	useNumber, err := queryopts.Bool(opts, OptionUseNumber)
+	if err != nil {
+		return false, err
+	}
+	delete(opts, OptionUseNumber)
+	return useNumber, nil
+}
+
+// decodeJSON decodes the JSON value read from r into dest, with the decoder
+// registered for dest, if any.
+func decodeJSON(r io.Reader, dest interface{}, useNumber bool) error {
+	if fn := decoderFor(dest); fn != nil {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return fn(data, dest)
+	}
+	dec := json.NewDecoder(r)
+	if useNumber {
+		dec.UseNumber()
+	}
+	return dec.Decode(dest)
+}
+
+// unmarshalJSON unmarshals data into dest, with the decoder registered for
+// dest, if any.
+func unmarshalJSON(data []byte, dest interface{}, useNumber bool) error {
+	if fn := decoderFor(dest); fn != nil {
+		return fn(data, dest)
+	}
+	if !useNumber {
+		return json.Unmarshal(data, dest)
+	}
+	return decodeJSON(bytes.NewReader(data), dest, true)
+}
@@ -0,0 +1,195 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+const bigNumberDoc = `{"n":12345678901234567890,"price":0.10}`
+
+func TestUseNumberOption(t *testing.T) {
+	t.Run("unset", func(t *testing.T) {
+		opts := Options{"foo": "bar"}
+		useNumber, err := useNumberOption(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if useNumber {
+			t.Error("Expected false")
+		}
+	})
+	t.Run("set", func(t *testing.T) {
+		opts := Options{OptionUseNumber: "true", "foo": "bar"}
+		useNumber, err := useNumberOption(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !useNumber {
+			t.Error("Expected true")
+		}
+		if d := testy.DiffInterface(Options{"foo": "bar"}, opts); d != nil {
+			t.Errorf("Option not removed:\n%s", d)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := useNumberOption(Options{OptionUseNumber: 3})
+		testy.StatusError(t, "invalid value for kivik.use_number: 3", http.StatusBadRequest, err)
+	})
+}
+
+func TestGetUseNumber(t *testing.T) {
+	db := &DB{
+		driverDB: &mock.DB{
+			GetFunc: func(_ context.Context, _ string, opts map[string]interface{}) (*driver.Document, error) {
+				if _, ok := opts[OptionUseNumber]; ok {
+					return nil, fmt.Errorf("Option passed to driver: %v", opts)
+				}
+				return &driver.Document{Body: ioutil.NopCloser(strings.NewReader(bigNumberDoc))}, nil
+			},
+		},
+		options: Options{OptionUseNumber: true},
+	}
+	var doc map[string]interface{}
+	if err := db.Get(context.Background(), "foo").ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"n":     json.Number("12345678901234567890"),
+		"price": json.Number("0.10"),
+	}
+	if d := testy.DiffInterface(expected, doc); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestRowsUseNumber(t *testing.T) {
+	newDB := func(t *testing.T) *DB {
+		var done bool
+		return &DB{
+			driverDB: &mock.DB{
+				QueryFunc: func(_ context.Context, _, _ string, opts map[string]interface{}) (driver.Rows, error) {
+					if _, ok := opts[OptionUseNumber]; ok {
+						return nil, fmt.Errorf("Option passed to driver: %v", opts)
+					}
+					return &mock.Rows{
+						NextFunc: func(row *driver.Row) error {
+							if done {
+								return io.EOF
+							}
+							done = true
+							row.Key = json.RawMessage(`12345678901234567890`)
+							row.ValueReader = strings.NewReader(`12345678901234567891`)
+							row.Doc = json.RawMessage(bigNumberDoc)
+							return nil
+						},
+						CloseFunc: func() error { return nil },
+					}, nil
+				},
+			},
+		}
+	}
+	scan := func(t *testing.T, rows *Rows) []interface{} {
+		t.Helper()
+		if !rows.Next() {
+			t.Fatal(rows.Err())
+		}
+		var key, value interface{}
+		var doc map[string]interface{}
+		if err := rows.ScanKey(&key); err != nil {
+			t.Fatal(err)
+		}
+		if err := rows.ScanValue(&value); err != nil {
+			t.Fatal(err)
+		}
+		if err := rows.ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		return []interface{}{key, value, doc["n"]}
+	}
+
+	t.Run("default", func(t *testing.T) {
+		rows, err := newDB(t).Query(context.Background(), "foo", "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []interface{}{1.2345678901234567e+19, 1.2345678901234567e+19, 1.2345678901234567e+19}
+		if d := testy.DiffInterface(expected, scan(t, rows)); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("use number", func(t *testing.T) {
+		rows, err := newDB(t).Query(context.Background(), "foo", "bar", Options{OptionUseNumber: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []interface{}{
+			json.Number("12345678901234567890"),
+			json.Number("12345678901234567891"),
+			json.Number("12345678901234567890"),
+		}
+		if d := testy.DiffInterface(expected, scan(t, rows.Skip(0))); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("invalid option", func(t *testing.T) {
+		_, err := newDB(t).Query(context.Background(), "foo", "bar", Options{OptionUseNumber: "maybe"})
+		testy.StatusError(t, "invalid value for kivik.use_number: \"maybe\"", http.StatusBadRequest, err)
+	})
+}
+
+func TestChangesUseNumber(t *testing.T) {
+	db := &DB{
+		driverDB: &mock.DB{
+			ChangesFunc: func(_ context.Context, opts map[string]interface{}) (driver.Changes, error) {
+				if _, ok := opts[OptionUseNumber]; ok {
+					return nil, fmt.Errorf("Option passed to driver: %v", opts)
+				}
+				return &mock.Changes{
+					NextFunc: func(change *driver.Change) error {
+						change.Doc = json.RawMessage(bigNumberDoc)
+						return nil
+					},
+					CloseFunc: func() error { return nil },
+				}, nil
+			},
+		},
+	}
+	changes, err := db.Changes(context.Background(), Options{OptionUseNumber: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer changes.Close() // nolint: errcheck
+	if !changes.Next() {
+		t.Fatal(changes.Err())
+	}
+	var doc map[string]interface{}
+	if err := changes.ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["n"] != json.Number("12345678901234567890") {
+		t.Errorf("Unexpected value: %v", doc["n"])
+	}
+}
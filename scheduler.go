@@ -0,0 +1,159 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+var schedulerNotImplemented = &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: driver does not support the replication scheduler"}
+
+// SchedulerJob represents a running replication job.
+// See https://docs.couchdb.org/en/stable/api/server/common.html#scheduler-jobs
+type SchedulerJob struct {
+	// Database is the replicator database containing the job's document.
+	Database string
+	// JobID is the replication ID.
+	JobID     string
+	PID       string
+	Source    string
+	Target    string
+	User      string
+	DocID     string
+	History   []SchedulerJobEvent
+	Node      string
+	StartTime time.Time
+}
+
+// SchedulerJobEvent is an entry in a replication job's history, such as
+// "added", "started" or "crashed".
+type SchedulerJobEvent struct {
+	Timestamp time.Time
+	Type      string
+	Reason    string
+}
+
+// SchedulerDoc represents the state of a replication defined by a
+// _replicator document.
+// See https://docs.couchdb.org/en/stable/api/server/common.html#scheduler-docs
+type SchedulerDoc struct {
+	// Database is the replicator database containing the document.
+	Database      string
+	DocID         string
+	ReplicationID string
+	Node          string
+	Source        string
+	Target        string
+	// State is the scheduler state, such as ReplicationRunning or
+	// ReplicationCrashing.
+	State       ReplicationState
+	Info        map[string]interface{}
+	ErrorCount  int
+	LastUpdated time.Time
+	StartTime   time.Time
+}
+
+func newSchedulerJob(j *driver.SchedulerJob) *SchedulerJob {
+	history := make([]SchedulerJobEvent, len(j.History))
+	for i, e := range j.History {
+		history[i] = SchedulerJobEvent(e)
+	}
+	return &SchedulerJob{
+		Database:  j.Database,
+		JobID:     j.JobID,
+		PID:       j.PID,
+		Source:    j.Source,
+		Target:    j.Target,
+		User:      j.User,
+		DocID:     j.DocID,
+		History:   history,
+		Node:      j.Node,
+		StartTime: j.StartTime,
+	}
+}
+
+func newSchedulerDoc(d *driver.SchedulerDoc) *SchedulerDoc {
+	return &SchedulerDoc{
+		Database:      d.Database,
+		DocID:         d.DocID,
+		ReplicationID: d.ReplicationID,
+		Node:          d.Node,
+		Source:        d.Source,
+		Target:        d.Target,
+		State:         ReplicationState(d.State),
+		Info:          d.Info,
+		ErrorCount:    d.ErrorCount,
+		LastUpdated:   d.LastUpdated,
+		StartTime:     d.StartTime,
+	}
+}
+
+// SchedulerJobs returns the replication jobs currently being run by the
+// scheduler. Options such as limit and skip are passed to the driver.
+func (c *Client) SchedulerJobs(ctx context.Context, options ...Options) ([]*SchedulerJob, error) {
+	scheduler, ok := c.driverClient.(driver.Scheduler)
+	if !ok {
+		return nil, schedulerNotImplemented
+	}
+	jobs, err := scheduler.SchedulerJobs(ctx, driverOptions(c.mergeOptions(options...)))
+	if err != nil {
+		return nil, opError("SchedulerJobs", err)
+	}
+	result := make([]*SchedulerJob, len(jobs))
+	for i, job := range jobs {
+		result[i] = newSchedulerJob(job)
+	}
+	return result, nil
+}
+
+// SchedulerDocs returns the state of the replications defined in all
+// _replicator databases, including those which have completed or failed.
+func (c *Client) SchedulerDocs(ctx context.Context, options ...Options) ([]*SchedulerDoc, error) {
+	scheduler, ok := c.driverClient.(driver.Scheduler)
+	if !ok {
+		return nil, schedulerNotImplemented
+	}
+	docs, err := scheduler.SchedulerDocs(ctx, driverOptions(c.mergeOptions(options...)))
+	if err != nil {
+		return nil, opError("SchedulerDocs", err)
+	}
+	result := make([]*SchedulerDoc, len(docs))
+	for i, doc := range docs {
+		result[i] = newSchedulerDoc(doc)
+	}
+	return result, nil
+}
+
+// SchedulerDoc returns the state of the replication defined by docID in the
+// named replicator database, usually "_replicator".
+func (c *Client) SchedulerDoc(ctx context.Context, replicatorDB, docID string) (*SchedulerDoc, error) {
+	if replicatorDB == "" {
+		return nil, missingArg("replicatorDB")
+	}
+	if docID == "" {
+		return nil, missingArg("docID")
+	}
+	scheduler, ok := c.driverClient.(driver.Scheduler)
+	if !ok {
+		return nil, schedulerNotImplemented
+	}
+	doc, err := scheduler.SchedulerDoc(ctx, replicatorDB, docID)
+	if err != nil {
+		return nil, opError("SchedulerDoc", err)
+	}
+	return newSchedulerDoc(doc), nil
+}
@@ -0,0 +1,180 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestSchedulerJobs(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	type tst struct {
+		client   driver.Client
+		expected []*SchedulerJob
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("driver doesn't implement Scheduler interface", tst{
+		client: &mock.Client{},
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support the replication scheduler",
+	})
+	tests.Add("client error", tst{
+		client: &mock.Scheduler{
+			SchedulerJobsFunc: func(context.Context, map[string]interface{}) ([]*driver.SchedulerJob, error) {
+				return nil, errors.New("client error")
+			},
+		},
+		status: http.StatusInternalServerError,
+		err:    "client error",
+	})
+	tests.Add("success", tst{
+		client: &mock.Scheduler{
+			SchedulerJobsFunc: func(context.Context, map[string]interface{}) ([]*driver.SchedulerJob, error) {
+				return []*driver.SchedulerJob{{
+					Database:  "_replicator",
+					JobID:     "abc+continuous",
+					DocID:     "rep1",
+					History:   []driver.SchedulerJobEvent{{Timestamp: start, Type: "started"}},
+					StartTime: start,
+				}}, nil
+			},
+		},
+		expected: []*SchedulerJob{{
+			Database:  "_replicator",
+			JobID:     "abc+continuous",
+			DocID:     "rep1",
+			History:   []SchedulerJobEvent{{Timestamp: start, Type: "started"}},
+			StartTime: start,
+		}},
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		c := &Client{
+			driverClient: test.client,
+		}
+		result, err := c.SchedulerJobs(context.Background())
+		testy.StatusError(t, test.err, test.status, err)
+		if d := testy.DiffInterface(test.expected, result); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestSchedulerDocs(t *testing.T) {
+	type tst struct {
+		client   driver.Client
+		expected []*SchedulerDoc
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("driver doesn't implement Scheduler interface", tst{
+		client: &mock.Client{},
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support the replication scheduler",
+	})
+	tests.Add("success", tst{
+		client: &mock.Scheduler{
+			SchedulerDocsFunc: func(_ context.Context, opts map[string]interface{}) ([]*driver.SchedulerDoc, error) {
+				return []*driver.SchedulerDoc{{
+					Database: "_replicator",
+					DocID:    "rep1",
+					State:    "crashing",
+				}}, nil
+			},
+		},
+		expected: []*SchedulerDoc{{
+			Database: "_replicator",
+			DocID:    "rep1",
+			State:    ReplicationCrashing,
+		}},
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		c := &Client{
+			driverClient: test.client,
+		}
+		result, err := c.SchedulerDocs(context.Background())
+		testy.StatusError(t, test.err, test.status, err)
+		if d := testy.DiffInterface(test.expected, result); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestSchedulerDoc(t *testing.T) {
+	type tst struct {
+		client       driver.Client
+		replicatorDB string
+		docID        string
+		expected     *SchedulerDoc
+		status       int
+		err          string
+	}
+	tests := testy.NewTable()
+	tests.Add("missing replicator db", tst{
+		client: &mock.Client{},
+		docID:  "rep1",
+		status: http.StatusBadRequest,
+		err:    "kivik: replicatorDB required",
+	})
+	tests.Add("missing doc id", tst{
+		client:       &mock.Client{},
+		replicatorDB: "_replicator",
+		status:       http.StatusBadRequest,
+		err:          "kivik: docID required",
+	})
+	tests.Add("not found", tst{
+		client: &mock.Scheduler{
+			SchedulerDocFunc: func(context.Context, string, string) (*driver.SchedulerDoc, error) {
+				return nil, &Error{HTTPStatus: http.StatusNotFound, Message: "missing"}
+			},
+		},
+		replicatorDB: "_replicator",
+		docID:        "rep1",
+		status:       http.StatusNotFound,
+		err:          "missing",
+	})
+	tests.Add("success", tst{
+		client: &mock.Scheduler{
+			SchedulerDocFunc: func(_ context.Context, db, docID string) (*driver.SchedulerDoc, error) {
+				return &driver.SchedulerDoc{Database: db, DocID: docID, State: "completed"}, nil
+			},
+		},
+		replicatorDB: "_replicator",
+		docID:        "rep1",
+		expected:     &SchedulerDoc{Database: "_replicator", DocID: "rep1", State: ReplicationComplete},
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		c := &Client{
+			driverClient: test.client,
+		}
+		result, err := c.SchedulerDoc(context.Background(), test.replicatorDB, test.docID)
+		testy.StatusError(t, test.err, test.status, err)
+		if d := testy.DiffInterface(test.expected, result); d != nil {
+			t.Error(d)
+		}
+	})
+}
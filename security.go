@@ -12,14 +12,236 @@
 
 package kivik
 
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
 // Members represents the members of a database security document.
 type Members struct {
 	Names []string `json:"names,omitempty"`
 	Roles []string `json:"roles,omitempty"`
 }
 
+// AddName adds name to m.Names, if it is not already present.
+func (m *Members) AddName(name string) {
+	m.Names = addString(m.Names, name)
+}
+
+// RemoveName removes name from m.Names, if present.
+func (m *Members) RemoveName(name string) {
+	m.Names = removeString(m.Names, name)
+}
+
+// AddRole adds role to m.Roles, if it is not already present.
+func (m *Members) AddRole(role string) {
+	m.Roles = addString(m.Roles, role)
+}
+
+// RemoveRole removes role from m.Roles, if present.
+func (m *Members) RemoveRole(role string) {
+	m.Roles = removeString(m.Roles, role)
+}
+
+func addString(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+func removeString(list []string, s string) []string {
+	for i, v := range list {
+		if v == s {
+			return append(list[:i:i], list[i+1:]...)
+		}
+	}
+	return list
+}
+
 // Security represents a database security document.
 type Security struct {
 	Admins  Members `json:"admins"`
 	Members Members `json:"members"`
+
+	// Extra holds any other fields of the security document, keyed by field
+	// name. Vendor extensions are more conveniently accessed with Extension
+	// and SetExtension.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// MarshalJSON satisfies the json.Marshaler interface, including s.Extra
+// alongside the standard fields.
+func (s Security) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.driver())
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface, collecting any
+// non-standard fields in s.Extra.
+func (s *Security) UnmarshalJSON(data []byte) error {
+	var sec driver.Security
+	if err := json.Unmarshal(data, &sec); err != nil {
+		return err
+	}
+	*s = Security{
+		Admins:  Members(sec.Admins),
+		Members: Members(sec.Members),
+		Extra:   sec.Extra,
+	}
+	return nil
+}
+
+func (s *Security) driver() *driver.Security {
+	return &driver.Security{
+		Admins:  driver.Members(s.Admins),
+		Members: driver.Members(s.Members),
+		Extra:   s.Extra,
+	}
+}
+
+// AddAdminName adds name to the database admins.
+func (s *Security) AddAdminName(name string) { s.Admins.AddName(name) }
+
+// RemoveAdminName removes name from the database admins.
+func (s *Security) RemoveAdminName(name string) { s.Admins.RemoveName(name) }
+
+// AddAdminRole adds role to the database admin roles.
+func (s *Security) AddAdminRole(role string) { s.Admins.AddRole(role) }
+
+// RemoveAdminRole removes role from the database admin roles.
+func (s *Security) RemoveAdminRole(role string) { s.Admins.RemoveRole(role) }
+
+// AddMemberName adds name to the database members.
+func (s *Security) AddMemberName(name string) { s.Members.AddName(name) }
+
+// RemoveMemberName removes name from the database members.
+func (s *Security) RemoveMemberName(name string) { s.Members.RemoveName(name) }
+
+// AddMemberRole adds role to the database member roles.
+func (s *Security) AddMemberRole(role string) { s.Members.AddRole(role) }
+
+// RemoveMemberRole removes role from the database member roles.
+func (s *Security) RemoveMemberRole(role string) { s.Members.RemoveRole(role) }
+
+// SecurityExtension is implemented by typed representations of vendor
+// extensions to the security document, such as CloudantSecurity.
+type SecurityExtension interface {
+	// SecurityField returns the name of the security document field which
+	// holds the extension.
+	SecurityField() string
+}
+
+// Extension decodes the security document field named by ext into ext. If
+// the field is not present, ext is left unaltered.
+func (s *Security) Extension(ext SecurityExtension) error {
+	raw, ok := s.Extra[ext.SecurityField()]
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal(raw, ext); err != nil {
+		return &Error{HTTPStatus: http.StatusBadGateway, Err: err}
+	}
+	return nil
+}
+
+// SetExtension encodes ext into the security document field it names.
+func (s *Security) SetExtension(ext SecurityExtension) error {
+	raw, err := json.Marshal(ext)
+	if err != nil {
+		return &Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	if s.Extra == nil {
+		s.Extra = make(map[string]json.RawMessage)
+	}
+	s.Extra[ext.SecurityField()] = raw
+	return nil
+}
+
+// CloudantSecurity represents the "cloudant" field of a Cloudant security
+// document, which maps each user name, or "nobody", to that user's roles,
+// such as "_reader" and "_writer".
+type CloudantSecurity map[string][]string
+
+var _ SecurityExtension = CloudantSecurity{}
+
+// SecurityField returns "cloudant".
+func (CloudantSecurity) SecurityField() string { return "cloudant" }
+
+// maxSecurityAttempts is the number of times UpdateSecurity calls fn before
+// giving up on concurrent changes.
+const maxSecurityAttempts = 5
+
+// UpdateSecurity fetches the database security document, passes it to fn for
+// modification, and stores the result. If fn returns an error, nothing is
+// stored and the error is returned. If fn makes no changes, nothing is
+// stored.
+//
+// CouchDB does not support conditional updates of the security document.
+// As the next best thing, UpdateSecurity fetches the document again
+// immediately before storing it. If it has changed since fn was called, fn
+// is called again on the new version. After several such attempts, a status
+// 409 error is returned.
+func (db *DB) UpdateSecurity(ctx context.Context, fn func(*Security) error) error {
+	if db.err != nil {
+		return db.err
+	}
+	current, err := db.Security(ctx)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < maxSecurityAttempts; i++ {
+		updated, err := copySecurity(current)
+		if err != nil {
+			return err
+		}
+		if err := fn(updated); err != nil {
+			return err
+		}
+		if sameSecurity(current, updated) {
+			return nil
+		}
+		latest, err := db.Security(ctx)
+		if err != nil {
+			return err
+		}
+		if sameSecurity(current, latest) {
+			return db.SetSecurity(ctx, updated)
+		}
+		current = latest
+	}
+	return &Error{HTTPStatus: http.StatusConflict, Message: "kivik: security document changed concurrently"}
+}
+
+// copySecurity returns a deep copy of s, so that fn's changes do not alter
+// the document used for comparison.
+func copySecurity(s *Security) (*Security, error) {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, &Error{HTTPStatus: http.StatusInternalServerError, Err: err}
+	}
+	c := new(Security)
+	if err := json.Unmarshal(raw, c); err != nil {
+		return nil, &Error{HTTPStatus: http.StatusInternalServerError, Err: err}
+	}
+	return c, nil
+}
+
+// sameSecurity reports whether a and b have the same JSON representation,
+// which disregards differences such as nil versus empty lists.
+func sameSecurity(a, b *Security) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(x, y)
 }
@@ -0,0 +1,162 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestMembers(t *testing.T) {
+	m := &Members{}
+	m.AddName("bob")
+	m.AddName("alice")
+	m.AddName("bob")
+	m.AddRole("admin")
+	m.RemoveName("bob")
+	m.RemoveRole("missing")
+	expected := &Members{Names: []string{"alice"}, Roles: []string{"admin"}}
+	if d := testy.DiffInterface(expected, m); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestSecurityJSON(t *testing.T) {
+	input := `{"admins":{"names":["bob"]},"cloudant":{"nobody":["_reader"]},"couchdb_auth_only":true,"members":{}}`
+	sec := new(Security)
+	if err := json.Unmarshal([]byte(input), sec); err != nil {
+		t.Fatal(err)
+	}
+	expected := &Security{
+		Admins: Members{Names: []string{"bob"}},
+		Extra: map[string]json.RawMessage{
+			"cloudant":          json.RawMessage(`{"nobody":["_reader"]}`),
+			"couchdb_auth_only": json.RawMessage(`true`),
+		},
+	}
+	if d := testy.DiffInterface(expected, sec); d != nil {
+		t.Error(d)
+	}
+	output, err := json.Marshal(sec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffJSON([]byte(input), output); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestSecurityExtension(t *testing.T) {
+	sec := &Security{}
+	got := CloudantSecurity{}
+	if err := sec.Extension(got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Unexpected extension: %v", got)
+	}
+	if err := sec.SetExtension(CloudantSecurity{"nobody": {"_reader"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sec.Extension(&got); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(CloudantSecurity{"nobody": {"_reader"}}, got); d != nil {
+		t.Error(d)
+	}
+	sec.Extra["cloudant"] = json.RawMessage(`"invalid"`)
+	err := sec.Extension(&got)
+	testy.StatusError(t, "json: cannot unmarshal string into Go value of type kivik.CloudantSecurity", http.StatusBadGateway, err)
+}
+
+func TestUpdateSecurity(t *testing.T) {
+	type tt struct {
+		versions []*driver.Security
+		fn       func(*Security) error
+		expected *driver.Security
+		status   int
+		err      string
+	}
+	bob := &driver.Security{Admins: driver.Members{Names: []string{"bob"}}}
+	alice := &driver.Security{Admins: driver.Members{Names: []string{"alice"}}}
+	addRole := func(s *Security) error {
+		s.AddMemberRole("reader")
+		return nil
+	}
+	tests := testy.NewTable()
+	tests.Add("success", tt{
+		versions: []*driver.Security{bob},
+		fn:       addRole,
+		expected: &driver.Security{
+			Admins:  driver.Members{Names: []string{"bob"}},
+			Members: driver.Members{Roles: []string{"reader"}},
+		},
+	})
+	tests.Add("no change", tt{
+		versions: []*driver.Security{bob},
+		fn:       func(*Security) error { return nil },
+	})
+	tests.Add("fn error", tt{
+		versions: []*driver.Security{bob},
+		fn:       func(*Security) error { return errors.New("nope") },
+		status:   http.StatusInternalServerError,
+		err:      "nope",
+	})
+	tests.Add("concurrent change", tt{
+		versions: []*driver.Security{bob, alice},
+		fn:       addRole,
+		expected: &driver.Security{
+			Admins:  driver.Members{Names: []string{"alice"}},
+			Members: driver.Members{Roles: []string{"reader"}},
+		},
+	})
+	tests.Add("too many concurrent changes", tt{
+		versions: []*driver.Security{bob, alice, bob, alice, bob, alice},
+		fn:       addRole,
+		status:   http.StatusConflict,
+		err:      "kivik: security document changed concurrently",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		var stored *driver.Security
+		calls := 0
+		db := &DB{driverDB: &mock.DB{
+			SecurityFunc: func(context.Context) (*driver.Security, error) {
+				v := tt.versions[len(tt.versions)-1]
+				if calls < len(tt.versions) {
+					v = tt.versions[calls]
+				}
+				calls++
+				sec := *v
+				return &sec, nil
+			},
+			SetSecurityFunc: func(_ context.Context, sec *driver.Security) error {
+				stored = sec
+				return nil
+			},
+		}}
+		err := db.UpdateSecurity(context.Background(), tt.fn)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, stored); d != nil {
+			t.Error(d)
+		}
+	})
+}
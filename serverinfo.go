@@ -0,0 +1,168 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SemVer is a parsed semantic version number.
+type SemVer struct {
+	Major, Minor, Patch int
+	// Pre is the pre-release suffix, without the leading hyphen, such as
+	// "RC1", if any.
+	Pre string
+}
+
+// ParseSemVer parses a version string of the form major[.minor[.patch]],
+// optionally followed by -pre-release and +build suffixes. Missing minor or
+// patch numbers are treated as 0. A leading "v" is permitted.
+func ParseSemVer(version string) (SemVer, error) {
+	v := strings.TrimPrefix(version, "v")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	var sv SemVer
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		v, sv.Pre = v[:i], v[i+1:]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) > 3 {
+		return SemVer{}, &Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid version %q", version)}
+	}
+	nums := []*int{&sv.Major, &sv.Minor, &sv.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return SemVer{}, &Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid version %q", version)}
+		}
+		*nums[i] = n
+	}
+	return sv, nil
+}
+
+// String returns v in the form major.minor.patch[-pre].
+func (v SemVer) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if v is less than, equal to, or greater than o.
+// A pre-release sorts before the corresponding release; pre-release suffixes
+// are otherwise compared as strings.
+func (v SemVer) Compare(o SemVer) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+	}
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	case v.Pre < o.Pre:
+		return -1
+	}
+	return 1
+}
+
+// AtLeast returns true if v is at least major.minor.patch. Pre-releases of
+// major.minor.patch do not qualify.
+func (v SemVer) AtLeast(major, minor, patch int) bool {
+	return v.Compare(SemVer{Major: major, Minor: minor, Patch: patch}) >= 0
+}
+
+// ServerInfo describes the server, as reported by the welcome message at the
+// server root.
+//
+// See https://docs.couchdb.org/en/stable/api/server/common.html#get--
+type ServerInfo struct {
+	// Version is the version string reported by the server.
+	Version string
+	// SemVer is the parsed Version. It is the zero value if Version could not
+	// be parsed.
+	SemVer SemVer
+	// GitSHA is the git commit the server was built from, if reported.
+	GitSHA string
+	// UUID is the server's unique identifier, if reported.
+	UUID string
+	// Features lists the enabled optional features.
+	Features []string
+	// Vendor is the vendor name reported by the server.
+	Vendor string
+	// VendorVersion is the vendor's own version string, if reported.
+	VendorVersion string
+	// RawResponse is the raw welcome message.
+	RawResponse json.RawMessage
+}
+
+// ServerInfo returns details of the server, as for Version, with the version
+// parsed, and additional fields from the welcome message decoded where the
+// driver provides it.
+func (c *Client) ServerInfo(ctx context.Context) (*ServerInfo, error) {
+	ver, err := c.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info := &ServerInfo{
+		Version:     ver.Version,
+		Features:    ver.Features,
+		Vendor:      ver.Vendor,
+		RawResponse: ver.RawResponse,
+	}
+	if len(ver.RawResponse) > 0 {
+		var welcome struct {
+			GitSHA string `json:"git_sha"`
+			UUID   string `json:"uuid"`
+			Vendor struct {
+				Version string `json:"version"`
+			} `json:"vendor"`
+		}
+		if err := json.Unmarshal(ver.RawResponse, &welcome); err == nil {
+			info.GitSHA = welcome.GitSHA
+			info.UUID = welcome.UUID
+			info.VendorVersion = welcome.Vendor.Version
+		}
+	}
+	info.SemVer, _ = ParseSemVer(info.Version)
+	return info, nil
+}
+
+// AtLeast returns true if the server version is at least major.minor.patch.
+func (i *ServerInfo) AtLeast(major, minor, patch int) bool {
+	return i.SemVer.AtLeast(major, minor, patch)
+}
+
+// HasFeature returns true if the server reports feature as enabled.
+func (i *ServerInfo) HasFeature(feature string) bool {
+	for _, f := range i.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
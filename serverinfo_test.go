@@ -0,0 +1,192 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestParseSemVer(t *testing.T) {
+	type tt struct {
+		version  string
+		expected SemVer
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("full", tt{
+		version:  "3.3.2",
+		expected: SemVer{Major: 3, Minor: 3, Patch: 2},
+	})
+	tests.Add("major only", tt{
+		version:  "2",
+		expected: SemVer{Major: 2},
+	})
+	tests.Add("prefix, pre-release and build", tt{
+		version:  "v2.1.0-RC1+abc123",
+		expected: SemVer{Major: 2, Minor: 1, Pre: "RC1"},
+	})
+	tests.Add("empty", tt{
+		version: "",
+		err:     `kivik: invalid version ""`,
+	})
+	tests.Add("too many parts", tt{
+		version: "1.2.3.4",
+		err:     `kivik: invalid version "1.2.3.4"`,
+	})
+	tests.Add("not a number", tt{
+		version: "1.x",
+		err:     `kivik: invalid version "1.x"`,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		v, err := ParseSemVer(tt.version)
+		var status int
+		if tt.err != "" {
+			status = http.StatusBadRequest
+		}
+		testy.StatusError(t, tt.err, status, err)
+		if d := testy.DiffInterface(tt.expected, v); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestSemVerCompare(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0.0", "2.0.0", -1},
+		{"2.1.0", "2.0.9", 1},
+		{"2.1.1", "2.1.2", -1},
+		{"3.0.0-RC1", "3.0.0", -1},
+		{"3.0.0", "3.0.0-RC1", 1},
+		{"3.0.0-RC1", "3.0.0-RC2", -1},
+		{"3.0.0-RC2", "3.0.0-RC1", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.a+" vs "+test.b, func(t *testing.T) {
+			a, _ := ParseSemVer(test.a)
+			b, _ := ParseSemVer(test.b)
+			if got := a.Compare(b); got != test.expected {
+				t.Errorf("Expected %d, got %d", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestSemVerString(t *testing.T) {
+	if s := (SemVer{Major: 1, Minor: 2, Patch: 3, Pre: "RC1"}).String(); s != "1.2.3-RC1" {
+		t.Errorf("Unexpected string: %s", s)
+	}
+}
+
+func TestServerInfo(t *testing.T) {
+	type tt struct {
+		client   *Client
+		expected *ServerInfo
+		status   int
+		err      string
+	}
+	version := func(v *driver.Version) *Client {
+		return &Client{driverClient: &mock.Client{
+			VersionFunc: func(context.Context) (*driver.Version, error) {
+				return v, nil
+			},
+		}}
+	}
+	tests := testy.NewTable()
+	tests.Add("error", tt{
+		client: &Client{driverClient: &mock.Client{
+			VersionFunc: func(context.Context) (*driver.Version, error) {
+				return nil, errors.New("version failed")
+			},
+		}},
+		status: http.StatusInternalServerError,
+		err:    "version failed",
+	})
+	tests.Add("couchdb", func() interface{} {
+		raw := []byte(`{"couchdb":"Welcome","version":"3.3.2","git_sha":"11a234070","uuid":"abc","features":["access-ready","scheduler"],"vendor":{"name":"The Apache Software Foundation","version":"3.3.2-1"}}`)
+		return tt{
+			client: version(&driver.Version{
+				Version:     "3.3.2",
+				Vendor:      "The Apache Software Foundation",
+				Features:    []string{"access-ready", "scheduler"},
+				RawResponse: raw,
+			}),
+			expected: &ServerInfo{
+				Version:       "3.3.2",
+				SemVer:        SemVer{Major: 3, Minor: 3, Patch: 2},
+				GitSHA:        "11a234070",
+				UUID:          "abc",
+				Features:      []string{"access-ready", "scheduler"},
+				Vendor:        "The Apache Software Foundation",
+				VendorVersion: "3.3.2-1",
+				RawResponse:   raw,
+			},
+		}
+	})
+	tests.Add("no raw response", tt{
+		client: version(&driver.Version{Version: "0.0.1", Vendor: "Kivik Memory Adaptor"}),
+		expected: &ServerInfo{
+			Version: "0.0.1",
+			SemVer:  SemVer{Patch: 1},
+			Vendor:  "Kivik Memory Adaptor",
+		},
+	})
+	tests.Add("unparseable version", tt{
+		client: version(&driver.Version{Version: "unknown"}),
+		expected: &ServerInfo{
+			Version: "unknown",
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		info, err := tt.client.ServerInfo(context.Background())
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, info); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestServerInfoHelpers(t *testing.T) {
+	info := &ServerInfo{
+		SemVer:   SemVer{Major: 3, Minor: 3},
+		Features: []string{"scheduler"},
+	}
+	if !info.AtLeast(3, 3, 0) {
+		t.Error("Expected 3.3.0 to be at least 3.3.0")
+	}
+	if !info.AtLeast(2, 9, 9) {
+		t.Error("Expected 3.3.0 to be at least 2.9.9")
+	}
+	if info.AtLeast(3, 3, 1) {
+		t.Error("Expected 3.3.0 not to be at least 3.3.1")
+	}
+	if !info.HasFeature("scheduler") {
+		t.Error("Expected scheduler feature")
+	}
+	if info.HasFeature("partitioned") {
+		t.Error("Unexpected partitioned feature")
+	}
+}
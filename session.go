@@ -45,7 +45,7 @@ func (c *Client) Session(ctx context.Context) (*Session, error) {
 	if sessioner, ok := c.driverClient.(driver.Sessioner); ok {
 		session, err := sessioner.Session(ctx)
 		if err != nil {
-			return nil, err
+			return nil, opError("Session", err)
 		}
 		ses := Session(*session)
 		return &ses, nil
@@ -0,0 +1,47 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// Snapshot returns a read-only handle to the database as it is at the time of
+// the call. Reads through the snapshot are unaffected by later writes to the
+// database, so a test can assert on a consistent state while other goroutines
+// continue to write. Writes through the snapshot fail.
+//
+// Snapshots are only supported by drivers which implement driver.Snapshotter,
+// such as the memory driver.
+func (db *DB) Snapshot(ctx context.Context) (*DB, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	snapshotter, ok := db.driverDB.(driver.Snapshotter)
+	if !ok {
+		return nil, &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: driver does not support snapshots"}
+	}
+	snapshot, err := snapshotter.Snapshot(ctx)
+	if err != nil {
+		return nil, opError("Snapshot", err)
+	}
+	return &DB{
+		client:   db.client,
+		name:     db.name,
+		driverDB: snapshot,
+		options:  db.options,
+	}, nil
+}
@@ -0,0 +1,79 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestSnapshot(t *testing.T) {
+	type tt struct {
+		db     *DB
+		status int
+		err    string
+	}
+	snapshot := &mock.DB{}
+	tests := testy.NewTable()
+	tests.Add("db error", tt{
+		db:     &DB{err: errors.New("db error")},
+		status: http.StatusInternalServerError,
+		err:    "db error",
+	})
+	tests.Add("not supported", tt{
+		db:     &DB{driverDB: &mock.DB{}},
+		status: http.StatusNotImplemented,
+		err:    "kivik: driver does not support snapshots",
+	})
+	tests.Add("driver error", tt{
+		db: &DB{driverDB: &mock.Snapshotter{
+			SnapshotFunc: func(context.Context) (driver.DB, error) {
+				return nil, errors.New("snapshot failed")
+			},
+		}},
+		status: http.StatusInternalServerError,
+		err:    "snapshot failed",
+	})
+	tests.Add("success", tt{
+		db: &DB{
+			name: "foo",
+			driverDB: &mock.Snapshotter{
+				SnapshotFunc: func(context.Context) (driver.DB, error) {
+					return snapshot, nil
+				},
+			},
+			options: Options{"include_docs": true},
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		result, err := tt.db.Snapshot(context.Background())
+		testy.StatusError(t, tt.err, tt.status, err)
+		if result.driverDB != snapshot {
+			t.Errorf("Unexpected driver DB: %v", result.driverDB)
+		}
+		if result.Name() != tt.db.name {
+			t.Errorf("Unexpected name: %s", result.Name())
+		}
+		if d := testy.DiffInterface(tt.db.options, result.options); d != nil {
+			t.Error(d)
+		}
+	})
+}
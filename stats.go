@@ -0,0 +1,51 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// ClientStats contains connection pool and concurrency statistics for a
+// client, as reported by the driver.
+type ClientStats struct {
+	// MaxIdleConns is the maximum number of idle connections.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost is the maximum number of idle connections per host.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost is the maximum number of connections per host.
+	MaxConnsPerHost int
+	// MaxConcurrentRequests is the maximum number of requests in flight.
+	MaxConcurrentRequests int
+	// ActiveRequests is the number of requests in flight.
+	ActiveRequests int
+	// WaitingRequests is the number of requests waiting for a slot.
+	WaitingRequests int
+	// TotalRequests is the number of requests issued by the client.
+	TotalRequests int64
+}
+
+// Stats returns connection pool and concurrency statistics for the client.
+// The available options for tuning these limits are driver-specific; consult
+// the driver documentation for details.
+func (c *Client) Stats(ctx context.Context) (*ClientStats, error) {
+	statser, ok := c.driverClient.(driver.ClientStatser)
+	if !ok {
+		return nil, &Error{HTTPStatus: http.StatusNotImplemented, Message: "kivik: driver does not support client stats"}
+	}
+	stats, err := statser.Stats(ctx)
+	return (*ClientStats)(stats), opError("Stats", err)
+}
@@ -0,0 +1,83 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestClientStats(t *testing.T) {
+	tests := []struct {
+		name     string
+		client   driver.Client
+		expected *ClientStats
+		status   int
+		err      string
+	}{
+		{
+			name:   "driver doesn't implement ClientStatser",
+			client: &mock.Client{},
+			status: http.StatusNotImplemented,
+			err:    "kivik: driver does not support client stats",
+		},
+		{
+			name: "driver returns error",
+			client: &mock.ClientStatser{
+				StatsFunc: func(_ context.Context) (*driver.ClientStats, error) {
+					return nil, errors.New("stats error")
+				},
+			},
+			status: http.StatusInternalServerError,
+			err:    "stats error",
+		},
+		{
+			name: "success",
+			client: &mock.ClientStatser{
+				StatsFunc: func(_ context.Context) (*driver.ClientStats, error) {
+					return &driver.ClientStats{
+						MaxConnsPerHost:       10,
+						MaxConcurrentRequests: 5,
+						ActiveRequests:        5,
+						WaitingRequests:       2,
+						TotalRequests:         123,
+					}, nil
+				},
+			},
+			expected: &ClientStats{
+				MaxConnsPerHost:       10,
+				MaxConcurrentRequests: 5,
+				ActiveRequests:        5,
+				WaitingRequests:       2,
+				TotalRequests:         123,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &Client{driverClient: test.client}
+			result, err := client.Stats(context.Background())
+			testy.StatusError(t, test.err, test.status, err)
+			if d := testy.DiffInterface(test.expected, result); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+const optionChangesTransform = "kivik.changes_transform"
+
+// DocTransformFunc transforms the document of a change, before the change is
+// yielded by a changes feed. id is the ID of the changed document, and doc is
+// the document as returned by the server. It returns the document as the
+// consumer should see it.
+type DocTransformFunc func(ctx context.Context, id string, doc json.RawMessage) (json.RawMessage, error)
+
+// ChangesTransform returns an option for Changes and CatchUpChanges, which
+// applies fns, in order, to the document of each change which includes one,
+// as with include_docs=true. This allows documents to be decrypted, or
+// upgraded to the current schema, before any consumer reads them with
+// ScanDoc or ScanEvent.
+//
+// To apply the transform to every changes feed of a database, pass it to
+// Client.DB. A ChangesTransform option replaces any given before it. If a
+// transform returns an error, the feed ends with that error.
+func ChangesTransform(fns ...DocTransformFunc) Options {
+	return Options{optionChangesTransform: fns}
+}
+
+// changesTransformOption removes the ChangesTransform option from opts, and
+// returns its functions.
+func changesTransformOption(opts Options) ([]DocTransformFunc, error) {
+	v, ok := opts[optionChangesTransform]
+	if !ok {
+		return nil, nil
+	}
+	delete(opts, optionChangesTransform)
+	fns, ok := v.([]DocTransformFunc)
+	if !ok {
+		return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("kivik: invalid value for %s: %v", optionChangesTransform, v)}
+	}
+	for _, fn := range fns {
+		if fn == nil {
+			return nil, &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: nil changes transform"}
+		}
+	}
+	return fns, nil
+}
+
+// transformChanges is a driver.Changes which applies transforms to the
+// document of each change.
+type transformChanges struct {
+	driver.Changes
+	ctx context.Context
+	fns []DocTransformFunc
+}
+
+// withTransforms wraps changesi to apply fns, if there are any.
+func withTransforms(ctx context.Context, changesi driver.Changes, fns []DocTransformFunc) driver.Changes {
+	if len(fns) == 0 {
+		return changesi
+	}
+	return &transformChanges{Changes: changesi, ctx: ctx, fns: fns}
+}
+
+func (c *transformChanges) Next(change *driver.Change) error {
+	if err := c.Changes.Next(change); err != nil {
+		return err
+	}
+	if len(change.Doc) == 0 || string(change.Doc) == "null" {
+		return nil
+	}
+	for _, fn := range c.fns {
+		doc, err := fn(c.ctx, change.ID, change.Doc)
+		if err != nil {
+			return err
+		}
+		change.Doc = doc
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// docChanges returns a changes feed of the given documents, keyed by ID.
+// IDs with an empty document yield changes without a document.
+func docChanges(docs ...[2]string) *mock.Changes {
+	return &mock.Changes{
+		NextFunc: func(change *driver.Change) error {
+			if len(docs) == 0 {
+				return io.EOF
+			}
+			*change = driver.Change{ID: docs[0][0], Doc: json.RawMessage(docs[0][1])}
+			docs = docs[1:]
+			return nil
+		},
+		CloseFunc:   func() error { return nil },
+		LastSeqFunc: func() string { return "" },
+		PendingFunc: func() int64 { return 0 },
+		ETagFunc:    func() string { return "" },
+	}
+}
+
+// appendField returns a transform which sets field to the ID of the
+// document.
+func appendField(field string) DocTransformFunc {
+	return func(_ context.Context, id string, doc json.RawMessage) (json.RawMessage, error) {
+		var m map[string]interface{}
+		if err := json.Unmarshal(doc, &m); err != nil {
+			return nil, err
+		}
+		m[field] = id
+		return json.Marshal(m)
+	}
+}
+
+func TestChangesTransform(t *testing.T) {
+	type tst struct {
+		db       *DB
+		options  Options
+		catchUp  bool
+		expected []string
+		status   int
+		err      string
+	}
+	feed := func(opts map[string]interface{}) (driver.Changes, error) {
+		if _, ok := opts[optionChangesTransform]; ok {
+			return nil, errors.New("transform option passed to driver")
+		}
+		if opts["feed"] == "continuous" {
+			// The live feed of CatchUpChanges has nothing more to add.
+			return docChanges(), nil
+		}
+		return docChanges([2]string{"a", `{"x":1}`}, [2]string{"b", ""}, [2]string{"c", "null"}), nil
+	}
+	newDB := func(options Options) *DB {
+		return &DB{
+			driverDB: &mock.DB{
+				ChangesFunc: func(_ context.Context, opts map[string]interface{}) (driver.Changes, error) {
+					return feed(opts)
+				},
+			},
+			options: options,
+		}
+	}
+	tests := testy.NewTable()
+	tests.Add("no transform", tst{
+		db:       newDB(nil),
+		expected: []string{`{"x":1}`, "", "null"},
+	})
+	tests.Add("transforms in order", tst{
+		db:       newDB(nil),
+		options:  ChangesTransform(appendField("first"), appendField("second")),
+		expected: []string{`{"first":"a","second":"a","x":1}`, "", "null"},
+	})
+	tests.Add("database default", tst{
+		db:       newDB(ChangesTransform(appendField("first"))),
+		expected: []string{`{"first":"a","x":1}`, "", "null"},
+	})
+	tests.Add("replaces database default", tst{
+		db:       newDB(ChangesTransform(appendField("first"))),
+		options:  ChangesTransform(appendField("second")),
+		expected: []string{`{"second":"a","x":1}`, "", "null"},
+	})
+	tests.Add("catch up", tst{
+		db:       newDB(nil),
+		options:  ChangesTransform(appendField("first")),
+		catchUp:  true,
+		expected: []string{`{"first":"a","x":1}`, "", "null"},
+	})
+	tests.Add("transform error", tst{
+		db: newDB(nil),
+		options: ChangesTransform(func(context.Context, string, json.RawMessage) (json.RawMessage, error) {
+			return nil, &Error{HTTPStatus: http.StatusBadGateway, Message: "decryption failed"}
+		}),
+		expected: []string{},
+		status:   http.StatusBadGateway,
+		err:      "decryption failed",
+	})
+	tests.Add("nil transform", tst{
+		db:      newDB(nil),
+		options: ChangesTransform(nil),
+		status:  http.StatusBadRequest,
+		err:     "kivik: nil changes transform",
+	})
+	tests.Add("invalid option", tst{
+		db:      newDB(nil),
+		options: Options{optionChangesTransform: "foo"},
+		status:  http.StatusBadRequest,
+		err:     "kivik: invalid value for kivik.changes_transform: foo",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		var changes *Changes
+		var err error
+		if tt.catchUp {
+			changes, err = tt.db.CatchUpChanges(context.Background(), 10, tt.options)
+		} else {
+			changes, err = tt.db.Changes(context.Background(), tt.options)
+		}
+		if err == nil {
+			docs := []string{}
+			for changes.Next() {
+				var event ChangeEvent
+				if err := changes.ScanEvent(&event); err != nil {
+					t.Fatal(err)
+				}
+				docs = append(docs, string(event.Doc))
+			}
+			if d := testy.DiffInterface(tt.expected, docs); d != nil {
+				t.Error(d)
+			}
+			err = changes.Err()
+		}
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
@@ -96,7 +96,9 @@ func (c *Client) DBUpdates(ctx context.Context) (*DBUpdates, error) {
 	}
 	updatesi, err := updater.DBUpdates(ctx)
 	if err != nil {
-		return nil, err
+		return nil, opError("DBUpdates", err)
 	}
-	return newDBUpdates(context.Background(), updatesi), nil
+	updates := newDBUpdates(context.Background(), updatesi)
+	c.measure(updates.iter, "DBUpdates", "/_db_updates")
+	return updates, nil
 }
@@ -213,6 +213,9 @@ func TestDBUpdates(t *testing.T) {
 						DBUpdates: &mock.DBUpdates{ID: "a"},
 					},
 					curVal: &driver.DBUpdate{},
+					metrics: iterMetrics{
+						stats: IteratorStats{Operation: "DBUpdates", Endpoint: "/_db_updates"},
+					},
 				},
 				updatesi: &mock.DBUpdates{ID: "a"},
 			},
@@ -0,0 +1,98 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// boolOption interprets an option value as a boolean. Both native bools and
+// their string representations are recognized. ok is false if the option is
+// unset or not a recognized boolean value.
+func boolOption(opts Options, key string) (value, ok bool) {
+	switch t := opts[key].(type) {
+	case bool:
+		return t, true
+	case string:
+		v, err := strconv.ParseBool(t)
+		return v, err == nil
+	}
+	return false, false
+}
+
+// groupingRequested returns true if opts request grouped view results, by
+// setting either group=true or group_level.
+func groupingRequested(opts Options) bool {
+	if group, _ := boolOption(opts, "group"); group {
+		return true
+	}
+	_, ok := opts["group_level"]
+	return ok
+}
+
+// validateQueryOptions checks for view query option combinations which are
+// never valid, regardless of the view being queried.
+func validateQueryOptions(opts Options) error {
+	if reduce, ok := boolOption(opts, "reduce"); ok && !reduce && groupingRequested(opts) {
+		return &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: group and group_level are invalid when reduce=false"}
+	}
+	return nil
+}
+
+// ValidateQuery checks the options for a view query against the view's
+// definition in the design document, without executing the query. It returns
+// a status 400 error if grouping or reduce=true is requested for a view
+// without a reduce function, or if grouping is combined with reduce=false,
+// and a status 404 error if the view does not exist.
+//
+// ValidateQuery requires an extra request to fetch the design document, so is
+// intended for use in development, or when building queries from untrusted
+// input. Query itself only performs the checks which do not require inspecting
+// the design document.
+func (db *DB) ValidateQuery(ctx context.Context, ddoc, view string, options ...Options) error {
+	if db.err != nil {
+		return db.err
+	}
+	ddoc = strings.TrimPrefix(ddoc, "_design/")
+	view = strings.TrimPrefix(view, "_view/")
+	opts := db.mergeOptions(options...)
+	if err := validateQueryOptions(opts); err != nil {
+		return err
+	}
+	var doc struct {
+		Views map[string]struct {
+			Reduce string `json:"reduce"`
+		} `json:"views"`
+	}
+	if err := db.Get(ctx, "_design/"+ddoc).ScanDoc(&doc); err != nil {
+		return err
+	}
+	def, ok := doc.Views[view]
+	if !ok {
+		return &Error{HTTPStatus: http.StatusNotFound, Message: fmt.Sprintf("kivik: view %s/%s not found", ddoc, view)}
+	}
+	if def.Reduce != "" {
+		return nil
+	}
+	if groupingRequested(opts) {
+		return &Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("kivik: group and group_level are invalid for map-only view %s/%s", ddoc, view)}
+	}
+	if reduce, _ := boolOption(opts, "reduce"); reduce {
+		return &Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("kivik: reduce=true is invalid for map-only view %s/%s", ddoc, view)}
+	}
+	return nil
+}
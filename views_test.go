@@ -0,0 +1,166 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func TestValidateQueryOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		status int
+		err    string
+	}{
+		{
+			name: "no options",
+		},
+		{
+			name: "group without reduce",
+			opts: Options{"group": true},
+		},
+		{
+			name: "group with reduce=true",
+			opts: Options{"group": true, "reduce": true},
+		},
+		{
+			name:   "group with reduce=false",
+			opts:   Options{"group": true, "reduce": false},
+			status: http.StatusBadRequest,
+			err:    "kivik: group and group_level are invalid when reduce=false",
+		},
+		{
+			name:   "group_level with string reduce=false",
+			opts:   Options{"group_level": 2, "reduce": "false"},
+			status: http.StatusBadRequest,
+			err:    "kivik: group and group_level are invalid when reduce=false",
+		},
+		{
+			name: "group=false with reduce=false",
+			opts: Options{"group": false, "reduce": false},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateQueryOptions(test.opts)
+			testy.StatusError(t, test.err, test.status, err)
+		})
+	}
+}
+
+func TestValidateQuery(t *testing.T) {
+	ddocDB := func(ddoc string) *DB {
+		return &DB{
+			driverDB: &mock.DB{
+				GetFunc: func(_ context.Context, docID string, _ map[string]interface{}) (*driver.Document, error) {
+					if docID != "_design/foo" {
+						return nil, fmt.Errorf("Unexpected docID: %s", docID)
+					}
+					return &driver.Document{Body: body(ddoc)}, nil
+				},
+			},
+		}
+	}
+	const ddoc = `{"views":{"map":{"map":"function(doc){}"},"reduce":{"map":"function(doc){}","reduce":"_count"}}}`
+	tests := []struct {
+		name       string
+		db         *DB
+		ddoc, view string
+		opts       Options
+		status     int
+		err        string
+	}{
+		{
+			name:   "db error",
+			db:     &DB{err: errors.New("db error")},
+			status: http.StatusInternalServerError,
+			err:    "db error",
+		},
+		{
+			name:   "invalid options",
+			db:     &DB{driverDB: &mock.DB{}},
+			opts:   Options{"group": true, "reduce": false},
+			status: http.StatusBadRequest,
+			err:    "kivik: group and group_level are invalid when reduce=false",
+		},
+		{
+			name: "ddoc not found",
+			db: &DB{
+				driverDB: &mock.DB{
+					GetFunc: func(_ context.Context, _ string, _ map[string]interface{}) (*driver.Document, error) {
+						return nil, &Error{HTTPStatus: http.StatusNotFound, Message: "missing"}
+					},
+				},
+			},
+			ddoc:   "foo",
+			view:   "bar",
+			status: http.StatusNotFound,
+			err:    "missing",
+		},
+		{
+			name:   "view not found",
+			db:     ddocDB(ddoc),
+			ddoc:   "_design/foo",
+			view:   "_view/bar",
+			status: http.StatusNotFound,
+			err:    "kivik: view foo/bar not found",
+		},
+		{
+			name:   "group on map view",
+			db:     ddocDB(ddoc),
+			ddoc:   "foo",
+			view:   "map",
+			opts:   Options{"group_level": 1},
+			status: http.StatusBadRequest,
+			err:    "kivik: group and group_level are invalid for map-only view foo/map",
+		},
+		{
+			name:   "reduce=true on map view",
+			db:     ddocDB(ddoc),
+			ddoc:   "foo",
+			view:   "map",
+			opts:   Options{"reduce": true},
+			status: http.StatusBadRequest,
+			err:    "kivik: reduce=true is invalid for map-only view foo/map",
+		},
+		{
+			name: "map view",
+			db:   ddocDB(ddoc),
+			ddoc: "foo",
+			view: "map",
+		},
+		{
+			name: "group on reduce view",
+			db:   ddocDB(ddoc),
+			ddoc: "foo",
+			view: "reduce",
+			opts: Options{"group": true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.db.ValidateQuery(context.Background(), test.ddoc, test.view, test.opts)
+			testy.StatusError(t, test.err, test.status, err)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"sync"
+)
+
+// DocUpdate describes an update to a watched document.
+type DocUpdate struct {
+	// ID is the document ID.
+	ID string
+	// Rev is the document's new winning revision.
+	Rev string
+	// Seq is the update sequence of the change.
+	Seq string
+	// Deleted is true if the update deleted the document.
+	Deleted bool
+}
+
+// DocWatcher is an iterator over updates to a set of documents, as returned
+// by WatchDocs.
+type DocWatcher struct {
+	changes *Changes
+	cancel  context.CancelFunc
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// WatchDocs watches the changes feed for updates to the listed documents, for
+// example to invalidate cached copies, or to reload configuration stored in a
+// document. Only updates made after the call are reported, unless options
+// includes since. Other options are passed to the changes feed. The watcher
+// remains open until closed, or ctx is cancelled.
+//
+// As with the changes feed, several updates to a document between calls to
+// Next may be reported as one, for the latest revision.
+func (db *DB) WatchDocs(ctx context.Context, docIDs []string, options ...Options) (*DocWatcher, error) {
+	if len(docIDs) == 0 {
+		return nil, missingArg("docIDs")
+	}
+	opts := Options{"feed": "continuous", "since": "now"}
+	for _, o := range options {
+		for k, v := range o {
+			opts[k] = v
+		}
+	}
+	for k, v := range ChangesDocIDs(docIDs...) {
+		opts[k] = v
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	changes, err := db.Changes(ctx, opts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &DocWatcher{changes: changes, cancel: cancel}, nil
+}
+
+// Next blocks until the next update, returning true when one is ready to be
+// read with Update, or false if the watcher is closed or an error occurs, in
+// which case Err should be consulted.
+func (w *DocWatcher) Next() bool {
+	return w.changes.Next()
+}
+
+// Update returns the current update.
+func (w *DocWatcher) Update() DocUpdate {
+	u := DocUpdate{
+		ID:      w.changes.ID(),
+		Seq:     w.changes.Seq(),
+		Deleted: w.changes.Deleted(),
+	}
+	if revs := w.changes.Changes(); len(revs) > 0 {
+		u.Rev = revs[0]
+	}
+	return u
+}
+
+// Err returns the error, if any, which ended iteration, other than the
+// watcher being closed.
+func (w *DocWatcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	return w.changes.Err()
+}
+
+// Close stops watching. It may be called from another goroutine, to end a
+// blocked call to Next.
+func (w *DocWatcher) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	w.cancel()
+	return w.changes.Close()
+}
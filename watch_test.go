@@ -0,0 +1,133 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func watchUpdates(t *testing.T, w *kivik.DocWatcher, n int) []kivik.DocUpdate {
+	t.Helper()
+	var updates []kivik.DocUpdate
+	for len(updates) < n && w.Next() {
+		u := w.Update()
+		if u.Seq == "" {
+			t.Errorf("No seq for update %v", u)
+		}
+		u.Seq = ""
+		updates = append(updates, u)
+	}
+	if err := w.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return updates
+}
+
+func TestWatchDocs(t *testing.T) {
+	ctx := context.Background()
+	db := liveDB(t)
+	rev1, err := db.Put(ctx, "a", map[string]string{"n": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("new updates", func(t *testing.T) {
+		w, err := db.WatchDocs(ctx, []string{"a", "c"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Close() // nolint: errcheck
+		// Updates are read as they happen, as the changes feed reports
+		// only the latest revision of a document updated more than once
+		// between reads.
+		rev2, err := db.Put(ctx, "a", map[string]string{"_rev": rev1, "n": "2"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []kivik.DocUpdate{{ID: "a", Rev: rev2}}
+		if d := testy.DiffInterface(expected, watchUpdates(t, w, 1)); d != nil {
+			t.Error(d)
+		}
+		if _, err := db.Put(ctx, "b", map[string]string{}); err != nil {
+			t.Fatal(err)
+		}
+		rev3, err := db.Delete(ctx, "a", rev2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = []kivik.DocUpdate{{ID: "a", Rev: rev3, Deleted: true}}
+		if d := testy.DiffInterface(expected, watchUpdates(t, w, 1)); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("since", func(t *testing.T) {
+		w, err := db.WatchDocs(ctx, []string{"b"}, kivik.Options{"since": "0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Close() // nolint: errcheck
+		updates := watchUpdates(t, w, 1)
+		if len(updates) != 1 || updates[0].ID != "b" {
+			t.Errorf("Unexpected updates: %v", updates)
+		}
+	})
+	t.Run("no documents", func(t *testing.T) {
+		_, err := db.WatchDocs(ctx, nil)
+		testy.StatusError(t, "kivik: docIDs required", http.StatusBadRequest, err)
+	})
+	t.Run("close while blocked", func(t *testing.T) {
+		w, err := db.WatchDocs(ctx, []string{"a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		done := make(chan bool)
+		go func() { done <- w.Next() }()
+		time.Sleep(10 * time.Millisecond)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case next := <-done:
+			if next {
+				t.Error("Next returned true after Close")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Next still blocked after Close")
+		}
+		if err := w.Err(); err != nil {
+			t.Error(err)
+		}
+	})
+	t.Run("closed", func(t *testing.T) {
+		w, err := db.WatchDocs(ctx, []string{"a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if w.Next() {
+			t.Error("Next returned true after Close")
+		}
+		if err := w.Err(); err != nil {
+			t.Error(err)
+		}
+	})
+}
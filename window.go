@@ -0,0 +1,205 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// Skip returns an iterator over r which discards the first n rows. The skip
+// happens client-side, regardless of any skip option sent to the server, so
+// it may be combined freely with Limit and Chunk.
+//
+// r must not be used directly after calling Skip. Closing the returned Rows
+// closes r.
+func (r *Rows) Skip(n int) *Rows {
+	return r.window(n, -1)
+}
+
+// Limit returns an iterator over r which stops after n rows. Once the limit is
+// reached, r is closed, releasing the underlying query. The limit applies
+// client-side, regardless of any limit option sent to the server.
+//
+// r must not be used directly after calling Limit. Closing the returned Rows
+// closes r.
+func (r *Rows) Limit(n int) *Rows {
+	if n < 0 {
+		n = 0
+	}
+	return r.window(0, n)
+}
+
+func (r *Rows) window(skip, limit int) *Rows {
+	feed := &windowFeed{parent: r, skip: skip, limit: limit}
+	return &Rows{
+		iter:      newIterator(context.Background(), feed, &driver.Row{}),
+		rowsi:     r.rowsi,
+		useNumber: r.useNumber,
+	}
+}
+
+// windowFeed reads from a parent Rows, discarding the first skip rows, and
+// stopping after limit rows. A negative limit means no limit.
+type windowFeed struct {
+	parent *Rows
+	skip   int
+	limit  int
+}
+
+var _ iterator = &windowFeed{}
+
+func (f *windowFeed) Next(i interface{}) error {
+	for {
+		if f.limit == 0 {
+			return io.EOF
+		}
+		if !f.parent.Next() {
+			if err := f.parent.Err(); err != nil {
+				return err
+			}
+			return io.EOF
+		}
+		if f.parent.EOQ() {
+			return driver.EOQ
+		}
+		if f.skip > 0 {
+			f.skip--
+			continue
+		}
+		if f.limit > 0 {
+			f.limit--
+		}
+		*i.(*driver.Row) = *f.parent.curVal.(*driver.Row)
+		return nil
+	}
+}
+
+func (f *windowFeed) Close() error {
+	return f.parent.Close()
+}
+
+// Chunks is an iterator over batches of rows, as returned by Rows.Chunk.
+type Chunks struct {
+	rows  *Rows
+	size  int
+	batch []driver.Row
+	err   error
+}
+
+// Chunk returns an iterator over r in batches of up to n rows, for processing
+// pipelines which consume results in groups, such as for bulk updates. Each
+// batch is read into memory in full before it is returned.
+//
+// r must not be used directly after calling Chunk. Closing the returned
+// Chunks closes r.
+func (r *Rows) Chunk(n int) *Chunks {
+	c := &Chunks{rows: r, size: n}
+	if n < 1 {
+		c.err = &Error{HTTPStatus: http.StatusBadRequest, Message: "kivik: chunk size must be positive"}
+		_ = r.Close()
+	}
+	return c
+}
+
+// Next reads the next batch of rows. It returns false when no rows remain, or
+// an error occurs. Err should be consulted to distinguish between the two.
+func (c *Chunks) Next() bool {
+	if c.err != nil {
+		return false
+	}
+	c.batch = nil
+	for len(c.batch) < c.size && c.rows.Next() {
+		if c.rows.EOQ() {
+			continue
+		}
+		row, err := bufferRow(c.rows.curVal.(*driver.Row))
+		if err != nil {
+			c.err = err
+			_ = c.rows.Close()
+			return false
+		}
+		c.batch = append(c.batch, row)
+	}
+	return len(c.batch) > 0
+}
+
+// bufferRow returns a copy of row, with any readers read into memory.
+func bufferRow(row *driver.Row) (driver.Row, error) {
+	buf := *row
+	if row.ValueReader != nil {
+		value, err := ioutil.ReadAll(row.ValueReader)
+		if err != nil {
+			return buf, err
+		}
+		buf.ValueReader, buf.Value = nil, value
+	}
+	if row.DocReader != nil {
+		doc, err := ioutil.ReadAll(row.DocReader)
+		if err != nil {
+			return buf, err
+		}
+		buf.DocReader, buf.Doc = nil, doc
+	}
+	return buf, nil
+}
+
+// Len returns the number of rows in the current batch.
+func (c *Chunks) Len() int {
+	return len(c.batch)
+}
+
+// Rows returns an iterator over the current batch. Metadata, such as
+// TotalRows, is read from the underlying result set.
+func (c *Chunks) Rows() *Rows {
+	batch := &batchRows{Rows: c.rows.rowsi, rows: c.batch}
+	return &Rows{
+		iter:      newIterator(context.Background(), &rowsIterator{batch}, &driver.Row{}),
+		rowsi:     c.rows.rowsi,
+		useNumber: c.rows.useNumber,
+	}
+}
+
+// Err returns the error, if any, that was encountered during iteration.
+func (c *Chunks) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.rows.Err()
+}
+
+// Close closes the underlying Rows.
+func (c *Chunks) Close() error {
+	return c.rows.Close()
+}
+
+// batchRows iterates over a buffered batch of rows, taking metadata from the
+// embedded driver.Rows.
+type batchRows struct {
+	driver.Rows
+	rows []driver.Row
+}
+
+func (b *batchRows) Next(row *driver.Row) error {
+	if len(b.rows) == 0 {
+		return io.EOF
+	}
+	*row, b.rows = b.rows[0], b.rows[1:]
+	return nil
+}
+
+func (b *batchRows) Close() error { return nil }
@@ -0,0 +1,241 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+// countRows returns Rows with IDs and values 0 through n-1, which fails with
+// err, if non-nil, instead of ending. closed is set when the rows are closed.
+func countRows(n int, err error, closed *bool) *Rows {
+	var i int
+	return newRows(context.Background(), &mock.Rows{
+		NextFunc: func(row *driver.Row) error {
+			if i == n {
+				if err != nil {
+					return err
+				}
+				return io.EOF
+			}
+			row.ID = fmt.Sprint(i)
+			row.ValueReader = strings.NewReader(fmt.Sprint(i))
+			i++
+			return nil
+		},
+		CloseFunc: func() error {
+			*closed = true
+			return nil
+		},
+		TotalRowsFunc: func() int64 { return int64(n) },
+	})
+}
+
+func rowIDs(rows *Rows) []string {
+	var ids []string
+	for rows.Next() {
+		ids = append(ids, rows.ID())
+	}
+	return ids
+}
+
+func TestRowsWindow(t *testing.T) {
+	type tt struct {
+		window func(*Rows) *Rows
+		err    error
+		ids    []string
+		status int
+		errMsg string
+	}
+	tests := testy.NewTable()
+	tests.Add("skip", tt{
+		window: func(r *Rows) *Rows { return r.Skip(3) },
+		ids:    []string{"3", "4"},
+	})
+	tests.Add("skip all", tt{
+		window: func(r *Rows) *Rows { return r.Skip(10) },
+	})
+	tests.Add("limit", tt{
+		window: func(r *Rows) *Rows { return r.Limit(2) },
+		ids:    []string{"0", "1"},
+	})
+	tests.Add("limit zero", tt{
+		window: func(r *Rows) *Rows { return r.Limit(0) },
+	})
+	tests.Add("skip and limit", tt{
+		window: func(r *Rows) *Rows { return r.Skip(1).Limit(2) },
+		ids:    []string{"1", "2"},
+	})
+	tests.Add("limit then skip", tt{
+		window: func(r *Rows) *Rows { return r.Limit(2).Skip(1) },
+		ids:    []string{"1"},
+	})
+	tests.Add("error", tt{
+		window: func(r *Rows) *Rows { return r.Skip(1) },
+		err:    &Error{HTTPStatus: http.StatusBadGateway, Message: "broken"},
+		ids:    []string{"1", "2", "3", "4"},
+		status: http.StatusBadGateway,
+		errMsg: "broken",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		var closed bool
+		rows := tt.window(countRows(5, tt.err, &closed))
+		ids := rowIDs(rows)
+		if d := testy.DiffInterface(tt.ids, ids); d != nil {
+			t.Error(d)
+		}
+		testy.StatusError(t, tt.errMsg, tt.status, rows.Err())
+		if !closed {
+			t.Error("underlying rows not closed")
+		}
+	})
+}
+
+func TestRowsLimitScan(t *testing.T) {
+	var closed bool
+	rows := countRows(5, nil, &closed).Skip(2).Limit(1)
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	var v int
+	if err := rows.ScanValue(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v != 2 {
+		t.Errorf("Unexpected value: %d", v)
+	}
+	if total := rows.TotalRows(); total != 5 {
+		t.Errorf("Unexpected total rows: %d", total)
+	}
+}
+
+func TestRowsChunk(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		err     error
+		batches [][]string
+		status  int
+		errMsg  string
+	}{
+		{
+			name:    "even",
+			size:    5,
+			batches: [][]string{{"0", "1", "2", "3", "4"}},
+		},
+		{
+			name:    "uneven",
+			size:    2,
+			batches: [][]string{{"0", "1"}, {"2", "3"}, {"4"}},
+		},
+		{
+			name:   "invalid size",
+			size:   0,
+			status: http.StatusBadRequest,
+			errMsg: "kivik: chunk size must be positive",
+		},
+		{
+			name:    "error",
+			size:    3,
+			err:     errors.New("broken"),
+			batches: [][]string{{"0", "1", "2"}, {"3", "4"}},
+			status:  http.StatusInternalServerError,
+			errMsg:  "broken",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var closed bool
+			chunks := countRows(5, test.err, &closed).Chunk(test.size)
+			var batches [][]string
+			for chunks.Next() {
+				batch := chunks.Rows()
+				ids := rowIDs(batch)
+				if len(ids) != chunks.Len() {
+					t.Errorf("Len() = %d, but batch has %d rows", chunks.Len(), len(ids))
+				}
+				batches = append(batches, ids)
+			}
+			if d := testy.DiffInterface(test.batches, batches); d != nil {
+				t.Error(d)
+			}
+			testy.StatusError(t, test.errMsg, test.status, chunks.Err())
+			if !closed {
+				t.Error("underlying rows not closed")
+			}
+		})
+	}
+}
+
+func TestChunkBuffersValues(t *testing.T) {
+	var closed bool
+	chunks := countRows(3, nil, &closed).Chunk(3)
+	if !chunks.Next() {
+		t.Fatal(chunks.Err())
+	}
+	rows := chunks.Rows()
+	var values []int
+	for rows.Next() {
+		var v int
+		if err := rows.ScanValue(&v); err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, v)
+	}
+	if d := testy.DiffInterface([]int{0, 1, 2}, values); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestChunkUseNumber(t *testing.T) {
+	var done bool
+	rows := newRowsOpts(context.Background(), &mock.Rows{
+		NextFunc: func(row *driver.Row) error {
+			if done {
+				return io.EOF
+			}
+			done = true
+			row.ValueReader = strings.NewReader(`12345678901234567890`)
+			return nil
+		},
+		CloseFunc: func() error { return nil },
+	}, true)
+	chunks := rows.Chunk(2)
+	if !chunks.Next() {
+		t.Fatal(chunks.Err())
+	}
+	batch := chunks.Rows()
+	if !batch.Next() {
+		t.Fatal(batch.Err())
+	}
+	var value interface{}
+	if err := batch.ScanValue(&value); err != nil {
+		t.Fatal(err)
+	}
+	if value != json.Number("12345678901234567890") {
+		t.Errorf("Unexpected value: %v (%T)", value, value)
+	}
+}
@@ -0,0 +1,296 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package attmigrate moves the attachments of a database elsewhere, such as
+// to another database or driver, or to object storage, for moving blob-heavy
+// databases off CouchDB.
+//
+// Documents are read in ID order, a batch at a time, and the attachments of
+// each are streamed, one at a time, from the source. With a Checkpoint, the
+// ID of each completed document is recorded, so that an interrupted
+// migration resumes after the last one.
+package attmigrate // import "github.com/go-kivik/kivik/v4/x/attmigrate"
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/errors"
+)
+
+// DefaultBatchSize is the number of documents read from the source at a time,
+// unless changed with WithBatchSize.
+const DefaultBatchSize = 100
+
+// AttachmentFunc receives an attachment of revision rev of document docID.
+// att.Content streams the content from the source, and is closed once the
+// function returns; att.Digest and att.RevPos are those recorded by the
+// source.
+type AttachmentFunc func(ctx context.Context, docID, rev string, att *kivik.Attachment) error
+
+// Checkpoint records the progress of a migration, so that it may resume.
+type Checkpoint interface {
+	// Load returns the ID of the last document migrated, or "" to start from
+	// the beginning.
+	Load(ctx context.Context) (string, error)
+	// Save records docID as the last document migrated.
+	Save(ctx context.Context, docID string) error
+}
+
+// Result summarizes a migration.
+type Result struct {
+	// Docs is the number of documents with attachments migrated.
+	Docs int
+	// Attachments is the number of attachments migrated.
+	Attachments int
+	// Bytes is the total size of the attachments migrated.
+	Bytes int64
+}
+
+// Migrator migrates the attachments of a source database.
+type Migrator struct {
+	source     *kivik.DB
+	batchSize  int
+	checkpoint Checkpoint
+}
+
+// New returns a Migrator which reads from source.
+func New(source *kivik.DB) *Migrator {
+	return &Migrator{source: source, batchSize: DefaultBatchSize}
+}
+
+// WithBatchSize returns a copy of m which reads n documents at a time.
+func (m *Migrator) WithBatchSize(n int) *Migrator {
+	c := *m
+	c.batchSize = n
+	return &c
+}
+
+// WithCheckpoint returns a copy of m which records its progress with cp, and
+// resumes from it.
+func (m *Migrator) WithCheckpoint(cp Checkpoint) *Migrator {
+	c := *m
+	c.checkpoint = cp
+	return &c
+}
+
+// document is a document read from the source.
+type document struct {
+	ID          string                       `json:"_id"`
+	Rev         string                       `json:"_rev"`
+	Attachments map[string]*kivik.Attachment `json:"_attachments"`
+
+	raw json.RawMessage
+}
+
+// filenames returns the names of the document's attachments, in order.
+func (d *document) filenames() []string {
+	names := make([]string, 0, len(d.Attachments))
+	for name := range d.Attachments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Attachments passes each attachment of the source to fn.
+func (m *Migrator) Attachments(ctx context.Context, fn AttachmentFunc) (*Result, error) {
+	return m.run(ctx, func(doc *document, result *Result) error {
+		for _, name := range doc.filenames() {
+			att, err := m.attachment(ctx, doc, name)
+			if err != nil {
+				return err
+			}
+			err = fn(ctx, doc.ID, doc.Rev, att)
+			_ = att.Content.Close()
+			if err != nil {
+				return errors.Wrapf(err, "attmigrate: %s/%s", doc.ID, name)
+			}
+			result.Attachments++
+			result.Bytes += att.Size
+		}
+		return nil
+	})
+}
+
+// CopyTo copies each document of the source which has attachments to target,
+// at the same revision, with new_edits=false, so that the attachments keep
+// their digests and revpos. The target driver must support new_edits=false.
+// Documents without attachments are not copied.
+func (m *Migrator) CopyTo(ctx context.Context, target *kivik.DB) (*Result, error) {
+	return m.run(ctx, func(doc *document, result *Result) error {
+		var body map[string]interface{}
+		if err := json.Unmarshal(doc.raw, &body); err != nil {
+			return err
+		}
+		atts := make(kivik.Attachments, len(doc.Attachments))
+		for _, name := range doc.filenames() {
+			att, err := m.attachment(ctx, doc, name)
+			if err != nil {
+				return err
+			}
+			// Attachment.MarshalJSON reads and closes the content as the
+			// document is sent.
+			atts[name] = att
+			result.Attachments++
+			result.Bytes += att.Size
+		}
+		body["_attachments"] = atts
+		if _, err := target.Put(ctx, doc.ID, body, kivik.Options{"new_edits": false}); err != nil {
+			for _, att := range atts {
+				_ = att.Content.Close()
+			}
+			return errors.Wrapf(err, "attmigrate: %s", doc.ID)
+		}
+		return nil
+	})
+}
+
+// attachment fetches the content of attachment name of doc.
+func (m *Migrator) attachment(ctx context.Context, doc *document, name string) (*kivik.Attachment, error) {
+	stub := doc.Attachments[name]
+	att, err := m.source.GetAttachment(ctx, doc.ID, name, kivik.Options{"rev": doc.Rev})
+	if err != nil {
+		return nil, errors.Wrapf(err, "attmigrate: %s/%s", doc.ID, name)
+	}
+	att.Filename = name
+	att.Stub = false
+	att.RevPos = stub.RevPos
+	if stub.Digest != "" {
+		att.Digest = stub.Digest
+	}
+	if att.ContentType == "" {
+		att.ContentType = stub.ContentType
+	}
+	if att.Size < 0 {
+		att.Size = stub.Size
+	}
+	return att, nil
+}
+
+// run calls migrate for each document of the source with attachments, after
+// the checkpoint, saving the checkpoint after each.
+func (m *Migrator) run(ctx context.Context, migrate func(*document, *Result) error) (*Result, error) {
+	if m.batchSize < 1 {
+		return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: "attmigrate: batch size must be positive"}
+	}
+	var after string
+	if m.checkpoint != nil {
+		var err error
+		if after, err = m.checkpoint.Load(ctx); err != nil {
+			return nil, err
+		}
+	}
+	result := &Result{}
+	for {
+		docs, err := m.batch(ctx, after)
+		if err != nil {
+			return result, err
+		}
+		if len(docs) == 0 {
+			return result, nil
+		}
+		for _, doc := range docs {
+			after = doc.ID
+			if len(doc.Attachments) == 0 {
+				continue
+			}
+			if err := migrate(doc, result); err != nil {
+				return result, err
+			}
+			result.Docs++
+			if m.checkpoint != nil {
+				if err := m.checkpoint.Save(ctx, doc.ID); err != nil {
+					return result, err
+				}
+			}
+		}
+	}
+}
+
+// batch reads the next batch of documents after the document ID after.
+func (m *Migrator) batch(ctx context.Context, after string) ([]*document, error) {
+	opts := kivik.Options{"include_docs": true, "limit": m.batchSize}
+	if after != "" {
+		// The start key is inclusive, so read one more, and skip it.
+		opts["startkey"] = after
+		opts["limit"] = m.batchSize + 1
+	}
+	rows, err := m.source.AllDocs(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() // nolint: errcheck
+	var docs []*document
+	for rows.Next() {
+		if rows.ID() == after {
+			continue
+		}
+		var raw json.RawMessage
+		if err := rows.ScanDoc(&raw); err != nil {
+			return nil, err
+		}
+		doc := &document{raw: append(json.RawMessage(nil), raw...)}
+		if err := json.Unmarshal(raw, doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// localCheckpoint stores progress in a local document.
+type localCheckpoint struct {
+	db    *kivik.DB
+	docID string
+}
+
+// LocalCheckpoint returns a Checkpoint which stores progress in the local
+// document _local/<name> of db, which may be the source or the target.
+func LocalCheckpoint(db *kivik.DB, name string) Checkpoint {
+	return &localCheckpoint{db: db, docID: "_local/" + name}
+}
+
+type checkpointDoc struct {
+	Rev   string `json:"_rev,omitempty"`
+	After string `json:"after"`
+}
+
+func (c *localCheckpoint) get(ctx context.Context) (*checkpointDoc, error) {
+	doc := &checkpointDoc{}
+	err := c.db.Get(ctx, c.docID).ScanDoc(doc)
+	if kivik.IsNotFound(err) {
+		return &checkpointDoc{}, nil
+	}
+	return doc, err
+}
+
+func (c *localCheckpoint) Load(ctx context.Context) (string, error) {
+	doc, err := c.get(ctx)
+	if err != nil {
+		return "", err
+	}
+	return doc.After, nil
+}
+
+func (c *localCheckpoint) Save(ctx context.Context, docID string) error {
+	doc, err := c.get(ctx)
+	if err != nil {
+		return err
+	}
+	doc.After = docID
+	_, err = c.db.Put(ctx, c.docID, doc)
+	return err
+}
@@ -0,0 +1,243 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package attmigrate
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	_ "github.com/go-kivik/kivik/v4/x/fsdb"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+func newDB(t *testing.T, driver, dsn string) *kivik.DB {
+	t.Helper()
+	client, err := kivik.New(driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	return client.DB(context.Background(), "test")
+}
+
+func putAttachment(t *testing.T, db *kivik.DB, docID, rev, filename, content string) string {
+	t.Helper()
+	rev, err := db.PutAttachment(context.Background(), docID, rev, &kivik.Attachment{
+		Filename:    filename,
+		ContentType: "text/plain",
+		Content:     ioutil.NopCloser(strings.NewReader(content)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rev
+}
+
+// newSource returns a database with documents a, without attachments, b,
+// with attachments added at revisions 2 and 3, and c, with one attachment.
+func newSource(t *testing.T) *kivik.DB {
+	t.Helper()
+	db := newDB(t, "memory", "")
+	ctx := context.Background()
+	if _, err := db.Put(ctx, "a", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	rev, err := db.Put(ctx, "b", map[string]string{"name": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rev = putAttachment(t, db, "b", rev, "one.txt", "one")
+	putAttachment(t, db, "b", rev, "two.txt", "two!")
+	putAttachment(t, db, "c", "", "three.txt", "three")
+	return db
+}
+
+type migrated struct {
+	DocID, Filename, Content string
+	RevPos                   int64
+	Digest                   bool
+}
+
+func collect(into *[]migrated, failAt string) AttachmentFunc {
+	return func(_ context.Context, docID, rev string, att *kivik.Attachment) error {
+		if docID == failAt {
+			return errors.New("sink unavailable")
+		}
+		if rev == "" {
+			return errors.New("no rev")
+		}
+		content, err := ioutil.ReadAll(att.Content)
+		if err != nil {
+			return err
+		}
+		*into = append(*into, migrated{
+			DocID:    docID,
+			Filename: att.Filename,
+			Content:  string(content),
+			RevPos:   att.RevPos,
+			Digest:   strings.HasPrefix(att.Digest, "md5-"),
+		})
+		return nil
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	type tt struct {
+		batchSize int
+		failAt    string
+		expected  []migrated
+		result    *Result
+		status    int
+		err       string
+	}
+	all := []migrated{
+		{DocID: "b", Filename: "one.txt", Content: "one", RevPos: 2, Digest: true},
+		{DocID: "b", Filename: "two.txt", Content: "two!", RevPos: 3, Digest: true},
+		{DocID: "c", Filename: "three.txt", Content: "three", RevPos: 1, Digest: true},
+	}
+	tests := testy.NewTable()
+	tests.Add("default batch size", tt{
+		expected: all,
+		result:   &Result{Docs: 2, Attachments: 3, Bytes: 12},
+	})
+	tests.Add("batches of one", tt{
+		batchSize: 1,
+		expected:  all,
+		result:    &Result{Docs: 2, Attachments: 3, Bytes: 12},
+	})
+	tests.Add("sink failure", tt{
+		failAt:   "c",
+		expected: all[:2],
+		result:   &Result{Docs: 1, Attachments: 2, Bytes: 7},
+		status:   http.StatusInternalServerError,
+		err:      "attmigrate: c/three.txt: sink unavailable",
+	})
+	tests.Add("invalid batch size", tt{
+		batchSize: -1,
+		status:    http.StatusBadRequest,
+		err:       "attmigrate: batch size must be positive",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		m := New(newSource(t))
+		if tt.batchSize != 0 {
+			m = m.WithBatchSize(tt.batchSize)
+		}
+		var got []migrated
+		result, err := m.Attachments(context.Background(), collect(&got, tt.failAt))
+		if d := testy.DiffInterface(tt.expected, got); d != nil {
+			t.Error(d)
+		}
+		if d := testy.DiffInterface(tt.result, result); d != nil {
+			t.Error(d)
+		}
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
+
+func TestResume(t *testing.T) {
+	ctx := context.Background()
+	source := newSource(t)
+	m := New(source).WithBatchSize(1).WithCheckpoint(LocalCheckpoint(source, "attmigrate"))
+
+	var got []migrated
+	if _, err := m.Attachments(ctx, collect(&got, "c")); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if len(got) != 2 {
+		t.Fatalf("Unexpected first run: %v", got)
+	}
+	got = nil
+	result, err := m.Attachments(ctx, collect(&got, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []migrated{{DocID: "c", Filename: "three.txt", Content: "three", RevPos: 1, Digest: true}}
+	if d := testy.DiffInterface(expected, got); d != nil {
+		t.Error(d)
+	}
+	if result.Docs != 1 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	// A completed migration has nothing left to do.
+	got = nil
+	if _, err := m.Attachments(ctx, collect(&got, "")); err != nil {
+		t.Fatal(err)
+	}
+	if got != nil {
+		t.Errorf("Unexpected attachments: %v", got)
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "attmigrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	source := newSource(t)
+	target := newDB(t, "fs", dir)
+
+	result, err := New(source).CopyTo(ctx, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(&Result{Docs: 2, Attachments: 3, Bytes: 12}, result); d != nil {
+		t.Error(d)
+	}
+	for _, att := range [][2]string{{"b", "one.txt"}, {"b", "two.txt"}, {"c", "three.txt"}} {
+		want, err := source.GetAttachmentMeta(ctx, att[0], att[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := target.GetAttachment(ctx, att[0], att[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = got.Content.Close()
+		if got.Digest != want.Digest || got.RevPos != want.RevPos || got.Size != want.Size {
+			t.Errorf("%s/%s: got %s revpos %d, want %s revpos %d", att[0], att[1], got.Digest, got.RevPos, want.Digest, want.RevPos)
+		}
+	}
+	_, sourceRev, err := source.GetMeta(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, targetRev, err := target.GetMeta(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sourceRev != targetRev {
+		t.Errorf("Revision changed from %s to %s", sourceRev, targetRev)
+	}
+	if _, _, err := target.GetMeta(ctx, "a"); !kivik.IsNotFound(err) {
+		t.Errorf("Document without attachments copied: %v", err)
+	}
+
+	// Copying again is harmless.
+	if _, err := New(source).CopyTo(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+}
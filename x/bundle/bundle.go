@@ -0,0 +1,286 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package bundle snapshots the non-document state of a CouchDB server, and
+// re-applies it elsewhere, for promoting configuration between environments,
+// such as from development to staging to production.
+//
+// A Bundle holds the security objects, design documents and Mango index
+// definitions of selected databases, and selected server config keys. It is
+// written as indented JSON with sorted keys, so that it may be kept under
+// version control, and changes reviewed as diffs.
+package bundle // import "github.com/go-kivik/kivik/v4/x/bundle"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/errors"
+)
+
+// FormatVersion is the version of the bundle format written by this package.
+const FormatVersion = 1
+
+// Bundle is a snapshot of non-document server state.
+type Bundle struct {
+	// Version is the bundle format version.
+	Version int `json:"version"`
+	// Databases holds the state of each database, keyed by name.
+	Databases map[string]*Database `json:"databases,omitempty"`
+	// Config holds the selected config values, keyed by section, then key.
+	Config map[string]map[string]string `json:"config,omitempty"`
+}
+
+// Database is the non-document state of a single database.
+type Database struct {
+	Security *kivik.Security `json:"security,omitempty"`
+	// DesignDocs holds the design documents, keyed by ID, without their _rev
+	// fields. Design documents holding Mango indexes are not included, as the
+	// indexes are recorded in Indexes.
+	DesignDocs map[string]json.RawMessage `json:"design_docs,omitempty"`
+	// Indexes holds the Mango index definitions.
+	Indexes []kivik.Index `json:"indexes,omitempty"`
+}
+
+// Selection chooses the state to include in a bundle.
+type Selection struct {
+	// Databases lists the databases to include.
+	Databases []string
+	// Node is the node whose config is read, or written by Apply. If empty,
+	// "_local" is used.
+	Node string
+	// Config lists the config keys to include, in the form "section/key".
+	Config []string
+}
+
+func (s Selection) node() string {
+	if s.Node == "" {
+		return "_local"
+	}
+	return s.Node
+}
+
+// Export reads the selected state from client.
+func Export(ctx context.Context, client *kivik.Client, sel Selection) (*Bundle, error) {
+	b := &Bundle{Version: FormatVersion}
+	for _, name := range sel.Databases {
+		db, err := exportDB(ctx, client.DB(ctx, name))
+		if err != nil {
+			return nil, errors.Wrapf(err, "bundle: %s", name)
+		}
+		if b.Databases == nil {
+			b.Databases = make(map[string]*Database, len(sel.Databases))
+		}
+		b.Databases[name] = db
+	}
+	for _, sk := range sel.Config {
+		section, key, err := splitKey(sk)
+		if err != nil {
+			return nil, err
+		}
+		value, err := client.ConfigValue(ctx, sel.node(), section, key)
+		if kivik.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "bundle: config %s", sk)
+		}
+		if b.Config == nil {
+			b.Config = make(map[string]map[string]string)
+		}
+		if b.Config[section] == nil {
+			b.Config[section] = make(map[string]string)
+		}
+		b.Config[section][key] = value
+	}
+	return b, nil
+}
+
+func splitKey(sk string) (section, key string, err error) {
+	parts := strings.SplitN(sk, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("bundle: invalid config key %q; expected section/key", sk)}
+	}
+	return parts[0], parts[1], nil
+}
+
+func exportDB(ctx context.Context, db *kivik.DB) (*Database, error) {
+	sec, err := db.Security(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := &Database{Security: sec}
+	rows, err := db.AllDocs(ctx, kivik.Options{
+		"startkey":     "_design/",
+		"endkey":       "_design0",
+		"include_docs": true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var doc map[string]interface{}
+		if err := rows.ScanDoc(&doc); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		if doc["language"] == "query" {
+			continue
+		}
+		delete(doc, "_rev")
+		raw, err := json.Marshal(doc)
+		if err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		if out.DesignDocs == nil {
+			out.DesignDocs = make(map[string]json.RawMessage)
+		}
+		out.DesignDocs[rows.ID()] = raw
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	indexes, err := db.GetIndexes(ctx)
+	if kivik.StatusCode(err) == http.StatusNotImplemented {
+		return out, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range indexes {
+		if idx.Type != "special" {
+			out.Indexes = append(out.Indexes, idx)
+		}
+	}
+	return out, nil
+}
+
+// Apply writes the state in b to client. Databases which do not exist are
+// created. Design documents are created or replaced, and indexes created if
+// missing. Existing design documents and indexes not in b are left alone.
+// Config values are written to node, or "_local" if node is empty.
+func Apply(ctx context.Context, client *kivik.Client, b *Bundle, node string) error {
+	if b.Version != FormatVersion {
+		return &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("bundle: unsupported format version %d", b.Version)}
+	}
+	names := make([]string, 0, len(b.Databases))
+	for name := range b.Databases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := applyDB(ctx, client, name, b.Databases[name]); err != nil {
+			return errors.Wrapf(err, "bundle: %s", name)
+		}
+	}
+	sel := Selection{Node: node}
+	for _, section := range sortedKeys(b.Config) {
+		for _, key := range sortedKeys(b.Config[section]) {
+			if _, err := client.SetConfigValue(ctx, sel.node(), section, key, b.Config[section][key]); err != nil {
+				return errors.Wrapf(err, "bundle: config %s/%s", section, key)
+			}
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch t := m.(type) {
+	case map[string]map[string]string:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	case map[string]string:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	case map[string]json.RawMessage:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func applyDB(ctx context.Context, client *kivik.Client, name string, state *Database) error {
+	exists, err := client.DBExists(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := client.CreateDB(ctx, name); err != nil {
+			return err
+		}
+	}
+	db := client.DB(ctx, name)
+	if state.Security != nil {
+		if err := db.SetSecurity(ctx, state.Security); err != nil {
+			return err
+		}
+	}
+	for _, id := range sortedKeys(state.DesignDocs) {
+		if err := putDesignDoc(ctx, db, id, state.DesignDocs[id]); err != nil {
+			return err
+		}
+	}
+	for _, idx := range state.Indexes {
+		if err := db.CreateIndex(ctx, idx.DesignDoc, idx.Name, idx.Definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putDesignDoc writes doc as id, replacing the current version, if any.
+func putDesignDoc(ctx context.Context, db *kivik.DB, id string, raw json.RawMessage) error {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+	_, rev, err := db.GetMeta(ctx, id)
+	if err != nil && !kivik.IsNotFound(err) {
+		return err
+	}
+	if rev != "" {
+		doc["_rev"] = rev
+	}
+	_, err = db.Put(ctx, id, doc)
+	return err
+}
+
+// Write writes b to w as indented JSON.
+func Write(w io.Writer, b *Bundle) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// Read reads a bundle written by Write.
+func Read(r io.Reader) (*Bundle, error) {
+	b := &Bundle{}
+	if err := json.NewDecoder(r).Decode(b); err != nil {
+		return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	if b.Version != FormatVersion {
+		return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("bundle: unsupported format version %d", b.Version)}
+	}
+	return b, nil
+}
@@ -0,0 +1,213 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+// config is the server config served by the "bundletest" driver.
+var config = map[string]map[string]string{}
+
+type configDriver struct{}
+
+func (configDriver) NewClient(string) (driver.Client, error) {
+	return &mock.Configer{
+		Client: &mock.Client{},
+		ConfigValueFunc: func(_ context.Context, node, section, key string) (string, error) {
+			if v, ok := config[node+"/"+section][key]; ok {
+				return v, nil
+			}
+			return "", &kivik.Error{HTTPStatus: http.StatusNotFound, Message: "unknown_config_value"}
+		},
+		SetConfigValueFunc: func(_ context.Context, node, section, key, value string) (string, error) {
+			if config[node+"/"+section] == nil {
+				config[node+"/"+section] = map[string]string{}
+			}
+			old := config[node+"/"+section][key]
+			config[node+"/"+section][key] = value
+			return old, nil
+		},
+	}, nil
+}
+
+func init() {
+	kivik.Register("bundletest", configDriver{})
+}
+
+func newClient(t *testing.T) *kivik.Client {
+	t.Helper()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestExportApply(t *testing.T) {
+	ctx := context.Background()
+	src := newClient(t)
+	if err := src.CreateDB(ctx, "app"); err != nil {
+		t.Fatal(err)
+	}
+	db := src.DB(ctx, "app")
+	sec := &kivik.Security{Admins: kivik.Members{Roles: []string{"admin"}}}
+	if err := db.SetSecurity(ctx, sec); err != nil {
+		t.Fatal(err)
+	}
+	ddoc := map[string]interface{}{"views": map[string]interface{}{"v": map[string]string{"map": "function(doc){}"}}}
+	if _, err := db.Put(ctx, "_design/app", ddoc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Put(ctx, "doc", map[string]string{"not": "exported"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateIndex(ctx, "idx", "by_n", map[string]interface{}{"fields": []string{"n"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Export(ctx, src, Selection{Databases: []string{"app"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := Write(buf, b); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "version": 1,
+  "databases": {
+    "app": {
+      "security": {
+        "admins": {
+          "roles": [
+            "admin"
+          ]
+        },
+        "members": {}
+      },
+      "design_docs": {
+        "_design/app": {
+          "_id": "_design/app",
+          "views": {
+            "v": {
+              "map": "function(doc){}"
+            }
+          }
+        }
+      },
+      "indexes": [
+        {
+          "ddoc": "_design/idx",
+          "name": "by_n",
+          "type": "json",
+          "def": {
+            "fields": [
+              "n"
+            ]
+          }
+        }
+      ]
+    }
+  }
+}
+`
+	if d := testy.DiffText(expected, buf.String()); d != nil {
+		t.Error(d)
+	}
+	b, err = Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newClient(t)
+	for i := 0; i < 2; i++ {
+		// Applying twice must update, rather than conflict.
+		if err := Apply(ctx, dst, b, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	again, err := Export(ctx, dst, Selection{Databases: []string{"app"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffAsJSON(b, again); d != nil {
+		t.Error(d)
+	}
+	if err := dst.DB(ctx, "app").Get(ctx, "doc").Err; !kivik.IsNotFound(err) {
+		t.Errorf("Expected documents not to be copied, got %v", err)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	ctx := context.Background()
+	config = map[string]map[string]string{
+		"_local/couchdb": {"max_document_size": "4194304", "uuid": "secret"},
+	}
+	client, err := kivik.New("bundletest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Export(ctx, client, Selection{Config: []string{"couchdb/max_document_size", "couchdb/missing"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Bundle{
+		Version: FormatVersion,
+		Config:  map[string]map[string]string{"couchdb": {"max_document_size": "4194304"}},
+	}
+	if d := testy.DiffInterface(expected, b); d != nil {
+		t.Error(d)
+	}
+	if err := Apply(ctx, client, b, "node2"); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(map[string]string{"max_document_size": "4194304"}, config["node2/couchdb"]); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t)
+	t.Run("invalid config key", func(t *testing.T) {
+		_, err := Export(ctx, client, Selection{Config: []string{"couchdb"}})
+		testy.StatusError(t, `bundle: invalid config key "couchdb"; expected section/key`, http.StatusBadRequest, err)
+	})
+	t.Run("missing database", func(t *testing.T) {
+		_, err := Export(ctx, client, Selection{Databases: []string{"missing"}})
+		testy.StatusError(t, "bundle: missing: database does not exist", http.StatusNotFound, err)
+	})
+	t.Run("unsupported version", func(t *testing.T) {
+		err := Apply(ctx, client, &Bundle{Version: 2}, "")
+		testy.StatusError(t, "bundle: unsupported format version 2", http.StatusBadRequest, err)
+	})
+	t.Run("read invalid", func(t *testing.T) {
+		_, err := Read(bytes.NewReader([]byte(`{"version":0}`)))
+		testy.StatusError(t, "bundle: unsupported format version 0", http.StatusBadRequest, err)
+	})
+	t.Run("read invalid JSON", func(t *testing.T) {
+		_, err := Read(bytes.NewReader([]byte(`}`)))
+		testy.StatusError(t, "invalid character '}' looking for beginning of value", http.StatusBadRequest, err)
+	})
+}
@@ -0,0 +1,229 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package cache provides a read-through cache for documents and attachments.
+//
+// Cached entries are revalidated on every read with a conditional GET, which
+// sends the cached revision or attachment digest as If-None-Match, and
+// serves the cached body when the server responds 304 (not modified). The
+// full body is transferred only when the entry is missing or stale. This
+// saves bandwidth and server work for large, frequently read documents, but
+// does not save a round trip. A driver which ignores If-None-Match returns
+// the full body every time, which is cached but never served from the cache.
+//
+// Writes made through the DB invalidate the affected entries. Writes made
+// by other clients are detected by revalidation.
+package cache // import "github.com/go-kivik/kivik/v4/x/cache"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// optionIfNoneMatch is the option by which the CouchDB driver sends an
+// If-None-Match header.
+const optionIfNoneMatch = "If-None-Match"
+
+// Entry is a cached document or attachment body.
+type Entry struct {
+	// ETag identifies the cached version: the rev of a document, or the
+	// digest of an attachment.
+	ETag string
+	// Body is the raw document JSON, or attachment content.
+	Body []byte
+	// ContentType is the content type of an attachment.
+	ContentType string
+}
+
+// Store is a pluggable cache store. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Get returns the entry stored for key, if any.
+	Get(key string) (*Entry, bool)
+	// Set stores entry for key, replacing any existing entry.
+	Set(key string, entry *Entry)
+	// Delete removes the entry for key, if any.
+	Delete(key string)
+}
+
+// DB wraps a database, caching reads made through it in a Store.
+type DB struct {
+	db    *kivik.DB
+	store Store
+}
+
+// New returns a DB which caches reads from db in store. A store may be
+// shared by several DBs.
+func New(db *kivik.DB, store Store) *DB {
+	return &DB{db: db, store: store}
+}
+
+// DB returns the underlying database.
+func (c *DB) DB() *kivik.DB {
+	return c.db
+}
+
+func (c *DB) docKey(docID string) string {
+	return c.db.Name() + "\x00" + docID
+}
+
+func (c *DB) attKey(docID, filename string) string {
+	return c.db.Name() + "\x00" + docID + "\x00" + filename
+}
+
+// cacheable reports whether a read with options may be cached. Options,
+// including defaults set on the underlying DB with WithOptions, may alter the
+// response, so only reads without any are.
+func (c *DB) cacheable(options []kivik.Options) bool {
+	for _, opts := range options {
+		if len(opts) > 0 {
+			return false
+		}
+	}
+	return len(c.db.Options()) == 0
+}
+
+// ifNoneMatch returns the options for a conditional GET of the version of
+// entry, or nil if there is no entry.
+func ifNoneMatch(entry *Entry, ok bool) kivik.Options {
+	if !ok {
+		return nil
+	}
+	return kivik.Options{optionIfNoneMatch: `"` + entry.ETag + `"`}
+}
+
+// Get fetches docID, as DB.Get, serving the body from the cache if the
+// cached rev is current. Requests with options, or through a DB with default
+// options, are not cached, as options may alter the response.
+func (c *DB) Get(ctx context.Context, docID string, options ...kivik.Options) *kivik.Row {
+	if !c.cacheable(options) {
+		return c.db.Get(ctx, docID, options...)
+	}
+	key := c.docKey(docID)
+	entry, ok := c.store.Get(key)
+	row := c.db.Get(ctx, docID, ifNoneMatch(entry, ok))
+	if row.Err != nil {
+		if ok && kivik.StatusCode(row.Err) == http.StatusNotModified {
+			return &kivik.Row{
+				ContentLength: int64(len(entry.Body)),
+				Rev:           entry.ETag,
+				Body:          ioutil.NopCloser(bytes.NewReader(entry.Body)),
+			}
+		}
+		if kivik.IsNotFound(row.Err) {
+			c.store.Delete(key)
+		}
+		return row
+	}
+	body, err := ioutil.ReadAll(row.Body)
+	_ = row.Body.Close()
+	if err != nil {
+		return &kivik.Row{Err: err}
+	}
+	rev := row.Rev
+	if rev == "" {
+		var doc struct {
+			Rev string `json:"_rev"`
+		}
+		_ = json.Unmarshal(body, &doc)
+		rev = doc.Rev
+	}
+	if rev != "" {
+		c.store.Set(key, &Entry{ETag: rev, Body: body})
+	}
+	return &kivik.Row{
+		ContentLength: int64(len(body)),
+		Rev:           rev,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+	}
+}
+
+// GetMeta returns the size and current rev of docID, as DB.GetMeta. It is
+// never served from the cache.
+func (c *DB) GetMeta(ctx context.Context, docID string, options ...kivik.Options) (int64, string, error) {
+	return c.db.GetMeta(ctx, docID, options...)
+}
+
+// GetAttachment fetches an attachment, as DB.GetAttachment, serving the
+// content from the cache if the cached digest is current. Requests with
+// options, or through a DB with default options, are not cached.
+func (c *DB) GetAttachment(ctx context.Context, docID, filename string, options ...kivik.Options) (*kivik.Attachment, error) {
+	if !c.cacheable(options) {
+		return c.db.GetAttachment(ctx, docID, filename, options...)
+	}
+	key := c.attKey(docID, filename)
+	cached, ok := c.store.Get(key)
+	att, err := c.db.GetAttachment(ctx, docID, filename, ifNoneMatch(cached, ok))
+	if err != nil {
+		if ok && kivik.StatusCode(err) == http.StatusNotModified {
+			return cachedAttachment(filename, cached), nil
+		}
+		if kivik.IsNotFound(err) {
+			c.store.Delete(key)
+		}
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(att.Content)
+	_ = att.Content.Close()
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{ETag: att.Digest, Body: body, ContentType: att.ContentType}
+	if att.Digest != "" {
+		c.store.Set(key, entry)
+	}
+	att.Content = ioutil.NopCloser(bytes.NewReader(body))
+	return att, nil
+}
+
+func cachedAttachment(filename string, entry *Entry) *kivik.Attachment {
+	return &kivik.Attachment{
+		Filename:    filename,
+		ContentType: entry.ContentType,
+		Content:     ioutil.NopCloser(bytes.NewReader(entry.Body)),
+		Size:        int64(len(entry.Body)),
+		Digest:      entry.ETag,
+	}
+}
+
+// Put writes docID, as DB.Put, invalidating any cached version.
+func (c *DB) Put(ctx context.Context, docID string, doc interface{}, options ...kivik.Options) (string, error) {
+	c.store.Delete(c.docKey(docID))
+	return c.db.Put(ctx, docID, doc, options...)
+}
+
+// Delete deletes docID, as DB.Delete, invalidating any cached version.
+func (c *DB) Delete(ctx context.Context, docID, rev string, options ...kivik.Options) (string, error) {
+	c.store.Delete(c.docKey(docID))
+	return c.db.Delete(ctx, docID, rev, options...)
+}
+
+// PutAttachment writes an attachment, as DB.PutAttachment, invalidating the
+// cached document and attachment.
+func (c *DB) PutAttachment(ctx context.Context, docID, rev string, att *kivik.Attachment, options ...kivik.Options) (string, error) {
+	c.store.Delete(c.docKey(docID))
+	c.store.Delete(c.attKey(docID, att.Filename))
+	return c.db.PutAttachment(ctx, docID, rev, att, options...)
+}
+
+// DeleteAttachment deletes an attachment, as DB.DeleteAttachment,
+// invalidating the cached document and attachment.
+func (c *DB) DeleteAttachment(ctx context.Context, docID, rev, filename string, options ...kivik.Options) (string, error) {
+	c.store.Delete(c.docKey(docID))
+	c.store.Delete(c.attKey(docID, filename))
+	return c.db.DeleteAttachment(ctx, docID, rev, filename, options...)
+}
@@ -0,0 +1,177 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/memtest"
+)
+
+func getDoc(t *testing.T, c *DB, docID string) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := c.Get(context.Background(), docID).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	delete(doc, "_rev")
+	return doc
+}
+
+// poison replaces the cached body for key, so that serving from the cache
+// can be detected.
+func poison(t *testing.T, store *LRU, key, body string) {
+	t.Helper()
+	entry, ok := store.Get(key)
+	if !ok {
+		t.Fatalf("Expected %q to be cached", key)
+	}
+	store.Set(key, &Entry{ETag: entry.ETag, Body: []byte(body), ContentType: entry.ContentType})
+}
+
+func TestGet(t *testing.T) {
+	ctx := context.Background()
+	db := memtest.NewDB(t)
+	store := NewLRU(10)
+	c := New(db, store)
+	rev, err := c.Put(ctx, "foo", map[string]string{"v": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("miss", func(t *testing.T) {
+		if d := testy.DiffInterface(map[string]interface{}{"_id": "foo", "v": "1"}, getDoc(t, c, "foo")); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("hit", func(t *testing.T) {
+		poison(t, store, c.docKey("foo"), `{"_id":"foo","v":"cached"}`)
+		if d := testy.DiffInterface(map[string]interface{}{"_id": "foo", "v": "cached"}, getDoc(t, c, "foo")); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("stale", func(t *testing.T) {
+		// Written directly, bypassing invalidation.
+		if rev, err = db.Put(ctx, "foo", map[string]string{"_rev": rev, "v": "2"}); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(map[string]interface{}{"_id": "foo", "v": "2"}, getDoc(t, c, "foo")); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("with options", func(t *testing.T) {
+		poison(t, store, c.docKey("foo"), `{"_id":"foo","v":"cached"}`)
+		var doc map[string]interface{}
+		if err := c.Get(ctx, "foo", kivik.Options{"revs": true}).ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc["v"] != "2" {
+			t.Errorf("Expected uncached read, got %v", doc["v"])
+		}
+	})
+	t.Run("with default options", func(t *testing.T) {
+		poison(t, store, c.docKey("foo"), `{"_id":"foo","v":"cached"}`)
+		withRevs := New(db.WithOptions(kivik.Options{"revs": true}), store)
+		var doc map[string]interface{}
+		if err := withRevs.Get(ctx, "foo").ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc["v"] != "2" || doc["_revisions"] == nil {
+			t.Errorf("Expected uncached read, got %v", doc)
+		}
+	})
+	t.Run("invalidated by Put", func(t *testing.T) {
+		if rev, err = c.Put(ctx, "foo", map[string]string{"_rev": rev, "v": "3"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := store.Get(c.docKey("foo")); ok {
+			t.Error("Expected entry to be invalidated")
+		}
+	})
+	t.Run("deleted", func(t *testing.T) {
+		getDoc(t, c, "foo")
+		if _, err := db.Delete(ctx, "foo", rev); err != nil {
+			t.Fatal(err)
+		}
+		err := c.Get(ctx, "foo").Err
+		testy.StatusError(t, "deleted", http.StatusNotFound, err)
+		if _, ok := store.Get(c.docKey("foo")); ok {
+			t.Error("Expected entry to be removed")
+		}
+	})
+}
+
+func TestGetAttachment(t *testing.T) {
+	ctx := context.Background()
+	db := memtest.NewDB(t)
+	store := NewLRU(10)
+	c := New(db, store)
+	rev, err := c.PutAttachment(ctx, "foo", "", &kivik.Attachment{
+		Filename:    "a.txt",
+		ContentType: "text/plain",
+		Content:     ioutil.NopCloser(strings.NewReader("hello")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(t *testing.T) string {
+		t.Helper()
+		att, err := c.GetAttachment(ctx, "foo", "a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer att.Content.Close() // nolint: errcheck
+		body, err := ioutil.ReadAll(att.Content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if att.ContentType != "text/plain" {
+			t.Errorf("Unexpected content type: %s", att.ContentType)
+		}
+		return string(body)
+	}
+
+	if got := read(t); got != "hello" {
+		t.Errorf("Unexpected content: %s", got)
+	}
+	poison(t, store, c.attKey("foo", "a.txt"), "cached")
+	if got := read(t); got != "cached" {
+		t.Errorf("Expected cached content, got %s", got)
+	}
+	if _, err := db.PutAttachment(ctx, "foo", rev, &kivik.Attachment{
+		Filename:    "a.txt",
+		ContentType: "text/plain",
+		Content:     ioutil.NopCloser(strings.NewReader("changed")),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t); got != "changed" {
+		t.Errorf("Expected fresh content, got %s", got)
+	}
+	poison(t, store, c.attKey("foo", "a.txt"), "cached")
+	att, err := New(db.WithOptions(kivik.Options{"rev": rev}), store).GetAttachment(ctx, "foo", "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer att.Content.Close() // nolint: errcheck
+	if body, _ := ioutil.ReadAll(att.Content); string(body) != "hello" {
+		t.Errorf("Expected uncached content at rev, got %s", body)
+	}
+}
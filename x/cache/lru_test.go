@@ -0,0 +1,61 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package cache
+
+import (
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestLRU(t *testing.T) {
+	c := NewLRU(2)
+	c.Set("a", &Entry{ETag: "1"})
+	c.Set("b", &Entry{ETag: "2"})
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Expected a to be cached")
+	}
+	// b is now least recently used, so is evicted.
+	c.Set("c", &Entry{ETag: "3"})
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	c.Set("a", &Entry{ETag: "4"})
+	entry, ok := c.Get("a")
+	if !ok {
+		t.Fatal("Expected a to be cached")
+	}
+	if d := testy.DiffInterface(&Entry{ETag: "4"}, entry); d != nil {
+		t.Error(d)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Unexpected length: %d", c.Len())
+	}
+	c.Delete("a")
+	c.Delete("missing")
+	if _, ok := c.Get("a"); ok {
+		t.Error("Expected a to be deleted")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Unexpected length: %d", c.Len())
+	}
+}
+
+func TestNewLRUMinimumSize(t *testing.T) {
+	c := NewLRU(0)
+	c.Set("a", &Entry{})
+	c.Set("b", &Entry{})
+	if c.Len() != 1 {
+		t.Errorf("Unexpected length: %d", c.Len())
+	}
+}
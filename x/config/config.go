@@ -0,0 +1,194 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package config keeps a Go value in sync with a configuration document, so
+// that services can be reconfigured without restarting.
+//
+// Bind loads the document into a target value, and watches it for updates.
+// Each new revision is decoded into a fresh value, checked by any Validate
+// hooks, and only then copied into the target, so readers never see a
+// partial or rejected configuration. Readers must hold the Binding's read
+// lock while using the target:
+//
+//	var cfg ServiceConfig
+//	b, err := config.Bind(ctx, db, "service-config", &cfg, config.Hooks{
+//		OnChange: func(rev string) { log.Printf("config updated to %s", rev) },
+//	})
+//	...
+//	b.RLock()
+//	timeout := cfg.Timeout
+//	b.RUnlock()
+package config // import "github.com/go-kivik/kivik/v4/x/config"
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// Hooks are called as the bound document changes. Any field may be nil.
+type Hooks struct {
+	// Validate is called with a pointer to each newly decoded value, before
+	// it is copied into the target. Returning an error rejects the revision.
+	Validate func(candidate interface{}) error
+	// OnChange is called after the target has been updated to rev. It is not
+	// called for the initial load.
+	OnChange func(rev string)
+	// OnError is called when a revision cannot be loaded or is rejected, or
+	// the document is deleted. The target keeps its previous value.
+	OnError func(err error)
+}
+
+// Binding keeps a target value updated from a document.
+type Binding struct {
+	db     *kivik.DB
+	docID  string
+	target reflect.Value
+	hooks  []Hooks
+
+	mu  sync.RWMutex
+	rev string
+
+	watcher *kivik.DocWatcher
+	done    chan struct{}
+	err     error
+	closed  bool
+}
+
+// Bind loads the document docID into target, which must be a non-nil
+// pointer, then keeps it updated until ctx is cancelled or the Binding is
+// closed. It returns an error if the document cannot be loaded, or is
+// rejected by a Validate hook. The hooks are called in order.
+func Bind(ctx context.Context, db *kivik.DB, docID string, target interface{}, hooks ...Hooks) (*Binding, error) {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("config: target must be a non-nil pointer, not %T", target)}
+	}
+	// The document is watched before it is loaded, so that no update made
+	// in between is missed.
+	watcher, err := db.WatchDocs(ctx, []string{docID})
+	if err != nil {
+		return nil, err
+	}
+	b := &Binding{
+		db:      db,
+		docID:   docID,
+		target:  v.Elem(),
+		hooks:   hooks,
+		watcher: watcher,
+		done:    make(chan struct{}),
+	}
+	if err := b.load(ctx); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+	go b.watch(ctx)
+	return b, nil
+}
+
+// RLock locks the target for reading. Updates wait until RUnlock is called.
+func (b *Binding) RLock() {
+	b.mu.RLock()
+}
+
+// RUnlock undoes a single RLock call.
+func (b *Binding) RUnlock() {
+	b.mu.RUnlock()
+}
+
+// Rev returns the revision of the document currently held in the target.
+func (b *Binding) Rev() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.rev
+}
+
+// Err returns the error, if any, which stopped the Binding from watching for
+// updates, other than it being closed.
+func (b *Binding) Err() error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.err
+}
+
+// Close stops watching for updates. The target keeps its last value.
+func (b *Binding) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	err := b.watcher.Close()
+	<-b.done
+	return err
+}
+
+func (b *Binding) watch(ctx context.Context) {
+	defer close(b.done)
+	for b.watcher.Next() {
+		update := b.watcher.Update()
+		if update.Deleted {
+			b.onError(&kivik.Error{HTTPStatus: http.StatusNotFound, Message: fmt.Sprintf("config: document %q deleted", b.docID)})
+			continue
+		}
+		if update.Rev == b.Rev() {
+			continue
+		}
+		if err := b.load(ctx); err != nil {
+			b.onError(err)
+			continue
+		}
+		for _, h := range b.hooks {
+			if h.OnChange != nil {
+				h.OnChange(b.Rev())
+			}
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.err = b.watcher.Err()
+	}
+}
+
+// load fetches, decodes and validates the current revision, and copies it
+// into the target.
+func (b *Binding) load(ctx context.Context) error {
+	row := b.db.Get(ctx, b.docID)
+	candidate := reflect.New(b.target.Type())
+	if err := row.ScanDoc(candidate.Interface()); err != nil {
+		return err
+	}
+	for _, h := range b.hooks {
+		if h.Validate == nil {
+			continue
+		}
+		if err := h.Validate(candidate.Interface()); err != nil {
+			return &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("config: revision %s of %q rejected", row.Rev, b.docID), Err: err}
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.target.Set(candidate.Elem())
+	b.rev = row.Rev
+	return nil
+}
+
+func (b *Binding) onError(err error) {
+	for _, h := range b.hooks {
+		if h.OnError != nil {
+			h.OnError(err)
+		}
+	}
+}
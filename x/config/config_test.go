@@ -0,0 +1,191 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package config
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/memtest"
+)
+
+type serviceConfig struct {
+	Workers int    `json:"workers"`
+	Mode    string `json:"mode"`
+}
+
+func validateWorkers(candidate interface{}) error {
+	if candidate.(*serviceConfig).Workers <= 0 {
+		return errors.New("workers must be positive")
+	}
+	return nil
+}
+
+func read(b *Binding, cfg *serviceConfig) serviceConfig {
+	b.RLock()
+	defer b.RUnlock()
+	return *cfg
+}
+
+func TestBind(t *testing.T) {
+	ctx := context.Background()
+	db := memtest.NewDB(t)
+	rev, err := db.Put(ctx, "config", map[string]interface{}{"workers": 2, "mode": "fast"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan string)
+	errs := make(chan error)
+	var cfg serviceConfig
+	b, err := Bind(ctx, db, "config", &cfg, Hooks{
+		Validate: validateWorkers,
+		OnChange: func(rev string) { changes <- rev },
+		OnError:  func(err error) { errs <- err },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close() // nolint: errcheck
+
+	if d := testy.DiffInterface(serviceConfig{Workers: 2, Mode: "fast"}, read(b, &cfg)); d != nil {
+		t.Errorf("Unexpected initial value:\n%s", d)
+	}
+	if b.Rev() != rev {
+		t.Errorf("Unexpected rev: %s", b.Rev())
+	}
+
+	wait := func(t *testing.T) (string, error) {
+		t.Helper()
+		select {
+		case rev := <-changes:
+			return rev, nil
+		case err := <-errs:
+			return "", err
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for update")
+		}
+		return "", nil
+	}
+
+	t.Run("update", func(t *testing.T) {
+		var err error
+		rev, err = db.Put(ctx, "config", map[string]interface{}{"_rev": rev, "workers": 4, "mode": "fast"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		changed, err := wait(t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != rev {
+			t.Errorf("OnChange called with %s, expected %s", changed, rev)
+		}
+		if d := testy.DiffInterface(serviceConfig{Workers: 4, Mode: "fast"}, read(b, &cfg)); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("rejected", func(t *testing.T) {
+		var err error
+		rev, err = db.Put(ctx, "config", map[string]interface{}{"_rev": rev, "workers": 0, "mode": "slow"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = wait(t)
+		if d := testy.DiffInterface(serviceConfig{Workers: 4, Mode: "fast"}, read(b, &cfg)); d != nil {
+			t.Error(d)
+		}
+		testy.StatusError(t, "config: revision "+rev+` of "config" rejected: workers must be positive`, http.StatusBadRequest, err)
+	})
+	t.Run("deleted", func(t *testing.T) {
+		if _, err := db.Delete(ctx, "config", rev); err != nil {
+			t.Fatal(err)
+		}
+		_, err := wait(t)
+		if d := testy.DiffInterface(serviceConfig{Workers: 4, Mode: "fast"}, read(b, &cfg)); d != nil {
+			t.Error(d)
+		}
+		testy.StatusError(t, `config: document "config" deleted`, http.StatusNotFound, err)
+	})
+	t.Run("recreated", func(t *testing.T) {
+		rev, err := db.Put(ctx, "config", map[string]interface{}{"workers": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		changed, err := wait(t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != rev {
+			t.Errorf("OnChange called with %s, expected %s", changed, rev)
+		}
+		if d := testy.DiffInterface(serviceConfig{Workers: 1}, read(b, &cfg)); d != nil {
+			t.Error(d)
+		}
+	})
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Err(); err != nil {
+		t.Errorf("Unexpected error after close: %s", err)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	type tt struct {
+		db     *kivik.DB
+		target interface{}
+		status int
+		err    string
+	}
+	tests := testy.NewTable()
+	tests.Add("non-pointer target", func(t *testing.T) interface{} {
+		return tt{
+			db:     memtest.NewDB(t),
+			target: serviceConfig{},
+			status: http.StatusBadRequest,
+			err:    "config: target must be a non-nil pointer, not config.serviceConfig",
+		}
+	})
+	tests.Add("missing document", func(t *testing.T) interface{} {
+		return tt{
+			db:     memtest.NewDB(t),
+			target: &serviceConfig{},
+			status: http.StatusNotFound,
+			err:    "missing",
+		}
+	})
+	tests.Add("invalid document", func(t *testing.T) interface{} {
+		db := memtest.NewDB(t)
+		if _, err := db.Put(context.Background(), "config", map[string]interface{}{"workers": 0}); err != nil {
+			t.Fatal(err)
+		}
+		return tt{
+			db:     db,
+			target: &serviceConfig{},
+			status: http.StatusBadRequest,
+			err:    `rejected: workers must be positive`,
+		}
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		_, err := Bind(context.Background(), tt.db, "config", tt.target, Hooks{Validate: validateWorkers})
+		testy.StatusErrorRE(t, tt.err, tt.status, err)
+	})
+}
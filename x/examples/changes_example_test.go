@@ -0,0 +1,88 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package examples_test
+
+import (
+	"context"
+	"fmt"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// This example follows a continuous changes feed. The feed first reports the
+// changes already made, then blocks until the next change, so it is read on
+// the goroutine which consumes changes while other goroutines write. The
+// follower stops by closing the feed, once it has seen what it needs.
+func Example_changesFollower() {
+	ctx := context.Background()
+	db := newDB(ctx, "orders")
+
+	if _, err := db.Put(ctx, "order-1", map[string]string{"status": "new"}); err != nil {
+		panic(err)
+	}
+
+	changes, err := db.Changes(ctx, kivik.Options{
+		"feed":         "continuous",
+		"since":        "0",
+		"include_docs": true,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// Each write waits for the previous one to be reported, so that the
+	// output is predictable; a real follower would not coordinate with the
+	// writers. Each write is given the revision just reported.
+	writes := []func(rev string) error{
+		func(string) error {
+			_, err := db.Put(ctx, "order-2", map[string]string{"status": "new"})
+			return err
+		},
+		func(rev string) error {
+			_, err := db.Delete(ctx, "order-2", rev)
+			return err
+		},
+	}
+
+	seen := 0
+	for changes.Next() {
+		var doc struct {
+			Status string `json:"status"`
+		}
+		if err := changes.ScanDoc(&doc); err != nil {
+			panic(err)
+		}
+		if changes.Deleted() {
+			fmt.Printf("%s deleted\n", changes.ID())
+		} else {
+			fmt.Printf("%s is %s\n", changes.ID(), doc.Status)
+		}
+		if seen < len(writes) {
+			go func(write func(string) error, rev string) {
+				if err := write(rev); err != nil {
+					panic(err)
+				}
+			}(writes[seen], changes.Changes()[0])
+		}
+		if seen++; seen == 3 {
+			_ = changes.Close()
+		}
+	}
+	if err := changes.Err(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// order-1 is new
+	// order-2 is new
+	// order-2 deleted
+}
@@ -0,0 +1,84 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package examples_test
+
+import (
+	"context"
+	"fmt"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb" // The memory driver
+)
+
+// newDB returns a new, empty database named name, on a new memory server.
+func newDB(ctx context.Context, name string) *kivik.DB {
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		panic(err)
+	}
+	if err := client.CreateDB(ctx, name); err != nil {
+		panic(err)
+	}
+	return client.DB(ctx, name)
+}
+
+// This example creates, reads, updates and deletes a document. Each write
+// returns the document's new revision, which must be supplied with the next
+// write, or the write fails with a conflict.
+func Example_crud() {
+	ctx := context.Background()
+	db := newDB(ctx, "animals")
+
+	type Animal struct {
+		ID       string `json:"_id"`
+		Rev      string `json:"_rev,omitempty"`
+		Feet     int    `json:"feet"`
+		Greeting string `json:"greeting"`
+	}
+
+	// Create
+	cow := Animal{ID: "cow", Feet: 4, Greeting: "moo"}
+	rev, err := db.Put(ctx, cow.ID, cow)
+	if err != nil {
+		panic(err)
+	}
+
+	// Read
+	var stored Animal
+	if err := db.Get(ctx, "cow").ScanDoc(&stored); err != nil {
+		panic(err)
+	}
+	fmt.Printf("%s says %s, revision matches: %t\n", stored.ID, stored.Greeting, stored.Rev == rev)
+
+	// Update
+	stored.Greeting = "Moo!"
+	if stored.Rev, err = db.Put(ctx, stored.ID, stored); err != nil {
+		panic(err)
+	}
+
+	// An update with an out of date revision is rejected.
+	cow.Rev = rev
+	_, err = db.Put(ctx, cow.ID, cow)
+	fmt.Println("stale update:", kivik.StatusCode(err))
+
+	// Delete
+	if _, err := db.Delete(ctx, stored.ID, stored.Rev); err != nil {
+		panic(err)
+	}
+	err = db.Get(ctx, "cow").Err
+	fmt.Println("after delete:", kivik.StatusCode(err))
+	// Output:
+	// cow says moo, revision matches: true
+	// stale update: 409
+	// after delete: 404
+}
@@ -0,0 +1,22 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package examples contains runnable, end-to-end examples of using Kivik.
+//
+// The examples are Go example tests, run against the memory driver
+// (x/memorydb), so they need no server and are checked by go test: an
+// example which no longer compiles, or whose output changes, fails the
+// build. They cover document CRUD, following the changes feed, replicating
+// between databases, and querying views into typed results.
+//
+// The package has no exported API of its own.
+package examples // import "github.com/go-kivik/kivik/v4/x/examples"
@@ -0,0 +1,149 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package examples_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// replicate copies the current revision of each document changed in source
+// since the last call to target, and returns the number of changes applied.
+// Progress is checkpointed in a local document in target, under the ID
+// PouchDB would use, so that each call resumes where the last left off.
+//
+// The memory driver has no replicator of its own, so unlike
+// Client.Replicate, this runs in the client. It copies only winning
+// revisions, each as a new revision in target, which is enough to keep a
+// read-only copy current.
+func replicate(ctx context.Context, source, target *kivik.DB) (int, error) {
+	checkpointID, err := kivik.PouchDBReplicationID(source.Name(), target.Name())
+	if err != nil {
+		return 0, err
+	}
+	var checkpoint struct {
+		LastSeq string `json:"last_seq"`
+	}
+	err = target.GetLocal(ctx, checkpointID).ScanDoc(&checkpoint)
+	if err != nil && kivik.StatusCode(err) != http.StatusNotFound {
+		return 0, err
+	}
+	since := checkpoint.LastSeq
+	if since == "" {
+		since = "0"
+	}
+
+	changes, err := source.Changes(ctx, kivik.Options{"since": since, "include_docs": true})
+	if err != nil {
+		return 0, err
+	}
+	defer changes.Close() // nolint: errcheck
+	var applied int
+	for changes.Next() {
+		_, rev, err := target.GetMeta(ctx, changes.ID())
+		if err != nil && kivik.StatusCode(err) != http.StatusNotFound {
+			return applied, err
+		}
+		if changes.Deleted() {
+			if rev != "" {
+				if _, err := target.Delete(ctx, changes.ID(), rev); err != nil {
+					return applied, err
+				}
+			}
+		} else {
+			var doc map[string]json.RawMessage
+			if err := changes.ScanDoc(&doc); err != nil {
+				return applied, err
+			}
+			delete(doc, "_rev")
+			if rev != "" {
+				doc["_rev"], _ = json.Marshal(rev)
+			}
+			if _, err := target.Put(ctx, changes.ID(), doc); err != nil {
+				return applied, err
+			}
+		}
+		applied++
+	}
+	if err := changes.Err(); err != nil {
+		return applied, err
+	}
+	checkpoint.LastSeq = changes.LastSeq()
+	return applied, target.PutLocal(ctx, checkpointID, checkpoint)
+}
+
+// This example keeps a copy of one database in another, by following the
+// source's changes feed from a checkpoint. See replicate, above.
+func Example_replication() {
+	ctx := context.Background()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		panic(err)
+	}
+	for _, name := range []string{"primary", "replica"} {
+		if err := client.CreateDB(ctx, name); err != nil {
+			panic(err)
+		}
+	}
+	primary, replica := client.DB(ctx, "primary"), client.DB(ctx, "replica")
+
+	for _, id := range []string{"alice", "bob"} {
+		if _, err := primary.Put(ctx, id, map[string]string{"role": "user"}); err != nil {
+			panic(err)
+		}
+	}
+	applied, err := replicate(ctx, primary, replica)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("first run applied:", applied)
+
+	// Only the changes since the checkpoint are copied next time.
+	rev, err := primary.Put(ctx, "carol", map[string]string{"role": "admin"})
+	if err != nil {
+		panic(err)
+	}
+	if _, err := primary.Delete(ctx, "carol", rev); err != nil {
+		panic(err)
+	}
+	_, rev, err = primary.GetMeta(ctx, "bob")
+	if err != nil {
+		panic(err)
+	}
+	if _, err := primary.Put(ctx, "bob", map[string]string{"_rev": rev, "role": "admin"}); err != nil {
+		panic(err)
+	}
+	applied, err = replicate(ctx, primary, replica)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("second run applied:", applied)
+
+	var bob struct {
+		Role string `json:"role"`
+	}
+	if err := replica.Get(ctx, "bob").ScanDoc(&bob); err != nil {
+		panic(err)
+	}
+	fmt.Println("bob is now:", bob.Role)
+	fmt.Println("carol:", kivik.StatusCode(replica.Get(ctx, "carol").Err))
+	// Output:
+	// first run applied: 2
+	// second run applied: 2
+	// bob is now: admin
+	// carol: 404
+}
@@ -0,0 +1,113 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package examples_test
+
+import (
+	"context"
+	"fmt"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+func init() {
+	// With CouchDB, this view would be defined in JavaScript, in the design
+	// document _design/sales. The memory driver takes views written in Go.
+	memorydb.RegisterView("sales", "by_region", memorydb.View{
+		Map: func(doc map[string]interface{}, emit func(key, value interface{})) {
+			if doc["type"] == "sale" {
+				emit([]interface{}{doc["region"], doc["_id"]}, doc["amount"])
+			}
+		},
+		Reduce: memorydb.Sum,
+	})
+}
+
+// Sale is a document of type "sale".
+type Sale struct {
+	ID     string  `json:"_id"`
+	Type   string  `json:"type"`
+	Region string  `json:"region"`
+	Amount float64 `json:"amount"`
+}
+
+// This example queries a view, scanning its keys and values into typed
+// variables, first row by row and then reduced per group of rows.
+func Example_typedViews() {
+	ctx := context.Background()
+	db := newDB(ctx, "sales")
+
+	sales := []interface{}{
+		Sale{ID: "s1", Type: "sale", Region: "east", Amount: 10},
+		Sale{ID: "s2", Type: "sale", Region: "west", Amount: 25},
+		Sale{ID: "s3", Type: "sale", Region: "east", Amount: 5.5},
+	}
+	if _, err := db.BulkDocs(ctx, sales); err != nil {
+		panic(err)
+	}
+
+	// The map rows, with the documents which emitted them.
+	rows, err := db.Query(ctx, "_design/sales", "by_region", kivik.Options{
+		"reduce":       false,
+		"include_docs": true,
+	})
+	if err != nil {
+		panic(err)
+	}
+	for rows.Next() {
+		var key [2]string
+		var amount float64
+		var sale Sale
+		if err := rows.ScanKey(&key); err != nil {
+			panic(err)
+		}
+		if err := rows.ScanValue(&amount); err != nil {
+			panic(err)
+		}
+		if err := rows.ScanDoc(&sale); err != nil {
+			panic(err)
+		}
+		fmt.Printf("%s %s: %.2f (%s)\n", key[0], key[1], amount, sale.Type)
+	}
+	if err := rows.Err(); err != nil {
+		panic(err)
+	}
+
+	// Totals per region, grouping on the first element of the key.
+	rows, err = db.Query(ctx, "_design/sales", "by_region", kivik.Options{
+		"group_level": 1,
+	})
+	if err != nil {
+		panic(err)
+	}
+	for rows.Next() {
+		var key []string
+		var total float64
+		if err := rows.ScanKey(&key); err != nil {
+			panic(err)
+		}
+		if err := rows.ScanValue(&total); err != nil {
+			panic(err)
+		}
+		fmt.Printf("%s total: %.2f\n", key[0], total)
+	}
+	if err := rows.Err(); err != nil {
+		panic(err)
+	}
+	// Output:
+	// east s1: 10.00 (sale)
+	// east s3: 5.50 (sale)
+	// west s2: 25.00 (sale)
+	// east total: 15.50
+	// west total: 25.00
+}
@@ -0,0 +1,152 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+)
+
+// attachmentUpdate returns an update to the leaf revision rev, preserving its
+// content, with its attachments modified by fn. If the document does not
+// exist, or is deleted, the update starts from an empty document.
+func (s *state) attachmentUpdate(docID, rev string, fn func(atts map[string]interface{}) error) (*update, error) {
+	doc, err := s.loadDoc(docID)
+	if err != nil {
+		return nil, err
+	}
+	u := &update{
+		rev:         rev,
+		body:        map[string]interface{}{},
+		attachments: map[string]interface{}{},
+	}
+	if doc != nil {
+		if cur := doc.leaf(rev); cur != nil && !cur.deleted {
+			for k, v := range cur.body {
+				u.body[k] = v
+			}
+			for name := range cur.attachments {
+				u.attachments[name] = map[string]interface{}{"stub": true}
+			}
+		}
+	}
+	return u, fn(u.attachments)
+}
+
+func (d *db) PutAttachment(_ context.Context, docID, rev string, att *driver.Attachment, _ map[string]interface{}) (string, error) {
+	if err := validateDocID(docID); err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(docID, localPrefix) {
+		return "", errors.Status(http.StatusBadRequest, "local documents do not support attachments")
+	}
+	content, err := ioutil.ReadAll(att.Content)
+	if err != nil {
+		return "", err
+	}
+	contentType := att.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	s, err := d.client.state(d.name)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, err := s.writeAttachment(contentType, content)
+	if err != nil {
+		return "", err
+	}
+	u, err := s.attachmentUpdate(docID, rev, func(atts map[string]interface{}) error {
+		delete(atts, att.Filename)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	u.stored = map[string]*attachment{att.Filename: stored}
+	return s.put(docID, u)
+}
+
+func (d *db) GetAttachment(_ context.Context, docID, filename string, options map[string]interface{}) (*driver.Attachment, error) {
+	s, err := d.client.state(d.name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	doc, err := s.loadDoc(docID)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errors.Status(http.StatusNotFound, "missing")
+	}
+	r := doc.winner()
+	if rev, _ := options["rev"].(string); rev != "" {
+		r = doc.revs[rev]
+	}
+	if r == nil || r.deleted {
+		return nil, errors.Status(http.StatusNotFound, "missing")
+	}
+	att, ok := r.attachments[filename]
+	if !ok {
+		return nil, errors.Status(http.StatusNotFound, "Document is missing attachment")
+	}
+	data, err := s.readAttachment(att)
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Attachment{
+		Filename:    filename,
+		ContentType: att.ContentType,
+		Content:     ioutil.NopCloser(bytes.NewReader(data)),
+		Size:        att.Length,
+		RevPos:      att.RevPos,
+		Digest:      att.Digest,
+	}, nil
+}
+
+func (d *db) DeleteAttachment(_ context.Context, docID, rev, filename string, _ map[string]interface{}) (string, error) {
+	s, err := d.client.state(d.name)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	doc, err := s.loadDoc(docID)
+	if err != nil {
+		return "", err
+	}
+	if doc == nil || doc.winner().deleted {
+		return "", errors.Status(http.StatusNotFound, "missing")
+	}
+	u, err := s.attachmentUpdate(docID, rev, func(atts map[string]interface{}) error {
+		if _, ok := atts[filename]; !ok {
+			return errors.Status(http.StatusNotFound, "Document is missing attachment")
+		}
+		delete(atts, filename)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.put(docID, u)
+}
@@ -0,0 +1,103 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	root := tempDir(t)
+	client := newClient(t, root)
+	if err := client.CreateDB(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	db := client.DB(ctx, "test")
+	rev := put(t, db, "foo", map[string]string{"a": "b"})
+	rev, err := db.PutAttachment(ctx, "foo", rev, &kivik.Attachment{
+		Filename:    "foo.txt",
+		ContentType: "text/plain",
+		Content:     ioutil.NopCloser(strings.NewReader("hello")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	att, err := db.GetAttachment(ctx, "foo", "foo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(att.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "hello" || att.ContentType != "text/plain" || att.RevPos != 2 {
+		t.Errorf("Unexpected attachment: %+v, %s", att, content)
+	}
+
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "foo", kivik.Options{"attachments": true}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"_id":  "foo",
+		"_rev": rev,
+		"a":    "b",
+		"_attachments": map[string]interface{}{
+			"foo.txt": map[string]interface{}{
+				"content_type": "text/plain",
+				"data":         "aGVsbG8=",
+				"digest":       "md5-XUFAKrxLKna5cZ2REBfFkg==",
+				"length":       float64(5),
+				"revpos":       float64(2),
+			},
+		},
+	}
+	if d := testy.DiffInterface(expected, doc); d != nil {
+		t.Error(d)
+	}
+
+	_, err = db.GetAttachment(ctx, "foo", "bar.txt")
+	testy.StatusError(t, "Document is missing attachment", http.StatusNotFound, err)
+	_, err = db.DeleteAttachment(ctx, "foo", rev, "bar.txt")
+	testy.StatusError(t, "Document is missing attachment", http.StatusNotFound, err)
+
+	if _, err := db.DeleteAttachment(ctx, "foo", rev, "foo.txt"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.GetAttachment(ctx, "foo", "foo.txt")
+	testy.StatusError(t, "Document is missing attachment", http.StatusNotFound, err)
+
+	// Compaction removes the content, once no leaf revision refers to it.
+	if err := db.Compact(ctx); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(filepath.Join(root, "test", attachmentsDir))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Expected attachment content to be removed, found %d files", len(files))
+	}
+}
@@ -0,0 +1,140 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+)
+
+var (
+	_ driver.BulkDocer  = &db{}
+	_ driver.RevsDiffer = &db{}
+)
+
+// bulkResults is a driver.BulkResults backed by a slice.
+type bulkResults []driver.BulkResult
+
+var _ driver.BulkResults = &bulkResults{}
+
+func (r *bulkResults) Next(result *driver.BulkResult) error {
+	if len(*r) == 0 {
+		return io.EOF
+	}
+	*result = (*r)[0]
+	*r = (*r)[1:]
+	return nil
+}
+
+func (r *bulkResults) Close() error {
+	*r = nil
+	return nil
+}
+
+// BulkDocs writes each document in turn. Each document succeeds or fails
+// independently, as with CouchDB. The new_edits option is honored, so that
+// replicated revisions may be stored with their existing history.
+func (d *db) BulkDocs(ctx context.Context, docs []interface{}, options map[string]interface{}) (driver.BulkResults, error) {
+	if _, err := d.client.state(d.name); err != nil {
+		return nil, err
+	}
+	putOpts := make(map[string]interface{}, 1)
+	if newEdits, ok := options["new_edits"]; ok {
+		putOpts["new_edits"] = newEdits
+	}
+	results := make(bulkResults, len(docs))
+	for i, doc := range docs {
+		u, err := parseUpdate(doc)
+		if err != nil {
+			results[i] = driver.BulkResult{Error: err}
+			continue
+		}
+		if u.id == "" {
+			u.id = newUUID()
+		}
+		rev, err := d.Put(ctx, u.id, doc, putOpts)
+		results[i] = driver.BulkResult{ID: u.id, Rev: rev, Error: err}
+	}
+	return &results, nil
+}
+
+// RevsDiff reports which of the given revisions are not in the database.
+// revMap must marshal to a JSON object mapping document IDs to lists of
+// revisions.
+func (d *db) RevsDiff(_ context.Context, revMap interface{}) (driver.Rows, error) {
+	raw, err := json.Marshal(revMap)
+	if err != nil {
+		return nil, errors.WrapStatus(http.StatusBadRequest, err)
+	}
+	var wanted map[string][]string
+	if err := json.Unmarshal(raw, &wanted); err != nil {
+		return nil, errors.WrapStatus(http.StatusBadRequest, err)
+	}
+	s, err := d.client.state(d.name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(wanted))
+	for id := range wanted {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	result := &rows{}
+	for _, id := range ids {
+		doc, err := s.loadDoc(id)
+		if err != nil {
+			return nil, err
+		}
+		diff := revsDiff(doc, wanted[id])
+		if len(diff.Missing) == 0 {
+			continue
+		}
+		value, _ := json.Marshal(diff)
+		result.rows = append(result.rows, &driver.Row{ID: id, Value: value})
+	}
+	return result, nil
+}
+
+func revsDiff(doc *document, revs []string) driver.RevDiff {
+	var diff driver.RevDiff
+	var maxGen int64
+	for _, rev := range revs {
+		if doc != nil && doc.known(rev) {
+			continue
+		}
+		diff.Missing = append(diff.Missing, rev)
+		if gen, _ := strconv.ParseInt(strings.SplitN(rev, "-", 2)[0], 10, 64); gen > maxGen {
+			maxGen = gen
+		}
+	}
+	if doc == nil || len(diff.Missing) == 0 {
+		return diff
+	}
+	for _, l := range doc.leaves {
+		if l.generation() < maxGen {
+			diff.PossibleAncestors = append(diff.PossibleAncestors, l.rev)
+		}
+	}
+	sort.Strings(diff.PossibleAncestors)
+	return diff
+}
@@ -0,0 +1,123 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestBulkDocs(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev := put(t, db, "existing", map[string]string{})
+	results, err := db.BulkDocs(ctx, []interface{}{
+		map[string]string{"_id": "foo"},
+		map[string]string{"_id": "existing"},
+		map[string]interface{}{"_id": "existing", "_rev": rev, "_deleted": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	var errs []int
+	for results.Next() {
+		ids = append(ids, results.ID())
+		errs = append(errs, kivik.StatusCode(results.UpdateErr()))
+	}
+	if err := results.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"foo", "existing", "existing"}, ids); d != nil {
+		t.Error(d)
+	}
+	if d := testy.DiffInterface([]int{0, http.StatusConflict, 0}, errs); d != nil {
+		t.Error(d)
+	}
+}
+
+// TestReplicationTarget exercises the sequence of calls made by a replicator
+// writing to the database.
+func TestReplicationTarget(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	put(t, db, "foo", map[string]interface{}{
+		"_rev":       "2-bbb",
+		"_revisions": map[string]interface{}{"start": 2, "ids": []string{"bbb", "aaa"}},
+	}, kivik.Options{"new_edits": false})
+
+	rows, err := db.RevsDiff(ctx, map[string][]string{
+		"foo": {"1-aaa", "2-bbb", "3-ccc"},
+		"bar": {"1-aaa"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffs := map[string]kivik.RevDiff{}
+	for rows.Next() {
+		var diff kivik.RevDiff
+		if err := rows.ScanValue(&diff); err != nil {
+			t.Fatal(err)
+		}
+		diffs[rows.ID()] = diff
+	}
+	expected := map[string]kivik.RevDiff{
+		"foo": {Missing: []string{"3-ccc"}, PossibleAncestors: []string{"2-bbb"}},
+		"bar": {Missing: []string{"1-aaa"}},
+	}
+	if d := testy.DiffInterface(expected, diffs); d != nil {
+		t.Error(d)
+	}
+
+	results, err := db.BulkDocs(ctx, []interface{}{
+		map[string]interface{}{
+			"_id":        "foo",
+			"_rev":       "3-ccc",
+			"_revisions": map[string]interface{}{"start": 3, "ids": []string{"ccc", "bbb", "aaa"}},
+			"_attachments": map[string]interface{}{
+				"foo.txt": map[string]interface{}{"content_type": "text/plain", "data": "aGVsbG8=", "revpos": 3},
+			},
+		},
+		map[string]interface{}{"_id": "bar", "_rev": "1-aaa"},
+	}, kivik.Options{"new_edits": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for results.Next() {
+		if err := results.UpdateErr(); err != nil {
+			t.Errorf("%s: %s", results.ID(), err)
+		}
+	}
+
+	put(t, db, "_local/checkpoint", map[string]string{"last_seq": "2"})
+
+	_, rev, err := db.GetMeta(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev != "3-ccc" {
+		t.Errorf("Unexpected rev: %s", rev)
+	}
+	att, err := db.GetAttachment(ctx, "foo", "foo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if att.RevPos != 3 {
+		t.Errorf("Unexpected revpos: %d", att.RevPos)
+	}
+}
@@ -0,0 +1,317 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
+)
+
+const (
+	feedNormal     = "normal"
+	feedLongpoll   = "longpoll"
+	feedContinuous = "continuous"
+)
+
+// changes is a changes feed, read from the database's changes log. For
+// longpoll and continuous feeds, it waits for updates made through the same
+// Client.
+type changes struct {
+	ctx         context.Context
+	state       *state
+	docIDs      map[string]bool
+	feed        string
+	since       int64
+	descending  bool
+	includeDocs bool
+	allDocs     bool
+	limit       int64
+	hasLimit    bool
+	timeout     time.Duration
+
+	mu       sync.Mutex
+	done     chan struct{}
+	closed   bool
+	queue    []*driver.Change
+	sent     int64
+	waited   bool
+	lastSeq  string
+	pending  int64
+	waitChan <-chan struct{}
+	dbClosed bool
+}
+
+var _ driver.Changes = &changes{}
+
+// Changes supports the since, limit, descending, include_docs, feed, timeout
+// and style options, and the _doc_ids filter.
+func (d *db) Changes(ctx context.Context, options map[string]interface{}) (driver.Changes, error) {
+	s, err := d.client.state(d.name)
+	if err != nil {
+		return nil, err
+	}
+	c := &changes{
+		ctx:   ctx,
+		state: s,
+		feed:  feedNormal,
+		done:  make(chan struct{}),
+	}
+	if feed, _ := options["feed"].(string); feed != "" {
+		switch feed {
+		case feedNormal, feedLongpoll, feedContinuous:
+			c.feed = feed
+		default:
+			return nil, errors.Statusf(http.StatusBadRequest, "unsupported feed type: %s", feed)
+		}
+	}
+	if err := c.parseSince(options); err != nil {
+		return nil, err
+	}
+	if c.descending, err = queryopts.Bool(options, "descending"); err != nil {
+		return nil, err
+	}
+	if c.includeDocs, err = queryopts.Bool(options, "include_docs"); err != nil {
+		return nil, err
+	}
+	c.allDocs = options["style"] == "all_docs"
+	if c.limit, c.hasLimit, err = queryopts.Int(options, "limit"); err != nil {
+		return nil, err
+	}
+	if timeout, ok, err := queryopts.Int(options, "timeout"); err != nil {
+		return nil, err
+	} else if ok {
+		c.timeout = time.Duration(timeout) * time.Millisecond
+	}
+	switch filter, _ := options["filter"].(string); filter {
+	case "":
+	case "_doc_ids":
+		ids, err := queryopts.Strings(options, "doc_ids")
+		if err != nil {
+			return nil, err
+		}
+		c.docIDs = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			c.docIDs[id] = true
+		}
+	default:
+		return nil, errors.Statusf(http.StatusBadRequest, "unsupported filter: %s", filter)
+	}
+	if err := c.fetch(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *changes) parseSince(options map[string]interface{}) error {
+	var since string
+	switch t := options["since"].(type) {
+	case nil:
+		return nil
+	case string:
+		since = t
+	case int:
+		since = strconv.Itoa(t)
+	case int64:
+		since = strconv.FormatInt(t, 10)
+	default:
+		return errors.Statusf(http.StatusBadRequest, "invalid value for since: %v", t)
+	}
+	if since == "now" {
+		c.state.mu.RLock()
+		c.since = c.state.seq
+		c.state.mu.RUnlock()
+		return nil
+	}
+	seq, err := strconv.ParseInt(since, 10, 64)
+	if err != nil {
+		return errors.Statusf(http.StatusBadRequest, "invalid value for since: %q", since)
+	}
+	c.since = seq
+	return nil
+}
+
+// fetch queues all matching changes since c.since, and advances c.since.
+func (c *changes) fetch() error {
+	s := c.state
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c.waitChan = s.updated
+	c.dbClosed = s.closed
+	if s.closed {
+		return nil
+	}
+	// Only the most recent update to each document is reported.
+	latest := make(map[string]int64)
+	err := s.scanLog(func(e *logEntry) {
+		if c.docIDs == nil || c.docIDs[e.ID] {
+			latest[e.ID] = e.Seq
+		}
+	})
+	if err != nil {
+		return err
+	}
+	type logDoc struct {
+		id  string
+		seq int64
+	}
+	var docs []logDoc
+	for id, seq := range latest {
+		if seq > c.since {
+			docs = append(docs, logDoc{id: id, seq: seq})
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		return (docs[i].seq < docs[j].seq) != c.descending
+	})
+	c.pending = 0
+	if c.hasLimit {
+		if remaining := c.limit - c.sent; int64(len(docs)) > remaining {
+			c.pending = int64(len(docs)) - remaining
+			docs = docs[:remaining]
+		}
+	}
+	for _, d := range docs {
+		doc, err := s.loadDoc(d.id)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			continue
+		}
+		change, err := c.change(doc, d.seq)
+		if err != nil {
+			return err
+		}
+		c.queue = append(c.queue, change)
+	}
+	if c.pending > 0 && len(c.queue) > 0 {
+		c.lastSeq = c.queue[len(c.queue)-1].Seq
+	} else {
+		c.lastSeq = strconv.FormatInt(s.seq, 10)
+	}
+	if !c.descending {
+		c.since = s.seq
+	}
+	c.sent += int64(len(docs))
+	return nil
+}
+
+func (c *changes) change(doc *document, seq int64) (*driver.Change, error) {
+	winner := doc.winner()
+	change := &driver.Change{
+		ID:      doc.id,
+		Seq:     strconv.FormatInt(seq, 10),
+		Deleted: winner.deleted,
+		Changes: driver.ChangedRevs{winner.rev},
+	}
+	if c.allDocs {
+		change.Changes = change.Changes[:0]
+		for _, l := range doc.leaves {
+			change.Changes = append(change.Changes, l.rev)
+		}
+	}
+	if c.includeDocs {
+		body, err := c.state.render(doc, winner, nil)
+		if err != nil {
+			return nil, err
+		}
+		if change.Doc, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	return change, nil
+}
+
+func (c *changes) Next(change *driver.Change) error {
+	for {
+		c.mu.Lock()
+		if len(c.queue) > 0 {
+			*change = *c.queue[0]
+			c.queue = c.queue[1:]
+			c.mu.Unlock()
+			return nil
+		}
+		finished := c.closed || c.dbClosed ||
+			(c.hasLimit && c.sent >= c.limit) ||
+			c.feed == feedNormal ||
+			(c.feed == feedLongpoll && c.waited)
+		wait := c.waitChan
+		c.waited = true
+		c.mu.Unlock()
+		if finished {
+			return io.EOF
+		}
+		if err := c.wait(wait); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		err := c.fetch()
+		c.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// wait blocks until the database is updated, or the feed ends.
+func (c *changes) wait(updated <-chan struct{}) error {
+	var timeout <-chan time.Time
+	if c.timeout > 0 {
+		timer := time.NewTimer(c.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-updated:
+		return nil
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	case <-c.done:
+	case <-timeout:
+	}
+	return io.EOF
+}
+
+func (c *changes) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+	return nil
+}
+
+func (c *changes) LastSeq() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastSeq
+}
+
+func (c *changes) Pending() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pending
+}
+
+func (c *changes) ETag() string { return "" }
@@ -0,0 +1,156 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+type change struct {
+	ID      string
+	Seq     string
+	Deleted bool
+	Changes []string
+}
+
+func readChanges(t *testing.T, changes *kivik.Changes) []change {
+	t.Helper()
+	result := []change{}
+	for changes.Next() {
+		result = append(result, change{
+			ID:      changes.ID(),
+			Seq:     changes.Seq(),
+			Deleted: changes.Deleted(),
+			Changes: changes.Changes(),
+		})
+	}
+	if err := changes.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestChanges(t *testing.T) {
+	type tst struct {
+		options  kivik.Options
+		expected []change
+		lastSeq  string
+		pending  int64
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("all", tst{
+		expected: []change{
+			{ID: "b", Seq: "2", Changes: []string{"1-2d585700e08bd58478a4373548e37bf4"}},
+			{ID: "a", Seq: "3", Deleted: true, Changes: []string{"2-016a4bf5560bc322986061a769ccd91d"}},
+		},
+		lastSeq: "3",
+	})
+	tests.Add("since", tst{
+		options: kivik.Options{"since": "2"},
+		expected: []change{
+			{ID: "a", Seq: "3", Deleted: true, Changes: []string{"2-016a4bf5560bc322986061a769ccd91d"}},
+		},
+		lastSeq: "3",
+	})
+	tests.Add("limit", tst{
+		options: kivik.Options{"limit": 1, "descending": true},
+		expected: []change{
+			{ID: "a", Seq: "3", Deleted: true, Changes: []string{"2-016a4bf5560bc322986061a769ccd91d"}},
+		},
+		lastSeq: "3",
+		pending: 1,
+	})
+	tests.Add("doc_ids", tst{
+		options: kivik.Options{"filter": "_doc_ids", "doc_ids": []string{"b"}},
+		expected: []change{
+			{ID: "b", Seq: "2", Changes: []string{"1-2d585700e08bd58478a4373548e37bf4"}},
+		},
+		lastSeq: "3",
+	})
+	tests.Add("unsupported filter", tst{
+		options: kivik.Options{"filter": "_selector"},
+		status:  http.StatusBadRequest,
+		err:     "unsupported filter: _selector",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		db := newDB(t)
+		rev := put(t, db, "a", map[string]string{})
+		put(t, db, "b", map[string]string{})
+		if _, err := db.Delete(context.Background(), "a", rev); err != nil {
+			t.Fatal(err)
+		}
+
+		changes, err := db.Changes(context.Background(), tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, readChanges(t, changes)); d != nil {
+			t.Error(d)
+		}
+		if changes.LastSeq() != tt.lastSeq {
+			t.Errorf("Unexpected last seq: %s", changes.LastSeq())
+		}
+		if changes.Pending() != tt.pending {
+			t.Errorf("Unexpected pending: %d", changes.Pending())
+		}
+	})
+}
+
+func TestChangesStyleAllDocs(t *testing.T) {
+	db := newDB(t)
+	for _, rev := range []string{"1-aaa", "1-bbb"} {
+		put(t, db, "foo", map[string]string{"_rev": rev}, kivik.Options{"new_edits": false})
+	}
+	changes, err := db.Changes(context.Background(), kivik.Options{"style": "all_docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []change{
+		{ID: "foo", Seq: "2", Changes: []string{"1-bbb", "1-aaa"}},
+	}
+	if d := testy.DiffInterface(expected, readChanges(t, changes)); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestChangesContinuous(t *testing.T) {
+	db := newDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := db.Changes(ctx, kivik.Options{"feed": "continuous", "since": "now"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		put(t, db, "a", map[string]string{})
+	}()
+	if !changes.Next() {
+		t.Fatalf("Expected a change: %v", changes.Err())
+	}
+	if changes.ID() != "a" {
+		t.Errorf("Unexpected change: %s", changes.ID())
+	}
+	cancel()
+	if changes.Next() {
+		t.Errorf("Unexpected change: %s", changes.ID())
+	}
+}
@@ -0,0 +1,697 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
+)
+
+type db struct {
+	client *client
+	name   string
+}
+
+var _ driver.DB = &db{}
+
+const (
+	designPrefix = "_design/"
+	localPrefix  = "_local/"
+)
+
+func validateDocID(docID string) error {
+	if docID == "" {
+		return errors.Status(http.StatusBadRequest, "document id must not be empty")
+	}
+	if strings.HasPrefix(docID, "_") && !strings.HasPrefix(docID, designPrefix) && !strings.HasPrefix(docID, localPrefix) {
+		return errors.Status(http.StatusBadRequest, "only reserved document ids may start with underscore")
+	}
+	return nil
+}
+
+// toMap converts doc to a generic JSON object.
+func toMap(doc interface{}) (map[string]interface{}, error) {
+	var data []byte
+	switch t := doc.(type) {
+	case []byte:
+		data = t
+	case json.RawMessage:
+		data = t
+	default:
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, errors.WrapStatus(http.StatusBadRequest, err)
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, errors.WrapStatus(http.StatusBadRequest, err)
+	}
+	if m == nil {
+		return nil, errors.Status(http.StatusBadRequest, "document must be a JSON object")
+	}
+	return m, nil
+}
+
+// update is a document submitted for writing, split into its special
+// underscore fields and the remaining body.
+type update struct {
+	id          string
+	rev         string
+	deleted     bool
+	revisions   *revisions
+	attachments map[string]interface{}
+	// stored are attachments whose content has already been stored, which
+	// are added to those in attachments.
+	stored map[string]*attachment
+	body   map[string]interface{}
+}
+
+func parseUpdate(doc interface{}) (*update, error) {
+	body, err := toMap(doc)
+	if err != nil {
+		return nil, err
+	}
+	u := &update{body: body}
+	for k, v := range body {
+		if !strings.HasPrefix(k, "_") {
+			continue
+		}
+		switch k {
+		case "_id":
+			u.id, _ = v.(string)
+		case "_rev":
+			u.rev, _ = v.(string)
+		case "_deleted":
+			u.deleted, _ = v.(bool)
+		case "_attachments":
+			atts, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.Status(http.StatusBadRequest, "_attachments must be a JSON object")
+			}
+			u.attachments = atts
+		case "_revisions":
+			raw, _ := json.Marshal(v)
+			u.revisions = &revisions{}
+			if err := json.Unmarshal(raw, u.revisions); err != nil || len(u.revisions.IDs) == 0 {
+				return nil, errors.Status(http.StatusBadRequest, "invalid _revisions")
+			}
+		case "_revs_info", "_conflicts", "_deleted_conflicts", "_local_seq":
+			// Ignored on write, as by CouchDB.
+		default:
+			return nil, errors.Statusf(http.StatusBadRequest, "bad special document member: %s", k)
+		}
+		delete(body, k)
+	}
+	return u, nil
+}
+
+// resolveAttachments stores any new attachment content, and returns the
+// attachment metadata for the new revision. Stubs are resolved against
+// prior, the revisions from which the new one descends.
+func (s *state) resolveAttachments(atts map[string]interface{}, prior []*revision) (map[string]*attachment, error) {
+	if len(atts) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]*attachment, len(atts))
+	for name, v := range atts {
+		att, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.Statusf(http.StatusBadRequest, "invalid attachment %s", name)
+		}
+		if stub, _ := att["stub"].(bool); stub {
+			var old *attachment
+			for _, r := range prior {
+				if old = r.attachments[name]; old != nil {
+					break
+				}
+			}
+			if old == nil {
+				return nil, errors.Statusf(http.StatusPreconditionFailed, "invalid attachment stub for %s", name)
+			}
+			result[name] = old
+			continue
+		}
+		encoded, ok := att["data"].(string)
+		if !ok {
+			return nil, errors.Statusf(http.StatusBadRequest, "attachment %s has no data", name)
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.Statusf(http.StatusBadRequest, "invalid attachment data for %s", name)
+		}
+		contentType, _ := att["content_type"].(string)
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		stored, err := s.writeAttachment(contentType, data)
+		if err != nil {
+			return nil, err
+		}
+		if revpos, ok, _ := queryopts.Int(att, "revpos"); ok {
+			stored.RevPos = revpos
+		}
+		result[name] = stored
+	}
+	return result, nil
+}
+
+var errConflict = errors.Status(http.StatusConflict, "document update conflict")
+
+func (d *db) Put(_ context.Context, docID string, doc interface{}, options map[string]interface{}) (string, error) {
+	if err := validateDocID(docID); err != nil {
+		return "", err
+	}
+	u, err := parseUpdate(doc)
+	if err != nil {
+		return "", err
+	}
+	if u.rev == "" {
+		u.rev, _ = options["rev"].(string)
+	}
+	newEdits := true
+	if _, ok := options["new_edits"]; ok {
+		if newEdits, err = queryopts.Bool(options, "new_edits"); err != nil {
+			return "", err
+		}
+	}
+	s, err := d.client.state(d.name)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.HasPrefix(docID, localPrefix) {
+		return s.putLocal(docID, u)
+	}
+	if !newEdits {
+		return s.replicate(docID, u)
+	}
+	return s.put(docID, u)
+}
+
+// put stores u as a new revision of the document, descending from the leaf
+// revision u.rev. It must be called with s.mu held for writing.
+func (s *state) put(docID string, u *update) (string, error) {
+	doc, err := s.loadDoc(docID)
+	if err != nil {
+		return "", err
+	}
+	var parent *revision
+	switch {
+	case doc == nil && u.rev != "":
+		return "", errConflict
+	case doc == nil:
+	case u.rev != "":
+		if parent = doc.leaf(u.rev); parent == nil {
+			return "", errConflict
+		}
+	case doc.winner().deleted:
+		parent = doc.winner()
+	default:
+		return "", errConflict
+	}
+	var prior []*revision
+	if parent != nil {
+		prior = []*revision{parent}
+	}
+	atts, err := s.resolveAttachments(u.attachments, prior)
+	if err != nil {
+		return "", err
+	}
+	for name, att := range u.stored {
+		if atts == nil {
+			atts = make(map[string]*attachment, len(u.stored))
+		}
+		atts[name] = att
+	}
+	r := &revision{
+		deleted:     u.deleted,
+		body:        u.body,
+		attachments: atts,
+	}
+	var prev string
+	r.revisions.Start = 1
+	if parent != nil {
+		prev = parent.rev
+		r.revisions.Start = parent.generation() + 1
+	}
+	for _, att := range atts {
+		if att.RevPos == 0 {
+			att.RevPos = r.revisions.Start
+		}
+	}
+	r.rev = revID(r.revisions.Start, prev, r)
+	r.revisions.IDs = []string{strings.SplitN(r.rev, "-", 2)[1]}
+	if parent != nil {
+		r.revisions.IDs = append(r.revisions.IDs, parent.revisions.IDs...)
+	}
+	return r.rev, s.writeRevision(docID, r)
+}
+
+// replicate stores u with the revision ID and history it already has, as
+// done by replication with new_edits=false. Storing a revision which already
+// exists does nothing. It must be called with s.mu held for writing.
+func (s *state) replicate(docID string, u *update) (string, error) {
+	if u.rev == "" {
+		return "", errors.Status(http.StatusBadRequest, "_rev is required when new_edits=false")
+	}
+	parts := strings.SplitN(u.rev, "-", 2)
+	gen, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || len(parts) != 2 || gen < 1 {
+		return "", errors.Statusf(http.StatusBadRequest, "invalid rev format: %s", u.rev)
+	}
+	history := revisions{Start: gen, IDs: []string{parts[1]}}
+	if u.revisions != nil {
+		history = *u.revisions
+		if history.Start != gen || history.IDs[0] != parts[1] {
+			return "", errors.Status(http.StatusBadRequest, "_rev does not match _revisions")
+		}
+	}
+	doc, err := s.loadDoc(docID)
+	if err != nil {
+		return "", err
+	}
+	var prior []*revision
+	if doc != nil {
+		if _, ok := doc.revs[u.rev]; ok {
+			return u.rev, nil
+		}
+		for _, rev := range history.revs()[1:] {
+			if r, ok := doc.revs[rev]; ok {
+				prior = append(prior, r)
+			}
+		}
+	}
+	atts, err := s.resolveAttachments(u.attachments, prior)
+	if err != nil {
+		return "", err
+	}
+	for _, att := range atts {
+		if att.RevPos == 0 {
+			att.RevPos = gen
+		}
+	}
+	return u.rev, s.writeRevision(docID, &revision{
+		rev:         u.rev,
+		deleted:     u.deleted,
+		revisions:   history,
+		body:        u.body,
+		attachments: atts,
+	})
+}
+
+func (d *db) CreateDoc(ctx context.Context, doc interface{}, options map[string]interface{}) (string, string, error) {
+	u, err := parseUpdate(doc)
+	if err != nil {
+		return "", "", err
+	}
+	docID := u.id
+	if docID == "" {
+		docID = newUUID()
+	}
+	rev, err := d.Put(ctx, docID, doc, options)
+	return docID, rev, err
+}
+
+func (d *db) Delete(_ context.Context, docID, rev string, _ map[string]interface{}) (string, error) {
+	if err := validateDocID(docID); err != nil {
+		return "", err
+	}
+	s, err := d.client.state(d.name)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.HasPrefix(docID, localPrefix) {
+		return s.deleteLocal(docID, rev)
+	}
+	doc, err := s.loadDoc(docID)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case doc == nil:
+		return "", errors.Status(http.StatusNotFound, "missing")
+	case doc.winner().deleted:
+		return "", errors.Status(http.StatusNotFound, "deleted")
+	}
+	return s.put(docID, &update{
+		rev:     rev,
+		deleted: true,
+		body:    map[string]interface{}{},
+	})
+}
+
+func (d *db) Get(_ context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+	s, err := d.client.state(d.name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var doc map[string]interface{}
+	var rev string
+	if strings.HasPrefix(docID, localPrefix) {
+		doc, rev, err = s.getLocal(docID)
+	} else {
+		doc, rev, err = s.get(docID, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Document{
+		ContentLength: int64(len(body)),
+		Rev:           rev,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+// get returns the requested revision of the document, or the winning
+// revision if none is specified in options, rendered as a JSON object.
+func (s *state) get(docID string, options map[string]interface{}) (map[string]interface{}, string, error) {
+	doc, err := s.loadDoc(docID)
+	if err != nil {
+		return nil, "", err
+	}
+	if doc == nil {
+		return nil, "", errors.Status(http.StatusNotFound, "missing")
+	}
+	r := doc.winner()
+	if rev, _ := options["rev"].(string); rev != "" {
+		var ok bool
+		if r, ok = doc.revs[rev]; !ok {
+			return nil, "", errors.Status(http.StatusNotFound, "missing")
+		}
+	} else if r.deleted {
+		return nil, "", errors.Status(http.StatusNotFound, "deleted")
+	}
+	rendered, err := s.render(doc, r, options)
+	return rendered, r.rev, err
+}
+
+// render returns revision r of doc as a JSON object, including the special
+// fields requested by options.
+func (s *state) render(doc *document, r *revision, options map[string]interface{}) (map[string]interface{}, error) {
+	inlineAtts, err := queryopts.Bool(options, "attachments")
+	if err != nil {
+		return nil, err
+	}
+	revs, err := queryopts.Bool(options, "revs")
+	if err != nil {
+		return nil, err
+	}
+	conflicts, err := queryopts.Bool(options, "conflicts")
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]interface{}, len(r.body)+3)
+	for k, v := range r.body {
+		out[k] = v
+	}
+	out["_id"] = doc.id
+	out["_rev"] = r.rev
+	if r.deleted {
+		out["_deleted"] = true
+	}
+	if len(r.attachments) > 0 {
+		atts := make(map[string]interface{}, len(r.attachments))
+		for name, att := range r.attachments {
+			a := map[string]interface{}{
+				"content_type": att.ContentType,
+				"digest":       att.Digest,
+				"length":       att.Length,
+				"revpos":       att.RevPos,
+			}
+			if inlineAtts {
+				data, err := s.readAttachment(att)
+				if err != nil {
+					return nil, err
+				}
+				a["data"] = base64.StdEncoding.EncodeToString(data)
+			} else {
+				a["stub"] = true
+			}
+			atts[name] = a
+		}
+		out["_attachments"] = atts
+	}
+	if revs {
+		out["_revisions"] = r.revisions
+	}
+	if conflicts {
+		var revs []string
+		for _, l := range doc.leaves {
+			if l != r && !l.deleted {
+				revs = append(revs, l.rev)
+			}
+		}
+		if len(revs) > 0 {
+			out["_conflicts"] = revs
+		}
+	}
+	return out, nil
+}
+
+func (s *state) localPath(docID string) string {
+	return filepath.Join(s.path, localDir, escape(docID)+".json")
+}
+
+// readLocal returns the local document, or nil if it does not exist.
+func (s *state) readLocal(docID string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(s.localPath(docID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toMap(json.RawMessage(data))
+}
+
+func (s *state) putLocal(docID string, u *update) (string, error) {
+	cur, err := s.readLocal(docID)
+	if err != nil {
+		return "", err
+	}
+	var curRev string
+	if cur != nil {
+		curRev, _ = cur["_rev"].(string)
+	}
+	if u.rev != curRev {
+		return "", errConflict
+	}
+	if u.deleted {
+		return "0-0", os.Remove(s.localPath(docID))
+	}
+	var gen int64
+	if curRev != "" {
+		gen, _ = strconv.ParseInt(strings.TrimPrefix(curRev, "0-"), 10, 64)
+	}
+	rev := "0-" + strconv.FormatInt(gen+1, 10)
+	u.body["_id"] = docID
+	u.body["_rev"] = rev
+	data, err := json.Marshal(u.body)
+	if err != nil {
+		return "", err
+	}
+	return rev, writeFile(s.localPath(docID), data)
+}
+
+func (s *state) getLocal(docID string) (map[string]interface{}, string, error) {
+	doc, err := s.readLocal(docID)
+	if err != nil {
+		return nil, "", err
+	}
+	if doc == nil {
+		return nil, "", errors.Status(http.StatusNotFound, "missing")
+	}
+	rev, _ := doc["_rev"].(string)
+	return doc, rev, nil
+}
+
+func (s *state) deleteLocal(docID, rev string) (string, error) {
+	doc, err := s.readLocal(docID)
+	if err != nil {
+		return "", err
+	}
+	if doc == nil {
+		return "", errors.Status(http.StatusNotFound, "missing")
+	}
+	if doc["_rev"] != rev {
+		return "", errConflict
+	}
+	return "0-0", os.Remove(s.localPath(docID))
+}
+
+// eachDoc calls fn for each document in the database, in unspecified order.
+func (s *state) eachDoc(fn func(*document) error) error {
+	files, err := ioutil.ReadDir(filepath.Join(s.path, docsDir))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		id, ok := unescape(f.Name())
+		if !f.IsDir() || !ok {
+			continue
+		}
+		doc, err := s.loadDoc(id)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			continue
+		}
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *db) Stats(_ context.Context) (*driver.DBStats, error) {
+	s, err := d.client.state(d.name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stats := &driver.DBStats{
+		Name:      d.name,
+		UpdateSeq: strconv.FormatInt(s.seq, 10),
+	}
+	err = s.eachDoc(func(doc *document) error {
+		if doc.winner().deleted {
+			stats.DeletedCount++
+		} else {
+			stats.DocCount++
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// Compact removes the files of all non-leaf revisions, and of any attachment
+// content no longer referenced. The revision tree itself is preserved in the
+// history of the leaf revisions.
+func (d *db) Compact(_ context.Context) error {
+	s, err := d.client.state(d.name)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	referenced := make(map[string]bool)
+	err = s.eachDoc(func(doc *document) error {
+		leaves := make(map[string]bool, len(doc.leaves))
+		for _, l := range doc.leaves {
+			leaves[l.rev] = true
+			for _, att := range l.attachments {
+				path, _ := s.attachmentPath(att.Digest)
+				referenced[filepath.Base(path)] = true
+			}
+		}
+		for rev := range doc.revs {
+			if leaves[rev] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(s.docPath(doc.id), escape(rev)+".json")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	files, err := ioutil.ReadDir(filepath.Join(s.path, attachmentsDir))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if !referenced[f.Name()] {
+			if err := os.Remove(filepath.Join(s.path, attachmentsDir, f.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CompactView is a no-op, as views are not supported.
+func (d *db) CompactView(_ context.Context, _ string) error {
+	_, err := d.client.state(d.name)
+	return err
+}
+
+// ViewCleanup is a no-op, as views are not supported.
+func (d *db) ViewCleanup(_ context.Context) error {
+	_, err := d.client.state(d.name)
+	return err
+}
+
+// Query is not supported.
+func (d *db) Query(_ context.Context, _, _ string, _ map[string]interface{}) (driver.Rows, error) {
+	return nil, errors.Status(http.StatusNotImplemented, "views are not supported")
+}
+
+func (d *db) Security(_ context.Context) (*driver.Security, error) {
+	s, err := d.client.state(d.name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sec := &driver.Security{}
+	data, err := ioutil.ReadFile(filepath.Join(s.path, securityFile))
+	if os.IsNotExist(err) {
+		return sec, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sec, json.Unmarshal(data, sec)
+}
+
+func (d *db) SetSecurity(_ context.Context, security *driver.Security) error {
+	s, err := d.client.state(d.name)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.Marshal(security)
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(s.path, securityFile), data)
+}
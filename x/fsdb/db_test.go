@@ -0,0 +1,267 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestPut(t *testing.T) {
+	tests := []struct {
+		name    string
+		docID   string
+		doc     interface{}
+		options kivik.Options
+		status  int
+		err     string
+	}{
+		{
+			name:  "new doc",
+			docID: "foo",
+			doc:   map[string]string{"a": "b"},
+		},
+		{
+			name:  "update",
+			docID: "existing",
+			doc:   map[string]string{"_rev": "1-4db7278dc43b3a6533143acd9d03fccc"},
+		},
+		{
+			name:   "conflict",
+			docID:  "existing",
+			doc:    map[string]string{"a": "b"},
+			status: http.StatusConflict,
+			err:    "document update conflict",
+		},
+		{
+			name:   "reserved id",
+			docID:  "_foo",
+			doc:    map[string]string{},
+			status: http.StatusBadRequest,
+			err:    "only reserved document ids may start with underscore",
+		},
+		{
+			name:   "invalid special field",
+			docID:  "foo",
+			doc:    map[string]string{"_foo": "bar"},
+			status: http.StatusBadRequest,
+			err:    "bad special document member: _foo",
+		},
+		{
+			name:    "new_edits=false without rev",
+			docID:   "foo",
+			doc:     map[string]string{},
+			options: kivik.Options{"new_edits": false},
+			status:  http.StatusBadRequest,
+			err:     "_rev is required when new_edits=false",
+		},
+		{
+			name:    "new_edits=false with mismatched history",
+			docID:   "foo",
+			doc:     map[string]interface{}{"_rev": "2-xxx", "_revisions": map[string]interface{}{"start": 2, "ids": []string{"yyy"}}},
+			options: kivik.Options{"new_edits": false},
+			status:  http.StatusBadRequest,
+			err:     "_rev does not match _revisions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newDB(t)
+			if rev := put(t, db, "existing", map[string]string{"a": "b"}); rev != "1-4db7278dc43b3a6533143acd9d03fccc" {
+				t.Fatalf("Unexpected rev: %s", rev)
+			}
+			_, err := db.Put(context.Background(), tt.docID, tt.doc, tt.options)
+			testy.StatusError(t, tt.err, tt.status, err)
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev1 := put(t, db, "foo", map[string]string{"a": "b"})
+	rev2 := put(t, db, "foo", map[string]string{"_rev": rev1, "a": "c"})
+
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "foo", kivik.Options{"revs": true}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"_id":  "foo",
+		"_rev": rev2,
+		"a":    "c",
+		"_revisions": map[string]interface{}{
+			"start": float64(2),
+			"ids":   []interface{}{rev2[2:], rev1[2:]},
+		},
+	}
+	if d := testy.DiffInterface(expected, doc); d != nil {
+		t.Error(d)
+	}
+
+	doc = nil
+	if err := db.Get(ctx, "foo", kivik.Options{"rev": rev1}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["a"] != "b" {
+		t.Errorf("Unexpected old revision: %v", doc)
+	}
+
+	err := db.Get(ctx, "bar").Err
+	testy.StatusError(t, "missing", http.StatusNotFound, err)
+
+	if _, err := db.Delete(ctx, "foo", rev2); err != nil {
+		t.Fatal(err)
+	}
+	err = db.Get(ctx, "foo").Err
+	testy.StatusError(t, "deleted", http.StatusNotFound, err)
+	_, err = db.Delete(ctx, "foo", rev2)
+	testy.StatusError(t, "deleted", http.StatusNotFound, err)
+
+	// A deleted document may be recreated without a rev.
+	put(t, db, "foo", map[string]string{})
+}
+
+func TestConflicts(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev1 := put(t, db, "foo", map[string]string{"a": "local"})
+	lower := put(t, db, "foo", map[string]interface{}{
+		"_rev":       "2-aaa",
+		"_revisions": map[string]interface{}{"start": 2, "ids": []string{"aaa", rev1[2:]}},
+		"a":          "lower",
+	}, kivik.Options{"new_edits": false})
+	higher := put(t, db, "foo", map[string]interface{}{
+		"_rev":       "2-bbb",
+		"_revisions": map[string]interface{}{"start": 2, "ids": []string{"bbb", rev1[2:]}},
+		"a":          "higher",
+	}, kivik.Options{"new_edits": "false"})
+	// Storing an existing revision again is a no-op.
+	put(t, db, "foo", map[string]interface{}{"_rev": "2-bbb"}, kivik.Options{"new_edits": false})
+
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "foo", kivik.Options{"conflicts": true}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"_id":        "foo",
+		"_rev":       higher,
+		"a":          "higher",
+		"_conflicts": []interface{}{lower},
+	}
+	if d := testy.DiffInterface(expected, doc); d != nil {
+		t.Error(d)
+	}
+
+	// Resolving the conflict, by deleting the winner, promotes the loser.
+	if _, err := db.Delete(ctx, "foo", higher); err != nil {
+		t.Fatal(err)
+	}
+	_, rev, err := db.GetMeta(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev != lower {
+		t.Errorf("Unexpected winner: %s", rev)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev1 := put(t, db, "foo", map[string]string{"a": "b"})
+	put(t, db, "foo", map[string]string{"_rev": rev1, "a": "c"})
+	if err := db.Compact(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err := db.Get(ctx, "foo", kivik.Options{"rev": rev1}).Err
+	testy.StatusError(t, "missing", http.StatusNotFound, err)
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "foo", kivik.Options{"revs": true}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if revs := doc["_revisions"].(map[string]interface{})["ids"].([]interface{}); len(revs) != 2 {
+		t.Errorf("Compaction should preserve history, got %v", revs)
+	}
+}
+
+func TestLocalDocs(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev := put(t, db, "_local/foo", map[string]string{"a": "b"})
+	if rev != "0-1" {
+		t.Errorf("Unexpected rev: %s", rev)
+	}
+	_, err := db.Put(ctx, "_local/foo", map[string]string{})
+	testy.StatusError(t, "document update conflict", http.StatusConflict, err)
+	rev = put(t, db, "_local/foo", map[string]string{"_rev": rev, "a": "c"})
+
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "_local/foo").ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"_id": "_local/foo", "_rev": "0-2", "a": "c"}
+	if d := testy.DiffInterface(expected, doc); d != nil {
+		t.Error(d)
+	}
+
+	if _, err := db.Delete(ctx, "_local/foo", rev); err != nil {
+		t.Fatal(err)
+	}
+	err = db.Get(ctx, "_local/foo").Err
+	testy.StatusError(t, "missing", http.StatusNotFound, err)
+
+	// Local documents are not replicated, so don't appear in the changes feed.
+	changes, err := db.Changes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.Next() {
+		t.Errorf("Unexpected change: %s", changes.ID())
+	}
+}
+
+func TestSecurity(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	sec, err := db.Security(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(&kivik.Security{}, sec); d != nil {
+		t.Error(d)
+	}
+	sec = &kivik.Security{
+		Members: kivik.Members{Roles: []string{"users"}},
+	}
+	if err := db.SetSecurity(ctx, sec); err != nil {
+		t.Fatal(err)
+	}
+	result, err := db.Security(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(sec, result); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	_, err := newDB(t).Query(context.Background(), "foo", "bar")
+	testy.StatusError(t, "views are not supported", http.StatusNotImplemented, err)
+}
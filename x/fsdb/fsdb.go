@@ -0,0 +1,216 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package fsdb provides an experimental filesystem-backed Kivik driver.
+//
+// Each database is stored as a directory beneath the root directory given as
+// the DSN:
+//
+//	<root>/<db>/docs/<docid>/<rev>.json  one file per document revision
+//	<root>/<db>/local/<docid>.json       local (non-replicating) documents
+//	<root>/<db>/attachments/<digest>     attachment content, by MD5 digest
+//	<root>/<db>/changes.log              append-only log of updates
+//	<root>/<db>/security.json            the security object
+//
+// Each revision file records its full revision history, from which the
+// document's revision tree is reconstructed, so conflicting revisions are
+// preserved. Together with support for new_edits=false, RevsDiff, and local
+// documents, this allows an fsdb database to act as a replication target.
+//
+// To use it, import the package for its side effect of registering the "fs"
+// driver:
+//
+//	import _ "github.com/go-kivik/kivik/v4/x/fsdb"
+//
+//	client, err := kivik.New("fs", "/path/to/root")
+//
+// A database must only be accessed by a single Client at a time.
+package fsdb // import "github.com/go-kivik/kivik/v4/x/fsdb"
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+)
+
+func init() {
+	kivik.Register("fs", &fsDriver{})
+}
+
+type fsDriver struct{}
+
+var _ driver.Driver = &fsDriver{}
+
+// NewClient returns a client for the databases stored beneath the root
+// directory dsn, which must already exist. A "file://" prefix is permitted.
+func (d *fsDriver) NewClient(dsn string) (driver.Client, error) {
+	root := strings.TrimPrefix(dsn, "file://")
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, errors.WrapStatus(http.StatusNotFound, err)
+	}
+	if !info.IsDir() {
+		return nil, errors.Statusf(http.StatusBadRequest, "%s is not a directory", root)
+	}
+	return &client{
+		root: root,
+		dbs:  make(map[string]*state),
+	}, nil
+}
+
+type client struct {
+	root string
+
+	mu  sync.Mutex
+	dbs map[string]*state
+}
+
+var _ driver.Client = &client{}
+
+const vendor = "Kivik Filesystem Adaptor"
+
+func (c *client) Version(_ context.Context) (*driver.Version, error) {
+	raw, _ := json.Marshal(map[string]interface{}{
+		"couchdb": "Welcome",
+		"version": kivik.KivikVersion,
+		"vendor": map[string]string{
+			"name": vendor,
+		},
+	})
+	return &driver.Version{
+		Version:     kivik.KivikVersion,
+		Vendor:      vendor,
+		RawResponse: raw,
+	}, nil
+}
+
+// escape converts a database name or document ID to a safe filename.
+func escape(name string) string {
+	name = url.PathEscape(name)
+	if strings.HasPrefix(name, ".") {
+		name = "%2E" + name[1:]
+	}
+	return name
+}
+
+func unescape(filename string) (string, bool) {
+	name, err := url.PathUnescape(filename)
+	return name, err == nil
+}
+
+func (c *client) dbPath(dbName string) string {
+	return filepath.Join(c.root, escape(dbName))
+}
+
+func isDB(path string) bool {
+	info, err := os.Stat(filepath.Join(path, docsDir))
+	return err == nil && info.IsDir()
+}
+
+func (c *client) AllDBs(_ context.Context, _ map[string]interface{}) ([]string, error) {
+	files, err := ioutil.ReadDir(c.root)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		if !f.IsDir() || !isDB(filepath.Join(c.root, f.Name())) {
+			continue
+		}
+		if name, ok := unescape(f.Name()); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (c *client) DBExists(_ context.Context, dbName string, _ map[string]interface{}) (bool, error) {
+	return isDB(c.dbPath(dbName)), nil
+}
+
+var (
+	validDBName = regexp.MustCompile(`^[a-z][a-z0-9_$()+/-]*$`)
+	systemDBs   = map[string]bool{
+		"_users":          true,
+		"_replicator":     true,
+		"_global_changes": true,
+	}
+)
+
+func (c *client) CreateDB(_ context.Context, dbName string, _ map[string]interface{}) error {
+	if !validDBName.MatchString(dbName) && !systemDBs[dbName] {
+		return errors.Status(http.StatusBadRequest, "invalid database name")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	path := c.dbPath(dbName)
+	if isDB(path) {
+		return errors.Status(http.StatusPreconditionFailed, "database exists")
+	}
+	for _, dir := range []string{docsDir, localDir, attachmentsDir} {
+		if err := os.MkdirAll(filepath.Join(path, dir), 0o755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *client) DestroyDB(_ context.Context, dbName string, _ map[string]interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	path := c.dbPath(dbName)
+	if !isDB(path) {
+		return errors.Status(http.StatusNotFound, "database does not exist")
+	}
+	if s, ok := c.dbs[dbName]; ok {
+		s.close()
+		delete(c.dbs, dbName)
+	}
+	return os.RemoveAll(path)
+}
+
+func (c *client) DB(_ context.Context, dbName string, _ map[string]interface{}) (driver.DB, error) {
+	return &db{client: c, name: dbName}, nil
+}
+
+// state returns the shared, in-process state of the named database, or a
+// status 404 error if it does not exist.
+func (c *client) state(dbName string) (*state, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.dbs[dbName]; ok {
+		return s, nil
+	}
+	path := c.dbPath(dbName)
+	if !isDB(path) {
+		return nil, errors.Status(http.StatusNotFound, "database does not exist")
+	}
+	s, err := openState(path)
+	if err != nil {
+		return nil, err
+	}
+	c.dbs[dbName] = s
+	return s, nil
+}
@@ -0,0 +1,139 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// tempDir returns a new temporary directory, which is removed when the test
+// completes.
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "fsdb-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func newClient(t *testing.T, root string) *kivik.Client {
+	t.Helper()
+	client, err := kivik.New("fs", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// newDB returns a new, empty database in a new root directory.
+func newDB(t *testing.T) *kivik.DB {
+	t.Helper()
+	client := newClient(t, tempDir(t))
+	if err := client.CreateDB(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	return client.DB(context.Background(), "test")
+}
+
+// put stores doc, and fails the test on error.
+func put(t *testing.T, db *kivik.DB, docID string, doc interface{}, options ...kivik.Options) string {
+	t.Helper()
+	rev, err := db.Put(context.Background(), docID, doc, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rev
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := kivik.New("fs", "/does/not/exist")
+	testy.StatusError(t, "stat /does/not/exist: no such file or directory", http.StatusNotFound, err)
+
+	if _, err := kivik.New("fs", "file://"+tempDir(t)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDBs(t *testing.T) {
+	ctx := context.Background()
+	client := newClient(t, tempDir(t))
+	for _, name := range []string{"b", "a/b", "_users"} {
+		if err := client.CreateDB(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := client.CreateDB(ctx, "b")
+	testy.StatusError(t, "database exists", http.StatusPreconditionFailed, err)
+	err = client.CreateDB(ctx, "B")
+	testy.StatusError(t, "invalid database name", http.StatusBadRequest, err)
+
+	dbs, err := client.AllDBs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"_users", "a/b", "b"}, dbs); d != nil {
+		t.Error(d)
+	}
+
+	if err := client.DestroyDB(ctx, "a/b"); err != nil {
+		t.Fatal(err)
+	}
+	err = client.DestroyDB(ctx, "a/b")
+	testy.StatusError(t, "database does not exist", http.StatusNotFound, err)
+	exists, err := client.DBExists(ctx, "a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("Database should not exist after DestroyDB")
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	ctx := context.Background()
+	root := tempDir(t)
+	client := newClient(t, root)
+	if err := client.CreateDB(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+	rev := put(t, client.DB(ctx, "test"), "foo", map[string]string{"a": "b"})
+
+	db := newClient(t, root).DB(ctx, "test")
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "foo").ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"_id": "foo", "_rev": rev, "a": "b"}
+	if d := testy.DiffInterface(expected, doc); d != nil {
+		t.Error(d)
+	}
+	stats, err := db.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.UpdateSeq != "1" || stats.DocCount != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,177 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
+)
+
+// rows is a driver.Rows backed by a slice.
+type rows struct {
+	rows      []*driver.Row
+	offset    int64
+	totalRows int64
+}
+
+var _ driver.Rows = &rows{}
+
+func (r *rows) Next(row *driver.Row) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	*row = *r.rows[0]
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func (r *rows) Close() error {
+	r.rows = nil
+	return nil
+}
+
+func (r *rows) Offset() int64     { return r.offset }
+func (r *rows) TotalRows() int64  { return r.totalRows }
+func (r *rows) UpdateSeq() string { return "" }
+
+// keyOpt returns the named string option, which may be given as raw JSON.
+func keyOpt(options map[string]interface{}, keys ...string) (string, bool, error) {
+	v, ok, err := queryopts.JSON(options, keys...)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	key, ok := v.(string)
+	if !ok {
+		return "", false, errors.Statusf(http.StatusBadRequest, "invalid value for %s: must be a string", keys[0])
+	}
+	return key, true, nil
+}
+
+// AllDocs supports the include_docs, startkey, endkey, key, inclusive_end,
+// descending, skip and limit options.
+func (d *db) AllDocs(_ context.Context, options map[string]interface{}) (driver.Rows, error) {
+	start, hasStart, err := keyOpt(options, "startkey", "start_key")
+	if err != nil {
+		return nil, err
+	}
+	end, hasEnd, err := keyOpt(options, "endkey", "end_key")
+	if err != nil {
+		return nil, err
+	}
+	if key, ok, err := keyOpt(options, "key"); err != nil {
+		return nil, err
+	} else if ok {
+		start, end, hasStart, hasEnd = key, key, true, true
+	}
+	inclusiveEnd := true
+	if _, ok := options["inclusive_end"]; ok {
+		if inclusiveEnd, err = queryopts.Bool(options, "inclusive_end"); err != nil {
+			return nil, err
+		}
+	}
+	descending, err := queryopts.Bool(options, "descending")
+	if err != nil {
+		return nil, err
+	}
+	skip, _, err := queryopts.Int(options, "skip")
+	if err != nil {
+		return nil, err
+	}
+	limit, hasLimit, err := queryopts.Int(options, "limit")
+	if err != nil {
+		return nil, err
+	}
+	includeDocs, err := queryopts.Bool(options, "include_docs")
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := d.client.state(d.name)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var docs []*document
+	err = s.eachDoc(func(doc *document) error {
+		if !doc.winner().deleted {
+			docs = append(docs, doc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		return (docs[i].id < docs[j].id) != descending
+	})
+	// With descending=true, startkey is the upper bound.
+	cmp := strings.Compare
+	if descending {
+		cmp = func(a, b string) int { return strings.Compare(b, a) }
+	}
+	result := &rows{totalRows: int64(len(docs))}
+	for _, doc := range docs {
+		if hasStart && cmp(doc.id, start) < 0 {
+			result.offset++
+			continue
+		}
+		if hasEnd {
+			if c := cmp(doc.id, end); c > 0 || (c == 0 && !inclusiveEnd) {
+				break
+			}
+		}
+		if skip > 0 {
+			skip--
+			result.offset++
+			continue
+		}
+		if hasLimit && int64(len(result.rows)) >= limit {
+			break
+		}
+		row, err := s.row(doc, includeDocs)
+		if err != nil {
+			return nil, err
+		}
+		result.rows = append(result.rows, row)
+	}
+	return result, nil
+}
+
+func (s *state) row(doc *document, includeDocs bool) (*driver.Row, error) {
+	key, _ := json.Marshal(doc.id)
+	value, _ := json.Marshal(map[string]string{"rev": doc.winner().rev})
+	row := &driver.Row{
+		ID:    doc.id,
+		Key:   key,
+		Value: value,
+	}
+	if includeDocs {
+		body, err := s.render(doc, doc.winner(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if row.Doc, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
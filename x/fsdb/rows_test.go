@@ -0,0 +1,114 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"context"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestAllDocs(t *testing.T) {
+	type tst struct {
+		options  kivik.Options
+		expected []string
+		offset   int64
+	}
+	tests := testy.NewTable()
+	tests.Add("all", tst{
+		expected: []string{"_design/foo", "a", "b", "c"},
+	})
+	tests.Add("descending", tst{
+		options:  kivik.Options{"descending": true},
+		expected: []string{"c", "b", "a", "_design/foo"},
+	})
+	tests.Add("range", tst{
+		options:  kivik.Options{"startkey": "a", "endkey": "b"},
+		expected: []string{"a", "b"},
+		offset:   1,
+	})
+	tests.Add("exclusive end", tst{
+		options:  kivik.Options{"start_key": []byte(`"a"`), "end_key": "b", "inclusive_end": false},
+		expected: []string{"a"},
+		offset:   1,
+	})
+	tests.Add("descending range", tst{
+		options:  kivik.Options{"startkey": "b", "endkey": "a", "descending": true},
+		expected: []string{"b", "a"},
+		offset:   1,
+	})
+	tests.Add("key", tst{
+		options:  kivik.Options{"key": "b"},
+		expected: []string{"b"},
+		offset:   2,
+	})
+	tests.Add("skip and limit", tst{
+		options:  kivik.Options{"skip": 1, "limit": "2"},
+		expected: []string{"a", "b"},
+		offset:   1,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		db := newDB(t)
+		for _, id := range []string{"b", "a", "c", "_design/foo", "_local/foo"} {
+			put(t, db, id, map[string]string{})
+		}
+		rev := put(t, db, "deleted", map[string]string{})
+		if _, err := db.Delete(context.Background(), "deleted", rev); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := db.AllDocs(context.Background(), tt.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for rows.Next() {
+			ids = append(ids, rows.ID())
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(tt.expected, ids); d != nil {
+			t.Error(d)
+		}
+		if rows.Offset() != tt.offset {
+			t.Errorf("Unexpected offset: %d", rows.Offset())
+		}
+		if rows.TotalRows() != 4 {
+			t.Errorf("Unexpected total rows: %d", rows.TotalRows())
+		}
+	})
+}
+
+func TestAllDocsIncludeDocs(t *testing.T) {
+	db := newDB(t)
+	rev := put(t, db, "foo", map[string]string{"a": "b"})
+	rows, err := db.AllDocs(context.Background(), kivik.Options{"include_docs": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rows.Next() {
+		t.Fatal("Expected a row")
+	}
+	var doc map[string]interface{}
+	if err := rows.ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"_id": "foo", "_rev": rev, "a": "b"}
+	if d := testy.DiffInterface(expected, doc); d != nil {
+		t.Error(d)
+	}
+}
@@ -0,0 +1,375 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package fsdb
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-kivik/kivik/v4/errors"
+)
+
+const (
+	docsDir        = "docs"
+	localDir       = "local"
+	attachmentsDir = "attachments"
+	changesLog     = "changes.log"
+	securityFile   = "security.json"
+)
+
+// state is the in-process state of an open database. All access to the
+// database's files is serialized through mu.
+type state struct {
+	path string
+
+	mu     sync.RWMutex
+	seq    int64
+	closed bool
+	// updated is closed, and replaced, whenever the database changes, to wake
+	// up any waiting changes feeds.
+	updated chan struct{}
+}
+
+// logEntry is a single line of the changes log.
+type logEntry struct {
+	Seq int64  `json:"seq"`
+	ID  string `json:"id"`
+	Rev string `json:"rev"`
+}
+
+func openState(path string) (*state, error) {
+	s := &state{
+		path:    path,
+		updated: make(chan struct{}),
+	}
+	err := s.scanLog(func(e *logEntry) {
+		s.seq = e.Seq
+	})
+	return s, err
+}
+
+// scanLog calls fn for each entry in the changes log, in order.
+func (s *state) scanLog(fn func(*logEntry)) error {
+	f, err := os.Open(filepath.Join(s.path, changesLog))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint: errcheck
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e logEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return errors.Wrap(err, "corrupt changes log")
+		}
+		fn(&e)
+	}
+	return scanner.Err()
+}
+
+// appendLog records an update to the document, and wakes up any waiting
+// changes feeds. It must be called with s.mu held for writing.
+func (s *state) appendLog(id, rev string) error {
+	f, err := os.OpenFile(filepath.Join(s.path, changesLog), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	line, _ := json.Marshal(logEntry{Seq: s.seq + 1, ID: id, Rev: rev})
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s.seq++
+	s.notify()
+	return nil
+}
+
+func (s *state) notify() {
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+func (s *state) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.notify()
+}
+
+// writeFile atomically replaces the named file with data.
+func writeFile(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// revisions is a revision history, as stored in the _revisions field.
+type revisions struct {
+	Start int64    `json:"start"`
+	IDs   []string `json:"ids"`
+}
+
+// revs returns the full revision IDs in the history, newest first.
+func (r revisions) revs() []string {
+	revs := make([]string, len(r.IDs))
+	for i, id := range r.IDs {
+		revs[i] = fmt.Sprintf("%d-%s", r.Start-int64(i), id)
+	}
+	return revs
+}
+
+// attachment is the stored metadata of an attachment. The content is stored
+// separately, by digest.
+type attachment struct {
+	ContentType string `json:"content_type"`
+	Digest      string `json:"digest"`
+	Length      int64  `json:"length"`
+	RevPos      int64  `json:"revpos"`
+}
+
+// revision is a single stored revision of a document.
+type revision struct {
+	rev         string
+	deleted     bool
+	revisions   revisions
+	body        map[string]interface{}
+	attachments map[string]*attachment
+}
+
+func (r *revision) generation() int64 {
+	return r.revisions.Start
+}
+
+// document is a document with all of its stored revisions.
+type document struct {
+	id   string
+	revs map[string]*revision
+	// leaves are the leaf revisions of the revision tree, winner first.
+	leaves []*revision
+}
+
+func (d *document) winner() *revision {
+	return d.leaves[0]
+}
+
+func (d *document) leaf(rev string) *revision {
+	for _, l := range d.leaves {
+		if l.rev == rev {
+			return l
+		}
+	}
+	return nil
+}
+
+// known reports whether rev is part of the document's revision tree, even if
+// its content has been compacted away.
+func (d *document) known(rev string) bool {
+	for _, r := range d.revs {
+		for _, h := range r.revisions.revs() {
+			if h == rev {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *state) docPath(id string) string {
+	return filepath.Join(s.path, docsDir, escape(id))
+}
+
+// loadDoc reads all stored revisions of the document, or returns nil if it
+// does not exist.
+func (s *state) loadDoc(id string) (*document, error) {
+	files, err := ioutil.ReadDir(s.docPath(id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc := &document{id: id, revs: make(map[string]*revision)}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		r, err := readRevision(filepath.Join(s.docPath(id), f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		doc.revs[r.rev] = r
+	}
+	if len(doc.revs) == 0 {
+		return nil, nil
+	}
+	ancestors := make(map[string]bool)
+	for _, r := range doc.revs {
+		for _, h := range r.revisions.revs()[1:] {
+			ancestors[h] = true
+		}
+	}
+	for rev, r := range doc.revs {
+		if !ancestors[rev] {
+			doc.leaves = append(doc.leaves, r)
+		}
+	}
+	// The winning revision is the non-deleted leaf with the longest history,
+	// with ties broken by comparing revision IDs, as in CouchDB.
+	sort.Slice(doc.leaves, func(i, j int) bool {
+		a, b := doc.leaves[i], doc.leaves[j]
+		if a.deleted != b.deleted {
+			return !a.deleted
+		}
+		if a.generation() != b.generation() {
+			return a.generation() > b.generation()
+		}
+		return a.rev > b.rev
+	})
+	return doc, nil
+}
+
+// storedRevision is the on-disk format of a revision.
+type storedRevision struct {
+	Rev         string                 `json:"_rev"`
+	Deleted     bool                   `json:"_deleted,omitempty"`
+	Revisions   revisions              `json:"_revisions"`
+	Attachments map[string]*attachment `json:"_attachments,omitempty"`
+	Body        map[string]interface{} `json:"body"`
+}
+
+func readRevision(path string) (*revision, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var stored storedRevision
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&stored); err != nil {
+		return nil, errors.Wrapf(err, "corrupt revision file %s", path)
+	}
+	return &revision{
+		rev:         stored.Rev,
+		deleted:     stored.Deleted,
+		revisions:   stored.Revisions,
+		body:        stored.Body,
+		attachments: stored.Attachments,
+	}, nil
+}
+
+// writeRevision stores r, and records the update in the changes log. It must
+// be called with s.mu held for writing.
+func (s *state) writeRevision(id string, r *revision) error {
+	if err := os.MkdirAll(s.docPath(id), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(storedRevision{
+		Rev:         r.rev,
+		Deleted:     r.deleted,
+		Revisions:   r.revisions,
+		Attachments: r.attachments,
+		Body:        r.body,
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(s.docPath(id), escape(r.rev)+".json"), data); err != nil {
+		return err
+	}
+	return s.appendLog(id, r.rev)
+}
+
+func (s *state) attachmentPath(digest string) (string, error) {
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(digest, "md5-"))
+	if err != nil || !strings.HasPrefix(digest, "md5-") {
+		return "", errors.Statusf(http.StatusBadRequest, "invalid attachment digest %s", digest)
+	}
+	return filepath.Join(s.path, attachmentsDir, hex.EncodeToString(sum)), nil
+}
+
+// writeAttachment stores the attachment content, and returns its metadata.
+func (s *state) writeAttachment(contentType string, data []byte) (*attachment, error) {
+	sum := md5.Sum(data)
+	att := &attachment{
+		ContentType: contentType,
+		Digest:      "md5-" + base64.StdEncoding.EncodeToString(sum[:]),
+		Length:      int64(len(data)),
+	}
+	path, _ := s.attachmentPath(att.Digest)
+	if _, err := os.Stat(path); err == nil {
+		return att, nil
+	}
+	return att, writeFile(path, data)
+}
+
+func (s *state) readAttachment(att *attachment) ([]byte, error) {
+	path, err := s.attachmentPath(att.Digest)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(path)
+}
+
+// revID calculates a deterministic revision ID for a new revision.
+func revID(gen int64, prev string, r *revision) string {
+	digests := make([]string, 0, len(r.attachments))
+	for name, att := range r.attachments {
+		digests = append(digests, name+":"+att.Digest)
+	}
+	sort.Strings(digests)
+	data, _ := json.Marshal([]interface{}{prev, r.deleted, r.body, digests})
+	sum := md5.Sum(data)
+	return strconv.FormatInt(gen, 10) + "-" + hex.EncodeToString(sum[:])
+}
+
+// newUUID returns a random document ID.
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
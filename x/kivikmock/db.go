@@ -0,0 +1,176 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivikmock
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+type db struct {
+	mock *Client
+	name string
+}
+
+var _ driver.DB = &db{}
+
+func (d *db) call(method string) string {
+	return fmt.Sprintf("DB(%q).%s", d.name, method)
+}
+
+func (d *db) AllDocs(_ context.Context, options map[string]interface{}) (driver.Rows, error) {
+	e, err := d.mock.match(d.call("AllDocs()"), func(e expectation) bool {
+		x, ok := e.(*ExpectedAllDocs)
+		return ok && x.db == d.name && matchOptions(x.options, options)
+	})
+	if err != nil {
+		return nil, err
+	}
+	x := e.(*ExpectedAllDocs)
+	if x.err != nil {
+		return nil, x.err
+	}
+	return x.rows.iterator(), nil
+}
+
+func (d *db) Get(_ context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+	e, err := d.mock.match(d.call(fmt.Sprintf("Get(%q)", docID)), func(e expectation) bool {
+		x, ok := e.(*ExpectedGet)
+		return ok && x.db == d.name && x.docID == docID && matchOptions(x.options, options)
+	})
+	if err != nil {
+		return nil, err
+	}
+	x := e.(*ExpectedGet)
+	if x.err != nil {
+		return nil, x.err
+	}
+	return &driver.Document{
+		ContentLength: int64(len(x.body)),
+		Rev:           x.rev,
+		Body:          ioutil.NopCloser(bytes.NewReader(x.body)),
+	}, nil
+}
+
+func (d *db) CreateDoc(_ context.Context, doc interface{}, options map[string]interface{}) (string, string, error) {
+	e, err := d.mock.match(d.call("CreateDoc()"), func(e expectation) bool {
+		x, ok := e.(*ExpectedCreateDoc)
+		return ok && x.db == d.name && matchDoc(x.doc, doc) && matchOptions(x.options, options)
+	})
+	if err != nil {
+		return "", "", err
+	}
+	x := e.(*ExpectedCreateDoc)
+	if x.err != nil {
+		return "", "", x.err
+	}
+	return x.docID, x.rev, nil
+}
+
+func (d *db) Put(_ context.Context, docID string, doc interface{}, options map[string]interface{}) (string, error) {
+	e, err := d.mock.match(d.call(fmt.Sprintf("Put(%q)", docID)), func(e expectation) bool {
+		x, ok := e.(*ExpectedPut)
+		return ok && x.db == d.name && x.docID == docID && matchDoc(x.doc, doc) && matchOptions(x.options, options)
+	})
+	if err != nil {
+		return "", err
+	}
+	x := e.(*ExpectedPut)
+	if x.err != nil {
+		return "", x.err
+	}
+	return x.rev, nil
+}
+
+func (d *db) Delete(_ context.Context, docID, rev string, options map[string]interface{}) (string, error) {
+	e, err := d.mock.match(d.call(fmt.Sprintf("Delete(%q)", docID)), func(e expectation) bool {
+		x, ok := e.(*ExpectedDelete)
+		return ok && x.db == d.name && x.docID == docID &&
+			(x.rev == nil || *x.rev == rev) && matchOptions(x.options, options)
+	})
+	if err != nil {
+		return "", err
+	}
+	x := e.(*ExpectedDelete)
+	if x.err != nil {
+		return "", x.err
+	}
+	return x.newRev, nil
+}
+
+func (d *db) Query(_ context.Context, ddoc, view string, options map[string]interface{}) (driver.Rows, error) {
+	e, err := d.mock.match(d.call(fmt.Sprintf("Query(%q, %q)", ddoc, view)), func(e expectation) bool {
+		x, ok := e.(*ExpectedQuery)
+		return ok && x.db == d.name && x.ddoc == ddoc && x.view == view && matchOptions(x.options, options)
+	})
+	if err != nil {
+		return nil, err
+	}
+	x := e.(*ExpectedQuery)
+	if x.err != nil {
+		return nil, x.err
+	}
+	return x.rows.iterator(), nil
+}
+
+// The remaining methods have no expectations yet, so any call to them is
+// unexpected.
+
+func (d *db) unexpected(method string) error {
+	_, err := d.mock.match(d.call(method+"()"), func(expectation) bool { return false })
+	return err
+}
+
+func (d *db) Stats(_ context.Context) (*driver.DBStats, error) {
+	return nil, d.unexpected("Stats")
+}
+
+func (d *db) Compact(_ context.Context) error {
+	return d.unexpected("Compact")
+}
+
+func (d *db) CompactView(_ context.Context, _ string) error {
+	return d.unexpected("CompactView")
+}
+
+func (d *db) ViewCleanup(_ context.Context) error {
+	return d.unexpected("ViewCleanup")
+}
+
+func (d *db) Security(_ context.Context) (*driver.Security, error) {
+	return nil, d.unexpected("Security")
+}
+
+func (d *db) SetSecurity(_ context.Context, _ *driver.Security) error {
+	return d.unexpected("SetSecurity")
+}
+
+func (d *db) Changes(_ context.Context, _ map[string]interface{}) (driver.Changes, error) {
+	return nil, d.unexpected("Changes")
+}
+
+func (d *db) PutAttachment(_ context.Context, _, _ string, _ *driver.Attachment, _ map[string]interface{}) (string, error) {
+	return "", d.unexpected("PutAttachment")
+}
+
+func (d *db) GetAttachment(_ context.Context, _, _ string, _ map[string]interface{}) (*driver.Attachment, error) {
+	return nil, d.unexpected("GetAttachment")
+}
+
+func (d *db) DeleteAttachment(_ context.Context, _, _, _ string, _ map[string]interface{}) (string, error) {
+	return "", d.unexpected("DeleteAttachment")
+}
@@ -0,0 +1,190 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivikmock
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestDB(t *testing.T) {
+	type tt struct {
+		setup  func(*Client)
+		call   func(*kivik.DB) (interface{}, error)
+		result interface{}
+		status int
+		err    string
+	}
+	tests := testy.NewTable()
+	tests.Add("Get", tt{
+		setup: func(m *Client) {
+			m.ExpectGet("db", "foo").WillReturn(map[string]string{"_id": "foo", "_rev": "1-xxx"})
+		},
+		call: func(db *kivik.DB) (interface{}, error) {
+			row := db.Get(context.Background(), "foo")
+			var doc map[string]string
+			err := row.ScanDoc(&doc)
+			return []interface{}{row.Rev, doc}, err
+		},
+		result: []interface{}{"1-xxx", map[string]string{"_id": "foo", "_rev": "1-xxx"}},
+	})
+	tests.Add("Get wrong db", tt{
+		setup: func(m *Client) {
+			m.ExpectGet("other", "foo")
+		},
+		call: func(db *kivik.DB) (interface{}, error) {
+			return nil, db.Get(context.Background(), "foo").Err
+		},
+		status: http.StatusInternalServerError,
+		err:    `kivikmock: call to DB("db").Get("foo") was not expected, next expectation is: DB("other").Get("foo")`,
+	})
+	tests.Add("Get not found", tt{
+		setup: func(m *Client) {
+			m.ExpectGet("db", "foo").WillReturnError(&kivik.Error{HTTPStatus: http.StatusNotFound, Message: "missing"})
+		},
+		call: func(db *kivik.DB) (interface{}, error) {
+			return nil, db.Get(context.Background(), "foo").Err
+		},
+		status: http.StatusNotFound,
+		err:    "missing",
+	})
+	tests.Add("Put", tt{
+		setup: func(m *Client) {
+			m.ExpectPut("db", "foo").WithDoc(`{"a":1}`).WillReturn("1-xxx")
+		},
+		call: func(db *kivik.DB) (interface{}, error) {
+			return db.Put(context.Background(), "foo", map[string]int{"a": 1})
+		},
+		result: "1-xxx",
+	})
+	tests.Add("Put wrong doc", tt{
+		setup: func(m *Client) {
+			m.ExpectPut("db", "foo").WithDoc(map[string]int{"a": 1})
+		},
+		call: func(db *kivik.DB) (interface{}, error) {
+			return db.Put(context.Background(), "foo", map[string]int{"a": 2})
+		},
+		result: "",
+		status: http.StatusInternalServerError,
+		err:    `kivikmock: call to DB("db").Put("foo") was not expected, next expectation is: DB("db").Put("foo")`,
+	})
+	tests.Add("CreateDoc", tt{
+		setup: func(m *Client) {
+			m.ExpectCreateDoc("db").WithDoc(AnyValue).WillReturn("foo", "1-xxx")
+		},
+		call: func(db *kivik.DB) (interface{}, error) {
+			id, rev, err := db.CreateDoc(context.Background(), map[string]int{"a": 1})
+			return []string{id, rev}, err
+		},
+		result: []string{"foo", "1-xxx"},
+	})
+	tests.Add("Delete", tt{
+		setup: func(m *Client) {
+			m.ExpectDelete("db", "foo").WithRev("1-xxx").WillReturn("2-xxx")
+		},
+		call: func(db *kivik.DB) (interface{}, error) {
+			return db.Delete(context.Background(), "foo", "1-xxx")
+		},
+		result: "2-xxx",
+	})
+	tests.Add("Delete wrong rev", tt{
+		setup: func(m *Client) {
+			m.ExpectDelete("db", "foo").WithRev("1-xxx")
+		},
+		call: func(db *kivik.DB) (interface{}, error) {
+			return db.Delete(context.Background(), "foo", "1-yyy")
+		},
+		result: "",
+		status: http.StatusInternalServerError,
+		err:    `kivikmock: call to DB("db").Delete("foo") was not expected, next expectation is: DB("db").Delete("foo")`,
+	})
+	tests.Add("Query", tt{
+		setup: func(m *Client) {
+			m.ExpectQuery("db", "_design/foo", "bar").
+				WithOptions(map[string]interface{}{"limit": AnyValue}).
+				WillReturnRows(NewRows().
+					AddRow(Row{ID: "a", Key: "a", Value: 1}).
+					AddRow(Row{ID: "b", Key: "b", Value: 2}).
+					TotalRows(10))
+		},
+		call: func(db *kivik.DB) (interface{}, error) {
+			rows, err := db.Query(context.Background(), "foo", "bar", kivik.Options{"limit": 2})
+			if err != nil {
+				return nil, err
+			}
+			var values []int
+			for rows.Next() {
+				var v int
+				if err := rows.ScanValue(&v); err != nil {
+					return nil, err
+				}
+				values = append(values, v)
+			}
+			return []interface{}{values, rows.TotalRows()}, rows.Err()
+		},
+		result: []interface{}{[]int{1, 2}, int64(10)},
+	})
+	tests.Add("AllDocs", tt{
+		setup: func(m *Client) {
+			m.ExpectAllDocs("db").WillReturnRows(NewRows().
+				AddRow(Row{ID: "a", Key: "a", Doc: map[string]string{"_id": "a"}}).
+				AddRow(Row{ID: "b", Key: "b"}).
+				RowError(1, &kivik.Error{HTTPStatus: http.StatusBadGateway, Message: "broken"}))
+		},
+		call: func(db *kivik.DB) (interface{}, error) {
+			rows, err := db.AllDocs(context.Background())
+			if err != nil {
+				return nil, err
+			}
+			var ids []string
+			for rows.Next() {
+				var doc map[string]string
+				if err := rows.ScanDoc(&doc); err != nil {
+					return nil, err
+				}
+				ids = append(ids, doc["_id"])
+			}
+			return ids, rows.Err()
+		},
+		result: []string{"a"},
+		status: http.StatusBadGateway,
+		err:    "broken",
+	})
+	tests.Add("unsupported method", tt{
+		setup: func(*Client) {},
+		call: func(db *kivik.DB) (interface{}, error) {
+			return db.Stats(context.Background())
+		},
+		result: (*kivik.DBStats)(nil),
+		status: http.StatusInternalServerError,
+		err:    `kivikmock: call to DB("db").Stats() was not expected`,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		client, mock, err := New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.setup(mock)
+		result, err := tt.call(client.DB(context.Background(), "db"))
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.result, result); d != nil {
+			t.Error(d)
+		}
+	})
+}
@@ -0,0 +1,353 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivikmock
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type expectation interface {
+	fmt.Stringer
+	met() bool
+	trigger()
+}
+
+// commonExpectation holds the state shared by all expectations.
+type commonExpectation struct {
+	triggered bool
+	err       error
+	options   map[string]interface{}
+}
+
+func (e *commonExpectation) met() bool { return e.triggered }
+func (e *commonExpectation) trigger()  { e.triggered = true }
+
+// describe formats a call for error messages.
+func (e *commonExpectation) describe(call string) string {
+	if e.options == nil {
+		return call
+	}
+	return fmt.Sprintf("%s with options %v", call, e.options)
+}
+
+// ExpectedAllDBs represents an expectation for a call to AllDBs.
+type ExpectedAllDBs struct {
+	commonExpectation
+	ret []string
+}
+
+func (e *ExpectedAllDBs) String() string { return e.describe("AllDBs()") }
+
+// WithOptions sets the options the call is expected to receive.
+func (e *ExpectedAllDBs) WithOptions(options map[string]interface{}) *ExpectedAllDBs {
+	e.options = options
+	return e
+}
+
+// WillReturn sets the database names to return.
+func (e *ExpectedAllDBs) WillReturn(dbNames []string) *ExpectedAllDBs {
+	e.ret = dbNames
+	return e
+}
+
+// WillReturnError sets the error to return.
+func (e *ExpectedAllDBs) WillReturnError(err error) *ExpectedAllDBs {
+	e.err = err
+	return e
+}
+
+// ExpectedDBExists represents an expectation for a call to DBExists.
+type ExpectedDBExists struct {
+	commonExpectation
+	dbName string
+	ret    bool
+}
+
+func (e *ExpectedDBExists) String() string {
+	return e.describe(fmt.Sprintf("DBExists(%q)", e.dbName))
+}
+
+// WithOptions sets the options the call is expected to receive.
+func (e *ExpectedDBExists) WithOptions(options map[string]interface{}) *ExpectedDBExists {
+	e.options = options
+	return e
+}
+
+// WillReturn sets whether the database is reported to exist.
+func (e *ExpectedDBExists) WillReturn(exists bool) *ExpectedDBExists {
+	e.ret = exists
+	return e
+}
+
+// WillReturnError sets the error to return.
+func (e *ExpectedDBExists) WillReturnError(err error) *ExpectedDBExists {
+	e.err = err
+	return e
+}
+
+// ExpectedCreateDB represents an expectation for a call to CreateDB.
+type ExpectedCreateDB struct {
+	commonExpectation
+	dbName string
+}
+
+func (e *ExpectedCreateDB) String() string {
+	return e.describe(fmt.Sprintf("CreateDB(%q)", e.dbName))
+}
+
+// WithOptions sets the options the call is expected to receive.
+func (e *ExpectedCreateDB) WithOptions(options map[string]interface{}) *ExpectedCreateDB {
+	e.options = options
+	return e
+}
+
+// WillReturnError sets the error to return.
+func (e *ExpectedCreateDB) WillReturnError(err error) *ExpectedCreateDB {
+	e.err = err
+	return e
+}
+
+// ExpectedDestroyDB represents an expectation for a call to DestroyDB.
+type ExpectedDestroyDB struct {
+	commonExpectation
+	dbName string
+}
+
+func (e *ExpectedDestroyDB) String() string {
+	return e.describe(fmt.Sprintf("DestroyDB(%q)", e.dbName))
+}
+
+// WithOptions sets the options the call is expected to receive.
+func (e *ExpectedDestroyDB) WithOptions(options map[string]interface{}) *ExpectedDestroyDB {
+	e.options = options
+	return e
+}
+
+// WillReturnError sets the error to return.
+func (e *ExpectedDestroyDB) WillReturnError(err error) *ExpectedDestroyDB {
+	e.err = err
+	return e
+}
+
+// ExpectedGet represents an expectation for a call to Get.
+type ExpectedGet struct {
+	commonExpectation
+	db, docID string
+	body      []byte
+	rev       string
+}
+
+func (e *ExpectedGet) String() string {
+	return e.describe(fmt.Sprintf("DB(%q).Get(%q)", e.db, e.docID))
+}
+
+// WithOptions sets the options the call is expected to receive.
+func (e *ExpectedGet) WithOptions(options map[string]interface{}) *ExpectedGet {
+	e.options = options
+	return e
+}
+
+// WillReturn sets the document to return. doc is marshaled to JSON, unless
+// it is already a []byte or json.RawMessage. The returned revision is taken
+// from the document's _rev field, if any.
+func (e *ExpectedGet) WillReturn(doc interface{}) *ExpectedGet {
+	body, err := toJSON(doc)
+	if err != nil {
+		e.err = err
+		return e
+	}
+	var meta struct {
+		Rev string `json:"_rev"`
+	}
+	_ = json.Unmarshal(body, &meta)
+	e.body, e.rev = body, meta.Rev
+	return e
+}
+
+// WillReturnError sets the error to return.
+func (e *ExpectedGet) WillReturnError(err error) *ExpectedGet {
+	e.err = err
+	return e
+}
+
+// ExpectedPut represents an expectation for a call to Put.
+type ExpectedPut struct {
+	commonExpectation
+	db, docID string
+	doc       interface{}
+	rev       string
+}
+
+func (e *ExpectedPut) String() string {
+	return e.describe(fmt.Sprintf("DB(%q).Put(%q)", e.db, e.docID))
+}
+
+// WithOptions sets the options the call is expected to receive.
+func (e *ExpectedPut) WithOptions(options map[string]interface{}) *ExpectedPut {
+	e.options = options
+	return e
+}
+
+// WithDoc sets the document the call is expected to receive. Documents are
+// compared by their JSON representation, unless doc is an Argument. A string,
+// []byte or json.RawMessage is treated as raw JSON.
+func (e *ExpectedPut) WithDoc(doc interface{}) *ExpectedPut {
+	e.doc = doc
+	return e
+}
+
+// WillReturn sets the new revision to return.
+func (e *ExpectedPut) WillReturn(rev string) *ExpectedPut {
+	e.rev = rev
+	return e
+}
+
+// WillReturnError sets the error to return.
+func (e *ExpectedPut) WillReturnError(err error) *ExpectedPut {
+	e.err = err
+	return e
+}
+
+// ExpectedCreateDoc represents an expectation for a call to CreateDoc.
+type ExpectedCreateDoc struct {
+	commonExpectation
+	db         string
+	doc        interface{}
+	docID, rev string
+}
+
+func (e *ExpectedCreateDoc) String() string {
+	return e.describe(fmt.Sprintf("DB(%q).CreateDoc()", e.db))
+}
+
+// WithOptions sets the options the call is expected to receive.
+func (e *ExpectedCreateDoc) WithOptions(options map[string]interface{}) *ExpectedCreateDoc {
+	e.options = options
+	return e
+}
+
+// WithDoc sets the document the call is expected to receive. Documents are
+// compared by their JSON representation, unless doc is an Argument. A string,
+// []byte or json.RawMessage is treated as raw JSON.
+func (e *ExpectedCreateDoc) WithDoc(doc interface{}) *ExpectedCreateDoc {
+	e.doc = doc
+	return e
+}
+
+// WillReturn sets the document ID and revision to return.
+func (e *ExpectedCreateDoc) WillReturn(docID, rev string) *ExpectedCreateDoc {
+	e.docID, e.rev = docID, rev
+	return e
+}
+
+// WillReturnError sets the error to return.
+func (e *ExpectedCreateDoc) WillReturnError(err error) *ExpectedCreateDoc {
+	e.err = err
+	return e
+}
+
+// ExpectedDelete represents an expectation for a call to Delete.
+type ExpectedDelete struct {
+	commonExpectation
+	db, docID string
+	rev       *string
+	newRev    string
+}
+
+func (e *ExpectedDelete) String() string {
+	return e.describe(fmt.Sprintf("DB(%q).Delete(%q)", e.db, e.docID))
+}
+
+// WithOptions sets the options the call is expected to receive.
+func (e *ExpectedDelete) WithOptions(options map[string]interface{}) *ExpectedDelete {
+	e.options = options
+	return e
+}
+
+// WithRev sets the revision the call is expected to receive. If not set, any
+// revision is accepted.
+func (e *ExpectedDelete) WithRev(rev string) *ExpectedDelete {
+	e.rev = &rev
+	return e
+}
+
+// WillReturn sets the new revision to return.
+func (e *ExpectedDelete) WillReturn(newRev string) *ExpectedDelete {
+	e.newRev = newRev
+	return e
+}
+
+// WillReturnError sets the error to return.
+func (e *ExpectedDelete) WillReturnError(err error) *ExpectedDelete {
+	e.err = err
+	return e
+}
+
+// ExpectedQuery represents an expectation for a call to Query.
+type ExpectedQuery struct {
+	commonExpectation
+	db, ddoc, view string
+	rows           *Rows
+}
+
+func (e *ExpectedQuery) String() string {
+	return e.describe(fmt.Sprintf("DB(%q).Query(%q, %q)", e.db, e.ddoc, e.view))
+}
+
+// WithOptions sets the options the call is expected to receive.
+func (e *ExpectedQuery) WithOptions(options map[string]interface{}) *ExpectedQuery {
+	e.options = options
+	return e
+}
+
+// WillReturnRows sets the result set to return.
+func (e *ExpectedQuery) WillReturnRows(rows *Rows) *ExpectedQuery {
+	e.rows = rows
+	return e
+}
+
+// WillReturnError sets the error to return.
+func (e *ExpectedQuery) WillReturnError(err error) *ExpectedQuery {
+	e.err = err
+	return e
+}
+
+// ExpectedAllDocs represents an expectation for a call to AllDocs.
+type ExpectedAllDocs struct {
+	commonExpectation
+	db   string
+	rows *Rows
+}
+
+func (e *ExpectedAllDocs) String() string {
+	return e.describe(fmt.Sprintf("DB(%q).AllDocs()", e.db))
+}
+
+// WithOptions sets the options the call is expected to receive.
+func (e *ExpectedAllDocs) WithOptions(options map[string]interface{}) *ExpectedAllDocs {
+	e.options = options
+	return e
+}
+
+// WillReturnRows sets the result set to return.
+func (e *ExpectedAllDocs) WillReturnRows(rows *Rows) *ExpectedAllDocs {
+	e.rows = rows
+	return e
+}
+
+// WillReturnError sets the error to return.
+func (e *ExpectedAllDocs) WillReturnError(err error) *ExpectedAllDocs {
+	e.err = err
+	return e
+}
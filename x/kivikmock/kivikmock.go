@@ -0,0 +1,287 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package kivikmock provides a mock Kivik driver, for testing code which uses
+// Kivik without a running server. Rather than implementing driver interfaces
+// by hand, tests declare the calls they expect, and the results those calls
+// should produce:
+//
+//	client, mock, err := kivikmock.New()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	mock.ExpectGet("db", "docID").WillReturn(map[string]string{"_rev": "1-xxx"})
+//
+//	// ... exercise code which uses client ...
+//
+//	if err := mock.ExpectationsWereMet(); err != nil {
+//		t.Error(err)
+//	}
+//
+// By default, expectations must be met in the order in which they were
+// declared. See MatchExpectationsInOrder to relax this.
+package kivikmock // import "github.com/go-kivik/kivik/v4/x/kivikmock"
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+)
+
+// DriverName is the name under which the mock driver is registered.
+const DriverName = "kivikmock"
+
+func init() {
+	kivik.Register(DriverName, &mockDriver{})
+}
+
+var pool = struct {
+	sync.Mutex
+	counter int
+	clients map[string]*Client
+}{
+	clients: make(map[string]*Client),
+}
+
+type mockDriver struct{}
+
+var _ driver.Driver = &mockDriver{}
+
+// NewClient returns the mock client created by New for dsn.
+func (d *mockDriver) NewClient(dsn string) (driver.Client, error) {
+	pool.Lock()
+	defer pool.Unlock()
+	c, ok := pool.clients[dsn]
+	if !ok {
+		return nil, errors.Statusf(http.StatusBadRequest, "kivikmock: no mock client for DSN %q", dsn)
+	}
+	return &client{mock: c}, nil
+}
+
+// Client is a mock Kivik server, on which expectations are set.
+type Client struct {
+	mu       sync.Mutex
+	ordered  bool
+	expected []expectation
+}
+
+// New returns a new kivik client backed by a new mock, and the mock itself,
+// on which expectations are set.
+func New() (*kivik.Client, *Client, error) {
+	pool.Lock()
+	pool.counter++
+	dsn := fmt.Sprintf("kivikmock_%d", pool.counter)
+	mock := &Client{ordered: true}
+	pool.clients[dsn] = mock
+	pool.Unlock()
+	c, err := kivik.New(DriverName, dsn)
+	return c, mock, err
+}
+
+// MatchExpectationsInOrder sets whether calls must match expectations in the
+// order in which they were declared. The default is true. When false, each
+// call is matched against the first unmet expectation it satisfies.
+func (c *Client) MatchExpectationsInOrder(ordered bool) {
+	c.mu.Lock()
+	c.ordered = ordered
+	c.mu.Unlock()
+}
+
+// ExpectationsWereMet returns an error if any declared expectation has not
+// been met.
+func (c *Client) ExpectationsWereMet() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.expected {
+		if !e.met() {
+			return errors.Errorf("kivikmock: there is a remaining expectation which was not matched: %s", e)
+		}
+	}
+	return nil
+}
+
+func (c *Client) expect(e expectation) {
+	c.mu.Lock()
+	c.expected = append(c.expected, e)
+	c.mu.Unlock()
+}
+
+// match finds and triggers the expectation satisfying a call, as reported by
+// fn. call describes the call, for error messages.
+func (c *Client) match(call string, fn func(expectation) bool) (expectation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.expected {
+		if e.met() {
+			continue
+		}
+		if fn(e) {
+			e.trigger()
+			return e, nil
+		}
+		if c.ordered {
+			return nil, errors.Errorf("kivikmock: call to %s was not expected, next expectation is: %s", call, e)
+		}
+	}
+	return nil, errors.Errorf("kivikmock: call to %s was not expected", call)
+}
+
+// ExpectAllDBs queues an expectation that AllDBs will be called.
+func (c *Client) ExpectAllDBs() *ExpectedAllDBs {
+	e := &ExpectedAllDBs{}
+	c.expect(e)
+	return e
+}
+
+// ExpectDBExists queues an expectation that DBExists will be called for the
+// named database.
+func (c *Client) ExpectDBExists(dbName string) *ExpectedDBExists {
+	e := &ExpectedDBExists{dbName: dbName}
+	c.expect(e)
+	return e
+}
+
+// ExpectCreateDB queues an expectation that CreateDB will be called for the
+// named database.
+func (c *Client) ExpectCreateDB(dbName string) *ExpectedCreateDB {
+	e := &ExpectedCreateDB{dbName: dbName}
+	c.expect(e)
+	return e
+}
+
+// ExpectDestroyDB queues an expectation that DestroyDB will be called for the
+// named database.
+func (c *Client) ExpectDestroyDB(dbName string) *ExpectedDestroyDB {
+	e := &ExpectedDestroyDB{dbName: dbName}
+	c.expect(e)
+	return e
+}
+
+// ExpectGet queues an expectation that Get will be called for docID in the
+// named database.
+func (c *Client) ExpectGet(dbName, docID string) *ExpectedGet {
+	e := &ExpectedGet{db: dbName, docID: docID}
+	c.expect(e)
+	return e
+}
+
+// ExpectPut queues an expectation that Put will be called for docID in the
+// named database.
+func (c *Client) ExpectPut(dbName, docID string) *ExpectedPut {
+	e := &ExpectedPut{db: dbName, docID: docID}
+	c.expect(e)
+	return e
+}
+
+// ExpectCreateDoc queues an expectation that CreateDoc will be called on the
+// named database.
+func (c *Client) ExpectCreateDoc(dbName string) *ExpectedCreateDoc {
+	e := &ExpectedCreateDoc{db: dbName}
+	c.expect(e)
+	return e
+}
+
+// ExpectDelete queues an expectation that Delete will be called for docID in
+// the named database.
+func (c *Client) ExpectDelete(dbName, docID string) *ExpectedDelete {
+	e := &ExpectedDelete{db: dbName, docID: docID}
+	c.expect(e)
+	return e
+}
+
+// ExpectQuery queues an expectation that the view ddoc/view will be queried
+// in the named database. ddoc may be given with or without the "_design/"
+// prefix.
+func (c *Client) ExpectQuery(dbName, ddoc, view string) *ExpectedQuery {
+	e := &ExpectedQuery{db: dbName, ddoc: strings.TrimPrefix(ddoc, "_design/"), view: strings.TrimPrefix(view, "_view/")}
+	c.expect(e)
+	return e
+}
+
+// ExpectAllDocs queues an expectation that AllDocs will be called on the
+// named database.
+func (c *Client) ExpectAllDocs(dbName string) *ExpectedAllDocs {
+	e := &ExpectedAllDocs{db: dbName}
+	c.expect(e)
+	return e
+}
+
+type client struct {
+	mock *Client
+}
+
+var _ driver.Client = &client{}
+
+func (c *client) Version(_ context.Context) (*driver.Version, error) {
+	return &driver.Version{
+		Version: kivik.KivikVersion,
+		Vendor:  "Kivik Mock",
+	}, nil
+}
+
+func (c *client) AllDBs(_ context.Context, options map[string]interface{}) ([]string, error) {
+	e, err := c.mock.match("AllDBs()", func(e expectation) bool {
+		x, ok := e.(*ExpectedAllDBs)
+		return ok && matchOptions(x.options, options)
+	})
+	if err != nil {
+		return nil, err
+	}
+	x := e.(*ExpectedAllDBs)
+	return x.ret, x.err
+}
+
+func (c *client) DBExists(_ context.Context, dbName string, options map[string]interface{}) (bool, error) {
+	e, err := c.mock.match(fmt.Sprintf("DBExists(%q)", dbName), func(e expectation) bool {
+		x, ok := e.(*ExpectedDBExists)
+		return ok && x.dbName == dbName && matchOptions(x.options, options)
+	})
+	if err != nil {
+		return false, err
+	}
+	x := e.(*ExpectedDBExists)
+	return x.ret, x.err
+}
+
+func (c *client) CreateDB(_ context.Context, dbName string, options map[string]interface{}) error {
+	e, err := c.mock.match(fmt.Sprintf("CreateDB(%q)", dbName), func(e expectation) bool {
+		x, ok := e.(*ExpectedCreateDB)
+		return ok && x.dbName == dbName && matchOptions(x.options, options)
+	})
+	if err != nil {
+		return err
+	}
+	return e.(*ExpectedCreateDB).err
+}
+
+func (c *client) DestroyDB(_ context.Context, dbName string, options map[string]interface{}) error {
+	e, err := c.mock.match(fmt.Sprintf("DestroyDB(%q)", dbName), func(e expectation) bool {
+		x, ok := e.(*ExpectedDestroyDB)
+		return ok && x.dbName == dbName && matchOptions(x.options, options)
+	})
+	if err != nil {
+		return err
+	}
+	return e.(*ExpectedDestroyDB).err
+}
+
+// DB returns a handle to the named database. Opening a handle is not itself
+// an expectation; calls made through it are.
+func (c *client) DB(_ context.Context, dbName string, _ map[string]interface{}) (driver.DB, error) {
+	return &db{mock: c.mock, name: dbName}, nil
+}
@@ -0,0 +1,181 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivikmock
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestNewClientUnknownDSN(t *testing.T) {
+	_, err := kivik.New(DriverName, "unknown")
+	testy.StatusError(t, `kivikmock: no mock client for DSN "unknown"`, http.StatusBadRequest, err)
+}
+
+func TestClient(t *testing.T) {
+	type tt struct {
+		setup  func(*Client)
+		call   func(*kivik.Client) (interface{}, error)
+		result interface{}
+		status int
+		err    string
+		metErr string
+	}
+	tests := testy.NewTable()
+	tests.Add("AllDBs", tt{
+		setup: func(m *Client) {
+			m.ExpectAllDBs().WillReturn([]string{"a", "b"})
+		},
+		call: func(c *kivik.Client) (interface{}, error) {
+			return c.AllDBs(context.Background())
+		},
+		result: []string{"a", "b"},
+	})
+	tests.Add("AllDBs error", tt{
+		setup: func(m *Client) {
+			m.ExpectAllDBs().WillReturnError(&kivik.Error{HTTPStatus: http.StatusUnauthorized, Message: "nope"})
+		},
+		call: func(c *kivik.Client) (interface{}, error) {
+			return c.AllDBs(context.Background())
+		},
+		status: http.StatusUnauthorized,
+		err:    "nope",
+	})
+	tests.Add("DBExists", tt{
+		setup: func(m *Client) {
+			m.ExpectDBExists("foo").WillReturn(true)
+		},
+		call: func(c *kivik.Client) (interface{}, error) {
+			return c.DBExists(context.Background(), "foo")
+		},
+		result: true,
+	})
+	tests.Add("DBExists wrong name", tt{
+		setup: func(m *Client) {
+			m.ExpectDBExists("foo")
+		},
+		call: func(c *kivik.Client) (interface{}, error) {
+			return c.DBExists(context.Background(), "bar")
+		},
+		result: false,
+		status: http.StatusInternalServerError,
+		err:    `kivikmock: call to DBExists("bar") was not expected, next expectation is: DBExists("foo")`,
+		metErr: `kivikmock: there is a remaining expectation which was not matched: DBExists("foo")`,
+	})
+	tests.Add("CreateDB", tt{
+		setup: func(m *Client) {
+			m.ExpectCreateDB("foo")
+		},
+		call: func(c *kivik.Client) (interface{}, error) {
+			return nil, c.CreateDB(context.Background(), "foo")
+		},
+	})
+	tests.Add("DestroyDB with options", tt{
+		setup: func(m *Client) {
+			m.ExpectDestroyDB("foo").WithOptions(map[string]interface{}{"x": 1})
+		},
+		call: func(c *kivik.Client) (interface{}, error) {
+			return nil, c.DestroyDB(context.Background(), "foo", kivik.Options{"x": 1})
+		},
+	})
+	tests.Add("DestroyDB wrong options", tt{
+		setup: func(m *Client) {
+			m.ExpectDestroyDB("foo").WithOptions(map[string]interface{}{"x": 1})
+		},
+		call: func(c *kivik.Client) (interface{}, error) {
+			return nil, c.DestroyDB(context.Background(), "foo", kivik.Options{"x": 2})
+		},
+		status: http.StatusInternalServerError,
+		err:    `kivikmock: call to DestroyDB("foo") was not expected, next expectation is: DestroyDB("foo") with options map[x:1]`,
+		metErr: `kivikmock: there is a remaining expectation which was not matched: DestroyDB("foo") with options map[x:1]`,
+	})
+	tests.Add("nothing expected", tt{
+		setup: func(*Client) {},
+		call: func(c *kivik.Client) (interface{}, error) {
+			return nil, c.CreateDB(context.Background(), "foo")
+		},
+		status: http.StatusInternalServerError,
+		err:    `kivikmock: call to CreateDB("foo") was not expected`,
+	})
+	tests.Add("unmet expectation", tt{
+		setup: func(m *Client) {
+			m.ExpectCreateDB("foo")
+			m.ExpectCreateDB("bar")
+		},
+		call: func(c *kivik.Client) (interface{}, error) {
+			return nil, c.CreateDB(context.Background(), "foo")
+		},
+		metErr: `kivikmock: there is a remaining expectation which was not matched: CreateDB("bar")`,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		client, mock, err := New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tt.setup(mock)
+		result, err := tt.call(client)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.result, result); d != nil {
+			t.Error(d)
+		}
+		testy.Error(t, tt.metErr, mock.ExpectationsWereMet())
+	})
+}
+
+func TestMatchExpectationsInOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		ordered bool
+		status  int
+		err     string
+	}{
+		{
+			name:    "ordered",
+			ordered: true,
+			status:  http.StatusInternalServerError,
+			err:     `kivikmock: call to CreateDB("b") was not expected, next expectation is: CreateDB("a")`,
+		},
+		{
+			name:    "unordered",
+			ordered: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, mock, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			mock.MatchExpectationsInOrder(test.ordered)
+			mock.ExpectCreateDB("a")
+			mock.ExpectCreateDB("b")
+			err = client.CreateDB(context.Background(), "b")
+			testy.StatusError(t, test.err, test.status, err)
+			if err != nil {
+				return
+			}
+			if err := client.CreateDB(context.Background(), "a"); err != nil {
+				t.Fatal(err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivikmock
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Argument is implemented by values which match an option or document by
+// some rule other than equality. An Argument may be used as the value of any
+// key passed to WithOptions, or as the document passed to WithDoc.
+type Argument interface {
+	Match(v interface{}) bool
+}
+
+type anyValue struct{}
+
+func (anyValue) Match(interface{}) bool { return true }
+
+// AnyValue matches any value. As an option value, it requires only that the
+// key be present.
+var AnyValue Argument = anyValue{}
+
+// ArgumentFunc adapts an ordinary function to the Argument interface.
+type ArgumentFunc func(v interface{}) bool
+
+// Match calls f(v).
+func (f ArgumentFunc) Match(v interface{}) bool { return f(v) }
+
+// matchOptions reports whether got satisfies want. A nil want matches any
+// options; otherwise both must have the same keys, and each value must be
+// equal, or satisfy the Argument given in want.
+func matchOptions(want, got map[string]interface{}) bool {
+	if want == nil {
+		return true
+	}
+	if len(want) != len(got) {
+		return false
+	}
+	for k, w := range want {
+		g, ok := got[k]
+		if !ok {
+			return false
+		}
+		if arg, ok := w.(Argument); ok {
+			if !arg.Match(g) {
+				return false
+			}
+			continue
+		}
+		if !reflect.DeepEqual(w, g) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchDoc reports whether got satisfies want. A nil want matches any
+// document; otherwise both are compared by their JSON representation. A
+// string, []byte or json.RawMessage is treated as raw JSON.
+func matchDoc(want, got interface{}) bool {
+	if want == nil {
+		return true
+	}
+	if arg, ok := want.(Argument); ok {
+		return arg.Match(got)
+	}
+	w, err := normalize(want)
+	if err != nil {
+		return false
+	}
+	g, err := normalize(got)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(w, g)
+}
+
+func normalize(i interface{}) (interface{}, error) {
+	if s, ok := i.(string); ok {
+		i = []byte(s)
+	}
+	raw, err := toJSON(i)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	err = json.Unmarshal(raw, &v)
+	return v, err
+}
+
+func toJSON(i interface{}) ([]byte, error) {
+	switch t := i.(type) {
+	case []byte:
+		return t, nil
+	case json.RawMessage:
+		return t, nil
+	}
+	return json.Marshal(i)
+}
@@ -0,0 +1,118 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivikmock
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMatchOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		want, got map[string]interface{}
+		match     bool
+	}{
+		{
+			name:  "nil matches anything",
+			got:   map[string]interface{}{"a": 1},
+			match: true,
+		},
+		{
+			name:  "empty matches empty",
+			want:  map[string]interface{}{},
+			got:   map[string]interface{}{},
+			match: true,
+		},
+		{
+			name: "extra key",
+			want: map[string]interface{}{},
+			got:  map[string]interface{}{"a": 1},
+		},
+		{
+			name: "missing key",
+			want: map[string]interface{}{"a": 1, "b": 2},
+			got:  map[string]interface{}{"a": 1, "c": 2},
+		},
+		{
+			name: "different value",
+			want: map[string]interface{}{"a": 1},
+			got:  map[string]interface{}{"a": 2},
+		},
+		{
+			name:  "any value",
+			want:  map[string]interface{}{"a": AnyValue},
+			got:   map[string]interface{}{"a": "x"},
+			match: true,
+		},
+		{
+			name: "argument func",
+			want: map[string]interface{}{"a": ArgumentFunc(func(v interface{}) bool {
+				i, ok := v.(int)
+				return ok && i > 5
+			})},
+			got:   map[string]interface{}{"a": 10},
+			match: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if match := matchOptions(test.want, test.got); match != test.match {
+				t.Errorf("Unexpected result: %t", match)
+			}
+		})
+	}
+}
+
+func TestMatchDoc(t *testing.T) {
+	tests := []struct {
+		name      string
+		want, got interface{}
+		match     bool
+	}{
+		{
+			name:  "nil matches anything",
+			got:   map[string]int{"a": 1},
+			match: true,
+		},
+		{
+			name:  "struct vs map",
+			want:  struct{ A int }{A: 1},
+			got:   map[string]int{"A": 1},
+			match: true,
+		},
+		{
+			name:  "raw JSON",
+			want:  json.RawMessage(`{"a": 1}`),
+			got:   map[string]int{"a": 1},
+			match: true,
+		},
+		{
+			name: "different",
+			want: map[string]int{"a": 1},
+			got:  map[string]int{"a": 2},
+		},
+		{
+			name: "invalid JSON",
+			want: []byte("xxx"),
+			got:  map[string]int{"a": 2},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if match := matchDoc(test.want, test.got); match != test.match {
+				t.Errorf("Unexpected result: %t", match)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package kivikmock
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// Row is a single row of a mocked result set. Key, Value and Doc are
+// marshaled to JSON, unless they are already a []byte or json.RawMessage.
+// A nil Doc is omitted.
+type Row struct {
+	ID    string
+	Key   interface{}
+	Value interface{}
+	Doc   interface{}
+}
+
+// Rows is a mocked result set, as returned by Query or AllDocs.
+type Rows struct {
+	rows      []Row
+	offset    int64
+	totalRows int64
+	updateSeq string
+	rowErrs   map[int]error
+	closeErr  error
+}
+
+// NewRows returns a new, empty result set.
+func NewRows() *Rows {
+	return &Rows{}
+}
+
+// AddRow appends row to the result set.
+func (r *Rows) AddRow(row Row) *Rows {
+	r.rows = append(r.rows, row)
+	return r
+}
+
+// Offset sets the offset reported by the result set.
+func (r *Rows) Offset(offset int64) *Rows {
+	r.offset = offset
+	return r
+}
+
+// TotalRows sets the total number of rows reported by the result set.
+func (r *Rows) TotalRows(total int64) *Rows {
+	r.totalRows = total
+	return r
+}
+
+// UpdateSeq sets the update sequence reported by the result set.
+func (r *Rows) UpdateSeq(seq string) *Rows {
+	r.updateSeq = seq
+	return r
+}
+
+// RowError causes iteration to fail with err when it reaches row i, counting
+// from 0.
+func (r *Rows) RowError(i int, err error) *Rows {
+	if r.rowErrs == nil {
+		r.rowErrs = make(map[int]error)
+	}
+	r.rowErrs[i] = err
+	return r
+}
+
+// CloseError sets the error returned when the result set is closed.
+func (r *Rows) CloseError(err error) *Rows {
+	r.closeErr = err
+	return r
+}
+
+// iterator returns a new driver.Rows over r. A nil r yields no rows.
+func (r *Rows) iterator() *rowsIter {
+	if r == nil {
+		r = NewRows()
+	}
+	return &rowsIter{Rows: r}
+}
+
+type rowsIter struct {
+	*Rows
+	i int
+}
+
+var _ driver.Rows = &rowsIter{}
+
+func (r *rowsIter) Next(row *driver.Row) error {
+	if err, ok := r.rowErrs[r.i]; ok {
+		return err
+	}
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+	src := r.rows[r.i]
+	r.i++
+	key, err := toJSON(src.Key)
+	if err != nil {
+		return err
+	}
+	value, err := toJSON(src.Value)
+	if err != nil {
+		return err
+	}
+	var doc json.RawMessage
+	if src.Doc != nil {
+		if doc, err = toJSON(src.Doc); err != nil {
+			return err
+		}
+	}
+	row.ID = src.ID
+	row.Key = key
+	row.Value = value
+	row.Doc = doc
+	return nil
+}
+
+func (r *rowsIter) Close() error      { return r.closeErr }
+func (r *rowsIter) UpdateSeq() string { return r.updateSeq }
+func (r *rowsIter) Offset() int64     { return r.offset }
+func (r *rowsIter) TotalRows() int64  { return r.totalRows }
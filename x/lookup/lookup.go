@@ -0,0 +1,315 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package lookup maintains secondary lookup documents alongside the documents
+// of a database, enforcing uniqueness of computed values, such as a user's
+// email address.
+//
+// For each Index, and each document with a value for it, a lookup document
+// is stored with an ID derived from the index name and the value, recording
+// the ID of the owning document. As CouchDB allows only one document per ID,
+// creating the lookup document fails with a conflict if another document
+// already holds the value. Lookup documents also allow documents to be found
+// by value with a single Get, without a view.
+//
+// Writes are not atomic. The lookup document is created before the document
+// itself is written, and removed again if that write fails, so a crash may
+// leave a stale lookup behind. Stale lookups are detected and reclaimed:
+// before reporting a conflict, the owning document is fetched, and if it does
+// not hold the value, and the lookup was claimed longer ago than the grace
+// period, the lookup is taken over. Until then, the lookup may belong to a
+// write still in progress, so the value is treated as taken. The grace
+// period must be longer than a write can take, allowing for clock skew
+// between clients.
+package lookup // import "github.com/go-kivik/kivik/v4/x/lookup"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// Index defines a unique secondary index over a database's documents.
+type Index struct {
+	// Name identifies the index. It forms part of the lookup document IDs,
+	// so must not change once documents have been written.
+	Name string
+	// Value computes the indexed value of doc, returning false if doc has no
+	// value for this index.
+	Value func(doc map[string]interface{}) (string, bool)
+}
+
+// DefaultGracePeriod is how long a lookup whose owner does not hold the
+// value is assumed to belong to a write in progress, unless changed with
+// WithGracePeriod.
+const DefaultGracePeriod = time.Minute
+
+// DB wraps a database, maintaining lookup documents for a set of indexes on
+// every write made through it. Writes made directly to the database bypass
+// the indexes.
+type DB struct {
+	db      *kivik.DB
+	indexes []Index
+	grace   time.Duration
+}
+
+// New returns a DB which maintains indexes over db.
+func New(db *kivik.DB, indexes ...Index) *DB {
+	return &DB{db: db, indexes: indexes, grace: DefaultGracePeriod}
+}
+
+// WithGracePeriod returns a copy of d which reclaims lookups not held by
+// their owner once they are older than grace, rather than
+// DefaultGracePeriod.
+func (d *DB) WithGracePeriod(grace time.Duration) *DB {
+	c := *d
+	c.grace = grace
+	return &c
+}
+
+// lookupDoc is the content of a lookup document.
+type lookupDoc struct {
+	Rev   string `json:"_rev,omitempty"`
+	Owner string `json:"owner"`
+	// Claimed is when the lookup was written, before the owner was.
+	Claimed time.Time `json:"claimed"`
+}
+
+func lookupID(index, value string) string {
+	return "lookup:" + index + ":" + value
+}
+
+// ConstraintError is returned when a write would give a document an indexed
+// value already held by another document.
+type ConstraintError struct {
+	Index string
+	Value string
+	// Owner is the ID of the document which holds the value.
+	Owner string
+}
+
+var _ error = &ConstraintError{}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("lookup: %s %q is already used by %s", e.Index, e.Value, e.Owner)
+}
+
+// StatusCode returns 409 (conflict).
+func (e *ConstraintError) StatusCode() int {
+	return http.StatusConflict
+}
+
+// Lookup returns the ID of the document holding value in the named index, or
+// a status 404 error if there is none.
+func (d *DB) Lookup(ctx context.Context, index, value string) (string, error) {
+	idx, err := d.index(index)
+	if err != nil {
+		return "", err
+	}
+	l, held, err := d.owner(ctx, idx, value)
+	if err != nil {
+		return "", err
+	}
+	if !held {
+		return "", &kivik.Error{HTTPStatus: http.StatusNotFound, Message: fmt.Sprintf("lookup: no document with %s %q", index, value)}
+	}
+	return l.Owner, nil
+}
+
+func (d *DB) index(name string) (Index, error) {
+	for _, idx := range d.indexes {
+		if idx.Name == name {
+			return idx, nil
+		}
+	}
+	return Index{}, &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("lookup: unknown index %q", name)}
+}
+
+// owner returns the lookup document for value, and whether its owner holds
+// the value. A missing lookup document is not an error, and is returned with
+// no owner.
+func (d *DB) owner(ctx context.Context, idx Index, value string) (l lookupDoc, held bool, err error) {
+	err = d.db.Get(ctx, lookupID(idx.Name, value)).ScanDoc(&l)
+	if kivik.IsNotFound(err) {
+		return lookupDoc{}, false, nil
+	}
+	if err != nil {
+		return lookupDoc{}, false, err
+	}
+	doc, err := d.get(ctx, l.Owner)
+	if err != nil {
+		return lookupDoc{}, false, err
+	}
+	if doc != nil {
+		if v, ok := idx.Value(doc); ok && v == value {
+			return l, true, nil
+		}
+	}
+	return l, false, nil
+}
+
+// get returns docID, or nil if it does not exist.
+func (d *DB) get(ctx context.Context, docID string) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	err := d.db.Get(ctx, docID).ScanDoc(&doc)
+	if kivik.IsNotFound(err) {
+		return nil, nil
+	}
+	return doc, err
+}
+
+// values returns the indexed values of doc, keyed by index name.
+func (d *DB) values(doc map[string]interface{}) map[string]string {
+	values := make(map[string]string, len(d.indexes))
+	if doc == nil {
+		return values
+	}
+	for _, idx := range d.indexes {
+		if v, ok := idx.Value(doc); ok {
+			values[idx.Name] = v
+		}
+	}
+	return values
+}
+
+// claim is a lookup document written for the pending update.
+type claim struct {
+	id, rev string
+}
+
+// Put writes doc as docID, as DB.Put, first claiming any new indexed values.
+// If a value is held by another document, a *ConstraintError is returned,
+// and nothing is written. Lookups for values the document no longer holds
+// are removed afterwards.
+func (d *DB) Put(ctx context.Context, docID string, doc interface{}) (string, error) {
+	m, err := toMap(doc)
+	if err != nil {
+		return "", err
+	}
+	old, err := d.get(ctx, docID)
+	if err != nil {
+		return "", err
+	}
+	oldValues, newValues := d.values(old), d.values(m)
+	var claims []claim
+	for _, idx := range d.indexes {
+		v, ok := newValues[idx.Name]
+		if !ok || oldValues[idx.Name] == v {
+			continue
+		}
+		c, err := d.claim(ctx, idx, v, docID)
+		if err != nil {
+			d.release(ctx, claims)
+			return "", err
+		}
+		claims = append(claims, c)
+	}
+	rev, err := d.db.Put(ctx, docID, m)
+	if err != nil {
+		d.release(ctx, claims)
+		return "", err
+	}
+	d.releaseValues(ctx, docID, oldValues, newValues)
+	return rev, nil
+}
+
+// Delete deletes docID, as DB.Delete, then removes its lookups.
+func (d *DB) Delete(ctx context.Context, docID, rev string) (string, error) {
+	old, err := d.get(ctx, docID)
+	if err != nil {
+		return "", err
+	}
+	newRev, err := d.db.Delete(ctx, docID, rev)
+	if err != nil {
+		return "", err
+	}
+	d.releaseValues(ctx, docID, d.values(old), nil)
+	return newRev, nil
+}
+
+// claim creates the lookup document for value, owned by docID, taking over a
+// stale lookup if necessary.
+func (d *DB) claim(ctx context.Context, idx Index, value, docID string) (claim, error) {
+	id := lookupID(idx.Name, value)
+	rev, err := d.db.Put(ctx, id, lookupDoc{Owner: docID, Claimed: time.Now().UTC()})
+	if err == nil {
+		return claim{id: id, rev: rev}, nil
+	}
+	if !kivik.IsConflict(err) {
+		return claim{}, err
+	}
+	l, held, err := d.owner(ctx, idx, value)
+	if err != nil {
+		return claim{}, err
+	}
+	if l.Owner != docID && (held || time.Since(l.Claimed) < d.grace) {
+		return claim{}, &ConstraintError{Index: idx.Name, Value: value, Owner: l.Owner}
+	}
+	rev, err = d.db.Put(ctx, id, lookupDoc{Rev: l.Rev, Owner: docID, Claimed: time.Now().UTC()})
+	if kivik.IsConflict(err) {
+		// Another writer reclaimed it first.
+		l, _, _ = d.owner(ctx, idx, value)
+		return claim{}, &ConstraintError{Index: idx.Name, Value: value, Owner: l.Owner}
+	}
+	if err != nil {
+		return claim{}, err
+	}
+	return claim{id: id, rev: rev}, nil
+}
+
+// release deletes the lookup documents in claims. Errors are ignored, as any
+// lookup left behind is stale, and will be reclaimed.
+func (d *DB) release(ctx context.Context, claims []claim) {
+	for _, c := range claims {
+		_, _ = d.db.Delete(ctx, c.id, c.rev)
+	}
+}
+
+// releaseValues deletes the lookups owned by docID for the values in old
+// which are not also in current.
+func (d *DB) releaseValues(ctx context.Context, docID string, old, current map[string]string) {
+	for name, v := range old {
+		if current[name] == v {
+			continue
+		}
+		id := lookupID(name, v)
+		var l lookupDoc
+		if err := d.db.Get(ctx, id).ScanDoc(&l); err != nil || l.Owner != docID {
+			continue
+		}
+		_, _ = d.db.Delete(ctx, id, l.Rev)
+	}
+}
+
+func toMap(doc interface{}) (map[string]interface{}, error) {
+	var raw []byte
+	switch t := doc.(type) {
+	case []byte:
+		raw = t
+	case json.RawMessage:
+		raw = t
+	default:
+		var err error
+		if raw, err = json.Marshal(doc); err != nil {
+			return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Err: err}
+		}
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	return m, nil
+}
@@ -0,0 +1,241 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package lookup
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/memtest"
+)
+
+var email = Index{
+	Name: "email",
+	Value: func(doc map[string]interface{}) (string, bool) {
+		v, ok := doc["email"].(string)
+		return v, ok
+	},
+}
+
+func mustPut(t *testing.T, d *DB, docID string, doc interface{}) string {
+	t.Helper()
+	rev, err := d.Put(context.Background(), docID, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rev
+}
+
+func TestPut(t *testing.T) {
+	type tt struct {
+		db     *DB
+		docID  string
+		doc    interface{}
+		status int
+		err    string
+		// lookups maps values to expected owners; "" means no lookup.
+		lookups map[string]string
+	}
+	tests := testy.NewTable()
+	tests.Add("new value", func(t *testing.T) interface{} {
+		return tt{
+			db:      New(memtest.NewDB(t), email),
+			docID:   "bob",
+			doc:     map[string]string{"email": "bob@example.com"},
+			lookups: map[string]string{"bob@example.com": "bob"},
+		}
+	})
+	tests.Add("no value", func(t *testing.T) interface{} {
+		return tt{
+			db:    New(memtest.NewDB(t), email),
+			docID: "bob",
+			doc:   map[string]string{"name": "Bob"},
+		}
+	})
+	tests.Add("taken", func(t *testing.T) interface{} {
+		d := New(memtest.NewDB(t), email)
+		mustPut(t, d, "alice", map[string]string{"email": "bob@example.com"})
+		return tt{
+			db:      d,
+			docID:   "bob",
+			doc:     map[string]string{"email": "bob@example.com"},
+			status:  http.StatusConflict,
+			err:     `lookup: email "bob@example.com" is already used by alice`,
+			lookups: map[string]string{"bob@example.com": "alice"},
+		}
+	})
+	tests.Add("changed value", func(t *testing.T) interface{} {
+		d := New(memtest.NewDB(t), email)
+		rev := mustPut(t, d, "bob", map[string]string{"email": "bob@example.com"})
+		return tt{
+			db:    d,
+			docID: "bob",
+			doc:   map[string]string{"_rev": rev, "email": "robert@example.com"},
+			lookups: map[string]string{
+				"bob@example.com":    "",
+				"robert@example.com": "bob",
+			},
+		}
+	})
+	tests.Add("unchanged value", func(t *testing.T) interface{} {
+		d := New(memtest.NewDB(t), email)
+		rev := mustPut(t, d, "bob", map[string]string{"email": "bob@example.com"})
+		return tt{
+			db:      d,
+			docID:   "bob",
+			doc:     map[string]string{"_rev": rev, "email": "bob@example.com", "name": "Bob"},
+			lookups: map[string]string{"bob@example.com": "bob"},
+		}
+	})
+	tests.Add("stale lookup", func(t *testing.T) interface{} {
+		db := memtest.NewDB(t)
+		if _, err := db.Put(context.Background(), lookupID("email", "bob@example.com"), map[string]string{"owner": "alice"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Put(context.Background(), "alice", map[string]string{"email": "alice@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		return tt{
+			db:      New(db, email),
+			docID:   "bob",
+			doc:     map[string]string{"email": "bob@example.com"},
+			lookups: map[string]string{"bob@example.com": "bob"},
+		}
+	})
+	tests.Add("claim in progress", func(t *testing.T) interface{} {
+		db := memtest.NewDB(t)
+		// alice's lookup has been written, but alice not yet.
+		if _, err := db.Put(context.Background(), lookupID("email", "bob@example.com"), lookupDoc{Owner: "alice", Claimed: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+		return tt{
+			db:     New(db, email),
+			docID:  "bob",
+			doc:    map[string]string{"email": "bob@example.com"},
+			status: http.StatusConflict,
+			err:    `lookup: email "bob@example.com" is already used by alice`,
+		}
+	})
+	tests.Add("abandoned claim", func(t *testing.T) interface{} {
+		db := memtest.NewDB(t)
+		if _, err := db.Put(context.Background(), lookupID("email", "bob@example.com"), lookupDoc{Owner: "alice", Claimed: time.Now().Add(-time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+		return tt{
+			db:      New(db, email).WithGracePeriod(time.Minute),
+			docID:   "bob",
+			doc:     map[string]string{"email": "bob@example.com"},
+			lookups: map[string]string{"bob@example.com": "bob"},
+		}
+	})
+	tests.Add("write fails", func(t *testing.T) interface{} {
+		d := New(memtest.NewDB(t), email)
+		mustPut(t, d, "bob", map[string]string{"email": "bob@example.com"})
+		return tt{
+			db:      d,
+			docID:   "bob",
+			doc:     map[string]string{"_rev": "1-xxx", "email": "robert@example.com"},
+			status:  http.StatusConflict,
+			err:     "document update conflict",
+			lookups: map[string]string{"bob@example.com": "bob", "robert@example.com": ""},
+		}
+	})
+	tests.Add("invalid doc", tt{
+		db:     New(nil, email),
+		docID:  "bob",
+		doc:    []byte("invalid"),
+		status: http.StatusBadRequest,
+		err:    "invalid character 'i' looking for beginning of value",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		_, err := tt.db.Put(context.Background(), tt.docID, tt.doc)
+		testy.StatusError(t, tt.err, tt.status, err)
+		for value, want := range tt.lookups {
+			got, err := tt.db.Lookup(context.Background(), "email", value)
+			if want == "" {
+				if !kivik.IsNotFound(err) {
+					t.Errorf("Expected no lookup for %s, got %q, %v", value, got, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("Expected %s owned by %s, got %s", value, want, got)
+			}
+		}
+	})
+}
+
+func TestPutConcurrent(t *testing.T) {
+	d := New(memtest.NewDB(t), email)
+	const writers = 10
+	var wg sync.WaitGroup
+	errs := make([]error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = d.Put(context.Background(), fmt.Sprintf("user%d", i), map[string]string{"email": "bob@example.com"})
+		}(i)
+	}
+	wg.Wait()
+	var winner string
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			if winner != "" {
+				t.Fatalf("Both %s and user%d were written", winner, i)
+			}
+			winner = fmt.Sprintf("user%d", i)
+		case kivik.StatusCode(err) != http.StatusConflict:
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	if winner == "" {
+		t.Fatal("No writer succeeded")
+	}
+	owner, err := d.Lookup(context.Background(), "email", "bob@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner != winner {
+		t.Errorf("Expected lookup owned by %s, got %s", winner, owner)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	d := New(memtest.NewDB(t), email)
+	rev := mustPut(t, d, "bob", map[string]string{"email": "bob@example.com"})
+	if _, err := d.Delete(context.Background(), "bob", rev); err != nil {
+		t.Fatal(err)
+	}
+	_, err := d.Lookup(context.Background(), "email", "bob@example.com")
+	testy.StatusError(t, `lookup: no document with email "bob@example.com"`, http.StatusNotFound, err)
+	mustPut(t, d, "alice", map[string]string{"email": "bob@example.com"})
+}
+
+func TestLookupUnknownIndex(t *testing.T) {
+	d := New(memtest.NewDB(t), email)
+	_, err := d.Lookup(context.Background(), "phone", "555-1234")
+	testy.StatusError(t, `lookup: unknown index "phone"`, http.StatusBadRequest, err)
+}
@@ -0,0 +1,427 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package mango evaluates CouchDB Mango selectors against JSON documents. It is
+// intended for use by drivers which do not have a server to evaluate /_find
+// queries for them.
+//
+// See https://docs.couchdb.org/en/stable/api/database/find.html#find-selectors
+package mango
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/internal/collate"
+)
+
+// condition is a parsed selector, which may be evaluated against a JSON value.
+type condition func(value interface{}) bool
+
+// Selector is a parsed Mango selector.
+type Selector struct {
+	cond condition
+}
+
+// New parses the selector, which may be a map[string]interface{}, a JSON
+// string, []byte or json.RawMessage, or any other value which marshals to a
+// JSON object. A status 400 error is returned if the selector is invalid.
+func New(selector interface{}) (*Selector, error) {
+	var v interface{}
+	switch t := selector.(type) {
+	case string:
+		return New([]byte(t))
+	case json.RawMessage:
+		return New([]byte(t))
+	case []byte:
+		dec := json.NewDecoder(bytes.NewReader(t))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, errors.WrapStatus(http.StatusBadRequest, err)
+		}
+	default:
+		v = collate.Normalize(selector)
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.Status(http.StatusBadRequest, "invalid selector: must be a JSON object")
+	}
+	cond, err := parseObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &Selector{cond: cond}, nil
+}
+
+// Match returns true if doc matches the selector. doc is normalized to its
+// generic JSON representation before evaluation.
+func (s *Selector) Match(doc interface{}) bool {
+	return s.cond(collate.Normalize(doc))
+}
+
+func invalid(format string, args ...interface{}) error {
+	return errors.Statusf(http.StatusBadRequest, "invalid selector: "+format, args...)
+}
+
+// parseObject parses a selector object. Each key is either an operator, which
+// applies to the value being evaluated, or a field name, which applies to the
+// named field of the value. All keys must match.
+func parseObject(obj map[string]interface{}) (condition, error) {
+	conds := make([]condition, 0, len(obj))
+	for k, arg := range obj {
+		var cond condition
+		var err error
+		if strings.HasPrefix(k, "$") {
+			cond, err = parseOperator(k, arg)
+		} else {
+			cond, err = parseField(k, arg)
+		}
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+	return all(conds), nil
+}
+
+func all(conds []condition) condition {
+	return func(v interface{}) bool {
+		for _, cond := range conds {
+			if !cond(v) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// parseField parses the condition for a single field. A non-object argument
+// is an implicit $eq.
+func parseField(field string, arg interface{}) (condition, error) {
+	path := SplitField(field)
+	var cond condition
+	if obj, ok := arg.(map[string]interface{}); ok {
+		var err error
+		if cond, err = parseObject(obj); err != nil {
+			return nil, err
+		}
+	} else {
+		cond = func(v interface{}) bool { return collate.Compare(v, arg) == 0 }
+	}
+	// As with CouchDB, a missing field only matches {"$exists": false}.
+	existsFalse := isExistsFalse(arg)
+	return func(v interface{}) bool {
+		value, ok := lookup(v, path)
+		if !ok {
+			return existsFalse
+		}
+		return cond(value)
+	}, nil
+}
+
+func isExistsFalse(arg interface{}) bool {
+	obj, ok := arg.(map[string]interface{})
+	if !ok || len(obj) != 1 {
+		return false
+	}
+	exists, ok := obj["$exists"].(bool)
+	return ok && !exists
+}
+
+func parseSubSelectors(op string, arg interface{}) ([]condition, error) {
+	args, ok := arg.([]interface{})
+	if !ok {
+		return nil, invalid("%s requires an array", op)
+	}
+	conds := make([]condition, len(args))
+	for i, a := range args {
+		obj, ok := a.(map[string]interface{})
+		if !ok {
+			return nil, invalid("%s requires an array of objects", op)
+		}
+		var err error
+		if conds[i], err = parseObject(obj); err != nil {
+			return nil, err
+		}
+	}
+	return conds, nil
+}
+
+func parseSubSelector(op string, arg interface{}) (condition, error) {
+	obj, ok := arg.(map[string]interface{})
+	if !ok {
+		return nil, invalid("%s requires an object", op)
+	}
+	return parseObject(obj)
+}
+
+func parseOperator(op string, arg interface{}) (condition, error) { // nolint: gocyclo
+	switch op {
+	case "$and":
+		conds, err := parseSubSelectors(op, arg)
+		if err != nil {
+			return nil, err
+		}
+		return all(conds), nil
+	case "$or", "$nor":
+		conds, err := parseSubSelectors(op, arg)
+		if err != nil {
+			return nil, err
+		}
+		want := op == "$or"
+		return func(v interface{}) bool {
+			for _, cond := range conds {
+				if cond(v) {
+					return want
+				}
+			}
+			return !want
+		}, nil
+	case "$not":
+		cond, err := parseSubSelector(op, arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(v interface{}) bool { return !cond(v) }, nil
+	case "$eq", "$ne", "$lt", "$lte", "$gt", "$gte":
+		return compareOperator(op, arg), nil
+	case "$exists":
+		want, ok := arg.(bool)
+		if !ok {
+			return nil, invalid("$exists requires a boolean")
+		}
+		// parseField only evaluates conditions for fields which exist.
+		return func(interface{}) bool { return want }, nil
+	case "$type":
+		typ, ok := arg.(string)
+		if !ok {
+			return nil, invalid("$type requires a string")
+		}
+		return func(v interface{}) bool { return typeName(v) == typ }, nil
+	case "$in", "$nin":
+		args, ok := arg.([]interface{})
+		if !ok {
+			return nil, invalid("%s requires an array", op)
+		}
+		want := op == "$in"
+		return func(v interface{}) bool { return in(v, args) == want }, nil
+	case "$size":
+		size, ok := intArg(arg)
+		if !ok {
+			return nil, invalid("$size requires an integer")
+		}
+		return func(v interface{}) bool {
+			a, ok := v.([]interface{})
+			return ok && int64(len(a)) == size
+		}, nil
+	case "$mod":
+		return parseMod(arg)
+	case "$regex":
+		pattern, ok := arg.(string)
+		if !ok {
+			return nil, invalid("$regex requires a string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, invalid("$regex: %s", err)
+		}
+		return func(v interface{}) bool {
+			s, ok := v.(string)
+			return ok && re.MatchString(s)
+		}, nil
+	case "$beginsWith":
+		prefix, ok := arg.(string)
+		if !ok {
+			return nil, invalid("$beginsWith requires a string")
+		}
+		return func(v interface{}) bool {
+			s, ok := v.(string)
+			return ok && strings.HasPrefix(s, prefix)
+		}, nil
+	case "$all":
+		args, ok := arg.([]interface{})
+		if !ok {
+			return nil, invalid("$all requires an array")
+		}
+		return func(v interface{}) bool {
+			values, ok := v.([]interface{})
+			if !ok {
+				return false
+			}
+			for _, a := range args {
+				if !in(a, values) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case "$elemMatch", "$allMatch":
+		cond, err := parseSubSelector(op, arg)
+		if err != nil {
+			return nil, err
+		}
+		want := op == "$elemMatch"
+		return func(v interface{}) bool {
+			values, ok := v.([]interface{})
+			if !ok || len(values) == 0 {
+				return false
+			}
+			for _, value := range values {
+				if cond(value) == want {
+					return want
+				}
+			}
+			return !want
+		}, nil
+	case "$keyMapMatch":
+		cond, err := parseSubSelector(op, arg)
+		if err != nil {
+			return nil, err
+		}
+		return func(v interface{}) bool {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			for k := range obj {
+				if cond(k) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	return nil, invalid("unknown operator %s", op)
+}
+
+func compareOperator(op string, arg interface{}) condition {
+	test := map[string]func(int) bool{
+		"$eq":  func(c int) bool { return c == 0 },
+		"$ne":  func(c int) bool { return c != 0 },
+		"$lt":  func(c int) bool { return c < 0 },
+		"$lte": func(c int) bool { return c <= 0 },
+		"$gt":  func(c int) bool { return c > 0 },
+		"$gte": func(c int) bool { return c >= 0 },
+	}[op]
+	return func(v interface{}) bool { return test(collate.Compare(v, arg)) }
+}
+
+func parseMod(arg interface{}) (condition, error) {
+	args, ok := arg.([]interface{})
+	if !ok || len(args) != 2 {
+		return nil, invalid("$mod requires an array of [divisor, remainder]")
+	}
+	divisor, ok1 := intArg(args[0])
+	remainder, ok2 := intArg(args[1])
+	if !ok1 || !ok2 || divisor == 0 {
+		return nil, invalid("$mod requires a non-zero integer divisor and an integer remainder")
+	}
+	return func(v interface{}) bool {
+		n, ok := intArg(v)
+		return ok && n%divisor == remainder
+	}, nil
+}
+
+func intArg(v interface{}) (int64, bool) {
+	num, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := num.Int64()
+	return i, err == nil
+}
+
+// in returns true if v, or any element of v if it is an array, is equal to
+// one of args.
+func in(v interface{}, args []interface{}) bool {
+	values, ok := v.([]interface{})
+	if !ok {
+		values = []interface{}{v}
+	}
+	for _, value := range values {
+		for _, a := range args {
+			if collate.Compare(value, a) == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// SplitField splits a dotted field name into its path components. Literal
+// dots within a component may be escaped with a backslash.
+func SplitField(field string) []string {
+	var path []string
+	var cur strings.Builder
+	for i := 0; i < len(field); i++ {
+		switch c := field[i]; {
+		case c == '\\' && i+1 < len(field) && field[i+1] == '.':
+			cur.WriteByte('.')
+			i++
+		case c == '.':
+			path = append(path, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	return append(path, cur.String())
+}
+
+// Field returns the value of the dotted field name within doc, and true, or
+// false if the field does not exist. Numeric path components may be used to
+// index arrays.
+func Field(doc interface{}, field string) (interface{}, bool) {
+	return lookup(collate.Normalize(doc), SplitField(field))
+}
+
+func lookup(v interface{}, path []string) (interface{}, bool) {
+	for _, name := range path {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = t[name]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,195 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector interface{}
+		status   int
+		err      string
+	}{
+		{
+			name:     "map",
+			selector: map[string]interface{}{"a": 1},
+		},
+		{
+			name:     "string",
+			selector: `{"a": 1}`,
+		},
+		{
+			name:     "raw message",
+			selector: json.RawMessage(`{"a": 1}`),
+		},
+		{
+			name:     "invalid JSON",
+			selector: []byte(`{"a": `),
+			status:   http.StatusBadRequest,
+			err:      "unexpected EOF",
+		},
+		{
+			name:     "not an object",
+			selector: []interface{}{1},
+			status:   http.StatusBadRequest,
+			err:      "invalid selector: must be a JSON object",
+		},
+		{
+			name:     "unknown operator",
+			selector: `{"a": {"$foo": 1}}`,
+			status:   http.StatusBadRequest,
+			err:      "invalid selector: unknown operator $foo",
+		},
+		{
+			name:     "invalid $and",
+			selector: `{"$and": {"a": 1}}`,
+			status:   http.StatusBadRequest,
+			err:      "invalid selector: $and requires an array",
+		},
+		{
+			name:     "invalid $regex",
+			selector: `{"a": {"$regex": "("}}`,
+			status:   http.StatusBadRequest,
+			err:      "invalid selector: $regex: error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name:     "invalid $mod",
+			selector: `{"a": {"$mod": [0, 1]}}`,
+			status:   http.StatusBadRequest,
+			err:      "invalid selector: $mod requires a non-zero integer divisor and an integer remainder",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := New(test.selector)
+			testy.StatusError(t, test.err, test.status, err)
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	doc := map[string]interface{}{
+		"_id":   "foo",
+		"name":  "Bob",
+		"age":   42,
+		"tags":  []interface{}{"a", "b", "c"},
+		"null":  nil,
+		"dot.s": true,
+		"address": map[string]interface{}{
+			"city": "Amsterdam",
+			"zip":  "1000",
+		},
+		"pets": []interface{}{
+			map[string]interface{}{"kind": "cat", "age": 3},
+			map[string]interface{}{"kind": "dog", "age": 5},
+		},
+	}
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{`{}`, true},
+		{`{"name": "Bob"}`, true},
+		{`{"name": "Alice"}`, false},
+		{`{"name": "Bob", "age": 42}`, true},
+		{`{"name": "Bob", "age": 41}`, false},
+		{`{"age": {"$gt": 40, "$lt": 50}}`, true},
+		{`{"age": {"$gte": 42, "$lte": 42}}`, true},
+		{`{"age": {"$lt": 42}}`, false},
+		{`{"age": {"$ne": 42}}`, false},
+		{`{"missing": {"$ne": 42}}`, false},
+		{`{"missing": {"$exists": false}}`, true},
+		{`{"missing": {"$exists": true}}`, false},
+		{`{"null": {"$exists": true}}`, true},
+		{`{"null": {"$type": "null"}}`, true},
+		{`{"age": {"$type": "number"}}`, true},
+		{`{"tags": {"$type": "array"}}`, true},
+		{`{"address": {"$type": "object"}}`, true},
+		{`{"address.city": "Amsterdam"}`, true},
+		{`{"address": {"city": "Amsterdam"}}`, true},
+		{`{"address": {"city": "Paris"}}`, false},
+		{`{"dot\\.s": true}`, true},
+		{`{"pets.1.kind": "dog"}`, true},
+		{`{"pets.2.kind": "dog"}`, false},
+		{`{"name": {"$in": ["Alice", "Bob"]}}`, true},
+		{`{"name": {"$nin": ["Alice", "Bob"]}}`, false},
+		{`{"tags": {"$in": ["c", "d"]}}`, true},
+		{`{"tags": {"$all": ["a", "c"]}}`, true},
+		{`{"tags": {"$all": ["a", "d"]}}`, false},
+		{`{"tags": {"$size": 3}}`, true},
+		{`{"tags": {"$size": 2}}`, false},
+		{`{"age": {"$mod": [10, 2]}}`, true},
+		{`{"age": {"$mod": [10, 3]}}`, false},
+		{`{"name": {"$regex": "^B"}}`, true},
+		{`{"name": {"$regex": "^A"}}`, false},
+		{`{"name": {"$beginsWith": "Bo"}}`, true},
+		{`{"pets": {"$elemMatch": {"kind": "dog", "age": {"$gt": 4}}}}`, true},
+		{`{"pets": {"$elemMatch": {"kind": "dog", "age": {"$gt": 5}}}}`, false},
+		{`{"pets": {"$allMatch": {"age": {"$gt": 2}}}}`, true},
+		{`{"pets": {"$allMatch": {"kind": "cat"}}}`, false},
+		{`{"address": {"$keyMapMatch": {"$eq": "zip"}}}`, true},
+		{`{"$and": [{"name": "Bob"}, {"age": 42}]}`, true},
+		{`{"$and": [{"name": "Bob"}, {"age": 43}]}`, false},
+		{`{"$or": [{"name": "Alice"}, {"age": 42}]}`, true},
+		{`{"$or": [{"name": "Alice"}, {"age": 43}]}`, false},
+		{`{"$nor": [{"name": "Alice"}, {"age": 43}]}`, true},
+		{`{"$not": {"name": "Alice"}}`, true},
+		{`{"age": {"$not": {"$gt": 40}}}`, false},
+		{`{"age": {"$gt": "10"}}`, false},
+		{`{"name": {"$gt": 10}}`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.selector, func(t *testing.T) {
+			s, err := New(test.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Match(doc); got != test.want {
+				t.Errorf("Match() = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSplitField(t *testing.T) {
+	tests := map[string][]string{
+		"foo":          {"foo"},
+		"foo.bar":      {"foo", "bar"},
+		`foo\.bar.baz`: {"foo.bar", "baz"},
+		`foo\bar`:      {`foo\bar`},
+	}
+	for field, expected := range tests {
+		t.Run(field, func(t *testing.T) {
+			if d := testy.DiffInterface(expected, SplitField(field)); d != nil {
+				t.Error(d)
+			}
+		})
+	}
+}
+
+func TestField(t *testing.T) {
+	doc := map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1, 2}}}
+	if v, ok := Field(doc, "a.b.1"); !ok || v != json.Number("2") {
+		t.Errorf("Unexpected result: %v, %t", v, ok)
+	}
+	if v, ok := Field(doc, "a.c"); ok || v != nil {
+		t.Errorf("Unexpected result: %v, %t", v, ok)
+	}
+}
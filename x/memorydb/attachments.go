@@ -0,0 +1,130 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/x/storage"
+)
+
+// withAttachments returns a new revision with the same content as cur, and
+// its attachments modified by fn.
+func withAttachments(cur *storage.Revision, fn func(atts map[string]*storage.Attachment) error) (*storage.Revision, error) {
+	r := &storage.Revision{
+		Body:        map[string]interface{}{},
+		Attachments: make(map[string]*storage.Attachment),
+	}
+	if cur != nil && !cur.Deleted {
+		r.Body = copyMap(cur.Body)
+		for name, att := range cur.Attachments {
+			r.Attachments[name] = att
+		}
+	}
+	return r, fn(r.Attachments)
+}
+
+func (d *db) PutAttachment(ctx context.Context, docID, rev string, att *driver.Attachment, _ map[string]interface{}) (string, error) {
+	if err := validateDocID(docID); err != nil {
+		return "", err
+	}
+	data, err := d.database(ctx)
+	if err != nil {
+		return "", err
+	}
+	content, err := ioutil.ReadAll(att.Content)
+	if err != nil {
+		return "", err
+	}
+	contentType := att.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return data.update(ctx, docID, rev, func(tx storage.Tx, cur *storage.Revision) (*storage.Revision, error) {
+		return withAttachments(cur, func(atts map[string]*storage.Attachment) error {
+			stored, err := putAttachment(tx, contentType, content)
+			atts[att.Filename] = stored
+			return err
+		})
+	})
+}
+
+func (d *db) GetAttachment(ctx context.Context, docID, filename string, options map[string]interface{}) (*driver.Attachment, error) {
+	data, err := d.database(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result *driver.Attachment
+	err = data.store.View(ctx, func(tx storage.Tx) error {
+		_, r, err := current(tx, docID)
+		if err != nil {
+			return err
+		}
+		if r == nil {
+			return errors.Status(http.StatusNotFound, "missing")
+		}
+		if rev, _ := options["rev"].(string); rev != "" {
+			if r, err = tx.Revs().Get(docID, rev); err != nil {
+				return err
+			}
+		}
+		if r == nil || r.Deleted {
+			return errors.Status(http.StatusNotFound, "missing")
+		}
+		att, ok := r.Attachments[filename]
+		if !ok {
+			return errors.Status(http.StatusNotFound, "Document is missing attachment")
+		}
+		if notModified(options, att.Digest) {
+			return errors.Status(http.StatusNotModified, "not modified")
+		}
+		content, err := tx.Attachments().Get(att.Digest)
+		if err != nil {
+			return err
+		}
+		result = &driver.Attachment{
+			Filename:    filename,
+			ContentType: att.ContentType,
+			Content:     ioutil.NopCloser(bytes.NewReader(content)),
+			Size:        att.Length,
+			RevPos:      att.RevPos,
+			Digest:      att.Digest,
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (d *db) DeleteAttachment(ctx context.Context, docID, rev, filename string, _ map[string]interface{}) (string, error) {
+	data, err := d.database(ctx)
+	if err != nil {
+		return "", err
+	}
+	return data.update(ctx, docID, rev, func(_ storage.Tx, cur *storage.Revision) (*storage.Revision, error) {
+		if cur == nil || cur.Deleted {
+			return nil, errors.Status(http.StatusNotFound, "missing")
+		}
+		if _, ok := cur.Attachments[filename]; !ok {
+			return nil, errors.Status(http.StatusNotFound, "Document is missing attachment")
+		}
+		return withAttachments(cur, func(atts map[string]*storage.Attachment) error {
+			delete(atts, filename)
+			return nil
+		})
+	})
+}
@@ -0,0 +1,109 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev := put(t, db, "foo", map[string]string{"a": "b"})
+	rev, err := db.PutAttachment(ctx, "foo", rev, &kivik.Attachment{
+		Filename:    "foo.txt",
+		ContentType: "text/plain",
+		Content:     ioutil.NopCloser(strings.NewReader("hello")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	att, err := db.GetAttachment(ctx, "foo", "foo.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(att.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "hello" || att.ContentType != "text/plain" || att.RevPos != 2 {
+		t.Errorf("Unexpected attachment: %+v, %s", att, content)
+	}
+	_, err = db.GetAttachment(ctx, "foo", "foo.txt", kivik.Options{"If-None-Match": `"` + att.Digest + `"`})
+	testy.StatusError(t, "not modified", http.StatusNotModified, err)
+
+	// The document body is preserved, and the attachment survives an update
+	// which includes it as a stub.
+	var doc map[string]interface{}
+	if err := db.Get(ctx, "foo").ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["a"] != "b" {
+		t.Errorf("Unexpected doc: %v", doc)
+	}
+	rev = put(t, db, "foo", doc)
+	if _, err := db.GetAttachment(ctx, "foo", "foo.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.GetAttachment(ctx, "foo", "bar.txt")
+	testy.StatusError(t, "Document is missing attachment", http.StatusNotFound, err)
+	_, err = db.DeleteAttachment(ctx, "foo", rev, "bar.txt")
+	testy.StatusError(t, "Document is missing attachment", http.StatusNotFound, err)
+
+	if _, err := db.DeleteAttachment(ctx, "foo", rev, "foo.txt"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.GetAttachment(ctx, "foo", "foo.txt")
+	testy.StatusError(t, "Document is missing attachment", http.StatusNotFound, err)
+}
+
+func TestInlineAttachments(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	put(t, db, "foo", map[string]interface{}{
+		"_attachments": map[string]interface{}{
+			"foo.txt": map[string]interface{}{
+				"content_type": "text/plain",
+				"data":         "aGVsbG8=",
+			},
+		},
+	})
+	var doc struct {
+		Attachments map[string]map[string]interface{} `json:"_attachments"`
+	}
+	if err := db.Get(ctx, "foo", kivik.Options{"attachments": true}).ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]map[string]interface{}{
+		"foo.txt": {
+			"content_type": "text/plain",
+			"data":         "aGVsbG8=",
+			"digest":       "md5-XUFAKrxLKna5cZ2REBfFkg==",
+			"length":       float64(5),
+			"revpos":       float64(1),
+		},
+	}
+	if d := testy.DiffInterface(expected, doc.Attachments); d != nil {
+		t.Error(d)
+	}
+}
@@ -0,0 +1,69 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+)
+
+var _ driver.BulkDocer = &db{}
+
+// bulkResults is a driver.BulkResults backed by a slice.
+type bulkResults []driver.BulkResult
+
+var _ driver.BulkResults = &bulkResults{}
+
+func (r *bulkResults) Next(result *driver.BulkResult) error {
+	if len(*r) == 0 {
+		return io.EOF
+	}
+	*result = (*r)[0]
+	*r = (*r)[1:]
+	return nil
+}
+
+func (r *bulkResults) Close() error {
+	*r = nil
+	return nil
+}
+
+// BulkDocs writes each document in turn. Each document succeeds or fails
+// independently, as with CouchDB; the new_edits=false mode used by
+// replication is not supported.
+func (d *db) BulkDocs(ctx context.Context, docs []interface{}, options map[string]interface{}) (driver.BulkResults, error) {
+	if newEdits, ok := options["new_edits"]; ok && newEdits != true && newEdits != "true" {
+		return nil, errors.Status(http.StatusNotImplemented, "new_edits=false is not supported")
+	}
+	if _, err := d.database(ctx); err != nil {
+		return nil, err
+	}
+	results := make(bulkResults, len(docs))
+	for i, doc := range docs {
+		p, err := parseDoc(doc)
+		if err != nil {
+			results[i] = driver.BulkResult{Error: err}
+			continue
+		}
+		if p.id == "" {
+			p.id = newUUID()
+		}
+		rev, err := d.Put(ctx, p.id, doc, nil)
+		results[i] = driver.BulkResult{ID: p.id, Rev: rev, Error: err}
+	}
+	return &results, nil
+}
@@ -0,0 +1,57 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestBulkDocs(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev := put(t, db, "existing", map[string]string{})
+	results, err := db.BulkDocs(ctx, []interface{}{
+		map[string]string{"_id": "foo"},
+		map[string]string{"_id": "existing"},
+		map[string]interface{}{"_id": "existing", "_rev": rev, "_deleted": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []int
+	var ids []string
+	for results.Next() {
+		ids = append(ids, results.ID())
+		errs = append(errs, kivik.StatusCode(results.UpdateErr()))
+	}
+	if err := results.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"foo", "existing", "existing"}, ids); d != nil {
+		t.Error(d)
+	}
+	if d := testy.DiffInterface([]int{0, http.StatusConflict, 0}, errs); d != nil {
+		t.Error(d)
+	}
+	err = db.Get(ctx, "existing").Err
+	testy.StatusError(t, "deleted", http.StatusNotFound, err)
+
+	_, err = db.BulkDocs(ctx, []interface{}{map[string]string{}}, kivik.Options{"new_edits": false})
+	testy.StatusError(t, "new_edits=false is not supported", http.StatusNotImplemented, err)
+}
@@ -0,0 +1,389 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
+	"github.com/go-kivik/kivik/v4/x/mango"
+	"github.com/go-kivik/kivik/v4/x/storage"
+)
+
+const (
+	feedNormal     = "normal"
+	feedLongpoll   = "longpoll"
+	feedContinuous = "continuous"
+)
+
+// changes is a changes feed. For longpoll and continuous feeds, it waits for
+// updates to the database.
+type changes struct {
+	ctx         context.Context
+	data        *database
+	filter      func(tx storage.Tx, id string, cur *storage.Revision) (bool, error)
+	feed        string
+	since       int64
+	descending  bool
+	includeDocs bool
+	limit       int64
+	hasLimit    bool
+	timeout     time.Duration
+
+	mu       sync.Mutex
+	done     chan struct{}
+	closed   bool
+	queue    []*driver.Change
+	sent     int64
+	waited   bool
+	lastSeq  string
+	pending  int64
+	waitChan <-chan struct{}
+	dbClosed bool
+}
+
+var _ driver.Changes = &changes{}
+
+func (d *db) Changes(ctx context.Context, options map[string]interface{}) (driver.Changes, error) {
+	data, err := d.database(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := &changes{
+		ctx:  ctx,
+		data: data,
+		feed: feedNormal,
+		done: make(chan struct{}),
+	}
+	if feed, _ := options["feed"].(string); feed != "" {
+		switch feed {
+		case feedNormal, feedLongpoll, feedContinuous:
+			c.feed = feed
+		default:
+			return nil, errors.Statusf(http.StatusBadRequest, "unsupported feed type: %s", feed)
+		}
+	}
+	if err := c.parseSince(options); err != nil {
+		return nil, err
+	}
+	if c.descending, err = queryopts.Bool(options, "descending"); err != nil {
+		return nil, err
+	}
+	if c.includeDocs, err = queryopts.Bool(options, "include_docs"); err != nil {
+		return nil, err
+	}
+	if c.limit, c.hasLimit, err = queryopts.Int(options, "limit"); err != nil {
+		return nil, err
+	}
+	if timeout, ok, err := queryopts.Int(options, "timeout"); err != nil {
+		return nil, err
+	} else if ok {
+		c.timeout = time.Duration(timeout) * time.Millisecond
+	}
+	if c.filter, err = changesFilter(options); err != nil {
+		return nil, err
+	}
+	if err := c.fetch(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *changes) parseSince(options map[string]interface{}) error {
+	var since string
+	switch t := options["since"].(type) {
+	case nil:
+		return nil
+	case string:
+		since = t
+	case int:
+		since = strconv.Itoa(t)
+	case int64:
+		since = strconv.FormatInt(t, 10)
+	default:
+		return errors.Statusf(http.StatusBadRequest, "invalid value for since: %v", t)
+	}
+	if since == "now" {
+		return c.data.store.View(c.ctx, func(tx storage.Tx) error {
+			var err error
+			c.since, err = tx.Docs().Seq()
+			return err
+		})
+	}
+	seq, err := strconv.ParseInt(since, 10, 64)
+	if err != nil {
+		return errors.Statusf(http.StatusBadRequest, "invalid value for since: %q", since)
+	}
+	c.since = seq
+	return nil
+}
+
+// changesFilter returns the filter function for the requested built-in
+// filter. Custom filter functions are not supported.
+func changesFilter(options map[string]interface{}) (func(storage.Tx, string, *storage.Revision) (bool, error), error) {
+	filter, _ := options["filter"].(string)
+	switch filter {
+	case "":
+		return nil, nil
+	case "_doc_ids":
+		ids, err := queryopts.Strings(options, "doc_ids")
+		if err != nil {
+			return nil, err
+		}
+		wanted := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			wanted[id] = true
+		}
+		return func(_ storage.Tx, id string, _ *storage.Revision) (bool, error) {
+			return wanted[id], nil
+		}, nil
+	case "_selector":
+		sel, ok, err := queryopts.JSON(options, "selector")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.Status(http.StatusBadRequest, "the _selector filter requires a selector")
+		}
+		selector, err := mango.New(sel)
+		if err != nil {
+			return nil, err
+		}
+		return func(tx storage.Tx, id string, cur *storage.Revision) (bool, error) {
+			body, err := render(tx, id, cur, nil)
+			if err != nil {
+				return false, err
+			}
+			return selector.Match(body), nil
+		}, nil
+	case "_view":
+		name, _ := options["view"].(string)
+		parts := strings.SplitN(name, "/", 2)
+		if len(parts) != 2 {
+			return nil, errors.Status(http.StatusBadRequest, "the _view filter requires a view of the form ddoc/view")
+		}
+		view, ok := lookupView(parts[0], parts[1])
+		if !ok {
+			return nil, errors.Statusf(http.StatusNotFound, "missing named view %s", name)
+		}
+		return func(tx storage.Tx, id string, cur *storage.Revision) (bool, error) {
+			if cur.Deleted || strings.HasPrefix(id, designPrefix) {
+				return false, nil
+			}
+			return emits(tx, view.Map, id, cur)
+		}, nil
+	case "_design":
+		return func(_ storage.Tx, id string, _ *storage.Revision) (bool, error) {
+			return strings.HasPrefix(id, designPrefix), nil
+		}, nil
+	}
+	return nil, errors.Statusf(http.StatusBadRequest, "unsupported filter: %s", filter)
+}
+
+// emits returns true if mapFn emits at least one row for revision cur of
+// document id. A map function which panics emits nothing.
+func emits(tx storage.Tx, mapFn MapFunc, id string, cur *storage.Revision) (emitted bool, err error) {
+	body, err := render(tx, id, cur, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			emitted = false
+		}
+	}()
+	mapFn(body, func(_, _ interface{}) {
+		emitted = true
+	})
+	return emitted, nil
+}
+
+// changed is a document, and its winning revision, selected for a changes
+// feed.
+type changed struct {
+	info *storage.DocInfo
+	cur  *storage.Revision
+}
+
+// fetch queues all matching changes since c.since, and advances c.since.
+func (c *changes) fetch() error {
+	c.waitChan, c.dbClosed = c.data.watch()
+	if c.dbClosed {
+		return nil
+	}
+	err := c.data.store.View(c.ctx, c.fetchTx)
+	if err != nil {
+		// The database may have been destroyed since it was last watched,
+		// which ends the feed.
+		if _, c.dbClosed = c.data.watch(); c.dbClosed {
+			return nil
+		}
+	}
+	return err
+}
+
+func (c *changes) fetchTx(tx storage.Tx) error {
+	seq, err := tx.Docs().Seq()
+	if err != nil {
+		return err
+	}
+	var docs []changed
+	err = tx.Docs().Changes(c.since, func(info *storage.DocInfo) error {
+		cur, err := winner(tx, info)
+		if err != nil {
+			return err
+		}
+		if c.filter != nil {
+			if ok, err := c.filter(tx, info.ID, cur); err != nil || !ok {
+				return err
+			}
+		}
+		docs = append(docs, changed{info: info, cur: cur})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if c.descending {
+		for i, j := 0, len(docs)-1; i < j; i, j = i+1, j-1 {
+			docs[i], docs[j] = docs[j], docs[i]
+		}
+	}
+	c.pending = 0
+	if c.hasLimit {
+		if remaining := c.limit - c.sent; int64(len(docs)) > remaining {
+			c.pending = int64(len(docs)) - remaining
+			docs = docs[:remaining]
+		}
+	}
+	for _, doc := range docs {
+		change, err := c.change(tx, doc)
+		if err != nil {
+			return err
+		}
+		c.queue = append(c.queue, change)
+	}
+	switch {
+	case c.pending > 0 && len(docs) > 0:
+		c.lastSeq = c.queue[len(c.queue)-1].Seq
+	default:
+		c.lastSeq = strconv.FormatInt(seq, 10)
+	}
+	if !c.descending {
+		c.since = seq
+	}
+	c.sent += int64(len(docs))
+	return nil
+}
+
+func (c *changes) change(tx storage.Tx, doc changed) (*driver.Change, error) {
+	change := &driver.Change{
+		ID:      doc.info.ID,
+		Seq:     strconv.FormatInt(doc.info.Seq, 10),
+		Deleted: doc.cur.Deleted,
+		Changes: driver.ChangedRevs{doc.cur.Rev},
+	}
+	if c.includeDocs {
+		body, err := render(tx, doc.info.ID, doc.cur, nil)
+		if err != nil {
+			return nil, err
+		}
+		if change.Doc, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	return change, nil
+}
+
+func (c *changes) Next(change *driver.Change) error {
+	for {
+		c.mu.Lock()
+		if len(c.queue) > 0 {
+			*change = *c.queue[0]
+			c.queue = c.queue[1:]
+			c.mu.Unlock()
+			return nil
+		}
+		finished := c.closed || c.dbClosed ||
+			(c.hasLimit && c.sent >= c.limit) ||
+			c.feed == feedNormal ||
+			(c.feed == feedLongpoll && c.waited)
+		wait := c.waitChan
+		c.waited = true
+		c.mu.Unlock()
+		if finished {
+			return io.EOF
+		}
+		if err := c.wait(wait); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		err := c.fetch()
+		c.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// wait blocks until the database is updated, or the feed ends.
+func (c *changes) wait(updated <-chan struct{}) error {
+	var timeout <-chan time.Time
+	if c.timeout > 0 {
+		timer := time.NewTimer(c.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-updated:
+		return nil
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	case <-c.done:
+	case <-timeout:
+	}
+	return io.EOF
+}
+
+func (c *changes) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+	return nil
+}
+
+func (c *changes) LastSeq() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastSeq
+}
+
+func (c *changes) Pending() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pending
+}
+
+func (c *changes) ETag() string { return "" }
@@ -0,0 +1,216 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+type change struct {
+	ID      string
+	Seq     string
+	Deleted bool
+}
+
+func readChanges(t *testing.T, changes *kivik.Changes) []change {
+	t.Helper()
+	result := []change{}
+	for changes.Next() {
+		result = append(result, change{
+			ID:      changes.ID(),
+			Seq:     changes.Seq(),
+			Deleted: changes.Deleted(),
+		})
+	}
+	if err := changes.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestChanges(t *testing.T) {
+	type tst struct {
+		options  kivik.Options
+		expected []change
+		lastSeq  string
+		pending  int64
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("all", tst{
+		expected: []change{
+			{ID: "b", Seq: "2"},
+			{ID: "_design/foo", Seq: "3"},
+			{ID: "a", Seq: "4", Deleted: true},
+		},
+		lastSeq: "4",
+	})
+	tests.Add("since", tst{
+		options: kivik.Options{"since": "3"},
+		expected: []change{
+			{ID: "a", Seq: "4", Deleted: true},
+		},
+		lastSeq: "4",
+	})
+	tests.Add("since now", tst{
+		options:  kivik.Options{"since": "now"},
+		expected: []change{},
+		lastSeq:  "4",
+	})
+	tests.Add("limit", tst{
+		options: kivik.Options{"limit": 1},
+		expected: []change{
+			{ID: "b", Seq: "2"},
+		},
+		lastSeq: "2",
+		pending: 2,
+	})
+	tests.Add("descending", tst{
+		options: kivik.Options{"descending": true},
+		expected: []change{
+			{ID: "a", Seq: "4", Deleted: true},
+			{ID: "_design/foo", Seq: "3"},
+			{ID: "b", Seq: "2"},
+		},
+		lastSeq: "4",
+	})
+	tests.Add("doc_ids", tst{
+		options: kivik.Options{"filter": "_doc_ids", "doc_ids": []string{"b"}},
+		expected: []change{
+			{ID: "b", Seq: "2"},
+		},
+		lastSeq: "4",
+	})
+	tests.Add("selector", tst{
+		options: kivik.Options{"filter": "_selector", "selector": map[string]interface{}{"_deleted": true}},
+		expected: []change{
+			{ID: "a", Seq: "4", Deleted: true},
+		},
+		lastSeq: "4",
+	})
+	tests.Add("design", tst{
+		options: kivik.Options{"filter": "_design"},
+		expected: []change{
+			{ID: "_design/foo", Seq: "3"},
+		},
+		lastSeq: "4",
+	})
+	tests.Add("view", tst{
+		options: kivik.ChangesView("_design/test", "map_only"),
+		expected: []change{
+			{ID: "b", Seq: "2"},
+		},
+		lastSeq: "4",
+	})
+	tests.Add("missing view", tst{
+		options: kivik.ChangesView("test", "missing"),
+		status:  http.StatusNotFound,
+		err:     "missing named view test/missing",
+	})
+	tests.Add("invalid view", tst{
+		options: kivik.Options{"filter": "_view", "view": "test"},
+		status:  http.StatusBadRequest,
+		err:     "the _view filter requires a view of the form ddoc/view",
+	})
+	tests.Add("unsupported filter", tst{
+		options: kivik.Options{"filter": "foo/bar"},
+		status:  http.StatusBadRequest,
+		err:     "unsupported filter: foo/bar",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		db := newDB(t)
+		rev := put(t, db, "a", map[string]string{})
+		put(t, db, "b", map[string]string{})
+		put(t, db, "_design/foo", map[string]string{})
+		if _, err := db.Delete(context.Background(), "a", rev); err != nil {
+			t.Fatal(err)
+		}
+
+		changes, err := db.Changes(context.Background(), tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, readChanges(t, changes)); d != nil {
+			t.Error(d)
+		}
+		if changes.LastSeq() != tt.lastSeq {
+			t.Errorf("Unexpected last seq: %s", changes.LastSeq())
+		}
+		if changes.Pending() != tt.pending {
+			t.Errorf("Unexpected pending: %d", changes.Pending())
+		}
+	})
+}
+
+func TestChangesLongpoll(t *testing.T) {
+	db := newDB(t)
+	put(t, db, "a", map[string]string{})
+	changes, err := db.Changes(context.Background(), kivik.Options{
+		"feed":  "longpoll",
+		"since": "now",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		put(t, db, "b", map[string]string{})
+	}()
+	expected := []change{{ID: "b", Seq: "2"}}
+	if d := testy.DiffInterface(expected, readChanges(t, changes)); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestChangesContinuous(t *testing.T) {
+	db := newDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := db.Changes(ctx, kivik.Options{"feed": "continuous"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b"} {
+		put(t, db, id, map[string]string{})
+		if !changes.Next() {
+			t.Fatalf("Expected change for %s: %v", id, changes.Err())
+		}
+		if changes.ID() != id {
+			t.Errorf("Unexpected change: %s", changes.ID())
+		}
+	}
+	if err := changes.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChangesTimeout(t *testing.T) {
+	db := newDB(t)
+	changes, err := db.Changes(context.Background(), kivik.Options{
+		"feed":    "continuous",
+		"timeout": 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]change{}, readChanges(t, changes)); d != nil {
+		t.Error(d)
+	}
+}
@@ -0,0 +1,630 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
+	"github.com/go-kivik/kivik/v4/x/storage"
+)
+
+type db struct {
+	client *client
+	name   string
+	// snapshot is the storage of a snapshot handle, which is read in place
+	// of the named database.
+	snapshot *database
+}
+
+var _ driver.DB = &db{}
+
+// database returns the database's storage, or a status 404 error if the
+// database does not exist.
+func (d *db) database(ctx context.Context) (*database, error) {
+	if d.snapshot != nil {
+		return d.snapshot, nil
+	}
+	return d.client.database(ctx, d.name)
+}
+
+const (
+	designPrefix = "_design/"
+	localPrefix  = "_local/"
+)
+
+func validateDocID(docID string) error {
+	if docID == "" {
+		return errors.Status(http.StatusBadRequest, "document id must not be empty")
+	}
+	if strings.HasPrefix(docID, "_") && !strings.HasPrefix(docID, designPrefix) && !strings.HasPrefix(docID, localPrefix) {
+		return errors.Status(http.StatusBadRequest, "only reserved document ids may start with underscore")
+	}
+	return nil
+}
+
+// parsedDoc is a document submitted for writing, split into its special
+// underscore fields and the remaining body.
+type parsedDoc struct {
+	id          string
+	rev         string
+	deleted     bool
+	attachments map[string]interface{}
+	body        map[string]interface{}
+}
+
+func parseDoc(doc interface{}) (*parsedDoc, error) {
+	body, err := toMap(doc)
+	if err != nil {
+		return nil, err
+	}
+	p := &parsedDoc{body: body}
+	for k, v := range body {
+		if !strings.HasPrefix(k, "_") {
+			continue
+		}
+		switch k {
+		case "_id":
+			p.id, _ = v.(string)
+		case "_rev":
+			p.rev, _ = v.(string)
+		case "_deleted":
+			p.deleted, _ = v.(bool)
+		case "_attachments":
+			atts, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.Status(http.StatusBadRequest, "_attachments must be a JSON object")
+			}
+			p.attachments = atts
+		case "_revisions", "_revs_info", "_conflicts", "_deleted_conflicts", "_local_seq":
+			// Ignored on write, as by CouchDB.
+		default:
+			return nil, errors.Statusf(http.StatusBadRequest, "bad special document member: %s", k)
+		}
+		delete(body, k)
+	}
+	return p, nil
+}
+
+// buildAttachments resolves the _attachments member of a document being
+// written, storing the content of any new attachments. Stubs refer to the
+// attachments of cur.
+func buildAttachments(tx storage.Tx, cur *storage.Revision, atts map[string]interface{}) (map[string]*storage.Attachment, error) {
+	if len(atts) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]*storage.Attachment, len(atts))
+	for name, v := range atts {
+		att, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.Statusf(http.StatusBadRequest, "invalid attachment %s", name)
+		}
+		if stub, _ := att["stub"].(bool); stub {
+			var old *storage.Attachment
+			if cur != nil {
+				old = cur.Attachments[name]
+			}
+			if old == nil {
+				return nil, errors.Statusf(http.StatusPreconditionFailed, "invalid attachment stub for %s", name)
+			}
+			result[name] = old
+			continue
+		}
+		encoded, ok := att["data"].(string)
+		if !ok {
+			return nil, errors.Statusf(http.StatusBadRequest, "attachment %s has no data", name)
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.Statusf(http.StatusBadRequest, "invalid attachment data for %s", name)
+		}
+		contentType, _ := att["content_type"].(string)
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		if result[name], err = putAttachment(tx, contentType, data); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (d *db) Put(ctx context.Context, docID string, doc interface{}, options map[string]interface{}) (string, error) {
+	if err := validateDocID(docID); err != nil {
+		return "", err
+	}
+	p, err := parseDoc(doc)
+	if err != nil {
+		return "", err
+	}
+	if p.rev == "" {
+		p.rev, _ = options["rev"].(string)
+	}
+	data, err := d.database(ctx)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(docID, localPrefix) {
+		return data.putLocal(ctx, docID, p)
+	}
+	return data.update(ctx, docID, p.rev, func(tx storage.Tx, cur *storage.Revision) (*storage.Revision, error) {
+		atts, err := buildAttachments(tx, cur, p.attachments)
+		if err != nil {
+			return nil, err
+		}
+		return &storage.Revision{
+			Deleted:     p.deleted,
+			Body:        p.body,
+			Attachments: atts,
+		}, nil
+	})
+}
+
+func (d *db) CreateDoc(ctx context.Context, doc interface{}, options map[string]interface{}) (string, string, error) {
+	p, err := parseDoc(doc)
+	if err != nil {
+		return "", "", err
+	}
+	docID := p.id
+	if docID == "" {
+		docID = newUUID()
+	}
+	rev, err := d.Put(ctx, docID, doc, options)
+	return docID, rev, err
+}
+
+func (d *db) Get(ctx context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+	data, err := d.database(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	var rev string
+	if strings.HasPrefix(docID, localPrefix) {
+		doc, rev, err = data.getLocal(ctx, docID)
+	} else {
+		doc, rev, err = data.get(ctx, docID, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &driver.Document{
+		ContentLength: int64(len(body)),
+		Rev:           rev,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+// get returns the requested revision of the document, or the current revision
+// if none is specified in options, rendered as a JSON object.
+func (d *database) get(ctx context.Context, docID string, options map[string]interface{}) (doc map[string]interface{}, rev string, err error) {
+	err = d.store.View(ctx, func(tx storage.Tx) error {
+		_, r, err := current(tx, docID)
+		if err != nil {
+			return err
+		}
+		if r == nil {
+			return errors.Status(http.StatusNotFound, "missing")
+		}
+		if rev, _ := options["rev"].(string); rev != "" {
+			if r, err = tx.Revs().Get(docID, rev); err != nil {
+				return err
+			}
+			if r == nil || compacted(r) {
+				return errors.Status(http.StatusNotFound, "missing")
+			}
+		} else if r.Deleted {
+			return errors.Status(http.StatusNotFound, "deleted")
+		}
+		if notModified(options, r.Rev) {
+			return errors.Status(http.StatusNotModified, "not modified")
+		}
+		doc, err = render(tx, docID, r, options)
+		rev = r.Rev
+		return err
+	})
+	return doc, rev, err
+}
+
+// notModified reports whether the If-None-Match option, as used by the CouchDB
+// driver for a conditional GET, names etag.
+func notModified(options map[string]interface{}, etag string) bool {
+	inm, _ := options["If-None-Match"].(string)
+	return inm != "" && strings.Trim(inm, `"`) == etag
+}
+
+// render returns revision r of document id as a JSON object, including the
+// special fields requested by options.
+func render(tx storage.Tx, id string, r *storage.Revision, options map[string]interface{}) (map[string]interface{}, error) {
+	inlineAtts, err := queryopts.Bool(options, "attachments")
+	if err != nil {
+		return nil, err
+	}
+	revs, err := queryopts.Bool(options, "revs")
+	if err != nil {
+		return nil, err
+	}
+	revsInfo, err := queryopts.Bool(options, "revs_info")
+	if err != nil {
+		return nil, err
+	}
+	out := copyMap(r.Body)
+	if out == nil {
+		out = map[string]interface{}{}
+	}
+	out["_id"] = id
+	out["_rev"] = r.Rev
+	if r.Deleted {
+		out["_deleted"] = true
+	}
+	if len(r.Attachments) > 0 {
+		atts := make(map[string]interface{}, len(r.Attachments))
+		for name, att := range r.Attachments {
+			a := map[string]interface{}{
+				"content_type": att.ContentType,
+				"digest":       att.Digest,
+				"length":       att.Length,
+				"revpos":       att.RevPos,
+			}
+			if inlineAtts {
+				content, err := tx.Attachments().Get(att.Digest)
+				if err != nil {
+					return nil, err
+				}
+				a["data"] = base64.StdEncoding.EncodeToString(content)
+			} else {
+				a["stub"] = true
+			}
+			atts[name] = a
+		}
+		out["_attachments"] = atts
+	}
+	if revs || revsInfo {
+		history, err := history(tx, id, r)
+		if err != nil {
+			return nil, err
+		}
+		if revs {
+			ids := make([]string, len(history))
+			for i, h := range history {
+				ids[i] = strings.SplitN(h.Rev, "-", 2)[1]
+			}
+			out["_revisions"] = map[string]interface{}{
+				"start": generation(r.Rev),
+				"ids":   ids,
+			}
+		}
+		if revsInfo {
+			info := make([]map[string]string, len(history))
+			for i, h := range history {
+				status := "available"
+				switch {
+				case compacted(h):
+					status = "missing"
+				case h.Deleted:
+					status = "deleted"
+				}
+				info[i] = map[string]string{"rev": h.Rev, "status": status}
+			}
+			out["_revs_info"] = info
+		}
+	}
+	return out, nil
+}
+
+// history returns revision r of document id, and its stored ancestors, newest
+// first.
+func history(tx storage.Tx, id string, r *storage.Revision) ([]*storage.Revision, error) {
+	history := []*storage.Revision{r}
+	for r.Parent != "" {
+		parent, err := tx.Revs().Get(id, r.Parent)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			break
+		}
+		history = append(history, parent)
+		r = parent
+	}
+	return history, nil
+}
+
+func (d *db) Delete(ctx context.Context, docID, rev string, _ map[string]interface{}) (string, error) {
+	if err := validateDocID(docID); err != nil {
+		return "", err
+	}
+	data, err := d.database(ctx)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(docID, localPrefix) {
+		return data.deleteLocal(ctx, docID, rev)
+	}
+	var cur *storage.Revision
+	if err := data.store.View(ctx, func(tx storage.Tx) error {
+		_, cur, err = current(tx, docID)
+		return err
+	}); err != nil {
+		return "", err
+	}
+	switch {
+	case cur == nil:
+		return "", errors.Status(http.StatusNotFound, "missing")
+	case cur.Deleted:
+		return "", errors.Status(http.StatusNotFound, "deleted")
+	}
+	return data.update(ctx, docID, rev, func(storage.Tx, *storage.Revision) (*storage.Revision, error) {
+		return &storage.Revision{
+			Deleted: true,
+			Body:    map[string]interface{}{},
+		}, nil
+	})
+}
+
+func (d *database) putLocal(ctx context.Context, docID string, p *parsedDoc) (string, error) {
+	var rev string
+	err := d.store.Update(ctx, func(tx storage.Tx) error {
+		doc, err := tx.Local().Get(docID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case doc == nil && p.rev != "":
+			return errConflict
+		case doc != nil && p.rev != localRev(doc.Rev):
+			return errConflict
+		}
+		if p.deleted {
+			rev = "0-0"
+			return tx.Local().Delete(docID)
+		}
+		next := &storage.LocalDoc{ID: docID, Rev: 1, Body: p.body}
+		if doc != nil {
+			next.Rev = doc.Rev + 1
+		}
+		rev = localRev(next.Rev)
+		return tx.Local().Put(next)
+	})
+	return rev, err
+}
+
+func localRev(rev int64) string {
+	return "0-" + strconv.FormatInt(rev, 10)
+}
+
+func (d *database) getLocal(ctx context.Context, docID string) (out map[string]interface{}, rev string, err error) {
+	err = d.store.View(ctx, func(tx storage.Tx) error {
+		doc, err := tx.Local().Get(docID)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			return errors.Status(http.StatusNotFound, "missing")
+		}
+		rev = localRev(doc.Rev)
+		out = copyMap(doc.Body)
+		out["_id"] = docID
+		out["_rev"] = rev
+		return nil
+	})
+	return out, rev, err
+}
+
+func (d *database) deleteLocal(ctx context.Context, docID, rev string) (string, error) {
+	err := d.store.Update(ctx, func(tx storage.Tx) error {
+		doc, err := tx.Local().Get(docID)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			return errors.Status(http.StatusNotFound, "missing")
+		}
+		if rev != localRev(doc.Rev) {
+			return errConflict
+		}
+		return tx.Local().Delete(docID)
+	})
+	if err != nil {
+		return "", err
+	}
+	return "0-0", nil
+}
+
+var _ driver.LocalDocStorer = &db{}
+
+func (d *db) GetLocal(ctx context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+	return d.Get(ctx, localPrefix+docID, options)
+}
+
+// PutLocal stores a local document, replacing any existing version regardless
+// of its revision.
+func (d *db) PutLocal(ctx context.Context, docID string, doc interface{}, _ map[string]interface{}) error {
+	p, err := parseDoc(doc)
+	if err != nil {
+		return err
+	}
+	data, err := d.database(ctx)
+	if err != nil {
+		return err
+	}
+	docID = localPrefix + docID
+	return data.store.Update(ctx, func(tx storage.Tx) error {
+		if p.deleted {
+			return tx.Local().Delete(docID)
+		}
+		old, err := tx.Local().Get(docID)
+		if err != nil {
+			return err
+		}
+		next := &storage.LocalDoc{ID: docID, Rev: 1, Body: p.body}
+		if old != nil {
+			next.Rev = old.Rev + 1
+		}
+		return tx.Local().Put(next)
+	})
+}
+
+func (d *db) DeleteLocal(ctx context.Context, docID string, _ map[string]interface{}) error {
+	data, err := d.database(ctx)
+	if err != nil {
+		return err
+	}
+	return data.store.Update(ctx, func(tx storage.Tx) error {
+		doc, err := tx.Local().Get(localPrefix + docID)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			return errors.Status(http.StatusNotFound, "missing")
+		}
+		return tx.Local().Delete(localPrefix + docID)
+	})
+}
+
+func (d *db) Stats(ctx context.Context) (*driver.DBStats, error) {
+	data, err := d.database(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats := &driver.DBStats{Name: d.name}
+	err = data.store.View(ctx, func(tx storage.Tx) error {
+		seq, err := tx.Docs().Seq()
+		if err != nil {
+			return err
+		}
+		stats.UpdateSeq = strconv.FormatInt(seq, 10)
+		return tx.Docs().Range(func(info *storage.DocInfo) error {
+			if info.Deleted {
+				stats.DeletedCount++
+			} else {
+				stats.DocCount++
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Compact discards the content of all non-current revisions, and any
+// attachment content no longer referred to by a revision.
+func (d *db) Compact(ctx context.Context) error {
+	data, err := d.database(ctx)
+	if err != nil {
+		return err
+	}
+	return data.store.Update(ctx, func(tx storage.Tx) error {
+		referenced := make(map[string]bool)
+		err := tx.Docs().Range(func(info *storage.DocInfo) error {
+			revs, err := tx.Revs().Revs(info.ID)
+			if err != nil {
+				return err
+			}
+			for _, r := range revs {
+				if r.Rev == info.Rev {
+					for _, att := range r.Attachments {
+						referenced[att.Digest] = true
+					}
+					continue
+				}
+				if compacted(r) {
+					continue
+				}
+				if err := tx.Revs().Put(info.ID, &storage.Revision{
+					Rev:     r.Rev,
+					Parent:  r.Parent,
+					Deleted: r.Deleted,
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		var unreferenced []string
+		if err := tx.Attachments().Range(func(digest string) error {
+			if !referenced[digest] {
+				unreferenced = append(unreferenced, digest)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		for _, digest := range unreferenced {
+			if err := tx.Attachments().Delete(digest); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CompactView is a no-op, as views are not indexed.
+func (d *db) CompactView(ctx context.Context, _ string) error {
+	_, err := d.database(ctx)
+	return err
+}
+
+// ViewCleanup is a no-op, as views are not indexed.
+func (d *db) ViewCleanup(ctx context.Context) error {
+	_, err := d.database(ctx)
+	return err
+}
+
+func (d *db) Security(ctx context.Context) (*driver.Security, error) {
+	data, err := d.database(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var sec driver.Security
+	err = data.store.View(ctx, func(tx storage.Tx) error {
+		stored, err := tx.Security()
+		if err == nil {
+			sec = *stored
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sec, nil
+}
+
+func (d *db) SetSecurity(ctx context.Context, security *driver.Security) error {
+	data, err := d.database(ctx)
+	if err != nil {
+		return err
+	}
+	sec := *security
+	return data.store.Update(ctx, func(tx storage.Tx) error {
+		return tx.SetSecurity(&sec)
+	})
+}
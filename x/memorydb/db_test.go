@@ -0,0 +1,420 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/x/storage"
+)
+
+func TestPut(t *testing.T) {
+	tests := []struct {
+		name   string
+		docID  string
+		doc    interface{}
+		status int
+		err    string
+	}{
+		{
+			name:  "new doc",
+			docID: "foo",
+			doc:   map[string]string{"a": "b"},
+		},
+		{
+			name:  "update",
+			docID: "existing",
+			doc:   map[string]string{"_rev": "1-4db7278dc43b3a6533143acd9d03fccc"},
+		},
+		{
+			name:   "conflict",
+			docID:  "existing",
+			doc:    map[string]string{"a": "b"},
+			status: http.StatusConflict,
+			err:    "document update conflict",
+		},
+		{
+			name:   "wrong rev",
+			docID:  "existing",
+			doc:    map[string]string{"_rev": "1-xxx"},
+			status: http.StatusConflict,
+			err:    "document update conflict",
+		},
+		{
+			name:   "rev for new doc",
+			docID:  "foo",
+			doc:    map[string]string{"_rev": "1-xxx"},
+			status: http.StatusConflict,
+			err:    "document update conflict",
+		},
+		{
+			name:   "invalid special field",
+			docID:  "foo",
+			doc:    map[string]string{"_foo": "bar"},
+			status: http.StatusBadRequest,
+			err:    "bad special document member: _foo",
+		},
+		{
+			name:   "reserved id",
+			docID:  "_foo",
+			doc:    map[string]string{},
+			status: http.StatusBadRequest,
+			err:    "only reserved document ids may start with underscore",
+		},
+		{
+			name:   "not an object",
+			docID:  "foo",
+			doc:    []string{"a"},
+			status: http.StatusBadRequest,
+			err:    "json: cannot unmarshal array into Go value of type map[string]interface {}",
+		},
+		{
+			name:   "invalid stub",
+			docID:  "foo",
+			doc:    map[string]interface{}{"_attachments": map[string]interface{}{"foo.txt": map[string]interface{}{"stub": true}}},
+			status: http.StatusPreconditionFailed,
+			err:    "invalid attachment stub for foo.txt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newDB(t)
+			rev := put(t, db, "existing", map[string]string{"a": "b"})
+			if rev != "1-4db7278dc43b3a6533143acd9d03fccc" {
+				t.Fatalf("Unexpected rev: %s", rev)
+			}
+			_, err := db.Put(context.Background(), tt.docID, tt.doc)
+			testy.StatusError(t, tt.err, tt.status, err)
+		})
+	}
+}
+
+func TestCreateDoc(t *testing.T) {
+	db := newDB(t)
+	ctx := context.Background()
+	docID, rev, err := db.CreateDoc(ctx, map[string]string{"a": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docID) != 32 {
+		t.Errorf("Unexpected doc id: %s", docID)
+	}
+	if _, err := db.Put(ctx, docID, map[string]string{"_rev": rev}); err != nil {
+		t.Fatal(err)
+	}
+	docID, _, err = db.CreateDoc(ctx, map[string]string{"_id": "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if docID != "foo" {
+		t.Errorf("Unexpected doc id: %s", docID)
+	}
+}
+
+func TestGet(t *testing.T) {
+	type tst struct {
+		docID    string
+		options  kivik.Options
+		expected map[string]interface{}
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("missing", tst{
+		docID:  "bar",
+		status: http.StatusNotFound,
+		err:    "missing",
+	})
+	tests.Add("current", tst{
+		docID: "foo",
+		expected: map[string]interface{}{
+			"_id":  "foo",
+			"_rev": "2-b4126bcde17f9990847dd0745c3cd0ac",
+			"a":    "c",
+		},
+	})
+	tests.Add("old rev", tst{
+		docID:   "foo",
+		options: kivik.Options{"rev": "1-4db7278dc43b3a6533143acd9d03fccc"},
+		expected: map[string]interface{}{
+			"_id":  "foo",
+			"_rev": "1-4db7278dc43b3a6533143acd9d03fccc",
+			"a":    "b",
+		},
+	})
+	tests.Add("unknown rev", tst{
+		docID:   "foo",
+		options: kivik.Options{"rev": "1-xxx"},
+		status:  http.StatusNotFound,
+		err:     "missing",
+	})
+	tests.Add("revs", tst{
+		docID:   "foo",
+		options: kivik.Options{"revs": true, "revs_info": "true"},
+		expected: map[string]interface{}{
+			"_id":  "foo",
+			"_rev": "2-b4126bcde17f9990847dd0745c3cd0ac",
+			"a":    "c",
+			"_revisions": map[string]interface{}{
+				"start": float64(2),
+				"ids":   []interface{}{"b4126bcde17f9990847dd0745c3cd0ac", "4db7278dc43b3a6533143acd9d03fccc"},
+			},
+			"_revs_info": []interface{}{
+				map[string]interface{}{"rev": "2-b4126bcde17f9990847dd0745c3cd0ac", "status": "available"},
+				map[string]interface{}{"rev": "1-4db7278dc43b3a6533143acd9d03fccc", "status": "available"},
+			},
+		},
+	})
+	tests.Add("deleted", tst{
+		docID:  "deleted",
+		status: http.StatusNotFound,
+		err:    "deleted",
+	})
+	tests.Add("not modified", tst{
+		docID:   "foo",
+		options: kivik.Options{"If-None-Match": `"2-b4126bcde17f9990847dd0745c3cd0ac"`},
+		status:  http.StatusNotModified,
+		err:     "not modified",
+	})
+	tests.Add("modified", tst{
+		docID:   "foo",
+		options: kivik.Options{"If-None-Match": `"1-4db7278dc43b3a6533143acd9d03fccc"`},
+		expected: map[string]interface{}{
+			"_id":  "foo",
+			"_rev": "2-b4126bcde17f9990847dd0745c3cd0ac",
+			"a":    "c",
+		},
+	})
+	tests.Add("local", tst{
+		docID: "_local/foo",
+		expected: map[string]interface{}{
+			"_id":  "_local/foo",
+			"_rev": "0-1",
+			"a":    "b",
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		db := newDB(t)
+		rev := put(t, db, "foo", map[string]string{"a": "b"})
+		put(t, db, "foo", map[string]string{"_rev": rev, "a": "c"})
+		rev = put(t, db, "deleted", map[string]string{})
+		if _, err := db.Delete(context.Background(), "deleted", rev); err != nil {
+			t.Fatal(err)
+		}
+		put(t, db, "_local/foo", map[string]string{"a": "b"})
+
+		var result map[string]interface{}
+		err := db.Get(context.Background(), tt.docID, tt.options).ScanDoc(&result)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, result); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		docID  string
+		rev    string
+		status int
+		err    string
+	}{
+		{
+			name:  "success",
+			docID: "foo",
+			rev:   "1-4db7278dc43b3a6533143acd9d03fccc",
+		},
+		{
+			name:   "missing",
+			docID:  "bar",
+			rev:    "1-xxx",
+			status: http.StatusNotFound,
+			err:    "missing",
+		},
+		{
+			name:   "conflict",
+			docID:  "foo",
+			rev:    "1-xxx",
+			status: http.StatusConflict,
+			err:    "document update conflict",
+		},
+		{
+			name:  "local",
+			docID: "_local/foo",
+			rev:   "0-1",
+		},
+		{
+			name:   "local conflict",
+			docID:  "_local/foo",
+			rev:    "0-2",
+			status: http.StatusConflict,
+			err:    "document update conflict",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newDB(t)
+			put(t, db, "foo", map[string]string{"a": "b"})
+			put(t, db, "_local/foo", map[string]string{"a": "b"})
+			_, err := db.Delete(context.Background(), tt.docID, tt.rev)
+			testy.StatusError(t, tt.err, tt.status, err)
+			if err != nil {
+				return
+			}
+			err = db.Get(context.Background(), tt.docID).Err
+			if kivik.StatusCode(err) != http.StatusNotFound {
+				t.Errorf("Expected deleted doc to be missing, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStatsAndCompact(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev := put(t, db, "foo", map[string]string{"a": "b"})
+	put(t, db, "foo", map[string]string{"_rev": rev, "a": "c"})
+	rev = put(t, db, "bar", map[string]string{})
+	if _, err := db.Delete(ctx, "bar", rev); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := db.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &kivik.DBStats{
+		Name:         "test",
+		DocCount:     1,
+		DeletedCount: 1,
+		UpdateSeq:    "4",
+	}
+	if d := testy.DiffInterface(expected, stats); d != nil {
+		t.Error(d)
+	}
+	if err := db.Compact(ctx); err != nil {
+		t.Fatal(err)
+	}
+	err = db.Get(ctx, "foo", kivik.Options{"rev": "1-4db7278dc43b3a6533143acd9d03fccc"}).Err
+	testy.StatusError(t, "missing", http.StatusNotFound, err)
+}
+
+func TestCompactAttachments(t *testing.T) {
+	ctx := context.Background()
+	engine := storage.NewMemory()
+	c, err := NewDriver(func(string) (storage.Engine, error) {
+		return engine, nil
+	}).NewClient("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateDB(ctx, "test", nil); err != nil {
+		t.Fatal(err)
+	}
+	db, err := c.DB(ctx, "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rev, err := db.Put(ctx, "foo", map[string]interface{}{
+		"_attachments": map[string]interface{}{
+			"old.txt":  map[string]interface{}{"data": "b2xk"},
+			"kept.txt": map[string]interface{}{"data": "a2VwdA=="},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Put(ctx, "foo", map[string]interface{}{
+		"_rev": rev,
+		"_attachments": map[string]interface{}{
+			"kept.txt": map[string]interface{}{"stub": true},
+		},
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	blobs := func() int {
+		store, err := engine.DB(ctx, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		if err := store.View(ctx, func(tx storage.Tx) error {
+			return tx.Attachments().Range(func(string) error {
+				n++
+				return nil
+			})
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := blobs(); n != 2 {
+		t.Fatalf("Expected 2 stored attachments before compaction, got %d", n)
+	}
+	if err := db.Compact(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := blobs(); n != 1 {
+		t.Errorf("Expected 1 stored attachment after compaction, got %d", n)
+	}
+}
+
+func TestSecurity(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	sec := &kivik.Security{
+		Admins: kivik.Members{Names: []string{"bob"}},
+	}
+	if err := db.SetSecurity(ctx, sec); err != nil {
+		t.Fatal(err)
+	}
+	result, err := db.Security(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(sec, result); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestLocalDocStorer(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	if err := db.PutLocal(ctx, "checkpoint", map[string]interface{}{"seq": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PutLocal(ctx, "_local/checkpoint", map[string]interface{}{"_rev": "0-99", "seq": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := db.GetLocal(ctx, "checkpoint").ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"_id": "_local/checkpoint", "_rev": "0-2", "seq": "2"}
+	if d := testy.DiffInterface(expected, doc); d != nil {
+		t.Error(d)
+	}
+	if err := db.DeleteLocal(ctx, "checkpoint"); err != nil {
+		t.Fatal(err)
+	}
+	err := db.DeleteLocal(ctx, "checkpoint")
+	testy.StatusError(t, "missing", http.StatusNotFound, err)
+	err = db.GetLocal(ctx, "checkpoint").Err
+	testy.StatusError(t, "missing", http.StatusNotFound, err)
+}
@@ -0,0 +1,393 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/internal/collate"
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
+	"github.com/go-kivik/kivik/v4/x/mango"
+	"github.com/go-kivik/kivik/v4/x/storage"
+)
+
+var _ driver.OptsFinder = &db{}
+
+const (
+	defaultFindLimit = 25
+	noIndexWarning   = "No matching index found, create an index to optimize query time."
+)
+
+// findQuery is a parsed /_find request.
+type findQuery struct {
+	raw      map[string]interface{}
+	selector *mango.Selector
+	fields   []string
+	sort     []sortField
+	limit    int64
+	skip     int64
+}
+
+type sortField struct {
+	field string
+	desc  bool
+}
+
+func parseFindQuery(query interface{}) (*findQuery, error) {
+	if s, ok := query.(string); ok {
+		query = []byte(s)
+	}
+	raw, err := toMap(query)
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := raw["selector"]
+	if !ok {
+		return nil, errors.Status(http.StatusBadRequest, "missing required key: selector")
+	}
+	q := &findQuery{raw: raw, limit: defaultFindLimit}
+	if q.selector, err = mango.New(sel); err != nil {
+		return nil, err
+	}
+	if q.fields, err = queryopts.Strings(raw, "fields"); err != nil {
+		return nil, err
+	}
+	if q.sort, err = parseSort(raw["sort"]); err != nil {
+		return nil, err
+	}
+	if limit, ok, err := queryopts.Int(raw, "limit"); err != nil {
+		return nil, err
+	} else if ok {
+		q.limit = limit
+	}
+	if q.skip, _, err = queryopts.Int(raw, "skip"); err != nil {
+		return nil, err
+	}
+	if bookmark, _ := raw["bookmark"].(string); bookmark != "" && bookmark != "nil" {
+		skip, err := decodeBookmark(bookmark)
+		if err != nil {
+			return nil, err
+		}
+		q.skip = skip
+	}
+	return q, nil
+}
+
+func parseSort(v interface{}) ([]sortField, error) {
+	if v == nil {
+		return nil, nil
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.Status(http.StatusBadRequest, "invalid sort: must be an array")
+	}
+	fields := make([]sortField, 0, len(list))
+	for _, item := range list {
+		switch t := item.(type) {
+		case string:
+			fields = append(fields, sortField{field: t})
+		case map[string]interface{}:
+			if len(t) != 1 {
+				return nil, errors.Status(http.StatusBadRequest, "invalid sort: each field must have exactly one direction")
+			}
+			for field, dir := range t {
+				switch dir {
+				case "asc":
+					fields = append(fields, sortField{field: field})
+				case "desc":
+					fields = append(fields, sortField{field: field, desc: true})
+				default:
+					return nil, errors.Statusf(http.StatusBadRequest, "invalid sort direction for %s: %v", field, dir)
+				}
+			}
+		default:
+			return nil, errors.Statusf(http.StatusBadRequest, "invalid sort field: %v", item)
+		}
+	}
+	return fields, nil
+}
+
+// The bookmark is an opaque, encoded form of the number of results already
+// returned.
+
+func encodeBookmark(skip int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(skip, 10)))
+}
+
+func decodeBookmark(bookmark string) (int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(bookmark)
+	if err != nil {
+		return 0, errors.Status(http.StatusBadRequest, "invalid bookmark")
+	}
+	skip, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil || skip < 0 {
+		return 0, errors.Status(http.StatusBadRequest, "invalid bookmark")
+	}
+	return skip, nil
+}
+
+func (d *db) Find(ctx context.Context, query interface{}, _ map[string]interface{}) (driver.Rows, error) {
+	q, err := parseFindQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.database(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matches []map[string]interface{}
+	var indexed bool
+	err = data.store.View(ctx, func(tx storage.Tx) error {
+		indexes, err := tx.Indexes().List()
+		if err != nil {
+			return err
+		}
+		indexed = len(indexes) > 0
+		return tx.Docs().Range(func(info *storage.DocInfo) error {
+			if info.Deleted || strings.HasPrefix(info.ID, designPrefix) {
+				return nil
+			}
+			cur, err := winner(tx, info)
+			if err != nil {
+				return err
+			}
+			body, err := render(tx, info.ID, cur, nil)
+			if err != nil {
+				return err
+			}
+			if q.selector.Match(body) {
+				matches = append(matches, body)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return q.less(matches[i], matches[j])
+	})
+	start := q.skip
+	if start > int64(len(matches)) {
+		start = int64(len(matches))
+	}
+	end := start + q.limit
+	if end > int64(len(matches)) {
+		end = int64(len(matches))
+	}
+	result := &rows{
+		bookmark: encodeBookmark(end),
+	}
+	if !indexed {
+		result.warning = noIndexWarning
+	}
+	for _, doc := range matches[start:end] {
+		id, _ := doc["_id"].(string)
+		body, err := json.Marshal(project(doc, q.fields))
+		if err != nil {
+			return nil, err
+		}
+		result.rows = append(result.rows, &driver.Row{ID: id, Doc: body})
+	}
+	return result, nil
+}
+
+// less orders documents by the requested sort fields, then by ID.
+func (q *findQuery) less(a, b map[string]interface{}) bool {
+	for _, s := range q.sort {
+		av, _ := mango.Field(a, s.field)
+		bv, _ := mango.Field(b, s.field)
+		if c := collate.Compare(av, bv); c != 0 {
+			return (c < 0) != s.desc
+		}
+	}
+	return a["_id"].(string) < b["_id"].(string)
+}
+
+// project returns only the requested fields of doc, or all of doc if fields
+// is empty.
+func project(doc map[string]interface{}, fields []string) map[string]interface{} {
+	if len(fields) == 0 {
+		return doc
+	}
+	out := make(map[string]interface{})
+	for _, field := range fields {
+		value, ok := mango.Field(doc, field)
+		if !ok {
+			continue
+		}
+		path := mango.SplitField(field)
+		target := out
+		for _, name := range path[:len(path)-1] {
+			next, ok := target[name].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				target[name] = next
+			}
+			target = next
+		}
+		target[path[len(path)-1]] = value
+	}
+	return out
+}
+
+func (d *db) CreateIndex(ctx context.Context, ddoc, name string, index interface{}, _ map[string]interface{}) error {
+	def, err := toMap(index)
+	if err != nil {
+		return err
+	}
+	if inner, ok := def["index"].(map[string]interface{}); ok {
+		def = inner
+	}
+	if err := validateIndexFields(def["fields"]); err != nil {
+		return err
+	}
+	data, err := d.database(ctx)
+	if err != nil {
+		return err
+	}
+	if ddoc == "" || name == "" {
+		raw, _ := json.Marshal(def)
+		sum := md5.Sum(raw)
+		hash := hex.EncodeToString(sum[:])
+		if ddoc == "" {
+			ddoc = hash
+		}
+		if name == "" {
+			name = hash
+		}
+	}
+	if !strings.HasPrefix(ddoc, designPrefix) {
+		ddoc = designPrefix + ddoc
+	}
+	return data.store.Update(ctx, func(tx storage.Tx) error {
+		if existing, err := tx.Indexes().Get(ddoc, name); err != nil || existing != nil {
+			return err
+		}
+		return tx.Indexes().Put(&driver.Index{
+			DesignDoc:  ddoc,
+			Name:       name,
+			Type:       "json",
+			Definition: def,
+		})
+	})
+}
+
+// validateIndexFields validates the fields of an index definition, which
+// take the same form as a sort.
+func validateIndexFields(v interface{}) error {
+	fields, err := parseSort(v)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return errors.Status(http.StatusBadRequest, "index definition must include fields")
+	}
+	return nil
+}
+
+// allDocsIndex is the special index which is used when no other index
+// applies.
+var allDocsIndex = driver.Index{
+	Name: "_all_docs",
+	Type: "special",
+	Definition: map[string]interface{}{
+		"fields": []interface{}{
+			map[string]interface{}{"_id": "asc"},
+		},
+	},
+}
+
+func (d *db) GetIndexes(ctx context.Context, _ map[string]interface{}) ([]driver.Index, error) {
+	data, err := d.database(ctx)
+	if err != nil {
+		return nil, err
+	}
+	indexes := []driver.Index{allDocsIndex}
+	err = data.store.View(ctx, func(tx storage.Tx) error {
+		stored, err := tx.Indexes().List()
+		for _, index := range stored {
+			indexes = append(indexes, *index)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return indexes, nil
+}
+
+func (d *db) DeleteIndex(ctx context.Context, ddoc, name string, _ map[string]interface{}) error {
+	data, err := d.database(ctx)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(ddoc, designPrefix) {
+		ddoc = designPrefix + ddoc
+	}
+	return data.store.Update(ctx, func(tx storage.Tx) error {
+		existing, err := tx.Indexes().Get(ddoc, name)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return errors.Status(http.StatusNotFound, "index not found")
+		}
+		return tx.Indexes().Delete(ddoc, name)
+	})
+}
+
+// Explain always reports the _all_docs index, as indexes are recorded but not
+// used to answer queries.
+func (d *db) Explain(ctx context.Context, query interface{}, _ map[string]interface{}) (*driver.QueryPlan, error) {
+	q, err := parseFindQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.database(ctx); err != nil {
+		return nil, err
+	}
+	selector, _ := q.raw["selector"].(map[string]interface{})
+	fields := make([]interface{}, len(q.fields))
+	for i, f := range q.fields {
+		fields[i] = f
+	}
+	return &driver.QueryPlan{
+		DBName: d.name,
+		Index: map[string]interface{}{
+			"ddoc": nil,
+			"name": allDocsIndex.Name,
+			"type": allDocsIndex.Type,
+			"def":  allDocsIndex.Definition,
+		},
+		Selector: selector,
+		Options: map[string]interface{}{
+			"limit":  q.limit,
+			"skip":   q.skip,
+			"fields": fields,
+		},
+		Limit:  q.limit,
+		Skip:   q.skip,
+		Fields: fields,
+	}, nil
+}
@@ -0,0 +1,175 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestFind(t *testing.T) {
+	type tst struct {
+		query    interface{}
+		expected []map[string]interface{}
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("missing selector", tst{
+		query:  map[string]interface{}{},
+		status: http.StatusBadRequest,
+		err:    "missing required key: selector",
+	})
+	tests.Add("invalid selector", tst{
+		query:  `{"selector":{"n":{"$foo":1}}}`,
+		status: http.StatusBadRequest,
+		err:    "invalid selector: unknown operator $foo",
+	})
+	tests.Add("match", tst{
+		query: map[string]interface{}{
+			"selector": map[string]interface{}{"n": map[string]interface{}{"$gt": 1}},
+			"fields":   []string{"_id", "n"},
+		},
+		expected: []map[string]interface{}{
+			{"_id": "b", "n": float64(2)},
+			{"_id": "c", "n": float64(3)},
+		},
+	})
+	tests.Add("sort, skip and limit", tst{
+		query: `{"selector":{},"fields":["_id"],"sort":[{"n":"desc"}],"skip":1,"limit":1}`,
+		expected: []map[string]interface{}{
+			{"_id": "b"},
+		},
+	})
+	tests.Add("nested fields", tst{
+		query: map[string]interface{}{
+			"selector": map[string]interface{}{"sub.x": "y"},
+			"fields":   []string{"sub.x"},
+		},
+		expected: []map[string]interface{}{
+			{"sub": map[string]interface{}{"x": "y"}},
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		db := newDB(t)
+		put(t, db, "a", map[string]interface{}{"n": 1, "sub": map[string]string{"x": "y", "z": "z"}})
+		put(t, db, "b", map[string]interface{}{"n": 2})
+		put(t, db, "c", map[string]interface{}{"n": 3})
+		put(t, db, "_design/foo", map[string]interface{}{"n": 4})
+
+		rows, err := db.Find(context.Background(), tt.query)
+		testy.StatusError(t, tt.err, tt.status, err)
+		result := []map[string]interface{}{}
+		for rows.Next() {
+			var doc map[string]interface{}
+			if err := rows.ScanDoc(&doc); err != nil {
+				t.Fatal(err)
+			}
+			result = append(result, doc)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(tt.expected, result); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestFindBookmark(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	for _, id := range []string{"a", "b", "c"} {
+		put(t, db, id, map[string]string{})
+	}
+	var ids []string
+	var bookmark string
+	for i := 0; i < 3; i++ {
+		rows, err := db.Find(ctx, map[string]interface{}{
+			"selector": map[string]interface{}{},
+			"limit":    2,
+			"bookmark": bookmark,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			ids = append(ids, rows.ID())
+		}
+		if rows.Warning() != noIndexWarning {
+			t.Errorf("Unexpected warning: %s", rows.Warning())
+		}
+		bookmark = rows.Bookmark()
+	}
+	if d := testy.DiffInterface([]string{"a", "b", "c"}, ids); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	if err := db.CreateIndex(ctx, "foo", "bar", map[string]interface{}{"fields": []string{"n"}}); err != nil {
+		t.Fatal(err)
+	}
+	err := db.CreateIndex(ctx, "", "", map[string]interface{}{})
+	testy.StatusError(t, "index definition must include fields", http.StatusBadRequest, err)
+
+	indexes, err := db.GetIndexes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []kivik.Index{
+		{
+			Name: "_all_docs",
+			Type: "special",
+			Definition: map[string]interface{}{
+				"fields": []interface{}{
+					map[string]interface{}{"_id": "asc"},
+				},
+			},
+		},
+		{
+			DesignDoc:  "_design/foo",
+			Name:       "bar",
+			Type:       "json",
+			Definition: map[string]interface{}{"fields": []interface{}{"n"}},
+		},
+	}
+	if d := testy.DiffInterface(expected, indexes); d != nil {
+		t.Error(d)
+	}
+
+	if err := db.DeleteIndex(ctx, "foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+	err = db.DeleteIndex(ctx, "foo", "bar")
+	testy.StatusError(t, "index not found", http.StatusNotFound, err)
+}
+
+func TestExplain(t *testing.T) {
+	db := newDB(t)
+	plan, err := db.Explain(context.Background(), `{"selector":{"n":1},"limit":10}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.DBName != "test" || plan.Index["name"] != "_all_docs" || plan.Limit != 10 {
+		t.Errorf("Unexpected plan: %+v", plan)
+	}
+}
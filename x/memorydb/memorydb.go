@@ -0,0 +1,162 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package memorydb provides a memory-backed Kivik driver, intended for
+// testing. It supports document CRUD with revision tracking, attachments,
+// the changes feed, Mango queries via /_find, map/reduce views implemented
+// in Go (see RegisterView), and read-only point-in-time snapshots (see
+// kivik's DB.Snapshot).
+//
+// To use it, import the package for its side effect of registering the
+// "memory" driver:
+//
+//	import _ "github.com/go-kivik/kivik/v4/x/memorydb"
+//
+//	client, err := kivik.New("memory", "")
+//
+// Each client represents a separate, empty server. The DSN is ignored.
+//
+// The driver keeps its data in a storage.Engine. NewDriver returns a driver
+// which keeps its data in some other engine, such as one which persists it.
+package memorydb // import "github.com/go-kivik/kivik/v4/x/memorydb"
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sync"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/x/storage"
+)
+
+func init() {
+	kivik.Register("memory", NewDriver(func(string) (storage.Engine, error) {
+		return storage.NewMemory(), nil
+	}))
+}
+
+// NewDriver returns a driver which stores its data in the engines returned by
+// open, which is called with the DSN of each new client. Register the driver
+// with kivik.Register to make it available.
+func NewDriver(open func(dsn string) (storage.Engine, error)) driver.Driver {
+	return &engineDriver{open: open}
+}
+
+type engineDriver struct {
+	open func(dsn string) (storage.Engine, error)
+}
+
+var _ driver.Driver = &engineDriver{}
+
+func (d *engineDriver) NewClient(dsn string) (driver.Client, error) {
+	engine, err := d.open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		engine: engine,
+		dbs:    make(map[string]*database),
+	}, nil
+}
+
+type client struct {
+	engine storage.Engine
+
+	mu sync.Mutex
+	// dbs are the databases which have been opened.
+	dbs map[string]*database
+}
+
+var _ driver.Client = &client{}
+
+const vendor = "Kivik Memory Adaptor"
+
+func (c *client) Version(_ context.Context) (*driver.Version, error) {
+	raw, _ := json.Marshal(map[string]interface{}{
+		"couchdb": "Welcome",
+		"version": kivik.KivikVersion,
+		"vendor": map[string]string{
+			"name": vendor,
+		},
+	})
+	return &driver.Version{
+		Version:     kivik.KivikVersion,
+		Vendor:      vendor,
+		RawResponse: raw,
+	}, nil
+}
+
+func (c *client) AllDBs(ctx context.Context, _ map[string]interface{}) ([]string, error) {
+	return c.engine.AllDBs(ctx)
+}
+
+func (c *client) DBExists(ctx context.Context, dbName string, _ map[string]interface{}) (bool, error) {
+	_, err := c.engine.DB(ctx, dbName)
+	if kivik.StatusCode(err) == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// database returns the named database, opening it if necessary, or a status
+// 404 error if it does not exist.
+func (c *client) database(ctx context.Context, dbName string) (*database, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if data, ok := c.dbs[dbName]; ok {
+		return data, nil
+	}
+	store, err := c.engine.DB(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	data := newDatabase(store)
+	c.dbs[dbName] = data
+	return data, nil
+}
+
+var (
+	validDBName = regexp.MustCompile(`^[a-z][a-z0-9_$()+/-]*$`)
+	systemDBs   = map[string]bool{
+		"_users":          true,
+		"_replicator":     true,
+		"_global_changes": true,
+	}
+)
+
+func (c *client) CreateDB(ctx context.Context, dbName string, _ map[string]interface{}) error {
+	if !validDBName.MatchString(dbName) && !systemDBs[dbName] {
+		return errors.Status(http.StatusBadRequest, "invalid database name")
+	}
+	return c.engine.CreateDB(ctx, dbName)
+}
+
+func (c *client) DestroyDB(ctx context.Context, dbName string, _ map[string]interface{}) error {
+	if err := c.engine.DestroyDB(ctx, dbName); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if data, ok := c.dbs[dbName]; ok {
+		data.close()
+		delete(c.dbs, dbName)
+	}
+	return nil
+}
+
+func (c *client) DB(_ context.Context, dbName string, _ map[string]interface{}) (driver.DB, error) {
+	return &db{client: c, name: dbName}, nil
+}
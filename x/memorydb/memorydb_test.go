@@ -0,0 +1,197 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/x/storage"
+)
+
+// newDB returns a new, empty database on a new client.
+func newDB(t *testing.T) *kivik.DB {
+	t.Helper()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateDB(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	return client.DB(context.Background(), "test")
+}
+
+// put stores doc, and fails the test on error.
+func put(t *testing.T, db *kivik.DB, docID string, doc interface{}) string {
+	t.Helper()
+	rev, err := db.Put(context.Background(), docID, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rev
+}
+
+func TestVersion(t *testing.T) {
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := client.Version(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.Vendor != vendor {
+		t.Errorf("Unexpected vendor: %s", version.Vendor)
+	}
+}
+
+func TestCreateDB(t *testing.T) {
+	tests := []struct {
+		name   string
+		dbName string
+		status int
+		err    string
+	}{
+		{
+			name:   "success",
+			dbName: "foo",
+		},
+		{
+			name:   "system db",
+			dbName: "_users",
+		},
+		{
+			name:   "already exists",
+			dbName: "exists",
+			status: http.StatusPreconditionFailed,
+			err:    "database exists",
+		},
+		{
+			name:   "invalid name",
+			dbName: "Foo",
+			status: http.StatusBadRequest,
+			err:    "invalid database name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := kivik.New("memory", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := client.CreateDB(context.Background(), "exists"); err != nil {
+				t.Fatal(err)
+			}
+			err = client.CreateDB(context.Background(), tt.dbName)
+			testy.StatusError(t, tt.err, tt.status, err)
+		})
+	}
+}
+
+func TestDestroyDB(t *testing.T) {
+	ctx := context.Background()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.DestroyDB(ctx, "foo")
+	testy.StatusError(t, "database does not exist", http.StatusNotFound, err)
+	if err := client.CreateDB(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DestroyDB(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	exists, err := client.DBExists(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("Database should not exist after DestroyDB")
+	}
+	_, err = client.DB(ctx, "foo").Stats(ctx)
+	testy.StatusError(t, "database does not exist", http.StatusNotFound, err)
+}
+
+func TestAllDBs(t *testing.T) {
+	ctx := context.Background()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"b", "a", "c"} {
+		if err := client.CreateDB(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbs, err := client.AllDBs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"a", "b", "c"}, dbs); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestNewDriver(t *testing.T) {
+	ctx := context.Background()
+	engine := storage.NewMemory()
+	var dsns []string
+	d := NewDriver(func(dsn string) (storage.Engine, error) {
+		if dsn == "fail" {
+			return nil, errors.Status(http.StatusBadRequest, "bad dsn")
+		}
+		dsns = append(dsns, dsn)
+		return engine, nil
+	})
+	_, err := d.NewClient("fail")
+	testy.StatusError(t, "bad dsn", http.StatusBadRequest, err)
+	c1, err := d.NewClient("one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := d.NewClient("two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"one", "two"}, dsns); d != nil {
+		t.Error(d)
+	}
+	if err := c1.CreateDB(ctx, "foo", nil); err != nil {
+		t.Fatal(err)
+	}
+	db, err := c1.DB(ctx, "foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Put(ctx, "doc", map[string]string{"a": "b"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	db, err = c2.DB(ctx, "foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := db.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.DocCount != 1 {
+		t.Errorf("Expected the document to be visible to the second client, got %d documents", stats.DocCount)
+	}
+}
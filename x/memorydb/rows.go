@@ -0,0 +1,480 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
+	"github.com/go-kivik/kivik/v4/x/storage"
+)
+
+// rows is a driver.Rows backed by a slice.
+type rows struct {
+	rows      []*driver.Row
+	offset    int64
+	totalRows int64
+	updateSeq string
+	bookmark  string
+	warning   string
+}
+
+var (
+	_ driver.Rows       = &rows{}
+	_ driver.Bookmarker = &rows{}
+	_ driver.RowsWarner = &rows{}
+)
+
+func (r *rows) Next(row *driver.Row) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	*row = *r.rows[0]
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func (r *rows) Close() error {
+	r.rows = nil
+	return nil
+}
+
+func (r *rows) Offset() int64     { return r.offset }
+func (r *rows) TotalRows() int64  { return r.totalRows }
+func (r *rows) UpdateSeq() string { return r.updateSeq }
+func (r *rows) Bookmark() string  { return r.bookmark }
+func (r *rows) Warning() string   { return r.warning }
+
+// entry is a single candidate row of a view or /_all_docs result.
+type entry struct {
+	key   interface{}
+	id    string
+	value interface{}
+	// rev is the winning revision of the document, if the entry has one.
+	rev *storage.Revision
+}
+
+// rangeOpts are the options common to /_all_docs and views, which select a
+// range of entries.
+type rangeOpts struct {
+	start, end           interface{}
+	hasStart, hasEnd     bool
+	startID, endID       string
+	hasStartID, hasEndID bool
+	inclusiveEnd         bool
+	descending           bool
+	keys                 []interface{}
+	skip                 int64
+	limit                int64
+	hasLimit             bool
+	includeDocs          bool
+	updateSeq            bool
+	// conflicts is accepted for compatibility. Revision histories in this
+	// driver are linear, so there are never any conflicts to report.
+	conflicts bool
+}
+
+func parseRangeOpts(opts map[string]interface{}) (*rangeOpts, error) {
+	o := &rangeOpts{inclusiveEnd: true}
+	var err error
+	if o.start, o.hasStart, err = queryopts.JSON(opts, "startkey", "start_key"); err != nil {
+		return nil, err
+	}
+	if o.end, o.hasEnd, err = queryopts.JSON(opts, "endkey", "end_key"); err != nil {
+		return nil, err
+	}
+	if key, ok, err := queryopts.JSON(opts, "key"); err != nil {
+		return nil, err
+	} else if ok {
+		o.start, o.end = key, key
+		o.hasStart, o.hasEnd = true, true
+	}
+	if keys, ok, err := queryopts.JSON(opts, "keys"); err != nil {
+		return nil, err
+	} else if ok {
+		list, ok := toList(keys)
+		if !ok {
+			return nil, errors.Status(http.StatusBadRequest, "keys must be an array")
+		}
+		o.keys = list
+	}
+	o.startID, o.hasStartID = opts["startkey_docid"].(string)
+	if !o.hasStartID {
+		o.startID, o.hasStartID = opts["start_key_doc_id"].(string)
+	}
+	o.endID, o.hasEndID = opts["endkey_docid"].(string)
+	if !o.hasEndID {
+		o.endID, o.hasEndID = opts["end_key_doc_id"].(string)
+	}
+	if _, ok := opts["inclusive_end"]; ok {
+		if o.inclusiveEnd, err = queryopts.Bool(opts, "inclusive_end"); err != nil {
+			return nil, err
+		}
+	}
+	if o.descending, err = queryopts.Bool(opts, "descending"); err != nil {
+		return nil, err
+	}
+	if o.skip, _, err = queryopts.Int(opts, "skip"); err != nil {
+		return nil, err
+	}
+	if o.limit, o.hasLimit, err = queryopts.Int(opts, "limit"); err != nil {
+		return nil, err
+	}
+	if o.includeDocs, err = queryopts.Bool(opts, "include_docs"); err != nil {
+		return nil, err
+	}
+	if o.updateSeq, err = queryopts.Bool(opts, "update_seq"); err != nil {
+		return nil, err
+	}
+	if o.conflicts, err = queryopts.Bool(opts, "conflicts"); err != nil {
+		return nil, err
+	}
+	if o.keys != nil && (o.hasStart || o.hasEnd) {
+		return nil, errors.Status(http.StatusBadRequest, "`keys` is incompatible with `key`, `start_key` and `end_key`")
+	}
+	return o, nil
+}
+
+// docIDBounds makes startkey_docid and endkey_docid serve as the start and
+// end keys, when those are not given, as /_all_docs does.
+func (o *rangeOpts) docIDBounds() {
+	if !o.hasStart && o.hasStartID {
+		o.start, o.hasStart, o.hasStartID = o.startID, true, false
+	}
+	if !o.hasEnd && o.hasEndID {
+		o.end, o.hasEnd, o.hasEndID = o.endID, true, false
+	}
+}
+
+// checkRange returns an error if the start and end bounds are given in the
+// wrong order for the requested direction, as no rows could match.
+func (o *rangeOpts) checkRange(cmp func(a, b interface{}) int) error {
+	if !o.hasStart || !o.hasEnd {
+		return nil
+	}
+	c := cmp(o.start, o.end)
+	if c == 0 && o.hasStartID && o.hasEndID {
+		c = strings.Compare(o.startID, o.endID)
+	}
+	if o.descending {
+		c = -c
+	}
+	if c > 0 {
+		return errors.Status(http.StatusBadRequest, "No rows can match your key range, reverse your start_key and end_key or set descending=true")
+	}
+	return nil
+}
+
+// toList converts v to a []interface{}, if it is a slice.
+func toList(v interface{}) ([]interface{}, bool) {
+	switch t := v.(type) {
+	case []interface{}:
+		return t, true
+	case []string:
+		list := make([]interface{}, len(t))
+		for i, s := range t {
+			list[i] = s
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+// bound compares e to the range bound given by key and id.
+func bound(cmp func(a, b interface{}) int, e *entry, key interface{}, id string, hasID bool) int {
+	if c := cmp(e.key, key); c != 0 || !hasID {
+		return c
+	}
+	return strings.Compare(e.id, id)
+}
+
+// afterStart reports whether e falls on the correct side of the start bound.
+func (o *rangeOpts) afterStart(cmp func(a, b interface{}) int, e *entry) bool {
+	if !o.hasStart {
+		return true
+	}
+	c := bound(cmp, e, o.start, o.startID, o.hasStartID)
+	if o.descending {
+		return c <= 0
+	}
+	return c >= 0
+}
+
+// beforeEnd reports whether e falls on the correct side of the end bound.
+func (o *rangeOpts) beforeEnd(cmp func(a, b interface{}) int, e *entry) bool {
+	if !o.hasEnd {
+		return true
+	}
+	c := bound(cmp, e, o.end, o.endID, o.hasEndID)
+	if o.descending {
+		c = -c
+	}
+	return c < 0 || (c == 0 && o.inclusiveEnd)
+}
+
+// filter returns the entries, which must be sorted in ascending order, which
+// fall within the selected range, in the requested order, along with the
+// offset of the first selected entry.
+func (o *rangeOpts) filter(cmp func(a, b interface{}) int, entries []*entry) ([]*entry, int64) {
+	if o.keys != nil {
+		var result []*entry
+		for _, key := range o.keys {
+			for _, e := range entries {
+				if cmp(e.key, key) == 0 {
+					result = append(result, e)
+				}
+			}
+		}
+		return result, 0
+	}
+	ordered := entries
+	if o.descending {
+		ordered = make([]*entry, len(entries))
+		for i, e := range entries {
+			ordered[len(entries)-1-i] = e
+		}
+	}
+	var offset int64
+	result := make([]*entry, 0, len(ordered))
+	for _, e := range ordered {
+		if !o.afterStart(cmp, e) {
+			offset++
+			continue
+		}
+		if !o.beforeEnd(cmp, e) {
+			break
+		}
+		result = append(result, e)
+	}
+	return result, offset
+}
+
+// page applies skip and limit to entries.
+func (o *rangeOpts) page(entries []*entry) []*entry {
+	if o.skip >= int64(len(entries)) {
+		return nil
+	}
+	entries = entries[o.skip:]
+	if o.hasLimit && o.limit < int64(len(entries)) {
+		entries = entries[:o.limit]
+	}
+	return entries
+}
+
+// row converts e to a result row.
+func (e *entry) row(tx storage.Tx, includeDocs bool) (*driver.Row, error) {
+	key, err := json.Marshal(e.key)
+	if err != nil {
+		return nil, err
+	}
+	value, err := json.Marshal(e.value)
+	if err != nil {
+		return nil, err
+	}
+	row := &driver.Row{
+		ID:    e.id,
+		Key:   key,
+		Value: value,
+	}
+	if includeDocs {
+		row.Doc = json.RawMessage("null")
+		if !e.rev.Deleted {
+			doc, err := render(tx, e.id, e.rev, nil)
+			if err != nil {
+				return nil, err
+			}
+			if row.Doc, err = json.Marshal(doc); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return row, nil
+}
+
+// compareIDs compares document IDs in raw byte order, as /_all_docs does.
+func compareIDs(a, b interface{}) int {
+	as, _ := a.(string)
+	bs, _ := b.(string)
+	return strings.Compare(as, bs)
+}
+
+func (d *db) AllDocs(ctx context.Context, options map[string]interface{}) (driver.Rows, error) {
+	return d.allDocs(ctx, options, func(id string) bool {
+		return !strings.HasPrefix(id, localPrefix)
+	})
+}
+
+func (d *db) DesignDocs(ctx context.Context, options map[string]interface{}) (driver.Rows, error) {
+	return d.allDocs(ctx, options, func(id string) bool {
+		return strings.HasPrefix(id, designPrefix)
+	})
+}
+
+func (d *db) allDocs(ctx context.Context, options map[string]interface{}, include func(id string) bool) (driver.Rows, error) {
+	opts, err := parseRangeOpts(options)
+	if err != nil {
+		return nil, err
+	}
+	opts.docIDBounds()
+	if err := opts.checkRange(compareIDs); err != nil {
+		return nil, err
+	}
+	data, err := d.database(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &rows{}
+	err = data.store.View(ctx, func(tx storage.Tx) error {
+		var entries []*entry
+		byID := make(map[string]*entry)
+		err := tx.Docs().Range(func(info *storage.DocInfo) error {
+			if !include(info.ID) {
+				return nil
+			}
+			cur, err := winner(tx, info)
+			if err != nil {
+				return err
+			}
+			value := map[string]interface{}{"rev": cur.Rev}
+			if cur.Deleted {
+				value["deleted"] = true
+			}
+			e := &entry{key: info.ID, id: info.ID, value: value, rev: cur}
+			byID[info.ID] = e
+			if !cur.Deleted {
+				entries = append(entries, e)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		result.totalRows = int64(len(entries))
+		if opts.updateSeq {
+			seq, err := tx.Docs().Seq()
+			if err != nil {
+				return err
+			}
+			result.updateSeq = strconv.FormatInt(seq, 10)
+		}
+		if opts.keys != nil {
+			return result.appendKeys(tx, opts, byID)
+		}
+		selected, offset := opts.filter(compareIDs, entries)
+		result.offset = offset + opts.skip
+		return result.appendEntries(tx, opts, opts.page(selected))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// appendKeys adds the rows of a keys query to r. Unlike a range query, a
+// keys query includes deleted and missing documents in the result. The keys
+// are returned in the order given, or in reverse order when descending.
+func (r *rows) appendKeys(tx storage.Tx, opts *rangeOpts, byID map[string]*entry) error {
+	keys := keysAsEntries(opts.keys)
+	if opts.descending {
+		for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+	for _, key := range opts.page(keys) {
+		id, _ := key.key.(string)
+		e, ok := byID[id]
+		if !ok {
+			r.rows = append(r.rows, &driver.Row{
+				Key:   json.RawMessage(strconv.Quote(id)),
+				Error: errors.Status(http.StatusNotFound, "not_found"),
+			})
+			continue
+		}
+		if err := r.appendEntries(tx, opts, []*entry{e}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendEntries adds a row to r for each of entries.
+func (r *rows) appendEntries(tx storage.Tx, opts *rangeOpts, entries []*entry) error {
+	for _, e := range entries {
+		row, err := e.row(tx, opts.includeDocs)
+		if err != nil {
+			return err
+		}
+		r.rows = append(r.rows, row)
+	}
+	return nil
+}
+
+func keysAsEntries(keys []interface{}) []*entry {
+	entries := make([]*entry, len(keys))
+	for i, key := range keys {
+		entries[i] = &entry{key: key}
+	}
+	return entries
+}
+
+func (d *db) LocalDocs(ctx context.Context, options map[string]interface{}) (driver.Rows, error) {
+	opts, err := parseRangeOpts(options)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.database(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*entry
+	docs := make(map[string]*storage.LocalDoc)
+	err = data.store.View(ctx, func(tx storage.Tx) error {
+		return tx.Local().Range(func(doc *storage.LocalDoc) error {
+			docs[doc.ID] = doc
+			entries = append(entries, &entry{
+				key:   doc.ID,
+				id:    doc.ID,
+				value: map[string]interface{}{"rev": localRev(doc.Rev)},
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	selected, offset := opts.filter(compareIDs, entries)
+	result := &rows{
+		offset:    offset + opts.skip,
+		totalRows: int64(len(entries)),
+	}
+	for _, e := range opts.page(selected) {
+		row := &driver.Row{ID: e.id}
+		row.Key, _ = json.Marshal(e.key)
+		row.Value, _ = json.Marshal(e.value)
+		if opts.includeDocs {
+			body := copyMap(docs[e.id].Body)
+			body["_id"] = e.id
+			body["_rev"] = localRev(docs[e.id].Rev)
+			row.Doc, _ = json.Marshal(body)
+		}
+		result.rows = append(result.rows, row)
+	}
+	return result, nil
+}
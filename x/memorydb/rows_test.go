@@ -0,0 +1,212 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// rowIDs returns the IDs of all rows, and fails the test on error.
+func rowIDs(t *testing.T, rows *kivik.Rows, err error) []string {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for rows.Next() {
+		ids = append(ids, rows.ID())
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestAllDocs(t *testing.T) {
+	type tst struct {
+		options  kivik.Options
+		expected []string
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("all", tst{
+		expected: []string{"Z", "_design/foo", "a", "b", "c"},
+	})
+	tests.Add("descending", tst{
+		options:  kivik.Options{"descending": true},
+		expected: []string{"c", "b", "a", "_design/foo", "Z"},
+	})
+	tests.Add("range", tst{
+		options:  kivik.Options{"startkey": "a", "endkey": "b"},
+		expected: []string{"a", "b"},
+	})
+	tests.Add("raw JSON range", tst{
+		options:  kivik.Options{"start_key": []byte(`"a"`), "end_key": []byte(`"b"`), "inclusive_end": "false"},
+		expected: []string{"a"},
+	})
+	tests.Add("descending range", tst{
+		options:  kivik.Options{"startkey": "b", "endkey": "a", "descending": true},
+		expected: []string{"b", "a"},
+	})
+	tests.Add("key", tst{
+		options:  kivik.Options{"key": "b"},
+		expected: []string{"b"},
+	})
+	tests.Add("keys", tst{
+		options:  kivik.Options{"keys": []string{"c", "a"}},
+		expected: []string{"c", "a"},
+	})
+	tests.Add("skip and limit", tst{
+		options:  kivik.Options{"skip": 1, "limit": 2},
+		expected: []string{"_design/foo", "a"},
+	})
+	tests.Add("exclusive end", tst{
+		options:  kivik.Options{"startkey": "a", "endkey": "c", "inclusive_end": false},
+		expected: []string{"a", "b"},
+	})
+	tests.Add("descending exclusive end", tst{
+		options:  kivik.Options{"startkey": "c", "endkey": "a", "descending": true, "inclusive_end": false},
+		expected: []string{"c", "b"},
+	})
+	tests.Add("startkey_docid without startkey", tst{
+		options:  kivik.Options{"startkey_docid": "a"},
+		expected: []string{"a", "b", "c"},
+	})
+	tests.Add("endkey_docid without endkey", tst{
+		options:  kivik.Options{"end_key_doc_id": "_design/foo"},
+		expected: []string{"Z", "_design/foo"},
+	})
+	tests.Add("descending keys", tst{
+		options:  kivik.Options{"keys": []string{"a", "c", "b"}, "descending": true},
+		expected: []string{"b", "c", "a"},
+	})
+	tests.Add("raw JSON keys", tst{
+		options:  kivik.Options{"keys": []byte(`["b","a"]`), "limit": 1},
+		expected: []string{"b"},
+	})
+	tests.Add("keys with deleted", tst{
+		options:  kivik.Options{"keys": []string{"deleted", "a"}},
+		expected: []string{"deleted", "a"},
+	})
+	tests.Add("conflicts", tst{
+		options:  kivik.Options{"conflicts": true, "include_docs": true, "key": "a"},
+		expected: []string{"a"},
+	})
+	tests.Add("invalid conflicts", tst{
+		options: kivik.Options{"conflicts": "maybe"},
+		status:  http.StatusBadRequest,
+		err:     `invalid value for conflicts: "maybe"`,
+	})
+	tests.Add("keys with key", tst{
+		options: kivik.Options{"keys": []string{"a"}, "key": "a"},
+		status:  http.StatusBadRequest,
+		err:     "`keys` is incompatible with `key`, `start_key` and `end_key`",
+	})
+	tests.Add("reversed range", tst{
+		options: kivik.Options{"startkey": "c", "endkey": "a"},
+		status:  http.StatusBadRequest,
+		err:     "No rows can match your key range, reverse your start_key and end_key or set descending=true",
+	})
+	tests.Add("reversed descending range", tst{
+		options: kivik.Options{"startkey": "a", "endkey": "c", "descending": true},
+		status:  http.StatusBadRequest,
+		err:     "No rows can match your key range, reverse your start_key and end_key or set descending=true",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		db := newDB(t)
+		for _, id := range []string{"b", "a", "Z", "c", "_design/foo", "_local/foo"} {
+			put(t, db, id, map[string]string{})
+		}
+		rev := put(t, db, "deleted", map[string]string{})
+		if _, err := db.Delete(context.Background(), "deleted", rev); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := db.AllDocs(context.Background(), tt.options)
+		if tt.err != "" || err != nil {
+			testy.StatusError(t, tt.err, tt.status, err)
+		}
+		if d := testy.DiffInterface(tt.expected, rowIDs(t, rows, err)); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestAllDocsMeta(t *testing.T) {
+	db := newDB(t)
+	for _, id := range []string{"a", "b", "c"} {
+		put(t, db, id, map[string]string{"id": id})
+	}
+	rows, err := db.AllDocs(context.Background(), kivik.Options{
+		"startkey":     "b",
+		"include_docs": true,
+		"update_seq":   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var docs []map[string]interface{}
+	for rows.Next() {
+		var doc map[string]interface{}
+		if err := rows.ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		delete(doc, "_rev")
+		docs = append(docs, doc)
+	}
+	expected := []map[string]interface{}{
+		{"_id": "b", "id": "b"},
+		{"_id": "c", "id": "c"},
+	}
+	if d := testy.DiffInterface(expected, docs); d != nil {
+		t.Error(d)
+	}
+	if rows.Offset() != 1 {
+		t.Errorf("Unexpected offset: %d", rows.Offset())
+	}
+	if rows.TotalRows() != 3 {
+		t.Errorf("Unexpected total rows: %d", rows.TotalRows())
+	}
+	if rows.UpdateSeq() != "3" {
+		t.Errorf("Unexpected update seq: %s", rows.UpdateSeq())
+	}
+}
+
+func TestDesignDocs(t *testing.T) {
+	db := newDB(t)
+	for _, id := range []string{"a", "_design/b", "_design/a"} {
+		put(t, db, id, map[string]string{})
+	}
+	rows, err := db.DesignDocs(context.Background())
+	if d := testy.DiffInterface([]string{"_design/a", "_design/b"}, rowIDs(t, rows, err)); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestLocalDocs(t *testing.T) {
+	db := newDB(t)
+	for _, id := range []string{"a", "_local/b", "_local/a"} {
+		put(t, db, id, map[string]string{})
+	}
+	rows, err := db.LocalDocs(context.Background())
+	if d := testy.DiffInterface([]string{"_local/a", "_local/b"}, rowIDs(t, rows, err)); d != nil {
+		t.Error(d)
+	}
+}
@@ -0,0 +1,55 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/x/storage"
+)
+
+var _ driver.Snapshotter = &db{}
+
+var errSnapshotReadOnly = errors.Status(http.StatusForbidden, "snapshot is read-only")
+
+// readOnly is the storage of a snapshot, which rejects all updates.
+type readOnly struct {
+	storage.DB
+}
+
+func (readOnly) Update(context.Context, func(storage.Tx) error) error {
+	return errSnapshotReadOnly
+}
+
+// Snapshot copies the current contents of the database, and returns a
+// read-only handle to the copy. Writes through the handle fail with status
+// 403. The copy shares document content with the database, so taking a
+// snapshot is proportional to the number of documents, not their size.
+func (d *db) Snapshot(ctx context.Context) (driver.DB, error) {
+	data, err := d.database(ctx)
+	if err != nil {
+		return nil, err
+	}
+	store, err := storage.Snapshot(ctx, data.store)
+	if err != nil {
+		return nil, err
+	}
+	return &db{
+		client:   d.client,
+		name:     d.name,
+		snapshot: newDatabase(readOnly{store}),
+	}, nil
+}
@@ -0,0 +1,126 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+	rev := put(t, db, "foo", map[string]string{"a": "b"})
+	if err := db.PutLocal(ctx, "checkpoint", map[string]int{"seq": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep writing while the snapshot is taken and read.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if _, err := db.Put(ctx, fmt.Sprintf("doc%d", i), map[string]int{"i": i}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	snap, err := db.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := snap.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := stats.DocCount
+	if _, err := db.Put(ctx, "foo", map[string]string{"_rev": rev, "a": "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PutLocal(ctx, "checkpoint", map[string]int{"seq": 2}); err != nil {
+		t.Fatal(err)
+	}
+	close(stop)
+	wg.Wait()
+	put(t, db, "bar", map[string]string{})
+
+	var doc map[string]string
+	if err := snap.Get(ctx, "foo").ScanDoc(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["_rev"] != rev || doc["a"] != "b" {
+		t.Errorf("Unexpected document in snapshot: %v", doc)
+	}
+	var local map[string]interface{}
+	if err := snap.GetLocal(ctx, "checkpoint").ScanDoc(&local); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(local["seq"]) != "1" {
+		t.Errorf("Unexpected local document in snapshot: %v", local)
+	}
+	rows, err := snap.AllDocs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids int64
+	for rows.Next() {
+		ids++
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if ids != count {
+		t.Errorf("AllDocs returned %d documents, Stats %d", ids, count)
+	}
+	stats, err = snap.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.DocCount != count {
+		t.Errorf("Snapshot document count changed from %d to %d", count, stats.DocCount)
+	}
+	if stats, err := db.Stats(ctx); err != nil || stats.DocCount <= count {
+		t.Errorf("Database document count %v not above snapshot's %d: %v", stats, count, err)
+	}
+
+	_, err = snap.Put(ctx, "bar", map[string]string{})
+	testy.StatusError(t, "snapshot is read-only", http.StatusForbidden, err)
+	err = snap.PutLocal(ctx, "checkpoint", map[string]int{"seq": 3})
+	testy.StatusError(t, "snapshot is read-only", http.StatusForbidden, err)
+	err = snap.Compact(ctx)
+	testy.StatusError(t, "snapshot is read-only", http.StatusForbidden, err)
+}
+
+func TestSnapshotMissingDB(t *testing.T) {
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.DB(context.Background(), "missing").Snapshot(context.Background())
+	testy.StatusError(t, "database does not exist", http.StatusNotFound, err)
+}
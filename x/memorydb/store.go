@@ -0,0 +1,240 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/x/storage"
+)
+
+// database is an open database: its storage, and the state used to wake up
+// changes feeds waiting for updates.
+type database struct {
+	store storage.DB
+
+	mu     sync.Mutex
+	closed bool
+	// updated is closed, and replaced, whenever a document changes, to wake
+	// up any waiting changes feeds.
+	updated chan struct{}
+}
+
+func newDatabase(store storage.DB) *database {
+	return &database{
+		store:   store,
+		updated: make(chan struct{}),
+	}
+}
+
+// notify wakes up any goroutines waiting for updates.
+func (d *database) notify() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	close(d.updated)
+	d.updated = make(chan struct{})
+}
+
+// watch returns a channel which is closed at the next update, and whether the
+// database has been destroyed. It must be called before reading the state to
+// be watched, so that no update is missed.
+func (d *database) watch() (<-chan struct{}, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.updated, d.closed
+}
+
+// close marks the database as destroyed, and wakes up any waiting changes
+// feeds.
+func (d *database) close() {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+	d.notify()
+}
+
+// current returns the summary and winning revision of the document, or nils
+// if it does not exist.
+func current(tx storage.Tx, id string) (*storage.DocInfo, *storage.Revision, error) {
+	info, err := tx.Docs().Get(id)
+	if err != nil || info == nil {
+		return nil, nil, err
+	}
+	r, err := winner(tx, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	return info, r, nil
+}
+
+// winner returns the winning revision of the document summarized by info.
+func winner(tx storage.Tx, info *storage.DocInfo) (*storage.Revision, error) {
+	r, err := tx.Revs().Get(info.ID, info.Rev)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, errors.Statusf(http.StatusInternalServerError, "revision %s of %s is missing from storage", info.Rev, info.ID)
+	}
+	return r, nil
+}
+
+func generation(rev string) int64 {
+	gen, _ := strconv.ParseInt(strings.SplitN(rev, "-", 2)[0], 10, 64)
+	return gen
+}
+
+func compacted(r *storage.Revision) bool {
+	return r.Body == nil
+}
+
+// putAttachment stores the attachment content, and returns its metadata.
+func putAttachment(tx storage.Tx, contentType string, data []byte) (*storage.Attachment, error) {
+	sum := md5.Sum(data)
+	att := &storage.Attachment{
+		ContentType: contentType,
+		Digest:      "md5-" + base64.StdEncoding.EncodeToString(sum[:]),
+		Length:      int64(len(data)),
+	}
+	return att, tx.Attachments().Put(att.Digest, data)
+}
+
+var errConflict = errors.Status(http.StatusConflict, "document update conflict")
+
+// update stores a new revision of the document id. rev must match the current
+// revision, unless the document does not exist or is deleted. build is called
+// with the current revision, which may be nil, and must return the content of
+// the new revision, storing any new attachment content. Attachments with a
+// zero revpos are assigned the new revision's generation. update returns the
+// new revision ID.
+func (d *database) update(ctx context.Context, id, rev string, build func(tx storage.Tx, cur *storage.Revision) (*storage.Revision, error)) (string, error) {
+	var newRev string
+	err := d.store.Update(ctx, func(tx storage.Tx) error {
+		_, cur, err := current(tx, id)
+		if err != nil {
+			return err
+		}
+		switch {
+		case cur == nil && rev != "":
+			return errConflict
+		case cur != nil && !cur.Deleted && cur.Rev != rev:
+			return errConflict
+		case cur != nil && cur.Deleted && rev != "" && cur.Rev != rev:
+			return errConflict
+		}
+		r, err := build(tx, cur)
+		if err != nil {
+			return err
+		}
+		var gen int64 = 1
+		if cur != nil {
+			gen = generation(cur.Rev) + 1
+			r.Parent = cur.Rev
+		}
+		for _, att := range r.Attachments {
+			if att.RevPos == 0 {
+				att.RevPos = gen
+			}
+		}
+		r.Rev = revID(gen, r)
+		if err := tx.Revs().Put(id, r); err != nil {
+			return err
+		}
+		seq, err := tx.Docs().NextSeq()
+		if err != nil {
+			return err
+		}
+		newRev = r.Rev
+		return tx.Docs().Put(&storage.DocInfo{
+			ID:      id,
+			Rev:     r.Rev,
+			Deleted: r.Deleted,
+			Seq:     seq,
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	d.notify()
+	return newRev, nil
+}
+
+// revID calculates a deterministic revision ID for r, which is of generation
+// gen.
+func revID(gen int64, r *storage.Revision) string {
+	digests := make([]string, 0, len(r.Attachments))
+	for name, att := range r.Attachments {
+		digests = append(digests, name+":"+att.Digest)
+	}
+	sort.Strings(digests)
+	data, _ := json.Marshal([]interface{}{r.Parent, r.Deleted, r.Body, digests})
+	sum := md5.Sum(data)
+	return fmt.Sprintf("%d-%s", gen, hex.EncodeToString(sum[:]))
+}
+
+// newUUID returns a random document ID.
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// toMap converts doc to a generic JSON object.
+func toMap(doc interface{}) (map[string]interface{}, error) {
+	var data []byte
+	switch t := doc.(type) {
+	case []byte:
+		data = t
+	case json.RawMessage:
+		data = t
+	default:
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, errors.WrapStatus(http.StatusBadRequest, err)
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, errors.WrapStatus(http.StatusBadRequest, err)
+	}
+	if m == nil {
+		return nil, errors.Status(http.StatusBadRequest, "document must be a JSON object")
+	}
+	return m, nil
+}
+
+// copyMap returns a deep copy of m.
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	c, _ := toMap(m)
+	return c
+}
@@ -0,0 +1,288 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+	"github.com/go-kivik/kivik/v4/internal/collate"
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
+	"github.com/go-kivik/kivik/v4/x/storage"
+)
+
+// MapFunc is the map function of a view. It is called once for each
+// non-deleted, non-design document in the database, with the document
+// (including its _id and _rev fields), and may call emit any number of times.
+type MapFunc func(doc map[string]interface{}, emit func(key, value interface{}))
+
+// ReduceFunc is the reduce function of a view. It is called with the keys and
+// values of each group of rows to be reduced, and returns the reduced value.
+type ReduceFunc func(keys, values []interface{}) (interface{}, error)
+
+// View is a map/reduce view implemented in Go. Reduce is optional.
+type View struct {
+	Map    MapFunc
+	Reduce ReduceFunc
+}
+
+var (
+	viewsMu sync.RWMutex
+	views   = make(map[string]View)
+)
+
+// RegisterView makes a view available to all memory databases, as view name
+// in design document ddoc. The design document need not exist in the
+// database. ddoc may be given with or without the "_design/" prefix.
+//
+// If RegisterView is called twice for the same view, or if view.Map is nil, it
+// panics.
+func RegisterView(ddoc, name string, view View) {
+	if view.Map == nil {
+		panic("memorydb: RegisterView view has no map function")
+	}
+	key := viewKey(ddoc, name)
+	viewsMu.Lock()
+	defer viewsMu.Unlock()
+	if _, dup := views[key]; dup {
+		panic("memorydb: RegisterView called twice for view " + key)
+	}
+	views[key] = view
+}
+
+func viewKey(ddoc, name string) string {
+	return strings.TrimPrefix(ddoc, designPrefix) + "/" + name
+}
+
+func lookupView(ddoc, name string) (View, bool) {
+	viewsMu.RLock()
+	defer viewsMu.RUnlock()
+	view, ok := views[viewKey(ddoc, name)]
+	return view, ok
+}
+
+// Count is a ReduceFunc which counts the rows, like CouchDB's built-in _count.
+func Count(_, values []interface{}) (interface{}, error) {
+	return len(values), nil
+}
+
+// Sum is a ReduceFunc which sums the numeric values, like CouchDB's built-in
+// _sum.
+func Sum(_, values []interface{}) (interface{}, error) {
+	var sum float64
+	for _, v := range values {
+		n, ok := v.(json.Number)
+		if !ok {
+			return nil, errors.Statusf(http.StatusInternalServerError, "the _sum function requires that map values be numbers, got %v", v)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return nil, errors.WrapStatus(http.StatusInternalServerError, err)
+		}
+		sum += f
+	}
+	return json.Number(strconv.FormatFloat(sum, 'f', -1, 64)), nil
+}
+
+func compareEntries(a, b *entry) bool {
+	if c := collate.Compare(a.key, b.key); c != 0 {
+		return c < 0
+	}
+	return a.id < b.id
+}
+
+type viewOpts struct {
+	*rangeOpts
+	reduce     bool
+	group      bool
+	groupLevel int64
+	hasLevel   bool
+}
+
+func parseViewOpts(view View, options map[string]interface{}) (*viewOpts, error) {
+	ropts, err := parseRangeOpts(options)
+	if err != nil {
+		return nil, err
+	}
+	opts := &viewOpts{
+		rangeOpts: ropts,
+		reduce:    view.Reduce != nil,
+	}
+	if _, ok := options["reduce"]; ok {
+		if opts.reduce, err = queryopts.Bool(options, "reduce"); err != nil {
+			return nil, err
+		}
+		if opts.reduce && view.Reduce == nil {
+			return nil, errors.Status(http.StatusBadRequest, "reduce is invalid for map-only views")
+		}
+	}
+	if opts.group, err = queryopts.Bool(options, "group"); err != nil {
+		return nil, err
+	}
+	if opts.groupLevel, opts.hasLevel, err = queryopts.Int(options, "group_level"); err != nil {
+		return nil, err
+	}
+	if opts.reduce && opts.includeDocs {
+		return nil, errors.Status(http.StatusBadRequest, "include_docs is invalid for reduce")
+	}
+	if !opts.reduce && (opts.group || opts.hasLevel) {
+		return nil, errors.Status(http.StatusBadRequest, "group and group_level are invalid when reduce=false")
+	}
+	if opts.reduce && opts.keys != nil && !opts.group && !opts.hasLevel {
+		return nil, errors.Status(http.StatusBadRequest, "multi-key fetches for reduce views must use group=true")
+	}
+	if err := opts.checkRange(collate.Compare); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+func (d *db) Query(ctx context.Context, ddoc, name string, options map[string]interface{}) (driver.Rows, error) {
+	view, ok := lookupView(ddoc, name)
+	if !ok {
+		return nil, errors.Statusf(http.StatusNotFound, "missing named view %s", viewKey(ddoc, name))
+	}
+	opts, err := parseViewOpts(view, options)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.database(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &rows{}
+	err = data.store.View(ctx, func(tx storage.Tx) error {
+		entries, updateSeq, err := mapView(tx, view.Map)
+		if err != nil {
+			return err
+		}
+		if opts.updateSeq {
+			result.updateSeq = updateSeq
+		}
+		selected, offset := opts.filter(collate.Compare, entries)
+		if opts.reduce {
+			if selected, err = reduce(view.Reduce, opts, selected); err != nil {
+				return err
+			}
+		} else {
+			result.offset = offset + opts.skip
+			result.totalRows = int64(len(entries))
+		}
+		return result.appendEntries(tx, opts.rangeOpts, opts.page(selected))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// mapView runs the map function against every document in the database, and
+// returns the emitted entries, sorted by key and document ID, along with the
+// database's current update sequence.
+func mapView(tx storage.Tx, mapFn MapFunc) (entries []*entry, updateSeq string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Status(http.StatusInternalServerError, fmt.Sprintf("map function failed: %v", r))
+		}
+	}()
+	err = tx.Docs().Range(func(info *storage.DocInfo) error {
+		if info.Deleted || strings.HasPrefix(info.ID, designPrefix) {
+			return nil
+		}
+		cur, err := winner(tx, info)
+		if err != nil {
+			return err
+		}
+		body, err := render(tx, info.ID, cur, nil)
+		if err != nil {
+			return err
+		}
+		mapFn(body, func(key, value interface{}) {
+			entries = append(entries, &entry{
+				key:   collate.Normalize(key),
+				id:    info.ID,
+				value: collate.Normalize(value),
+				rev:   cur,
+			})
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return compareEntries(entries[i], entries[j])
+	})
+	seq, err := tx.Docs().Seq()
+	if err != nil {
+		return nil, "", err
+	}
+	return entries, strconv.FormatInt(seq, 10), nil
+}
+
+// groupKey returns the key by which e is grouped.
+func (o *viewOpts) groupKey(e *entry) interface{} {
+	switch {
+	case o.hasLevel:
+		if list, ok := e.key.([]interface{}); ok && int64(len(list)) > o.groupLevel {
+			return list[:o.groupLevel]
+		}
+		return e.key
+	case o.group:
+		return e.key
+	}
+	return nil
+}
+
+// reduce groups the entries, which must be in order, by their group key, and
+// returns one reduced entry per group.
+func reduce(reduceFn ReduceFunc, opts *viewOpts, entries []*entry) ([]*entry, error) {
+	var result []*entry
+	var keys, values []interface{}
+	flush := func(groupKey interface{}) error {
+		if len(keys) == 0 {
+			return nil
+		}
+		value, err := reduceFn(keys, values)
+		if err != nil {
+			return err
+		}
+		result = append(result, &entry{key: groupKey, value: collate.Normalize(value)})
+		keys, values = nil, nil
+		return nil
+	}
+	var current interface{}
+	for i, e := range entries {
+		key := opts.groupKey(e)
+		if i > 0 && collate.Compare(key, current) != 0 {
+			if err := flush(current); err != nil {
+				return nil, err
+			}
+		}
+		current = key
+		keys = append(keys, []interface{}{e.key, e.id})
+		values = append(values, e.value)
+	}
+	if err := flush(current); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,198 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package memorydb
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func init() {
+	RegisterView("test", "by_tag", View{
+		Map: func(doc map[string]interface{}, emit func(key, value interface{})) {
+			tags, _ := doc["tags"].([]interface{})
+			for _, tag := range tags {
+				emit(tag, doc["n"])
+			}
+		},
+		Reduce: Sum,
+	})
+	RegisterView("_design/test", "by_type_tag", View{
+		Map: func(doc map[string]interface{}, emit func(key, value interface{})) {
+			tags, _ := doc["tags"].([]interface{})
+			for _, tag := range tags {
+				emit([]interface{}{doc["type"], tag}, nil)
+			}
+		},
+		Reduce: Count,
+	})
+	RegisterView("test", "map_only", View{
+		Map: func(doc map[string]interface{}, emit func(key, value interface{})) {
+			emit(doc["_id"], nil)
+		},
+	})
+}
+
+func TestRegisterView(t *testing.T) {
+	t.Run("duplicate", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r != "memorydb: RegisterView called twice for view test/by_tag" {
+				t.Errorf("Unexpected panic: %v", r)
+			}
+		}()
+		RegisterView("_design/test", "by_tag", View{Map: func(map[string]interface{}, func(_, _ interface{})) {}})
+	})
+	t.Run("no map", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r != "memorydb: RegisterView view has no map function" {
+				t.Errorf("Unexpected panic: %v", r)
+			}
+		}()
+		RegisterView("test", "no_map", View{})
+	})
+}
+
+type viewRow struct {
+	ID    string
+	Key   interface{}
+	Value interface{}
+}
+
+func TestQuery(t *testing.T) {
+	type tst struct {
+		view     string
+		options  kivik.Options
+		expected []viewRow
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("missing view", tst{
+		view:   "missing",
+		status: http.StatusNotFound,
+		err:    "missing named view test/missing",
+	})
+	tests.Add("map", tst{
+		view:    "by_tag",
+		options: kivik.Options{"reduce": false},
+		expected: []viewRow{
+			{ID: "a", Key: "Blue", Value: float64(1)},
+			{ID: "b", Key: "Blue", Value: float64(2)},
+			{ID: "a", Key: "red", Value: float64(1)},
+			{ID: "c", Key: "red", Value: float64(3)},
+		},
+	})
+	tests.Add("key", tst{
+		view:    "by_tag",
+		options: kivik.Options{"reduce": "false", "key": "red"},
+		expected: []viewRow{
+			{ID: "a", Key: "red", Value: float64(1)},
+			{ID: "c", Key: "red", Value: float64(3)},
+		},
+	})
+	tests.Add("descending with docid", tst{
+		view: "by_tag",
+		options: kivik.Options{
+			"reduce":         false,
+			"descending":     true,
+			"startkey":       "red",
+			"startkey_docid": "a",
+		},
+		expected: []viewRow{
+			{ID: "a", Key: "red", Value: float64(1)},
+			{ID: "b", Key: "Blue", Value: float64(2)},
+			{ID: "a", Key: "Blue", Value: float64(1)},
+		},
+	})
+	tests.Add("reduce", tst{
+		view: "by_tag",
+		expected: []viewRow{
+			{Key: nil, Value: float64(7)},
+		},
+	})
+	tests.Add("group", tst{
+		view:    "by_tag",
+		options: kivik.Options{"group": true},
+		expected: []viewRow{
+			{Key: "Blue", Value: float64(3)},
+			{Key: "red", Value: float64(4)},
+		},
+	})
+	tests.Add("group level", tst{
+		view:    "by_type_tag",
+		options: kivik.Options{"group_level": 1},
+		expected: []viewRow{
+			{Key: []interface{}{"x"}, Value: float64(3)},
+			{Key: []interface{}{"y"}, Value: float64(1)},
+		},
+	})
+	tests.Add("reduce on map-only view", tst{
+		view:    "map_only",
+		options: kivik.Options{"reduce": true},
+		status:  http.StatusBadRequest,
+		err:     "reduce is invalid for map-only views",
+	})
+	tests.Add("include_docs with reduce", tst{
+		view:    "by_tag",
+		options: kivik.Options{"include_docs": true},
+		status:  http.StatusBadRequest,
+		err:     "include_docs is invalid for reduce",
+	})
+	tests.Add("reversed range", tst{
+		view:    "by_tag",
+		options: kivik.Options{"reduce": false, "startkey": "red", "endkey": "Blue"},
+		status:  http.StatusBadRequest,
+		err:     "No rows can match your key range, reverse your start_key and end_key or set descending=true",
+	})
+	tests.Add("map only excludes design docs", tst{
+		view:    "map_only",
+		options: kivik.Options{"limit": 2, "skip": 1},
+		expected: []viewRow{
+			{ID: "b", Key: "b"},
+			{ID: "c", Key: "c"},
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		db := newDB(t)
+		put(t, db, "a", map[string]interface{}{"type": "x", "tags": []string{"red", "Blue"}, "n": 1})
+		put(t, db, "b", map[string]interface{}{"type": "x", "tags": []string{"Blue"}, "n": 2})
+		put(t, db, "c", map[string]interface{}{"type": "y", "tags": []string{"red"}, "n": 3})
+		put(t, db, "_design/test", map[string]interface{}{"tags": []string{"red"}, "n": 100})
+
+		rows, err := db.Query(context.Background(), "_design/test", tt.view, tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+		result := []viewRow{}
+		for rows.Next() {
+			row := viewRow{ID: rows.ID()}
+			if err := rows.ScanKey(&row.Key); err != nil {
+				t.Fatal(err)
+			}
+			if err := rows.ScanValue(&row.Value); err != nil {
+				t.Fatal(err)
+			}
+			result = append(result, row)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(tt.expected, result); d != nil {
+			t.Error(d)
+		}
+	})
+}
@@ -0,0 +1,230 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package offload stores large attachments outside the database, in
+// pluggable external storage such as an S3 bucket.
+//
+// An attachment larger than the threshold is uploaded to the Store, and the
+// document instead receives a small pointer attachment of the same name,
+// with content type PointerContentType, which records the key of the blob
+// and the original content type, length and digest. GetAttachment and
+// GetAttachmentMeta resolve pointers transparently, so readers see the
+// original attachment. Pointers replicate like any other attachment.
+//
+// Blobs are never deleted by this package: earlier revisions, and other
+// replicas, may still refer to them.
+package offload // import "github.com/go-kivik/kivik/v4/x/offload"
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// PointerContentType is the content type of the pointer attachments which
+// stand in for offloaded attachments.
+const PointerContentType = "application/vnd.kivik.offload+json"
+
+// Store is external blob storage, with S3-style semantics. Implementations
+// must be safe for concurrent use.
+type Store interface {
+	// Put stores content under key. size is the length of content, or -1 if
+	// unknown.
+	Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error
+	// Get returns the content stored under key. The caller closes it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// pointer is the content of a pointer attachment.
+type pointer struct {
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+	Length      int64  `json:"length"`
+	Digest      string `json:"digest"`
+}
+
+// DB wraps a database, offloading large attachments written through it to a
+// Store.
+type DB struct {
+	db        *kivik.DB
+	store     Store
+	threshold int64
+}
+
+// New returns a DB which offloads attachments larger than threshold bytes,
+// written to db, to store.
+func New(db *kivik.DB, store Store, threshold int64) *DB {
+	return &DB{db: db, store: store, threshold: threshold}
+}
+
+// DB returns the underlying database.
+func (o *DB) DB() *kivik.DB {
+	return o.db
+}
+
+// PutAttachment writes an attachment, as DB.PutAttachment, uploading it to
+// the store instead if it is larger than the threshold. Attachments of
+// unknown size, or a Size of 0, are buffered up to the threshold to decide.
+func (o *DB) PutAttachment(ctx context.Context, docID, rev string, att *kivik.Attachment, options ...kivik.Options) (string, error) {
+	content, large, err := o.inspect(att)
+	if err != nil {
+		return "", err
+	}
+	if !large {
+		small := *att
+		small.Content = content
+		return o.db.PutAttachment(ctx, docID, rev, &small, options...)
+	}
+	defer content.Close() // nolint: errcheck
+	key, err := o.key(docID, att.Filename)
+	if err != nil {
+		return "", err
+	}
+	size := att.Size
+	if size <= 0 {
+		size = -1
+	}
+	hash := md5.New()
+	counter := &countingReader{r: io.TeeReader(content, hash)}
+	if err := o.store.Put(ctx, key, counter, size, att.ContentType); err != nil {
+		return "", &kivik.Error{HTTPStatus: kivik.StatusCode(err), Message: fmt.Sprintf("offload: upload of %s/%s failed", docID, att.Filename), Err: err}
+	}
+	p, err := json.Marshal(pointer{
+		Key:         key,
+		ContentType: att.ContentType,
+		Length:      counter.n,
+		Digest:      "md5-" + base64.StdEncoding.EncodeToString(hash.Sum(nil)),
+	})
+	if err != nil {
+		return "", err
+	}
+	return o.db.PutAttachment(ctx, docID, rev, &kivik.Attachment{
+		Filename:    att.Filename,
+		ContentType: PointerContentType,
+		Content:     ioutil.NopCloser(bytes.NewReader(p)),
+		Size:        int64(len(p)),
+	}, options...)
+}
+
+// inspect returns the content of att, and whether it exceeds the threshold.
+func (o *DB) inspect(att *kivik.Attachment) (io.ReadCloser, bool, error) {
+	if att.Content == nil {
+		return nil, false, &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: "offload: attachment has no content"}
+	}
+	if att.Size > 0 {
+		return att.Content, att.Size > o.threshold, nil
+	}
+	head, err := ioutil.ReadAll(io.LimitReader(att.Content, o.threshold+1))
+	if err != nil {
+		_ = att.Content.Close()
+		return nil, false, err
+	}
+	content := struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), att.Content), att.Content}
+	return content, int64(len(head)) > o.threshold, nil
+}
+
+// key returns a new, unique key for an attachment of docID.
+func (o *DB) key(docID, filename string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return url.PathEscape(o.db.Name()) + "/" + url.PathEscape(docID) + "/" + url.PathEscape(filename) + "/" + hex.EncodeToString(b), nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// GetAttachment fetches an attachment, as DB.GetAttachment. The content of an
+// offloaded attachment is streamed from the store.
+func (o *DB) GetAttachment(ctx context.Context, docID, filename string, options ...kivik.Options) (*kivik.Attachment, error) {
+	att, err := o.db.GetAttachment(ctx, docID, filename, options...)
+	if err != nil || att.ContentType != PointerContentType {
+		return att, err
+	}
+	p, err := readPointer(att)
+	if err != nil {
+		return nil, err
+	}
+	content, err := o.store.Get(ctx, p.Key)
+	if err != nil {
+		return nil, &kivik.Error{HTTPStatus: kivik.StatusCode(err), Message: fmt.Sprintf("offload: download of %s/%s failed", docID, filename), Err: err}
+	}
+	resolved := p.attachment(att)
+	resolved.Content = content
+	return resolved, nil
+}
+
+// GetAttachmentMeta returns the metadata of an attachment, as
+// DB.GetAttachmentMeta. For an offloaded attachment, this is the metadata of
+// the original, read from the pointer.
+func (o *DB) GetAttachmentMeta(ctx context.Context, docID, filename string, options ...kivik.Options) (*kivik.Attachment, error) {
+	att, err := o.db.GetAttachmentMeta(ctx, docID, filename, options...)
+	if err != nil || att.ContentType != PointerContentType {
+		return att, err
+	}
+	full, err := o.db.GetAttachment(ctx, docID, filename, options...)
+	if err != nil {
+		return nil, err
+	}
+	p, err := readPointer(full)
+	if err != nil {
+		return nil, err
+	}
+	resolved := p.attachment(att)
+	resolved.Stub = true
+	resolved.Content = ioutil.NopCloser(bytes.NewReader(nil))
+	return resolved, nil
+}
+
+func readPointer(att *kivik.Attachment) (*pointer, error) {
+	defer att.Content.Close() // nolint: errcheck
+	p := &pointer{}
+	if err := json.NewDecoder(att.Content).Decode(p); err != nil || p.Key == "" {
+		return nil, &kivik.Error{HTTPStatus: http.StatusBadGateway, Message: fmt.Sprintf("offload: invalid pointer %s", att.Filename), Err: err}
+	}
+	return p, nil
+}
+
+// attachment returns the original attachment described by p, which stands in
+// its place as att.
+func (p *pointer) attachment(att *kivik.Attachment) *kivik.Attachment {
+	return &kivik.Attachment{
+		Filename:    att.Filename,
+		ContentType: p.ContentType,
+		Size:        p.Length,
+		Digest:      p.Digest,
+		RevPos:      att.RevPos,
+	}
+}
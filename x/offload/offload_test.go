@@ -0,0 +1,202 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package offload
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/memtest"
+)
+
+type memStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+	sizes map[string]int64
+	err   error
+}
+
+func newStore() *memStore {
+	return &memStore{blobs: map[string][]byte{}, sizes: map[string]int64{}}
+}
+
+func (s *memStore) Put(_ context.Context, key string, content io.Reader, size int64, _ string) error {
+	if s.err != nil {
+		return s.err
+	}
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = data
+	s.sizes[key] = size
+	return nil
+}
+
+func (s *memStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.blobs[key]
+	if !ok {
+		return nil, &kivik.Error{HTTPStatus: http.StatusNotFound, Message: "no such blob"}
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func TestOffload(t *testing.T) {
+	type tt struct {
+		content   string
+		size      int64
+		offloaded bool
+		storeErr  error
+		status    int
+		err       string
+	}
+	tests := testy.NewTable()
+	tests.Add("small", tt{
+		content: "small",
+		size:    5,
+	})
+	tests.Add("small, unknown size", tt{
+		content: "small",
+		size:    -1,
+	})
+	tests.Add("at threshold", tt{
+		content: "0123456789",
+	})
+	tests.Add("large", tt{
+		content:   "0123456789abc",
+		size:      13,
+		offloaded: true,
+	})
+	tests.Add("large, unknown size", tt{
+		content:   "0123456789abc",
+		offloaded: true,
+	})
+	tests.Add("upload failure", tt{
+		content:  "0123456789abc",
+		size:     13,
+		storeErr: &kivik.Error{HTTPStatus: http.StatusServiceUnavailable, Message: "bucket unavailable"},
+		status:   http.StatusServiceUnavailable,
+		err:      "offload: upload of doc/file.bin failed: bucket unavailable",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		ctx := context.Background()
+		db := memtest.NewDB(t)
+		store := newStore()
+		store.err = tt.storeErr
+		o := New(db, store, 10)
+		_, err := o.PutAttachment(ctx, "doc", "", &kivik.Attachment{
+			Filename:    "file.bin",
+			ContentType: "application/octet-stream",
+			Content:     ioutil.NopCloser(strings.NewReader(tt.content)),
+			Size:        tt.size,
+		})
+		testy.StatusError(t, tt.err, tt.status, err)
+
+		raw, err := db.GetAttachmentMeta(ctx, "doc", "file.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offloaded := raw.ContentType == PointerContentType; offloaded != tt.offloaded {
+			t.Errorf("Unexpected offloaded: %t", offloaded)
+		}
+		if tt.offloaded && len(store.blobs) != 1 {
+			t.Errorf("Expected one blob, got %d", len(store.blobs))
+		}
+		for _, size := range store.sizes {
+			if want := tt.size; size != want && !(want <= 0 && size == -1) {
+				t.Errorf("Unexpected size passed to store: %d", size)
+			}
+		}
+
+		att, err := o.GetAttachment(ctx, "doc", "file.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(att.Content)
+		_ = att.Content.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != tt.content {
+			t.Errorf("Unexpected content: %q", content)
+		}
+
+		meta, err := o.GetAttachmentMeta(ctx, "doc", "file.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The digest of the original is reported, whether or not it was
+		// offloaded.
+		plain := memtest.NewDB(t)
+		if _, err := plain.PutAttachment(ctx, "doc", "", &kivik.Attachment{
+			Filename:    "file.bin",
+			ContentType: "application/octet-stream",
+			Content:     ioutil.NopCloser(strings.NewReader(tt.content)),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		want, err := plain.GetAttachmentMeta(ctx, "doc", "file.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, got := range []*kivik.Attachment{att, meta} {
+			if got.ContentType != want.ContentType || got.Digest != want.Digest || got.Size != want.Size || got.RevPos != want.RevPos {
+				t.Errorf("Unexpected attachment: %+v, want %+v", got, want)
+			}
+		}
+	})
+}
+
+func TestGetAttachmentErrors(t *testing.T) {
+	ctx := context.Background()
+	put := func(t *testing.T, db *kivik.DB, content string) {
+		t.Helper()
+		if _, err := db.PutAttachment(ctx, "doc", "", &kivik.Attachment{
+			Filename:    "file.bin",
+			ContentType: PointerContentType,
+			Content:     ioutil.NopCloser(strings.NewReader(content)),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Run("missing blob", func(t *testing.T) {
+		db := memtest.NewDB(t)
+		put(t, db, `{"key":"gone"}`)
+		_, err := New(db, newStore(), 10).GetAttachment(ctx, "doc", "file.bin")
+		testy.StatusError(t, "offload: download of doc/file.bin failed: no such blob", http.StatusNotFound, err)
+	})
+	t.Run("invalid pointer", func(t *testing.T) {
+		db := memtest.NewDB(t)
+		put(t, db, `not json`)
+		_, err := New(db, newStore(), 10).GetAttachmentMeta(ctx, "doc", "file.bin")
+		testy.StatusError(t, "offload: invalid pointer file.bin: invalid character 'o' in literal null (expecting 'u')", http.StatusBadGateway, err)
+	})
+	t.Run("no content", func(t *testing.T) {
+		_, err := New(memtest.NewDB(t), newStore(), 10).PutAttachment(ctx, "doc", "", &kivik.Attachment{Filename: "file.bin"})
+		testy.StatusError(t, "offload: attachment has no content", http.StatusBadRequest, err)
+	})
+}
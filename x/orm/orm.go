@@ -0,0 +1,429 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package orm maps Go struct types onto CouchDB documents.
+//
+// A Repo is created for each struct type to be stored. The struct's document
+// ID and revision are identified by struct tags, either `orm:"id"` and
+// `orm:"rev"`, or a JSON name of _id and _rev:
+//
+//	type Widget struct {
+//		ID    string `orm:"id" json:"-"`
+//		Rev   string `orm:"rev" json:"-"`
+//		Color string `json:"color"`
+//	}
+//
+// Every document written through a Repo carries a type field, "type" by
+// default, naming the Go type it maps to, so that documents of different
+// types may share a database. Reads through the Repo reject documents of any
+// other type.
+//
+// A Repo may also be given an ID prefix with WithIDPrefix, such as "widget:",
+// which is added to document IDs on write and removed on read, so that each
+// type has its own range of IDs, which ByIDPrefix scans.
+//
+// Documents are otherwise marshaled with encoding/json, so the remaining
+// fields follow the usual json struct tag rules.
+package orm // import "github.com/go-kivik/kivik/v4/x/orm"
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// DefaultTypeField is the document field which holds the type name, unless
+// changed with WithTypeField.
+const DefaultTypeField = "type"
+
+// pageSize is the number of documents requested per Find call when
+// collecting the results of FindBySelector or AllOfType.
+const pageSize = 100
+
+type field struct {
+	index    []int
+	jsonName string // empty if the field is not marshaled by encoding/json
+}
+
+// Repo reads and writes documents of a single struct type.
+type Repo struct {
+	db        *kivik.DB
+	typ       reflect.Type
+	name      string
+	typeField string
+	prefix    string
+	id, rev   *field
+}
+
+// New returns a Repo which stores values of model's type, a struct or
+// pointer to struct, as documents of type name. model must have a string
+// field tagged as the document ID; a revision field is optional, but without
+// one, existing documents cannot be updated or deleted.
+func New(db *kivik.DB, model interface{}, name string) (*Repo, error) {
+	typ := reflect.TypeOf(model)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("orm: model must be a struct, not %v", typ)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("orm: type name required")
+	}
+	r := &Repo{
+		db:        db,
+		typ:       typ,
+		name:      name,
+		typeField: DefaultTypeField,
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		jsonName := jsonFieldName(f)
+		var target **field
+		var kind string
+		switch {
+		case f.Tag.Get("orm") == "id" || jsonName == "_id":
+			target, kind = &r.id, "ID"
+		case f.Tag.Get("orm") == "rev" || jsonName == "_rev":
+			target, kind = &r.rev, "revision"
+		default:
+			continue
+		}
+		if *target != nil {
+			return nil, fmt.Errorf("orm: %s has more than one %s field", typ, kind)
+		}
+		if f.Type.Kind() != reflect.String {
+			return nil, fmt.Errorf("orm: %s.%s must be a string", typ, f.Name)
+		}
+		*target = &field{index: f.Index, jsonName: jsonName}
+	}
+	if r.id == nil {
+		return nil, fmt.Errorf("orm: %s has no ID field", typ)
+	}
+	return r, nil
+}
+
+// jsonFieldName returns the name under which encoding/json marshals f, or
+// an empty string if it is omitted.
+func jsonFieldName(f reflect.StructField) string {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return f.Name
+}
+
+// WithTypeField returns a copy of r which stores the type name in field,
+// rather than DefaultTypeField.
+func (r *Repo) WithTypeField(field string) *Repo {
+	c := *r
+	c.typeField = field
+	return &c
+}
+
+// WithIDPrefix returns a copy of r which stores documents under IDs beginning
+// with prefix. The prefix is added to the ID of each document written, and
+// removed from the ID of each document read, so the model's ID field holds
+// only the remainder. A document without the prefix is reported as not found.
+// A document written without an ID is assigned a random one, as the database
+// cannot be asked to assign an ID with the prefix.
+func (r *Repo) WithIDPrefix(prefix string) *Repo {
+	c := *r
+	c.prefix = prefix
+	return &c
+}
+
+// DB returns the underlying database.
+func (r *Repo) DB() *kivik.DB {
+	return r.db
+}
+
+// Get fetches the document docID into dest, which must be a pointer to the
+// Repo's type. A document of another type is reported as not found.
+func (r *Repo) Get(ctx context.Context, docID string, dest interface{}, options ...kivik.Options) error {
+	v, err := r.value(dest)
+	if err != nil {
+		return err
+	}
+	var doc json.RawMessage
+	if err := r.db.Get(ctx, r.prefix+docID, options...).ScanDoc(&doc); err != nil {
+		return err
+	}
+	return r.decode(doc, v)
+}
+
+// Put stores doc, which must be a pointer to the Repo's type. If doc has no
+// ID, one is assigned; by the database, unless the Repo has an ID prefix. On
+// success, doc's ID and revision are updated, and the new revision is
+// returned.
+func (r *Repo) Put(ctx context.Context, doc interface{}, options ...kivik.Options) (rev string, err error) {
+	v, err := r.value(doc)
+	if err != nil {
+		return "", err
+	}
+	body, err := r.encode(v)
+	if err != nil {
+		return "", err
+	}
+	docID := v.FieldByIndex(r.id.index).String()
+	if docID == "" && r.prefix != "" {
+		docID = newID()
+		body["_id"] = r.prefix + docID
+	}
+	if docID == "" {
+		docID, rev, err = r.db.CreateDoc(ctx, body, options...)
+	} else {
+		rev, err = r.db.Put(ctx, r.prefix+docID, body, options...)
+	}
+	if err != nil {
+		return "", err
+	}
+	v.FieldByIndex(r.id.index).SetString(docID)
+	r.setRev(v, rev)
+	return rev, nil
+}
+
+// Delete deletes doc, which must be a pointer to the Repo's type, at its
+// current revision. On success, doc's revision is updated to that of the
+// deletion, which is also returned.
+func (r *Repo) Delete(ctx context.Context, doc interface{}, options ...kivik.Options) (rev string, err error) {
+	v, err := r.value(doc)
+	if err != nil {
+		return "", err
+	}
+	if r.rev == nil {
+		return "", &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("orm: %s has no revision field", r.typ)}
+	}
+	rev, err = r.db.Delete(ctx, r.prefix+v.FieldByIndex(r.id.index).String(), v.FieldByIndex(r.rev.index).String(), options...)
+	if err != nil {
+		return "", err
+	}
+	r.setRev(v, rev)
+	return rev, nil
+}
+
+// FindBySelector appends all documents of the Repo's type matching the
+// Mango selector to dest, which must be a pointer to a slice of the Repo's
+// type, or of pointers to it. A nil selector matches every document of the
+// type. Results are collected a page at a time, following the bookmark
+// returned with each page, so options should not include a limit or
+// bookmark. A backend which returns no bookmark is read in a single page.
+func (r *Repo) FindBySelector(ctx context.Context, selector interface{}, dest interface{}, options ...kivik.Options) error {
+	slice, err := r.slice(dest)
+	if err != nil {
+		return err
+	}
+	typeSelector := map[string]interface{}{r.typeField: r.name}
+	if r.prefix != "" {
+		typeSelector["_id"] = map[string]interface{}{
+			"$gte": r.prefix,
+			"$lt":  r.prefix + idRangeEnd,
+		}
+	}
+	query := map[string]interface{}{
+		"selector": typeSelector,
+		"limit":    pageSize,
+	}
+	if selector != nil {
+		query["selector"] = map[string]interface{}{
+			"$and": []interface{}{typeSelector, selector},
+		}
+	}
+	for {
+		rows, err := r.db.Find(ctx, query, options...)
+		if err != nil {
+			return err
+		}
+		count, err := r.appendRows(rows, slice, false)
+		if err != nil {
+			return err
+		}
+		bookmark := rows.Bookmark()
+		if count < pageSize || bookmark == "" || bookmark == query["bookmark"] {
+			return nil
+		}
+		query["bookmark"] = bookmark
+	}
+}
+
+// AllOfType appends all documents of the Repo's type to dest, which must be
+// a pointer to a slice of the Repo's type, or of pointers to it.
+func (r *Repo) AllOfType(ctx context.Context, dest interface{}, options ...kivik.Options) error {
+	return r.FindBySelector(ctx, nil, dest, options...)
+}
+
+// idRangeEnd is appended to an ID prefix to give the end of the range of IDs
+// which begin with it, as in CouchDB's collation no character sorts after it.
+const idRangeEnd = "\ufff0"
+
+// ByIDPrefix appends to dest all documents of the Repo's type whose IDs,
+// without the Repo's ID prefix, begin with idPrefix, in order of ID. dest
+// must be a pointer to a slice of the Repo's type, or of pointers to it.
+// Documents of other types in the range are skipped. The documents are read
+// from /_all_docs, so options may include, for example, descending=true, or
+// a limit, which counts any documents skipped; the range of IDs scanned is
+// set by idPrefix.
+func (r *Repo) ByIDPrefix(ctx context.Context, idPrefix string, dest interface{}, options ...kivik.Options) error {
+	slice, err := r.slice(dest)
+	if err != nil {
+		return err
+	}
+	start, end := r.prefix+idPrefix, r.prefix+idPrefix+idRangeEnd
+	var descending bool
+	for _, opts := range options {
+		if d, ok := opts["descending"]; ok {
+			descending = d == true || d == "true"
+		}
+	}
+	if descending {
+		start, end = end, start
+	}
+	rows, err := r.db.AllDocs(ctx, append(options, kivik.Options{
+		"include_docs": true,
+		"startkey":     start,
+		"endkey":       end,
+	})...)
+	if err != nil {
+		return err
+	}
+	_, err = r.appendRows(rows, slice, true)
+	return err
+}
+
+// appendRows decodes the documents of rows, appending them to slice, and
+// returns the number of rows read. If skipOthers is true, documents of other
+// types are skipped, rather than reported as an error.
+func (r *Repo) appendRows(rows *kivik.Rows, slice reflect.Value, skipOthers bool) (int, error) {
+	defer rows.Close() // nolint: errcheck
+	var count int
+	ptr := slice.Type().Elem().Kind() == reflect.Ptr
+	for rows.Next() {
+		count++
+		var doc json.RawMessage
+		if err := rows.ScanDoc(&doc); err != nil {
+			return 0, err
+		}
+		v := reflect.New(r.typ)
+		if err := r.decode(doc, v.Elem()); err != nil {
+			// decode reports a document of another type as not found.
+			if skipOthers && kivik.StatusCode(err) == http.StatusNotFound {
+				continue
+			}
+			return 0, err
+		}
+		if !ptr {
+			v = v.Elem()
+		}
+		slice.Set(reflect.Append(slice, v))
+	}
+	return count, rows.Err()
+}
+
+// value returns the struct pointed to by dest.
+func (r *Repo) value(dest interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Type() != r.typ {
+		return reflect.Value{}, &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("orm: expected *%s, got %T", r.typ, dest)}
+	}
+	return v.Elem(), nil
+}
+
+// slice returns the slice pointed to by dest.
+func (r *Repo) slice(dest interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(dest)
+	if v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Slice {
+		switch elem := v.Elem().Type().Elem(); {
+		case elem == r.typ, elem.Kind() == reflect.Ptr && elem.Elem() == r.typ:
+			return v.Elem(), nil
+		}
+	}
+	return reflect.Value{}, &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("orm: expected *[]%s or *[]*%[1]s, got %T", r.typ, dest)}
+}
+
+func (r *Repo) setRev(v reflect.Value, rev string) {
+	if r.rev != nil {
+		v.FieldByIndex(r.rev.index).SetString(rev)
+	}
+}
+
+// encode converts v to a document body, with the ID, revision and type
+// fields in place.
+func (r *Repo) encode(v reflect.Value) (map[string]interface{}, error) {
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	var body map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	for _, f := range []*field{r.id, r.rev} {
+		if f != nil && f.jsonName != "" {
+			delete(body, f.jsonName)
+		}
+	}
+	if id := v.FieldByIndex(r.id.index).String(); id != "" {
+		body["_id"] = r.prefix + id
+	}
+	if r.rev != nil {
+		if rev := v.FieldByIndex(r.rev.index).String(); rev != "" {
+			body["_rev"] = rev
+		}
+	}
+	body[r.typeField] = r.name
+	return body, nil
+}
+
+// decode unmarshals doc into v, after checking its type.
+func (r *Repo) decode(doc json.RawMessage, v reflect.Value) error {
+	var meta map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &meta); err != nil {
+		return &kivik.Error{HTTPStatus: http.StatusBadGateway, Err: err}
+	}
+	var id, rev, name string
+	_ = json.Unmarshal(meta["_id"], &id)
+	_ = json.Unmarshal(meta["_rev"], &rev)
+	_ = json.Unmarshal(meta[r.typeField], &name)
+	if name != r.name || !strings.HasPrefix(id, r.prefix) {
+		return &kivik.Error{HTTPStatus: http.StatusNotFound, Message: fmt.Sprintf("orm: document %q is not a %s", id, r.name)}
+	}
+	if err := json.Unmarshal(doc, v.Addr().Interface()); err != nil {
+		return &kivik.Error{HTTPStatus: http.StatusBadGateway, Err: err}
+	}
+	v.FieldByIndex(r.id.index).SetString(strings.TrimPrefix(id, r.prefix))
+	r.setRev(v, rev)
+	return nil
+}
+
+// newID returns a random document ID.
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
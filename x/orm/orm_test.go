@@ -0,0 +1,482 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package orm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/internal/memtest"
+	"github.com/go-kivik/kivik/v4/internal/mock"
+)
+
+func init() {
+	// The "orm-nobookmark" driver returns the same full page of widgets
+	// from every Find, without a bookmark.
+	kivik.Register("orm-nobookmark", &mock.Driver{
+		NewClientFunc: func(string) (driver.Client, error) {
+			return &mock.Client{
+				DBFunc: func(context.Context, string, map[string]interface{}) (driver.DB, error) {
+					return &mock.Finder{
+						DB: &mock.DB{},
+						FindFunc: func(context.Context, interface{}) (driver.Rows, error) {
+							var n int
+							return &mock.Rows{
+								NextFunc: func(row *driver.Row) error {
+									if n == pageSize {
+										return io.EOF
+									}
+									n++
+									row.ID = fmt.Sprintf("w%03d", n)
+									row.Doc = json.RawMessage(fmt.Sprintf(`{"_id":%q,"_rev":"1-xxx","type":"widget"}`, row.ID))
+									return nil
+								},
+								CloseFunc: func() error { return nil },
+							}, nil
+						},
+					}, nil
+				},
+			}, nil
+		},
+	})
+}
+
+type widget struct {
+	ID    string `orm:"id" json:"-"`
+	Rev   string `orm:"rev" json:"-"`
+	Color string `json:"color"`
+	Size  int    `json:"size,omitempty"`
+}
+
+type gadget struct {
+	ID   string `json:"_id"`
+	Rev  string `json:"_rev,omitempty"`
+	Name string `json:"name"`
+}
+
+func newRepo(t *testing.T, db *kivik.DB, model interface{}, name string) *Repo {
+	t.Helper()
+	r, err := New(db, model, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestNew(t *testing.T) {
+	type tt struct {
+		model interface{}
+		name  string
+		err   string
+	}
+	tests := testy.NewTable()
+	tests.Add("orm tags", tt{
+		model: widget{},
+		name:  "widget",
+	})
+	tests.Add("json tags", tt{
+		model: &gadget{},
+		name:  "gadget",
+	})
+	tests.Add("not a struct", tt{
+		model: "foo",
+		name:  "foo",
+		err:   "orm: model must be a struct, not string",
+	})
+	tests.Add("nil model", tt{
+		name: "foo",
+		err:  "orm: model must be a struct, not <nil>",
+	})
+	tests.Add("no name", tt{
+		model: widget{},
+		err:   "orm: type name required",
+	})
+	tests.Add("no id", tt{
+		model: struct{ Name string }{},
+		name:  "foo",
+		err:   "orm: struct { Name string } has no ID field",
+	})
+	tests.Add("non-string id", tt{
+		model: struct {
+			ID int `orm:"id"`
+		}{},
+		name: "foo",
+		err:  "orm: struct { ID int \"orm:\\\"id\\\"\" }.ID must be a string",
+	})
+	tests.Add("duplicate rev", tt{
+		model: struct {
+			ID   string `orm:"id"`
+			Rev  string `orm:"rev"`
+			Rev2 string `json:"_rev"`
+		}{},
+		name: "foo",
+		err:  "orm: struct { ID string \"orm:\\\"id\\\"\"; Rev string \"orm:\\\"rev\\\"\"; Rev2 string \"json:\\\"_rev\\\"\" } has more than one revision field",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		_, err := New(nil, tt.model, tt.name)
+		testy.Error(t, tt.err, err)
+	})
+}
+
+func TestPutGet(t *testing.T) {
+	ctx := context.Background()
+	db := memtest.NewDB(t)
+	widgets := newRepo(t, db, widget{}, "widget")
+
+	w := &widget{ID: "w1", Color: "red"}
+	rev, err := widgets.Put(ctx, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Rev != rev {
+		t.Errorf("Revision not set: %q, expected %q", w.Rev, rev)
+	}
+
+	var raw map[string]interface{}
+	if err := db.Get(ctx, "w1").ScanDoc(&raw); err != nil {
+		t.Fatal(err)
+	}
+	expectedRaw := map[string]interface{}{
+		"_id":   "w1",
+		"_rev":  rev,
+		"type":  "widget",
+		"color": "red",
+	}
+	if d := testy.DiffInterface(expectedRaw, raw); d != nil {
+		t.Errorf("Unexpected document:\n%s", d)
+	}
+
+	w.Color = "blue"
+	if _, err := widgets.Put(ctx, w); err != nil {
+		t.Fatal(err)
+	}
+	var got widget
+	if err := widgets.Get(ctx, "w1", &got); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(w, &got); d != nil {
+		t.Error(d)
+	}
+
+	if _, err := widgets.Put(ctx, &widget{ID: "w1", Color: "green"}); kivik.StatusCode(err) != http.StatusConflict {
+		t.Errorf("Expected conflict, got %v", err)
+	}
+
+	if _, err := widgets.Delete(ctx, w); err != nil {
+		t.Fatal(err)
+	}
+	err = widgets.Get(ctx, "w1", &got)
+	testy.StatusError(t, "deleted", http.StatusNotFound, err)
+}
+
+func TestPutNoID(t *testing.T) {
+	gadgets := newRepo(t, memtest.NewDB(t), gadget{}, "gadget")
+	g := &gadget{Name: "sprocket"}
+	if _, err := gadgets.Put(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+	if g.ID == "" || g.Rev == "" {
+		t.Fatalf("ID and revision not set: %+v", g)
+	}
+	var got gadget
+	if err := gadgets.Get(context.Background(), g.ID, &got); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(g, &got); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestGet(t *testing.T) {
+	type tt struct {
+		repo   *Repo
+		id     string
+		dest   interface{}
+		status int
+		err    string
+	}
+	tests := testy.NewTable()
+	tests.Add("wrong type", func(t *testing.T) interface{} {
+		db := memtest.NewDB(t)
+		if _, err := newRepo(t, db, gadget{}, "gadget").Put(context.Background(), &gadget{ID: "g1"}); err != nil {
+			t.Fatal(err)
+		}
+		return tt{
+			repo:   newRepo(t, db, widget{}, "widget"),
+			id:     "g1",
+			dest:   &widget{},
+			status: http.StatusNotFound,
+			err:    `orm: document "g1" is not a widget`,
+		}
+	})
+	tests.Add("untyped document", func(t *testing.T) interface{} {
+		db := memtest.NewDB(t)
+		if _, err := db.Put(context.Background(), "w1", map[string]string{"color": "red"}); err != nil {
+			t.Fatal(err)
+		}
+		return tt{
+			repo:   newRepo(t, db, widget{}, "widget"),
+			id:     "w1",
+			dest:   &widget{},
+			status: http.StatusNotFound,
+			err:    `orm: document "w1" is not a widget`,
+		}
+	})
+	tests.Add("custom type field", func(t *testing.T) interface{} {
+		db := memtest.NewDB(t)
+		if _, err := db.Put(context.Background(), "w1", map[string]string{"kind": "widget", "color": "red"}); err != nil {
+			t.Fatal(err)
+		}
+		return tt{
+			repo: newRepo(t, db, widget{}, "widget").WithTypeField("kind"),
+			id:   "w1",
+			dest: &widget{},
+		}
+	})
+	tests.Add("missing", func(t *testing.T) interface{} {
+		return tt{
+			repo:   newRepo(t, memtest.NewDB(t), widget{}, "widget"),
+			id:     "w1",
+			dest:   &widget{},
+			status: http.StatusNotFound,
+			err:    "missing",
+		}
+	})
+	tests.Add("wrong dest", func(t *testing.T) interface{} {
+		return tt{
+			repo:   newRepo(t, memtest.NewDB(t), widget{}, "widget"),
+			id:     "w1",
+			dest:   &gadget{},
+			status: http.StatusBadRequest,
+			err:    "orm: expected *orm.widget, got *orm.gadget",
+		}
+	})
+	tests.Add("non-pointer dest", func(t *testing.T) interface{} {
+		return tt{
+			repo:   newRepo(t, memtest.NewDB(t), widget{}, "widget"),
+			id:     "w1",
+			dest:   widget{},
+			status: http.StatusBadRequest,
+			err:    "orm: expected *orm.widget, got orm.widget",
+		}
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		err := tt.repo.Get(context.Background(), tt.id, tt.dest)
+		testy.StatusError(t, tt.err, tt.status, err)
+	})
+}
+
+func TestFindBySelector(t *testing.T) {
+	ctx := context.Background()
+	db := memtest.NewDB(t)
+	widgets := newRepo(t, db, widget{}, "widget")
+	gadgets := newRepo(t, db, gadget{}, "gadget")
+	for i := 0; i < pageSize+5; i++ {
+		color := "red"
+		if i%2 == 1 {
+			color = "blue"
+		}
+		if _, err := widgets.Put(ctx, &widget{ID: fmt.Sprintf("w%03d", i), Color: color, Size: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := gadgets.Put(ctx, &gadget{ID: "g1", Name: "red"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("all of type", func(t *testing.T) {
+		var all []widget
+		if err := widgets.AllOfType(ctx, &all); err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != pageSize+5 {
+			t.Errorf("Expected %d widgets, got %d", pageSize+5, len(all))
+		}
+		for _, w := range all {
+			if w.ID == "" || w.Rev == "" {
+				t.Errorf("ID and revision not set: %+v", w)
+			}
+		}
+	})
+	t.Run("selector", func(t *testing.T) {
+		var blue []*widget
+		if err := widgets.FindBySelector(ctx, map[string]interface{}{"color": "blue", "size": map[string]interface{}{"$lt": 6}}, &blue); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, w := range blue {
+			ids = append(ids, w.ID)
+		}
+		if d := testy.DiffInterface([]string{"w001", "w003", "w005"}, ids); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("exactly one page", func(t *testing.T) {
+		var found []widget
+		if err := widgets.FindBySelector(ctx, map[string]interface{}{"size": map[string]interface{}{"$gte": 5}}, &found); err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != pageSize {
+			t.Errorf("Expected %d widgets, got %d", pageSize, len(found))
+		}
+	})
+	t.Run("no bookmark", func(t *testing.T) {
+		client, err := kivik.New("orm-nobookmark", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo := newRepo(t, client.DB(ctx, "test"), widget{}, "widget")
+		var all []widget
+		if err := repo.AllOfType(ctx, &all); err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != pageSize {
+			t.Errorf("Expected %d widgets, got %d", pageSize, len(all))
+		}
+	})
+	t.Run("other type", func(t *testing.T) {
+		var all []gadget
+		if err := gadgets.AllOfType(ctx, &all); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface([]gadget{{ID: "g1", Rev: all[0].Rev, Name: "red"}}, all); d != nil {
+			t.Error(d)
+		}
+	})
+	t.Run("wrong dest", func(t *testing.T) {
+		var all []gadget
+		err := widgets.AllOfType(ctx, &all)
+		testy.StatusError(t, "orm: expected *[]orm.widget or *[]*orm.widget, got *[]orm.gadget", http.StatusBadRequest, err)
+	})
+}
+
+func TestIDPrefix(t *testing.T) {
+	ctx := context.Background()
+	db := memtest.NewDB(t)
+	widgets := newRepo(t, db, widget{}, "widget").WithIDPrefix("widget:")
+
+	w := &widget{ID: "w1", Color: "red"}
+	if _, err := widgets.Put(ctx, w); err != nil {
+		t.Fatal(err)
+	}
+	if w.ID != "w1" {
+		t.Errorf("Unexpected ID: %s", w.ID)
+	}
+	if err := db.Get(ctx, "widget:w1").Err; err != nil {
+		t.Fatalf("Document not stored under prefixed ID: %s", err)
+	}
+	var got widget
+	if err := widgets.Get(ctx, "w1", &got); err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface(w, &got); d != nil {
+		t.Error(d)
+	}
+
+	created := &widget{Color: "blue"}
+	if _, err := widgets.Put(ctx, created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || strings.HasPrefix(created.ID, "widget:") {
+		t.Errorf("Unexpected assigned ID: %q", created.ID)
+	}
+	if err := db.Get(ctx, "widget:"+created.ID).Err; err != nil {
+		t.Errorf("Assigned ID not prefixed: %s", err)
+	}
+	if _, err := widgets.Delete(ctx, created); err != nil {
+		t.Fatal(err)
+	}
+
+	// An unprefixed widget is not found through the prefixed Repo.
+	if _, err := newRepo(t, db, widget{}, "widget").Put(ctx, &widget{ID: "w2", Color: "red"}); err != nil {
+		t.Fatal(err)
+	}
+	err := widgets.Get(ctx, "w2", &got)
+	testy.StatusError(t, "missing", http.StatusNotFound, err)
+	var red []widget
+	if err := widgets.FindBySelector(ctx, map[string]interface{}{"color": "red"}, &red); err != nil {
+		t.Fatal(err)
+	}
+	if len(red) != 1 || red[0].ID != "w1" {
+		t.Errorf("Unexpected widgets: %+v", red)
+	}
+}
+
+func TestByIDPrefix(t *testing.T) {
+	ctx := context.Background()
+	db := memtest.NewDB(t)
+	widgets := newRepo(t, db, widget{}, "widget").WithIDPrefix("widget:")
+	for _, id := range []string{"a1", "a2", "a3", "b1"} {
+		if _, err := widgets.Put(ctx, &widget{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Put(ctx, "widget:a4", map[string]string{"type": "gadget"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newRepo(t, db, widget{}, "widget").Put(ctx, &widget{ID: "a5"}); err != nil {
+		t.Fatal(err)
+	}
+
+	type tt struct {
+		prefix  string
+		options kivik.Options
+		want    []string
+	}
+	tests := testy.NewTable()
+	tests.Add("all", tt{
+		want: []string{"a1", "a2", "a3", "b1"},
+	})
+	tests.Add("prefix", tt{
+		prefix: "a",
+		want:   []string{"a1", "a2", "a3"},
+	})
+	tests.Add("descending", tt{
+		prefix:  "a",
+		options: kivik.Options{"descending": true},
+		want:    []string{"a3", "a2", "a1"},
+	})
+	tests.Add("limit counts skipped documents", tt{
+		prefix:  "a",
+		options: kivik.Options{"descending": true, "limit": 2},
+		want:    []string{"a3"},
+	})
+	tests.Add("none", tt{
+		prefix: "c",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		var found []*widget
+		if err := widgets.ByIDPrefix(ctx, tt.prefix, &found, tt.options); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, w := range found {
+			ids = append(ids, w.ID)
+		}
+		if d := testy.DiffInterface(tt.want, ids); d != nil {
+			t.Error(d)
+		}
+	})
+}
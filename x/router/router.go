@@ -0,0 +1,238 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package router directs the operations of an application to several
+// databases, by document ID, for tiered storage architectures. For example,
+// recent documents may be kept in CouchDB, and archived documents in a local
+// database:
+//
+//	r := router.New(hot, router.Route{
+//		Match: router.OlderThan(90*24*time.Hour, timeFromID),
+//		DB:    cold,
+//	})
+//
+// Reads and writes of a single document go to its backend. AllDocs and Find
+// query every backend, and merge the results in document ID order.
+//
+// A Router only routes; it does not move documents. Where routing depends on
+// age, the application must move documents to their new backend as they
+// age, and reads of documents in transit may miss.
+package router // import "github.com/go-kivik/kivik/v4/x/router"
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/queryopts"
+)
+
+// defaultFindLimit is the number of results returned by Find without a limit,
+// as for CouchDB.
+const defaultFindLimit = 25
+
+var errNoRow = &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: "router: no current row; call Next first"}
+
+// Matcher reports whether a document belongs to a backend, by its ID.
+type Matcher func(docID string) bool
+
+// Prefix matches document IDs which begin with prefix.
+func Prefix(prefix string) Matcher {
+	return func(docID string) bool {
+		return strings.HasPrefix(docID, prefix)
+	}
+}
+
+// Pattern matches document IDs which match re.
+func Pattern(re *regexp.Regexp) Matcher {
+	return re.MatchString
+}
+
+// OlderThan matches documents more than age old, by the time derived from
+// their ID by timeOf, such as a timestamp prefix. Documents for which timeOf
+// returns false do not match.
+func OlderThan(age time.Duration, timeOf func(docID string) (time.Time, bool)) Matcher {
+	return func(docID string) bool {
+		t, ok := timeOf(docID)
+		return ok && time.Since(t) > age
+	}
+}
+
+// Route directs the documents matched by Match to DB.
+type Route struct {
+	Match Matcher
+	DB    *kivik.DB
+}
+
+// Router directs operations to the backend of each document: the DB of the
+// first route which matches its ID, or else the default.
+type Router struct {
+	def    *kivik.DB
+	routes []Route
+}
+
+// New returns a Router which directs documents matched by routes to their
+// backends, and all others to def.
+func New(def *kivik.DB, routes ...Route) *Router {
+	return &Router{def: def, routes: routes}
+}
+
+// DB returns the backend of docID.
+func (r *Router) DB(docID string) *kivik.DB {
+	for _, route := range r.routes {
+		if route.Match(docID) {
+			return route.DB
+		}
+	}
+	return r.def
+}
+
+// backends returns each distinct backend once, the default first.
+func (r *Router) backends() []*kivik.DB {
+	dbs := []*kivik.DB{r.def}
+	seen := map[*kivik.DB]bool{r.def: true}
+	for _, route := range r.routes {
+		if !seen[route.DB] {
+			seen[route.DB] = true
+			dbs = append(dbs, route.DB)
+		}
+	}
+	return dbs
+}
+
+// Get fetches docID from its backend.
+func (r *Router) Get(ctx context.Context, docID string, options ...kivik.Options) *kivik.Row {
+	return r.DB(docID).Get(ctx, docID, options...)
+}
+
+// Put stores doc in the backend of docID.
+func (r *Router) Put(ctx context.Context, docID string, doc interface{}, options ...kivik.Options) (string, error) {
+	return r.DB(docID).Put(ctx, docID, doc, options...)
+}
+
+// Delete deletes docID from its backend.
+func (r *Router) Delete(ctx context.Context, docID, rev string, options ...kivik.Options) (string, error) {
+	return r.DB(docID).Delete(ctx, docID, rev, options...)
+}
+
+// AllDocs queries the _all_docs of every backend, merging the results in
+// document ID order. skip and limit apply to the merged results. The keys
+// option is not supported, as documents are better fetched from their
+// backends with Get.
+func (r *Router) AllDocs(ctx context.Context, options ...kivik.Options) (*Rows, error) {
+	opts := kivik.Options{}
+	for _, o := range options {
+		for k, v := range o {
+			opts[k] = v
+		}
+	}
+	if _, ok := opts["keys"]; ok {
+		return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: "router: AllDocs does not support keys"}
+	}
+	descending, err := queryopts.Bool(opts, "descending")
+	if err != nil {
+		return nil, err
+	}
+	skip, limit, err := window(opts, -1)
+	if err != nil {
+		return nil, err
+	}
+	return r.query(descending, skip, limit, func(db *kivik.DB) (*kivik.Rows, error) {
+		return db.AllDocs(ctx, opts)
+	})
+}
+
+// Find runs query against every backend, merging the results in document ID
+// order. skip and limit apply to the merged results. Queries which sort are
+// not supported, as the results of each backend cannot be merged by the sort
+// fields.
+func (r *Router) Find(ctx context.Context, query interface{}, options ...kivik.Options) (*Rows, error) {
+	q, err := toMap(query)
+	if err != nil {
+		return nil, err
+	}
+	if sort, ok := q["sort"].([]interface{}); ok && len(sort) > 0 {
+		return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: "router: Find does not support sort"}
+	}
+	skip, limit, err := window(q, defaultFindLimit)
+	if err != nil {
+		return nil, err
+	}
+	delete(q, "bookmark")
+	return r.query(false, skip, limit, func(db *kivik.DB) (*kivik.Rows, error) {
+		return db.Find(ctx, q, options...)
+	})
+}
+
+func (r *Router) query(descending bool, skip, limit int64, fn func(*kivik.DB) (*kivik.Rows, error)) (*Rows, error) {
+	var sources []*source
+	for _, db := range r.backends() {
+		rows, err := fn(db)
+		if err != nil {
+			for _, s := range sources {
+				_ = s.rows.Close()
+			}
+			return nil, err
+		}
+		sources = append(sources, &source{db: db, rows: rows})
+	}
+	return newRows(sources, descending, skip, limit), nil
+}
+
+// window removes skip from opts, and raises limit, if any, to cover the
+// skipped results, as each backend must return all results up to the end of
+// the merged window. It returns the original skip and limit, or def if limit
+// is unset; a negative def means no limit.
+func window(opts map[string]interface{}, def int64) (skip, limit int64, err error) {
+	skip, _, err = queryopts.Int(opts, "skip")
+	if err != nil {
+		return 0, 0, err
+	}
+	limit, ok, err := queryopts.Int(opts, "limit")
+	if err != nil {
+		return 0, 0, err
+	}
+	if !ok {
+		limit = def
+	}
+	delete(opts, "skip")
+	if limit >= 0 {
+		opts["limit"] = skip + limit
+	}
+	return skip, limit, nil
+}
+
+func toMap(query interface{}) (map[string]interface{}, error) {
+	var data []byte
+	switch t := query.(type) {
+	case string:
+		data = []byte(t)
+	case []byte:
+		data = t
+	case json.RawMessage:
+		data = t
+	default:
+		var err error
+		if data, err = json.Marshal(query); err != nil {
+			return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Err: err}
+		}
+	}
+	var q map[string]interface{}
+	if err := json.Unmarshal(data, &q); err != nil || q == nil {
+		return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: "router: query must be a JSON object", Err: err}
+	}
+	return q, nil
+}
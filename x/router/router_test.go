@@ -0,0 +1,242 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package router
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	_ "github.com/go-kivik/kivik/v4/x/memorydb"
+)
+
+func newDB(t *testing.T, client *kivik.Client, name string) *kivik.DB {
+	t.Helper()
+	if err := client.CreateDB(context.Background(), name); err != nil {
+		t.Fatal(err)
+	}
+	return client.DB(context.Background(), name)
+}
+
+// newRouter returns a router which stores documents with IDs starting with
+// "archive:" in cold, and all others in hot.
+func newRouter(t *testing.T) (r *Router, hot, cold *kivik.DB) {
+	t.Helper()
+	client, err := kivik.New("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hot, cold = newDB(t, client, "hot"), newDB(t, client, "cold")
+	return New(hot, Route{Match: Prefix("archive:"), DB: cold}), hot, cold
+}
+
+func TestMatchers(t *testing.T) {
+	timeOf := func(docID string) (time.Time, bool) {
+		t, err := time.Parse("2006-01-02", strings.SplitN(docID, ":", 2)[0])
+		return t, err == nil
+	}
+	old := time.Now().AddDate(-1, 0, 0).Format("2006-01-02")
+	recent := time.Now().Format("2006-01-02")
+	tests := []struct {
+		name     string
+		match    Matcher
+		docID    string
+		expected bool
+	}{
+		{name: "prefix match", match: Prefix("a:"), docID: "a:1", expected: true},
+		{name: "prefix mismatch", match: Prefix("a:"), docID: "b:1"},
+		{name: "pattern match", match: Pattern(regexp.MustCompile(`^\d+$`)), docID: "123", expected: true},
+		{name: "pattern mismatch", match: Pattern(regexp.MustCompile(`^\d+$`)), docID: "x123"},
+		{name: "old", match: OlderThan(30*24*time.Hour, timeOf), docID: old + ":1", expected: true},
+		{name: "recent", match: OlderThan(30*24*time.Hour, timeOf), docID: recent + ":1"},
+		{name: "no time", match: OlderThan(30*24*time.Hour, timeOf), docID: "foo"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := test.match(test.docID); result != test.expected {
+				t.Errorf("Unexpected result: %t", result)
+			}
+		})
+	}
+}
+
+func TestDocuments(t *testing.T) {
+	ctx := context.Background()
+	r, hot, cold := newRouter(t)
+	if r.DB("archive:1") != cold || r.DB("1") != hot {
+		t.Fatal("Unexpected routing")
+	}
+	for _, id := range []string{"1", "archive:1"} {
+		rev, err := r.Put(ctx, id, map[string]string{"name": id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc map[string]interface{}
+		if err := r.Get(ctx, id).ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc["name"] != id {
+			t.Errorf("Unexpected doc: %v", doc)
+		}
+		if _, err := r.Delete(ctx, id, rev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := hot.Put(ctx, "2", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cold.Put(ctx, "archive:2", map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	err := r.Get(ctx, "archive:2x").ScanDoc(&map[string]interface{}{})
+	testy.StatusError(t, "missing", http.StatusNotFound, err)
+}
+
+// ids returns the IDs of rows, and the backend of each.
+func ids(t *testing.T, r *Router, rows *Rows) []string {
+	t.Helper()
+	var result []string
+	for rows.Next() {
+		id := rows.ID()
+		if rows.DB() != r.DB(id) {
+			t.Errorf("%s read from the wrong backend", id)
+		}
+		result = append(result, id)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func populate(t *testing.T, r *Router, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if _, err := r.Put(context.Background(), id, map[string]interface{}{"type": "widget", "n": len(id)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAllDocs(t *testing.T) {
+	type tt struct {
+		options  kivik.Options
+		expected []string
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("all", tt{
+		expected: []string{"a", "archive:a", "archive:b", "b", "c"},
+	})
+	tests.Add("descending", tt{
+		options:  kivik.Options{"descending": true},
+		expected: []string{"c", "b", "archive:b", "archive:a", "a"},
+	})
+	tests.Add("skip and limit", tt{
+		options:  kivik.Options{"skip": 1, "limit": 3},
+		expected: []string{"archive:a", "archive:b", "b"},
+	})
+	tests.Add("skip", tt{
+		options:  kivik.Options{"skip": 3},
+		expected: []string{"b", "c"},
+	})
+	tests.Add("range", tt{
+		options:  kivik.Options{"startkey": "archive:b"},
+		expected: []string{"archive:b", "b", "c"},
+	})
+	tests.Add("keys", tt{
+		options: kivik.Options{"keys": []string{"a"}},
+		status:  http.StatusBadRequest,
+		err:     "router: AllDocs does not support keys",
+	})
+	tests.Add("invalid limit", tt{
+		options: kivik.Options{"limit": "lots"},
+		status:  http.StatusBadRequest,
+		err:     `invalid value for limit: "lots"`,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		r, _, _ := newRouter(t)
+		populate(t, r, "c", "archive:b", "a", "archive:a", "b")
+		rows, err := r.AllDocs(context.Background(), tt.options)
+		testy.StatusError(t, tt.err, tt.status, err)
+		if d := testy.DiffInterface(tt.expected, ids(t, r, rows)); d != nil {
+			t.Error(d)
+		}
+		if total := rows.TotalRows(); total != 5 {
+			t.Errorf("Unexpected total rows: %d", total)
+		}
+	})
+}
+
+func TestFind(t *testing.T) {
+	type tt struct {
+		query    interface{}
+		expected []string
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("all", tt{
+		query:    map[string]interface{}{"selector": map[string]interface{}{"type": "widget"}},
+		expected: []string{"a", "archive:a", "archive:b", "b"},
+	})
+	tests.Add("selector", tt{
+		query:    `{"selector":{"n":1}}`,
+		expected: []string{"a", "b"},
+	})
+	tests.Add("skip and limit", tt{
+		query:    `{"selector":{"type":"widget"},"skip":1,"limit":2}`,
+		expected: []string{"archive:a", "archive:b"},
+	})
+	tests.Add("sort", tt{
+		query:  `{"selector":{},"sort":["n"]}`,
+		status: http.StatusBadRequest,
+		err:    "router: Find does not support sort",
+	})
+	tests.Add("not an object", tt{
+		query:  `[]`,
+		status: http.StatusBadRequest,
+		err:    "router: query must be a JSON object: json: cannot unmarshal array into Go value of type map[string]interface {}",
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		r, _, _ := newRouter(t)
+		populate(t, r, "archive:b", "a", "archive:a", "b")
+		rows, err := r.Find(context.Background(), tt.query)
+		testy.StatusError(t, tt.err, tt.status, err)
+		var result []string
+		for rows.Next() {
+			var doc struct {
+				ID string `json:"_id"`
+			}
+			if err := rows.ScanDoc(&doc); err != nil {
+				t.Fatal(err)
+			}
+			result = append(result, doc.ID)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(tt.expected, result); d != nil {
+			t.Error(d)
+		}
+	})
+}
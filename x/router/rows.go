@@ -0,0 +1,188 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package router
+
+import (
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// source is the result set of one backend.
+type source struct {
+	db   *kivik.DB
+	rows *kivik.Rows
+	done bool
+}
+
+// Rows is an iterator over results merged from several backends, in document
+// ID order.
+type Rows struct {
+	sources    []*source
+	descending bool
+	skip       int64
+	limit      int64 // negative for no limit
+	yielded    int64
+	started    bool
+	cur        *source
+	err        error
+}
+
+func newRows(sources []*source, descending bool, skip, limit int64) *Rows {
+	return &Rows{
+		sources:    sources,
+		descending: descending,
+		skip:       skip,
+		limit:      limit,
+	}
+}
+
+// Next prepares the next result for reading. It returns false when there are
+// no more results, or an error has occurred, in which case Err returns it.
+func (r *Rows) Next() bool {
+	for {
+		if r.err != nil {
+			return false
+		}
+		if !r.started {
+			r.started = true
+			for _, s := range r.sources {
+				r.advance(s)
+			}
+		} else if r.cur != nil {
+			r.advance(r.cur)
+		} else {
+			return false
+		}
+		r.cur = r.pick()
+		if r.err != nil || r.cur == nil || (r.limit >= 0 && r.yielded >= r.limit) {
+			r.cur = nil
+			_ = r.Close()
+			return false
+		}
+		if r.skip > 0 {
+			r.skip--
+			continue
+		}
+		r.yielded++
+		return true
+	}
+}
+
+func (r *Rows) advance(s *source) {
+	if s.done {
+		return
+	}
+	if !s.rows.Next() {
+		s.done = true
+		if err := s.rows.Err(); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+}
+
+// pick returns the source whose current row comes first, or nil if all are
+// exhausted.
+func (r *Rows) pick() *source {
+	var first *source
+	for _, s := range r.sources {
+		if s.done {
+			continue
+		}
+		if first == nil || (s.rows.ID() < first.rows.ID()) != r.descending {
+			first = s
+		}
+	}
+	return first
+}
+
+// Err returns the error, if any, encountered by any backend during iteration.
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Close closes the result sets of all backends.
+func (r *Rows) Close() error {
+	var err error
+	for _, s := range r.sources {
+		if e := s.rows.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// DB returns the backend of the current result.
+func (r *Rows) DB() *kivik.DB {
+	if r.cur == nil {
+		return nil
+	}
+	return r.cur.db
+}
+
+// ID returns the document ID of the current result.
+func (r *Rows) ID() string {
+	if r.cur == nil {
+		return ""
+	}
+	return r.cur.rows.ID()
+}
+
+// Key returns the key of the current result, as kivik.Rows.Key.
+func (r *Rows) Key() string {
+	if r.cur == nil {
+		return ""
+	}
+	return r.cur.rows.Key()
+}
+
+// ScanValue copies the value of the current result into dest, as
+// kivik.Rows.ScanValue.
+func (r *Rows) ScanValue(dest interface{}) error {
+	if err := r.ready(); err != nil {
+		return err
+	}
+	return r.cur.rows.ScanValue(dest)
+}
+
+// ScanDoc copies the document of the current result into dest, as
+// kivik.Rows.ScanDoc.
+func (r *Rows) ScanDoc(dest interface{}) error {
+	if err := r.ready(); err != nil {
+		return err
+	}
+	return r.cur.rows.ScanDoc(dest)
+}
+
+// ScanKey copies the key of the current result into dest, as
+// kivik.Rows.ScanKey.
+func (r *Rows) ScanKey(dest interface{}) error {
+	if err := r.ready(); err != nil {
+		return err
+	}
+	return r.cur.rows.ScanKey(dest)
+}
+
+func (r *Rows) ready() error {
+	if r.cur == nil {
+		return errNoRow
+	}
+	return nil
+}
+
+// TotalRows returns the sum of the total rows reported by the backends.
+func (r *Rows) TotalRows() int64 {
+	var total int64
+	for _, s := range r.sources {
+		total += s.rows.TotalRows()
+	}
+	return total
+}
@@ -0,0 +1,49 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package router
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestRowsNoCurrentRow(t *testing.T) {
+	r, _, _ := newRouter(t)
+	populate(t, r, "a")
+	rows, err := r.AllDocs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows.ID() != "" || rows.Key() != "" || rows.DB() != nil {
+		t.Error("Unexpected current row before Next")
+	}
+	var doc map[string]interface{}
+	t.Run("before Next", func(t *testing.T) {
+		testy.StatusError(t, "router: no current row; call Next first", http.StatusBadRequest, rows.ScanDoc(&doc))
+	})
+	if !rows.Next() || rows.Next() {
+		t.Fatal("Expected exactly one row")
+	}
+	if rows.Next() {
+		t.Error("Next after the end returned true")
+	}
+	t.Run("after the end", func(t *testing.T) {
+		testy.StatusError(t, "router: no current row; call Next first", http.StatusBadRequest, rows.ScanValue(&doc))
+	})
+	if err := rows.Close(); err != nil {
+		t.Error(err)
+	}
+}
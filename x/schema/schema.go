@@ -0,0 +1,202 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package schema upgrades documents written by older versions of an
+// application as they are read, so that a document schema can evolve without
+// migrating every document at once.
+//
+// A Schema lists the upgrade functions of one type of document, each of which
+// converts a document from one version to the next:
+//
+//	var widgets = schema.New(
+//		// Version 1 to 2: rename colour to color.
+//		func(doc map[string]interface{}) error {
+//			doc["color"] = doc["colour"]
+//			delete(doc, "colour")
+//			return nil
+//		},
+//	)
+//
+//	func init() {
+//		widgets.Register((*Widget)(nil))
+//	}
+//
+// Once registered, every document scanned into a *Widget, by Row.ScanDoc,
+// Rows.ScanDoc or Changes.ScanDoc, is first upgraded to the current version.
+// The version of a document is stored in its version field,
+// DefaultVersionField unless changed with WithVersionField; a document
+// without one is at version 1. Documents should be written with Put, which
+// records the current version.
+package schema // import "github.com/go-kivik/kivik/v4/x/schema"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// DefaultVersionField is the document field which holds the schema version,
+// unless changed with WithVersionField.
+const DefaultVersionField = "schema_version"
+
+// UpgradeFunc upgrades doc, in place, from one version of the schema to the
+// next. doc includes the special fields, such as _id and _rev, which should
+// be left unchanged.
+type UpgradeFunc func(doc map[string]interface{}) error
+
+// Schema is the version history of one type of document.
+type Schema struct {
+	versionField string
+	upgrades     []UpgradeFunc
+	writeBack    *kivik.DB
+}
+
+// New returns a schema whose current version is len(upgrades)+1. upgrades[0]
+// upgrades a document from version 1 to version 2, upgrades[1] from version 2
+// to version 3, and so on.
+func New(upgrades ...UpgradeFunc) *Schema {
+	return &Schema{
+		versionField: DefaultVersionField,
+		upgrades:     upgrades,
+	}
+}
+
+// WithVersionField returns a copy of s which stores the version in field,
+// rather than DefaultVersionField.
+func (s *Schema) WithVersionField(field string) *Schema {
+	c := *s
+	c.versionField = field
+	return &c
+}
+
+// WithWriteBack returns a copy of s which writes documents upgraded by its
+// registered decoder back to db, so that they need not be upgraded again.
+// Only documents which include their _id and _rev are written back. A
+// conflict means the document has been updated since it was read, and is
+// ignored; any other error is returned by the scan, after the destination
+// has been populated.
+func (s *Schema) WithWriteBack(db *kivik.DB) *Schema {
+	c := *s
+	c.writeBack = db
+	return &c
+}
+
+// Version returns the current version of the schema.
+func (s *Schema) Version() int {
+	return len(s.upgrades) + 1
+}
+
+// Upgrade returns the JSON document data, upgraded to the current version,
+// and whether any upgrade was applied. Values other than JSON objects, such
+// as the null document of a deleted row, are returned unchanged.
+func (s *Schema) Upgrade(data []byte) ([]byte, bool, error) {
+	var doc map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil || doc == nil {
+		return data, false, nil
+	}
+	id, _ := doc["_id"].(string)
+	version, err := s.version(id, doc)
+	if err != nil {
+		return nil, false, err
+	}
+	if version == s.Version() {
+		return data, false, nil
+	}
+	for ; version < s.Version(); version++ {
+		if err := s.upgrades[version-1](doc); err != nil {
+			return nil, false, &kivik.Error{
+				HTTPStatus: kivik.StatusCode(err),
+				Message:    fmt.Sprintf("schema: upgrade of %q to version %d failed", id, version+1),
+				Err:        err,
+			}
+		}
+	}
+	doc[s.versionField] = s.Version()
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	return upgraded, true, nil
+}
+
+// version returns the schema version of doc.
+func (s *Schema) version(id string, doc map[string]interface{}) (int, error) {
+	v, ok := doc[s.versionField]
+	if !ok {
+		return 1, nil
+	}
+	n, ok := v.(json.Number)
+	version, err := n.Int64()
+	if !ok || err != nil || version < 1 {
+		return 0, &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("schema: document %q has invalid version %v", id, v)}
+	}
+	if version > int64(s.Version()) {
+		return 0, &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("schema: document %q has version %d, newer than the current version %d", id, version, s.Version())}
+	}
+	return int(version), nil
+}
+
+// Register installs a decoder, with kivik.RegisterDecoder, which upgrades
+// documents scanned into destinations of target's type before decoding them
+// with encoding/json. As with kivik.RegisterDecoder, it is meant to be called
+// from an init function, and panics if target's type already has a decoder.
+func (s *Schema) Register(target interface{}) {
+	kivik.RegisterDecoder(target, s.decode)
+}
+
+func (s *Schema) decode(data []byte, dest interface{}) error {
+	doc, upgraded, err := s.Upgrade(data)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(doc, dest); err != nil {
+		return err
+	}
+	if !upgraded || s.writeBack == nil {
+		return nil
+	}
+	var meta struct {
+		ID  string `json:"_id"`
+		Rev string `json:"_rev"`
+	}
+	if err := json.Unmarshal(doc, &meta); err != nil || meta.ID == "" || meta.Rev == "" {
+		return err
+	}
+	_, err = s.writeBack.Put(context.Background(), meta.ID, json.RawMessage(doc))
+	if kivik.IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// Put stores doc in db under docID, with its version field set to the
+// current version. doc must marshal to a JSON object.
+func (s *Schema) Put(ctx context.Context, db *kivik.DB, docID string, doc interface{}, options ...kivik.Options) (rev string, err error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	var m map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil || m == nil {
+		return "", &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: "schema: document must be a JSON object", Err: err}
+	}
+	m[s.versionField] = s.Version()
+	return db.Put(ctx, docID, m, options...)
+}
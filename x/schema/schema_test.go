@@ -0,0 +1,223 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package schema
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/memtest"
+)
+
+// widgets renames colour to color in version 2, and adds a default size in
+// version 3.
+var widgets = New(
+	func(doc map[string]interface{}) error {
+		doc["color"] = doc["colour"]
+		delete(doc, "colour")
+		return nil
+	},
+	func(doc map[string]interface{}) error {
+		if _, ok := doc["size"]; !ok {
+			doc["size"] = 1
+		}
+		return nil
+	},
+)
+
+func TestUpgrade(t *testing.T) {
+	type tt struct {
+		schema   *Schema
+		data     string
+		expected string
+		upgraded bool
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("version 1", tt{
+		schema:   widgets,
+		data:     `{"_id":"foo","colour":"red"}`,
+		expected: `{"_id":"foo","color":"red","schema_version":3,"size":1}`,
+		upgraded: true,
+	})
+	tests.Add("version 2", tt{
+		schema:   widgets,
+		data:     `{"_id":"foo","color":"red","size":2,"schema_version":2}`,
+		expected: `{"_id":"foo","color":"red","schema_version":3,"size":2}`,
+		upgraded: true,
+	})
+	tests.Add("current version", tt{
+		schema:   widgets,
+		data:     `{"_id":"foo","color":"red","size":2,"schema_version":3}`,
+		expected: `{"_id":"foo","color":"red","size":2,"schema_version":3}`,
+	})
+	tests.Add("custom version field", tt{
+		schema:   widgets.WithVersionField("v"),
+		data:     `{"_id":"foo","colour":"red","schema_version":"unrelated"}`,
+		expected: `{"_id":"foo","color":"red","schema_version":"unrelated","size":1,"v":3}`,
+		upgraded: true,
+	})
+	tests.Add("null", tt{
+		schema:   widgets,
+		data:     `null`,
+		expected: `null`,
+	})
+	tests.Add("array", tt{
+		schema:   widgets,
+		data:     `[1,2]`,
+		expected: `[1,2]`,
+	})
+	tests.Add("invalid version", tt{
+		schema: widgets,
+		data:   `{"_id":"foo","schema_version":"two"}`,
+		status: http.StatusBadRequest,
+		err:    `schema: document "foo" has invalid version two`,
+	})
+	tests.Add("future version", tt{
+		schema: widgets,
+		data:   `{"_id":"foo","schema_version":4}`,
+		status: http.StatusBadRequest,
+		err:    `schema: document "foo" has version 4, newer than the current version 3`,
+	})
+	tests.Add("upgrade failure", tt{
+		schema: New(func(map[string]interface{}) error {
+			return &kivik.Error{HTTPStatus: http.StatusConflict, Err: errors.New("no")}
+		}),
+		data:   `{"_id":"foo"}`,
+		status: http.StatusConflict,
+		err:    `schema: upgrade of "foo" to version 2 failed: no`,
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		result, upgraded, err := tt.schema.Upgrade([]byte(tt.data))
+		testy.StatusError(t, tt.err, tt.status, err)
+		if upgraded != tt.upgraded {
+			t.Errorf("Unexpected upgraded: %t", upgraded)
+		}
+		if d := testy.DiffJSON([]byte(tt.expected), result); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+type widget struct {
+	ID      string `json:"_id"`
+	Rev     string `json:"_rev"`
+	Color   string `json:"color"`
+	Size    int    `json:"size"`
+	Version int    `json:"schema_version"`
+}
+
+type writtenWidget widget
+
+func init() {
+	widgets.Register((*widget)(nil))
+}
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	db := memtest.NewDB(t)
+	if _, err := db.Put(ctx, "foo", map[string]interface{}{"colour": "red"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var w widget
+	if err := db.Get(ctx, "foo").ScanDoc(&w); err != nil {
+		t.Fatal(err)
+	}
+	w.Rev = ""
+	if d := testy.DiffInterface(widget{ID: "foo", Color: "red", Size: 1, Version: 3}, w); d != nil {
+		t.Error(d)
+	}
+
+	rows, err := db.AllDocs(ctx, kivik.Options{"include_docs": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		w = widget{}
+		if err := rows.ScanDoc(&w); err != nil {
+			t.Fatal(err)
+		}
+		if w.Color != "red" || w.Version != 3 {
+			t.Errorf("Unexpected row doc: %+v", w)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stored document is unchanged without write-back.
+	var stored map[string]interface{}
+	if err := db.Get(ctx, "foo").ScanDoc(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stored["schema_version"]; ok {
+		t.Errorf("document was written back: %v", stored)
+	}
+}
+
+func TestRegisterWriteBack(t *testing.T) {
+	ctx := context.Background()
+	db := memtest.NewDB(t)
+	widgets.WithWriteBack(db).Register((*writtenWidget)(nil))
+	rev, err := db.Put(ctx, "foo", map[string]interface{}{"colour": "red"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var w writtenWidget
+	if err := db.Get(ctx, "foo").ScanDoc(&w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Rev != rev || w.Version != 3 {
+		t.Errorf("Unexpected doc: %+v", w)
+	}
+
+	var stored map[string]interface{}
+	if err := db.Get(ctx, "foo").ScanDoc(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored["_rev"] == rev {
+		t.Fatal("document was not written back")
+	}
+	delete(stored, "_rev")
+	expected := map[string]interface{}{"_id": "foo", "color": "red", "size": float64(1), "schema_version": float64(3)}
+	if d := testy.DiffInterface(expected, stored); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestPut(t *testing.T) {
+	ctx := context.Background()
+	db := memtest.NewDB(t)
+	if _, err := widgets.Put(ctx, db, "foo", widget{Color: "blue", Size: 2}); err != nil {
+		t.Fatal(err)
+	}
+	var stored map[string]interface{}
+	if err := db.Get(ctx, "foo").ScanDoc(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if v := stored["schema_version"]; v != float64(3) {
+		t.Errorf("Unexpected version: %v", v)
+	}
+
+	_, err := widgets.Put(ctx, db, "bar", []int{1})
+	testy.StatusError(t, "schema: document must be a JSON object: json: cannot unmarshal array into Go value of type map[string]interface {}", http.StatusBadRequest, err)
+}
@@ -0,0 +1,481 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// NewMemory returns a new, empty Engine which holds all of its data in
+// memory.
+func NewMemory() Engine {
+	return &memEngine{dbs: make(map[string]*memDB)}
+}
+
+type memEngine struct {
+	mu  sync.RWMutex
+	dbs map[string]*memDB
+}
+
+var _ Engine = &memEngine{}
+
+func (e *memEngine) AllDBs(_ context.Context) ([]string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	names := make([]string, 0, len(e.dbs))
+	for name := range e.dbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (e *memEngine) CreateDB(_ context.Context, name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.dbs[name]; ok {
+		return ErrDBExists
+	}
+	e.dbs[name] = newMemDB()
+	return nil
+}
+
+func newMemDB() *memDB {
+	return &memDB{
+		docs:     make(map[string]*DocInfo),
+		revs:     make(map[string]map[string]*Revision),
+		blobs:    make(map[string][]byte),
+		indexes:  make(map[string]*driver.Index),
+		local:    make(map[string]*LocalDoc),
+		security: &driver.Security{},
+	}
+}
+
+func (e *memEngine) DestroyDB(_ context.Context, name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	db, ok := e.dbs[name]
+	if !ok {
+		return ErrDBNotFound
+	}
+	db.mu.Lock()
+	db.destroyed = true
+	db.mu.Unlock()
+	delete(e.dbs, name)
+	return nil
+}
+
+func (e *memEngine) DB(_ context.Context, name string) (DB, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	db, ok := e.dbs[name]
+	if !ok {
+		return nil, ErrDBNotFound
+	}
+	return db, nil
+}
+
+type memDB struct {
+	mu        sync.RWMutex
+	destroyed bool
+	// readOnly is set for snapshots, which may not be updated.
+	readOnly bool
+	seq      int64
+	docs     map[string]*DocInfo
+	revs     map[string]map[string]*Revision
+	blobs    map[string][]byte
+	indexes  map[string]*driver.Index
+	local    map[string]*LocalDoc
+	security *driver.Security
+}
+
+var _ DB = &memDB{}
+
+func (db *memDB) View(_ context.Context, fn func(Tx) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.destroyed {
+		return ErrDBNotFound
+	}
+	return fn(&memTx{db: db})
+}
+
+func (db *memDB) Update(_ context.Context, fn func(Tx) error) error {
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.destroyed {
+		return ErrDBNotFound
+	}
+	tx := &memTx{db: db, writable: true}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.rollback()
+		}
+	}()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// memTx is a transaction on a memDB, which holds db.mu for its duration. Its
+// stores are the transaction itself, viewed through different types.
+//
+// An update transaction writes directly to db, recording in undo how to
+// reverse each write, so that the writes can be discarded if the transaction
+// fails. No other transaction can observe db until the update is finished.
+type memTx struct {
+	db       *memDB
+	writable bool
+	undo     []func()
+}
+
+var _ Tx = &memTx{}
+
+func (tx *memTx) Docs() DocStore         { return (*memDocs)(tx) }
+func (tx *memTx) Revs() RevTreeStore     { return (*memRevs)(tx) }
+func (tx *memTx) Attachments() BlobStore { return (*memBlobs)(tx) }
+func (tx *memTx) Indexes() IndexStore    { return (*memIndexes)(tx) }
+func (tx *memTx) Local() LocalStore      { return (*memLocal)(tx) }
+
+// write returns ErrReadOnly unless tx is an update transaction.
+func (tx *memTx) write() error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// onRollback records fn to be called if the transaction is rolled back.
+func (tx *memTx) onRollback(fn func()) {
+	tx.undo = append(tx.undo, fn)
+}
+
+// rollback reverses the writes made by the transaction, newest first.
+func (tx *memTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = nil
+}
+
+func (tx *memTx) Security() (*driver.Security, error) {
+	return tx.db.security, nil
+}
+
+func (tx *memTx) SetSecurity(sec *driver.Security) error {
+	if err := tx.write(); err != nil {
+		return err
+	}
+	prev := tx.db.security
+	tx.onRollback(func() { tx.db.security = prev })
+	tx.db.security = sec
+	return nil
+}
+
+type memDocs memTx
+
+func (s *memDocs) Get(id string) (*DocInfo, error) {
+	return s.db.docs[id], nil
+}
+
+func (s *memDocs) Put(doc *DocInfo) error {
+	if err := (*memTx)(s).write(); err != nil {
+		return err
+	}
+	s.restoreDoc(doc.ID)
+	s.db.docs[doc.ID] = doc
+	return nil
+}
+
+// restoreDoc arranges for the summary of document id to be restored on
+// rollback.
+func (s *memDocs) restoreDoc(id string) {
+	prev, ok := s.db.docs[id]
+	(*memTx)(s).onRollback(func() {
+		if ok {
+			s.db.docs[id] = prev
+		} else {
+			delete(s.db.docs, id)
+		}
+	})
+}
+
+func (s *memDocs) Delete(id string) error {
+	if err := (*memTx)(s).write(); err != nil {
+		return err
+	}
+	s.restoreDoc(id)
+	delete(s.db.docs, id)
+	return nil
+}
+
+// sorted returns the documents for which keep returns true, ordered by less.
+func (s *memDocs) sorted(keep func(*DocInfo) bool, less func(a, b *DocInfo) bool) []*DocInfo {
+	docs := make([]*DocInfo, 0, len(s.db.docs))
+	for _, doc := range s.db.docs {
+		if keep(doc) {
+			docs = append(docs, doc)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		return less(docs[i], docs[j])
+	})
+	return docs
+}
+
+func (s *memDocs) Range(fn func(*DocInfo) error) error {
+	docs := s.sorted(func(*DocInfo) bool { return true }, func(a, b *DocInfo) bool {
+		return a.ID < b.ID
+	})
+	for _, doc := range docs {
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memDocs) Changes(since int64, fn func(*DocInfo) error) error {
+	docs := s.sorted(func(doc *DocInfo) bool { return doc.Seq > since }, func(a, b *DocInfo) bool {
+		return a.Seq < b.Seq
+	})
+	for _, doc := range docs {
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memDocs) Seq() (int64, error) {
+	return s.db.seq, nil
+}
+
+func (s *memDocs) NextSeq() (int64, error) {
+	if err := (*memTx)(s).write(); err != nil {
+		return 0, err
+	}
+	prev := s.db.seq
+	(*memTx)(s).onRollback(func() { s.db.seq = prev })
+	s.db.seq++
+	return s.db.seq, nil
+}
+
+type memRevs memTx
+
+func (s *memRevs) Get(id, rev string) (*Revision, error) {
+	return s.db.revs[id][rev], nil
+}
+
+func (s *memRevs) Revs(id string) ([]*Revision, error) {
+	revs := make([]*Revision, 0, len(s.db.revs[id]))
+	for _, r := range s.db.revs[id] {
+		revs = append(revs, r)
+	}
+	return revs, nil
+}
+
+func (s *memRevs) Put(id string, rev *Revision) error {
+	if err := (*memTx)(s).write(); err != nil {
+		return err
+	}
+	revs, ok := s.db.revs[id]
+	if !ok {
+		revs = make(map[string]*Revision)
+		s.db.revs[id] = revs
+		(*memTx)(s).onRollback(func() { delete(s.db.revs, id) })
+	} else {
+		prev, ok := revs[rev.Rev]
+		(*memTx)(s).onRollback(func() {
+			if ok {
+				revs[rev.Rev] = prev
+			} else {
+				delete(revs, rev.Rev)
+			}
+		})
+	}
+	revs[rev.Rev] = rev
+	return nil
+}
+
+func (s *memRevs) Delete(id string) error {
+	if err := (*memTx)(s).write(); err != nil {
+		return err
+	}
+	if revs, ok := s.db.revs[id]; ok {
+		(*memTx)(s).onRollback(func() { s.db.revs[id] = revs })
+	}
+	delete(s.db.revs, id)
+	return nil
+}
+
+type memBlobs memTx
+
+func (s *memBlobs) Get(digest string) ([]byte, error) {
+	content, ok := s.db.blobs[digest]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return content, nil
+}
+
+func (s *memBlobs) Put(digest string, content []byte) error {
+	if err := (*memTx)(s).write(); err != nil {
+		return err
+	}
+	if _, ok := s.db.blobs[digest]; !ok {
+		(*memTx)(s).onRollback(func() { delete(s.db.blobs, digest) })
+		s.db.blobs[digest] = content
+	}
+	return nil
+}
+
+func (s *memBlobs) Delete(digest string) error {
+	if err := (*memTx)(s).write(); err != nil {
+		return err
+	}
+	if content, ok := s.db.blobs[digest]; ok {
+		(*memTx)(s).onRollback(func() { s.db.blobs[digest] = content })
+	}
+	delete(s.db.blobs, digest)
+	return nil
+}
+
+func (s *memBlobs) Range(fn func(string) error) error {
+	digests := make([]string, 0, len(s.db.blobs))
+	for digest := range s.db.blobs {
+		digests = append(digests, digest)
+	}
+	for _, digest := range digests {
+		if err := fn(digest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type memIndexes memTx
+
+func indexKey(ddoc, name string) string {
+	return ddoc + "\x00" + name
+}
+
+func (s *memIndexes) Get(ddoc, name string) (*driver.Index, error) {
+	return s.db.indexes[indexKey(ddoc, name)], nil
+}
+
+func (s *memIndexes) Put(index *driver.Index) error {
+	if err := (*memTx)(s).write(); err != nil {
+		return err
+	}
+	key := indexKey(index.DesignDoc, index.Name)
+	s.restoreIndex(key)
+	s.db.indexes[key] = index
+	return nil
+}
+
+// restoreIndex arranges for the index stored under key to be restored on
+// rollback.
+func (s *memIndexes) restoreIndex(key string) {
+	prev, ok := s.db.indexes[key]
+	(*memTx)(s).onRollback(func() {
+		if ok {
+			s.db.indexes[key] = prev
+		} else {
+			delete(s.db.indexes, key)
+		}
+	})
+}
+
+func (s *memIndexes) Delete(ddoc, name string) error {
+	if err := (*memTx)(s).write(); err != nil {
+		return err
+	}
+	key := indexKey(ddoc, name)
+	s.restoreIndex(key)
+	delete(s.db.indexes, key)
+	return nil
+}
+
+func (s *memIndexes) List() ([]*driver.Index, error) {
+	keys := make([]string, 0, len(s.db.indexes))
+	for key := range s.db.indexes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	indexes := make([]*driver.Index, len(keys))
+	for i, key := range keys {
+		indexes[i] = s.db.indexes[key]
+	}
+	return indexes, nil
+}
+
+type memLocal memTx
+
+func (s *memLocal) Get(id string) (*LocalDoc, error) {
+	return s.db.local[id], nil
+}
+
+func (s *memLocal) Put(doc *LocalDoc) error {
+	if err := (*memTx)(s).write(); err != nil {
+		return err
+	}
+	s.restoreLocal(doc.ID)
+	s.db.local[doc.ID] = doc
+	return nil
+}
+
+// restoreLocal arranges for local document id to be restored on rollback.
+func (s *memLocal) restoreLocal(id string) {
+	prev, ok := s.db.local[id]
+	(*memTx)(s).onRollback(func() {
+		if ok {
+			s.db.local[id] = prev
+		} else {
+			delete(s.db.local, id)
+		}
+	})
+}
+
+func (s *memLocal) Delete(id string) error {
+	if err := (*memTx)(s).write(); err != nil {
+		return err
+	}
+	s.restoreLocal(id)
+	delete(s.db.local, id)
+	return nil
+}
+
+func (s *memLocal) Range(fn func(*LocalDoc) error) error {
+	ids := make([]string, 0, len(s.db.local))
+	for id := range s.db.local {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := fn(s.db.local[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
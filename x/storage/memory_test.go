@@ -0,0 +1,385 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+func TestMemoryDBs(t *testing.T) {
+	ctx := context.Background()
+	e := NewMemory()
+	for _, name := range []string{"b", "a"} {
+		if err := e.CreateDB(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := e.CreateDB(ctx, "a")
+	testy.StatusErrorRE(t, "database exists", http.StatusPreconditionFailed, err)
+	names, err := e.AllDBs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"a", "b"}, names); d != nil {
+		t.Error(d)
+	}
+	db, err := e.DB(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.DestroyDB(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	err = db.View(ctx, func(Tx) error { return nil })
+	testy.StatusErrorRE(t, "database does not exist", http.StatusNotFound, err)
+	_, err = e.DB(ctx, "a")
+	testy.StatusErrorRE(t, "database does not exist", http.StatusNotFound, err)
+	err = e.DestroyDB(ctx, "a")
+	testy.StatusError(t, "database does not exist", http.StatusNotFound, err)
+}
+
+// newMemoryDB returns a new database in a new memory engine.
+func newMemoryDB(t *testing.T) DB {
+	t.Helper()
+	e := NewMemory()
+	if err := e.CreateDB(context.Background(), "db"); err != nil {
+		t.Fatal(err)
+	}
+	db, err := e.DB(context.Background(), "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestMemoryReadOnly(t *testing.T) {
+	type tst struct {
+		write func(Tx) error
+	}
+	tests := testy.NewTable()
+	tests.Add("doc", tst{
+		write: func(tx Tx) error {
+			return tx.Docs().Put(&DocInfo{ID: "a"})
+		},
+	})
+	tests.Add("seq", tst{
+		write: func(tx Tx) error {
+			_, err := tx.Docs().NextSeq()
+			return err
+		},
+	})
+	tests.Add("rev", tst{
+		write: func(tx Tx) error {
+			return tx.Revs().Put("a", &Revision{Rev: "1-a"})
+		},
+	})
+	tests.Add("blob", tst{
+		write: func(tx Tx) error {
+			return tx.Attachments().Put("md5-x", nil)
+		},
+	})
+	tests.Add("index", tst{
+		write: func(tx Tx) error {
+			return tx.Indexes().Delete("_design/a", "a")
+		},
+	})
+	tests.Add("local", tst{
+		write: func(tx Tx) error {
+			return tx.Local().Delete("_local/a")
+		},
+	})
+	tests.Add("security", tst{
+		write: func(tx Tx) error {
+			return tx.SetSecurity(&driver.Security{})
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		err := newMemoryDB(t).View(context.Background(), tt.write)
+		testy.StatusError(t, "storage: write in read-only transaction", http.StatusInternalServerError, err)
+	})
+}
+
+func TestMemoryDocs(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDB(t)
+	err := db.Update(ctx, func(tx Tx) error {
+		for _, id := range []string{"c", "a", "b", "a"} {
+			seq, err := tx.Docs().NextSeq()
+			if err != nil {
+				return err
+			}
+			if err := tx.Docs().Put(&DocInfo{ID: id, Rev: "1-x", Seq: seq}); err != nil {
+				return err
+			}
+		}
+		return tx.Docs().Delete("b")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var byID, bySeq []string
+	err = db.View(ctx, func(tx Tx) error {
+		if err := tx.Docs().Range(func(doc *DocInfo) error {
+			byID = append(byID, doc.ID)
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := tx.Docs().Changes(0, func(doc *DocInfo) error {
+			bySeq = append(bySeq, doc.ID)
+			return nil
+		}); err != nil {
+			return err
+		}
+		seq, err := tx.Docs().Seq()
+		if seq != 4 {
+			t.Errorf("Unexpected seq: %d", seq)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := testy.DiffInterface([]string{"a", "c"}, byID); d != nil {
+		t.Errorf("Range:\n%s", d)
+	}
+	if d := testy.DiffInterface([]string{"c", "a"}, bySeq); d != nil {
+		t.Errorf("Changes:\n%s", d)
+	}
+}
+
+func TestMemoryStores(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDB(t)
+	err := db.Update(ctx, func(tx Tx) error {
+		for _, r := range []*Revision{{Rev: "1-a"}, {Rev: "2-b", Parent: "1-a"}} {
+			if err := tx.Revs().Put("doc", r); err != nil {
+				return err
+			}
+		}
+		if err := tx.Attachments().Put("md5-x", []byte("content")); err != nil {
+			return err
+		}
+		for _, name := range []string{"b", "a"} {
+			if err := tx.Indexes().Put(&driver.Index{DesignDoc: "_design/x", Name: name}); err != nil {
+				return err
+			}
+		}
+		return tx.Local().Put(&LocalDoc{ID: "_local/a", Rev: 1})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.View(ctx, func(tx Tx) error {
+		revs, err := tx.Revs().Revs("doc")
+		if err != nil {
+			return err
+		}
+		if len(revs) != 2 {
+			t.Errorf("Unexpected revisions: %v", revs)
+		}
+		r, err := tx.Revs().Get("doc", "2-b")
+		if err != nil {
+			return err
+		}
+		if r == nil || r.Parent != "1-a" {
+			t.Errorf("Unexpected revision: %v", r)
+		}
+		if content, err := tx.Attachments().Get("md5-x"); err != nil || string(content) != "content" {
+			t.Errorf("Unexpected attachment content: %q, %v", content, err)
+		}
+		_, err = tx.Attachments().Get("md5-y")
+		testy.StatusErrorRE(t, "storage: not found", http.StatusInternalServerError, err)
+		indexes, err := tx.Indexes().List()
+		if err != nil {
+			return err
+		}
+		if len(indexes) != 2 || indexes[0].Name != "a" {
+			t.Errorf("Unexpected indexes: %v", indexes)
+		}
+		doc, err := tx.Local().Get("_local/a")
+		if err != nil {
+			return err
+		}
+		if doc == nil || doc.Rev != 1 {
+			t.Errorf("Unexpected local doc: %v", doc)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// memoryContents returns everything stored in db, for comparison.
+func memoryContents(t *testing.T, db DB) map[string]interface{} {
+	t.Helper()
+	contents := map[string]interface{}{}
+	err := db.View(context.Background(), func(tx Tx) error {
+		var docs []*DocInfo
+		if err := tx.Docs().Range(func(doc *DocInfo) error {
+			docs = append(docs, doc)
+			revs, err := tx.Revs().Revs(doc.ID)
+			contents["revs:"+doc.ID] = len(revs)
+			return err
+		}); err != nil {
+			return err
+		}
+		contents["docs"] = docs
+		seq, err := tx.Docs().Seq()
+		if err != nil {
+			return err
+		}
+		contents["seq"] = seq
+		var blobs []string
+		if err := tx.Attachments().Range(func(digest string) error {
+			blobs = append(blobs, digest)
+			return nil
+		}); err != nil {
+			return err
+		}
+		contents["blobs"] = blobs
+		indexes, err := tx.Indexes().List()
+		if err != nil {
+			return err
+		}
+		contents["indexes"] = indexes
+		var local []*LocalDoc
+		if err := tx.Local().Range(func(doc *LocalDoc) error {
+			local = append(local, doc)
+			return nil
+		}); err != nil {
+			return err
+		}
+		contents["local"] = local
+		sec, err := tx.Security()
+		contents["security"] = sec
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return contents
+}
+
+func TestMemoryRollback(t *testing.T) {
+	type tst struct {
+		// fail ends the transaction, after all its writes.
+		fail func() error
+	}
+	tests := testy.NewTable()
+	tests.Add("error", tst{
+		fail: func() error {
+			return errors.New("failed")
+		},
+	})
+	tests.Add("panic", tst{
+		fail: func() error {
+			panic("failed")
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, tt tst) {
+		ctx := context.Background()
+		db := newMemoryDB(t)
+		err := db.Update(ctx, func(tx Tx) error {
+			if err := tx.Docs().Put(&DocInfo{ID: "a", Rev: "1-a", Seq: 1}); err != nil {
+				return err
+			}
+			if _, err := tx.Docs().NextSeq(); err != nil {
+				return err
+			}
+			if err := tx.Revs().Put("a", &Revision{Rev: "1-a"}); err != nil {
+				return err
+			}
+			if err := tx.Attachments().Put("md5-a", []byte("a")); err != nil {
+				return err
+			}
+			if err := tx.Indexes().Put(&driver.Index{DesignDoc: "_design/x", Name: "a"}); err != nil {
+				return err
+			}
+			return tx.Local().Put(&LocalDoc{ID: "_local/a", Rev: 1})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := memoryContents(t, db)
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			err = db.Update(ctx, func(tx Tx) error {
+				for _, id := range []string{"a", "b"} {
+					seq, err := tx.Docs().NextSeq()
+					if err != nil {
+						return err
+					}
+					if err := tx.Docs().Put(&DocInfo{ID: id, Rev: "2-b", Seq: seq}); err != nil {
+						return err
+					}
+					if err := tx.Revs().Put(id, &Revision{Rev: "2-b"}); err != nil {
+						return err
+					}
+				}
+				if err := tx.Docs().Delete("a"); err != nil {
+					return err
+				}
+				if err := tx.Revs().Delete("a"); err != nil {
+					return err
+				}
+				for _, digest := range []string{"md5-b", "md5-a"} {
+					if err := tx.Attachments().Put(digest, []byte("b")); err != nil {
+						return err
+					}
+				}
+				if err := tx.Attachments().Delete("md5-a"); err != nil {
+					return err
+				}
+				if err := tx.Indexes().Delete("_design/x", "a"); err != nil {
+					return err
+				}
+				if err := tx.Indexes().Put(&driver.Index{DesignDoc: "_design/x", Name: "b"}); err != nil {
+					return err
+				}
+				if err := tx.Local().Put(&LocalDoc{ID: "_local/a", Rev: 2}); err != nil {
+					return err
+				}
+				if err := tx.Local().Put(&LocalDoc{ID: "_local/b", Rev: 1}); err != nil {
+					return err
+				}
+				if err := tx.SetSecurity(&driver.Security{Admins: driver.Members{Names: []string{"bob"}}}); err != nil {
+					return err
+				}
+				return tt.fail()
+			})
+		}()
+		if err == nil {
+			t.Fatal("Expected the update to fail")
+		}
+		if d := testy.DiffInterface(want, memoryContents(t, db)); d != nil {
+			t.Errorf("Writes were not discarded:\n%s", d)
+		}
+	})
+}
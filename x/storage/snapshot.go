@@ -0,0 +1,70 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package storage
+
+import "context"
+
+// Snapshot returns a copy of the contents of src, read in a single View
+// transaction, so that it is consistent as of a single point in time. The
+// copy is held in memory, and shares the stored values of src rather than
+// duplicating them. It is read-only: Update returns ErrReadOnly.
+func Snapshot(ctx context.Context, src DB) (DB, error) {
+	snap := newMemDB()
+	snap.readOnly = true
+	err := src.View(ctx, func(tx Tx) error {
+		var err error
+		if snap.seq, err = tx.Docs().Seq(); err != nil {
+			return err
+		}
+		if err := tx.Docs().Range(func(doc *DocInfo) error {
+			snap.docs[doc.ID] = doc
+			revs, err := tx.Revs().Revs(doc.ID)
+			if err != nil {
+				return err
+			}
+			snap.revs[doc.ID] = make(map[string]*Revision, len(revs))
+			for _, r := range revs {
+				snap.revs[doc.ID][r.Rev] = r
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		if err := tx.Attachments().Range(func(digest string) error {
+			content, err := tx.Attachments().Get(digest)
+			snap.blobs[digest] = content
+			return err
+		}); err != nil {
+			return err
+		}
+		indexes, err := tx.Indexes().List()
+		if err != nil {
+			return err
+		}
+		for _, index := range indexes {
+			snap.indexes[indexKey(index.DesignDoc, index.Name)] = index
+		}
+		if err := tx.Local().Range(func(doc *LocalDoc) error {
+			snap.local[doc.ID] = doc
+			return nil
+		}); err != nil {
+			return err
+		}
+		snap.security, err = tx.Security()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
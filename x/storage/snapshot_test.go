@@ -0,0 +1,69 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package storage
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryDB(t)
+	write := func(rev string) {
+		t.Helper()
+		err := db.Update(ctx, func(tx Tx) error {
+			seq, err := tx.Docs().NextSeq()
+			if err != nil {
+				return err
+			}
+			if err := tx.Docs().Put(&DocInfo{ID: "a", Rev: rev, Seq: seq}); err != nil {
+				return err
+			}
+			if err := tx.Revs().Put("a", &Revision{Rev: rev}); err != nil {
+				return err
+			}
+			if err := tx.Attachments().Put("md5-"+rev, []byte(rev)); err != nil {
+				return err
+			}
+			if err := tx.Indexes().Put(&driver.Index{DesignDoc: "_design/x", Name: rev}); err != nil {
+				return err
+			}
+			if err := tx.Local().Put(&LocalDoc{ID: "_local/" + rev, Rev: 1}); err != nil {
+				return err
+			}
+			return tx.SetSecurity(&driver.Security{Admins: driver.Members{Names: []string{rev}}})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("1-a")
+	want := memoryContents(t, db)
+	snap, err := Snapshot(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("2-b")
+
+	if d := testy.DiffInterface(want, memoryContents(t, snap)); d != nil {
+		t.Errorf("Snapshot changed:\n%s", d)
+	}
+	err = snap.Update(ctx, func(Tx) error { return nil })
+	testy.StatusError(t, "storage: write in read-only transaction", http.StatusInternalServerError, err)
+}
@@ -0,0 +1,212 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package storage defines the primitives with which an embedded driver stores
+// databases: summaries of documents, their revision trees, attachment
+// content and index definitions. The CouchDB semantics of revisions, the
+// changes feed and queries are implemented on top of these primitives by the
+// driver, so that a new backend need only implement Engine.
+//
+// NewMemory returns an Engine which holds everything in memory. The memory
+// driver (see package memorydb) is the only driver built on this package, and
+// accepts any other Engine via memorydb.NewDriver. The filesystem driver (see
+// package fsdb) has its own storage, and does not share these semantics; in
+// particular, it preserves conflicts and supports new_edits=false, which the
+// memory driver does not.
+package storage // import "github.com/go-kivik/kivik/v4/x/storage"
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kivik/kivik/v4/driver"
+	"github.com/go-kivik/kivik/v4/errors"
+)
+
+// Errors which an Engine returns, so that drivers can tell these conditions
+// apart from other failures.
+var (
+	ErrDBNotFound = errors.Status(http.StatusNotFound, "database does not exist")
+	ErrDBExists   = errors.Status(http.StatusPreconditionFailed, "database exists")
+	ErrNotFound   = errors.Status(http.StatusInternalServerError, "storage: not found")
+	ErrReadOnly   = errors.Status(http.StatusInternalServerError, "storage: write in read-only transaction")
+)
+
+// Engine is a storage backend, which holds any number of named databases.
+// Database names are validated by the driver before they are passed to the
+// Engine.
+type Engine interface {
+	// AllDBs returns the names of all databases, in sorted order.
+	AllDBs(ctx context.Context) ([]string, error)
+	// CreateDB creates a new, empty database. It returns ErrDBExists if the
+	// database already exists.
+	CreateDB(ctx context.Context, name string) error
+	// DestroyDB deletes the database and all of its contents. It returns
+	// ErrDBNotFound if the database does not exist.
+	DestroyDB(ctx context.Context, name string) error
+	// DB returns the named database, or ErrDBNotFound if it does not exist.
+	DB(ctx context.Context, name string) (DB, error)
+}
+
+// DB is the storage of a single database. All access to its contents is
+// through transactions. Once the database has been destroyed, View and
+// Update return ErrDBNotFound.
+type DB interface {
+	// View calls fn with a read-only transaction, in which writes return
+	// ErrReadOnly.
+	View(ctx context.Context, fn func(Tx) error) error
+	// Update calls fn with a read-write transaction. Transactions are
+	// isolated from each other, and update transactions are serialized. If
+	// fn returns an error, or panics, the writes made by fn are discarded.
+	Update(ctx context.Context, fn func(Tx) error) error
+}
+
+// Tx is a transaction on a single database. It must not be used once the
+// function it was passed to has returned.
+//
+// Values passed to the stores of a Tx, and the values they return, belong to
+// the engine, and must not be modified by the caller. Nor does the engine
+// modify a value once it is stored, so values may be retained after the
+// transaction has finished.
+type Tx interface {
+	Docs() DocStore
+	Revs() RevTreeStore
+	Attachments() BlobStore
+	Indexes() IndexStore
+	Local() LocalStore
+
+	// Security returns the database's security object.
+	Security() (*driver.Security, error)
+	// SetSecurity replaces the database's security object.
+	SetSecurity(*driver.Security) error
+}
+
+// DocInfo is the summary of a document: its winning revision, and the update
+// sequence at which the document last changed.
+type DocInfo struct {
+	ID      string
+	Rev     string
+	Deleted bool
+	Seq     int64
+}
+
+// DocStore indexes documents by ID and by update sequence, as /_all_docs and
+// /_changes require.
+type DocStore interface {
+	// Get returns the summary of the document, or nil if it does not exist.
+	Get(id string) (*DocInfo, error)
+	// Put stores the summary, replacing any previous summary of the same
+	// document, and its position in the sequence index.
+	Put(doc *DocInfo) error
+	// Delete removes the summary of the document, as when it is purged.
+	Delete(id string) error
+	// Range calls fn for each document, in ascending order of ID. If fn
+	// returns an error, Range stops and returns it. fn must not write to the
+	// store.
+	Range(fn func(*DocInfo) error) error
+	// Changes calls fn for each document whose sequence is greater than
+	// since, in ascending order of sequence. It stops as Range does.
+	Changes(since int64, fn func(*DocInfo) error) error
+	// Seq returns the database's current update sequence, which is 0 for a
+	// new database.
+	Seq() (int64, error)
+	// NextSeq increments the database's update sequence, and returns the new
+	// value.
+	NextSeq() (int64, error)
+}
+
+// Revision is a single revision of a document: a node of its revision tree.
+type Revision struct {
+	Rev string
+	// Parent is the ID of the revision this one replaced, or empty for the
+	// first revision of a document.
+	Parent  string
+	Deleted bool
+	// Body is the document content, without special underscore fields. It,
+	// and Attachments, are nil for a revision whose content has been removed
+	// by compaction.
+	Body        map[string]interface{}
+	Attachments map[string]*Attachment
+}
+
+// Attachment is the metadata of an attachment of a revision. Its content is
+// held in the BlobStore, by Digest.
+type Attachment struct {
+	ContentType string
+	Digest      string
+	Length      int64
+	RevPos      int64
+}
+
+// RevTreeStore holds the revisions of each document. A document's revision
+// tree is described by the Parent of each revision; the store records it
+// without interpretation.
+type RevTreeStore interface {
+	// Get returns the revision of the document, or nil if it is not stored.
+	Get(id, rev string) (*Revision, error)
+	// Revs returns all stored revisions of the document, in no particular
+	// order.
+	Revs(id string) ([]*Revision, error)
+	// Put stores the revision, replacing any stored revision of the document
+	// with the same ID.
+	Put(id string, rev *Revision) error
+	// Delete removes all revisions of the document.
+	Delete(id string) error
+}
+
+// BlobStore holds attachment content, addressed by digest. Content stored
+// under a digest never changes, so storing it again is a no-op.
+type BlobStore interface {
+	// Get returns the content stored under digest, or ErrNotFound.
+	Get(digest string) ([]byte, error)
+	// Put stores content under digest.
+	Put(digest string, content []byte) error
+	// Delete removes the content stored under digest, if any.
+	Delete(digest string) error
+	// Range calls fn with each stored digest, in no particular order. It
+	// stops as DocStore.Range does.
+	Range(fn func(digest string) error) error
+}
+
+// IndexStore holds the definitions of the database's Mango indexes.
+type IndexStore interface {
+	// Get returns the index, or nil if it does not exist.
+	Get(ddoc, name string) (*driver.Index, error)
+	// Put stores the index, replacing any index with the same design
+	// document and name.
+	Put(index *driver.Index) error
+	// Delete removes the index, if it exists.
+	Delete(ddoc, name string) error
+	// List returns all indexes, ordered by design document, then name.
+	List() ([]*driver.Index, error)
+}
+
+// LocalDoc is a non-replicating local document. Local documents have no
+// revision history; Rev counts the times the document has been written.
+type LocalDoc struct {
+	ID   string
+	Rev  int64
+	Body map[string]interface{}
+}
+
+// LocalStore holds the database's local documents.
+type LocalStore interface {
+	// Get returns the local document, or nil if it does not exist.
+	Get(id string) (*LocalDoc, error)
+	// Put stores the local document, replacing any with the same ID.
+	Put(doc *LocalDoc) error
+	// Delete removes the local document, if it exists.
+	Delete(id string) error
+	// Range calls fn for each local document, in ascending order of ID. It
+	// stops as DocStore.Range does.
+	Range(fn func(*LocalDoc) error) error
+}
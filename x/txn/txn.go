@@ -0,0 +1,279 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package txn provides best-effort multi-document transactions for Kivik.
+//
+// CouchDB has no multi-document transactions. A Txn emulates one: its writes
+// are sent in a single _bulk_docs request, and if any of them fails, those
+// which succeeded are reverted by further writes, restoring each document to
+// the version read before the transaction was committed.
+//
+// The semantics are strictly best-effort:
+//
+//   - Other clients may observe the partially applied transaction before it
+//     is rolled back, and replication may copy it elsewhere.
+//   - Rolling back is itself a write, so it creates new revisions, and may
+//     fail, for example if another client has since updated a document. Any
+//     such documents are reported in Error.RollbackFailed.
+//   - Reverting a document restores its body as fetched by Get, without
+//     attachment content. Attachment stubs are preserved, but attachments
+//     added by the transaction are lost with the reverted revision.
+//
+// Txn is suitable for grouping related documents whose consistency matters
+// to the application, with a structured way to detect and report failure. It
+// is not a substitute for designing documents so that each update is
+// self-contained.
+package txn // import "github.com/go-kivik/kivik/v4/x/txn"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// Txn is a group of document writes, applied together by Commit. A Txn is not
+// safe for concurrent use, and should not be reused after Commit.
+type Txn struct {
+	db  *kivik.DB
+	ops []op
+	err error
+}
+
+type op struct {
+	docID string
+	doc   map[string]interface{}
+}
+
+// New returns a new, empty transaction against db.
+func New(db *kivik.DB) *Txn {
+	return &Txn{db: db}
+}
+
+// Put adds a write of doc, as docID, to the transaction. As with DB.Put, doc
+// must include the current _rev to update an existing document.
+func (t *Txn) Put(docID string, doc interface{}) {
+	if t.err != nil {
+		return
+	}
+	if docID == "" {
+		t.err = &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: "txn: docID required"}
+		return
+	}
+	m, err := toMap(doc)
+	if err != nil {
+		t.err = err
+		return
+	}
+	m["_id"] = docID
+	t.ops = append(t.ops, op{docID: docID, doc: m})
+}
+
+// Delete adds the deletion of docID at rev to the transaction.
+func (t *Txn) Delete(docID, rev string) {
+	if t.err != nil {
+		return
+	}
+	if docID == "" {
+		t.err = &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: "txn: docID required"}
+		return
+	}
+	t.ops = append(t.ops, op{docID: docID, doc: map[string]interface{}{
+		"_id":      docID,
+		"_rev":     rev,
+		"_deleted": true,
+	}})
+}
+
+func toMap(doc interface{}) (map[string]interface{}, error) {
+	var raw []byte
+	switch t := doc.(type) {
+	case []byte:
+		raw = t
+	case json.RawMessage:
+		raw = t
+	default:
+		var err error
+		if raw, err = json.Marshal(doc); err != nil {
+			return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Err: err}
+		}
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Err: err}
+	}
+	if m == nil {
+		m = map[string]interface{}{}
+	}
+	return m, nil
+}
+
+// DocError associates an error with a document ID.
+type DocError struct {
+	DocID string
+	Err   error
+}
+
+// Error is returned by Commit when the transaction could not be applied in
+// full.
+type Error struct {
+	// Failed lists the writes which failed, causing the rollback.
+	Failed []DocError
+	// RollbackFailed lists documents which were written by the transaction,
+	// but could not be reverted. These documents are left in their
+	// transaction state.
+	RollbackFailed []DocError
+}
+
+var _ error = &Error{}
+
+func (e *Error) Error() string {
+	msgs := make([]string, len(e.Failed))
+	for i, f := range e.Failed {
+		msgs[i] = fmt.Sprintf("%s: %s", f.DocID, f.Err)
+	}
+	msg := "txn: transaction failed: " + strings.Join(msgs, "; ")
+	if len(e.RollbackFailed) > 0 {
+		msg += fmt.Sprintf(" (%d documents could not be rolled back)", len(e.RollbackFailed))
+	}
+	return msg
+}
+
+// StatusCode returns the status of the first failed write, typically 409
+// (conflict).
+func (e *Error) StatusCode() int {
+	if len(e.Failed) == 0 {
+		return http.StatusInternalServerError
+	}
+	return kivik.StatusCode(e.Failed[0].Err)
+}
+
+// prior is the state of a document before the transaction.
+type prior struct {
+	exists bool
+	doc    map[string]interface{}
+}
+
+// Commit applies the transaction, returning the new revision of each
+// document, keyed by document ID. If any write fails, the others are rolled
+// back, and an *Error is returned. Errors encountered before anything is
+// written, such as a document appearing twice, are returned directly, as are
+// errors from the bulk request itself, in which case the outcome of each
+// write is unknown.
+func (t *Txn) Commit(ctx context.Context) (map[string]string, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	if len(t.ops) == 0 {
+		return map[string]string{}, nil
+	}
+	seen := make(map[string]bool, len(t.ops))
+	for _, o := range t.ops {
+		if seen[o.docID] {
+			return nil, &kivik.Error{HTTPStatus: http.StatusBadRequest, Message: fmt.Sprintf("txn: document %q appears more than once", o.docID)}
+		}
+		seen[o.docID] = true
+	}
+	priors, err := t.fetchPriors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]interface{}, len(t.ops))
+	for i, o := range t.ops {
+		docs[i] = o.doc
+	}
+	revs, failed, err := bulk(ctx, t.db, docs)
+	if err != nil {
+		return nil, err
+	}
+	if len(failed) == 0 {
+		return revs, nil
+	}
+	return nil, &Error{
+		Failed:         failed,
+		RollbackFailed: t.rollback(ctx, priors, revs),
+	}
+}
+
+func (t *Txn) fetchPriors(ctx context.Context) (map[string]prior, error) {
+	priors := make(map[string]prior, len(t.ops))
+	for _, o := range t.ops {
+		var doc map[string]interface{}
+		err := t.db.Get(ctx, o.docID).ScanDoc(&doc)
+		switch {
+		case kivik.IsNotFound(err):
+			priors[o.docID] = prior{}
+		case err != nil:
+			return nil, err
+		default:
+			priors[o.docID] = prior{exists: true, doc: doc}
+		}
+	}
+	return priors, nil
+}
+
+// rollback reverts the documents written, as recorded in revs, to their
+// prior state, and returns any which could not be reverted.
+func (t *Txn) rollback(ctx context.Context, priors map[string]prior, revs map[string]string) []DocError {
+	if len(revs) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, 0, len(revs))
+	for _, o := range t.ops {
+		rev, ok := revs[o.docID]
+		if !ok {
+			continue
+		}
+		p := priors[o.docID]
+		if !p.exists {
+			docs = append(docs, map[string]interface{}{
+				"_id":      o.docID,
+				"_rev":     rev,
+				"_deleted": true,
+			})
+			continue
+		}
+		p.doc["_rev"] = rev
+		docs = append(docs, p.doc)
+	}
+	_, failed, err := bulk(ctx, t.db, docs)
+	if err != nil {
+		failed = failed[:0]
+		for _, doc := range docs {
+			failed = append(failed, DocError{DocID: doc.(map[string]interface{})["_id"].(string), Err: err})
+		}
+	}
+	return failed
+}
+
+// bulk writes docs, returning the new revisions of those written, and the
+// errors for those which were not.
+func bulk(ctx context.Context, db *kivik.DB, docs []interface{}) (map[string]string, []DocError, error) {
+	results, err := db.BulkDocs(ctx, docs)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer results.Close() // nolint: errcheck
+	revs := make(map[string]string, len(docs))
+	var failed []DocError
+	for results.Next() {
+		if err := results.UpdateErr(); err != nil {
+			failed = append(failed, DocError{DocID: results.ID(), Err: err})
+			continue
+		}
+		revs[results.ID()] = results.Rev()
+	}
+	return revs, failed, results.Err()
+}
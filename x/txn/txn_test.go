@@ -0,0 +1,150 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package txn
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/memtest"
+)
+
+func put(t *testing.T, db *kivik.DB, docID string, doc interface{}) string {
+	t.Helper()
+	rev, err := db.Put(context.Background(), docID, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rev
+}
+
+func getDoc(t *testing.T, db *kivik.DB, docID string) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	err := db.Get(context.Background(), docID).ScanDoc(&doc)
+	if kivik.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(doc, "_rev")
+	return doc
+}
+
+func TestCommit(t *testing.T) {
+	ctx := context.Background()
+	db := memtest.NewDB(t)
+	rev := put(t, db, "existing", map[string]string{"a": "b"})
+	delRev := put(t, db, "doomed", map[string]string{})
+
+	txn := New(db)
+	txn.Put("new", map[string]string{"x": "y"})
+	txn.Put("existing", map[string]string{"_rev": rev, "a": "c"})
+	txn.Delete("doomed", delRev)
+	revs, err := txn.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 3 {
+		t.Errorf("Unexpected revs: %v", revs)
+	}
+	expected := map[string]map[string]interface{}{
+		"new":      {"_id": "new", "x": "y"},
+		"existing": {"_id": "existing", "a": "c"},
+		"doomed":   nil,
+	}
+	for id, want := range expected {
+		if d := testy.DiffInterface(want, getDoc(t, db, id)); d != nil {
+			t.Errorf("%s: %s", id, d)
+		}
+	}
+}
+
+func TestCommitRollback(t *testing.T) {
+	ctx := context.Background()
+	db := memtest.NewDB(t)
+	rev := put(t, db, "existing", map[string]string{"a": "b"})
+	put(t, db, "conflicting", map[string]string{"c": "d"})
+
+	txn := New(db)
+	txn.Put("new", map[string]string{"x": "y"})
+	txn.Put("existing", map[string]string{"_rev": rev, "a": "changed"})
+	txn.Put("conflicting", map[string]string{"c": "changed"})
+	_, err := txn.Commit(ctx)
+	testy.StatusError(t, "txn: transaction failed: conflicting: document update conflict", http.StatusConflict, err)
+	terr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("Unexpected error type: %T", err)
+	}
+	if len(terr.RollbackFailed) != 0 {
+		t.Errorf("Unexpected rollback failures: %v", terr.RollbackFailed)
+	}
+	expected := map[string]map[string]interface{}{
+		"new":         nil,
+		"existing":    {"_id": "existing", "a": "b"},
+		"conflicting": {"_id": "conflicting", "c": "d"},
+	}
+	for id, want := range expected {
+		if d := testy.DiffInterface(want, getDoc(t, db, id)); d != nil {
+			t.Errorf("%s: %s", id, d)
+		}
+	}
+}
+
+func TestCommitErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		build  func(*Txn)
+		status int
+		err    string
+	}{
+		{
+			name:  "empty",
+			build: func(*Txn) {},
+		},
+		{
+			name:   "missing docID",
+			build:  func(txn *Txn) { txn.Put("", map[string]string{}) },
+			status: http.StatusBadRequest,
+			err:    "txn: docID required",
+		},
+		{
+			name:   "invalid doc",
+			build:  func(txn *Txn) { txn.Put("foo", []byte("invalid")) },
+			status: http.StatusBadRequest,
+			err:    "invalid character 'i' looking for beginning of value",
+		},
+		{
+			name: "duplicate",
+			build: func(txn *Txn) {
+				txn.Put("foo", map[string]string{})
+				txn.Delete("foo", "1-xxx")
+			},
+			status: http.StatusBadRequest,
+			err:    `txn: document "foo" appears more than once`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			txn := New(memtest.NewDB(t))
+			test.build(txn)
+			_, err := txn.Commit(context.Background())
+			testy.StatusError(t, test.err, test.status, err)
+		})
+	}
+}
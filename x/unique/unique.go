@@ -0,0 +1,149 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package unique enforces uniqueness of values across distributed writers,
+// using the reserve-document pattern.
+//
+// A value is reserved by creating a document with an ID derived from the
+// value's namespace and the value itself, recording the reservation's owner.
+// As CouchDB allows only one document per ID, only one writer can create the
+// reservation; any other receives a conflict. The reservation is released by
+// deleting the document, and transferred by updating it. Package lookup
+// provides a similar mechanism, maintained automatically as documents are
+// written.
+//
+// Reservations are only enforced within a single CouchDB instance or
+// cluster. Replication between databases accepting writes independently may
+// produce conflicting reservations, each accepted by one side.
+package unique // import "github.com/go-kivik/kivik/v4/x/unique"
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// reservation is the content of a reservation document.
+type reservation struct {
+	Rev   string `json:"_rev,omitempty"`
+	Owner string `json:"owner"`
+}
+
+func docID(namespace, value string) string {
+	return "unique:" + namespace + ":" + value
+}
+
+// ClaimedError is returned when a value is reserved by another owner.
+type ClaimedError struct {
+	Namespace string
+	Value     string
+	// Owner is the current owner of the value.
+	Owner string
+}
+
+var _ error = &ClaimedError{}
+
+func (e *ClaimedError) Error() string {
+	return fmt.Sprintf("unique: %s %q is claimed by %s", e.Namespace, e.Value, e.Owner)
+}
+
+// StatusCode returns 409 (conflict).
+func (e *ClaimedError) StatusCode() int {
+	return http.StatusConflict
+}
+
+func notClaimed(namespace, value string) error {
+	return &kivik.Error{HTTPStatus: http.StatusNotFound, Message: fmt.Sprintf("unique: %s %q is not claimed", namespace, value)}
+}
+
+// get returns the reservation for value, or a status 404 error if there is
+// none.
+func get(ctx context.Context, db *kivik.DB, namespace, value string) (*reservation, error) {
+	var r reservation
+	err := db.Get(ctx, docID(namespace, value)).ScanDoc(&r)
+	if kivik.IsNotFound(err) {
+		return nil, notClaimed(namespace, value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Owner returns the owner of value in namespace, or a status 404 error if
+// value is not claimed.
+func Owner(ctx context.Context, db *kivik.DB, namespace, value string) (string, error) {
+	r, err := get(ctx, db, namespace, value)
+	if err != nil {
+		return "", err
+	}
+	return r.Owner, nil
+}
+
+// Claim reserves value in namespace for ownerID. If the value is already
+// claimed by ownerID, Claim succeeds without change. If it is claimed by
+// another owner, a *ClaimedError is returned.
+func Claim(ctx context.Context, db *kivik.DB, namespace, value, ownerID string) error {
+	_, err := db.Put(ctx, docID(namespace, value), reservation{Owner: ownerID})
+	if !kivik.IsConflict(err) {
+		return err
+	}
+	r, err := get(ctx, db, namespace, value)
+	if kivik.IsNotFound(err) {
+		// Released since the conflict; try once more.
+		_, err = db.Put(ctx, docID(namespace, value), reservation{Owner: ownerID})
+		if !kivik.IsConflict(err) {
+			return err
+		}
+		r, err = get(ctx, db, namespace, value)
+	}
+	if err != nil {
+		return err
+	}
+	if r.Owner != ownerID {
+		return &ClaimedError{Namespace: namespace, Value: value, Owner: r.Owner}
+	}
+	return nil
+}
+
+// Release releases ownerID's claim on value in namespace, making it available
+// to be claimed again. It returns a status 404 error if value is not claimed,
+// and a *ClaimedError if it is claimed by another owner.
+func Release(ctx context.Context, db *kivik.DB, namespace, value, ownerID string) error {
+	r, err := get(ctx, db, namespace, value)
+	if err != nil {
+		return err
+	}
+	if r.Owner != ownerID {
+		return &ClaimedError{Namespace: namespace, Value: value, Owner: r.Owner}
+	}
+	_, err = db.Delete(ctx, docID(namespace, value), r.Rev)
+	return err
+}
+
+// Transfer moves the claim on value in namespace from fromOwner to toOwner,
+// without the value becoming available to others in between. It returns a
+// status 404 error if value is not claimed, and a *ClaimedError if it is not
+// claimed by fromOwner.
+func Transfer(ctx context.Context, db *kivik.DB, namespace, value, fromOwner, toOwner string) error {
+	r, err := get(ctx, db, namespace, value)
+	if err != nil {
+		return err
+	}
+	if r.Owner != fromOwner {
+		return &ClaimedError{Namespace: namespace, Value: value, Owner: r.Owner}
+	}
+	_, err = db.Put(ctx, docID(namespace, value), reservation{Rev: r.Rev, Owner: toOwner})
+	return err
+}
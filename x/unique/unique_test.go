@@ -0,0 +1,185 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package unique
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/internal/memtest"
+)
+
+func claim(t *testing.T, db *kivik.DB, value, ownerID string) {
+	t.Helper()
+	if err := Claim(context.Background(), db, "email", value, ownerID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	type tt struct {
+		db     *kivik.DB
+		owner  string
+		status int
+		err    string
+		// expected is the owner after the claim
+		expected string
+	}
+	tests := testy.NewTable()
+	tests.Add("unclaimed", func(t *testing.T) interface{} {
+		return tt{
+			db:       memtest.NewDB(t),
+			owner:    "bob",
+			expected: "bob",
+		}
+	})
+	tests.Add("claimed by owner", func(t *testing.T) interface{} {
+		db := memtest.NewDB(t)
+		claim(t, db, "bob@example.com", "bob")
+		return tt{
+			db:       db,
+			owner:    "bob",
+			expected: "bob",
+		}
+	})
+	tests.Add("claimed by other", func(t *testing.T) interface{} {
+		db := memtest.NewDB(t)
+		claim(t, db, "bob@example.com", "alice")
+		return tt{
+			db:       db,
+			owner:    "bob",
+			status:   http.StatusConflict,
+			err:      `unique: email "bob@example.com" is claimed by alice`,
+			expected: "alice",
+		}
+	})
+	tests.Add("released", func(t *testing.T) interface{} {
+		db := memtest.NewDB(t)
+		claim(t, db, "bob@example.com", "alice")
+		if err := Release(context.Background(), db, "email", "bob@example.com", "alice"); err != nil {
+			t.Fatal(err)
+		}
+		return tt{
+			db:       db,
+			owner:    "bob",
+			expected: "bob",
+		}
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		err := Claim(context.Background(), tt.db, "email", "bob@example.com", tt.owner)
+		testy.StatusError(t, tt.err, tt.status, err)
+		owner, err := Owner(context.Background(), tt.db, "email", "bob@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if owner != tt.expected {
+			t.Errorf("Unexpected owner: %s", owner)
+		}
+	})
+}
+
+func TestRelease(t *testing.T) {
+	type tt struct {
+		db     *kivik.DB
+		status int
+		err    string
+	}
+	tests := testy.NewTable()
+	tests.Add("not claimed", func(t *testing.T) interface{} {
+		return tt{
+			db:     memtest.NewDB(t),
+			status: http.StatusNotFound,
+			err:    `unique: email "bob@example.com" is not claimed`,
+		}
+	})
+	tests.Add("claimed by other", func(t *testing.T) interface{} {
+		db := memtest.NewDB(t)
+		claim(t, db, "bob@example.com", "alice")
+		return tt{
+			db:     db,
+			status: http.StatusConflict,
+			err:    `unique: email "bob@example.com" is claimed by alice`,
+		}
+	})
+	tests.Add("success", func(t *testing.T) interface{} {
+		db := memtest.NewDB(t)
+		claim(t, db, "bob@example.com", "bob")
+		return tt{db: db}
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		err := Release(context.Background(), tt.db, "email", "bob@example.com", "bob")
+		testy.StatusError(t, tt.err, tt.status, err)
+		_, err = Owner(context.Background(), tt.db, "email", "bob@example.com")
+		if !kivik.IsNotFound(err) {
+			t.Errorf("Expected value to be unclaimed, got %v", err)
+		}
+	})
+}
+
+func TestTransfer(t *testing.T) {
+	type tt struct {
+		db       *kivik.DB
+		status   int
+		err      string
+		expected string
+	}
+	tests := testy.NewTable()
+	tests.Add("not claimed", func(t *testing.T) interface{} {
+		return tt{
+			db:     memtest.NewDB(t),
+			status: http.StatusNotFound,
+			err:    `unique: email "bob@example.com" is not claimed`,
+		}
+	})
+	tests.Add("claimed by other", func(t *testing.T) interface{} {
+		db := memtest.NewDB(t)
+		claim(t, db, "bob@example.com", "carol")
+		return tt{
+			db:       db,
+			status:   http.StatusConflict,
+			err:      `unique: email "bob@example.com" is claimed by carol`,
+			expected: "carol",
+		}
+	})
+	tests.Add("success", func(t *testing.T) interface{} {
+		db := memtest.NewDB(t)
+		claim(t, db, "bob@example.com", "alice")
+		return tt{
+			db:       db,
+			expected: "bob",
+		}
+	})
+
+	tests.Run(t, func(t *testing.T, tt tt) {
+		err := Transfer(context.Background(), tt.db, "email", "bob@example.com", "alice", "bob")
+		testy.StatusError(t, tt.err, tt.status, err)
+		owner, _ := Owner(context.Background(), tt.db, "email", "bob@example.com")
+		if owner != tt.expected {
+			t.Errorf("Unexpected owner: %q", owner)
+		}
+	})
+}
+
+func TestNamespaces(t *testing.T) {
+	db := memtest.NewDB(t)
+	claim(t, db, "bob", "alice")
+	if err := Claim(context.Background(), db, "username", "bob", "bob"); err != nil {
+		t.Fatal(err)
+	}
+}